@@ -0,0 +1,139 @@
+// Command snoop-injector is a mutating admission webhook that adds the snoop
+// sidecar to pods selected by label, by annotation, or by an annotation on
+// their namespace.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/chainguard-dev/clog/slag"
+	"github.com/imjasonh/snoop/pkg/inject"
+	"github.com/imjasonh/snoop/pkg/kube"
+)
+
+// certReload is how often the serving certificate is re-read, so that
+// certificates rotated by cert-manager are picked up without a restart.
+const certReload = time.Minute
+
+func main() {
+	var (
+		addr     string
+		certFile string
+		keyFile  string
+		image    string
+		selector string
+		args     string
+		logLevel slag.Level
+	)
+	flag.StringVar(&addr, "addr", ":8443", "Address to serve the webhook on")
+	flag.StringVar(&certFile, "tls-cert", "/etc/snoop-injector/tls/tls.crt", "TLS certificate to serve with")
+	flag.StringVar(&keyFile, "tls-key", "/etc/snoop-injector/tls/tls.key", "TLS private key to serve with")
+	flag.StringVar(&image, "image", inject.DefaultImage, "snoop image to inject")
+	flag.StringVar(&selector, "selector", inject.AnnotationInject+"=true", "Label selector (e.g. app=web,tier) of pods to inject; pods and namespaces can also opt in with the "+inject.AnnotationInject+"=true annotation")
+	flag.StringVar(&args, "args", "", "Whitespace-separated snoop flags added to every injected sidecar (e.g. \"-packages -interval=1m\")")
+	flag.Var(&logLevel, "log-level", "Log level (debug, info, warn, error)")
+	flag.Parse()
+
+	ctx := clog.WithLogger(context.Background(), clog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.Level(logLevel),
+	})))
+	log := clog.FromContext(ctx)
+
+	sel, err := inject.ParseSelector(selector)
+	if err != nil {
+		log.Fatalf("Invalid -selector: %v", err)
+	}
+	injector := &inject.Injector{
+		Image:    image,
+		Args:     strings.Fields(args),
+		Selector: sel,
+	}
+	if kube.InCluster() {
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			log.Fatalf("Creating Kubernetes client: %v", err)
+		}
+		injector.Namespace = func(ctx context.Context, name string) (*kube.ObjectMeta, error) {
+			ns, err := client.GetNamespace(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			return &ns.Metadata, nil
+		}
+	} else {
+		log.Warn("Not running in a cluster, namespace annotations are ignored")
+	}
+
+	certs := &certLoader{certFile: certFile, keyFile: keyFile}
+	if _, err := certs.get(nil); err != nil {
+		log.Fatalf("Loading TLS certificate: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /mutate", injector)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{GetCertificate: certs.get, MinVersion: tls.VersionTLS12},
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Info("Received shutdown signal")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("Serving sidecar injection webhook on %s (selector %q)", addr, selector)
+	if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Webhook server error: %v", err)
+	}
+}
+
+// certLoader serves a TLS key pair from files, re-reading them every
+// certReload.
+type certLoader struct {
+	certFile, keyFile string
+
+	mu     sync.Mutex
+	cert   *tls.Certificate
+	loaded time.Time
+}
+
+func (l *certLoader) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cert != nil && time.Since(l.loaded) < certReload {
+		return l.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		if l.cert != nil {
+			// Keep serving the previous certificate mid-rotation
+			return l.cert, nil
+		}
+		return nil, err
+	}
+	l.cert, l.loaded = &cert, time.Now()
+	return l.cert, nil
+}
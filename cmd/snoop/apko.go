@@ -0,0 +1,109 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+const (
+	defaultApkoRepository = "https://packages.wolfi.dev/os"
+	defaultApkoKeyring    = "https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"
+)
+
+// runApko implements `snoop apko`, which turns observed package usage into
+// an apko configuration for a minimal image.
+func runApko(args []string) error {
+	fs := flag.NewFlagSet("apko", flag.ExitOnError)
+	output := fs.String("o", "-", "Path to write the apko config (- for stdout)")
+	container := fs.String("container", "", "Container to generate a config for (required if the report has more than one)")
+	repositories := fs.String("repositories", defaultApkoRepository, "Comma-separated apk repositories")
+	keyrings := fs.String("keyrings", defaultApkoKeyring, "Comma-separated apk signing keys")
+	archs := fs.String("archs", "", "Comma-separated architectures to build for (default: apko's default)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop apko [-container name] [-o apko.yaml] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Generate an apko config that installs only the apk packages a container used.\n")
+		fmt.Fprintf(fs.Output(), "The report must have been recorded with -packages.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+	opts := reporter.ApkoOptions{
+		Repositories: splitList(*repositories),
+		Keyrings:     splitList(*keyrings),
+		Archs:        splitList(*archs),
+	}
+
+	if *output == "" || *output == "-" {
+		return writeApko(os.Stdout, c, opts)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeApko(f, c, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeApko(w io.Writer, c reporter.ContainerReport, opts reporter.ApkoOptions) error {
+	bw := bufio.NewWriter(w)
+	if err := reporter.WriteApkoConfig(bw, c, opts); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// selectContainer returns the named container, or the only container if
+// name is empty.
+func selectContainer(report *reporter.Report, name string) (reporter.ContainerReport, error) {
+	if name == "" {
+		if len(report.Containers) != 1 {
+			names := make([]string, 0, len(report.Containers))
+			for _, c := range report.Containers {
+				names = append(names, c.Name)
+			}
+			return reporter.ContainerReport{}, fmt.Errorf("report has %d containers (%s); pick one with -container", len(names), strings.Join(names, ", "))
+		}
+		return report.Containers[0], nil
+	}
+	for _, c := range report.Containers {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return reporter.ContainerReport{}, fmt.Errorf("container %q not found in report", name)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var result []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
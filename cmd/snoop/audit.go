@@ -0,0 +1,38 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runVerifyAudit implements `snoop verify-audit`, which checks that an audit
+// log written with -audit-log hasn't been altered.
+func runVerifyAudit(args []string) error {
+	fs := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+	head := fs.String("head", "", "Hash of a record noted earlier, e.g. the last one when the log was archived, to also check that records weren't removed from the end")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop verify-audit [flags] <audit-log>\n\n")
+		fmt.Fprintf(fs.Output(), "Check that every record of an audit log is intact and chained to the one before\nit, and print the number of records and the hash of the last one as JSON.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var anchors []string
+	if *head != "" {
+		anchors = append(anchors, *head)
+	}
+	got, err := reporter.VerifyAuditFile(fs.Arg(0), anchors...)
+	if err != nil {
+		return err
+	}
+	return writeJSON("", got)
+}
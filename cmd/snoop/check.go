@@ -0,0 +1,36 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/preflight"
+)
+
+// runCheck implements `snoop check`, which checks that the host can run the
+// eBPF probe before snoop is deployed to it.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop check\n\n")
+		fmt.Fprintf(fs.Output(), "Check the kernel version, BTF, cgroup v2, capabilities, ring buffer support, and\nsyscall tracepoints snoop needs, and that its eBPF programs load.\n")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	results := preflight.Run(preflight.DefaultPaths)
+	if err := preflight.Write(os.Stdout, results); err != nil {
+		return err
+	}
+	if preflight.Failed(results) {
+		return errors.New("some checks failed")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// subcommands maps subcommand names to their entry points. Each receives the
+// arguments following the subcommand name.
+var subcommands = map[string]func(args []string) error{
+	"merge":        runMerge,
+	"diff":         runDiff,
+	"html":         runHTML,
+	"export":       runExport,
+	"apko":         runApko,
+	"dockerfile":   runDockerfile,
+	"slim":         runSlim,
+	"rootfs":       runRootFS,
+	"selinux":      runSELinux,
+	"dirs":         runDirs,
+	"jars":         runJars,
+	"layers":       runLayers,
+	"check":        runCheck,
+	"version":      runVersion,
+	"verify-audit": runVerifyAudit,
+}
+
+// readReport decodes a report file of any supported schema version.
+func readReport(path string) (*reporter.Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	report, err := reporter.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
+
+// writeJSON writes v as indented JSON to path, or to stdout if path is empty or "-".
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling output: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "" || path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
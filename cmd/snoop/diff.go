@@ -0,0 +1,89 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runDiff implements `snoop diff`, which compares two reports.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format (text or json)")
+	output := fs.String("o", "-", "Path to write the diff (- for stdout)")
+	exitCode := fs.Bool("exit-code", false, "Exit with status 1 if the reports differ")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop diff [-format text|json] [-o diff.json] old.json new.json\n\n")
+		fmt.Fprintf(fs.Output(), "Show files added and removed per container between two reports.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	old, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	new, err := readReport(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	diff := reporter.Diff(old, new)
+
+	switch *format {
+	case "json":
+		if err := writeJSON(*output, diff); err != nil {
+			return err
+		}
+	case "text":
+		w := io.Writer(os.Stdout)
+		if *output != "" && *output != "-" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		writeDiffText(w, diff)
+	default:
+		return fmt.Errorf("unknown format %q (must be text or json)", *format)
+	}
+
+	if *exitCode && !diff.Empty() {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// writeDiffText writes a human-readable, unified-diff style summary.
+func writeDiffText(w io.Writer, diff *reporter.ReportDiff) {
+	if diff.Empty() {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+	for _, c := range diff.Containers {
+		fmt.Fprintf(w, "container %s (%s): +%d -%d\n", c.Name, c.Status, len(c.AddedFiles), len(c.RemovedFiles))
+		for _, f := range c.AddedFiles {
+			fmt.Fprintf(w, "  + %s\n", f)
+		}
+		for _, f := range c.RemovedFiles {
+			fmt.Fprintf(w, "  - %s\n", f)
+		}
+		for _, p := range c.AddedPackages {
+			fmt.Fprintf(w, "  + package %s\n", p)
+		}
+		for _, p := range c.RemovedPackages {
+			fmt.Fprintf(w, "  - package %s\n", p)
+		}
+	}
+}
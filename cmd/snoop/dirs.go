@@ -0,0 +1,58 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runDirs implements `snoop dirs`, which shows how much of each directory of
+// a container's image was accessed.
+func runDirs(args []string) error {
+	fs := flag.NewFlagSet("dirs", flag.ExitOnError)
+	container := fs.String("container", "", "Container to show (required if the report has more than one)")
+	depth := fs.Int("depth", reporter.DefaultDirectoryDepth, "Deepest directory level to show, e.g. 2 for /usr/share")
+	sortBy := fs.String("sort", "path", "Order of directories (path or unused)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop dirs [-container name] [-depth 2] [-sort path|unused] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Show the share of each image directory's files a container accessed, to spot\n")
+		fmt.Fprintf(fs.Output(), "unused locales, docs, and the like. Record the report with -coverage.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *depth < 1 {
+		return fmt.Errorf("-depth must be at least 1")
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+	if c.Coverage == nil {
+		return fmt.Errorf("container %s has no coverage; record the report with -layers and -coverage", c.Name)
+	}
+
+	dirs := reporter.DirectoryUtilization(c.Coverage, *depth)
+	switch *sortBy {
+	case "path":
+	case "unused":
+		sort.SliceStable(dirs, func(i, j int) bool { return dirs[i].UnusedBytes() > dirs[j].UnusedBytes() })
+	default:
+		return fmt.Errorf("unknown sort order %q (must be path or unused)", *sortBy)
+	}
+	return reporter.WriteDirectoryUtilization(os.Stdout, dirs)
+}
@@ -0,0 +1,500 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/cgroup"
+	"github.com/imjasonh/snoop/pkg/config"
+	"github.com/imjasonh/snoop/pkg/containerd"
+	"github.com/imjasonh/snoop/pkg/docker"
+	"github.com/imjasonh/snoop/pkg/ebpf"
+	"github.com/imjasonh/snoop/pkg/health"
+	"github.com/imjasonh/snoop/pkg/kube"
+	"github.com/imjasonh/snoop/pkg/processor"
+)
+
+// eventRetry is how long to wait before resubscribing to runtime events
+// after the stream ends.
+const eventRetry = 5 * time.Second
+
+// restartWindow is how long the report of a container that went away is
+// kept for folding into a restarted container of the same name. It exceeds
+// Kubernetes' 5 minute cap on the backoff between restarts of a crashing
+// container.
+const restartWindow = 10 * time.Minute
+
+// containerSource finds the containers to trace and identifies them.
+type containerSource interface {
+	// discover returns the containers to trace, keyed by cgroup ID.
+	discover(ctx context.Context) (map[uint64]*cgroup.ContainerInfo, error)
+
+	// identify returns the name and image of each container.
+	identify(ctx context.Context, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage
+
+	// rootfs returns where a container's root filesystem can be read
+	// directly, or "" to read it through /proc/<pid>/root.
+	rootfs(info *cgroup.ContainerInfo) string
+
+	// events returns a channel signalled when containers may have started
+	// or stopped, or nil if the source can't tell.
+	events(ctx context.Context) <-chan struct{}
+}
+
+// newContainerSource returns the source selected by the configuration.
+func newContainerSource(cfg *config.Config) containerSource {
+	if len(cfg.RunCommand) > 0 || cfg.RunPID != 0 {
+		return newProcessSource(cfg)
+	}
+	if cfg.SystemdUnit != "" {
+		return newSystemdSource(cfg)
+	}
+	if cfg.DockerSocket != "" {
+		s := &dockerSource{cfg: cfg, client: docker.NewClient(cfg.DockerSocket)}
+		if cfg.ComposeProject != "" {
+			s.labels = []string{docker.LabelComposeProject + "=" + cfg.ComposeProject}
+		}
+		return s
+	}
+	var ctrd *containerd.Client
+	if cfg.ContainerdSocket != "" {
+		ctrd = containerd.NewClient(cfg.ContainerdSocket, cfg.ContainerdNS)
+	}
+	if cfg.Node {
+		return &nodeSource{podSource{cfg: cfg, containerd: ctrd}}
+	}
+	return &podSource{cfg: cfg, containerd: ctrd}
+}
+
+// podSource traces the other containers in snoop's pod, found through the
+// pod's cgroup directory.
+type podSource struct {
+	cfg        *config.Config
+	containerd *containerd.Client // nil unless a containerd socket is configured
+}
+
+func (s *podSource) discover(context.Context) (map[uint64]*cgroup.ContainerInfo, error) {
+	return cgroup.DiscoverAllExceptSelf()
+}
+
+func (s *podSource) identify(ctx context.Context, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage {
+	return resolveImages(ctx, s.cfg, containers)
+}
+
+// rootfs returns the container's task rootfs in containerd's state
+// directory, if it is mounted into snoop's container.
+func (s *podSource) rootfs(info *cgroup.ContainerInfo) string {
+	if s.containerd == nil || info.ID == "" {
+		return ""
+	}
+	dir := s.containerd.Rootfs(info.ID)
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// events watches the pod cgroup directory for container cgroups being
+// created and removed, and containerd for task starts and exits if a
+// socket is configured.
+func (s *podSource) events(ctx context.Context) <-chan struct{} {
+	triggers := []<-chan struct{}{s.containerdEvents(ctx)}
+	if dir, err := cgroup.PodCgroupPath(); err != nil {
+		clog.FromContext(ctx).Warnf("Not watching pod cgroup: %v", err)
+	} else if ch, err := cgroup.WatchDir(ctx, filepath.Join("/sys/fs/cgroup", dir)); err != nil {
+		clog.FromContext(ctx).Warnf("Not watching pod cgroup: %v", err)
+	} else {
+		clog.FromContext(ctx).Infof("Watching pod cgroup %s for containers", dir)
+		triggers = append(triggers, ch)
+	}
+
+	return mergeTriggers(ctx, triggers...)
+}
+
+// containerdEvents subscribes to containerd's task events, or returns nil
+// if no containerd socket is configured.
+func (s *podSource) containerdEvents(ctx context.Context) <-chan struct{} {
+	if s.containerd == nil {
+		return nil
+	}
+	return subscribe(ctx, "containerd", func(ctx context.Context) (<-chan string, func() error, error) {
+		events, wait, err := s.containerd.Subscribe(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		ch := make(chan string)
+		go func() {
+			defer close(ch)
+			for ev := range events {
+				ch <- fmt.Sprintf("%s %s", ev.Topic, ev.ContainerID)
+			}
+		}()
+		return ch, wait, nil
+	})
+}
+
+// nodeSource traces the containers of every pod on the node, as a
+// DaemonSet. It needs the host's cgroup namespace.
+type nodeSource struct {
+	podSource
+}
+
+func (s *nodeSource) discover(context.Context) (map[uint64]*cgroup.ContainerInfo, error) {
+	return cgroup.DiscoverNode()
+}
+
+func (s *nodeSource) identify(ctx context.Context, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage {
+	return resolveNodeImages(ctx, s.cfg, containers)
+}
+
+// events only watches containerd, if configured; pod cgroups are spread
+// over several directories, so other changes are found by rescanning.
+func (s *nodeSource) events(ctx context.Context) <-chan struct{} {
+	return s.containerdEvents(ctx)
+}
+
+// dockerSource traces the running containers of a Docker host, except
+// snoop's own. It needs the host's PID namespace to map containers to
+// their cgroups.
+type dockerSource struct {
+	cfg    *config.Config
+	client *docker.Client
+	labels []string // Only trace containers with these labels
+
+	// mu guards containers, the last discovered containers by cgroup ID.
+	mu         sync.Mutex
+	containers map[uint64]docker.Container
+}
+
+func (s *dockerSource) discover(ctx context.Context) (map[uint64]*cgroup.ContainerInfo, error) {
+	selfID, err := cgroup.GetSelfCgroupID()
+	if err != nil {
+		return nil, fmt.Errorf("getting self cgroup ID: %w", err)
+	}
+	list, err := s.client.List(ctx, s.labels...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint64]*cgroup.ContainerInfo, len(list))
+	byCgroup := make(map[uint64]docker.Container, len(list))
+	for _, ctr := range list {
+		path, err := cgroup.GetCgroupPathByPID(ctr.PID)
+		if err != nil {
+			clog.FromContext(ctx).Debugf("Skipping container %s: %v", ctr.Name, err)
+			continue
+		}
+		cgroupID, err := cgroup.GetCgroupIDByPath(path)
+		if err != nil || cgroupID == selfID {
+			continue
+		}
+		result[cgroupID] = &cgroup.ContainerInfo{
+			CgroupID:   cgroupID,
+			CgroupPath: path,
+			Name:       containerDisplayName(ctr),
+			ID:         ctr.ID,
+		}
+		byCgroup[cgroupID] = ctr
+	}
+
+	s.mu.Lock()
+	s.containers = byCgroup
+	s.mu.Unlock()
+	return result, nil
+}
+
+func (s *dockerSource) identify(_ context.Context, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[uint64]kube.ContainerImage, len(containers))
+	for cgroupID := range containers {
+		img := kube.ContainerImage{Ref: s.cfg.ImageRef, Digest: s.cfg.ImageDigest}
+		if ctr, ok := s.containers[cgroupID]; ok {
+			img = kube.ContainerImage{Name: containerDisplayName(ctr), Ref: ctr.Image, Digest: ctr.ImageDigest}
+		}
+		result[cgroupID] = img
+	}
+	return result
+}
+
+// rootfs returns the container's merged overlay directory, if the Docker
+// data directory is mounted into snoop's container at the same path.
+func (s *dockerSource) rootfs(info *cgroup.ContainerInfo) string {
+	s.mu.Lock()
+	dir := s.containers[info.CgroupID].Rootfs
+	s.mu.Unlock()
+	if dir == "" {
+		return ""
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
+func (s *dockerSource) events(ctx context.Context) <-chan struct{} {
+	return subscribe(ctx, "Docker", func(ctx context.Context) (<-chan string, func() error, error) {
+		events, wait, err := s.client.Events(ctx, s.labels...)
+		if err != nil {
+			return nil, nil, err
+		}
+		ch := make(chan string)
+		go func() {
+			defer close(ch)
+			for ev := range events {
+				ch <- fmt.Sprintf("%s %s", ev.Action, ev.ContainerID)
+			}
+		}()
+		return ch, wait, nil
+	})
+}
+
+// containerDisplayName names a Docker container in reports: by its Compose
+// service, with the replica number for replicas after the first, or by its
+// Docker name for containers Compose didn't create.
+func containerDisplayName(ctr docker.Container) string {
+	service := ctr.Labels[docker.LabelComposeService]
+	if service == "" {
+		return ctr.Name
+	}
+	if n := ctr.Labels[docker.LabelComposeNumber]; n != "" && n != "1" {
+		return service + "-" + n
+	}
+	return service
+}
+
+// subscribe keeps a runtime event stream open, resubscribing after
+// eventRetry whenever it ends, and signals the returned channel on each
+// event. Signals are coalesced while a rescan is pending.
+func subscribe(ctx context.Context, runtime string, open func(ctx context.Context) (<-chan string, func() error, error)) <-chan struct{} {
+	log := clog.FromContext(ctx)
+	trigger := make(chan struct{}, 1)
+	go func() {
+		for ctx.Err() == nil {
+			events, wait, err := open(ctx)
+			if err == nil {
+				log.Infof("Watching %s for container starts and exits", runtime)
+				for ev := range events {
+					log.Debugf("%s event: %s", runtime, ev)
+					select {
+					case trigger <- struct{}{}:
+					default:
+					}
+				}
+				err = wait()
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnf("%s event stream ended, retrying in %s: %v", runtime, eventRetry, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(eventRetry):
+			}
+		}
+	}()
+	return trigger
+}
+
+// mergeTriggers returns a channel signalled whenever any of triggers is,
+// or nil if there are none.
+func mergeTriggers(ctx context.Context, triggers ...<-chan struct{}) <-chan struct{} {
+	triggers = slices.DeleteFunc(triggers, func(t <-chan struct{}) bool { return t == nil })
+	switch len(triggers) {
+	case 0:
+		return nil
+	case 1:
+		return triggers[0]
+	}
+	merged := make(chan struct{}, 1)
+	for _, t := range triggers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t:
+				}
+				select {
+				case merged <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+	return merged
+}
+
+// selectContainers returns the containers to trace: those that
+// -trace-containers and -skip-containers select, less ephemeral containers
+// if -skip-ephemeral is set. Containers are matched by the name they were
+// identified by, or by the short ID from their cgroup if they couldn't be.
+func selectContainers(ctx context.Context, cfg *config.Config, containers map[uint64]*cgroup.ContainerInfo, images map[uint64]kube.ContainerImage) map[uint64]*cgroup.ContainerInfo {
+	result := make(map[uint64]*cgroup.ContainerInfo, len(containers))
+	for cgroupID, info := range containers {
+		name := info.Name
+		if images[cgroupID].Name != "" {
+			name = images[cgroupID].Name
+		}
+		if !cfg.TraceContainer(name) {
+			clog.FromContext(ctx).Infof("Skipping container %s (cgroup_id=%d)", name, cgroupID)
+			continue
+		}
+		if images[cgroupID].Ephemeral && cfg.SkipEphemeral {
+			clog.FromContext(ctx).Infof("Skipping ephemeral container %s (cgroup_id=%d)", name, cgroupID)
+			continue
+		}
+		result[cgroupID] = info
+	}
+	return result
+}
+
+// toProcessorContainers converts discovered containers to the processor's
+// representation, which mirrors cgroup.ContainerInfo to avoid an import
+// cycle, attaching the resolved names, images, and root filesystems.
+// Containers whose name couldn't be resolved keep the short ID from their
+// cgroup.
+func toProcessorContainers(source containerSource, containers map[uint64]*cgroup.ContainerInfo, images map[uint64]kube.ContainerImage) map[uint64]*processor.ContainerInfo {
+	result := make(map[uint64]*processor.ContainerInfo, len(containers))
+	for cgroupID, info := range containers {
+		name := info.Name
+		if images[cgroupID].Name != "" {
+			name = images[cgroupID].Name
+		}
+		result[cgroupID] = &processor.ContainerInfo{
+			CgroupID:     info.CgroupID,
+			CgroupPath:   info.CgroupPath,
+			Name:         name,
+			ID:           info.ID,
+			PodUID:       info.PodUID,
+			PodName:      images[cgroupID].PodName,
+			PodNamespace: images[cgroupID].PodNamespace,
+			Ephemeral:    images[cgroupID].Ephemeral,
+			ImageRef:     images[cgroupID].Ref,
+			ImageDigest:  images[cgroupID].Digest,
+			Rootfs:       source.rootfs(info),
+		}
+	}
+	return result
+}
+
+// discover runs one discovery scan of source, bounded by the configured
+// timeout, and records its outcome in the health status.
+func discover(ctx context.Context, cfg *config.Config, source containerSource, hc *health.Checker) (map[uint64]*cgroup.ContainerInfo, error) {
+	if cfg.DiscoveryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DiscoveryTimeout)
+		defer cancel()
+	}
+	containers, err := source.discover(ctx)
+	hc.RecordDiscovery(err)
+	return containers, err
+}
+
+// discoverWithRetry runs the startup discovery scan, retrying failures with
+// a backoff starting at a second, up to the configured number of attempts.
+func discoverWithRetry(ctx context.Context, cfg *config.Config, source containerSource, hc *health.Checker) (map[uint64]*cgroup.ContainerInfo, error) {
+	attempts := max(cfg.DiscoveryAttempts, 1)
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		containers, err := discover(ctx, cfg, source, hc)
+		if err == nil || attempt == attempts {
+			return containers, err
+		}
+		clog.FromContext(ctx).Warnf("Discovering containers (attempt %d/%d), retrying in %s: %v", attempt, attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// watchContainers rescans for containers that started or went away after
+// startup, periodically and on runtime events if the source has them.
+// New containers are traced once their image is resolved; anything they
+// access before they are discovered is missed. Gone containers stop being
+// traced, and their cgroup IDs are sent on the returned channel so the
+// caller can report on them before removing them from the processor.
+// Which containers are traced follows the current configuration of live;
+// containers already traced stay traced.
+func watchContainers(ctx context.Context, live *config.Reloader, source containerSource, probe *ebpf.Probe, proc *processor.Processor, hc *health.Checker, known map[uint64]*cgroup.ContainerInfo) <-chan []uint64 {
+	log := clog.FromContext(ctx)
+	cfg := live.Config()
+	retired := make(chan []uint64)
+	scan := func() (map[uint64]*cgroup.ContainerInfo, error) { return discover(ctx, cfg, source, hc) }
+	go func() {
+		defer close(retired)
+		for changes := range cgroup.WatchScan(ctx, cfg.DiscoveryInterval, known, source.events(ctx), scan) {
+			// Register with the processor before the probe so the new
+			// container's first events aren't dropped as unknown
+			images := source.identify(ctx, changes.Added)
+			for cgroupID, info := range toProcessorContainers(source, selectContainers(ctx, live.Config(), changes.Added, images), images) {
+				log.Infof("Discovered container %s (cgroup_id=%d, path=%s)", info.Name, cgroupID, info.CgroupPath)
+				proc.AddContainer(info)
+				if err := probe.AddTracedCgroup(cgroupID); err != nil {
+					log.Errorf("Adding cgroup %s: %v", info.Name, err)
+				} else if cfg.SnapshotOpenFiles {
+					snapshotOpenFiles(ctx, proc, info)
+				}
+			}
+
+			// Skipped containers were never traced
+			var gone []uint64
+			for _, cgroupID := range changes.Removed {
+				if proc.Container(cgroupID) == nil {
+					continue
+				}
+				if err := probe.RemoveTracedCgroup(cgroupID); err != nil {
+					log.Debugf("Removing cgroup %d: %v", cgroupID, err)
+				}
+				gone = append(gone, cgroupID)
+			}
+			if len(gone) > 0 {
+				select {
+				case retired <- gone:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return retired
+}
+
+// containerName returns the name of a traced container for notifications.
+func containerName(proc *processor.Processor, cgroupID uint64) string {
+	if info := proc.Container(cgroupID); info != nil {
+		return info.Name
+	}
+	return ""
+}
+
+// snapshotOpenFiles records the files that the processes of a container
+// snoop just started tracing already have open or mapped, which they opened
+// before it was traced and so were never seen.
+func snapshotOpenFiles(ctx context.Context, proc *processor.Processor, info *processor.ContainerInfo) {
+	log := clog.FromContext(ctx)
+	pids, err := cgroup.Processes(info.CgroupPath)
+	if err != nil {
+		log.Debugf("Listing processes of container %s: %v", info.Name, err)
+		return
+	}
+	procPIDs := make([]uint32, len(pids))
+	for i, pid := range pids {
+		procPIDs[i] = uint32(pid)
+	}
+	if n := proc.Snapshot(info.CgroupID, procPIDs); n > 0 {
+		log.Infof("Recorded %d files container %s already had open", n, info.Name)
+	}
+}
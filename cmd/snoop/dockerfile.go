@@ -0,0 +1,104 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/imjasonh/snoop/pkg/registry"
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runDockerfile implements `snoop dockerfile`, which writes a Dockerfile
+// copying only the files a container accessed out of its source image.
+func runDockerfile(args []string) error {
+	fs := flag.NewFlagSet("dockerfile", flag.ExitOnError)
+	output := fs.String("o", "-", "Path to write the Dockerfile (- for stdout)")
+	container := fs.String("container", "", "Container to generate a Dockerfile for (required if the report has more than one)")
+	image := fs.String("image", "", "Source image reference (default: the container's image in the report)")
+	keep := fs.String("keep", "", "Comma-separated globs of paths to keep even if they weren't accessed, e.g. /etc/ssl")
+	platform := fs.String("platform", "", "Platform to select from a multi-platform image, as os/arch (default: this machine's)")
+	base := fs.String("base", registry.DefaultDockerfileBase, "Image to copy the files onto, e.g. scratch or a distroless image")
+	copier := fs.String("copier", registry.DefaultDockerfileCopier, "Image with a shell and tar used to gather the files while building")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop dockerfile [-container name] [-keep globs] [-o Dockerfile] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Generate a multi-stage Dockerfile copying only the files a container accessed,\n")
+		fmt.Fprintf(fs.Output(), "and the symlinks they're reached through, from its source image.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+	warnIncomplete(c)
+	src, ref, err := sourceImage(c, *image)
+	if err != nil {
+		return err
+	}
+	client, err := platformClient(*platform)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	files, err := client.SlimFiles(ctx, ref, registry.SlimOptions{
+		Files: keptFiles(c),
+		Keep:  keptGlobs(c, *keep),
+	})
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", ref, err)
+	}
+	for _, f := range files.Missing {
+		fmt.Fprintf(os.Stderr, "warning: %s is not in %s\n", f, ref)
+	}
+	opts := registry.DockerfileOptions{
+		Source:  src,
+		Base:    *base,
+		Copier:  *copier,
+		Comment: dockerfileComment(c, len(files.Paths)),
+	}
+
+	if *output == "" || *output == "-" {
+		return writeDockerfile(os.Stdout, files, opts)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeDockerfile(f, files, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dockerfileComment describes where a generated Dockerfile came from.
+func dockerfileComment(c reporter.ContainerReport, paths int) string {
+	return fmt.Sprintf("Generated by snoop from observed usage of container %s.\nCopies %d paths: the files it accessed, the directories holding them, and\nthe symlinks they're reached through. Build it and run the image's tests\nbefore shipping it.", c.Name, paths)
+}
+
+func writeDockerfile(w io.Writer, files *registry.SlimFiles, opts registry.DockerfileOptions) error {
+	bw := bufio.NewWriter(w)
+	if err := registry.WriteDockerfile(bw, files, opts); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
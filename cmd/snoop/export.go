@@ -0,0 +1,60 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runExport implements `snoop export`, which converts a JSON report to
+// another format offline.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "-", "Path to write the converted report (- for stdout)")
+	format := fs.String("format", "", "Output format: "+strings.Join(reporter.ExportFormats, ", "))
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop export -format=cyclonedx|spdx|csv|md [-o out] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Convert a report to a CycloneDX or SPDX SBOM, a CSV of accessed files, or Markdown.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || !slices.Contains(reporter.ExportFormats, *format) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *output == "" || *output == "-" {
+		return export(os.Stdout, report, *format)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := export(f, report, *format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func export(w io.Writer, report *reporter.Report, format string) error {
+	bw := bufio.NewWriter(w)
+	if err := reporter.Export(bw, report, format); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runHTML implements `snoop html`, which renders a report as a
+// self-contained HTML page for human review.
+func runHTML(args []string) error {
+	fs := flag.NewFlagSet("html", flag.ExitOnError)
+	output := fs.String("o", "-", "Path to write the HTML page (- for stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop html [-o report.html] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Render a report as a self-contained HTML page with sortable file tables.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *output == "" || *output == "-" {
+		return renderHTML(os.Stdout, report)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := renderHTML(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func renderHTML(w io.Writer, report *reporter.Report) error {
+	bw := bufio.NewWriter(w)
+	if err := reporter.RenderHTML(bw, report); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,181 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/cgroup"
+	"github.com/imjasonh/snoop/pkg/config"
+	"github.com/imjasonh/snoop/pkg/cri"
+	"github.com/imjasonh/snoop/pkg/kube"
+)
+
+// resolveImages maps discovered cgroup IDs to the name and image of each
+// container, asking the container runtime over CRI and then the pod status
+// from the kubelet, if configured, or the Kubernetes API. Containers that can't be resolved fall back to
+// the -image and -image-digest flags. The pod status is read at least once
+// even when CRI resolved every container, since only it tells which
+// containers are ephemeral. Lookup failures are logged rather than returned
+// since images are report metadata only.
+func resolveImages(ctx context.Context, cfg *config.Config, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage {
+	log := clog.FromContext(ctx)
+
+	result := make(map[uint64]kube.ContainerImage, len(containers))
+	for cgroupID := range containers {
+		result[cgroupID] = kube.ContainerImage{Ref: cfg.ImageRef, Digest: cfg.ImageDigest}
+	}
+
+	pending := make(map[uint64]string, len(containers))
+	for cgroupID, info := range containers {
+		if info.ID != "" {
+			pending[cgroupID] = info.ID
+		}
+	}
+	resolveFromRuntime(ctx, cfg, pending, result)
+
+	if cfg.PodName == "" || cfg.Namespace == "" {
+		return result
+	}
+	// The pod status is optional when only ephemeral containers are missing
+	warnf := log.Warnf
+	if len(pending) == 0 {
+		warnf = log.Debugf
+	}
+	var getPod func(ctx context.Context, namespace, name string) (*kube.Pod, error)
+	switch {
+	case cfg.KubeletURL != "":
+		getPod = kube.NewClient(cfg.KubeletURL, "", nil).GetKubeletPod
+	case kube.InCluster():
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			warnf("Unable to create Kubernetes client, per-container images unavailable: %v", err)
+			return result
+		}
+		getPod = client.GetPod
+	default:
+		return result
+	}
+
+	// Retry while the pod status doesn't yet report the IDs of containers
+	// that started alongside snoop
+	attempts := max(cfg.ImageLookupAttempts, 1)
+	backoff := time.Second
+	for attempt := 1; attempt <= attempts && (attempt == 1 || len(pending) > 0); attempt++ {
+		pod, err := getPod(ctx, cfg.Namespace, cfg.PodName)
+		if err != nil {
+			warnf("Looking up pod %s/%s (attempt %d/%d): %v", cfg.Namespace, cfg.PodName, attempt, attempts, err)
+		} else {
+			images := pod.ContainerImages()
+			for cgroupID, id := range pending {
+				if img, ok := images[id]; ok {
+					result[cgroupID] = img
+					delete(pending, cgroupID)
+				}
+			}
+			ephemeral := pod.EphemeralContainerNames()
+			for cgroupID, img := range result {
+				if ephemeral[img.Name] {
+					img.Ephemeral = true
+					result[cgroupID] = img
+				}
+			}
+		}
+		if len(pending) == 0 || attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	for cgroupID := range pending {
+		log.Warnf("No pod status found for container %s, using default image metadata", containers[cgroupID].Name)
+	}
+	return result
+}
+
+// resolveFromRuntime fills in result for the pending containers the
+// container runtime knows over CRI, removing them from pending.
+func resolveFromRuntime(ctx context.Context, cfg *config.Config, pending map[uint64]string, result map[uint64]kube.ContainerImage) {
+	socket := cfg.CRISocket
+	if socket == "" {
+		socket = cri.FindSocket()
+	}
+	if socket == "" || len(pending) == 0 {
+		return
+	}
+	log := clog.FromContext(ctx)
+	client := cri.NewClient(socket)
+	for cgroupID, id := range pending {
+		c, err := client.ContainerStatus(ctx, id)
+		if err != nil {
+			if cri.NotFound(err) {
+				continue
+			}
+			log.Warnf("Querying container runtime at %s, falling back to the pod status: %v", socket, err)
+			return
+		}
+		img := kube.ContainerImage{
+			Name:   c.Labels[cri.LabelContainerName],
+			Ref:    c.Image,
+			Digest: kube.ImageDigest(c.ImageRef),
+
+			PodName:      c.Labels[cri.LabelPodName],
+			PodNamespace: c.Labels[cri.LabelPodNamespace],
+		}
+		if img.Name == "" {
+			img.Name = c.Name
+		}
+		if img.Ref == "" {
+			img.Ref = result[cgroupID].Ref
+		}
+		if img.Digest == "" {
+			img.Digest = result[cgroupID].Digest
+		}
+		result[cgroupID] = img
+		delete(pending, cgroupID)
+	}
+}
+
+// resolveNodeImages is resolveImages for containers in any pod on the node:
+// containers the runtime doesn't know over CRI are looked up among all the
+// kubelet's pods, if -kubelet-url is set. It never retries, since node-wide
+// rescans pick up containers whose status wasn't reported yet.
+func resolveNodeImages(ctx context.Context, cfg *config.Config, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage {
+	result := make(map[uint64]kube.ContainerImage, len(containers))
+	pending := make(map[uint64]string, len(containers))
+	for cgroupID, info := range containers {
+		result[cgroupID] = kube.ContainerImage{Ref: cfg.ImageRef, Digest: cfg.ImageDigest}
+		if info.ID != "" {
+			pending[cgroupID] = info.ID
+		}
+	}
+	resolveFromRuntime(ctx, cfg, pending, result)
+	if len(pending) == 0 || cfg.KubeletURL == "" {
+		return result
+	}
+
+	pods, err := kube.NewClient(cfg.KubeletURL, "", nil).ListKubeletPods(ctx)
+	if err != nil {
+		clog.FromContext(ctx).Warnf("Listing kubelet pods, using default image metadata: %v", err)
+		return result
+	}
+	images := make(map[string]kube.ContainerImage)
+	for i := range pods {
+		for id, img := range pods[i].ContainerImages() {
+			images[id] = img
+		}
+	}
+	for cgroupID, id := range pending {
+		if img, ok := images[id]; ok {
+			result[cgroupID] = img
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/java"
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runJars implements `snoop jars`, which shows which jars a container's JVMs
+// had on their classpath, opened, and loaded classes from.
+func runJars(args []string) error {
+	fs := flag.NewFlagSet("jars", flag.ExitOnError)
+	container := fs.String("container", "", "Container to show (required if the report has more than one)")
+	classLog := fs.String("class-log", "", "JVM class-loading log (from -Xlog:class+load or -verbose:class) to count the classes loaded from each jar")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop jars [-container name] [-class-log file] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Show the jars on a container's classpath and whether each was opened. Record\n")
+		fmt.Fprintf(fs.Output(), "the report with -java-classpath. The JVM opens classpath jars while searching\n")
+		fmt.Fprintf(fs.Output(), "for classes, so pass the application's class-loading log to tell which ones\n")
+		fmt.Fprintf(fs.Output(), "classes were actually loaded from.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+
+	var classes map[string]int
+	if *classLog != "" {
+		f, err := os.Open(*classLog)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if classes, err = java.ParseClassLog(f); err != nil {
+			return fmt.Errorf("%s: %w", *classLog, err)
+		}
+	}
+	jars := reporter.JarUsage(c, classes)
+	if len(jars) == 0 {
+		return fmt.Errorf("container %s opened no jars", c.Name)
+	}
+	return reporter.WriteJars(os.Stdout, jars, classes != nil)
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runLayers implements `snoop layers`, which shows how much of each image
+// layer a container accessed and which layers it didn't use at all.
+func runLayers(args []string) error {
+	fs := flag.NewFlagSet("layers", flag.ExitOnError)
+	container := fs.String("container", "", "Container to show (required if the report has more than one)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop layers [-container name] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Show the accessed and total files and bytes of each image layer, base layer\n")
+		fmt.Fprintf(fs.Output(), "first, and the layers that provided no accessed files. Record the report\n")
+		fmt.Fprintf(fs.Output(), "with -layers.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+	if len(c.Layers) == 0 {
+		return fmt.Errorf("container %s has no layers; record the report with -layers", c.Name)
+	}
+	return reporter.WriteLayers(os.Stdout, c.Layers)
+}
@@ -4,78 +4,417 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/chainguard-dev/clog"
 	"github.com/chainguard-dev/clog/slag"
-	"github.com/imjasonh/snoop/pkg/cgroup"
+	"github.com/imjasonh/snoop/pkg/apk"
 	"github.com/imjasonh/snoop/pkg/config"
+	"github.com/imjasonh/snoop/pkg/containerd"
+	"github.com/imjasonh/snoop/pkg/docker"
+	"github.com/imjasonh/snoop/pkg/dpkg"
 	"github.com/imjasonh/snoop/pkg/ebpf"
 	"github.com/imjasonh/snoop/pkg/health"
+	"github.com/imjasonh/snoop/pkg/kube"
 	"github.com/imjasonh/snoop/pkg/metrics"
+	"github.com/imjasonh/snoop/pkg/notify"
+	"github.com/imjasonh/snoop/pkg/npm"
+	"github.com/imjasonh/snoop/pkg/packages"
+	"github.com/imjasonh/snoop/pkg/policy"
 	"github.com/imjasonh/snoop/pkg/processor"
+	"github.com/imjasonh/snoop/pkg/python"
+	"github.com/imjasonh/snoop/pkg/registry"
 	"github.com/imjasonh/snoop/pkg/reporter"
+	"github.com/imjasonh/snoop/pkg/sampling"
+	"github.com/imjasonh/snoop/pkg/sbom"
+	"github.com/imjasonh/snoop/pkg/serving"
+	"github.com/imjasonh/snoop/pkg/version"
 )
 
 func main() {
+	// `snoop run [flags] -- command` traces a command instead of containers,
+	// taking the same flags as the daemon
+	runMode := len(os.Args) > 1 && os.Args[1] == "run"
+	if runMode {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	if len(os.Args) > 1 && !runMode {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "snoop %s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	var (
 		reportPath     string
+		reportDir      string
+		reportCompact  bool
 		reportInterval time.Duration
+		reportURL      string
+		pushgatewayURL string
+		otlpEndpoint   string
+		remoteWriteURL string
+		syslogAddr     string
+		auditLog       string
+		reportSocket   string
+		reportCRD      bool
+		configFile     string
+		configDir      string
+		configReload   time.Duration
 		excludePaths   string
+		discoveryEvery time.Duration
+		discoveryTries int
+		discoveryLimit time.Duration
+		imageTries     int
+		criSocket      string
+		containerdSock string
+		containerdNS   string
+		dockerSocket   string
+		composeProject string
+		runPID         int
+		systemdUnit    string
+		duration       time.Duration
+		stableAfter    time.Duration
+		exitStable     bool
+		kubeletURL     string
+		node           bool
+		traceCtrs      string
+		skipCtrs       string
+		includeInfra   bool
+		skipEphemeral  bool
 		imageRef       string
 		imageDigest    string
 		containerID    string
 		podName        string
+		podUID         string
 		namespace      string
 		labels         string
+		nodeName       string
+		podInfoDir     string
 		metricsAddr    string
+		pprofEnabled   bool
+		debugState     bool
+		tlsCert        string
+		tlsKey         string
+		clientCA       string
+		clientIDs      string
+		tokenFile      string
+		debugSample    int
+		serveAddr      string
 		logLevel       slag.Level
 		maxUniqueFiles int
+		maxPathBytes   int64
+		maxSampleRate  int
+		reportMaxFiles int
+		fileMetadata   bool
+		snapshotOpen   bool
+		pkgAttribution bool
+		packagesSBOM   string
+		packagesReload time.Duration
+		pkgLoadTries   int
+		pkgMetricsTop  int
+		pkgLoadRetry   time.Duration
+		unusedFiles    bool
+		packagesVerify bool
+		apkDatabases   string
+		packagesImage  bool
+		pythonPackages bool
+		npmPackages    bool
+		goBuildInfo    bool
+		libraryDeps    bool
+		javaClasspath  bool
+		layers         bool
+		layersHostRoot string
+		coverage       bool
+		coverageTop    int
+		volumeFiles    bool
+		hashFiles      bool
+		hashMaxSize    int64
+		hashWorkers    int
+		webhookURL     string
+		webhookTmpl    string
+		events         bool
+		watchPaths     string
+		baselinePath   string
+		dropRateAlert  float64
 	)
 
-	flag.StringVar(&reportPath, "report", "/data/snoop-report.json", "Path to write the JSON report")
+	flag.StringVar(&reportPath, "report", "/data/snoop-report.json", "Path to write the JSON report (empty to disable)")
+	flag.StringVar(&reportDir, "report-dir", "", "Directory to write one JSON report per pod to, named <namespace>_<pod>_<uid>.json (for -node)")
+	flag.BoolVar(&reportCompact, "report-compact", false, "Write the JSON report without indentation")
 	flag.DurationVar(&reportInterval, "interval", 30*time.Second, "Interval between report writes")
+	flag.StringVar(&reportURL, "report-url", "", "HTTP endpoint to POST JSON reports to (empty to disable)")
+	flag.StringVar(&pushgatewayURL, "pushgateway-url", "", "Prometheus Pushgateway URL to push metrics to on each report (empty to disable)")
+	flag.StringVar(&reportSocket, "report-socket", "", "Unix socket to serve the latest report on via HTTP GET /report (empty to disable)")
+	flag.BoolVar(&reportCRD, "report-crd", false, "Create or update a FileAccessReport resource (snoop.dev/v1alpha1) named after each traced pod, in its namespace")
+	flag.StringVar(&syslogAddr, "syslog", "", "Syslog destination for file events and summaries: local, unix:///path, udp://host:port, or tcp://host:port (empty to disable)")
+	flag.StringVar(&auditLog, "audit-log", "", "Append-only JSONL file of file events and summaries, each record chained to the previous one by its hash; check it with snoop verify-audit (empty to disable)")
+	flag.StringVar(&remoteWriteURL, "remote-write-url", "", "Prometheus remote-write endpoint to push per-container stats to on each report (empty to disable)")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector endpoint to export report metrics and logs to (empty to disable)")
+	flag.StringVar(&configFile, "config", "", "YAML or JSON file of flag settings and per-container settings; flags on the command line take precedence (empty to disable)")
+	flag.StringVar(&configDir, "config-dir", "", "Directory of settings files, such as a mounted ConfigMap, overriding the flags of the same names ("+strings.Join(config.ReloadableSettings, ", ")+") and reloaded when it changes (empty to disable)")
+	flag.DurationVar(&configReload, "config-reload", config.DefaultConfigReload, "How often to check -config-dir for changes (0 = only at startup)")
 	flag.StringVar(&excludePaths, "exclude", "/proc/,/sys/,/dev/", "Comma-separated path prefixes to exclude")
-	flag.StringVar(&imageRef, "image", "", "Image reference for report metadata")
-	flag.StringVar(&imageDigest, "image-digest", "", "Image digest for report metadata")
+	flag.DurationVar(&discoveryEvery, "discovery-interval", config.DefaultDiscoveryInterval, "How often to rescan the pod for containers that started, restarted, or exited (0 = only at startup)")
+	flag.IntVar(&discoveryTries, "discovery-attempts", config.DefaultDiscoveryAttempts, "Times to attempt container discovery at startup, with exponential backoff, before giving up")
+	flag.DurationVar(&discoveryLimit, "discovery-timeout", config.DefaultDiscoveryTimeout, "Time limit for one container discovery scan (0 = unbounded)")
+	flag.IntVar(&imageTries, "image-lookup-attempts", config.DefaultImageLookupAttempts, "Times to read the pod status, with exponential backoff, while it's missing containers' images")
+	flag.StringVar(&criSocket, "cri-socket", "", "Container runtime CRI socket to look up container names and images from (empty to try the containerd, CRI-O, and cri-dockerd defaults)")
+	flag.StringVar(&containerdSock, "containerd-socket", "", "containerd socket to watch for container starts and exits and to read container root filesystems from (empty to disable)")
+	flag.StringVar(&containerdNS, "containerd-namespace", containerd.DefaultNamespace, "containerd namespace of the traced containers")
+	flag.StringVar(&dockerSocket, "docker", "", "Trace the containers of a Docker host through its API socket (e.g. /var/run/docker.sock) instead of the containers in snoop's pod; requires the host PID namespace")
+	flag.BoolVar(&node, "node", false, "Trace the containers of every pod on the node, as a DaemonSet, instead of the containers in snoop's pod; requires the host cgroup namespace")
+	flag.StringVar(&traceCtrs, "trace-containers", "", "Comma-separated container names or glob patterns (e.g. app,worker-*) to trace; others are ignored (empty to trace all)")
+	flag.StringVar(&skipCtrs, "skip-containers", "", "Comma-separated container names or glob patterns (e.g. fluent-bit,*-proxy) not to trace")
+	flag.BoolVar(&includeInfra, "include-infra", false, "Also trace service-mesh and infrastructure containers (istio-proxy, linkerd-proxy, envoy, pause, ...), which are skipped by default")
+	flag.BoolVar(&skipEphemeral, "skip-ephemeral", false, "Don't trace ephemeral containers (e.g. from kubectl debug); by default they're traced and marked ephemeral in reports")
+	flag.IntVar(&runPID, "pid", 0, "Trace this existing process and its descendants, moved into a transient cgroup, instead of containers")
+	flag.DurationVar(&duration, "duration", 0, "Trace for this long, then write a final report and exit, failing if the drop rate exceeded -drop-rate-threshold (0 = until stopped)")
+	flag.DurationVar(&stableAfter, "stable-after", 0, "Mark reports converged once no new files have been recorded for this long (0 = never)")
+	flag.BoolVar(&exitStable, "exit-when-stable", false, "Write a final report and exit once reports converge (requires -stable-after)")
+	flag.StringVar(&systemdUnit, "systemd-unit", "", "Trace this systemd unit (e.g. myapp.service) instead of containers; requires the host cgroup namespace")
+	flag.StringVar(&composeProject, "compose", "", "Trace only the containers of this Docker Compose project, named by service; implies -docker "+docker.DefaultSocket+" unless -docker is set")
+	flag.StringVar(&kubeletURL, "kubelet-url", "", "Kubelet read-only API (e.g. http://$(HOST_IP):10255) to read the pod status from instead of the API server (empty to use the API server)")
+	flag.StringVar(&imageRef, "image", "", "Default image reference for containers whose image can't be resolved from the pod status")
+	flag.StringVar(&imageDigest, "image-digest", "", "Default image digest for containers whose image can't be resolved from the pod status")
 	flag.StringVar(&containerID, "container-id", "", "Container ID for report metadata")
 	flag.StringVar(&podName, "pod-name", "", "Pod name for report metadata")
 	flag.StringVar(&namespace, "namespace", "", "Namespace for report metadata")
-	flag.StringVar(&labels, "labels", "", "Comma-separated key=value labels for report metadata")
+	flag.StringVar(&podUID, "pod-uid", "", "Pod UID for report metadata (defaults to $POD_UID)")
+	flag.StringVar(&nodeName, "node-name", "", "Node name for report metadata (defaults to $NODE_NAME)")
+	flag.StringVar(&labels, "labels", "", "Comma-separated key=value labels for report metadata (defaults to the labels file in -podinfo-dir)")
+	flag.StringVar(&podInfoDir, "podinfo-dir", kube.DefaultPodInfoDir, "Downward API volume to read the pod's name, namespace, UID, and labels from when their flags and environment variables aren't set")
 	flag.StringVar(&metricsAddr, "metrics-addr", ":9090", "Address for Prometheus metrics endpoint (empty to disable)")
+	flag.BoolVar(&pprofEnabled, "pprof", false, "Serve Go runtime profiles under /debug/pprof/ on the metrics server")
+	flag.BoolVar(&debugState, "debug-state", false, "Serve the live per-container file lists, cache and package database state, and configuration as JSON at /debug/state on the metrics server")
+	flag.StringVar(&serveAddr, "serve-addr", "", "Address for an HTTP API serving live report state: GET /report, /containers/{name}/files, /packages, secured like the metrics server (empty to disable)")
 	flag.Var(&logLevel, "log-level", "Log level (debug, info, warn, error)")
+	flag.StringVar(&tlsCert, "metrics-tls-cert", "", "TLS certificate to serve the metrics server with (re-read when rotated)")
+	flag.StringVar(&tlsKey, "metrics-tls-key", "", "TLS private key for -metrics-tls-cert")
+	flag.StringVar(&clientCA, "metrics-client-ca", "", "CA bundle whose client certificates may call the metrics server (mTLS); /healthz stays open")
+	flag.StringVar(&clientIDs, "metrics-client-ids", "", "Comma-separated SPIFFE IDs, DNS names, or common names of client certificates allowed by -metrics-client-ca (default: any)")
+	flag.StringVar(&tokenFile, "metrics-token-file", "", "File holding a bearer token that may call the metrics server; /healthz stays open")
+	flag.IntVar(&debugSample, "debug-sample-rate", 0, "Log 1 in N events with full detail at info level (0 = disabled)")
+	flag.IntVar(&reportMaxFiles, "report-max-files", 0, "Maximum files listed per container in reports, keeping the most accessed (0 = unbounded)")
 	flag.IntVar(&maxUniqueFiles, "max-unique-files", config.DefaultMaxUniqueFiles, fmt.Sprintf("Maximum unique files to track per container (0 = unbounded, default = %d)", config.DefaultMaxUniqueFiles))
+	flag.Int64Var(&maxPathBytes, "max-path-bytes", 0, "Approximate memory limit, in bytes, of the paths tracked per container, evicting the least recently used (0 = unbounded)")
+	flag.IntVar(&maxSampleRate, "max-sample-rate", config.DefaultMaxSampleRate, "When events arrive faster than they can be processed, process as few as 1 in N file accesses rather than overflow the ring buffer, recording it in reports (1 = never sample; ignored with rules or a baseline)")
+	flag.BoolVar(&pkgAttribution, "packages", false, "Attribute accessed files to installed OS packages (reads the package database via /proc/<pid>/root)")
+	flag.DurationVar(&packagesReload, "packages-reload", config.DefaultPackagesReload, "How often to check a container's package database for changes and reload it (0 = never)")
+	flag.IntVar(&pkgLoadTries, "packages-load-attempts", config.DefaultPackageLoadAttempts, "Times to look for a container's package database, e.g. while its filesystem isn't reachable yet")
+	flag.DurationVar(&pkgLoadRetry, "packages-load-retry", config.DefaultPackageLoadRetry, "Minimum time between attempts to load a container's package database")
+	flag.BoolVar(&unusedFiles, "packages-unused-files", false, "List each package's files that were never accessed (can make reports much larger)")
+	flag.IntVar(&pkgMetricsTop, "packages-metrics-top", config.DefaultPackageMetricsTop, "Most accessed packages per container to export snoop_package_accesses metrics for (0 = none)")
+	flag.BoolVar(&packagesVerify, "packages-verify", false, "Check accessed package files against the checksums recorded by the package manager (apk) and report modified files")
+	flag.StringVar(&apkDatabases, "apk-db", strings.Join(apk.DefaultDatabasePaths, ","), "Comma-separated apk installed database paths to check in each container; databases under a prefix (e.g. /opt/rootfs/lib/apk/db/installed) describe that root")
+	flag.BoolVar(&packagesImage, "packages-image", false, "When a container's apk database can't be read from its filesystem, fetch it from the container's image in the registry, with docker's credentials")
+	flag.StringVar(&packagesSBOM, "packages-sbom", "", "SPDX or CycloneDX JSON SBOM (file path or http(s) URL) to attribute files from instead of the image's package database; implies -packages")
+	flag.BoolVar(&npmPackages, "npm-packages", false, "Attribute accessed files to npm packages in node_modules directories (reads package.json via /proc/<pid>/root)")
+	flag.BoolVar(&goBuildInfo, "go-buildinfo", false, "Read the embedded build info (module, version, VCS revision) of executed Go binaries via /proc/<pid>/root")
+	flag.BoolVar(&libraryDeps, "library-deps", false, "Resolve the shared libraries executed binaries need via /proc/<pid>/root, and report those never opened")
+	flag.BoolVar(&javaClasspath, "java-classpath", false, "Read the classpath of JVMs (via /proc/<pid>/cmdline) the first time they open a jar, and report which jars on it were never opened")
+	flag.BoolVar(&pythonPackages, "python-packages", false, "Attribute accessed files to pip packages in site-packages directories (reads dist-info RECORD files via /proc/<pid>/root)")
+	flag.BoolVar(&layers, "layers", false, "Attribute accessed files and packages to the overlayfs image layer providing them (reads /proc/<pid>/mountinfo and the layer directories)")
+	flag.StringVar(&layersHostRoot, "layers-host-root", "", "Directory where the host filesystem is mounted, for reading layer directories (empty if snoop sees the host filesystem directly)")
+	flag.BoolVar(&coverage, "coverage", false, "Compare accessed files with every regular file in the image layers, per directory, and list the largest never-accessed ones (requires -layers)")
+	flag.IntVar(&coverageTop, "coverage-largest-files", config.DefaultCoverageLargestFiles, "Number of the largest never-accessed image files to list with -coverage")
+	flag.BoolVar(&volumeFiles, "volume-files", false, "Classify accessed files as on the image or a volume (configMap, secret, PVC, emptyDir, hostPath, tmpfs), report those on volumes in a separate list, and count files per class (reads /proc/<pid>/mountinfo)")
+	flag.BoolVar(&snapshotOpen, "snapshot-open-files", false, "When a container is first traced, record the files its processes already have open or mapped (read via /proc/<pid>/fd and /proc/<pid>/maps), flagged as pre-existing in reports")
+	flag.BoolVar(&fileMetadata, "file-metadata", false, "Record size, mode, owner, mtime, and SELinux label of accessed files (read via /proc/<pid>/root)")
+	flag.BoolVar(&hashFiles, "hash-files", false, "Compute sha256 digests of accessed files (read via /proc/<pid>/root)")
+	flag.Int64Var(&hashMaxSize, "hash-max-size", config.DefaultHashMaxSize, "Largest file to hash, in bytes (0 = unbounded)")
+	flag.IntVar(&hashWorkers, "hash-workers", config.DefaultHashWorkers, "Number of concurrent hashing workers")
+	flag.StringVar(&webhookURL, "webhook-url", "", "URL to POST notifications to when notable events occur (empty to disable)")
+	flag.StringVar(&webhookTmpl, "webhook-template", "", "Path to a Go text/template for webhook request bodies (default: JSON)")
+	flag.BoolVar(&events, "events", false, "Record notifications (watched paths, drop rate, evictions, eBPF load failures) as Kubernetes Events on snoop's pod")
+	flag.StringVar(&watchPaths, "watch-paths", "", "Comma-separated paths whose first access triggers a notification (trailing / matches a directory, globs allowed)")
+	flag.Float64Var(&dropRateAlert, "drop-rate-threshold", 0, "Ring buffer drop rate percentage per interval that triggers a notification (0 = disabled)")
+	flag.StringVar(&baselinePath, "baseline", "", "Previously captured report of the files each container is expected to access; accessing any other file is logged, counted, and notified (empty to disable)")
+
+	// gen-manifests checks the flags it writes into manifests against the
+	// ones defined above
+	if len(os.Args) > 1 && os.Args[1] == "gen-manifests" && !runMode {
+		hasFlag := func(name string) bool { return flag.Lookup(name) != nil }
+		if err := runGenManifests(os.Args[2:], hasFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "snoop gen-manifests: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	flag.Parse()
 
-	// Build configuration from flags (also check environment variables)
+	// Settings from -config fill in the flags not set on the command line
+	var containerSettings []config.ContainerSettings
+	var ruleSettings []config.RuleSettings
+	if configFile != "" {
+		f, err := config.LoadFile(configFile)
+		if err == nil {
+			if _, ok := f.Flags["config"]; ok {
+				err = fmt.Errorf("%s: config files cannot set -config", configFile)
+			} else {
+				err = f.Apply(flag.CommandLine)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snoop: %v\n", err)
+			os.Exit(2)
+		}
+		containerSettings = f.Containers
+		ruleSettings = f.Rules
+	}
+
+	// Build configuration from flags, filling in pod metadata they don't
+	// set from the downward API
+	podInfo := kube.ReadPodInfo(podInfoDir)
 	if podName == "" {
-		podName = os.Getenv("POD_NAME")
+		podName = podInfo.Name
 	}
 	if namespace == "" {
-		namespace = os.Getenv("POD_NAMESPACE")
+		namespace = podInfo.Namespace
+	}
+	if podUID == "" {
+		podUID = podInfo.UID
+	}
+	if nodeName == "" {
+		nodeName = podInfo.NodeName
+	}
+	podLabels := parseLabels(labels)
+	if podLabels == nil {
+		podLabels = podInfo.Labels
+	}
+	if otlpEndpoint == "" {
+		otlpEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if composeProject != "" && dockerSocket == "" {
+		dockerSocket = docker.DefaultSocket
+	}
+	var runCommand []string
+	if runMode {
+		runCommand = flag.Args()
+		if len(runCommand) == 0 && runPID == 0 {
+			fmt.Fprintln(os.Stderr, "Usage: snoop run [flags] -- command [args...]\n       snoop run [flags] -pid PID")
+			os.Exit(2)
+		}
+	}
+	if runMode || runPID != 0 || systemdUnit != "" {
+		// The daemon's default report path is a volume mount in its image
+		reportSet := false
+		flag.Visit(func(f *flag.Flag) { reportSet = reportSet || f.Name == "report" })
+		if !reportSet {
+			reportPath = "snoop-report.json"
+		}
 	}
 
 	cfg := &config.Config{
-		ReportPath:     reportPath,
-		ReportInterval: reportInterval,
-		ExcludePaths:   config.ParseExcludePaths(excludePaths),
-		ImageRef:       imageRef,
-		ImageDigest:    imageDigest,
-		ContainerID:    containerID,
-		PodName:        podName,
-		Namespace:      namespace,
-		Labels:         parseLabels(labels),
-		MetricsAddr:    metricsAddr,
-		LogLevel:       slog.Level(logLevel),
-		MaxUniqueFiles: maxUniqueFiles,
+		ReportPath:          reportPath,
+		ReportDir:           reportDir,
+		ReportCompact:       reportCompact,
+		ReportInterval:      reportInterval,
+		ReportURL:           reportURL,
+		PushgatewayURL:      pushgatewayURL,
+		OTLPEndpoint:        otlpEndpoint,
+		RemoteWriteURL:      remoteWriteURL,
+		SyslogAddr:          syslogAddr,
+		AuditLog:            auditLog,
+		ReportSocket:        reportSocket,
+		ReportCRD:           reportCRD,
+		ConfigDir:           configDir,
+		ConfigReload:        configReload,
+		ExcludePaths:        config.ParseExcludePaths(excludePaths),
+		DiscoveryInterval:   discoveryEvery,
+		DiscoveryAttempts:   discoveryTries,
+		DiscoveryTimeout:    discoveryLimit,
+		ImageLookupAttempts: imageTries,
+		CRISocket:           criSocket,
+		ContainerdSocket:    containerdSock,
+		ContainerdNS:        containerdNS,
+		DockerSocket:        dockerSocket,
+		ComposeProject:      composeProject,
+		RunCommand:          runCommand,
+		RunPID:              runPID,
+		SystemdUnit:         systemdUnit,
+		Duration:            duration,
+		StableAfter:         stableAfter,
+		ExitWhenStable:      exitStable,
+		KubeletURL:          kubeletURL,
+		Node:                node,
+		TraceContainers:     config.ParseExcludePaths(traceCtrs),
+		SkipContainers:      config.ParseExcludePaths(skipCtrs),
+		IncludeInfra:        includeInfra,
+		SkipEphemeral:       skipEphemeral,
+		Containers:          containerSettings,
+		Rules:               ruleSettings,
+		ImageRef:            imageRef,
+		ImageDigest:         imageDigest,
+		ContainerID:         containerID,
+		PodName:             podName,
+		Namespace:           namespace,
+		PodUID:              podUID,
+		NodeName:            nodeName,
+		Labels:              podLabels,
+		MetricsAddr:         metricsAddr,
+		Pprof:               pprofEnabled,
+		DebugState:          debugState,
+		ServeAddr:           serveAddr,
+		LogLevel:            slog.Level(logLevel),
+		DebugSampleRate:     debugSample,
+		MetricsTLSCert:      tlsCert,
+		MetricsTLSKey:       tlsKey,
+		MetricsClientCA:     clientCA,
+		MetricsClientIDs:    config.ParseExcludePaths(clientIDs),
+		MetricsTokenFile:    tokenFile,
+		MaxUniqueFiles:      maxUniqueFiles,
+		MaxPathBytes:        maxPathBytes,
+		MaxSampleRate:       maxSampleRate,
+		ReportMaxFiles:      reportMaxFiles,
+		SnapshotOpenFiles:   snapshotOpen,
+		FileMetadata:        fileMetadata,
+		Packages:            pkgAttribution || packagesSBOM != "",
+		PackagesSBOM:        packagesSBOM,
+		PackagesReload:      packagesReload,
+		PackageLoadAttempts: pkgLoadTries,
+		PackageMetricsTop:   pkgMetricsTop,
+		PackageLoadRetry:    pkgLoadRetry,
+		UnusedFiles:         unusedFiles,
+		PackagesVerify:      packagesVerify,
+		APKDatabases:        config.ParseExcludePaths(apkDatabases),
+		PackagesImage:       packagesImage,
+		PythonPackages:      pythonPackages,
+		NpmPackages:         npmPackages,
+		GoBuildInfo:         goBuildInfo,
+		LibraryDeps:         libraryDeps,
+		JavaClasspath:       javaClasspath,
+		Layers:              layers,
+		LayersHostRoot:      layersHostRoot,
+		Coverage:            coverage,
+		CoverageLargest:     coverageTop,
+		VolumeFiles:         volumeFiles,
+		HashFiles:           hashFiles,
+		HashMaxSize:         hashMaxSize,
+		HashWorkers:         hashWorkers,
+
+		WebhookURL:        webhookURL,
+		WebhookTemplate:   webhookTmpl,
+		Events:            events,
+		WatchPaths:        config.ParseExcludePaths(watchPaths),
+		DropRateThreshold: dropRateAlert,
+		Baseline:          baselinePath,
 	}
 
 	// Initialize logging context
@@ -111,11 +450,367 @@ func parseLabels(s string) map[string]string {
 	return result
 }
 
+// metricsContainerLabel returns the container label of per-container
+// metrics: the container's name, qualified by its pod as
+// "<namespace>/<pod>/<name>" when tracing every pod on a node, where names
+// repeat across pods.
+func metricsContainerLabel(name, podName, podNamespace string) string {
+	if podName == "" {
+		return name
+	}
+	return podNamespace + "/" + podName + "/" + name
+}
+
+// convertMetadata converts processor file metadata to its report representation.
+func convertMetadata(md map[string]processor.FileMetadata) map[string]reporter.FileMetadata {
+	if len(md) == 0 {
+		return nil
+	}
+	result := make(map[string]reporter.FileMetadata, len(md))
+	for path, m := range md {
+		result[path] = reporter.FileMetadata{
+			Size:    m.Size,
+			Mode:    m.Mode.String(),
+			UID:     m.UID,
+			GID:     m.GID,
+			ModTime: m.ModTime,
+			Label:   m.Label,
+		}
+	}
+	return result
+}
+
+// convertPackages converts processor package stats to their report representation.
+func convertPackages(stats []packages.PackageStats) []reporter.PackageReport {
+	if len(stats) == 0 {
+		return nil
+	}
+	result := make([]reporter.PackageReport, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, reporter.PackageReport{
+			Name:          s.Name,
+			Version:       s.Version,
+			Manager:       s.Manager,
+			TotalFiles:    s.TotalFiles,
+			AccessedFiles: s.AccessedFiles,
+			AccessCount:   s.AccessCount,
+			Origin:        s.Origin,
+			License:       s.License,
+			Arch:          s.Arch,
+			Size:          s.Size,
+			Layer:         s.Layer,
+			InstallReason: s.InstallReason,
+			Depends:       s.Depends,
+			Provides:      s.Provides,
+			UnusedFiles:   s.UnusedFiles,
+			SampleFiles:   s.SampleFiles,
+		})
+	}
+	return result
+}
+
+// convertLayers converts processor layer usage to its report representation,
+// along with the total size of unused files in image layers.
+func convertLayers(usage processor.LayerUsage) ([]reporter.LayerReport, map[string]int, int64) {
+	if len(usage.Layers) == 0 {
+		return nil, nil, 0
+	}
+	accessed := make(map[int]int)
+	for _, index := range usage.Files {
+		accessed[index]++
+	}
+	result := make([]reporter.LayerReport, 0, len(usage.Layers))
+	var unused int64
+	for i, l := range usage.Layers {
+		lr := reporter.LayerReport{
+			Index:         l.Index,
+			Digest:        l.Digest,
+			Dir:           l.Dir,
+			Upper:         l.Upper,
+			AccessedFiles: accessed[l.Index],
+		}
+		if i < len(usage.Sizes) {
+			lr.TotalFiles = usage.Sizes[i].Files
+			lr.TotalBytes = usage.Sizes[i].Bytes
+			// Files replaced since they were accessed can make this negative
+			lr.UnusedBytes = max(lr.TotalBytes-usage.AccessedBytes[l.Index], 0)
+			if !l.Upper {
+				unused += lr.UnusedBytes
+			}
+		}
+		result = append(result, lr)
+	}
+	return result, usage.Files, unused
+}
+
+// convertModified converts processor modified package files to their report representation.
+func convertModified(files []processor.ModifiedFile) []reporter.ModifiedFile {
+	if len(files) == 0 {
+		return nil
+	}
+	result := make([]reporter.ModifiedFile, 0, len(files))
+	for _, f := range files {
+		result = append(result, reporter.ModifiedFile{
+			Path:     f.Path,
+			Package:  f.Package,
+			Expected: f.Expected,
+			Actual:   f.Actual,
+		})
+	}
+	return result
+}
+
+// convertCoverage converts processor image file coverage to its report
+// representation, or returns nil if the container's layers weren't measured.
+func convertCoverage(c processor.Coverage) *reporter.Coverage {
+	if c.Directories == nil {
+		return nil
+	}
+	result := &reporter.Coverage{
+		Files:         c.Total.Files,
+		AccessedFiles: c.Total.AccessedFiles,
+		Bytes:         c.Total.Bytes,
+		AccessedBytes: c.Total.AccessedBytes,
+	}
+	for _, dir := range slices.Sorted(maps.Keys(c.Directories)) {
+		d := c.Directories[dir]
+		result.Directories = append(result.Directories, reporter.DirectoryCoverage{
+			Path:          dir,
+			Files:         d.Files,
+			AccessedFiles: d.AccessedFiles,
+			Bytes:         d.Bytes,
+			AccessedBytes: d.AccessedBytes,
+		})
+	}
+	for _, f := range c.LargestUnused {
+		result.LargestUnusedFiles = append(result.LargestUnusedFiles, reporter.ImageFile{Path: f.Path, Size: f.Size})
+	}
+	return result
+}
+
+// convertVolumes converts processor volume files to their report representation.
+func convertVolumes(files []processor.VolumeFile) []reporter.VolumeFile {
+	if len(files) == 0 {
+		return nil
+	}
+	result := make([]reporter.VolumeFile, 0, len(files))
+	for _, f := range files {
+		result = append(result, reporter.VolumeFile{
+			Path:       f.Path,
+			Volume:     f.Volume,
+			MountPoint: f.MountPoint,
+		})
+	}
+	return result
+}
+
+// convertLibraries converts processor library dependencies to their report representation.
+func convertLibraries(deps []processor.LibraryDependency) []reporter.LibraryDependency {
+	if len(deps) == 0 {
+		return nil
+	}
+	result := make([]reporter.LibraryDependency, 0, len(deps))
+	for _, d := range deps {
+		result = append(result, reporter.LibraryDependency{
+			Name:       d.Name,
+			Path:       d.Path,
+			RequiredBy: d.RequiredBy,
+		})
+	}
+	return result
+}
+
+// convertJars converts processor jar usage to its report representation.
+func convertJars(jars []processor.JarUse) []reporter.JarReport {
+	if len(jars) == 0 {
+		return nil
+	}
+	result := make([]reporter.JarReport, 0, len(jars))
+	for _, j := range jars {
+		result = append(result, reporter.JarReport{
+			Path:        j.Path,
+			OnClasspath: j.OnClasspath,
+			Opened:      j.Opened,
+		})
+	}
+	return result
+}
+
+// convertBuildInfo converts processor Go build info to its report representation.
+func convertBuildInfo(infos []processor.GoBuildInfo) []reporter.GoBinary {
+	if len(infos) == 0 {
+		return nil
+	}
+	result := make([]reporter.GoBinary, 0, len(infos))
+	for _, info := range infos {
+		b := reporter.GoBinary{
+			Path:        info.Path,
+			GoVersion:   info.GoVersion,
+			Module:      info.ModulePath,
+			Version:     info.ModuleVersion,
+			VCSRevision: info.VCSRevision,
+			VCSModified: info.VCSModified,
+		}
+		if !info.VCSTime.IsZero() {
+			t := info.VCSTime
+			b.VCSTime = &t
+		}
+		result = append(result, b)
+	}
+	return result
+}
+
+// newMonitor creates a notification monitor if a webhook is configured,
+// along with a function that flushes pending notifications.
+// Returns a nil monitor if notifications are disabled.
+func newMonitor(ctx context.Context, cfg *config.Config) (*notify.Monitor, func(), error) {
+	var notifiers notify.MultiNotifier
+	var closers []func() error
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	if cfg.WebhookURL != "" {
+		var tmpl string
+		if cfg.WebhookTemplate != "" {
+			data, err := os.ReadFile(cfg.WebhookTemplate)
+			if err != nil {
+				return nil, nil, fmt.Errorf("reading webhook template: %w", err)
+			}
+			tmpl = string(data)
+		}
+		webhook, err := notify.NewWebhook(ctx, cfg.WebhookURL, tmpl)
+		if err != nil {
+			return nil, nil, err
+		}
+		notifiers = append(notifiers, webhook)
+		closers = append(closers, webhook.Close)
+	}
+	if cfg.Events {
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("Kubernetes Events: %w", err)
+		}
+		events := kube.NewEventRecorder(ctx, client, cfg.Namespace, cfg.PodName, cfg.PodUID)
+		notifiers = append(notifiers, events)
+		closers = append(closers, events.Close)
+	}
+	if len(notifiers) == 0 {
+		return nil, func() {}, nil
+	}
+	monitor := notify.NewMonitor(notifiers, notify.Thresholds{
+		WatchPaths:      cfg.WatchPaths,
+		DropRatePercent: cfg.DropRateThreshold,
+	}, cfg.PodName, cfg.Namespace)
+	return monitor, closeAll, nil
+}
+
+// fileRecorder records each newly accessed file as it happens.
+type fileRecorder interface {
+	FileAccessed(container, path string) error
+}
+
+// newReporter builds the set of reporters enabled by the configuration. The
+// syslog reporter and audit log, if enabled, are also returned on their own
+// so that new-file events can be sent to them as they happen.
+func newReporter(ctx context.Context, cfg *config.Config, m *metrics.Metrics) (reporter.Reporter, []fileRecorder, error) {
+	var reporters []reporter.Reporter
+	if cfg.ReportPath != "" {
+		var opts []reporter.FileReporterOption
+		if cfg.ReportCompact {
+			opts = append(opts, reporter.WithCompactOutput())
+		}
+		reporters = append(reporters, reporter.NewFileReporter(ctx, cfg.ReportPath, opts...))
+	}
+	if cfg.ReportDir != "" {
+		var opts []reporter.FileReporterOption
+		if cfg.ReportCompact {
+			opts = append(opts, reporter.WithCompactOutput())
+		}
+		log := clog.FromContext(ctx)
+		log.Infof("Writing per-pod reports to %s", cfg.ReportDir)
+		reporters = append(reporters, reporter.NewPodReporter(cfg.ReportDir, opts...))
+	}
+	if cfg.ReportURL != "" {
+		reporters = append(reporters, reporter.NewHTTPReporter(ctx, cfg.ReportURL))
+	}
+	if cfg.PushgatewayURL != "" {
+		reporters = append(reporters, metrics.NewPushReporter(ctx, m, cfg.PushgatewayURL, "snoop", map[string]string{
+			"namespace": cfg.Namespace,
+			"pod":       cfg.PodName,
+		}))
+	}
+	if cfg.OTLPEndpoint != "" {
+		reporters = append(reporters, reporter.NewOTLPReporter(ctx, cfg.OTLPEndpoint))
+	}
+	if cfg.RemoteWriteURL != "" {
+		reporters = append(reporters, metrics.NewRemoteWriteReporter(ctx, cfg.RemoteWriteURL))
+	}
+	if cfg.ReportSocket != "" {
+		sock, err := reporter.NewSocketReporter(ctx, cfg.ReportSocket)
+		if err != nil {
+			return nil, nil, err
+		}
+		reporters = append(reporters, sock)
+	}
+	if cfg.ReportCRD {
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			return nil, nil, fmt.Errorf("FileAccessReport resources: %w", err)
+		}
+		reporters = append(reporters, kube.NewReportReporter(ctx, client))
+	}
+	var recorders []fileRecorder
+	if cfg.SyslogAddr != "" {
+		syslog, err := reporter.NewSyslogReporter(ctx, cfg.SyslogAddr, cfg.PodName, cfg.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		reporters = append(reporters, syslog)
+		recorders = append(recorders, syslog)
+	}
+	if cfg.AuditLog != "" {
+		audit, err := reporter.NewAuditLog(ctx, cfg.AuditLog, cfg.PodName, cfg.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		reporters = append(reporters, audit)
+		recorders = append(recorders, audit)
+	}
+	if len(reporters) == 1 {
+		return reporters[0], recorders, nil
+	}
+	return reporter.NewMultiReporter(reporters...), recorders, nil
+}
+
 func run(ctx context.Context, cfg *config.Config) error {
 	log := clog.FromContext(ctx)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	build := version.Get()
+	log.Info("Starting snoop", "version", build.Version, "commit", build.Commit, "bpf_object", build.BPFObject)
+	agent := &reporter.AgentInfo{
+		Version:   build.Version,
+		Commit:    build.Commit,
+		BuildDate: build.BuildDate,
+		GoVersion: build.GoVersion,
+		BPFObject: build.BPFObject,
+	}
+
+	// Settings in the config directory override the flags, and are applied
+	// again whenever the directory changes
+	live, err := config.NewReloader(cfg)
+	if err != nil {
+		return fmt.Errorf("loading config directory: %w", err)
+	}
+	cfg = live.Config()
+	if cfg.ConfigDir != "" {
+		log.Infof("Loaded settings from %s, checking for changes every %s", cfg.ConfigDir, cfg.ConfigReload)
+	}
+
 	// Handle signals for graceful shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
@@ -125,22 +820,76 @@ func run(ctx context.Context, cfg *config.Config) error {
 		cancel()
 	}()
 
+	// Reports can be requested outside the normal interval with SIGUSR1 or
+	// POST /report; requests that arrive while one is pending are coalesced
+	reportNow := make(chan struct{}, 1)
+	requestReport := func() {
+		select {
+		case reportNow <- struct{}{}:
+		default:
+		}
+	}
+	usr1Ch := make(chan os.Signal, 1)
+	signal.Notify(usr1Ch, syscall.SIGUSR1)
+	defer signal.Stop(usr1Ch)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-usr1Ch:
+				log.Info("Received SIGUSR1, writing report")
+				requestReport()
+			}
+		}
+	}()
+
 	// Initialize metrics and health checker
 	m := metrics.New()
+	m.RegisterBuildInfo(build)
 	healthChecker := health.New()
 
-	// Start metrics and health server if address is provided
+	// Start metrics and health server if address is provided. Handlers
+	// needing the processor are added to mux once it exists.
+	mux := http.NewServeMux()
 	if cfg.MetricsAddr != "" {
-		mux := http.NewServeMux()
 		mux.Handle("/metrics", m.Handler())
 		mux.Handle("/healthz", healthChecker.Handler())
+		mux.HandleFunc("POST /report", func(w http.ResponseWriter, r *http.Request) {
+			log.Info("Report requested via HTTP")
+			requestReport()
+			w.WriteHeader(http.StatusAccepted)
+		})
+		if cfg.Pprof {
+			mux.HandleFunc("/debug/pprof/", pprof.Index)
+			mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+			mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+			mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+			mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		}
+		secure, err := serving.New(serving.Options{
+			CertFile:     cfg.MetricsTLSCert,
+			KeyFile:      cfg.MetricsTLSKey,
+			ClientCAFile: cfg.MetricsClientCA,
+			ClientIDs:    cfg.MetricsClientIDs,
+			TokenFile:    cfg.MetricsTokenFile,
+			Public:       []string{"/healthz"},
+		})
+		if err != nil {
+			return fmt.Errorf("configuring metrics server: %w", err)
+		}
 		server := &http.Server{
-			Addr:    cfg.MetricsAddr,
-			Handler: mux,
+			Addr:      cfg.MetricsAddr,
+			Handler:   secure.Handler(mux),
+			TLSConfig: secure.TLSConfig(),
 		}
 		go func() {
 			log.Infof("Starting metrics and health server on %s", cfg.MetricsAddr)
-			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serve := server.ListenAndServe
+			if server.TLSConfig != nil {
+				serve = func() error { return server.ListenAndServeTLS("", "") }
+			}
+			if err := serve(); err != nil && err != http.ErrServerClosed {
 				log.Errorf("Metrics server error: %v", err)
 			}
 		}()
@@ -151,62 +900,358 @@ func run(ctx context.Context, cfg *config.Config) error {
 		}()
 	}
 
+	// Notifiers are set up first so a failure to load the probe is reported
+	monitor, closeMonitor, err := newMonitor(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("creating notifier: %w", err)
+	}
+	defer closeMonitor()
+
+	var baseline *reporter.Baseline
+	if cfg.Baseline != "" {
+		report, err := readReport(cfg.Baseline)
+		if err != nil {
+			return fmt.Errorf("reading baseline: %w", err)
+		}
+		baseline = reporter.NewBaseline(report)
+		log.Infof("Alerting on files outside the baseline of %d containers from %s", len(report.Containers), cfg.Baseline)
+		for _, name := range reporter.Incomplete(report) {
+			log.Warnf("Baseline of container %s is incomplete (truncated or evicted files), so files it did access may be flagged", name)
+		}
+	}
+
 	// Create and load the eBPF probe
 	log.Info("Loading eBPF program")
 	probe, err := ebpf.NewProbe(ctx)
 	if err != nil {
+		if monitor != nil {
+			monitor.ProbeFailed(err)
+		}
 		return fmt.Errorf("creating probe: %w", err)
 	}
 	defer probe.Close()
 
 	log.Info("eBPF program loaded successfully")
 	healthChecker.SetEBPFLoaded()
+	m.RegisterRingBuffer(probe)
+	// Rules and baseline alerts must see every file access, so they rule out
+	// sampling; exec events, which flag unowned executables, are never sampled
+	maxSampleRate := cfg.MaxSampleRate
+	if maxSampleRate > 1 && (len(cfg.Rules) > 0 || cfg.Baseline != "") {
+		log.Warn("Not sampling events under backpressure, since rules and baseline alerts must see every file access")
+		maxSampleRate = 1
+	}
+	sampler := sampling.New(maxSampleRate)
+	m.RegisterSampler(sampler)
 
-	// Auto-discover all containers in the pod
-	log.Info("Discovering containers in pod")
-	discoveredContainers, err := cgroup.DiscoverAllExceptSelf()
+	// Auto-discover all containers in the pod, or on the Docker host
+	source := newContainerSource(cfg)
+	if s, ok := source.(starter); ok {
+		defer s.stop(ctx)
+	}
+	watching := cfg.DiscoveryInterval > 0 || cfg.ContainerdSocket != "" || cfg.DockerSocket != ""
+	switch {
+	case len(cfg.RunCommand) > 0 || cfg.RunPID != 0:
+		log.Info("Creating a transient cgroup for the traced process")
+	case cfg.SystemdUnit != "":
+		log.Infof("Discovering cgroups of systemd unit %s", cfg.SystemdUnit)
+	case cfg.DockerSocket != "":
+		log.Infof("Discovering containers on Docker host %s", cfg.DockerSocket)
+	case cfg.Node:
+		log.Info("Discovering containers of every pod on the node")
+	default:
+		log.Info("Discovering containers in pod")
+	}
+	discoveredContainers, err := discoverWithRetry(ctx, cfg, source, healthChecker)
 	if err != nil {
 		return fmt.Errorf("discovering containers: %w", err)
 	}
 
-	if len(discoveredContainers) == 0 {
-		return fmt.Errorf("no containers discovered (pod has only snoop?)")
+	images := source.identify(ctx, discoveredContainers)
+	processorContainers := toProcessorContainers(source, selectContainers(ctx, cfg, discoveredContainers, images), images)
+	if len(processorContainers) == 0 {
+		if !watching {
+			return fmt.Errorf("no containers to trace (pod has only snoop, or all were skipped?)")
+		}
+		log.Warn("No containers to trace yet, waiting for containers to start")
 	}
 
-	log.Infof("Discovered %d containers to trace", len(discoveredContainers))
-	for cgroupID, info := range discoveredContainers {
+	log.Infof("Discovered %d containers to trace", len(processorContainers))
+	for cgroupID, info := range processorContainers {
 		log.Infof("  - %s (cgroup_id=%d, path=%s)", info.Name, cgroupID, info.CgroupPath)
 		if err := probe.AddTracedCgroup(cgroupID); err != nil {
 			return fmt.Errorf("adding cgroup %s: %w", info.Name, err)
 		}
 	}
 
-	// Convert cgroup.ContainerInfo to processor.ContainerInfo to avoid import cycle
-	processorContainers := make(map[uint64]*processor.ContainerInfo)
-	for cgroupID, info := range discoveredContainers {
-		processorContainers[cgroupID] = &processor.ContainerInfo{
-			CgroupID:   info.CgroupID,
-			CgroupPath: info.CgroupPath,
-			Name:       info.Name,
+	// Create processor and reporter
+	procOpts := []processor.Option{processor.WithUnknownContainerWarnInterval(cfg.ReportInterval)}
+	if cfg.FileMetadata {
+		procOpts = append(procOpts, processor.WithFileMetadata(nil))
+	}
+	if cfg.GoBuildInfo {
+		procOpts = append(procOpts, processor.WithGoBuildInfo(nil))
+	}
+	if cfg.LibraryDeps {
+		procOpts = append(procOpts, processor.WithLibraryDependencies(nil))
+	}
+	if cfg.JavaClasspath {
+		procOpts = append(procOpts, processor.WithJavaClasspath(nil))
+	}
+	if cfg.PackagesSBOM != "" {
+		procOpts = append(procOpts, processor.WithPackageAttribution(nil, sbom.Loader(cfg.PackagesSBOM)))
+	} else if cfg.Packages {
+		procOpts = append(procOpts, processor.WithPackageAttribution(nil, dpkg.Load, apk.Loader(cfg.APKDatabases...)))
+		procOpts = append(procOpts, processor.WithPackageLoadRetry(cfg.PackageLoadAttempts, cfg.PackageLoadRetry))
+		if cfg.PackagesReload > 0 {
+			watch := append([]string{dpkg.StatusPath}, cfg.APKDatabases...)
+			procOpts = append(procOpts, processor.WithPackageReload(cfg.PackagesReload, watch...))
+		}
+		if cfg.PackagesImage {
+			procOpts = append(procOpts, processor.WithImagePackages(apk.ImageLoader(registry.NewClient(), cfg.APKDatabases...)))
+		}
+	}
+	if cfg.UnusedFiles {
+		procOpts = append(procOpts, processor.WithUnusedPackageFiles())
+	}
+	if cfg.PackagesVerify {
+		procOpts = append(procOpts, processor.WithPackageVerification())
+	}
+	var dirLoaders []packages.DirLoader
+	if cfg.PythonPackages {
+		dirLoaders = append(dirLoaders, python.DirLoader())
+	}
+	if cfg.NpmPackages {
+		dirLoaders = append(dirLoaders, npm.DirLoader())
+	}
+	if len(dirLoaders) > 0 {
+		procOpts = append(procOpts, processor.WithLanguagePackages(nil, dirLoaders...))
+	}
+	if cfg.Layers {
+		procOpts = append(procOpts, processor.WithLayerAttribution(nil, cfg.LayersHostRoot))
+	}
+	if cfg.Coverage {
+		procOpts = append(procOpts, processor.WithCoverage(cfg.CoverageLargest))
+	}
+	if cfg.VolumeFiles {
+		procOpts = append(procOpts, processor.WithVolumeClassification(nil))
+	}
+	if cfg.HashFiles {
+		procOpts = append(procOpts, processor.WithContentHashing(nil, cfg.HashMaxSize, cfg.HashWorkers))
+	}
+	if len(cfg.Containers) > 0 {
+		procOpts = append(procOpts, processor.WithContainerOverrides(func(info *processor.ContainerInfo) processor.ContainerOverrides {
+			s := live.Config().ContainerSettingsFor(info.Name)
+			if s == nil {
+				return processor.ContainerOverrides{}
+			}
+			return processor.ContainerOverrides{Exclude: s.Exclude, MaxUniqueFiles: s.MaxUniqueFiles, MaxPathBytes: s.MaxPathBytes}
+		}))
+	}
+	if cfg.MaxPathBytes > 0 {
+		procOpts = append(procOpts, processor.WithMaxPathBytes(cfg.MaxPathBytes))
+	}
+	var proc *processor.Processor
+	if len(cfg.Rules) > 0 {
+		rules := make([]policy.Rule, len(cfg.Rules))
+		for i, rs := range cfg.Rules {
+			rules[i] = rs.Rule()
 		}
+		procOpts = append(procOpts, processor.WithRules(rules, func(a processor.RuleAlertEvent) {
+			name := containerName(proc, a.CgroupID)
+			log.Warn("File access rule matched",
+				"rule", a.Rule, "container", name, "path", a.Path, "pid", a.PID, "process", a.Process, "operation", a.Operation)
+			if monitor != nil {
+				monitor.RuleAlert(name, a.Rule, a.Path, a.Process)
+			}
+		}))
+	}
+	proc = processor.NewProcessor(ctx, processorContainers, cfg.ExcludePaths, cfg.MaxUniqueFiles, procOpts...)
+	defer proc.Close()
+	if len(cfg.Rules) > 0 {
+		m.RegisterRules(proc.RuleHits)
 	}
+	if cfg.SnapshotOpenFiles {
+		for _, info := range processorContainers {
+			snapshotOpenFiles(ctx, proc, info)
+		}
+	}
+	rep, recorders, err := newReporter(ctx, cfg, m)
+	if err != nil {
+		return fmt.Errorf("creating reporter: %w", err)
+	}
+	defer rep.Close()
 
-	// Create processor and reporter
-	proc := processor.NewProcessor(ctx, processorContainers, cfg.ExcludePaths, cfg.MaxUniqueFiles)
-	rep := reporter.NewFileReporter(ctx, cfg.ReportPath)
+	// Containers that went away are removed from the processor only after
+	// the next report, so what they accessed is reported at least once
+	var retired <-chan []uint64
+	if watching {
+		retired = watchContainers(ctx, live, source, probe, proc, healthChecker, discoveredContainers)
+	}
+
+	// Start the traced command, or adopt the traced process, now that its
+	// events will be processed; when it exits, write a final report and stop
+	if s, ok := source.(starter); ok {
+		if err := s.start(ctx, cancel); err != nil {
+			return fmt.Errorf("starting traced process: %w", err)
+		}
+	}
 
 	startedAt := time.Now()
-	log.Infof("Writing reports to: %s (interval: %s)", cfg.ReportPath, cfg.ReportInterval)
+	log.Infof("Writing reports every %s", cfg.ReportInterval)
+
+	// With -duration, stop after the tracing window like on a signal, writing
+	// a final report on the way out
+	if cfg.Duration > 0 {
+		log.Infof("Tracing for %s", cfg.Duration)
+		stop := time.AfterFunc(cfg.Duration, func() {
+			log.Infof("Traced for %s, stopping", cfg.Duration)
+			cancel()
+		})
+		defer stop.Stop()
+	}
+
+	// With -exit-when-stable, stop once no new files have been recorded for
+	// -stable-after, waking only when the window could next be up
+	if cfg.ExitWhenStable {
+		go func() {
+			for {
+				stableSince := proc.Aggregate().LastNewFile
+				if stableSince.IsZero() {
+					stableSince = startedAt
+				}
+				wait := time.Until(stableSince.Add(cfg.StableAfter))
+				if wait <= 0 {
+					log.Infof("No new files for %s, stopping", cfg.StableAfter)
+					cancel()
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+		}()
+	}
 
 	// Track last seen drops and evictions count for computing deltas
 	var lastDrops uint64
 	var lastEvicted uint64
+	var lastReceived uint64
+	lastEvictedPerContainer := make(map[uint64]uint64)
+	lastReceivedPerContainer := make(map[uint64]uint64)
+	packageBaseline := make(map[uint64]bool)
 	var finalReportWritten bool
+	var converged bool
+	var eventCount uint64
+
+	// Restarted containers are reported under their name with what previous
+	// runs accessed, from the last report of each container that went away
+	restarts := reporter.NewRestarts(restartWindow)
+	lastReports := make(map[uint64]reporter.ContainerReport)
 
 	// Start periodic report writer
 	reportTicker := time.NewTicker(cfg.ReportInterval)
 	defer reportTicker.Stop()
 
+	reloaded := live.Watch(ctx, cfg.ConfigReload)
+
+	// buildReport assembles a report from what the processor has recorded;
+	// the report API calls it between intervals with the live configuration
+	buildReport := func(cfg *config.Config, containerStats map[uint64]processor.ContainerStats, aggregateStats processor.AggregateStats, drops uint64) *reporter.Report {
+		filesPerContainer, truncatedPerContainer := proc.TopFiles(cfg.ReportMaxFiles)
+		metadataPerContainer := proc.Metadata()
+		digestsPerContainer := proc.Digests()
+		packagesPerContainer := proc.Packages()
+		langPackagesPerContainer := proc.LanguagePackages()
+		buildInfoPerContainer := proc.BuildInfo()
+		layersPerContainer := proc.Layers()
+		modifiedPerContainer := proc.VerifyPackageFiles()
+		preexistingPerContainer := proc.Preexisting()
+		unownedPerContainer := proc.UnownedExecutables()
+		volumesPerContainer := proc.VolumeFiles()
+		coveragePerContainer := proc.Coverage()
+		librariesPerContainer := proc.UnobservedLibraries()
+		jarsPerContainer := proc.Jars()
+		containers := make([]reporter.ContainerReport, 0, len(containerStats))
+		for cgroupID, stats := range containerStats {
+			pkgs := convertPackages(packagesPerContainer[cgroupID])
+			var pythonModules []string
+			if cfg.PythonPackages {
+				pythonModules = python.Modules(filesPerContainer[cgroupID])
+			}
+			layers, fileLayers, unusedFileBytes := convertLayers(layersPerContainer[cgroupID])
+			containers = append(containers, reporter.ContainerReport{
+				Name:                stats.Name,
+				ContainerID:         stats.ID,
+				PodUID:              stats.PodUID,
+				PodName:             stats.PodName,
+				PodNamespace:        stats.PodNamespace,
+				Ephemeral:           stats.Ephemeral,
+				CgroupID:            cgroupID,
+				CgroupPath:          stats.CgroupPath,
+				ImageRef:            stats.ImageRef,
+				ImageDigest:         stats.ImageDigest,
+				Files:               filesPerContainer[cgroupID],
+				FilesTruncated:      truncatedPerContainer[cgroupID],
+				TotalEvents:         stats.EventsReceived,
+				UniqueFiles:         stats.UniqueFiles,
+				EvictedFiles:        stats.EventsEvicted,
+				PreexistingFiles:    preexistingPerContainer[cgroupID],
+				FileMetadata:        convertMetadata(metadataPerContainer[cgroupID]),
+				FileDigests:         digestsPerContainer[cgroupID],
+				Layers:              layers,
+				FileLayers:          fileLayers,
+				Packages:            pkgs,
+				RemovablePackages:   reporter.RemovableSets(pkgs),
+				UnusedPackageBytes:  reporter.UnusedSize(pkgs),
+				UnusedFileBytes:     unusedFileBytes,
+				Coverage:            convertCoverage(coveragePerContainer[cgroupID]),
+				ModifiedFiles:       convertModified(modifiedPerContainer[cgroupID]),
+				UnownedExecutables:  unownedPerContainer[cgroupID],
+				UnobservedLibraries: convertLibraries(librariesPerContainer[cgroupID]),
+				VolumeFiles:         convertVolumes(volumesPerContainer[cgroupID]),
+				PythonPackages:      convertPackages(langPackagesPerContainer[cgroupID][python.Ecosystem]),
+				PythonModules:       pythonModules,
+				Jars:                convertJars(jarsPerContainer[cgroupID]),
+				NpmPackages:         convertPackages(langPackagesPerContainer[cgroupID][npm.Ecosystem]),
+				GoBinaries:          convertBuildInfo(buildInfoPerContainer[cgroupID]),
+			})
+			if cfg.VolumeFiles {
+				c := &containers[len(containers)-1]
+				c.VolumeSummary = reporter.SummarizeVolumes(*c)
+			}
+		}
+		restarts.Apply(containers)
+
+		report := &reporter.Report{
+			PodName:       cfg.PodName,
+			Namespace:     cfg.Namespace,
+			NodeName:      cfg.NodeName,
+			Agent:         agent,
+			StartedAt:     startedAt,
+			Containers:    containers,
+			TotalEvents:   aggregateStats.EventsReceived,
+			DroppedEvents: drops,
+		}
+		// In node mode, reports cover other pods, whose UIDs are per container
+		if !cfg.Node {
+			report.PodUID = cfg.PodUID
+			report.Labels = cfg.Labels
+		}
+		if s := sampler.Stats(); s.PeakRate > 1 {
+			report.Sampling = &reporter.SamplingInfo{
+				SkippedEvents:   s.Skipped,
+				Rate:            s.Rate,
+				PeakRate:        s.PeakRate,
+				DegradedSeconds: s.Degraded.Seconds(),
+			}
+		}
+		report.SetConvergence(aggregateStats.LastNewFile, cfg.StableAfter, time.Now())
+		return report
+	}
+
 	writeReport := func() {
 		containerStats := proc.Stats()
 		aggregateStats := proc.Aggregate()
@@ -217,11 +1262,12 @@ func run(ctx context.Context, cfg *config.Config) error {
 		}
 
 		// Update the drops counter metric with the delta
+		var dropsDelta uint64
 		if drops > lastDrops {
-			delta := drops - lastDrops
-			m.EventsDropped.Add(float64(delta))
-			if delta > 0 {
-				log.Warnf("Ring buffer overflow: %d events dropped since last report", delta)
+			dropsDelta = drops - lastDrops
+			m.EventsDropped.Add(float64(dropsDelta))
+			if dropsDelta > 0 {
+				log.Warnf("Ring buffer overflow: %d events dropped since last report", dropsDelta)
 			}
 			lastDrops = drops
 		}
@@ -236,34 +1282,81 @@ func run(ctx context.Context, cfg *config.Config) error {
 			lastEvicted = aggregateStats.EventsEvicted
 		}
 
-		// Build per-container reports
-		filesPerContainer := proc.Files()
-		containers := make([]reporter.ContainerReport, 0, len(containerStats))
+		// Update per-container metrics
 		for cgroupID, stats := range containerStats {
-			containers = append(containers, reporter.ContainerReport{
-				Name:        stats.Name,
-				CgroupID:    cgroupID,
-				CgroupPath:  stats.CgroupPath,
-				Files:       filesPerContainer[cgroupID],
-				TotalEvents: stats.EventsReceived,
-				UniqueFiles: stats.UniqueFiles,
-			})
+			label := metricsContainerLabel(stats.Name, stats.PodName, stats.PodNamespace)
+			if stats.EventsReceived > lastReceivedPerContainer[cgroupID] {
+				m.ContainerEventsReceived.WithLabelValues(label).Add(float64(stats.EventsReceived - lastReceivedPerContainer[cgroupID]))
+				lastReceivedPerContainer[cgroupID] = stats.EventsReceived
+			}
+			m.ContainerUniqueFiles.WithLabelValues(label).Set(float64(stats.UniqueFiles))
 		}
 
-		report := &reporter.Report{
-			PodName:       cfg.PodName,
-			Namespace:     cfg.Namespace,
-			StartedAt:     startedAt,
-			Containers:    containers,
-			TotalEvents:   aggregateStats.EventsReceived,
-			DroppedEvents: drops,
+		// Check notification thresholds for this interval
+		if monitor != nil {
+			evicted := make(map[string]uint64, len(containerStats))
+			for cgroupID, stats := range containerStats {
+				evicted[stats.Name] = stats.EventsEvicted - lastEvictedPerContainer[cgroupID]
+				lastEvictedPerContainer[cgroupID] = stats.EventsEvicted
+			}
+			monitor.Interval(aggregateStats.EventsReceived-lastReceived, dropsDelta, evicted)
+			lastReceived = aggregateStats.EventsReceived
+		}
+
+		report := buildReport(cfg, containerStats, aggregateStats, drops)
+		if report.Converged && !converged {
+			log.Infof("Report converged: no new files for %s", cfg.StableAfter)
+		}
+		converged = report.Converged
+		clear(lastReports)
+		for _, c := range report.Containers {
+			lastReports[c.CgroupID] = c
+		}
+		if cfg.Packages || cfg.PythonPackages || cfg.NpmPackages {
+			usage := make(map[string][]reporter.PackageReport, len(report.Containers))
+			for _, c := range report.Containers {
+				label := metricsContainerLabel(c.Name, c.PodName, c.PodNamespace)
+				usage[label] = slices.Concat(c.Packages, c.PythonPackages, c.NpmPackages)
+			}
+			m.SetPackageUsage(usage, cfg.PackageMetricsTop)
+		}
+		if cfg.Packages {
+			for _, c := range report.Containers {
+				label := metricsContainerLabel(c.Name, c.PodName, c.PodNamespace)
+				m.ContainerUnownedExecutables.WithLabelValues(label).Set(float64(len(c.UnownedExecutables)))
+			}
+		}
+
+		// Notify about packages that became used since the last report; the
+		// first report with packages for a container sets the baseline
+		if monitor != nil {
+			for _, c := range report.Containers {
+				if len(c.Packages) == 0 {
+					continue
+				}
+				var used []string
+				for _, p := range c.Packages {
+					if p.Used() && p.Name != packages.OrphanName {
+						used = append(used, p.Name)
+					}
+				}
+				if !packageBaseline[c.CgroupID] {
+					monitor.PackagesBaseline(c.Name, used)
+					packageBaseline[c.CgroupID] = true
+					continue
+				}
+				for _, p := range used {
+					monitor.PackageUsed(c.Name, p)
+				}
+			}
 		}
+
 		if err := rep.Update(ctx, report); err != nil {
 			log.Errorf("Error writing report: %v", err)
 			m.ReportWriteErrors.Inc()
 		} else {
 			log.Infof("Report written: %d containers, %d unique files, %d events processed, %d dropped, %d evicted",
-				len(containers), aggregateStats.UniqueFiles, aggregateStats.EventsProcessed, drops, aggregateStats.EventsEvicted)
+				len(report.Containers), aggregateStats.UniqueFiles, aggregateStats.EventsProcessed, drops, aggregateStats.EventsEvicted)
 			m.ReportWrites.Inc()
 			healthChecker.RecordReportWritten()
 		}
@@ -271,36 +1364,130 @@ func run(ctx context.Context, cfg *config.Config) error {
 		m.UniqueFiles.Set(float64(aggregateStats.UniqueFiles))
 	}
 
-	// Read and process events
+	// Serve live report state between intervals
+	if cfg.ServeAddr != "" {
+		// The API is secured like the metrics server, with no public paths
+		secure, err := serving.New(serving.Options{
+			CertFile:     cfg.MetricsTLSCert,
+			KeyFile:      cfg.MetricsTLSKey,
+			ClientCAFile: cfg.MetricsClientCA,
+			ClientIDs:    cfg.MetricsClientIDs,
+			TokenFile:    cfg.MetricsTokenFile,
+		})
+		if err != nil {
+			return fmt.Errorf("configuring report API server: %w", err)
+		}
+		server := &http.Server{
+			Addr: cfg.ServeAddr,
+			Handler: secure.Handler(reporter.NewAPIHandler(func() *reporter.Report {
+				drops, err := probe.Drops()
+				if err != nil {
+					log.Warnf("Failed to read drops counter: %v", err)
+				}
+				return buildReport(live.Config(), proc.Stats(), proc.Aggregate(), drops)
+			})),
+			TLSConfig: secure.TLSConfig(),
+		}
+		go func() {
+			log.Infof("Serving report API on %s", cfg.ServeAddr)
+			serve := server.ListenAndServe
+			if server.TLSConfig != nil {
+				serve = func() error { return server.ListenAndServeTLS("", "") }
+			}
+			if err := serve(); err != nil && err != http.ErrServerClosed {
+				log.Errorf("Report API server error: %v", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer shutdownCancel()
+			server.Shutdown(shutdownCtx)
+		}()
+	}
+
+	if cfg.DebugState {
+		mux.HandleFunc("GET /debug/state", func(w http.ResponseWriter, r *http.Request) {
+			data, err := json.MarshalIndent(struct {
+				Config     *config.Config             `json:"config"`
+				Containers []processor.ContainerState `json:"containers"`
+			}{live.Config(), proc.State(r.URL.Query().Get("prefix"))}, "", "  ")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(append(data, '\n'))
+		})
+	}
+
+	// finish writes the final report on shutdown, and fails a -duration run
+	// whose drop rate over the whole run exceeded -drop-rate-threshold, so
+	// that CI jobs don't archive an incomplete report as a good one
+	finish := func() error {
+		if !finalReportWritten {
+			log.Info("Writing final report")
+			writeReport()
+			finalReportWritten = true
+		}
+		if cfg.Duration > 0 && cfg.DropRateThreshold > 0 {
+			if rate := notify.DropRate(proc.Aggregate().EventsReceived, lastDrops); rate > cfg.DropRateThreshold {
+				return fmt.Errorf("ring buffer drop rate %.1f%% exceeds %.1f%% (%d events dropped)", rate, cfg.DropRateThreshold, lastDrops)
+			}
+		}
+		return nil
+	}
+
+	// Read and process events. Events are read on their own goroutine so
+	// that reports, reloads, and shutdown aren't held up waiting for one.
 	log.Info("Waiting for events (press Ctrl+C to exit)")
+	events := probe.Events(ctx, sampler)
 	for {
 		select {
 		case <-ctx.Done():
 			// Graceful shutdown: write final report
-			if !finalReportWritten {
-				log.Info("Writing final report")
-				writeReport()
-				finalReportWritten = true
-			}
-			return nil
+			return finish()
 
 		case <-reportTicker.C:
 			writeReport()
 
-		default:
-			event, err := probe.ReadEvent(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					// Context cancelled, write final report
-					if !finalReportWritten {
-						log.Info("Writing final report")
-						writeReport()
-						finalReportWritten = true
-					}
-					return nil
+		case <-reportNow:
+			writeReport()
+			reportTicker.Reset(cfg.ReportInterval)
+
+		case next := <-reloaded:
+			log.Infof("Reloaded settings from %s", next.ConfigDir)
+			proc.SetExclusions(next.ExcludePaths)
+			proc.SetMaxUniqueFiles(next.MaxUniqueFiles)
+			proc.SetMaxPathBytes(next.MaxPathBytes)
+			if monitor != nil {
+				monitor.SetWatchPaths(next.WatchPaths)
+			}
+			if next.ReportInterval != cfg.ReportInterval {
+				log.Infof("Writing reports every %s", next.ReportInterval)
+				reportTicker.Reset(next.ReportInterval)
+			}
+			cfg = next
+
+		case gone := <-retired:
+			writeReport()
+			for _, cgroupID := range gone {
+				if c, ok := lastReports[cgroupID]; ok {
+					restarts.Retire(c)
+				}
+				proc.RemoveContainer(cgroupID)
+				delete(lastEvictedPerContainer, cgroupID)
+				delete(lastReceivedPerContainer, cgroupID)
+				delete(packageBaseline, cgroupID)
+			}
+
+		case event, ok := <-events:
+			if !ok {
+				// The ring buffer was closed, write final report
+				if err := probe.Err(); err != nil {
+					finish()
+					return err
 				}
-				log.Errorf("Error reading event: %v", err)
-				continue
+				return finish()
 			}
 
 			// Convert ebpf.Event to processor.Event
@@ -311,8 +1498,9 @@ func run(ctx context.Context, cfg *config.Config) error {
 				Path:      event.Path,
 			}
 
-			// Update received counter
+			// Update received counters
 			m.EventsReceived.Inc()
+			m.EventsBySyscall.WithLabelValues(ebpf.SyscallName(event.SyscallNr)).Inc()
 			healthChecker.RecordEventReceived()
 
 			cgroupID, path, result := proc.Process(procEvent)
@@ -320,12 +1508,45 @@ func run(ctx context.Context, cfg *config.Config) error {
 			case processor.ResultNew:
 				m.EventsProcessed.Inc()
 				log.Debugf("New file: %s (container cgroup_id=%d)", path, cgroupID)
+				name := containerName(proc, cgroupID)
+				if monitor != nil {
+					monitor.FileAccessed(name, path)
+				}
+				if baseline != nil && baseline.Violates(name, path) {
+					log.Warnf("Container %s accessed %s, which is outside its baseline", name, path)
+					if info := proc.Container(cgroupID); info != nil {
+						m.BaselineViolations.WithLabelValues(metricsContainerLabel(info.Name, info.PodName, info.PodNamespace)).Inc()
+					}
+					if monitor != nil {
+						monitor.BaselineViolation(name, path)
+					}
+				}
+				for _, r := range recorders {
+					if err := r.FileAccessed(name, path); err != nil {
+						log.Debugf("Recording file event: %v", err)
+					}
+				}
 			case processor.ResultDuplicate:
 				m.EventsDuplicate.Inc()
 			case processor.ResultExcluded:
 				m.EventsExcluded.Inc()
 			case processor.ResultUnknownContainer:
-				// Already logged by processor
+				// Logged by the processor, at most once per cgroup per interval
+				m.EventsUnknownContainer.WithLabelValues(strconv.FormatUint(cgroupID, 10)).Inc()
+			}
+
+			// Log a sample of events in full, to see what snoop is doing
+			// without debug logging every event
+			eventCount++
+			if cfg.DebugSampleRate > 0 && eventCount%uint64(cfg.DebugSampleRate) == 0 {
+				log.Info("Sampled event",
+					"path", path,
+					"raw_path", event.Path,
+					"container", containerName(proc, cgroupID),
+					"cgroup_id", cgroupID,
+					"pid", event.PID,
+					"syscall", ebpf.SyscallName(event.SyscallNr),
+					"result", result.String())
 			}
 		}
 	}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/imjasonh/snoop/pkg/inject"
+	"github.com/imjasonh/snoop/pkg/manifests"
+)
+
+// runGenManifests implements `snoop gen-manifests`, which writes manifests
+// for deploying snoop. hasFlag reports whether the snoop daemon defines a
+// flag, so manifests can't pass it flags this binary doesn't have.
+func runGenManifests(args []string, hasFlag func(name string) bool) error {
+	fs := flag.NewFlagSet("gen-manifests", flag.ExitOnError)
+	output := fs.String("o", "-", "Path to write the manifests (- for stdout)")
+	namespace := fs.String("namespace", "snoop-system", "Namespace for snoop's service account, DaemonSet, and injector")
+	image := fs.String("image", inject.DefaultImage, "snoop image to run")
+	serviceAccount := fs.String("service-account", "snoop", "Service account snoop runs as")
+	interval := fs.Duration("interval", 30*time.Second, "Interval between report writes")
+	snoopArgs := fs.String("args", "", "Whitespace-separated additional snoop flags (e.g. \"-packages -events\")")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop gen-manifests [flags] %s...\n\n", strings.Join(manifests.Kinds, "|"))
+		fmt.Fprintf(fs.Output(), "Generate Kubernetes manifests for deploying snoop:\n\n")
+		fmt.Fprintf(fs.Output(), "  rbac       namespace, service account, and the API access snoop uses\n")
+		fmt.Fprintf(fs.Output(), "  sidecar    strategic merge patch adding the sidecar to a workload\n")
+		fmt.Fprintf(fs.Output(), "  daemonset  node-wide DaemonSet tracing every pod on each node\n")
+		fmt.Fprintf(fs.Output(), "  webhook    sidecar injection webhook (requires cert-manager)\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts := manifests.Options{
+		Namespace:      *namespace,
+		Image:          *image,
+		ServiceAccount: *serviceAccount,
+		Interval:       *interval,
+		Args:           strings.Fields(*snoopArgs),
+		HasFlag:        hasFlag,
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" && *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	for i, kind := range fs.Args() {
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		if err := manifests.Render(w, kind, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
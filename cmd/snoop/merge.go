@@ -0,0 +1,44 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runMerge implements `snoop merge`, which unions several reports for the
+// same image into one.
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := fs.String("o", "-", "Path to write the merged report (- for stdout)")
+	byDigest := fs.Bool("by-digest", false, "Match containers by image digest instead of name, to combine every pod running an image")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop merge [-by-digest] [-o merged.json] report.json [report.json...]\n\n")
+		fmt.Fprintf(fs.Output(), "Union file sets and sum counters across reports for the same image.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	reports := make([]*reporter.Report, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		r, err := readReport(path)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, r)
+	}
+
+	if *byDigest {
+		return writeJSON(*output, reporter.MergeByImage(reports...))
+	}
+	return writeJSON(*output, reporter.Merge(reports...))
+}
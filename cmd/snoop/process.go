@@ -0,0 +1,211 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/cgroup"
+	"github.com/imjasonh/snoop/pkg/config"
+	"github.com/imjasonh/snoop/pkg/kube"
+)
+
+// processPollInterval is how often an existing process traced with -pid is
+// checked for having exited.
+const processPollInterval = time.Second
+
+// starter is implemented by sources that start tracing their target only
+// once the probe and processor are ready, and that need cleaning up after.
+type starter interface {
+	// start starts or adopts the traced processes, calling done when they
+	// have exited.
+	start(ctx context.Context, done func()) error
+
+	// stop releases whatever start set up.
+	stop(ctx context.Context)
+}
+
+// processSource traces a command snoop starts (`snoop run -- cmd`), or an
+// existing process tree (-pid), outside any container. The processes are
+// put in a transient cgroup of their own, which is traced like a container
+// named after the command.
+type processSource struct {
+	cfg  *config.Config
+	name string
+
+	// mu guards the transient cgroup, which is created on first discovery.
+	mu   sync.Mutex
+	path string
+	id   uint64
+
+	// moved records the original cgroups of processes moved for -pid, so
+	// they can be put back when snoop stops.
+	moved map[int]string
+}
+
+func newProcessSource(cfg *config.Config) *processSource {
+	name := "pid-" + strconv.Itoa(cfg.RunPID)
+	if len(cfg.RunCommand) > 0 {
+		name = filepath.Base(cfg.RunCommand[0])
+	} else if comm, err := cgroup.Proc.ReadFile(uint32(cfg.RunPID), "comm"); err == nil {
+		name = strings.TrimSpace(string(comm))
+	}
+	return &processSource{cfg: cfg, name: name}
+}
+
+func (s *processSource) discover(context.Context) (map[uint64]*cgroup.ContainerInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		path, id, err := cgroup.CreateTransient(fmt.Sprintf("snoop-run-%d", os.Getpid()))
+		if err != nil {
+			return nil, err
+		}
+		s.path, s.id = path, id
+	}
+	return map[uint64]*cgroup.ContainerInfo{
+		s.id: {CgroupID: s.id, CgroupPath: s.path, Name: s.name},
+	}, nil
+}
+
+func (s *processSource) identify(_ context.Context, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage {
+	result := make(map[uint64]kube.ContainerImage, len(containers))
+	for cgroupID := range containers {
+		result[cgroupID] = kube.ContainerImage{Name: s.name, Ref: s.cfg.ImageRef, Digest: s.cfg.ImageDigest}
+	}
+	return result
+}
+
+// rootfs is always empty: the traced processes share the host's root.
+func (s *processSource) rootfs(*cgroup.ContainerInfo) string { return "" }
+
+func (s *processSource) events(context.Context) <-chan struct{} { return nil }
+
+func (s *processSource) start(ctx context.Context, done func()) error {
+	if len(s.cfg.RunCommand) > 0 {
+		return s.startCommand(ctx, done)
+	}
+	return s.adopt(ctx, done)
+}
+
+// startCommand starts the command directly in the transient cgroup, so its
+// first file accesses are traced too.
+func (s *processSource) startCommand(ctx context.Context, done func()) error {
+	dir, err := os.Open(filepath.Join("/sys/fs/cgroup", s.path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	cmd := exec.Command(s.cfg.RunCommand[0], s.cfg.RunCommand[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(dir.Fd())}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting %s: %w", s.cfg.RunCommand[0], err)
+	}
+	log := clog.FromContext(ctx)
+	log.Infof("Started %s (pid %d)", s.name, cmd.Process.Pid)
+	go func() {
+		err := cmd.Wait()
+		var exit *exec.ExitError
+		switch {
+		case err == nil:
+			log.Infof("%s exited", s.name)
+		case errors.As(err, &exit):
+			log.Infof("%s exited: %v", s.name, exit)
+		default:
+			log.Errorf("Waiting for %s: %v", s.name, err)
+		}
+		done()
+	}()
+	return nil
+}
+
+// adopt moves an existing process and its descendants into the transient
+// cgroup. Files they opened before are not seen.
+func (s *processSource) adopt(ctx context.Context, done func()) error {
+	pid := s.cfg.RunPID
+	children, err := cgroup.Descendants(pid)
+	if err != nil {
+		return err
+	}
+	s.moved = make(map[int]string)
+	for _, p := range append([]int{pid}, children...) {
+		orig, err := cgroup.GetCgroupPathByPID(p)
+		if err != nil {
+			if p == pid {
+				return err
+			}
+			continue
+		}
+		if err := cgroup.MoveProcess(s.path, p); err != nil {
+			if p == pid {
+				return err
+			}
+			clog.FromContext(ctx).Warnf("Not tracing process %d: %v", p, err)
+			continue
+		}
+		s.moved[p] = orig
+	}
+	clog.FromContext(ctx).Infof("Tracing %s (pid %d) and %d descendants", s.name, pid, len(s.moved)-1)
+
+	go func() {
+		ticker := time.NewTicker(processPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+				clog.FromContext(ctx).Infof("%s (pid %d) exited", s.name, pid)
+				done()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stop moves adopted processes still running back to their original
+// cgroups, with processes they started since going to the target's, and
+// removes the transient cgroup.
+func (s *processSource) stop(ctx context.Context) {
+	log := clog.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		return
+	}
+	if s.moved != nil {
+		data, _ := os.ReadFile(filepath.Join("/sys/fs/cgroup", s.path, "cgroup.procs"))
+		for _, field := range strings.Fields(string(data)) {
+			p, err := strconv.Atoi(field)
+			if err != nil {
+				continue
+			}
+			orig, ok := s.moved[p]
+			if !ok {
+				orig = s.moved[s.cfg.RunPID]
+			}
+			if err := cgroup.MoveProcess(orig, p); err != nil {
+				log.Warnf("Restoring cgroup of process %d: %v", p, err)
+			}
+		}
+	}
+	if err := cgroup.RemoveTransient(s.path); err != nil {
+		log.Warnf("Removing cgroup %s: %v", s.path, err)
+	}
+}
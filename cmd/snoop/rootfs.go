@@ -0,0 +1,85 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/imjasonh/snoop/pkg/registry"
+)
+
+// runRootFS implements `snoop rootfs`, which writes a tar of only the files
+// a container accessed, taken from its source image.
+func runRootFS(args []string) error {
+	fs := flag.NewFlagSet("rootfs", flag.ExitOnError)
+	output := fs.String("o", "", "Path to write the root filesystem tar (- for stdout)")
+	container := fs.String("container", "", "Container to export (required if the report has more than one)")
+	image := fs.String("image", "", "Source image reference (default: the container's image in the report)")
+	keep := fs.String("keep", "", "Comma-separated globs of paths to keep even if they weren't accessed, e.g. /etc/ssl")
+	platform := fs.String("platform", "", "Platform to select from a multi-platform image, as os/arch (default: this machine's)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop rootfs [-container name] [-keep globs] -o rootfs.tar report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Write a tar holding only the files a container accessed, with their original\n")
+		fmt.Fprintf(fs.Output(), "modes and owners, from its source image, e.g. for docker import.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *output == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+	if !report.Converged {
+		fmt.Fprintf(os.Stderr, "warning: %s isn't converged, so the root filesystem may miss files the container uses later\n", fs.Arg(0))
+	}
+	warnIncomplete(c)
+	_, ref, err := sourceImage(c, *image)
+	if err != nil {
+		return err
+	}
+	client, err := platformClient(*platform)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	slim, err := client.Slim(ctx, ref, registry.SlimOptions{
+		Files: keptFiles(c),
+		Keep:  keptGlobs(c, *keep),
+	})
+	if err != nil {
+		return fmt.Errorf("slimming %s: %w", ref, err)
+	}
+	for _, f := range slim.Missing {
+		fmt.Fprintf(os.Stderr, "warning: %s is not in %s\n", f, ref)
+	}
+	fmt.Fprintf(os.Stderr, "Kept %d entries (%d bytes) from %s\n", slim.Files, slim.Size, ref)
+
+	if *output == "-" {
+		return slim.WriteRootFS(os.Stdout)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := slim.WriteRootFS(f); err != nil {
+		f.Close()
+		return errors.Join(err, os.Remove(*output))
+	}
+	return f.Close()
+}
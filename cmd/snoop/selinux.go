@@ -0,0 +1,70 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runSELinux implements `snoop selinux`, which turns observed file access
+// into an SELinux policy module.
+func runSELinux(args []string) error {
+	fs := flag.NewFlagSet("selinux", flag.ExitOnError)
+	output := fs.String("o", "-", "Path to write the policy module (- for stdout)")
+	container := fs.String("container", "", "Container to generate a policy for (required if the report has more than one)")
+	module := fs.String("module", "", "Policy module name (default snoop_<container>)")
+	domain := fs.String("domain", "container_t", "SELinux type the container's processes run as")
+	fileType := fs.String("file-type", "container_file_t", "SELinux type of files whose label the report doesn't record")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop selinux [-container name] [-o policy.te] report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Generate an SELinux type enforcement module allowing the file access a container was seen making.\n")
+		fmt.Fprintf(fs.Output(), "Record the report with -file-metadata to use each file's label and class.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+	if !report.Converged {
+		fmt.Fprintf(os.Stderr, "warning: %s isn't converged, so the policy may miss access the container makes later\n", fs.Arg(0))
+	}
+	opts := reporter.SELinuxOptions{Module: *module, Domain: *domain, FileType: *fileType}
+
+	if *output == "" || *output == "-" {
+		return writeSELinux(os.Stdout, c, opts)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeSELinux(f, c, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeSELinux(w io.Writer, c reporter.ContainerReport, opts reporter.SELinuxOptions) error {
+	bw := bufio.NewWriter(w)
+	if err := reporter.WriteSELinuxPolicy(bw, c, opts); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
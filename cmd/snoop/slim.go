@@ -0,0 +1,198 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/python"
+	"github.com/imjasonh/snoop/pkg/registry"
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+// runSlim implements `snoop slim`, which builds an image holding only the
+// files a container accessed.
+func runSlim(args []string) error {
+	fs := flag.NewFlagSet("slim", flag.ExitOnError)
+	output := fs.String("o", "", "Path to write the image as a docker save tarball (- for stdout)")
+	push := fs.String("push", "", "Image reference to push the image to")
+	tag := fs.String("tag", "", "Tag to record in the tarball (default: the -push reference)")
+	container := fs.String("container", "", "Container to slim (required if the report has more than one)")
+	image := fs.String("image", "", "Source image reference (default: the container's image in the report)")
+	keep := fs.String("keep", "", "Comma-separated globs of paths to keep even if they weren't accessed, e.g. /etc/ssl")
+	platform := fs.String("platform", "", "Platform to select from a multi-platform image, as os/arch (default: this machine's)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop slim [-container name] [-keep globs] (-o image.tar | -push ref) report.json\n\n")
+		fmt.Fprintf(fs.Output(), "Build a single-layer image holding only the files a container accessed, from\n")
+		fmt.Fprintf(fs.Output(), "its source image. Merge reports from several runs first to cover every path.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || (*output == "" && *push == "") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	report, err := readReport(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := selectContainer(report, *container)
+	if err != nil {
+		return err
+	}
+	warnIncomplete(c)
+
+	_, ref, err := sourceImage(c, *image)
+	if err != nil {
+		return err
+	}
+	var dst registry.Reference
+	if *push != "" {
+		if dst, err = registry.ParseReference(*push, ""); err != nil {
+			return err
+		}
+	}
+	client, err := platformClient(*platform)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	slim, err := client.Slim(ctx, ref, registry.SlimOptions{
+		Files: keptFiles(c),
+		Keep:  keptGlobs(c, *keep),
+	})
+	if err != nil {
+		return fmt.Errorf("slimming %s: %w", ref, err)
+	}
+	for _, f := range slim.Missing {
+		fmt.Fprintf(os.Stderr, "warning: %s is not in %s\n", f, ref)
+	}
+	fmt.Fprintf(os.Stderr, "Kept %d entries (%d bytes) from %s\n", slim.Files, slim.Size, ref)
+
+	if *output != "" {
+		if *tag == "" && *push != "" {
+			*tag = dst.String()
+		}
+		if err := writeTarball(*output, slim, *tag); err != nil {
+			return err
+		}
+	}
+	if *push != "" {
+		if err := client.Push(ctx, dst, slim); err != nil {
+			return fmt.Errorf("pushing %s: %w", dst, err)
+		}
+		fmt.Fprintf(os.Stderr, "Pushed %s\n", dst)
+	}
+	return nil
+}
+
+// warnIncomplete warns if c's file list is missing files, which an image
+// built from it would then lack.
+func warnIncomplete(c reporter.ContainerReport) {
+	if c.FilesTruncated > 0 || c.EvictedFiles > 0 {
+		fmt.Fprintf(os.Stderr, "warning: container %s's file list is incomplete (%d truncated, %d evicted); the image may be missing files it needs\n", c.Name, c.FilesTruncated, c.EvictedFiles)
+	}
+}
+
+// keptFiles returns the files an image built from c must keep: the accessed
+// files that weren't on volumes, and the shared libraries its executed
+// binaries need but weren't seen opening. It warns about needed libraries
+// the container doesn't have.
+func keptFiles(c reporter.ContainerReport) []string {
+	files := c.Files
+	if len(c.UnobservedLibraries) > 0 || len(c.VolumeFiles) > 0 {
+		volumes := reporter.VolumePaths(c)
+		files = make([]string, 0, len(c.Files))
+		for _, f := range c.Files {
+			// Volumes are mounted over the image, so their files aren't in it
+			if !volumes[f] {
+				files = append(files, f)
+			}
+		}
+	}
+	for _, l := range c.UnobservedLibraries {
+		if l.Path == "" {
+			fmt.Fprintf(os.Stderr, "warning: %s needs %s, which container %s doesn't have\n", strings.Join(l.RequiredBy, ", "), l.Name, c.Name)
+			continue
+		}
+		files = append(files, l.Path)
+	}
+	return files
+}
+
+// keptGlobs returns the globs of files an image built from c must keep in
+// addition to keptFiles: those in keep, a comma-separated list, and the
+// cached bytecode or source paired with each accessed Python file, so
+// Python doesn't import a source it has to recompile or bytecode whose
+// source is gone.
+func keptGlobs(c reporter.ContainerReport, keep string) []string {
+	globs := splitList(keep)
+	for _, f := range c.Files {
+		globs = append(globs, python.Counterparts(f)...)
+	}
+	return globs
+}
+
+// sourceImage returns the image c ran, or image if it's set, both as given
+// (pinned to the recorded digest) and parsed.
+func sourceImage(c reporter.ContainerReport, image string) (string, registry.Reference, error) {
+	src, digest := image, ""
+	if src == "" {
+		src, digest = c.ImageRef, c.ImageDigest
+	}
+	if src == "" && digest == "" {
+		return "", registry.Reference{}, fmt.Errorf("report doesn't record container %s's image; set -image", c.Name)
+	}
+	if src == "" {
+		return "", registry.Reference{}, fmt.Errorf("report only records container %s's image digest; set -image to its repository", c.Name)
+	}
+	ref, err := registry.ParseReference(src, digest)
+	if err != nil {
+		return "", registry.Reference{}, err
+	}
+	if digest != "" {
+		src, _, _ = strings.Cut(src, "@")
+		src += "@" + digest
+	}
+	return src, ref, nil
+}
+
+// platformClient returns a registry client selecting platform, given as
+// os/arch, from multi-platform images, or this machine's if it's empty.
+func platformClient(platform string) (*registry.Client, error) {
+	client := registry.NewClient()
+	if platform != "" {
+		goos, goarch, ok := strings.Cut(platform, "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("invalid platform %q, want os/arch", platform)
+		}
+		client.OS, client.Arch = goos, goarch
+	}
+	return client, nil
+}
+
+// writeTarball writes img to path, or to stdout if path is "-".
+func writeTarball(path string, img *registry.SlimImage, tag string) error {
+	if path == "-" {
+		return img.WriteTarball(os.Stdout, tag)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := img.WriteTarball(f, tag); err != nil {
+		f.Close()
+		return errors.Join(err, os.Remove(path))
+	}
+	return f.Close()
+}
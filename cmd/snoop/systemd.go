@@ -0,0 +1,75 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/cgroup"
+	"github.com/imjasonh/snoop/pkg/config"
+	"github.com/imjasonh/snoop/pkg/kube"
+)
+
+// systemdSource traces a systemd unit's cgroup, and any cgroups below it,
+// named after the unit. A unit that isn't running has no cgroup; it is
+// traced once it starts, and again under a new cgroup after each restart.
+type systemdSource struct {
+	cfg  *config.Config
+	unit string
+}
+
+func newSystemdSource(cfg *config.Config) *systemdSource {
+	return &systemdSource{cfg: cfg, unit: cgroup.UnitName(cfg.SystemdUnit)}
+}
+
+func (s *systemdSource) discover(ctx context.Context) (map[uint64]*cgroup.ContainerInfo, error) {
+	path, err := cgroup.UnitCgroupPath(ctx, s.unit)
+	if errors.Is(err, fs.ErrNotExist) {
+		clog.FromContext(ctx).Debugf("Unit %s not running: %v", s.unit, err)
+		return map[uint64]*cgroup.ContainerInfo{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	containers, err := cgroup.DiscoverTree(path, s.unit)
+	if errors.Is(err, fs.ErrNotExist) {
+		// The unit stopped since its cgroup was looked up
+		return map[uint64]*cgroup.ContainerInfo{}, nil
+	}
+	return containers, err
+}
+
+func (s *systemdSource) identify(_ context.Context, containers map[uint64]*cgroup.ContainerInfo) map[uint64]kube.ContainerImage {
+	result := make(map[uint64]kube.ContainerImage, len(containers))
+	for cgroupID := range containers {
+		result[cgroupID] = kube.ContainerImage{Ref: s.cfg.ImageRef, Digest: s.cfg.ImageDigest}
+	}
+	return result
+}
+
+// rootfs is always empty: units share the host's root, or have their own
+// RootDirectory= that /proc/<pid>/root already points to.
+func (s *systemdSource) rootfs(*cgroup.ContainerInfo) string { return "" }
+
+// events watches the unit's slice, where its cgroup is created when it
+// starts and removed when it stops.
+func (s *systemdSource) events(ctx context.Context) <-chan struct{} {
+	log := clog.FromContext(ctx)
+	unitPath, err := cgroup.UnitCgroupPath(ctx, s.unit)
+	if err != nil {
+		log.Infof("Not watching for %s to start or stop: %v", s.unit, err)
+		return nil
+	}
+	slice := path.Dir(unitPath)
+	ch, err := cgroup.WatchDir(ctx, filepath.Join("/sys/fs/cgroup", slice))
+	if err != nil {
+		log.Warnf("Not watching for %s to start or stop: %v", s.unit, err)
+		return nil
+	}
+	log.Infof("Watching %s for %s to start or stop", slice, s.unit)
+	return ch
+}
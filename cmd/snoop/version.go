@@ -0,0 +1,27 @@
+//go:build linux
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/imjasonh/snoop/pkg/version"
+)
+
+// runVersion implements `snoop version`, which prints the build's version,
+// commit, build date, Go version, and eBPF object digest as JSON.
+func runVersion(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: snoop version\n\n")
+		fmt.Fprintf(fs.Output(), "Print the version, commit, build date, Go version, and eBPF object digest of this\nbuild as JSON.\n")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return writeJSON("", version.Get())
+}
@@ -5,7 +5,11 @@ go 1.25.5
 require (
 	github.com/chainguard-dev/clog v1.8.0
 	github.com/cilium/ebpf v0.20.0
+	github.com/golang/snappy v1.0.0
 	github.com/prometheus/client_golang v1.23.2
+	go.yaml.in/yaml/v2 v2.4.2
+	golang.org/x/sys v0.37.0
+	google.golang.org/protobuf v1.36.8
 )
 
 require (
@@ -15,7 +19,4 @@ require (
 	github.com/prometheus/client_model v0.6.2 // indirect
 	github.com/prometheus/common v0.66.1 // indirect
 	github.com/prometheus/procfs v0.16.1 // indirect
-	go.yaml.in/yaml/v2 v2.4.2 // indirect
-	golang.org/x/sys v0.37.0 // indirect
-	google.golang.org/protobuf v1.36.8 // indirect
 )
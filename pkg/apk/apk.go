@@ -0,0 +1,414 @@
+// Package apk reads the installed package database of Alpine and Wolfi
+// images for package attribution.
+package apk
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+	"github.com/imjasonh/snoop/pkg/registry"
+)
+
+const (
+	// InstalledPath is the apk installed database, relative to the image root.
+	InstalledPath = "/lib/apk/db/installed"
+
+	// UsrInstalledPath is where Wolfi and other merged-/usr images keep the
+	// installed database.
+	UsrInstalledPath = "/usr/lib/apk/db/installed"
+
+	// WorldPath lists the packages that were explicitly requested, as
+	// opposed to pulled in as dependencies.
+	WorldPath = "/etc/apk/world"
+
+	// Manager identifies packages loaded by this package.
+	Manager = "apk"
+)
+
+// Entry is one package stanza from the apk installed database.
+type Entry struct {
+	Name     string
+	Version  string
+	Arch     string
+	Origin   string   // Source package this was built from (o:)
+	License  string   // License expression (L:)
+	Size     int64    // Installed size in bytes (I:)
+	Provides []string // Names this package provides (p:), without versions
+	Priority int      // Provider priority (k:), preferred when several packages provide a name
+	Depends  []string // Dependencies (D:), without versions; conflicts are omitted
+	Files    []string // Absolute paths of owned files (R: entries under F:)
+
+	// Checksums maps owned files to their recorded content digest in
+	// "<algorithm>:<hex>" form, from the Z: line following each R: line.
+	Checksums map[string]string
+}
+
+// ParseInstalled parses the apk installed database, a sequence of stanzas
+// of single-letter "K:value" lines separated by blank lines.
+func ParseInstalled(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var cur Entry
+	var dir string
+	flush := func() {
+		if cur.Name != "" {
+			entries = append(entries, cur)
+		}
+		cur = Entry{}
+		dir = ""
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "P":
+			cur.Name = value
+		case "V":
+			cur.Version = value
+		case "A":
+			cur.Arch = value
+		case "o":
+			cur.Origin = value
+		case "L":
+			cur.License = value
+		case "k":
+			cur.Priority, _ = strconv.Atoi(value)
+		case "I":
+			cur.Size, _ = strconv.ParseInt(value, 10, 64)
+		case "p":
+			for _, p := range strings.Fields(value) {
+				cur.Provides = append(cur.Provides, dependencyName(p))
+			}
+		case "D":
+			for _, d := range strings.Fields(value) {
+				if !strings.HasPrefix(d, "!") {
+					cur.Depends = append(cur.Depends, dependencyName(d))
+				}
+			}
+		case "F":
+			dir = value
+		case "R":
+			cur.Files = append(cur.Files, path.Join("/", dir, value))
+		case "Z":
+			if len(cur.Files) == 0 {
+				continue
+			}
+			if digest, ok := parseChecksum(value); ok {
+				if cur.Checksums == nil {
+					cur.Checksums = make(map[string]string)
+				}
+				cur.Checksums[cur.Files[len(cur.Files)-1]] = digest
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading apk database: %w", err)
+	}
+	flush()
+	return entries, nil
+}
+
+// ParseWorld parses /etc/apk/world and returns the requested package names
+// with version and repository constraints removed. Conflicts (!name) are skipped.
+func ParseWorld(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading apk world: %w", err)
+	}
+	var names []string
+	for _, dep := range strings.Fields(string(data)) {
+		if strings.HasPrefix(dep, "!") {
+			continue
+		}
+		if name := dependencyName(dep); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// parseChecksum converts an apk checksum ("Q1" + base64 SHA-1, or "Q2" +
+// base64 SHA-256) to "<algorithm>:<hex>" form.
+func parseChecksum(value string) (string, bool) {
+	var algo string
+	switch {
+	case strings.HasPrefix(value, "Q1"):
+		algo = "sha1"
+	case strings.HasPrefix(value, "Q2"):
+		algo = "sha256"
+	default:
+		return "", false
+	}
+	sum, err := base64.StdEncoding.DecodeString(value[2:])
+	if err != nil {
+		return "", false
+	}
+	return algo + ":" + hex.EncodeToString(sum), true
+}
+
+// dependencyName strips the version or repository constraint from a
+// dependency, e.g. "curl>=8.0" or "so:libc.musl-x86_64.so.1=1".
+func dependencyName(dep string) string {
+	if i := strings.IndexAny(dep, "=<>~@"); i >= 0 {
+		return dep[:i]
+	}
+	return dep
+}
+
+// DefaultDatabasePaths are the installed database locations Load checks.
+var DefaultDatabasePaths = []string{InstalledPath, UsrInstalledPath}
+
+// Load reads installed packages from the apk database at any of the
+// DefaultDatabasePaths under root. It implements packages.Loader.
+func Load(root string) ([]*packages.Package, error) {
+	return Loader(DefaultDatabasePaths...)(root)
+}
+
+// Loader returns a packages.Loader that reads every apk database found at
+// the given paths and combines them, for images with more than one root
+// (e.g. a chroot or vendored rootfs at /opt/rootfs/lib/apk/db/installed).
+// Files in a database under such a prefix are attributed with the prefix,
+// and its world file is looked for under the same prefix. Paths that are the
+// same file (e.g. when /lib links to /usr/lib) are read once. With no
+// paths, DefaultDatabasePaths are used.
+//
+// Packages named in a world file (directly or through something they
+// provide, e.g. cmd:bash) are marked as explicitly installed and the rest as
+// dependencies. Dependencies are resolved to the names of the installed
+// packages that satisfy them.
+func Loader(paths ...string) packages.Loader {
+	if len(paths) == 0 {
+		paths = DefaultDatabasePaths
+	}
+	return func(root string) ([]*packages.Package, error) {
+		var pkgs []*packages.Package
+		var seen []os.FileInfo
+		found := false
+	paths:
+		for _, dbPath := range paths {
+			info, err := os.Stat(filepath.Join(root, dbPath))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("opening apk database: %w", err)
+			}
+			for _, s := range seen {
+				if os.SameFile(s, info) {
+					continue paths
+				}
+			}
+			seen = append(seen, info)
+
+			p, err := loadDatabase(root, dbPath)
+			if err != nil {
+				return nil, err
+			}
+			found = true
+			pkgs = append(pkgs, p...)
+		}
+		if !found {
+			return nil, fmt.Errorf("no apk database found at %s: %w", strings.Join(paths, ", "), fs.ErrNotExist)
+		}
+		return pkgs, nil
+	}
+}
+
+// databasePrefix returns the root a database at dbPath describes, e.g.
+// "/opt/rootfs" for /opt/rootfs/lib/apk/db/installed. Databases at
+// nonstandard locations describe the image root.
+func databasePrefix(dbPath string) string {
+	dbPath = path.Clean("/" + dbPath)
+	for _, suffix := range []string{UsrInstalledPath, InstalledPath} {
+		if prefix, ok := strings.CutSuffix(dbPath, suffix); ok {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// loadDatabase reads the apk database at dbPath under root.
+func loadDatabase(root, dbPath string) ([]*packages.Package, error) {
+	f, err := os.Open(filepath.Join(root, dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("opening apk database: %w", err)
+	}
+	defer f.Close()
+	prefix := databasePrefix(dbPath)
+
+	entries, err := ParseInstalled(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a world file there's no way to tell why a package is
+	// installed. World entries naming something provided (e.g. cmd:bash)
+	// mark the package apk chose to provide it.
+	providers := providerIndex(entries)
+	var explicit map[string]bool
+	if wf, err := os.Open(filepath.Join(root, prefix, WorldPath)); err == nil {
+		world, err := ParseWorld(wf)
+		wf.Close()
+		if err != nil {
+			return nil, err
+		}
+		explicit = make(map[string]bool, len(world))
+		for _, name := range world {
+			if p, ok := providers[name]; ok {
+				explicit[p] = true
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("opening apk world: %w", err)
+	}
+
+	pkgs := make([]*packages.Package, 0, len(entries))
+	for _, e := range entries {
+		pkg := &packages.Package{
+			Name:      e.Name,
+			Version:   e.Version,
+			Manager:   Manager,
+			Origin:    e.Origin,
+			License:   e.License,
+			Arch:      e.Arch,
+			Size:      e.Size,
+			Files:     e.Files,
+			Provides:  e.Provides,
+			Depends:   resolveDepends(e, providers),
+			Checksums: e.Checksums,
+		}
+		if prefix != "" {
+			pkg.Files = make([]string, len(e.Files))
+			for i, f := range e.Files {
+				pkg.Files[i] = prefix + f
+			}
+			pkg.Checksums = make(map[string]string, len(e.Checksums))
+			for f, sum := range e.Checksums {
+				pkg.Checksums[prefix+f] = sum
+			}
+		}
+		if explicit != nil {
+			pkg.InstallReason = packages.Dependency
+			if explicit[e.Name] {
+				pkg.InstallReason = packages.Explicit
+			}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// providerIndex maps everything that can satisfy a dependency (package
+// names, provided names like so:libc.musl-x86_64.so.1, and owned file
+// paths like /bin/sh) to the name of the package providing it. When several
+// packages provide a name, the one with the highest provider priority wins,
+// as in apk, with ties going to the first name alphabetically.
+func providerIndex(entries []Entry) map[string]string {
+	providers := make(map[string]string)
+	for _, e := range entries {
+		for _, f := range e.Files {
+			providers[f] = e.Name
+		}
+	}
+	chosen := make(map[string]Entry)
+	for _, e := range entries {
+		for _, p := range e.Provides {
+			cur, ok := chosen[p]
+			if !ok || e.Priority > cur.Priority || (e.Priority == cur.Priority && e.Name < cur.Name) {
+				chosen[p] = e
+			}
+		}
+	}
+	for p, e := range chosen {
+		providers[p] = e.Name
+	}
+	// Real package names take precedence over provides
+	for _, e := range entries {
+		providers[e.Name] = e.Name
+	}
+	return providers
+}
+
+// resolveDepends returns the sorted, de-duplicated names of the installed
+// packages satisfying e's dependencies. Unsatisfied dependencies are dropped.
+func resolveDepends(e Entry, providers map[string]string) []string {
+	seen := make(map[string]bool)
+	var deps []string
+	for _, d := range e.Depends {
+		name, ok := providers[d]
+		if !ok || name == e.Name || seen[name] {
+			continue
+		}
+		seen[name] = true
+		deps = append(deps, name)
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// ImageLoader returns a function that reads the apk databases at the given
+// paths (DefaultDatabasePaths if none) from an image in its registry, for
+// containers whose root filesystem can't be read. Results are cached by
+// image, so containers running the same image fetch it once.
+func ImageLoader(client *registry.Client, paths ...string) func(ctx context.Context, ref, digest string) ([]*packages.Package, error) {
+	if len(paths) == 0 {
+		paths = DefaultDatabasePaths
+	}
+	var (
+		mu    sync.Mutex
+		cache = make(map[string][]*packages.Package)
+	)
+	return func(ctx context.Context, ref, digest string) ([]*packages.Package, error) {
+		r, err := registry.ParseReference(ref, digest)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if pkgs, ok := cache[r.String()]; ok {
+			return pkgs, nil
+		}
+
+		dir, err := os.MkdirTemp("", "snoop-apk-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		want := append([]string{}, paths...)
+		for _, p := range paths {
+			want = append(want, path.Join(databasePrefix(p), WorldPath))
+		}
+		if _, err := client.Extract(ctx, r, dir, want...); err != nil {
+			return nil, fmt.Errorf("fetching apk database from %s: %w", r, err)
+		}
+		pkgs, err := Loader(paths...)(dir)
+		if err != nil {
+			return nil, err
+		}
+		cache[r.String()] = pkgs
+		return pkgs, nil
+	}
+}
@@ -0,0 +1,262 @@
+package apk
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+const testInstalled = `C:Q1abc=
+P:musl
+V:1.2.4-r2
+A:x86_64
+o:musl
+L:MIT
+I:651264
+p:so:libc.musl-x86_64.so.1=1
+F:lib
+R:ld-musl-x86_64.so.1
+a:0:0:755
+Z:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAA=
+R:libc.musl-x86_64.so.1
+
+P:bash
+V:5.2.15-r5
+A:x86_64
+D:/bin/sh so:libc.musl-x86_64.so.1
+p:cmd:bash=5.2.15-r5
+F:bin
+R:bash
+F:etc/bash
+R:bashrc
+
+P:ca-certificates-bundle
+V:20230506-r0
+A:x86_64
+F:etc/ssl/certs
+R:ca-certificates.crt
+
+P:alpine-baselayout
+V:3.4.3-r1
+A:x86_64
+`
+
+const testWorld = `alpine-baselayout
+cmd:bash
+ca-certificates-bundle>=20230506
+!busybox-extras
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseInstalled(t *testing.T) {
+	entries, err := ParseInstalled(strings.NewReader(testInstalled))
+	if err != nil {
+		t.Fatalf("ParseInstalled failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+
+	musl := entries[0]
+	if musl.Name != "musl" || musl.Version != "1.2.4-r2" || musl.Arch != "x86_64" || musl.Origin != "musl" || musl.License != "MIT" || musl.Size != 651264 {
+		t.Errorf("musl = %+v", musl)
+	}
+	if want := []string{"/lib/ld-musl-x86_64.so.1", "/lib/libc.musl-x86_64.so.1"}; !slices.Equal(musl.Files, want) {
+		t.Errorf("musl files = %v, want %v", musl.Files, want)
+	}
+	if want := []string{"so:libc.musl-x86_64.so.1"}; !slices.Equal(musl.Provides, want) {
+		t.Errorf("musl provides = %v, want %v", musl.Provides, want)
+	}
+
+	// Z: lines attach to the preceding R: line; Q1 is base64 SHA-1
+	if got, want := musl.Checksums["/lib/ld-musl-x86_64.so.1"], "sha1:"+strings.Repeat("00", 20); got != want {
+		t.Errorf("ld-musl checksum = %q, want %q", got, want)
+	}
+	if _, ok := musl.Checksums["/lib/libc.musl-x86_64.so.1"]; ok {
+		t.Error("libc.musl has no Z: line and should have no checksum")
+	}
+
+	// Files follow the most recent F: line
+	if want := []string{"/bin/bash", "/etc/bash/bashrc"}; !slices.Equal(entries[1].Files, want) {
+		t.Errorf("bash files = %v, want %v", entries[1].Files, want)
+	}
+	if len(entries[3].Files) != 0 {
+		t.Errorf("alpine-baselayout files = %v, want none", entries[3].Files)
+	}
+}
+
+func TestParseWorld(t *testing.T) {
+	got, err := ParseWorld(strings.NewReader(testWorld))
+	if err != nil {
+		t.Fatalf("ParseWorld failed: %v", err)
+	}
+	want := []string{"alpine-baselayout", "cmd:bash", "ca-certificates-bundle"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseWorld = %v, want %v", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, InstalledPath), testInstalled)
+	writeFile(t, filepath.Join(root, WorldPath), testWorld)
+
+	pkgs, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	reasons := make(map[string]string)
+	for _, p := range pkgs {
+		if p.Manager != Manager {
+			t.Errorf("%s manager = %q", p.Name, p.Manager)
+		}
+		reasons[p.Name] = p.InstallReason
+		if p.Name == "musl" && (p.Origin != "musl" || p.License != "MIT" || p.Arch != "x86_64") {
+			t.Errorf("musl metadata = %q, %q, %q", p.Origin, p.License, p.Arch)
+		}
+	}
+	want := map[string]string{
+		"musl":                   packages.Dependency,
+		"bash":                   packages.Explicit, // via cmd:bash
+		"ca-certificates-bundle": packages.Explicit,
+		"alpine-baselayout":      packages.Explicit,
+	}
+	for name, reason := range want {
+		if reasons[name] != reason {
+			t.Errorf("%s install reason = %q, want %q", name, reasons[name], reason)
+		}
+	}
+
+	// Dependencies resolve through file paths and provides to package names
+	deps := make(map[string][]string)
+	for _, p := range pkgs {
+		deps[p.Name] = p.Depends
+	}
+	if want := []string{"musl"}; !slices.Equal(deps["bash"], want) {
+		t.Errorf("bash depends = %v, want %v", deps["bash"], want)
+	}
+
+	db := packages.NewDatabase(pkgs)
+	if pkg := db.Lookup("/usr/bin/bash"); pkg == nil || pkg.Name != "bash" {
+		t.Errorf("Lookup(/usr/bin/bash) = %v, want bash", pkg)
+	}
+}
+
+func TestLoadProviderPriority(t *testing.T) {
+	// Both provide cmd:sh; apk picks the higher k: priority
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, InstalledPath), `P:busybox-binsh
+V:1.36.1-r5
+k:100
+p:cmd:sh
+
+P:dash-binsh
+V:0.5.12-r1
+k:60
+p:cmd:sh
+
+P:app
+V:1.0
+D:cmd:sh
+`)
+	writeFile(t, filepath.Join(root, WorldPath), "app\ncmd:sh\n")
+
+	pkgs, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	byName := make(map[string]*packages.Package)
+	for _, p := range pkgs {
+		byName[p.Name] = p
+	}
+	if want := []string{"busybox-binsh"}; !slices.Equal(byName["app"].Depends, want) {
+		t.Errorf("app depends = %v, want %v", byName["app"].Depends, want)
+	}
+	if want := []string{"cmd:sh"}; !slices.Equal(byName["dash-binsh"].Provides, want) {
+		t.Errorf("dash-binsh provides = %v, want %v", byName["dash-binsh"].Provides, want)
+	}
+	if got := byName["busybox-binsh"].InstallReason; got != packages.Explicit {
+		t.Errorf("busybox-binsh install reason = %q, want explicit", got)
+	}
+	if got := byName["dash-binsh"].InstallReason; got != packages.Dependency {
+		t.Errorf("dash-binsh install reason = %q, want dependency", got)
+	}
+}
+
+func TestLoadWithoutWorld(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, InstalledPath), testInstalled)
+
+	pkgs, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, p := range pkgs {
+		if p.InstallReason != "" {
+			t.Errorf("%s install reason = %q, want empty without a world file", p.Name, p.InstallReason)
+		}
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	_, err := Load(t.TempDir())
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestLoadUsrMerged(t *testing.T) {
+	// Wolfi keeps the database under /usr, and /lib may link to /usr/lib
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, UsrInstalledPath), testInstalled)
+	if err := os.Symlink("usr/lib", filepath.Join(root, "lib")); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	// Read once despite being reachable through both default paths
+	if len(pkgs) != 4 {
+		t.Errorf("got %d packages, want 4", len(pkgs))
+	}
+}
+
+func TestLoaderMultipleRoots(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, InstalledPath), testInstalled)
+	writeFile(t, filepath.Join(root, "/opt/rootfs", InstalledPath), "P:jq\nV:1.7-r0\nF:usr/bin\nR:jq\n")
+	writeFile(t, filepath.Join(root, "/opt/rootfs", WorldPath), "jq\n")
+
+	pkgs, err := Loader(InstalledPath, "/opt/rootfs"+InstalledPath, "/missing"+InstalledPath)(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	db := packages.NewDatabase(pkgs)
+	jq := db.Lookup("/opt/rootfs/usr/bin/jq")
+	if jq == nil || jq.Name != "jq" {
+		t.Fatalf("Lookup(/opt/rootfs/usr/bin/jq) = %v, want jq", jq)
+	}
+	if jq.InstallReason != packages.Explicit {
+		t.Errorf("jq install reason = %q, want explicit from the chroot's world file", jq.InstallReason)
+	}
+	if pkg := db.Lookup("/bin/bash"); pkg == nil || pkg.Name != "bash" {
+		t.Errorf("Lookup(/bin/bash) = %v, want bash", pkg)
+	}
+}
@@ -17,6 +17,8 @@ type ContainerInfo struct {
 	CgroupID   uint64
 	CgroupPath string
 	Name       string // Short container ID or name
+	ID         string // Full runtime container ID, if the cgroup name contains one
+	PodUID     string // UID of the container's pod, in node-wide discovery
 }
 
 // Discovery finds cgroup IDs to trace
@@ -51,10 +53,9 @@ func (d *SelfExcludingDiscovery) Discover(ctx context.Context) ([]uint64, error)
 // excluding snoop's own container.
 // Returns a map of cgroup_id -> ContainerInfo.
 func DiscoverAllExceptSelf() (map[uint64]*ContainerInfo, error) {
-	// Get our own cgroup path and ID
-	selfCgroupPath, err := GetSelfCgroupPath()
+	podCgroupPath, err := PodCgroupPath()
 	if err != nil {
-		return nil, fmt.Errorf("getting self cgroup path: %w", err)
+		return nil, err
 	}
 
 	selfCgroupID, err := GetSelfCgroupID()
@@ -62,42 +63,13 @@ func DiscoverAllExceptSelf() (map[uint64]*ContainerInfo, error) {
 		return nil, fmt.Errorf("getting self cgroup ID: %w", err)
 	}
 
-	// Get the pod cgroup (parent directory)
-	podCgroupPath := filepath.Dir(selfCgroupPath)
-
-	// Special case: if we're in root cgroup ("/"), we need to find the actual pod cgroup
-	// This happens in some container runtimes (e.g., KinD) where /proc/self/cgroup shows 0::/
-	// In this case, look for POD_UID environment variable to find the pod cgroup
-	if podCgroupPath == "/" || podCgroupPath == "." {
-		podUID := os.Getenv("POD_UID")
-		if podUID != "" {
-			// Convert pod UID format: aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee
-			// to cgroup format: podaaaaaaaa_bbbb_cccc_dddd_eeeeeeeeeeee
-			podUIDCgroup := "pod" + strings.ReplaceAll(podUID, "-", "_")
-
-			// Search for the pod cgroup directory
-			foundPath := ""
-			filepath.Walk("/sys/fs/cgroup", func(path string, info os.FileInfo, err error) error {
-				if err != nil || foundPath != "" {
-					return filepath.SkipDir
-				}
-				if info.IsDir() && strings.Contains(filepath.Base(path), podUIDCgroup) {
-					foundPath = path
-					return filepath.SkipDir
-				}
-				// Limit search depth to avoid scanning entire filesystem
-				if strings.Count(path, "/") > 8 {
-					return filepath.SkipDir
-				}
-				return nil
-			})
-
-			if foundPath != "" {
-				podCgroupPath = strings.TrimPrefix(foundPath, "/sys/fs/cgroup")
-			}
-		}
-	}
+	return discoverIn(podCgroupPath, selfCgroupID)
+}
 
+// discoverIn returns the containers whose cgroups are children of
+// podCgroupPath, relative to /sys/fs/cgroup, except the one with cgroup ID
+// selfCgroupID.
+func discoverIn(podCgroupPath string, selfCgroupID uint64) (map[uint64]*ContainerInfo, error) {
 	fullPodPath := filepath.Join("/sys/fs/cgroup", podCgroupPath)
 
 	// Read all subdirectories in the pod cgroup
@@ -141,18 +113,112 @@ func DiscoverAllExceptSelf() (map[uint64]*ContainerInfo, error) {
 			CgroupID:   cgroupID,
 			CgroupPath: containerCgroupPath,
 			Name:       shortName,
+			ID:         extractContainerID(name),
 		}
 	}
 
 	return containers, nil
 }
 
+// PodCgroupPath returns the cgroup path of snoop's pod, the parent of its
+// own container's cgroup, relative to /sys/fs/cgroup.
+func PodCgroupPath() (string, error) {
+	selfCgroupPath, err := GetSelfCgroupPath()
+	if err != nil {
+		return "", fmt.Errorf("getting self cgroup path: %w", err)
+	}
+
+	// Get the pod cgroup (parent directory)
+	podCgroupPath := filepath.Dir(selfCgroupPath)
+
+	// Special case: if we're in root cgroup ("/"), we need to find the actual pod cgroup
+	// This happens in some container runtimes (e.g., KinD) where /proc/self/cgroup shows 0::/
+	// In this case, look for POD_UID environment variable to find the pod cgroup
+	if podCgroupPath == "/" || podCgroupPath == "." {
+		podUID := os.Getenv("POD_UID")
+		if podUID != "" {
+			// The kubelet names pod cgroups after the QoS class and UID, so
+			// try those paths before searching the hierarchy
+			for _, candidate := range podCgroupCandidates(podUID) {
+				if info, err := os.Stat(filepath.Join("/sys/fs/cgroup", candidate)); err == nil && info.IsDir() {
+					return candidate, nil
+				}
+			}
+		}
+		if podUID != "" {
+			// Convert pod UID format: aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee
+			// to cgroup format: podaaaaaaaa_bbbb_cccc_dddd_eeeeeeeeeeee
+			podUIDCgroup := "pod" + strings.ReplaceAll(podUID, "-", "_")
+
+			// Search for the pod cgroup directory
+			foundPath := ""
+			filepath.Walk("/sys/fs/cgroup", func(path string, info os.FileInfo, err error) error {
+				if err != nil || foundPath != "" {
+					return filepath.SkipDir
+				}
+				if info.IsDir() && strings.Contains(filepath.Base(path), podUIDCgroup) {
+					foundPath = path
+					return filepath.SkipDir
+				}
+				// Limit search depth to avoid scanning entire filesystem
+				if strings.Count(path, "/") > 8 {
+					return filepath.SkipDir
+				}
+				return nil
+			})
+
+			if foundPath != "" {
+				podCgroupPath = strings.TrimPrefix(foundPath, "/sys/fs/cgroup")
+			}
+		}
+	}
+
+	return podCgroupPath, nil
+}
+
+// podCgroupCandidates returns the paths, relative to /sys/fs/cgroup, where
+// the kubelet may have created the cgroup of the pod with the given UID: one
+// per QoS class, for both the systemd and cgroupfs cgroup drivers.
+// Guaranteed pods sit directly below kubepods.
+func podCgroupCandidates(uid string) []string {
+	underscored := strings.ReplaceAll(uid, "-", "_")
+	var paths []string
+	for _, qos := range []string{"burstable", "besteffort", ""} {
+		if qos == "" {
+			paths = append(paths,
+				"/kubepods.slice/kubepods-pod"+underscored+".slice",
+				"/kubepods/pod"+uid)
+			continue
+		}
+		paths = append(paths,
+			"/kubepods.slice/kubepods-"+qos+".slice/kubepods-"+qos+"-pod"+underscored+".slice",
+			"/kubepods/"+qos+"/pod"+uid)
+	}
+	return paths
+}
+
 // extractContainerName extracts a readable name from a cgroup directory name.
 // Handles various container runtime formats:
 // - cri-containerd-<id>.scope -> <id[:12]>
 // - docker-<id>.scope -> <id[:12]>
 // - <id> -> <id[:12]>
 func extractContainerName(dirName string) string {
+	name := extractContainerID(dirName)
+
+	// Truncate long IDs to 12 characters (like docker ps does)
+	if len(name) > 12 {
+		name = name[:12]
+	}
+
+	return name
+}
+
+// extractContainerID strips runtime-specific prefixes and suffixes from a
+// cgroup directory name, returning the full container ID:
+// - cri-containerd-<id>.scope -> <id>
+// - docker-<id>.scope -> <id>
+// - crio-<id>.scope -> <id>
+func extractContainerID(dirName string) string {
 	// Remove common suffixes
 	name := strings.TrimSuffix(dirName, ".scope")
 	name = strings.TrimSuffix(name, ".slice")
@@ -165,22 +231,26 @@ func extractContainerName(dirName string) string {
 	} else if strings.HasPrefix(name, "crio-") {
 		name = strings.TrimPrefix(name, "crio-")
 	}
-
-	// Truncate long IDs to 12 characters (like docker ps does)
-	if len(name) > 12 {
-		name = name[:12]
-	}
-
 	return name
 }
 
 // GetSelfCgroupPath returns the cgroup path of the current process
 // relative to /sys/fs/cgroup (e.g., "/system.slice/docker-abc123.scope")
 func GetSelfCgroupPath() (string, error) {
-	// Read /proc/self/cgroup to get cgroup path
-	data, err := os.ReadFile("/proc/self/cgroup")
+	return readCgroupPath("/proc/self/cgroup")
+}
+
+// GetCgroupPathByPID returns the cgroup path of a process relative to
+// /sys/fs/cgroup. The process must be visible in snoop's PID namespace.
+func GetCgroupPathByPID(pid int) (string, error) {
+	return readCgroupPath(filepath.Join(Proc.Dir(uint32(pid)), "cgroup"))
+}
+
+// readCgroupPath reads the cgroup v2 path from a /proc/<pid>/cgroup file.
+func readCgroupPath(file string) (string, error) {
+	data, err := os.ReadFile(file)
 	if err != nil {
-		return "", fmt.Errorf("reading /proc/self/cgroup: %w", err)
+		return "", fmt.Errorf("reading %s: %w", file, err)
 	}
 
 	// Parse cgroup v2 format: 0::/path/to/cgroup
@@ -192,7 +262,7 @@ func GetSelfCgroupPath() (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("cgroup v2 not found in /proc/self/cgroup")
+	return "", fmt.Errorf("cgroup v2 not found in %s", file)
 }
 
 // GetSelfCgroupID returns the cgroup ID of the current process
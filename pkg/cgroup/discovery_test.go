@@ -217,6 +217,22 @@ func TestExtractContainerName(t *testing.T) {
 	}
 }
 
+func TestExtractContainerID(t *testing.T) {
+	for _, tt := range []struct {
+		dirName string
+		wantID  string
+	}{
+		{"cri-containerd-abc123def456ghi789.scope", "abc123def456ghi789"},
+		{"docker-1234567890abcdef.scope", "1234567890abcdef"},
+		{"crio-fedcba0987654321.scope", "fedcba0987654321"},
+		{"abc123", "abc123"},
+	} {
+		if got := extractContainerID(tt.dirName); got != tt.wantID {
+			t.Errorf("extractContainerID(%q) = %q, want %q", tt.dirName, got, tt.wantID)
+		}
+	}
+}
+
 func TestDiscoverAllExceptSelf(t *testing.T) {
 	// This test requires a Linux system with cgroup v2
 	// Skip if we can't get our own cgroup path
@@ -254,3 +270,18 @@ func TestDiscoverAllExceptSelf(t *testing.T) {
 		t.Logf("Discovered container: %s (cgroup_id=%d, path=%s)", info.Name, cgroupID, info.CgroupPath)
 	}
 }
+
+func TestPodCgroupCandidates(t *testing.T) {
+	uid := "0c1d2e3f-1111-2222-3333-444455556666"
+	candidates := podCgroupCandidates(uid)
+	if len(candidates) != 6 {
+		t.Errorf("podCgroupCandidates returned %d paths, want 6 (3 QoS classes x 2 drivers)", len(candidates))
+	}
+	// Every candidate must be recognized as the pod's cgroup by node discovery
+	for _, c := range candidates {
+		m := podDirPattern.FindStringSubmatch(filepath.Base(c))
+		if m == nil || strings.ReplaceAll(m[1], "_", "-") != uid {
+			t.Errorf("candidate %s doesn't name pod %s", c, uid)
+		}
+	}
+}
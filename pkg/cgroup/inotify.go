@@ -0,0 +1,71 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"github.com/chainguard-dev/clog"
+	"golang.org/x/sys/unix"
+)
+
+// WatchDir watches dir for subdirectories being created or removed, such as
+// container cgroups appearing in the pod cgroup, and signals the returned
+// channel when they are. Signals are coalesced while a rescan is pending.
+// Watching stops when ctx is done.
+func WatchDir(ctx context.Context, dir string) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("initializing inotify: %w", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, unix.IN_CREATE|unix.IN_DELETE|unix.IN_ONLYDIR); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("watching %s: %w", dir, err)
+	}
+	// A non-blocking descriptor is registered with the runtime poller, so
+	// closing the file unblocks the pending read
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	trigger := make(chan struct{}, 1)
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, os.ErrClosed) {
+					clog.FromContext(ctx).Warnf("Reading inotify events for %s: %v", dir, err)
+				}
+				return
+			}
+			if !dirChanged(buf[:n]) {
+				continue
+			}
+			select {
+			case trigger <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return trigger, nil
+}
+
+// dirChanged reports whether any of the inotify events in buf is for a
+// subdirectory.
+func dirChanged(buf []byte) bool {
+	for len(buf) >= unix.SizeofInotifyEvent {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+		if ev.Mask&unix.IN_ISDIR != 0 {
+			return true
+		}
+		buf = buf[min(len(buf), unix.SizeofInotifyEvent+int(ev.Len)):]
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := WatchDir(ctx, dir)
+	if err != nil {
+		t.Fatalf("WatchDir failed: %v", err)
+	}
+
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no signal after %s", what)
+		}
+	}
+
+	// Files are ignored; only subdirectories signal
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctr := filepath.Join(dir, "cri-containerd-abc.scope")
+	if err := os.Mkdir(ctr, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wait("mkdir")
+	select {
+	case <-ch:
+		t.Fatal("unexpected second signal")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.Remove(ctr); err != nil {
+		t.Fatal(err)
+	}
+	wait("rmdir")
+}
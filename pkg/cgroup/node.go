@@ -0,0 +1,82 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// podDirPattern matches a pod cgroup directory name and captures the pod
+// UID: "pod<uid>" with the cgroupfs driver and
+// "kubepods-<qos>-pod<uid>.slice" with the systemd driver, which replaces
+// the UID's dashes with underscores.
+var podDirPattern = regexp.MustCompile(`(?:^|-)pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?$`)
+
+// maxPodDepth bounds how deep below the cgroup root pod cgroups are
+// searched for; kubelet puts them at most three levels down
+// (kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod<uid>.slice).
+const maxPodDepth = 4
+
+// DiscoverNode finds the containers of every pod on the node, excluding
+// snoop's own container. Each container's PodUID is set from its pod's
+// cgroup. It needs the host's cgroup hierarchy, so snoop's container must
+// run in the host cgroup namespace.
+// Returns a map of cgroup_id -> ContainerInfo.
+func DiscoverNode() (map[uint64]*ContainerInfo, error) {
+	selfCgroupID, err := GetSelfCgroupID()
+	if err != nil {
+		return nil, fmt.Errorf("getting self cgroup ID: %w", err)
+	}
+	pods, err := findPodCgroups("/sys/fs/cgroup")
+	if err != nil {
+		return nil, err
+	}
+
+	containers := make(map[uint64]*ContainerInfo)
+	for path, uid := range pods {
+		found, err := discoverIn(path, selfCgroupID)
+		if err != nil {
+			// The pod may have been deleted since it was found
+			continue
+		}
+		for cgroupID, info := range found {
+			info.PodUID = uid
+			containers[cgroupID] = info
+		}
+	}
+	return containers, nil
+}
+
+// findPodCgroups returns the pod cgroups below root, mapping their paths
+// relative to root to pod UIDs.
+func findPodCgroups(root string) (map[string]string, error) {
+	pods := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return fs.SkipDir
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+		rel := strings.TrimPrefix(path, root)
+		if m := podDirPattern.FindStringSubmatch(d.Name()); m != nil {
+			pods[rel] = strings.ReplaceAll(m[1], "_", "-")
+			return fs.SkipDir
+		}
+		if strings.Count(rel, "/") >= maxPodDepth || !strings.HasPrefix(strings.TrimPrefix(rel, "/"), "kubepods") {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("searching %s for pod cgroups: %w", root, err)
+	}
+	return pods, nil
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPodCgroups(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		// systemd driver
+		"kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod0c1d2e3f_1111_2222_3333_444455556666.slice/cri-containerd-abc.scope",
+		"kubepods.slice/kubepods-pod8a9b0c1d_aaaa_bbbb_cccc_ddddeeeeffff.slice",
+		// cgroupfs driver
+		"kubepods/besteffort/pod12345678-1234-1234-1234-123456789abc/def",
+		// Not pods
+		"system.slice/containerd.service",
+		"kubepods.slice/kubepods-besteffort.slice",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := findPodCgroups(root)
+	if err != nil {
+		t.Fatalf("findPodCgroups failed: %v", err)
+	}
+	want := map[string]string{
+		"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod0c1d2e3f_1111_2222_3333_444455556666.slice": "0c1d2e3f-1111-2222-3333-444455556666",
+		"/kubepods.slice/kubepods-pod8a9b0c1d_aaaa_bbbb_cccc_ddddeeeeffff.slice":                                    "8a9b0c1d-aaaa-bbbb-cccc-ddddeeeeffff",
+		"/kubepods/besteffort/pod12345678-1234-1234-1234-123456789abc":                                              "12345678-1234-1234-1234-123456789abc",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("findPodCgroups = %v, want %v", got, want)
+	}
+}
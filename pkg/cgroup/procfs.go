@@ -0,0 +1,62 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Procfs reads processes' files from a procfs mount, including the
+// filesystems of their containers through /proc/<pid>/root, without
+// entering any namespace or forking.
+type Procfs struct {
+	// Mount is where procfs is mounted.
+	Mount string
+
+	// Attempts bounds how many times ReadFile reads a file, and Delay is
+	// the time between attempts.
+	Attempts int
+	Delay    time.Duration
+}
+
+// Proc reads processes' files from /proc.
+var Proc = &Procfs{Mount: "/proc", Attempts: 3, Delay: 10 * time.Millisecond}
+
+// Dir returns a process's directory, e.g. /proc/<pid>.
+func (fs *Procfs) Dir(pid uint32) string {
+	return filepath.Join(fs.Mount, strconv.FormatUint(uint64(pid), 10))
+}
+
+// Root returns a process's root directory, through which its container's
+// filesystem can be read.
+func (fs *Procfs) Root(pid uint32) string {
+	return filepath.Join(fs.Dir(pid), "root")
+}
+
+// MountInfo returns a process's mountinfo file.
+func (fs *Procfs) MountInfo(pid uint32) string {
+	return filepath.Join(fs.Dir(pid), "mountinfo")
+}
+
+// ReadFile reads name, relative to a process's directory, such as "maps"
+// or "root/etc/os-release". A process that is still being started into its
+// container may not have its root or credentials in place yet, so failed
+// reads are retried, up to Attempts times, for as long as the process
+// exists. The last error is returned.
+func (fs *Procfs) ReadFile(pid uint32, name string) ([]byte, error) {
+	path := filepath.Join(fs.Dir(pid), name)
+	for attempt := 1; ; attempt++ {
+		data, err := os.ReadFile(path)
+		if err == nil || attempt >= fs.Attempts || !fs.exists(pid) {
+			return data, err
+		}
+		time.Sleep(fs.Delay)
+	}
+}
+
+// exists reports whether a process still exists.
+func (fs *Procfs) exists(pid uint32) bool {
+	_, err := os.Stat(fs.Dir(pid))
+	return err == nil
+}
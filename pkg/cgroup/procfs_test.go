@@ -0,0 +1,56 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProcfsPaths(t *testing.T) {
+	fs := &Procfs{Mount: "/proc"}
+	for got, want := range map[string]string{
+		fs.Dir(42):       "/proc/42",
+		fs.Root(42):      "/proc/42/root",
+		fs.MountInfo(42): "/proc/42/mountinfo",
+	} {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestProcfsReadFile(t *testing.T) {
+	mount := t.TempDir()
+	fs := &Procfs{Mount: mount, Attempts: 100, Delay: time.Millisecond}
+	root := filepath.Join(mount, "42", "root")
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file written while reads are being retried is read
+	done := make(chan error)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		done <- os.WriteFile(filepath.Join(root, "etc", "os-release"), []byte("ID=wolfi\n"), 0o644)
+	}()
+	data, err := fs.ReadFile(42, "root/etc/os-release")
+	if werr := <-done; werr != nil {
+		t.Fatal(werr)
+	}
+	if err != nil || string(data) != "ID=wolfi\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+
+	// Reads through a process that has exited aren't retried
+	fs.Delay = time.Hour
+	if _, err := fs.ReadFile(43, "maps"); !os.IsNotExist(err) {
+		t.Errorf("ReadFile of an exited process = %v, want not exist", err)
+	}
+
+	// Nor are reads beyond Attempts
+	fs.Attempts = 1
+	if _, err := fs.ReadFile(42, "root/missing"); !os.IsNotExist(err) {
+		t.Errorf("ReadFile of a missing file = %v, want not exist", err)
+	}
+}
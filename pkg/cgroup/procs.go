@@ -0,0 +1,46 @@
+package cgroup
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Processes returns the PIDs of the processes in the cgroup at path,
+// relative to /sys/fs/cgroup, and in the cgroups below it.
+func Processes(path string) ([]int, error) {
+	return processesIn("/sys/fs/cgroup", path)
+}
+
+func processesIn(root, path string) ([]int, error) {
+	dir := filepath.Join(root, path)
+	var pids []int
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// A cgroup below dir removed during the walk has no processes
+			// left
+			if p != dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != "cgroup.procs" {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if pid, err := strconv.Atoi(scanner.Text()); err == nil {
+				pids = append(pids, pid)
+			}
+		}
+		return scanner.Err()
+	})
+	return pids, err
+}
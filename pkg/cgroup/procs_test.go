@@ -0,0 +1,38 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestProcesses(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pod/app/cgroup.procs", "10\n11\n")
+	write("pod/app/worker/cgroup.procs", "12\n")
+	write("pod/app/cgroup.threads", "10\n11\n13\n")
+	write("pod/sidecar/cgroup.procs", "20\n")
+
+	pids, err := processesIn(root, "/pod/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(pids)
+	if want := []int{10, 11, 12}; !slices.Equal(pids, want) {
+		t.Errorf("processesIn(/pod/app) = %v, want %v", pids, want)
+	}
+
+	if _, err := processesIn(root, "/pod/missing"); err == nil {
+		t.Error("processesIn(/pod/missing) succeeded, want error")
+	}
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// systemctlTimeout bounds how long asking systemd for a unit's cgroup may
+// take.
+const systemctlTimeout = 5 * time.Second
+
+// UnitName returns a systemd unit name with the ".service" suffix added if
+// it has no unit type suffix, as systemctl does.
+func UnitName(unit string) string {
+	for _, suffix := range []string{".service", ".scope", ".slice", ".socket", ".mount", ".swap", ".timer"} {
+		if strings.HasSuffix(unit, suffix) {
+			return unit
+		}
+	}
+	return unit + ".service"
+}
+
+// UnitCgroupPath returns the cgroup of a systemd unit relative to
+// /sys/fs/cgroup. It asks systemctl, and if that isn't available (e.g. in
+// a container without the host's systemd), searches the well-known slices
+// for a directory named after the unit. It returns an error wrapping
+// fs.ErrNotExist if the unit isn't running.
+func UnitCgroupPath(ctx context.Context, unit string) (string, error) {
+	unit = UnitName(unit)
+	ctx, cancel := context.WithTimeout(ctx, systemctlTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "systemctl", "show", "--property=ControlGroup", "--value", unit).Output()
+	if err == nil {
+		path := strings.TrimSpace(string(out))
+		if path == "" {
+			return "", fmt.Errorf("unit %s has no cgroup (not running?): %w", unit, fs.ErrNotExist)
+		}
+		return path, nil
+	}
+	return findUnitCgroup("/sys/fs/cgroup", unit)
+}
+
+// findUnitCgroup searches the slices below root for a cgroup named unit,
+// returning its path relative to root.
+func findUnitCgroup(root, unit string) (string, error) {
+	found := ""
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || found != "" {
+			return fs.SkipDir
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && d.Name() == unit {
+			found = strings.TrimPrefix(path, root)
+			return fs.SkipAll
+		}
+		// Units live in slices; don't descend into other units' cgroups
+		if path != root && !strings.HasSuffix(d.Name(), ".slice") {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if found == "" {
+		return "", fmt.Errorf("no cgroup found for unit %s (not running?): %w", unit, fs.ErrNotExist)
+	}
+	return found, nil
+}
+
+// DiscoverTree returns the cgroup at path, relative to /sys/fs/cgroup, and
+// every cgroup below it, such as those of a unit that delegates its
+// subtree. The cgroup at path is named name, and those below it
+// "<name>/<subpath>".
+// Returns a map of cgroup_id -> ContainerInfo.
+func DiscoverTree(path, name string) (map[uint64]*ContainerInfo, error) {
+	root := filepath.Join("/sys/fs/cgroup", path)
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	containers := make(map[uint64]*ContainerInfo)
+	err := filepath.WalkDir(root, func(full string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if full == root {
+				return err
+			}
+			// The cgroup was removed while walking
+			return fs.SkipDir
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel := strings.TrimPrefix(full, root)
+		cgroupPath := path + rel
+		id, err := GetCgroupIDByPath(cgroupPath)
+		if err != nil {
+			return nil
+		}
+		containers[id] = &ContainerInfo{
+			CgroupID:   id,
+			CgroupPath: cgroupPath,
+			Name:       name + rel,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnitName(t *testing.T) {
+	for in, want := range map[string]string{
+		"nginx":           "nginx.service",
+		"nginx.service":   "nginx.service",
+		"session-3.scope": "session-3.scope",
+	} {
+		if got := UnitName(in); got != want {
+			t.Errorf("UnitName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFindUnitCgroup(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"system.slice/nginx.service",
+		"system.slice/docker.service/nginx.service", // Not a unit, inside another unit
+		"user.slice/user-1000.slice/user@1000.service/app.slice/editor.service",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := findUnitCgroup(root, "nginx.service"); err != nil || got != "/system.slice/nginx.service" {
+		t.Errorf("findUnitCgroup(nginx) = %q, %v", got, err)
+	}
+	if _, err := findUnitCgroup(root, "editor.service"); !errors.Is(err, fs.ErrNotExist) {
+		// user@1000.service isn't a slice, so its subtree isn't searched
+		t.Errorf("findUnitCgroup(editor) error = %v, want not found", err)
+	}
+	if _, err := findUnitCgroup(root, "missing.service"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("findUnitCgroup(missing) error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CreateTransient creates a cgroup named name directly below the root of
+// the cgroup v2 hierarchy, for tracing processes that aren't in a container.
+// It returns the cgroup's path relative to /sys/fs/cgroup and its ID.
+// The root cgroup is used because processes may only be moved into leaf
+// cgroups of a subtree with controllers enabled, which the root is exempt
+// from. It requires write access to /sys/fs/cgroup, i.e. root.
+func CreateTransient(name string) (string, uint64, error) {
+	path := "/" + name
+	if err := os.Mkdir(filepath.Join("/sys/fs/cgroup", path), 0o755); err != nil {
+		return "", 0, fmt.Errorf("creating cgroup %s: %w", path, err)
+	}
+	id, err := GetCgroupIDByPath(path)
+	if err != nil {
+		RemoveTransient(path)
+		return "", 0, err
+	}
+	return path, id, nil
+}
+
+// RemoveTransient removes a cgroup created by CreateTransient. It fails
+// while processes remain in it.
+func RemoveTransient(path string) error {
+	return os.Remove(filepath.Join("/sys/fs/cgroup", path))
+}
+
+// MoveProcess moves a process into the cgroup at path, relative to
+// /sys/fs/cgroup. Only the process itself moves; processes it starts later
+// inherit the cgroup, but existing children stay where they are.
+func MoveProcess(path string, pid int) error {
+	procs := filepath.Join("/sys/fs/cgroup", path, "cgroup.procs")
+	if err := os.WriteFile(procs, []byte(strconv.Itoa(pid)), 0); err != nil {
+		return fmt.Errorf("moving process %d to cgroup %s: %w", pid, path, err)
+	}
+	return nil
+}
+
+// Descendants returns the PIDs of the running descendants of pid, found
+// through the parent PIDs in /proc/<pid>/stat.
+func Descendants(pid int) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int][]int)
+	for _, e := range entries {
+		p, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", e.Name(), "stat"))
+		if err != nil {
+			// The process exited since the directory was listed
+			continue
+		}
+		if ppid, ok := parentPID(data); ok {
+			children[ppid] = append(children[ppid], p)
+		}
+	}
+
+	var result []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		result = append(result, p)
+		queue = append(queue, children[p]...)
+	}
+	return result, nil
+}
+
+// parentPID extracts the parent PID from the contents of /proc/<pid>/stat:
+// "pid (comm) state ppid ...", where comm may itself contain spaces and
+// parentheses.
+func parentPID(stat []byte) (int, bool) {
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	return ppid, err == nil
+}
@@ -0,0 +1,46 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func TestDescendants(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("starting sleep: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	got, err := Descendants(os.Getpid())
+	if err != nil {
+		t.Fatalf("Descendants failed: %v", err)
+	}
+	if !slices.Contains(got, cmd.Process.Pid) {
+		t.Errorf("Descendants(self) = %v, missing child %d", got, cmd.Process.Pid)
+	}
+}
+
+func TestParentPID(t *testing.T) {
+	for _, tt := range []struct {
+		stat string
+		want int
+		ok   bool
+	}{
+		{stat: "42 (sleep) S 7 42 42 0 -1", want: 7, ok: true},
+		{stat: "42 (my (weird) cmd) R 1 42 42", want: 1, ok: true},
+		{stat: "garbage", ok: false},
+	} {
+		got, ok := parentPID([]byte(tt.stat))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parentPID(%q) = %d, %v, want %d, %v", tt.stat, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// Changes describes containers that appeared in or disappeared from the pod
+// since the previous scan.
+type Changes struct {
+	Added   map[uint64]*ContainerInfo
+	Removed []uint64
+}
+
+// Empty reports whether nothing changed.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// Watch rescans the pod's containers every interval (if positive), and
+// whenever trigger (which may be nil) receives, and sends what changed relative to known,
+// which should be the result of the initial discovery. Restarted containers
+// get a new cgroup, so they show up as one removal and one addition. The
+// channel is closed when ctx is done.
+func Watch(ctx context.Context, interval time.Duration, known map[uint64]*ContainerInfo, trigger <-chan struct{}) <-chan Changes {
+	return WatchScan(ctx, interval, known, trigger, DiscoverAllExceptSelf)
+}
+
+// WatchScan is like Watch, but finds containers with scan instead of by
+// listing the pod's cgroup directory.
+func WatchScan(ctx context.Context, interval time.Duration, known map[uint64]*ContainerInfo, trigger <-chan struct{}, scan func() (map[uint64]*ContainerInfo, error)) <-chan Changes {
+	ch := make(chan Changes)
+	current := make(map[uint64]*ContainerInfo, len(known))
+	for id, info := range known {
+		current[id] = info
+	}
+	go func() {
+		defer close(ch)
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			case <-trigger:
+			}
+			found, err := scan()
+			if err != nil {
+				clog.FromContext(ctx).Warnf("Rescanning pod containers: %v", err)
+				continue
+			}
+			changes := diff(current, found)
+			if changes.Empty() {
+				continue
+			}
+			select {
+			case ch <- changes:
+				current = found
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// diff returns the containers in found but not in current, and the cgroup
+// IDs in current but not in found.
+func diff(current, found map[uint64]*ContainerInfo) Changes {
+	var c Changes
+	for id, info := range found {
+		if _, ok := current[id]; !ok {
+			if c.Added == nil {
+				c.Added = make(map[uint64]*ContainerInfo)
+			}
+			c.Added[id] = info
+		}
+	}
+	for id := range current {
+		if _, ok := found[id]; !ok {
+			c.Removed = append(c.Removed, id)
+		}
+	}
+	sort.Slice(c.Removed, func(i, j int) bool { return c.Removed[i] < c.Removed[j] })
+	return c
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	known := map[uint64]*ContainerInfo{
+		1: {CgroupID: 1, Name: "app"},
+		2: {CgroupID: 2, Name: "sidecar"},
+	}
+	// The sidecar restarts into cgroup 3; the next scan is unchanged
+	scans := []map[uint64]*ContainerInfo{
+		{1: known[1], 3: {CgroupID: 3, Name: "sidecar2"}},
+		{1: known[1], 3: {CgroupID: 3, Name: "sidecar2"}},
+		{3: {CgroupID: 3, Name: "sidecar2"}},
+	}
+	scan := func() (map[uint64]*ContainerInfo, error) {
+		if len(scans) == 0 {
+			return map[uint64]*ContainerInfo{}, nil
+		}
+		s := scans[0]
+		scans = scans[1:]
+		return s, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := WatchScan(ctx, time.Millisecond, known, nil, scan)
+
+	first := <-ch
+	if len(first.Added) != 1 || first.Added[3] == nil {
+		t.Errorf("first Added = %v, want cgroup 3", first.Added)
+	}
+	if !slices.Equal(first.Removed, []uint64{2}) {
+		t.Errorf("first Removed = %v, want [2]", first.Removed)
+	}
+
+	second := <-ch
+	if len(second.Added) != 0 || !slices.Equal(second.Removed, []uint64{1}) {
+		t.Errorf("second = %+v, want only cgroup 1 removed", second)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWatchTrigger(t *testing.T) {
+	scan := func() (map[uint64]*ContainerInfo, error) {
+		return map[uint64]*ContainerInfo{1: {CgroupID: 1, Name: "app"}}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger := make(chan struct{})
+	ch := WatchScan(ctx, 0, nil, trigger, scan)
+
+	// Without an interval, only the trigger causes a rescan
+	trigger <- struct{}{}
+	if changes := <-ch; changes.Added[1] == nil {
+		t.Errorf("Added = %v, want cgroup 1", changes.Added)
+	}
+}
@@ -3,48 +3,247 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"path"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+	"github.com/imjasonh/snoop/pkg/sbom"
 )
 
 const (
 	// DefaultMaxUniqueFiles is the default limit for unique files to prevent OOM (~6-8MB of memory)
 	DefaultMaxUniqueFiles = 100000
+
+	// DefaultMaxSampleRate is the default limit on sampling under
+	// backpressure: 1, never sampling, so that reports are complete unless
+	// sampling is asked for
+	DefaultMaxSampleRate = 1
+
+	// DefaultPackagesReload is the default interval for checking whether a
+	// container's package database changed
+	DefaultPackagesReload = 30 * time.Second
+
+	// DefaultHashMaxSize is the default size limit for content hashing (64MB)
+	DefaultHashMaxSize = 64 << 20
+
+	// DefaultHashWorkers is the default number of content hashing workers
+	DefaultHashWorkers = 2
+
+	// DefaultCoverageLargestFiles is the default number of never-accessed
+	// image files listed by coverage analysis
+	DefaultCoverageLargestFiles = 25
+
+	// DefaultDiscoveryInterval is the default interval for rescanning the
+	// pod for containers that started or went away
+	DefaultDiscoveryInterval = 10 * time.Second
+
+	// DefaultDiscoveryAttempts is the default number of times container
+	// discovery is attempted at startup before giving up
+	DefaultDiscoveryAttempts = 3
+
+	// DefaultDiscoveryTimeout is the default time limit for one container
+	// discovery scan
+	DefaultDiscoveryTimeout = 30 * time.Second
+
+	// DefaultImageLookupAttempts is the default number of times the pod
+	// status is read while waiting for it to list every container
+	DefaultImageLookupAttempts = 5
+
+	// DefaultPackageLoadAttempts is the default number of times a
+	// container's package database is looked for
+	DefaultPackageLoadAttempts = 5
+
+	// DefaultPackageLoadRetry is the default minimum time between package
+	// database load attempts
+	DefaultPackageLoadRetry = 2 * time.Second
+
+	// DefaultPackageMetricsTop is the default number of each container's
+	// most accessed packages to export per-package metrics for
+	DefaultPackageMetricsTop = 10
+
+	// DefaultConfigReload is the default interval for checking the config
+	// directory for changes
+	DefaultConfigReload = 10 * time.Second
 )
 
+// InfraContainers are the names of service-mesh and infrastructure
+// containers that aren't traced unless IncludeInfraContainers is set. Their
+// file churn dominates reports and rarely matters for slimming the app.
+var InfraContainers = []string{
+	"istio-proxy",
+	"istio-init",
+	"istio-validation",
+	"linkerd-proxy",
+	"linkerd-init",
+	"envoy",
+	"pause",
+}
+
 // Config holds the configuration for snoop.
 type Config struct {
 	// Output configuration
 	ReportPath     string
+	ReportDir      string // Directory to write one report per pod to (node mode)
+	ReportCompact  bool   // Write the report file without indentation
 	ReportInterval time.Duration
+	ReportURL      string // Optional HTTP endpoint to POST reports to
+	PushgatewayURL string // Optional Prometheus Pushgateway to push metrics to
+	OTLPEndpoint   string // Optional OTLP/HTTP collector endpoint
+	RemoteWriteURL string // Optional Prometheus remote-write endpoint
+	SyslogAddr     string // Optional syslog destination (local, unix://, udp://, tcp://)
+	AuditLog       string // Optional append-only, hash-chained JSONL log of file accesses
+	ReportSocket   string // Optional unix socket to serve the latest report on
+	ReportCRD      bool   // Create or update a FileAccessReport resource per pod
+
+	// Dynamic configuration
+	ConfigDir    string        // Directory of settings files (e.g. a mounted ConfigMap) reloaded while running
+	ConfigReload time.Duration // How often to check ConfigDir for changes (0 = only at startup)
 
 	// Filtering
 	ExcludePaths []string
 
+	// Discovery
+	DiscoveryInterval   time.Duration       // How often to rescan the pod for new and gone containers (0 = only at startup)
+	DiscoveryAttempts   int                 // Times to attempt discovery at startup before giving up (0 or 1 = no retries)
+	DiscoveryTimeout    time.Duration       // Time limit for one discovery scan (0 = unbounded)
+	ImageLookupAttempts int                 // Times to read the pod status while containers are missing from it (0 or 1 = no retries)
+	CRISocket           string              // Container runtime CRI socket for container names and images ("" = probe the usual paths)
+	KubeletURL          string              // Kubelet read-only API to read the pod status from instead of the API server (optional)
+	ContainerdSocket    string              // containerd socket for lifecycle events and container rootfs access (optional)
+	ContainerdNS        string              // containerd namespace of the traced containers
+	DockerSocket        string              // Trace a Docker host's containers through this API socket instead of the pod's
+	ComposeProject      string              // Only trace the Docker containers of this Compose project
+	Node                bool                // Trace the containers of every pod on the node instead of the pod's
+	RunCommand          []string            // Trace this command, started by snoop in a transient cgroup, instead of containers
+	RunPID              int                 // Trace this existing process tree, moved to a transient cgroup, instead of containers
+	SystemdUnit         string              // Trace this systemd unit's cgroup instead of containers
+	Duration            time.Duration       // Trace for this long, then write a final report and exit (0 = until stopped)
+	StableAfter         time.Duration       // Mark reports converged once no new files have been recorded for this long (0 = never)
+	ExitWhenStable      bool                // Write a final report and exit once reports converge
+	TraceContainers     []string            // Only trace containers whose names match one of these patterns (empty = all)
+	SkipContainers      []string            // Don't trace containers whose names match one of these patterns
+	IncludeInfra        bool                // Trace InfraContainers too
+	SkipEphemeral       bool                // Don't trace ephemeral containers (e.g. from kubectl debug)
+	Containers          []ContainerSettings // Per-container overrides, from a config file; the first match applies
+	Rules               []RuleSettings      // File access policy rules, from a config file; the first match applies
+
+	// Enrichment
+	SnapshotOpenFiles   bool          // Record the files containers already have open when tracing starts
+	FileMetadata        bool          // Stat accessed files through the container rootfs
+	Packages            bool          // Attribute accessed files to installed OS packages
+	PackagesSBOM        string        // SBOM file or URL to read packages from instead of the image's database
+	PackagesReload      time.Duration // How often to check the package database for changes (0 = never)
+	PackageLoadAttempts int           // Times to look for a container's package database (0 or 1 = no retries)
+	PackageLoadRetry    time.Duration // Minimum time between package database load attempts
+	UnusedFiles         bool          // List each package's never-accessed files
+	PackagesVerify      bool          // Check accessed package files against recorded checksums
+	PackageMetricsTop   int           // Most accessed packages per container to export access metrics for (0 = none)
+	APKDatabases        []string      // apk installed database locations, relative to the container root
+	PackagesImage       bool          // Read the apk database from the container's image when its filesystem lacks one
+	PythonPackages      bool          // Attribute accessed files to pip packages
+	NpmPackages         bool          // Attribute accessed files to npm packages
+	GoBuildInfo         bool          // Read build info from executed Go binaries
+	LibraryDeps         bool          // Resolve the shared libraries executed binaries need
+	JavaClasspath       bool          // Read the classpath of JVMs and report which jars on it were opened
+	Layers              bool          // Attribute accessed files and packages to image layers
+	LayersHostRoot      string        // Where the host filesystem is mounted, for reading layer directories
+	Coverage            bool          // Compare accessed files with every file in the image layers
+	CoverageLargest     int           // Largest never-accessed image files to list
+	VolumeFiles         bool          // Classify accessed files by the volume they are on
+	HashFiles           bool          // Compute sha256 digests of accessed files
+	HashMaxSize         int64         // Largest file to hash, in bytes (0 = unbounded)
+	HashWorkers         int           // Number of concurrent hashing workers
+
 	// Metadata
 	ImageRef    string
 	ImageDigest string
 	ContainerID string
 	PodName     string
 	Namespace   string
+	PodUID      string
+	NodeName    string
 	Labels      map[string]string
 
 	// Observability
 	MetricsAddr string
 	LogLevel    slog.Level
+	Pprof       bool // Serve net/http/pprof profiles under /debug/pprof/ on MetricsAddr
+	DebugState  bool // Serve the processor's live state under /debug/state on MetricsAddr
+
+	// DebugSampleRate, if positive, logs one in this many events in full at
+	// info level.
+	DebugSampleRate int
+
+	// MetricsTLSCert and MetricsTLSKey serve MetricsAddr over TLS. With
+	// MetricsClientCA or MetricsTokenFile, requests other than health checks
+	// must authenticate with a certificate signed by the CA, whose identity
+	// is one of MetricsClientIDs if set, or with the token.
+	MetricsTLSCert   string
+	MetricsTLSKey    string
+	MetricsClientCA  string
+	MetricsClientIDs []string
+	MetricsTokenFile string
+
+	// ServeAddr is the address of the HTTP API serving live report state,
+	// or empty to disable it.
+	ServeAddr string
+
+	// Notifications
+	WebhookURL        string   // Optional URL to POST notifications to
+	WebhookTemplate   string   // Optional path to a text/template for webhook bodies
+	Events            bool     // Record notifications as Kubernetes Events on the pod
+	WatchPaths        []string // Paths whose first access triggers a notification
+	DropRateThreshold float64  // Drop rate percentage that triggers a notification (0 = disabled)
+	Baseline          string   // Report whose files each container is expected to access; others raise alerts
 
 	// Resource limits
 	MaxUniqueFiles int
+	MaxPathBytes   int64 // Approximate memory limit of each container's tracked paths (0 = unbounded)
+	ReportMaxFiles int   // Max files listed per container in reports (0 = unbounded)
+	MaxSampleRate  int   // Process at worst 1 in N events under backpressure (1 = never sample)
 }
 
 // Validate checks that the configuration is valid and returns an error if not.
 func (c *Config) Validate() error {
 	var errs []string
 
-	// Required fields
-	if c.ReportPath == "" {
-		errs = append(errs, "report path is required")
+	// At least one reporter is required
+	if c.ReportPath == "" && c.ReportDir == "" && c.ReportURL == "" && c.PushgatewayURL == "" && c.OTLPEndpoint == "" && c.RemoteWriteURL == "" && c.SyslogAddr == "" && c.AuditLog == "" && c.ReportSocket == "" && !c.ReportCRD {
+		errs = append(errs, "report path is required (or configure a report URL, Pushgateway URL, OTLP endpoint, remote-write URL, syslog address, audit log, report socket, or FileAccessReport resources)")
+	}
+	if c.ReportCRD && !c.Node && (c.PodName == "" || c.Namespace == "") {
+		errs = append(errs, "FileAccessReport resources require the pod name and namespace (or node mode)")
+	}
+
+	// Validate remote reporter URLs
+	if c.ReportURL != "" {
+		if err := validateHTTPURL(c.ReportURL); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid report URL: %v", err))
+		}
+	}
+	if c.PushgatewayURL != "" {
+		if err := validateHTTPURL(c.PushgatewayURL); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid Pushgateway URL: %v", err))
+		}
+	}
+	if c.OTLPEndpoint != "" {
+		if err := validateHTTPURL(c.OTLPEndpoint); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid OTLP endpoint: %v", err))
+		}
+	}
+	if c.RemoteWriteURL != "" {
+		if err := validateHTTPURL(c.RemoteWriteURL); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid remote-write URL: %v", err))
+		}
+	}
+	if c.SyslogAddr != "" {
+		if _, _, err := reporter.ParseSyslogAddr(c.SyslogAddr); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid syslog address: %v", err))
+		}
 	}
 
 	// Validate report interval
@@ -54,6 +253,15 @@ func (c *Config) Validate() error {
 	if c.ReportInterval < time.Second {
 		errs = append(errs, "report interval must be at least 1 second")
 	}
+	if c.Duration < 0 {
+		errs = append(errs, "duration cannot be negative")
+	}
+	if c.StableAfter < 0 {
+		errs = append(errs, "stable-after window cannot be negative")
+	}
+	if c.ExitWhenStable && c.StableAfter == 0 {
+		errs = append(errs, "exiting when stable requires a stable-after window")
+	}
 
 	// Validate log level
 	validLevels := map[string]bool{
@@ -66,10 +274,157 @@ func (c *Config) Validate() error {
 		errs = append(errs, fmt.Sprintf("invalid log level %q (must be debug, info, warn, or error)", c.LogLevel))
 	}
 
+	if c.ConfigReload < 0 {
+		errs = append(errs, "config reload interval cannot be negative")
+	}
+
+	if c.DiscoveryInterval < 0 {
+		errs = append(errs, "discovery interval cannot be negative")
+	}
+	if c.DiscoveryAttempts < 0 {
+		errs = append(errs, "discovery attempts cannot be negative")
+	}
+	if c.DiscoveryTimeout < 0 {
+		errs = append(errs, "discovery timeout cannot be negative")
+	}
+	if c.ImageLookupAttempts < 0 {
+		errs = append(errs, "image lookup attempts cannot be negative")
+	}
+	if c.ContainerdSocket != "" && c.ContainerdNS == "" {
+		errs = append(errs, "containerd namespace is required with a containerd socket")
+	}
+	if c.KubeletURL != "" {
+		if err := validateHTTPURL(c.KubeletURL); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid kubelet URL: %v", err))
+		}
+	}
+	if c.DockerSocket != "" && c.ContainerdSocket != "" {
+		errs = append(errs, "Docker and containerd sockets are mutually exclusive")
+	}
+	if c.ComposeProject != "" && c.DockerSocket == "" {
+		errs = append(errs, "a Compose project requires a Docker socket")
+	}
+	if c.RunPID < 0 {
+		errs = append(errs, "PID cannot be negative")
+	}
+	if len(c.RunCommand) > 0 && c.RunPID != 0 {
+		errs = append(errs, "a command and a PID are mutually exclusive")
+	}
+	modes := 0
+	for _, set := range []bool{len(c.RunCommand) > 0 || c.RunPID != 0, c.SystemdUnit != "", c.Node, c.DockerSocket != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		errs = append(errs, "process, systemd unit, node, and Docker modes are mutually exclusive")
+	}
+	for _, patterns := range [][]string{c.TraceContainers, c.SkipContainers} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid container pattern %q: %v", pattern, err))
+			}
+		}
+	}
+
+	for _, cs := range c.Containers {
+		if _, err := path.Match(cs.Match, ""); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid container pattern %q: %v", cs.Match, err))
+		}
+		if cs.MaxUniqueFiles < 0 {
+			errs = append(errs, fmt.Sprintf("max unique files for containers matching %q cannot be negative", cs.Match))
+		}
+		if cs.MaxPathBytes < 0 {
+			errs = append(errs, fmt.Sprintf("max path bytes for containers matching %q cannot be negative", cs.Match))
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, rs := range c.Rules {
+		if err := rs.Rule().Validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("rules[%d]: %s", i, strings.ReplaceAll(err.Error(), "\n", "; ")))
+		}
+		if rs.Name != "" && names[rs.Name] {
+			errs = append(errs, fmt.Sprintf("rules[%d]: duplicate name %q", i, rs.Name))
+		}
+		names[rs.Name] = true
+	}
+
 	// Validate max unique files
 	if c.MaxUniqueFiles < 0 {
 		errs = append(errs, "max unique files cannot be negative")
 	}
+	if c.MaxPathBytes < 0 {
+		errs = append(errs, "max path bytes cannot be negative")
+	}
+	if c.MaxSampleRate < 0 {
+		errs = append(errs, "max sample rate cannot be negative")
+	}
+	if c.ReportMaxFiles < 0 {
+		errs = append(errs, "report max files cannot be negative")
+	}
+
+	// Validate notification settings
+	if c.WebhookURL != "" {
+		if err := validateHTTPURL(c.WebhookURL); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid webhook URL: %v", err))
+		}
+	}
+	if c.WebhookTemplate != "" {
+		if _, err := os.Stat(c.WebhookTemplate); err != nil {
+			errs = append(errs, fmt.Sprintf("cannot read webhook template: %v", err))
+		}
+	}
+	if c.Events && (c.PodName == "" || c.Namespace == "") {
+		errs = append(errs, "Kubernetes Events require the pod name and namespace")
+	}
+	if c.DropRateThreshold < 0 || c.DropRateThreshold > 100 {
+		errs = append(errs, "drop rate threshold must be between 0 and 100")
+	}
+
+	// Validate package attribution settings
+	if c.PackageLoadAttempts < 0 {
+		errs = append(errs, "package load attempts cannot be negative")
+	}
+	if c.PackageLoadRetry < 0 {
+		errs = append(errs, "package load retry cannot be negative")
+	}
+	if c.PackagesReload < 0 {
+		errs = append(errs, "packages reload interval cannot be negative")
+	}
+	if c.PackageMetricsTop < 0 {
+		errs = append(errs, "package metrics top-N cannot be negative")
+	}
+	for _, db := range c.APKDatabases {
+		if !strings.HasPrefix(db, "/") {
+			errs = append(errs, fmt.Sprintf("apk database path %q must be absolute", db))
+		}
+	}
+	if c.PackagesImage && !c.Packages {
+		errs = append(errs, "reading packages from the image requires package attribution")
+	}
+	if c.PackagesVerify && !c.Packages {
+		errs = append(errs, "package verification requires package attribution")
+	}
+	if c.Coverage && !c.Layers {
+		errs = append(errs, "coverage analysis requires layer attribution")
+	}
+	if c.CoverageLargest < 0 {
+		errs = append(errs, "coverage largest files cannot be negative")
+	}
+	if c.PackagesSBOM != "" {
+		if err := sbom.ValidateSource(c.PackagesSBOM); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid packages SBOM: %v", err))
+		}
+	}
+
+	// Validate content hashing settings
+	if c.HashMaxSize < 0 {
+		errs = append(errs, "hash max size cannot be negative")
+	}
+	if c.HashFiles && c.HashWorkers < 1 {
+		errs = append(errs, "hash workers must be at least 1 when hashing is enabled")
+	}
 
 	// Validate report path is writable (check directory exists and is writable)
 	if c.ReportPath != "" {
@@ -105,6 +460,38 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.DebugSampleRate < 0 {
+		errs = append(errs, "debug sample rate cannot be negative")
+	}
+
+	if c.Pprof && c.MetricsAddr == "" {
+		errs = append(errs, "pprof requires a metrics address")
+	}
+
+	if c.DebugState && c.MetricsAddr == "" {
+		errs = append(errs, "debug state requires a metrics address")
+	}
+
+	if (c.MetricsTLSCert == "") != (c.MetricsTLSKey == "") {
+		errs = append(errs, "metrics TLS certificate and key must be set together")
+	}
+	if c.MetricsClientCA != "" && c.MetricsTLSCert == "" {
+		errs = append(errs, "metrics client CA requires a metrics TLS certificate")
+	}
+	if len(c.MetricsClientIDs) > 0 && c.MetricsClientCA == "" {
+		errs = append(errs, "metrics client IDs require a metrics client CA")
+	}
+	if (c.MetricsTLSCert != "" || c.MetricsTokenFile != "") && c.MetricsAddr == "" {
+		errs = append(errs, "metrics TLS and authentication require a metrics address")
+	}
+
+	if c.ServeAddr != "" && !strings.Contains(c.ServeAddr, ":") {
+		errs = append(errs, fmt.Sprintf("invalid serve address format %q (expected :port or host:port)", c.ServeAddr))
+	}
+	if c.ServeAddr != "" && c.ServeAddr == c.MetricsAddr {
+		errs = append(errs, "serve address must differ from the metrics address")
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("configuration validation failed:\n  - %s", strings.Join(errs, "\n  - "))
 	}
@@ -112,11 +499,50 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validateHTTPURL checks that s is an absolute http or https URL.
+func validateHTTPURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must use http or https", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", s)
+	}
+	return nil
+}
+
 // ExcludePathsString returns the exclude paths as a comma-separated string.
 func (c *Config) ExcludePathsString() string {
 	return strings.Join(c.ExcludePaths, ",")
 }
 
+// TraceContainer reports whether the container with the given name should
+// be traced: it must match one of TraceContainers, if any are set, and none
+// of SkipContainers. Patterns use path.Match syntax, e.g. "app" or "*-proxy".
+// InfraContainers are skipped unless IncludeInfra is set.
+func (c *Config) TraceContainer(name string) bool {
+	if len(c.TraceContainers) > 0 && !matchAny(c.TraceContainers, name) {
+		return false
+	}
+	if !c.IncludeInfra && slices.Contains(InfraContainers, name) {
+		return false
+	}
+	return !matchAny(c.SkipContainers, name)
+}
+
+// matchAny reports whether name matches any of patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // ParseExcludePaths parses a comma-separated string of exclude paths.
 func ParseExcludePaths(s string) []string {
 	if s == "" {
@@ -76,6 +76,103 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "negative duration",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				Duration:       -time.Minute,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "exit when stable without a window",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				ExitWhenStable: true,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "exit when stable",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				StableAfter:    5 * time.Minute,
+				ExitWhenStable: true,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "negative debug sample rate",
+			cfg: &Config{
+				ReportPath:      filepath.Join(tmpDir, "report.json"),
+				ReportInterval:  30 * time.Second,
+				LogLevel:        slog.LevelInfo,
+				DebugSampleRate: -1,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "pprof without a metrics server",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				Pprof:          true,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "debug state without a metrics server",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				DebugState:     true,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "metrics TLS certificate without a key",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				MetricsAddr:    ":9090",
+				MetricsTLSCert: "/tls/tls.crt",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "metrics client CA without TLS",
+			cfg: &Config{
+				ReportPath:      filepath.Join(tmpDir, "report.json"),
+				ReportInterval:  30 * time.Second,
+				LogLevel:        slog.LevelInfo,
+				MetricsAddr:     ":9090",
+				MetricsClientCA: "/tls/ca.crt",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "metrics mTLS and token",
+			cfg: &Config{
+				ReportPath:       filepath.Join(tmpDir, "report.json"),
+				ReportInterval:   30 * time.Second,
+				LogLevel:         slog.LevelInfo,
+				MetricsAddr:      ":9090",
+				MetricsTLSCert:   "/tls/tls.crt",
+				MetricsTLSKey:    "/tls/tls.key",
+				MetricsClientCA:  "/tls/ca.crt",
+				MetricsClientIDs: []string{"spiffe://cluster.local/ns/monitoring/sa/prometheus"},
+				MetricsTokenFile: "/var/run/secrets/snoop/token",
+			},
+		},
 		{
 			desc: "negative max unique files",
 			cfg: &Config{
@@ -87,6 +184,111 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "negative max path bytes",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				MaxPathBytes:   -1,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "negative max sample rate",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				MaxSampleRate:  -1,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "negative report max files",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				ReportMaxFiles: -1,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "valid webhook",
+			cfg: &Config{
+				ReportPath:        filepath.Join(tmpDir, "report.json"),
+				ReportInterval:    30 * time.Second,
+				LogLevel:          slog.LevelInfo,
+				WebhookURL:        "https://hooks.example.com/snoop",
+				WatchPaths:        []string{"/etc/shadow"},
+				DropRateThreshold: 5,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "invalid webhook URL",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				WebhookURL:     "hooks.example.com",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "missing webhook template",
+			cfg: &Config{
+				ReportPath:      filepath.Join(tmpDir, "report.json"),
+				ReportInterval:  30 * time.Second,
+				LogLevel:        slog.LevelInfo,
+				WebhookURL:      "https://hooks.example.com/snoop",
+				WebhookTemplate: filepath.Join(tmpDir, "missing.tmpl"),
+			},
+			wantErr: true,
+		},
+		{
+			desc: "drop rate threshold out of range",
+			cfg: &Config{
+				ReportPath:        filepath.Join(tmpDir, "report.json"),
+				ReportInterval:    30 * time.Second,
+				LogLevel:          slog.LevelInfo,
+				DropRateThreshold: 150,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "hashing enabled with workers",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				HashFiles:      true,
+				HashMaxSize:    DefaultHashMaxSize,
+				HashWorkers:    DefaultHashWorkers,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "hashing enabled without workers",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				HashFiles:      true,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "negative hash max size",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				HashMaxSize:    -1,
+			},
+			wantErr: true,
+		},
 		{
 			desc: "nonexistent report directory",
 			cfg: &Config{
@@ -97,6 +299,150 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "report URL without report path",
+			cfg: &Config{
+				ReportURL:      "https://collector.example.com/reports",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "pushgateway URL without report path",
+			cfg: &Config{
+				PushgatewayURL: "http://pushgateway:9091",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "OTLP endpoint without report path",
+			cfg: &Config{
+				OTLPEndpoint:   "http://otel-collector:4318",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "invalid OTLP endpoint",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				OTLPEndpoint:   "otel-collector:4318",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "remote-write URL without report path",
+			cfg: &Config{
+				RemoteWriteURL: "http://prometheus:9090/api/v1/write",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "invalid remote-write URL",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				RemoteWriteURL: "/api/v1/write",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "syslog address without report path",
+			cfg: &Config{
+				SyslogAddr:     "udp://syslog.example.com:514",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "audit log without report path",
+			cfg: &Config{
+				AuditLog:       "/var/log/snoop/audit.jsonl",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "report socket without report path",
+			cfg: &Config{
+				ReportSocket:   "/run/snoop/report.sock",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "report CRD without report path",
+			cfg: &Config{
+				ReportCRD:      true,
+				PodName:        "web",
+				Namespace:      "default",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "report CRD without pod name",
+			cfg: &Config{
+				ReportCRD:      true,
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "events without pod name",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				Events:         true,
+				Namespace:      "default",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid syslog address",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				SyslogAddr:     "https://syslog.example.com",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid report URL scheme",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportURL:      "ftp://collector.example.com/reports",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid pushgateway URL",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				PushgatewayURL: "pushgateway:9091",
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+			},
+			wantErr: true,
+		},
 		{
 			desc: "invalid metrics address",
 			cfg: &Config{
@@ -138,6 +484,169 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			desc: "serve address",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				MetricsAddr:    ":9090",
+				ServeAddr:      ":9091",
+			},
+			wantErr: false,
+		},
+		{
+			desc: "serve address shared with metrics",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				MetricsAddr:    ":9090",
+				ServeAddr:      ":9090",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "packages SBOM URL",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				PackagesSBOM:   "https://example.com/sbom.spdx.json",
+			},
+			wantErr: false,
+		},
+		{
+			desc: "unsupported packages SBOM scheme",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				PackagesSBOM:   "oci://registry.example.com/app",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "packages image without package attribution",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				PackagesImage:  true,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "coverage without layer attribution",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				Coverage:       true,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "docker and containerd sockets",
+			cfg: &Config{
+				ReportPath:       filepath.Join(tmpDir, "report.json"),
+				ReportInterval:   30 * time.Second,
+				LogLevel:         slog.LevelInfo,
+				DockerSocket:     "/var/run/docker.sock",
+				ContainerdSocket: "/run/containerd/containerd.sock",
+				ContainerdNS:     "k8s.io",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "node mode with per-pod reports only",
+			cfg: &Config{
+				ReportDir:      filepath.Join(tmpDir, "pods"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				Node:           true,
+			},
+			wantErr: false,
+		},
+		{
+			desc: "node and docker modes",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				Node:           true,
+				DockerSocket:   "/var/run/docker.sock",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "command and PID",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				RunCommand:     []string{"ls"},
+				RunPID:         42,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "systemd unit and node mode",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				SystemdUnit:    "nginx.service",
+				Node:           true,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid container pattern",
+			cfg: &Config{
+				ReportPath:      filepath.Join(tmpDir, "report.json"),
+				ReportInterval:  30 * time.Second,
+				LogLevel:        slog.LevelInfo,
+				TraceContainers: []string{"app["},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "valid rules",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				Rules: []RuleSettings{
+					{Name: "app-secrets", Paths: []string{"/var/run/secrets/"}, Processes: []string{"/app/server"}, Action: "allow"},
+					{Name: "secrets", Paths: []string{"/var/run/secrets/"}, Operations: []string{"open"}, Action: "alert"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			desc: "invalid rule",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				Rules:          []RuleSettings{{Name: "secrets", Paths: []string{"/var/run/secrets/"}, Action: "deny"}},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "duplicate rule names",
+			cfg: &Config{
+				ReportPath:     filepath.Join(tmpDir, "report.json"),
+				ReportInterval: 30 * time.Second,
+				LogLevel:       slog.LevelInfo,
+				Rules: []RuleSettings{
+					{Name: "secrets", Paths: []string{"/var/run/secrets/"}, Action: "alert"},
+					{Name: "secrets", Paths: []string{"/etc/"}, Action: "alert"},
+				},
+			},
+			wantErr: true,
+		},
 	} {
 		t.Run(tt.desc, func(t *testing.T) {
 			err := tt.cfg.Validate()
@@ -205,3 +714,26 @@ func TestExcludePathsString(t *testing.T) {
 		t.Errorf("ExcludePathsString() = %q, want %q", got, want)
 	}
 }
+
+func TestTraceContainer(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		cfg   Config
+		name  string
+		trace bool
+	}{
+		{desc: "default traces everything", name: "app", trace: true},
+		{desc: "trace list match", cfg: Config{TraceContainers: []string{"app", "worker-*"}}, name: "worker-1", trace: true},
+		{desc: "trace list miss", cfg: Config{TraceContainers: []string{"app"}}, name: "fluent-bit", trace: false},
+		{desc: "skip list match", cfg: Config{SkipContainers: []string{"*-proxy"}}, name: "istio-proxy", trace: false},
+		{desc: "infra skipped by default", name: "istio-proxy", trace: false},
+		{desc: "infra included", cfg: Config{IncludeInfra: true}, name: "linkerd-proxy", trace: true},
+		{desc: "skip wins over trace", cfg: Config{TraceContainers: []string{"*"}, SkipContainers: []string{"log-shipper"}}, name: "log-shipper", trace: false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := tt.cfg.TraceContainer(tt.name); got != tt.trace {
+				t.Errorf("TraceContainer(%q) = %v, want %v", tt.name, got, tt.trace)
+			}
+		})
+	}
+}
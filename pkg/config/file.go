@@ -0,0 +1,198 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/policy"
+	"go.yaml.in/yaml/v2"
+)
+
+// ContainerSettings override settings for the containers whose names match
+// a pattern. They can only be set in a config file.
+type ContainerSettings struct {
+	// Match is a container name or path.Match pattern, e.g. "app" or
+	// "*-worker", matched like TraceContainers.
+	Match string `yaml:"match"`
+
+	// Exclude lists path prefixes not recorded for matching containers, in
+	// addition to ExcludePaths.
+	Exclude []string `yaml:"exclude"`
+
+	// MaxUniqueFiles overrides MaxUniqueFiles for matching containers when
+	// it is positive.
+	MaxUniqueFiles int `yaml:"max-unique-files"`
+
+	// MaxPathBytes overrides MaxPathBytes for matching containers when it
+	// is positive.
+	MaxPathBytes int64 `yaml:"max-path-bytes"`
+}
+
+// RuleSettings define a file access policy rule; see policy.Rule. They
+// can only be set in a config file.
+type RuleSettings struct {
+	Name       string   `yaml:"name"`
+	Paths      []string `yaml:"paths"`
+	Processes  []string `yaml:"processes"`
+	Operations []string `yaml:"operations"`
+	Action     string   `yaml:"action"`
+}
+
+// Rule returns the rule the settings define.
+func (r RuleSettings) Rule() policy.Rule {
+	return policy.Rule{
+		Name:       r.Name,
+		Paths:      r.Paths,
+		Processes:  r.Processes,
+		Operations: r.Operations,
+		Action:     policy.Action(r.Action),
+	}
+}
+
+// File is a configuration file in YAML or JSON. Its top-level keys are flag
+// names with the values they'd take on the command line; lists are joined
+// with commas and maps become comma-separated key=value pairs. The
+// "containers" key lists ContainerSettings and the "rules" key lists
+// RuleSettings, which have no flags.
+type File struct {
+	// Flags maps flag names to their values.
+	Flags map[string]string
+
+	// Containers are the per-container settings, in file order.
+	Containers []ContainerSettings
+
+	// Rules are the file access policy rules, in file order.
+	Rules []RuleSettings
+}
+
+// LoadFile reads a configuration file.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// ParseFile parses the contents of a configuration file. JSON is parsed as
+// YAML, of which it is a subset.
+func ParseFile(data []byte) (*File, error) {
+	var raw struct {
+		Containers []ContainerSettings    `yaml:"containers"`
+		Rules      []RuleSettings         `yaml:"rules"`
+		Flags      map[string]interface{} `yaml:",inline"`
+	}
+	if err := yaml.UnmarshalStrict(data, &raw); err != nil {
+		return nil, err
+	}
+	f := &File{Flags: make(map[string]string, len(raw.Flags)), Containers: raw.Containers, Rules: raw.Rules}
+	for name, v := range raw.Flags {
+		value, err := flagValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		f.Flags[name] = value
+	}
+	for i, c := range f.Containers {
+		if c.Match == "" {
+			return nil, fmt.Errorf("containers[%d]: match is required", i)
+		}
+	}
+	return f, nil
+}
+
+// flagValue renders a YAML value as a flag value.
+func flagValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			s, err := scalarValue(e)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, s)
+		}
+		return strings.Join(values, ","), nil
+	case map[interface{}]interface{}:
+		pairs := make([]string, 0, len(v))
+		for k, e := range v {
+			s, err := scalarValue(e)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, fmt.Sprintf("%v=%s", k, s))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), nil
+	default:
+		return scalarValue(v)
+	}
+}
+
+// scalarValue renders a YAML scalar as a flag value.
+func scalarValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// Apply sets the flags in fs from the file, except for flags already set
+// on the command line, which take precedence.
+func (f *File) Apply(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	names := make([]string, 0, len(f.Flags))
+	for name := range f.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []string
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			errs = append(errs, fmt.Sprintf("unknown flag %q", name))
+			continue
+		}
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, f.Flags[name]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid settings:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+// ContainerSettingsFor returns the first of Containers matching a
+// container's name, or nil if none does.
+func (c *Config) ContainerSettingsFor(name string) *ContainerSettings {
+	for i := range c.Containers {
+		if matchAny([]string{c.Containers[i].Match}, name) {
+			return &c.Containers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadFile(t *testing.T) {
+	for _, tt := range []struct {
+		name, content string
+	}{
+		{"snoop.yaml", `
+interval: 1m
+exclude: [/proc/, /sys/]
+labels:
+  team: web
+  app: shop
+packages: true
+max-unique-files: 5000
+drop-rate-threshold: 2.5
+containers:
+  - match: "*-worker"
+    exclude: [/cache/]
+    max-unique-files: 100
+rules:
+  - name: secrets
+    paths: [/var/run/secrets/]
+    operations: [open]
+    action: alert
+`},
+		{"snoop.json", `{
+  "interval": "1m",
+  "exclude": ["/proc/", "/sys/"],
+  "labels": {"team": "web", "app": "shop"},
+  "packages": true,
+  "max-unique-files": 5000,
+  "drop-rate-threshold": 2.5,
+  "containers": [{"match": "*-worker", "exclude": ["/cache/"], "max-unique-files": 100}],
+  "rules": [{"name": "secrets", "paths": ["/var/run/secrets/"], "operations": ["open"], "action": "alert"}]
+}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := LoadFile(path)
+			if err != nil {
+				t.Fatalf("LoadFile failed: %v", err)
+			}
+			want := &File{
+				Flags: map[string]string{
+					"interval":            "1m",
+					"exclude":             "/proc/,/sys/",
+					"labels":              "app=shop,team=web",
+					"packages":            "true",
+					"max-unique-files":    "5000",
+					"drop-rate-threshold": "2.5",
+				},
+				Containers: []ContainerSettings{{Match: "*-worker", Exclude: []string{"/cache/"}, MaxUniqueFiles: 100}},
+				Rules:      []RuleSettings{{Name: "secrets", Paths: []string{"/var/run/secrets/"}, Operations: []string{"open"}, Action: "alert"}},
+			}
+			if !reflect.DeepEqual(f, want) {
+				t.Errorf("LoadFile = %+v, want %+v", f, want)
+			}
+		})
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	for _, content := range []string{
+		"containers:\n  - exclude: [/tmp/]\n",
+		"containers:\n  - match: app\n    max-files: 3\n",
+		"interval: [1m, {a: [b]}]\n",
+		"not yaml: [",
+	} {
+		if _, err := ParseFile([]byte(content)); err == nil {
+			t.Errorf("ParseFile(%q) succeeded", content)
+		}
+	}
+}
+
+func TestFileApply(t *testing.T) {
+	fs := flag.NewFlagSet("snoop", flag.ContinueOnError)
+	interval := fs.Duration("interval", 30*time.Second, "")
+	exclude := fs.String("exclude", "", "")
+	packages := fs.Bool("packages", false, "")
+	if err := fs.Parse([]string{"-interval=5s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &File{Flags: map[string]string{"interval": "1m", "exclude": "/proc/", "packages": "true"}}
+	if err := f.Apply(fs); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	// The command line wins over the file
+	if *interval != 5*time.Second || *exclude != "/proc/" || !*packages {
+		t.Errorf("after Apply: interval=%s exclude=%q packages=%t", *interval, *exclude, *packages)
+	}
+
+	fs = flag.NewFlagSet("snoop", flag.ContinueOnError)
+	fs.Bool("packages", false, "")
+	f = &File{Flags: map[string]string{"intervl": "1m", "packages": "maybe"}}
+	err := f.Apply(fs)
+	if err == nil || !strings.Contains(err.Error(), `unknown flag "intervl"`) || !strings.Contains(err.Error(), "packages:") {
+		t.Errorf("Apply with bad settings = %v", err)
+	}
+}
+
+func TestContainerSettingsFor(t *testing.T) {
+	cfg := &Config{Containers: []ContainerSettings{
+		{Match: "app", MaxUniqueFiles: 1},
+		{Match: "*-worker", MaxUniqueFiles: 2},
+		{Match: "*", MaxUniqueFiles: 3},
+	}}
+	for name, want := range map[string]int{"app": 1, "img-worker": 2, "sidecar": 3} {
+		if got := cfg.ContainerSettingsFor(name); got == nil || got.MaxUniqueFiles != want {
+			t.Errorf("ContainerSettingsFor(%q) = %+v, want MaxUniqueFiles %d", name, got, want)
+		}
+	}
+	if got := (&Config{}).ContainerSettingsFor("app"); got != nil {
+		t.Errorf("ContainerSettingsFor without settings = %+v", got)
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// ReloadableSettings are the names of the files read from ConfigDir, named
+// after the flags they override. List settings are comma- or
+// newline-separated.
+var ReloadableSettings = []string{
+	"exclude",
+	"trace-containers",
+	"skip-containers",
+	"watch-paths",
+	"max-unique-files",
+	"max-path-bytes",
+	"report-max-files",
+	"interval",
+}
+
+// Reloader holds the current configuration: the flags, overlaid with the
+// settings read from ConfigDir, such as a mounted ConfigMap. When the
+// directory changes, its settings are read and validated again, so they can
+// be tuned without restarting snoop and losing what it has recorded.
+type Reloader struct {
+	base    *Config
+	current atomic.Pointer[Config]
+
+	// fingerprint identifies the directory contents last read.
+	fingerprint string
+}
+
+// NewReloader reads the settings in base.ConfigDir, if set, over base. It
+// fails if the directory can't be read or its settings are invalid.
+func NewReloader(base *Config) (*Reloader, error) {
+	r := &Reloader{base: base}
+	r.current.Store(base)
+	if base.ConfigDir == "" {
+		return r, nil
+	}
+	cfg, fingerprint, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(cfg)
+	r.fingerprint = fingerprint
+	return r, nil
+}
+
+// Config returns the current configuration, which must not be modified.
+func (r *Reloader) Config() *Config {
+	return r.current.Load()
+}
+
+// Reload reads ConfigDir again. It returns the new configuration if the
+// directory changed, or nil if it didn't. If the new settings are invalid,
+// it returns an error and keeps the current configuration until the
+// directory changes again.
+func (r *Reloader) Reload() (*Config, error) {
+	if r.base.ConfigDir == "" {
+		return nil, nil
+	}
+	cfg, fingerprint, err := r.load()
+	if fingerprint == r.fingerprint {
+		return nil, nil
+	}
+	r.fingerprint = fingerprint
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(cfg)
+	return cfg, nil
+}
+
+// Watch reloads ConfigDir every interval until ctx is done, sending each
+// changed configuration on the returned channel. Invalid settings are
+// logged and ignored. Without a ConfigDir, the channel never receives.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) <-chan *Config {
+	ch := make(chan *Config)
+	if r.base.ConfigDir == "" || interval <= 0 {
+		return ch
+	}
+	log := clog.FromContext(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			cfg, err := r.Reload()
+			if err != nil {
+				log.Errorf("Ignoring configuration in %s: %v", r.base.ConfigDir, err)
+				continue
+			}
+			if cfg == nil {
+				continue
+			}
+			select {
+			case ch <- cfg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// load reads ConfigDir over a copy of the base configuration, returning it
+// with a fingerprint of the directory's contents. Hidden files are skipped,
+// including the ..data link of a mounted ConfigMap.
+func (r *Reloader) load() (*Config, string, error) {
+	dir := r.base.ConfigDir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	cfg := *r.base
+	var fingerprint strings.Builder
+	var errs []string
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(&fingerprint, "%s=%q\n", name, data)
+		if err := cfg.set(name, strings.TrimSpace(string(data))); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fingerprint.String(), fmt.Errorf("invalid settings:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fingerprint.String(), err
+	}
+	return &cfg, fingerprint.String(), nil
+}
+
+// set overrides the setting named after a flag with value.
+func (c *Config) set(name, value string) error {
+	var err error
+	switch name {
+	case "exclude":
+		c.ExcludePaths = parseList(value)
+	case "trace-containers":
+		c.TraceContainers = parseList(value)
+	case "skip-containers":
+		c.SkipContainers = parseList(value)
+	case "watch-paths":
+		c.WatchPaths = parseList(value)
+	case "max-unique-files":
+		c.MaxUniqueFiles, err = strconv.Atoi(value)
+	case "max-path-bytes":
+		c.MaxPathBytes, err = strconv.ParseInt(value, 10, 64)
+	case "report-max-files":
+		c.ReportMaxFiles, err = strconv.Atoi(value)
+	case "interval":
+		c.ReportInterval, err = time.ParseDuration(value)
+	default:
+		return fmt.Errorf("unknown setting %q (must be one of %s)", name, strings.Join(ReloadableSettings, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// parseList parses a comma- or newline-separated list.
+func parseList(s string) []string {
+	return ParseExcludePaths(strings.ReplaceAll(s, "\n", ","))
+}
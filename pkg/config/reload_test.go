@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("exclude", "/proc/\n/sys/, /tmp/\n")
+	write("interval", "1m")
+	write("..data", "ignored")
+
+	base := &Config{
+		ConfigDir:      dir,
+		ReportSocket:   "/run/snoop/report.sock",
+		ReportInterval: 30 * time.Second,
+		ExcludePaths:   []string{"/proc/"},
+		MaxUniqueFiles: 100,
+		LogLevel:       slog.LevelInfo,
+	}
+	r, err := NewReloader(base)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	cfg := r.Config()
+	if want := []string{"/proc/", "/sys/", "/tmp/"}; !reflect.DeepEqual(cfg.ExcludePaths, want) {
+		t.Errorf("ExcludePaths = %v, want %v", cfg.ExcludePaths, want)
+	}
+	if cfg.ReportInterval != time.Minute || cfg.MaxUniqueFiles != 100 {
+		t.Errorf("ReportInterval = %s, MaxUniqueFiles = %d, want 1m and the flag's 100", cfg.ReportInterval, cfg.MaxUniqueFiles)
+	}
+	if base.ReportInterval != 30*time.Second {
+		t.Errorf("base config was modified: %+v", base)
+	}
+
+	// Nothing changed
+	if cfg, err := r.Reload(); cfg != nil || err != nil {
+		t.Errorf("Reload without changes = %v, %v, want nil, nil", cfg, err)
+	}
+
+	write("max-unique-files", "50")
+	cfg, err = r.Reload()
+	if err != nil || cfg == nil || cfg.MaxUniqueFiles != 50 || r.Config() != cfg {
+		t.Fatalf("Reload after change = %+v, %v", cfg, err)
+	}
+
+	// Invalid settings keep the current configuration
+	write("interval", "10ms")
+	if _, err := r.Reload(); err == nil {
+		t.Error("Reload with invalid interval succeeded, want error")
+	}
+	write("interval", "1m")
+	write("max-unique-files", "lots")
+	write("trace-cotnainers", "app")
+	if _, err := r.Reload(); err == nil {
+		t.Error("Reload with invalid settings succeeded, want error")
+	}
+	if r.Config().MaxUniqueFiles != 50 {
+		t.Errorf("MaxUniqueFiles = %d after invalid reload, want 50", r.Config().MaxUniqueFiles)
+	}
+
+	// Invalid settings at startup are an error
+	if _, err := NewReloader(base); err == nil {
+		t.Error("NewReloader with invalid settings succeeded, want error")
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	base := &Config{
+		ConfigDir:      dir,
+		ReportSocket:   "/run/snoop/report.sock",
+		ReportInterval: 30 * time.Second,
+		LogLevel:       slog.LevelInfo,
+	}
+	r, err := NewReloader(base)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := r.Watch(ctx, 10*time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(dir, "watch-paths"), []byte("/etc/shadow"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-ch:
+		if want := []string{"/etc/shadow"}; !reflect.DeepEqual(cfg.WatchPaths, want) {
+			t.Errorf("WatchPaths = %v, want %v", cfg.WatchPaths, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+}
+
+func TestReloaderWithoutDir(t *testing.T) {
+	base := &Config{ReportInterval: 30 * time.Second}
+	r, err := NewReloader(base)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	if r.Config() != base {
+		t.Error("Config() should return the base configuration")
+	}
+	if cfg, err := r.Reload(); cfg != nil || err != nil {
+		t.Errorf("Reload = %v, %v, want nil, nil", cfg, err)
+	}
+}
@@ -0,0 +1,224 @@
+// Package containerd talks to containerd's native API for container
+// lifecycle events, and locates container root filesystems in containerd's
+// state directory. Like package cri, it speaks just enough gRPC over the
+// unix socket to avoid depending on containerd's client library.
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// DefaultSocket is where containerd listens by default.
+	DefaultSocket = "/run/containerd/containerd.sock"
+
+	// DefaultNamespace is the namespace the kubelet's containers live in.
+	DefaultNamespace = "k8s.io"
+
+	// subscribeMethod is the streaming events Subscribe RPC.
+	subscribeMethod = "/containerd.services.events.v1.Events/Subscribe"
+
+	// maxMessageSize bounds the size of a streamed event.
+	maxMessageSize = 4 << 20
+)
+
+// Task event topics that change which containers are running.
+const (
+	TopicTaskStart  = "/tasks/start"
+	TopicTaskExit   = "/tasks/exit"
+	TopicTaskDelete = "/tasks/delete"
+)
+
+// Event is a containerd event about a container's task.
+type Event struct {
+	Namespace   string
+	Topic       string
+	ContainerID string
+}
+
+// Client talks to containerd on a unix socket.
+type Client struct {
+	socket    string
+	namespace string
+	http      *http.Client
+}
+
+// NewClient creates a client for containerd listening on socket, scoped to
+// the given namespace.
+func NewClient(socket, namespace string) *Client {
+	t := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetUnencryptedHTTP2(true)
+	// No client timeout: event subscriptions stay open until ctx is done
+	return &Client{socket: socket, namespace: namespace, http: &http.Client{Transport: t}}
+}
+
+// Rootfs returns the root filesystem of a running container's task, which
+// containerd mounts in its state directory next to the socket, e.g.
+// /run/containerd/io.containerd.runtime.v2.task/k8s.io/<id>/rootfs.
+func (c *Client) Rootfs(id string) string {
+	return filepath.Join(filepath.Dir(c.socket), "io.containerd.runtime.v2.task", c.namespace, id, "rootfs")
+}
+
+// Subscribe streams task start, exit, and delete events in the client's
+// namespace until ctx is done or the stream fails. The channel is closed
+// when the stream ends; the returned function reports why.
+func (c *Client) Subscribe(ctx context.Context) (<-chan Event, func() error, error) {
+	var msg []byte
+	for _, topic := range []string{TopicTaskStart, TopicTaskExit, TopicTaskDelete} {
+		filter := fmt.Sprintf(`topic==%q,namespace==%q`, topic, c.namespace)
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendString(msg, filter)
+	}
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+subscribeMethod, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("Containerd-Namespace", c.namespace)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("subscribing to containerd events: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("subscribing to containerd events: unexpected HTTP status %s", resp.Status)
+	}
+	if code := resp.Header.Get("Grpc-Status"); code != "" && code != "0" {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("subscribing to containerd events: rpc error: code = %s desc = %s", code, resp.Header.Get("Grpc-Message"))
+	}
+
+	ch := make(chan Event)
+	var streamErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(ch)
+		defer resp.Body.Close()
+		streamErr = readEvents(ctx, resp.Body, ch)
+		if streamErr == nil {
+			if code := resp.Trailer.Get("Grpc-Status"); code != "" && code != "0" {
+				streamErr = fmt.Errorf("rpc error: code = %s desc = %s", code, resp.Trailer.Get("Grpc-Message"))
+			}
+		}
+	}()
+	return ch, func() error { <-done; return streamErr }, nil
+}
+
+// readEvents decodes length-prefixed Envelope messages from r onto ch.
+func readEvents(ctx context.Context, r io.Reader, ch chan<- Event) error {
+	var prefix [5]byte
+	for {
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		size := binary.BigEndian.Uint32(prefix[1:])
+		if prefix[0] != 0 || size > maxMessageSize {
+			return fmt.Errorf("unsupported event message (flags %d, %d bytes)", prefix[0], size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		ev, err := parseEnvelope(data)
+		if err != nil {
+			return err
+		}
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// parseEnvelope decodes a containerd.services.events.v1.Envelope, taking
+// the container ID from the first field of the task event it wraps, which
+// is container_id in TaskStart, TaskExit, and TaskDelete.
+func parseEnvelope(b []byte) (Event, error) {
+	var ev Event
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return ev, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return ev, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return ev, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 2:
+			ev.Namespace = string(v)
+		case 3:
+			ev.Topic = string(v)
+		case 4:
+			// google.protobuf.Any: type_url = 1, value = 2
+			if value, ok := bytesField(v, 2); ok {
+				if id, ok := bytesField(value, 1); ok {
+					ev.ContainerID = string(id)
+				}
+			}
+		}
+	}
+	if !strings.HasPrefix(ev.Topic, "/") {
+		return ev, fmt.Errorf("event has no topic")
+	}
+	return ev, nil
+}
+
+// bytesField returns the first length-delimited field num in b.
+func bytesField(b []byte, num protowire.Number) ([]byte, bool) {
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return nil, false
+		}
+		b = b[l:]
+		if n == num && typ == protowire.BytesType {
+			v, l := protowire.ConsumeBytes(b)
+			return v, l >= 0
+		}
+		l = protowire.ConsumeFieldValue(n, typ, b)
+		if l < 0 {
+			return nil, false
+		}
+		b = b[l:]
+	}
+	return nil, false
+}
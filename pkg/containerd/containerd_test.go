@@ -0,0 +1,87 @@
+package containerd
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// envelope encodes an Envelope wrapping a task event for containerID.
+func envelope(topic, containerID string) []byte {
+	var task []byte
+	task = protowire.AppendTag(task, 1, protowire.BytesType)
+	task = protowire.AppendString(task, containerID)
+
+	var anyMsg []byte
+	anyMsg = protowire.AppendTag(anyMsg, 1, protowire.BytesType)
+	anyMsg = protowire.AppendString(anyMsg, "containerd.events.TaskStart")
+	anyMsg = protowire.AppendTag(anyMsg, 2, protowire.BytesType)
+	anyMsg = protowire.AppendBytes(anyMsg, task)
+
+	var env []byte
+	env = protowire.AppendTag(env, 2, protowire.BytesType)
+	env = protowire.AppendString(env, DefaultNamespace)
+	env = protowire.AppendTag(env, 3, protowire.BytesType)
+	env = protowire.AppendString(env, topic)
+	env = protowire.AppendTag(env, 4, protowire.BytesType)
+	env = protowire.AppendBytes(env, anyMsg)
+	return env
+}
+
+func TestSubscribe(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "containerd.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != subscribeMethod || r.Header.Get("Containerd-Namespace") != DefaultNamespace {
+			t.Errorf("request = %s, namespace %q", r.URL.Path, r.Header.Get("Containerd-Namespace"))
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		for _, ev := range [][]byte{envelope(TopicTaskStart, "abc"), envelope(TopicTaskExit, "def")} {
+			prefix := make([]byte, 5)
+			binary.BigEndian.PutUint32(prefix[1:], uint32(len(ev)))
+			w.Write(append(prefix, ev...))
+		}
+	}))
+	srv.Listener = l
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	c := NewClient(socket, DefaultNamespace)
+	events, wait, err := c.Subscribe(context.Background())
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	var got []Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+	if err := wait(); err != nil {
+		t.Errorf("stream error: %v", err)
+	}
+	want := []Event{
+		{Namespace: DefaultNamespace, Topic: TopicTaskStart, ContainerID: "abc"},
+		{Namespace: DefaultNamespace, Topic: TopicTaskExit, ContainerID: "def"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+}
+
+func TestRootfs(t *testing.T) {
+	c := NewClient(DefaultSocket, DefaultNamespace)
+	got := c.Rootfs("abc")
+	if want := "/run/containerd/io.containerd.runtime.v2.task/k8s.io/abc/rootfs"; got != want {
+		t.Errorf("Rootfs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,283 @@
+// Package cri queries a container runtime (containerd, CRI-O) over the
+// Kubernetes Container Runtime Interface for the identity of running
+// containers. It speaks just enough gRPC over the runtime's unix socket to
+// make unary calls, so it doesn't need generated CRI bindings.
+package cri
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// requestTimeout bounds each call to the runtime.
+	requestTimeout = 10 * time.Second
+
+	// maxMessageSize bounds the size of a response message.
+	maxMessageSize = 16 << 20
+
+	// containerStatusMethod is the CRI v1 ContainerStatus RPC.
+	containerStatusMethod = "/runtime.v1.RuntimeService/ContainerStatus"
+)
+
+// Kubernetes labels the kubelet sets on every container it creates.
+const (
+	LabelContainerName = "io.kubernetes.container.name"
+	LabelPodName       = "io.kubernetes.pod.name"
+	LabelPodNamespace  = "io.kubernetes.pod.namespace"
+)
+
+// DefaultSockets lists the usual CRI socket locations, for discovering
+// the runtime when no socket is configured.
+var DefaultSockets = []string{
+	"/run/containerd/containerd.sock",
+	"/run/crio/crio.sock",
+	"/var/run/cri-dockerd.sock",
+}
+
+// Container is the identity of a container as the runtime reports it.
+type Container struct {
+	ID      string
+	Name    string // Container name from the CRI metadata, e.g. "nginx"
+	Attempt uint32 // Restart count of the container within its pod
+
+	// Image is the image as requested, e.g. "nginx:1.25", when the runtime
+	// records it, and ImageRef is the resolved image, usually
+	// "repo@sha256:..." but just an image ID on some runtimes.
+	Image    string
+	ImageRef string
+
+	Labels map[string]string
+}
+
+// Client calls the CRI runtime service on a unix socket.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client for the runtime listening on socket.
+func NewClient(socket string) *Client {
+	t := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	// gRPC needs HTTP/2, which on a plain socket means prior knowledge
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetUnencryptedHTTP2(true)
+	return &Client{http: &http.Client{Transport: t, Timeout: requestTimeout}}
+}
+
+// FindSocket returns the first of DefaultSockets that exists, or "" if none.
+func FindSocket() string {
+	for _, s := range DefaultSockets {
+		if fi, err := os.Stat(s); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return s
+		}
+	}
+	return ""
+}
+
+// ContainerStatus returns the identity of the container with the given
+// full runtime ID.
+func (c *Client) ContainerStatus(ctx context.Context, id string) (*Container, error) {
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	req = protowire.AppendString(req, id)
+
+	resp, err := c.call(ctx, containerStatusMethod, req)
+	if err != nil {
+		return nil, fmt.Errorf("container status for %s: %w", id, err)
+	}
+	// ContainerStatusResponse: status = 1
+	status, ok := field(resp, 1)
+	if !ok {
+		return nil, fmt.Errorf("container status for %s: empty response", id)
+	}
+	return parseContainerStatus(status)
+}
+
+// call makes a unary gRPC call and returns the response message.
+func (c *Client) call(ctx context.Context, method string, msg []byte) ([]byte, error) {
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	var prefix [5]byte
+	_, readErr := io.ReadFull(resp.Body, prefix[:])
+	var data []byte
+	if readErr == nil {
+		if prefix[0] != 0 {
+			return nil, errors.New("compressed responses are not supported")
+		}
+		size := binary.BigEndian.Uint32(prefix[1:])
+		if size > maxMessageSize {
+			return nil, fmt.Errorf("response of %d bytes exceeds limit", size)
+		}
+		data = make([]byte, size)
+		if _, readErr = io.ReadFull(resp.Body, data); readErr != nil {
+			return nil, fmt.Errorf("reading response: %w", readErr)
+		}
+	}
+	// Trailers are only populated once the body is drained
+	io.Copy(io.Discard, resp.Body)
+
+	// Errors without a message are sent as headers only ("Trailers-Only")
+	code, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if code != "" && code != "0" {
+		return nil, &StatusError{Code: code, Message: message}
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("reading response: %w", readErr)
+	}
+	return data, nil
+}
+
+// StatusError is a non-OK gRPC status returned by the runtime.
+type StatusError struct {
+	Code    string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", e.Code, e.Message)
+}
+
+// NotFound reports whether err means the runtime doesn't know the
+// container (gRPC code 5).
+func NotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == "5"
+}
+
+// parseContainerStatus decodes a runtime.v1.ContainerStatus message.
+func parseContainerStatus(b []byte) (*Container, error) {
+	c := &Container{Labels: make(map[string]string)}
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			c.ID = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			// ContainerMetadata: name = 1, attempt = 2
+			fields(v, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					c.Name = string(v)
+				case num == 2 && typ == protowire.VarintType:
+					c.Attempt = uint32(x)
+				}
+			})
+		case num == 8 && typ == protowire.BytesType:
+			// ImageSpec: image = 1, user_specified_image = 18
+			var image, userSpecified string
+			fields(v, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					image = string(v)
+				case num == 18 && typ == protowire.BytesType:
+					userSpecified = string(v)
+				}
+			})
+			c.Image = userSpecified
+			if c.Image == "" && !strings.HasPrefix(image, "sha256:") {
+				c.Image = image
+			}
+		case num == 9 && typ == protowire.BytesType:
+			c.ImageRef = string(v)
+		case num == 12 && typ == protowire.BytesType:
+			// map<string, string> entry: key = 1, value = 2
+			var key, value string
+			fields(v, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					key = string(v)
+				case num == 2 && typ == protowire.BytesType:
+					value = string(v)
+				}
+			})
+			c.Labels[key] = value
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding container status: %w", err)
+	}
+	return c, nil
+}
+
+// fields calls fn for each varint and length-delimited field in b,
+// skipping fields of other types.
+func fields(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, x uint64)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			x, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, typ, nil, x)
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, typ, v, 0)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// field returns the first length-delimited field num in b.
+func field(b []byte, num protowire.Number) ([]byte, bool) {
+	var found []byte
+	var ok bool
+	fields(b, func(n protowire.Number, typ protowire.Type, v []byte, _ uint64) {
+		if n == num && typ == protowire.BytesType && !ok {
+			found, ok = v, true
+		}
+	})
+	return found, ok
+}
@@ -0,0 +1,108 @@
+package cri
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// containerStatusResponse encodes a ContainerStatusResponse like containerd's.
+func containerStatusResponse(id string) []byte {
+	var metadata []byte
+	metadata = appendString(metadata, 1, "nginx")
+	metadata = protowire.AppendTag(metadata, 2, protowire.VarintType)
+	metadata = protowire.AppendVarint(metadata, 3)
+
+	var image []byte
+	image = appendString(image, 1, "sha256:configdigest")
+	image = appendString(image, 18, "nginx:1.25")
+
+	var label []byte
+	label = appendString(label, 1, LabelPodName)
+	label = appendString(label, 2, "web-0")
+
+	var status []byte
+	status = appendString(status, 1, id)
+	status = appendMessage(status, 2, metadata)
+	status = protowire.AppendTag(status, 7, protowire.VarintType) // exit_code
+	status = protowire.AppendVarint(status, 0)
+	status = appendMessage(status, 8, image)
+	status = appendString(status, 9, "docker.io/library/nginx@sha256:abc")
+	status = appendMessage(status, 12, label)
+
+	return appendMessage(nil, 1, status)
+}
+
+func newRuntime(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "cri.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Listener = l
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return NewClient(socket)
+}
+
+func TestContainerStatus(t *testing.T) {
+	c := newRuntime(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != containerStatusMethod || r.Header.Get("Content-Type") != "application/grpc" {
+			t.Errorf("request = %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		req, _ := field(body[5:], 1)
+		if string(req) == "missing" {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "not found")
+			return
+		}
+
+		msg := containerStatusResponse(string(req))
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", "application/grpc")
+		prefix := make([]byte, 5)
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+		w.Write(append(prefix, msg...))
+		w.Header().Set("Grpc-Status", "0")
+	})
+
+	got, err := c.ContainerStatus(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("ContainerStatus failed: %v", err)
+	}
+	if got.ID != "abc123" || got.Name != "nginx" || got.Attempt != 3 {
+		t.Errorf("identity = %+v", got)
+	}
+	if got.Image != "nginx:1.25" || got.ImageRef != "docker.io/library/nginx@sha256:abc" {
+		t.Errorf("image = %q, %q", got.Image, got.ImageRef)
+	}
+	if got.Labels[LabelPodName] != "web-0" {
+		t.Errorf("labels = %v", got.Labels)
+	}
+
+	if _, err := c.ContainerStatus(context.Background(), "missing"); !NotFound(err) {
+		t.Errorf("ContainerStatus(missing) error = %v, want not found", err)
+	}
+}
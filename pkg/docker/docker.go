@@ -0,0 +1,231 @@
+// Package docker is a minimal Docker Engine API client for tracing
+// containers on a Docker host outside Kubernetes: listing and inspecting
+// running containers and watching for them to start and stop.
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSocket is where the Docker daemon listens by default.
+	DefaultSocket = "/var/run/docker.sock"
+
+	// apiVersion is the Engine API version requested; 1.41 is Docker 20.10.
+	apiVersion = "v1.41"
+
+	// requestTimeout bounds each non-streaming request.
+	requestTimeout = 10 * time.Second
+
+	// LabelComposeProject and LabelComposeService are the labels Docker
+	// Compose puts on the containers it creates, naming their project and
+	// service. LabelComposeNumber is the replica number within the service.
+	LabelComposeProject = "com.docker.compose.project"
+	LabelComposeService = "com.docker.compose.service"
+	LabelComposeNumber  = "com.docker.compose.container-number"
+)
+
+// Container is a running container as the Docker daemon describes it.
+type Container struct {
+	ID          string
+	Name        string // Without the leading "/"
+	Image       string // Image as requested, e.g. "nginx:1.25"
+	ImageDigest string // Repository digest, e.g. "sha256:abc...", if the image was pulled
+	PID         int    // Host PID of the container's init process
+	Rootfs      string // Merged overlay directory on the host, if the storage driver has one
+	Labels      map[string]string
+}
+
+// Event is a container lifecycle event.
+type Event struct {
+	Action      string // e.g. "start", "die"
+	ContainerID string
+}
+
+// Client talks to the Docker daemon on a unix socket.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client for the daemon listening on socket.
+func NewClient(socket string) *Client {
+	return &Client{http: &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}}
+}
+
+// get performs a GET request against the daemon and returns the response
+// body, which the caller must close.
+func (c *Client) get(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/"+apiVersion+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp.Body, nil
+}
+
+// getJSON performs a GET request and decodes the JSON response into v.
+func (c *Client) getJSON(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// List returns the running containers. If labels are given, as "key" or
+// "key=value", only containers with all of them are returned.
+func (c *Client) List(ctx context.Context, labels ...string) ([]Container, error) {
+	var summaries []struct {
+		ID string `json:"Id"`
+	}
+	path := "/containers/json"
+	if len(labels) > 0 {
+		path += "?filters=" + labelFilters(nil, labels)
+	}
+	if err := c.getJSON(ctx, path, &summaries); err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	containers := make([]Container, 0, len(summaries))
+	for _, s := range summaries {
+		ctr, err := c.Inspect(ctx, s.ID)
+		if err != nil {
+			// The container may have exited since it was listed
+			continue
+		}
+		if ctr.PID != 0 {
+			containers = append(containers, *ctr)
+		}
+	}
+	return containers, nil
+}
+
+// Inspect returns a container by ID or name.
+func (c *Client) Inspect(ctx context.Context, id string) (*Container, error) {
+	var info struct {
+		ID     string `json:"Id"`
+		Name   string `json:"Name"`
+		Image  string `json:"Image"`
+		Config struct {
+			Image  string            `json:"Image"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+		State struct {
+			Pid int `json:"Pid"`
+		} `json:"State"`
+		GraphDriver struct {
+			Data map[string]string `json:"Data"`
+		} `json:"GraphDriver"`
+	}
+	if err := c.getJSON(ctx, "/containers/"+url.PathEscape(id)+"/json", &info); err != nil {
+		return nil, fmt.Errorf("inspecting container %s: %w", id, err)
+	}
+	ctr := &Container{
+		ID:     info.ID,
+		Name:   strings.TrimPrefix(info.Name, "/"),
+		Image:  info.Config.Image,
+		PID:    info.State.Pid,
+		Rootfs: info.GraphDriver.Data["MergedDir"],
+		Labels: info.Config.Labels,
+	}
+
+	// The container only records the image ID; its repository digest is on
+	// the image, and only for pulled images
+	var img struct {
+		RepoDigests []string `json:"RepoDigests"`
+	}
+	if err := c.getJSON(ctx, "/images/"+url.PathEscape(info.Image)+"/json", &img); err == nil {
+		for _, rd := range img.RepoDigests {
+			if _, digest, ok := strings.Cut(rd, "@"); ok {
+				ctr.ImageDigest = digest
+				break
+			}
+		}
+	}
+	return ctr, nil
+}
+
+// Events streams container start and die events until ctx is done or the
+// connection fails, optionally only for containers with all of labels, as
+// for List. The channel is closed when the stream ends; the returned
+// function reports why.
+func (c *Client) Events(ctx context.Context, labels ...string) (<-chan Event, func() error, error) {
+	filters := labelFilters(map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "die"},
+	}, labels)
+	body, err := c.get(ctx, "/events?filters="+filters)
+	if err != nil {
+		return nil, nil, fmt.Errorf("watching events: %w", err)
+	}
+
+	ch := make(chan Event)
+	var streamErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(ch)
+		defer body.Close()
+		sc := bufio.NewScanner(body)
+		for sc.Scan() {
+			var msg struct {
+				Action string `json:"Action"`
+				Actor  struct {
+					ID string `json:"ID"`
+				} `json:"Actor"`
+			}
+			if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+				continue
+			}
+			select {
+			case ch <- Event{Action: msg.Action, ContainerID: msg.Actor.ID}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if ctx.Err() == nil {
+			streamErr = sc.Err()
+		}
+	}()
+	return ch, func() error { <-done; return streamErr }, nil
+}
+
+// labelFilters returns the query-escaped JSON filters argument combining
+// filters with label filters.
+func labelFilters(filters map[string][]string, labels []string) string {
+	if filters == nil {
+		filters = make(map[string][]string)
+	}
+	if len(labels) > 0 {
+		filters["label"] = labels
+	}
+	b, _ := json.Marshal(filters)
+	return url.QueryEscape(string(b))
+}
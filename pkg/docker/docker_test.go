@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newDaemon(t *testing.T, mux *http.ServeMux) *Client {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return NewClient(socket)
+}
+
+func TestList(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1.41/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("filters"), `{"label":["com.docker.compose.project=shop"]}`; got != want {
+			t.Errorf("filters = %s, want %s", got, want)
+		}
+		fmt.Fprint(w, `[{"Id": "abc"}, {"Id": "gone"}]`)
+	})
+	mux.HandleFunc("GET /v1.41/containers/abc/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"Id": "abc", "Name": "/web", "Image": "sha256:config",
+			"Config": {"Image": "nginx:1.25", "Labels": {"com.docker.compose.service": "web"}},
+			"State": {"Pid": 4242},
+			"GraphDriver": {"Name": "overlay2", "Data": {"MergedDir": "/var/lib/docker/overlay2/x/merged"}}
+		}`)
+	})
+	mux.HandleFunc("GET /v1.41/containers/gone/json", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "No such container: gone"}`, http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /v1.41/images/sha256:config/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"RepoDigests": ["nginx@sha256:abc"]}`)
+	})
+	c := newDaemon(t, mux)
+
+	got, err := c.List(context.Background(), LabelComposeProject+"=shop")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	want := Container{
+		ID:          "abc",
+		Name:        "web",
+		Image:       "nginx:1.25",
+		ImageDigest: "sha256:abc",
+		PID:         4242,
+		Rootfs:      "/var/lib/docker/overlay2/x/merged",
+		Labels:      map[string]string{LabelComposeService: "web"},
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("List = %+v, want [%+v]", got, want)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1.41/events", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Type": "container", "Action": "start", "Actor": {"ID": "abc"}}`)
+		fmt.Fprintln(w, `{"Type": "container", "Action": "die", "Actor": {"ID": "abc"}}`)
+	})
+	c := newDaemon(t, mux)
+
+	events, wait, err := c.Events(context.Background())
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	var got []Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+	if err := wait(); err != nil {
+		t.Errorf("stream error: %v", err)
+	}
+	if len(got) != 2 || got[0] != (Event{Action: "start", ContainerID: "abc"}) || got[1].Action != "die" {
+		t.Errorf("events = %+v", got)
+	}
+}
@@ -0,0 +1,227 @@
+// Package dpkg reads the installed package database of Debian-based
+// images for package attribution.
+package dpkg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+const (
+	// StatusPath is the dpkg status database, relative to the image root.
+	StatusPath = "/var/lib/dpkg/status"
+
+	// InfoDir holds per-package file lists (<pkg>.list or <pkg>:<arch>.list).
+	InfoDir = "/var/lib/dpkg/info"
+
+	// StatusDir is used by distroless images instead of StatusPath: one
+	// status stanza per file, with file lists in <pkg>.md5sums.
+	StatusDir = "/var/lib/dpkg/status.d"
+
+	// Manager identifies packages loaded by this package.
+	Manager = "dpkg"
+)
+
+// Entry is one package stanza from the dpkg status database.
+type Entry struct {
+	Package      string
+	Version      string
+	Architecture string
+	Status       string
+	Size         int64 // Installed-Size, converted from KiB to bytes
+}
+
+// Installed reports whether the entry describes an installed package, as
+// opposed to one that was removed but left config files behind.
+func (e Entry) Installed() bool {
+	fields := strings.Fields(e.Status)
+	return len(fields) == 3 && fields[2] == "installed"
+}
+
+// ParseStatus parses a dpkg status file, which is a sequence of RFC 822
+// style stanzas separated by blank lines.
+func ParseStatus(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var cur Entry
+	flush := func() {
+		if cur.Package != "" {
+			entries = append(entries, cur)
+		}
+		cur = Entry{}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		// Continuation lines (long descriptions, conffiles) start with whitespace
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Package":
+			cur.Package = value
+		case "Version":
+			cur.Version = value
+		case "Architecture":
+			cur.Architecture = value
+		case "Status":
+			cur.Status = value
+		case "Installed-Size":
+			if kib, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.Size = kib * 1024
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading dpkg status: %w", err)
+	}
+	flush()
+	return entries, nil
+}
+
+// Load reads installed packages and their file lists from the dpkg database
+// under root. It implements packages.Loader.
+func Load(root string) ([]*packages.Package, error) {
+	f, err := os.Open(filepath.Join(root, StatusPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return loadStatusDir(root)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening dpkg status: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := ParseStatus(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*packages.Package
+	for _, e := range entries {
+		if !e.Installed() {
+			continue
+		}
+		files, err := readList(root, e)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, &packages.Package{
+			Name:    e.Package,
+			Version: e.Version,
+			Manager: Manager,
+			Size:    e.Size,
+			Files:   packages.FilesOnly(files),
+		})
+	}
+	return pkgs, nil
+}
+
+// readList reads a package's file list. Multi-Arch: same packages use
+// <pkg>:<arch>.list; others use <pkg>.list. A missing list is not an error,
+// since some packages (e.g. metapackages) own no files.
+func readList(root string, e Entry) ([]string, error) {
+	candidates := []string{e.Package + ".list"}
+	if e.Architecture != "" {
+		candidates = append([]string{e.Package + ":" + e.Architecture + ".list"}, candidates...)
+	}
+	for _, name := range candidates {
+		data, err := os.ReadFile(filepath.Join(root, InfoDir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading dpkg file list for %s: %w", e.Package, err)
+		}
+		lines := parseLines(string(data))
+		for i, line := range lines {
+			// The first entry is "/." for the root directory
+			lines[i] = filepath.Clean(line)
+		}
+		return lines, nil
+	}
+	return nil, nil
+}
+
+// loadStatusDir reads the distroless layout: status.d/<pkg> stanzas with
+// file lists in status.d/<pkg>.md5sums.
+func loadStatusDir(root string) ([]*packages.Package, error) {
+	dir := filepath.Join(root, StatusDir)
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading dpkg status: %w", err)
+	}
+
+	var pkgs []*packages.Package
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() || strings.Contains(name, ".") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", name, err)
+		}
+		entries, err := ParseStatus(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+
+		var files []string
+		if data, err := os.ReadFile(filepath.Join(dir, name+".md5sums")); err == nil {
+			for _, line := range parseLines(string(data)) {
+				// "<md5>  <path relative to root>"
+				if _, p, ok := strings.Cut(line, "  "); ok {
+					files = append(files, "/"+strings.TrimPrefix(p, "/"))
+				}
+			}
+		}
+		sort.Strings(files)
+
+		for _, e := range entries {
+			// Distroless stanzas often omit Status; treat them as installed
+			if e.Status != "" && !e.Installed() {
+				continue
+			}
+			pkgs = append(pkgs, &packages.Package{
+				Name:    e.Package,
+				Version: e.Version,
+				Manager: Manager,
+				Size:    e.Size,
+				Files:   files,
+			})
+		}
+	}
+	return pkgs, nil
+}
+
+// parseLines splits data into non-empty lines.
+func parseLines(data string) []string {
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
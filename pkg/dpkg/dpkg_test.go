@@ -0,0 +1,114 @@
+package dpkg
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testStatus = `Package: libc6
+Status: install ok installed
+Priority: optional
+Architecture: amd64
+Multi-Arch: same
+Installed-Size: 12986
+Version: 2.36-9+deb12u4
+Description: GNU C Library: Shared libraries
+ Contains the standard libraries that are used by nearly all programs on
+ the system.
+
+Package: curl
+Status: install ok installed
+Architecture: amd64
+Version: 7.88.1-10+deb12u5
+Depends: libc6 (>= 2.34), libcurl4 (= 7.88.1-10+deb12u5)
+
+Package: oldpkg
+Status: deinstall ok config-files
+Architecture: all
+Version: 1.0
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	entries, err := ParseStatus(strings.NewReader(testStatus))
+	if err != nil {
+		t.Fatalf("ParseStatus failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(entries), entries)
+	}
+
+	want := Entry{Package: "libc6", Version: "2.36-9+deb12u4", Architecture: "amd64", Status: "install ok installed", Size: 12986 * 1024}
+	if entries[0] != want {
+		t.Errorf("entries[0] = %+v, want %+v", entries[0], want)
+	}
+	if !entries[1].Installed() {
+		t.Errorf("curl should be installed")
+	}
+	if entries[2].Installed() {
+		t.Errorf("oldpkg should not be installed")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, StatusPath), testStatus)
+	writeFile(t, filepath.Join(root, InfoDir, "libc6:amd64.list"), "/.\n/lib\n/lib/x86_64-linux-gnu\n/lib/x86_64-linux-gnu/libc.so.6\n/lib/x86_64-linux-gnu/libm.so.6\n")
+	writeFile(t, filepath.Join(root, InfoDir, "curl.list"), "/.\n/usr\n/usr/bin\n/usr/bin/curl\n/usr/share/doc/curl/copyright\n")
+
+	pkgs, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d packages, want 2 (removed package skipped)", len(pkgs))
+	}
+
+	libc := pkgs[0]
+	if libc.Name != "libc6" || libc.Manager != Manager {
+		t.Errorf("pkgs[0] = %+v", libc)
+	}
+	if want := []string{"/lib/x86_64-linux-gnu/libc.so.6", "/lib/x86_64-linux-gnu/libm.so.6"}; !slices.Equal(libc.Files, want) {
+		t.Errorf("libc6 files = %v, want %v (directories removed)", libc.Files, want)
+	}
+	if want := []string{"/usr/bin/curl", "/usr/share/doc/curl/copyright"}; !slices.Equal(pkgs[1].Files, want) {
+		t.Errorf("curl files = %v, want %v", pkgs[1].Files, want)
+	}
+}
+
+func TestLoadStatusDir(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, StatusDir, "base-files"), "Package: base-files\nVersion: 12.4+deb12u5\nArchitecture: amd64\n")
+	writeFile(t, filepath.Join(root, StatusDir, "base-files.md5sums"), "d41d8cd98f00b204e9800998ecf8427e  etc/debian_version\n0123456789abcdef0123456789abcdef  usr/lib/os-release\n")
+
+	pkgs, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Name != "base-files" {
+		t.Fatalf("pkgs = %+v, want base-files", pkgs)
+	}
+	if want := []string{"/etc/debian_version", "/usr/lib/os-release"}; !slices.Equal(pkgs[0].Files, want) {
+		t.Errorf("files = %v, want %v", pkgs[0].Files, want)
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	if _, err := Load(t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load on empty root error = %v, want not-exist", err)
+	}
+}
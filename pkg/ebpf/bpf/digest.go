@@ -0,0 +1,15 @@
+//go:build 386 || amd64 || arm64
+
+package bpf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ObjectDigest returns the sha256 digest of the embedded eBPF object, which
+// identifies the probe a binary loads.
+func ObjectDigest() string {
+	sum := sha256.Sum256(_SnoopBytes)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -5,11 +5,15 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
+	"unsafe"
 
 	"github.com/chainguard-dev/clog"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/ringbuf"
 	"github.com/imjasonh/snoop/pkg/ebpf/bpf"
+	"github.com/imjasonh/snoop/pkg/sampling"
 )
 
 // Event represents a file access event from the eBPF program
@@ -23,6 +27,22 @@ type Event struct {
 const (
 	// eventHeaderSize is the fixed size of the event header (8 bytes cgroup_id + 4 bytes pid + 4 bytes syscall_nr)
 	eventHeaderSize = 16
+
+	// recordSize is the space one event takes in the ring buffer: the event,
+	// which is always submitted whole, after an 8-byte record header,
+	// rounded up to 8 bytes
+	recordSize = (8 + int(unsafe.Sizeof(bpf.SnoopEvent{})) + 7) &^ 7
+
+	// eventQueueSize is how many parsed events Events buffers for its
+	// receiver; the ring buffer holds the rest
+	eventQueueSize = 256
+
+	// maxReadErrors is how many ring buffer reads in a row may fail before
+	// Events gives up, waiting between them from minReadBackoff, doubling up
+	// to maxReadBackoff
+	maxReadErrors  = 10
+	minReadBackoff = 10 * time.Millisecond
+	maxReadBackoff = time.Second
 )
 
 // Probe manages the eBPF program lifecycle
@@ -30,6 +50,15 @@ type Probe struct {
 	objs   *bpf.SnoopObjects
 	links  []link.Link
 	reader *ringbuf.Reader
+
+	// readerClosed is set once reader is closed, after which its fill
+	// level can't be read; guarded by readerMu
+	readerMu     sync.RWMutex
+	readerClosed bool
+
+	// readErr is why Events stopped reading, if not because ctx was
+	// cancelled; it is set before the events channel is closed
+	readErr error
 }
 
 // NewProbe creates and loads the eBPF program
@@ -162,34 +191,100 @@ func (p *Probe) RemoveTracedCgroup(cgroupID uint64) error {
 	return p.objs.TracedCgroups.Delete(&cgroupID)
 }
 
-// ReadEvent reads one event from the ring buffer
-// It respects the context and will return ctx.Err() if the context is cancelled
-func (p *Probe) ReadEvent(ctx context.Context) (*Event, error) {
-	// Use a goroutine + channel pattern to make Read() cancellable
-	type result struct {
-		record ringbuf.Record
-		err    error
-	}
-	ch := make(chan result, 1)
-
+// Events reads events from the ring buffer on a dedicated goroutine and
+// sends them on the returned channel, so that the receiver can wait for
+// events alongside timers and signals. When ctx is cancelled the ring buffer
+// is closed, which unblocks the pending read, and the channel is closed.
+// Records that fail to parse are logged and skipped. Failed reads are
+// retried with backoff, and after maxReadErrors in a row the channel is
+// closed, with Err reporting why.
+//
+// If sampler is set, it is adjusted to how full the ring buffer is, and only
+// the events it keeps are sent. Skipped events are still read, which is
+// cheap, but not processed, which is what falls behind. Exec events are
+// never skipped.
+func (p *Probe) Events(ctx context.Context, sampler *sampling.Sampler) <-chan *Event {
+	log := clog.FromContext(ctx)
+	events := make(chan *Event, eventQueueSize)
 	go func() {
-		record, err := p.reader.Read()
-		ch <- result{record, err}
-	}()
-
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case res := <-ch:
-		if res.err != nil {
-			if errors.Is(res.err, ringbuf.ErrClosed) {
-				return nil, res.err
+		defer close(events)
+		stop := context.AfterFunc(ctx, func() {
+			if err := p.closeReader(); err != nil {
+				log.Warnf("Closing ring buffer: %v", err)
+			}
+		})
+		defer stop()
+
+		failures, backoff := 0, minReadBackoff
+		for {
+			record, err := p.reader.Read()
+			if errors.Is(err, ringbuf.ErrClosed) {
+				return
+			}
+			if err != nil {
+				failures++
+				if failures >= maxReadErrors {
+					p.readErr = fmt.Errorf("reading from ring buffer failed %d times in a row: %w", failures, err)
+					return
+				}
+				log.Errorf("Error reading from ring buffer, retrying in %s: %v", backoff, err)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return
+				}
+				backoff = min(backoff*2, maxReadBackoff)
+				continue
+			}
+			failures, backoff = 0, minReadBackoff
+			event, err := parseEvent(record)
+			if err != nil {
+				log.Errorf("Error parsing event: %v", err)
+				continue
+			}
+			if sampler != nil {
+				if rate, changed := sampler.Adjust(p.pressure); changed && rate > 1 {
+					log.Warnf("Event backpressure: processing 1 in %d events", rate)
+				} else if changed {
+					log.Info("Event backpressure relieved: processing every event")
+				}
+				if !isExec(event.SyscallNr) && !sampler.Keep() {
+					continue
+				}
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
 			}
-			return nil, fmt.Errorf("reading from ring buffer: %w", res.err)
 		}
+	}()
+	return events
+}
+
+// Err returns why the channel returned by Events was closed, or nil if it
+// was closed because its context was cancelled. It must only be called once
+// the channel is closed.
+func (p *Probe) Err() error {
+	return p.readErr
+}
 
-		return parseEvent(res.record)
+// pressure returns the fraction of the ring buffer in use.
+func (p *Probe) pressure() float64 {
+	size := p.BufferSize()
+	if size == 0 {
+		return 0
 	}
+	return float64(p.PendingBytes()) / float64(size)
+}
+
+// closeReader closes the ring buffer reader, unblocking any pending read.
+// It is safe to call more than once.
+func (p *Probe) closeReader() error {
+	p.readerMu.Lock()
+	defer p.readerMu.Unlock()
+	p.readerClosed = true
+	return p.reader.Close()
 }
 
 // parseEvent parses a raw ring buffer record into an Event
@@ -231,12 +326,34 @@ func (p *Probe) Drops() (uint64, error) {
 	return drops, nil
 }
 
+// BufferSize returns the size of the ring buffer in bytes.
+func (p *Probe) BufferSize() int {
+	return p.reader.BufferSize()
+}
+
+// PendingBytes returns the number of bytes written to the ring buffer but
+// not read yet. Events are dropped once it reaches BufferSize.
+func (p *Probe) PendingBytes() int {
+	p.readerMu.RLock()
+	defer p.readerMu.RUnlock()
+	if p.readerClosed {
+		return 0
+	}
+	return p.reader.AvailableBytes()
+}
+
+// Backlog returns the number of events in the ring buffer waiting to be
+// read.
+func (p *Probe) Backlog() int {
+	return p.PendingBytes() / recordSize
+}
+
 // Close cleans up all resources
 func (p *Probe) Close() error {
 	var errs []error
 
 	if p.reader != nil {
-		if err := p.reader.Close(); err != nil {
+		if err := p.closeReader(); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -0,0 +1,31 @@
+package ebpf
+
+import "golang.org/x/sys/unix"
+
+// syscallNames names the syscalls the probe traces, by their numbers on
+// this architecture.
+var syscallNames = map[uint32]string{
+	unix.SYS_OPENAT:     "openat",
+	unix.SYS_OPENAT2:    "openat2",
+	unix.SYS_EXECVE:     "execve",
+	unix.SYS_EXECVEAT:   "execveat",
+	unix.SYS_NEWFSTATAT: "newfstatat",
+	unix.SYS_STATX:      "statx",
+	unix.SYS_FACCESSAT:  "faccessat",
+	unix.SYS_FACCESSAT2: "faccessat2",
+	unix.SYS_READLINKAT: "readlinkat",
+}
+
+// SyscallName returns the name of a traced syscall, such as "openat", from
+// an Event's SyscallNr, or "unknown" for syscalls the probe doesn't trace.
+func SyscallName(nr uint32) string {
+	if name, ok := syscallNames[nr]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// isExec reports whether a syscall number is execve or execveat.
+func isExec(nr uint32) bool {
+	return nr == unix.SYS_EXECVE || nr == unix.SYS_EXECVEAT
+}
@@ -15,6 +15,13 @@ type Checker struct {
 	lastEventReceived time.Time
 	lastReportWritten time.Time
 	startTime         time.Time
+
+	// Container discovery: total scans, consecutive failures, and the
+	// outcome of the latest scan
+	discoveryAttempts int
+	discoveryFailures int
+	lastDiscovery     time.Time
+	discoveryErr      error
 }
 
 // New creates a new health checker.
@@ -45,6 +52,21 @@ func (c *Checker) RecordReportWritten() {
 	c.lastReportWritten = time.Now()
 }
 
+// RecordDiscovery records the outcome of a container discovery scan, err
+// being nil if it succeeded.
+func (c *Checker) RecordDiscovery(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.discoveryAttempts++
+	c.lastDiscovery = time.Now()
+	c.discoveryErr = err
+	if err != nil {
+		c.discoveryFailures++
+	} else {
+		c.discoveryFailures = 0
+	}
+}
+
 // Status represents the current health status.
 type Status struct {
 	Healthy            bool    `json:"healthy"`
@@ -54,6 +76,10 @@ type Status struct {
 	LastReportWritten  string  `json:"last_report_written,omitempty"`
 	SecondsSinceEvent  float64 `json:"seconds_since_event,omitempty"`
 	SecondsSinceReport float64 `json:"seconds_since_report,omitempty"`
+	DiscoveryAttempts  int     `json:"discovery_attempts,omitempty"`
+	DiscoveryFailures  int     `json:"discovery_failures,omitempty"` // Consecutive failed scans
+	LastDiscovery      string  `json:"last_discovery,omitempty"`
+	DiscoveryError     string  `json:"discovery_error,omitempty"`
 	Message            string  `json:"message,omitempty"`
 }
 
@@ -62,6 +88,8 @@ type Status struct {
 // - eBPF program is loaded
 // - Events have been received (or it's been less than 5 minutes since start)
 // - Reports have been written (or it's been less than 5 minutes since start)
+// Failing container discovery is reported in the message but doesn't make
+// the service unhealthy, since containers already traced still are.
 func (c *Checker) Check() Status {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -73,6 +101,15 @@ func (c *Checker) Check() Status {
 		Healthy:    true,
 		Uptime:     uptime.Round(time.Second).String(),
 		EBPFLoaded: c.ebpfLoaded,
+
+		DiscoveryAttempts: c.discoveryAttempts,
+		DiscoveryFailures: c.discoveryFailures,
+	}
+	if !c.lastDiscovery.IsZero() {
+		status.LastDiscovery = c.lastDiscovery.Format(time.RFC3339)
+	}
+	if c.discoveryErr != nil {
+		status.DiscoveryError = c.discoveryErr.Error()
 	}
 
 	// Check eBPF loaded
@@ -97,6 +134,13 @@ func (c *Checker) Check() Status {
 		status.Message = "no events received yet (check cgroup filter)"
 	}
 
+	if c.discoveryFailures > 0 {
+		if status.Message != "" {
+			status.Message += "; "
+		}
+		status.Message += "container discovery failing"
+	}
+
 	// Check report writes
 	if !c.lastReportWritten.IsZero() {
 		timeSinceReport := now.Sub(c.lastReportWritten)
@@ -2,6 +2,7 @@ package health
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -68,6 +69,30 @@ func TestHealthChecker(t *testing.T) {
 			wantHealthy: true, // Still healthy, just a warning
 			wantMessage: "no events received recently (check cgroup filter)",
 		},
+		{
+			desc: "warning when container discovery is failing",
+			setup: func(c *Checker) {
+				c.SetEBPFLoaded()
+				c.RecordEventReceived()
+				c.RecordReportWritten()
+				c.RecordDiscovery(nil)
+				c.RecordDiscovery(errors.New("permission denied"))
+			},
+			wantHealthy: true, // Already traced containers still are
+			wantMessage: "container discovery failing",
+		},
+		{
+			desc: "healthy once container discovery recovers",
+			setup: func(c *Checker) {
+				c.SetEBPFLoaded()
+				c.RecordEventReceived()
+				c.RecordReportWritten()
+				c.RecordDiscovery(errors.New("permission denied"))
+				c.RecordDiscovery(nil)
+			},
+			wantHealthy: true,
+			wantMessage: "",
+		},
 	} {
 		t.Run(tt.desc, func(t *testing.T) {
 			c := New()
@@ -179,3 +204,29 @@ func TestHealthStatus(t *testing.T) {
 		t.Errorf("Expected non-negative SecondsSinceReport, got %f", status.SecondsSinceReport)
 	}
 }
+
+func TestHealthStatusDiscovery(t *testing.T) {
+	c := New()
+	c.SetEBPFLoaded()
+	if status := c.Check(); status.DiscoveryAttempts != 0 || status.LastDiscovery != "" {
+		t.Errorf("Expected no discovery fields before any scan, got %+v", status)
+	}
+
+	c.RecordDiscovery(nil)
+	c.RecordDiscovery(errors.New("timed out"))
+	c.RecordDiscovery(errors.New("permission denied"))
+
+	status := c.Check()
+	if status.DiscoveryAttempts != 3 {
+		t.Errorf("DiscoveryAttempts = %d, want 3", status.DiscoveryAttempts)
+	}
+	if status.DiscoveryFailures != 2 {
+		t.Errorf("DiscoveryFailures = %d, want 2", status.DiscoveryFailures)
+	}
+	if status.DiscoveryError != "permission denied" {
+		t.Errorf("DiscoveryError = %q, want the latest error", status.DiscoveryError)
+	}
+	if status.LastDiscovery == "" {
+		t.Error("Expected LastDiscovery to be set")
+	}
+}
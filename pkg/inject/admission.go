@@ -0,0 +1,36 @@
+package inject
+
+import "encoding/json"
+
+// AdmissionReview is the subset of an admission.k8s.io/v1 AdmissionReview
+// that the injector uses, sent by the API server to mutating webhooks.
+type AdmissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *AdmissionRequest  `json:"request,omitempty"`
+	Response   *AdmissionResponse `json:"response,omitempty"`
+}
+
+// AdmissionRequest describes the object being admitted.
+type AdmissionRequest struct {
+	UID       string          `json:"uid"`
+	Namespace string          `json:"namespace"`
+	Operation string          `json:"operation"`
+	Object    json.RawMessage `json:"object"`
+}
+
+// AdmissionResponse allows the object, with Patch applied if set.
+type AdmissionResponse struct {
+	UID       string   `json:"uid"`
+	Allowed   bool     `json:"allowed"`
+	PatchType string   `json:"patchType,omitempty"`
+	Patch     []byte   `json:"patch,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// PatchOperation is one RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
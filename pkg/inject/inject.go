@@ -0,0 +1,283 @@
+// Package inject implements a mutating admission webhook that adds the snoop
+// sidecar to pods, so workloads can be traced without editing their
+// manifests.
+package inject
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/kube"
+)
+
+const (
+	// AnnotationInject on a pod set to "true" or "false" forces injection
+	// on or off. On a namespace set to "true", it injects every pod in the
+	// namespace that doesn't opt out.
+	AnnotationInject = "snoop.dev/inject"
+
+	// AnnotationArgs on a pod adds whitespace-separated snoop flags to its
+	// sidecar, e.g. "-packages -trace-containers=app".
+	AnnotationArgs = "snoop.dev/args"
+
+	// AnnotationInjected marks pods the sidecar was added to.
+	AnnotationInjected = "snoop.dev/injected"
+
+	// ContainerName is the name of the injected sidecar container.
+	ContainerName = "snoop"
+
+	// DefaultImage is the snoop image injected by default.
+	DefaultImage = "ghcr.io/imjasonh/snoop:latest"
+
+	// ReportPath is where the sidecar writes its report, on an emptyDir
+	// volume named snoop-data.
+	ReportPath = "/data/snoop-report.json"
+)
+
+// Injector decides which pods get the snoop sidecar and builds the patch
+// adding it.
+type Injector struct {
+	// Image is the snoop image the sidecar runs.
+	Image string
+
+	// Args are snoop flags added to every sidecar, after the report path.
+	Args []string
+
+	// Selector selects pods to inject by their labels.
+	Selector Selector
+
+	// Namespace returns the metadata of a namespace, to check for the
+	// namespace-wide AnnotationInject. If nil, namespaces aren't checked.
+	Namespace func(ctx context.Context, name string) (*kube.ObjectMeta, error)
+}
+
+// pod is the subset of a pod the injector reads.
+type pod struct {
+	Metadata kube.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Containers []kube.Container  `json:"containers"`
+		Volumes    []json.RawMessage `json:"volumes,omitempty"`
+	} `json:"spec"`
+}
+
+// Mutate returns the response to an admission request for a pod: allowed,
+// with a patch adding the sidecar if the pod should be traced. Pods are
+// always allowed, even when the injector fails, so that it can't block
+// workloads from starting.
+func (i *Injector) Mutate(ctx context.Context, req *AdmissionRequest) *AdmissionResponse {
+	log := clog.FromContext(ctx)
+	resp := &AdmissionResponse{UID: req.UID, Allowed: true}
+
+	var p pod
+	if err := json.Unmarshal(req.Object, &p); err != nil {
+		log.Warnf("Decoding pod: %v", err)
+		resp.Warnings = []string{fmt.Sprintf("snoop sidecar not injected: decoding pod: %v", err)}
+		return resp
+	}
+	// Pods created by controllers have no name yet, only a generateName
+	name := p.Metadata.Name
+	if name == "" {
+		name = p.Metadata.GenerateName + "*"
+	}
+
+	inject, err := i.shouldInject(ctx, &p, req.Namespace)
+	if err != nil {
+		log.Warnf("Checking whether to inject pod %s/%s: %v", req.Namespace, name, err)
+		resp.Warnings = []string{fmt.Sprintf("snoop sidecar not injected: %v", err)}
+		return resp
+	}
+	if !inject {
+		return resp
+	}
+
+	patch, err := json.Marshal(i.patch(&p))
+	if err != nil {
+		log.Errorf("Encoding patch for pod %s/%s: %v", req.Namespace, name, err)
+		return resp
+	}
+	log.Infof("Injecting snoop sidecar into pod %s/%s", req.Namespace, name)
+	resp.PatchType = "JSONPatch"
+	resp.Patch = patch
+	return resp
+}
+
+// shouldInject reports whether the sidecar should be added to p, created
+// in namespace.
+func (i *Injector) shouldInject(ctx context.Context, p *pod, namespace string) (bool, error) {
+	if p.Metadata.Annotations[AnnotationInjected] != "" {
+		return false, nil
+	}
+	for _, c := range p.Spec.Containers {
+		if c.Name == ContainerName {
+			return false, nil
+		}
+	}
+	switch p.Metadata.Annotations[AnnotationInject] {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if i.Selector.Matches(p.Metadata.Labels) {
+		return true, nil
+	}
+	if i.Namespace == nil {
+		return false, nil
+	}
+	ns, err := i.Namespace(ctx, namespace)
+	if err != nil {
+		return false, fmt.Errorf("reading namespace %s: %w", namespace, err)
+	}
+	return ns.Annotations[AnnotationInject] == "true", nil
+}
+
+// patch returns the JSON Patch adding the sidecar, its volumes, and the
+// injected annotation to p.
+func (i *Injector) patch(p *pod) []PatchOperation {
+	ops := []PatchOperation{{Op: "add", Path: "/spec/containers/-", Value: i.sidecar(p)}}
+
+	vols := volumes()
+	if len(p.Spec.Volumes) == 0 {
+		ops = append(ops, PatchOperation{Op: "add", Path: "/spec/volumes", Value: vols})
+	} else {
+		for _, v := range vols {
+			ops = append(ops, PatchOperation{Op: "add", Path: "/spec/volumes/-", Value: v})
+		}
+	}
+
+	if p.Metadata.Annotations == nil {
+		ops = append(ops, PatchOperation{Op: "add", Path: "/metadata/annotations", Value: map[string]string{AnnotationInjected: "true"}})
+	} else {
+		ops = append(ops, PatchOperation{Op: "add", Path: "/metadata/annotations/" + escapePointer(AnnotationInjected), Value: "true"})
+	}
+	return ops
+}
+
+// sidecar returns the snoop container spec for p, like the sidecar in
+// deploy/kubernetes/deployment.yaml.
+func (i *Injector) sidecar(p *pod) map[string]any {
+	image := i.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	args := append([]string{"-report=" + ReportPath}, i.Args...)
+	args = append(args, strings.Fields(p.Metadata.Annotations[AnnotationArgs])...)
+
+	fieldEnv := func(name, field string) map[string]any {
+		return map[string]any{
+			"name":      name,
+			"valueFrom": map[string]any{"fieldRef": map[string]any{"fieldPath": field}},
+		}
+	}
+	return map[string]any{
+		"name":    ContainerName,
+		"image":   image,
+		"command": []string{"/usr/local/bin/snoop"},
+		"args":    args,
+		"env": []any{
+			fieldEnv("POD_NAME", "metadata.name"),
+			fieldEnv("POD_NAMESPACE", "metadata.namespace"),
+			fieldEnv("POD_UID", "metadata.uid"),
+			fieldEnv("NODE_NAME", "spec.nodeName"),
+		},
+		"securityContext": map[string]any{
+			"capabilities":           map[string]any{"add": []string{"SYS_ADMIN", "BPF", "PERFMON"}},
+			"readOnlyRootFilesystem": true,
+		},
+		"volumeMounts": []any{
+			map[string]any{"name": "snoop-data", "mountPath": "/data"},
+			map[string]any{"name": "snoop-cgroup", "mountPath": "/sys/fs/cgroup", "readOnly": true},
+			map[string]any{"name": "snoop-debugfs", "mountPath": "/sys/kernel/debug", "readOnly": true},
+			map[string]any{"name": "snoop-podinfo", "mountPath": kube.DefaultPodInfoDir, "readOnly": true},
+		},
+		"resources": map[string]any{
+			"requests": map[string]string{"cpu": "50m", "memory": "64Mi"},
+			"limits":   map[string]string{"cpu": "200m", "memory": "128Mi"},
+		},
+	}
+}
+
+// volumes returns the volumes the sidecar mounts.
+func volumes() []any {
+	hostPath := func(name, path string) map[string]any {
+		return map[string]any{"name": name, "hostPath": map[string]any{"path": path, "type": "Directory"}}
+	}
+	return []any{
+		map[string]any{"name": "snoop-data", "emptyDir": map[string]any{}},
+		hostPath("snoop-cgroup", "/sys/fs/cgroup"),
+		hostPath("snoop-debugfs", "/sys/kernel/debug"),
+		map[string]any{"name": "snoop-podinfo", "downwardAPI": map[string]any{"items": []any{
+			map[string]any{"path": "labels", "fieldRef": map[string]any{"fieldPath": "metadata.labels"}},
+		}}},
+	}
+}
+
+// escapePointer escapes a JSON Pointer reference token (RFC 6901).
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// ServeHTTP handles AdmissionReview requests from the API server.
+func (i *Injector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var review AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("decoding admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+	review.Response = i.Mutate(r.Context(), review.Request)
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// Selector is a label selector of equality ("key=value") and existence
+// ("key") requirements, all of which must match.
+type Selector []requirement
+
+type requirement struct {
+	key, value string
+	exists     bool // Only the key must be present
+}
+
+// ParseSelector parses a comma-separated label selector such as
+// "app=web,snoop". An empty string parses to a selector matching no pods.
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || strings.ContainsAny(key, "!=") || strings.Contains(value, "=") {
+			return nil, fmt.Errorf("invalid label selector requirement %q", part)
+		}
+		sel = append(sel, requirement{key: key, value: value, exists: !ok})
+	}
+	return sel, nil
+}
+
+// Matches reports whether labels satisfy every requirement. An empty
+// selector matches nothing, so that injection is always opted into.
+func (s Selector) Matches(labels map[string]string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, req := range s {
+		v, ok := labels[req.key]
+		if !ok || (!req.exists && v != req.value) {
+			return false
+		}
+	}
+	return true
+}
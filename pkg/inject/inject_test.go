@@ -0,0 +1,204 @@
+package inject
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/kube"
+)
+
+func TestParseSelector(t *testing.T) {
+	sel, err := ParseSelector("app=web, snoop")
+	if err != nil {
+		t.Fatalf("ParseSelector failed: %v", err)
+	}
+	for _, tt := range []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{labels: map[string]string{"app": "web", "snoop": ""}, want: true},
+		{labels: map[string]string{"app": "web", "snoop": "yes"}, want: true},
+		{labels: map[string]string{"app": "api", "snoop": ""}, want: false},
+		{labels: map[string]string{"app": "web"}, want: false},
+		{labels: nil, want: false},
+	} {
+		if got := sel.Matches(tt.labels); got != tt.want {
+			t.Errorf("Matches(%v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+
+	empty, err := ParseSelector("")
+	if err != nil || empty.Matches(map[string]string{"app": "web"}) {
+		t.Errorf("empty selector: err = %v, want matching nothing", err)
+	}
+	for _, bad := range []string{"=web", "app!=web", "a=b=c"} {
+		if _, err := ParseSelector(bad); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestShouldInject(t *testing.T) {
+	sel, _ := ParseSelector("snoop=enabled")
+	namespaces := map[string]map[string]string{
+		"traced": {AnnotationInject: "true"},
+		"plain":  nil,
+	}
+	i := &Injector{
+		Selector: sel,
+		Namespace: func(_ context.Context, name string) (*kube.ObjectMeta, error) {
+			annotations, ok := namespaces[name]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return &kube.ObjectMeta{Name: name, Annotations: annotations}, nil
+		},
+	}
+
+	for _, tt := range []struct {
+		desc      string
+		namespace string
+		pod       string
+		want      bool
+		wantErr   bool
+	}{{
+		desc:      "label selector",
+		namespace: "plain",
+		pod:       `{"metadata": {"labels": {"snoop": "enabled"}}, "spec": {"containers": [{"name": "app"}]}}`,
+		want:      true,
+	}, {
+		desc:      "pod annotation",
+		namespace: "plain",
+		pod:       `{"metadata": {"annotations": {"snoop.dev/inject": "true"}}, "spec": {"containers": [{"name": "app"}]}}`,
+		want:      true,
+	}, {
+		desc:      "namespace annotation",
+		namespace: "traced",
+		pod:       `{"spec": {"containers": [{"name": "app"}]}}`,
+		want:      true,
+	}, {
+		desc:      "pod opts out of namespace",
+		namespace: "traced",
+		pod:       `{"metadata": {"annotations": {"snoop.dev/inject": "false"}}, "spec": {"containers": [{"name": "app"}]}}`,
+		want:      false,
+	}, {
+		desc:      "not selected",
+		namespace: "plain",
+		pod:       `{"metadata": {"labels": {"app": "web"}}, "spec": {"containers": [{"name": "app"}]}}`,
+		want:      false,
+	}, {
+		desc:      "already has a snoop container",
+		namespace: "traced",
+		pod:       `{"spec": {"containers": [{"name": "app"}, {"name": "snoop"}]}}`,
+		want:      false,
+	}, {
+		desc:      "already injected",
+		namespace: "traced",
+		pod:       `{"metadata": {"annotations": {"snoop.dev/injected": "true"}}, "spec": {"containers": [{"name": "app"}]}}`,
+		want:      false,
+	}, {
+		desc:      "namespace lookup fails",
+		namespace: "missing",
+		pod:       `{"spec": {"containers": [{"name": "app"}]}}`,
+		wantErr:   true,
+	}} {
+		t.Run(tt.desc, func(t *testing.T) {
+			var p pod
+			if err := json.Unmarshal([]byte(tt.pod), &p); err != nil {
+				t.Fatal(err)
+			}
+			got, err := i.shouldInject(context.Background(), &p, tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("shouldInject error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("shouldInject = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	i := &Injector{Image: "example.com/snoop:v1", Args: []string{"-packages"}}
+
+	var p pod
+	json.Unmarshal([]byte(`{"metadata": {"annotations": {"snoop.dev/args": "-trace-containers=app  -file-metadata"}}, "spec": {"containers": [{"name": "app"}], "volumes": [{"name": "config"}]}}`), &p)
+	ops := i.patch(&p)
+
+	var paths []string
+	for _, op := range ops {
+		paths = append(paths, op.Path)
+	}
+	wantPaths := []string{"/spec/containers/-", "/spec/volumes/-", "/spec/volumes/-", "/spec/volumes/-", "/spec/volumes/-", "/metadata/annotations/snoop.dev~1injected"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("patch paths = %v, want %v", paths, wantPaths)
+	}
+
+	sidecar := ops[0].Value.(map[string]any)
+	if sidecar["image"] != "example.com/snoop:v1" {
+		t.Errorf("image = %v", sidecar["image"])
+	}
+	wantArgs := []string{"-report=/data/snoop-report.json", "-packages", "-trace-containers=app", "-file-metadata"}
+	if !reflect.DeepEqual(sidecar["args"], wantArgs) {
+		t.Errorf("args = %v, want %v", sidecar["args"], wantArgs)
+	}
+
+	// A pod without volumes or annotations gets both created
+	p = pod{}
+	ops = i.patch(&p)
+	if ops[1].Path != "/spec/volumes" || ops[2].Path != "/metadata/annotations" {
+		t.Errorf("patch = %+v, want volumes and annotations created", ops)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	i := &Injector{}
+	body, _ := json.Marshal(AdmissionReview{
+		APIVersion: "admission.k8s.io/v1",
+		Kind:       "AdmissionReview",
+		Request: &AdmissionRequest{
+			UID:       "req-1",
+			Namespace: "default",
+			Operation: "CREATE",
+			Object:    json.RawMessage(`{"metadata": {"generateName": "web-", "annotations": {"snoop.dev/inject": "true"}}, "spec": {"containers": [{"name": "app"}]}}`),
+		},
+	})
+	rec := httptest.NewRecorder()
+	i.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	var review AdmissionReview
+	if err := json.NewDecoder(rec.Body).Decode(&review); err != nil {
+		t.Fatal(err)
+	}
+	if review.APIVersion != "admission.k8s.io/v1" || review.Kind != "AdmissionReview" || review.Request != nil {
+		t.Errorf("review = %+v", review)
+	}
+	resp := review.Response
+	if resp == nil || resp.UID != "req-1" || !resp.Allowed || resp.PatchType != "JSONPatch" {
+		t.Fatalf("response = %+v", resp)
+	}
+	var ops []PatchOperation
+	if err := json.Unmarshal(resp.Patch, &ops); err != nil || len(ops) != 3 {
+		t.Errorf("patch = %s (%v), want 3 operations", resp.Patch, err)
+	}
+
+	// Undecodable pods are still allowed
+	review.Request = &AdmissionRequest{UID: "req-2", Object: json.RawMessage(`[]`)}
+	review.Response = nil
+	body, _ = json.Marshal(review)
+	rec = httptest.NewRecorder()
+	i.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body)))
+	json.NewDecoder(rec.Body).Decode(&review)
+	if review.Response == nil || !review.Response.Allowed || review.Response.Patch != nil {
+		t.Errorf("response for bad pod = %+v, want allowed without patch", review.Response)
+	}
+}
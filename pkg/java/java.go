@@ -0,0 +1,259 @@
+// Package java works out the classpath of JVM processes and reads JVM
+// class-loading logs, to tell which jars a Java application uses.
+package java
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IsArchive reports whether p names a Java archive.
+func IsArchive(p string) bool {
+	return strings.HasSuffix(strings.ToLower(p), ".jar")
+}
+
+// IsLauncher reports whether argv0, a process's first argument, is the java
+// launcher.
+func IsLauncher(argv0 string) bool {
+	return path.Base(argv0) == "java"
+}
+
+// Launch is how a JVM was told to find application classes.
+type Launch struct {
+	// Classpath lists the entries given with -cp, -classpath or
+	// --class-path, or in CLASSPATH, in order.
+	Classpath []string
+
+	// Jar is the application jar given with -jar, whose manifest's
+	// Class-Path replaces Classpath.
+	Jar string
+}
+
+// ParseArgs returns how the java launcher run with args, including argv0,
+// and environment env (as "KEY=value" strings) finds application classes.
+// Entries are as given: possibly relative, or wildcards such as "lib/*".
+func ParseArgs(args, env []string) Launch {
+	var l Launch
+	cp, set := "", false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-cp" || arg == "-classpath" || arg == "--class-path":
+			if i+1 < len(args) {
+				i++
+				cp, set = args[i], true
+			}
+		case strings.HasPrefix(arg, "--class-path="):
+			cp, set = strings.TrimPrefix(arg, "--class-path="), true
+		case arg == "-jar":
+			if i+1 < len(args) {
+				l.Jar = args[i+1]
+			}
+			// Everything after the jar is the application's
+			return l
+		case takesValue(arg):
+			i++
+		case !strings.HasPrefix(arg, "-"):
+			// The main class; everything after it is the application's
+			i = len(args)
+		}
+	}
+	if !set {
+		for _, kv := range env {
+			if v, ok := strings.CutPrefix(kv, "CLASSPATH="); ok {
+				cp, set = v, true
+			}
+		}
+	}
+	if !set {
+		cp = "."
+	}
+	l.Classpath = strings.Split(cp, ":")
+	return l
+}
+
+// takesValue reports whether a launcher option other than the classpath
+// takes the following argument as its value.
+func takesValue(arg string) bool {
+	switch arg {
+	case "-p", "--module-path", "--upgrade-module-path", "--add-modules",
+		"--limit-modules", "--add-reads", "--add-exports", "--add-opens",
+		"--patch-module", "-m", "--module", "--source", "--enable-native-access":
+		return true
+	}
+	return false
+}
+
+// Jars returns the absolute paths of the jars on the classpath of l, sorted,
+// for a process whose root directory is root and working directory cwd.
+// Relative entries are resolved against cwd, wildcard entries are expanded
+// to the jars in their directory, and an application jar's manifest
+// Class-Path is followed. Directory entries and jars that don't exist are
+// left out.
+func (l Launch) Jars(root, cwd string) []string {
+	found := make(map[string]bool)
+	if l.Jar != "" {
+		jar := abs(cwd, l.Jar)
+		if isFile(root, jar) {
+			found[jar] = true
+			// Manifest entries are URLs relative to the jar's directory
+			for _, entry := range manifestClassPath(root, jar) {
+				if p := abs(path.Dir(jar), entry); isFile(root, p) {
+					found[p] = true
+				}
+			}
+		}
+	} else {
+		for _, entry := range l.Classpath {
+			if entry == "" {
+				entry = "."
+			}
+			if dir, ok := strings.CutSuffix(entry, "*"); ok && (dir == "" || strings.HasSuffix(dir, "/")) {
+				dir = abs(cwd, dir)
+				entries, _ := os.ReadDir(filepath.Join(root, dir))
+				for _, e := range entries {
+					if !e.IsDir() && IsArchive(e.Name()) {
+						found[path.Join(dir, e.Name())] = true
+					}
+				}
+				continue
+			}
+			if p := abs(cwd, entry); IsArchive(p) && isFile(root, p) {
+				found[p] = true
+			}
+		}
+	}
+
+	jars := make([]string, 0, len(found))
+	for p := range found {
+		jars = append(jars, p)
+	}
+	sort.Strings(jars)
+	return jars
+}
+
+// abs resolves p against dir.
+func abs(dir, p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join("/", dir, p)
+}
+
+// isFile reports whether p under root is a regular file.
+func isFile(root, p string) bool {
+	fi, err := os.Stat(filepath.Join(root, p))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// manifestClassPath returns the Class-Path entries of the manifest of the
+// jar at p under root, or nil if it has none.
+func manifestClassPath(root, p string) []string {
+	zr, err := zip.OpenReader(filepath.Join(root, p))
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	f, err := zr.Open("META-INF/MANIFEST.MF")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	attrs, err := parseManifest(f)
+	if err != nil {
+		return nil
+	}
+	var entries []string
+	for _, entry := range strings.Fields(attrs["Class-Path"]) {
+		if u, err := url.PathUnescape(entry); err == nil {
+			entries = append(entries, u)
+		}
+	}
+	return entries
+}
+
+// parseManifest returns the main attributes of a jar manifest. Lines
+// starting with a space continue the previous one.
+func parseManifest(r io.Reader) (map[string]string, error) {
+	attrs := make(map[string]string)
+	var last string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			// The main section ends at the first blank line
+			break
+		}
+		if cont, ok := strings.CutPrefix(line, " "); ok && last != "" {
+			attrs[last] += cont
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid manifest line %q", line)
+		}
+		last = name
+		attrs[name] = strings.TrimPrefix(value, " ")
+	}
+	return attrs, sc.Err()
+}
+
+// ParseClassLog reads a JVM class-loading log, as written with
+// -Xlog:class+load (JDK 9 and later) or -verbose:class (JDK 8), and returns
+// how many classes were loaded from each jar, keyed by its path. Classes
+// loaded from the runtime image, class directories or the shared archive
+// aren't counted, and other lines are skipped, so the log can be mixed with
+// other output.
+func ParseClassLog(r io.Reader) (map[string]int, error) {
+	counts := make(map[string]int)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		var source string
+		if i := strings.Index(line, " source: "); i >= 0 && strings.Contains(line, "class,load") {
+			// [0.012s][info][class,load] com.example.Main source: file:/app/app.jar
+			source = line[i+len(" source: "):]
+		} else if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "[Loaded "); ok {
+			// [Loaded com.example.Main from file:/app/app.jar]
+			if _, from, ok := strings.Cut(rest, " from "); ok {
+				source = strings.TrimSuffix(from, "]")
+			}
+		}
+		if jar := sourceJar(source); jar != "" {
+			counts[jar]++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading class log: %w", err)
+	}
+	return counts, nil
+}
+
+// sourceJar returns the path of the jar a class-load log source names, such
+// as "file:/app/app.jar", "jar:file:/app/app.jar!/" or "/app/app.jar", or ""
+// if it isn't a jar.
+func sourceJar(source string) string {
+	source = strings.TrimSpace(source)
+	source = strings.TrimPrefix(source, "jar:")
+	if s, ok := strings.CutPrefix(source, "file:"); ok {
+		if u, err := url.PathUnescape(s); err == nil {
+			s = u
+		}
+		source = s
+	}
+	// Nested jars in Spring Boot style fat jars name the outer one first
+	source, _, _ = strings.Cut(source, "!")
+	if !path.IsAbs(source) || !IsArchive(source) {
+		return ""
+	}
+	return path.Clean(source)
+}
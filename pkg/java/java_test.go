@@ -0,0 +1,129 @@
+package java
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []string
+		env  []string
+		want Launch
+	}{{
+		name: "classpath",
+		args: []string{"java", "-Xmx1g", "-cp", "lib/*:app.jar", "com.example.Main", "-cp", "ignored"},
+		want: Launch{Classpath: []string{"lib/*", "app.jar"}},
+	}, {
+		name: "long option",
+		args: []string{"/usr/bin/java", "--add-opens", "java.base/java.lang=ALL-UNNAMED", "--class-path=/app/a.jar", "Main"},
+		want: Launch{Classpath: []string{"/app/a.jar"}},
+	}, {
+		name: "jar",
+		args: []string{"java", "-cp", "ignored.jar", "-jar", "/app/app.jar", "-cp", "x"},
+		want: Launch{Jar: "/app/app.jar"},
+	}, {
+		name: "environment",
+		args: []string{"java", "Main"},
+		env:  []string{"PATH=/bin", "CLASSPATH=/opt/lib/x.jar"},
+		want: Launch{Classpath: []string{"/opt/lib/x.jar"}},
+	}, {
+		name: "default",
+		args: []string{"java", "Main"},
+		want: Launch{Classpath: []string{"."}},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ParseArgs(tc.args, tc.env); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseArgs() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// writeJar writes a jar at p under root with the given manifest.
+func writeJar(t *testing.T, root, p, manifest string) {
+	t.Helper()
+	full := filepath.Join(root, p)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	if manifest != "" {
+		w, err := zw.Create("META-INF/MANIFEST.MF")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(manifest)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJars(t *testing.T) {
+	root := t.TempDir()
+	writeJar(t, root, "/app/app.jar", "Manifest-Version: 1.0\r\nMain-Class: Main\r\nClass-Path: lib/a.jar lib/b%20c.jar\r\n  lib/gone.jar\r\n\r\nName: x\r\nClass-Path: lib/no.jar\r\n")
+	writeJar(t, root, "/app/lib/a.jar", "")
+	writeJar(t, root, "/app/lib/b c.jar", "")
+	writeJar(t, root, "/app/lib/no.jar", "")
+	writeJar(t, root, "/opt/plugins/p.jar", "")
+	if err := os.WriteFile(filepath.Join(root, "/opt/plugins/README"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := Launch{Jar: "app.jar"}.Jars(root, "/app")
+	if want := []string{"/app/app.jar", "/app/lib/a.jar", "/app/lib/b c.jar"}; !slices.Equal(got, want) {
+		t.Errorf("Jars(-jar) = %q, want %q", got, want)
+	}
+
+	got = Launch{Classpath: []string{"/opt/plugins/*", "lib/a.jar", "classes", "missing.jar"}}.Jars(root, "/app")
+	if want := []string{"/app/lib/a.jar", "/opt/plugins/p.jar"}; !slices.Equal(got, want) {
+		t.Errorf("Jars(-cp) = %q, want %q", got, want)
+	}
+
+	if got := (Launch{Jar: "/missing.jar"}).Jars(root, "/"); len(got) != 0 {
+		t.Errorf("Jars(missing) = %q, want none", got)
+	}
+}
+
+func TestParseClassLog(t *testing.T) {
+	log := `[0.005s][info][class,load] java.lang.Object source: shared objects file
+[0.010s][info][class,load] java.lang.Thread source: jrt:/java.base
+[0.101s][info][class,load] com.example.Main source: file:/app/app.jar
+[0.102s][info][class,load] com.example.Util source: file:/app/app.jar
+[0.103s][info][class,load] org.lib.A source: jar:file:/app/lib/a%20b.jar!/
+[0.104s][info][class,load] com.example.Local source: file:/app/classes/
+[0.105s][info][class,load] org.boot.X source: jar:file:/app/boot.jar!/BOOT-INF/lib/x.jar!/
+Hello from the application, source: file:/not/a.jar
+[Opened /usr/lib/jvm/java-8/jre/lib/rt.jar]
+[Loaded java.lang.String from /usr/lib/jvm/java-8/jre/lib/rt.jar]
+[Loaded org.old.Y from file:/opt/old.jar]
+`
+	got, err := ParseClassLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("ParseClassLog failed: %v", err)
+	}
+	want := map[string]int{
+		"/app/app.jar":                       2,
+		"/app/lib/a b.jar":                   1,
+		"/app/boot.jar":                      1,
+		"/usr/lib/jvm/java-8/jre/lib/rt.jar": 1,
+		"/opt/old.jar":                       1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseClassLog() = %v, want %v", got, want)
+	}
+}
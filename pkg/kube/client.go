@@ -0,0 +1,196 @@
+// Package kube provides a minimal Kubernetes API client for the few calls
+// snoop needs, using the pod's service account.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// serviceAccountDir is where Kubernetes mounts the pod's service account credentials.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Client talks to the Kubernetes API server.
+type Client struct {
+	baseURL   string
+	token     string
+	tokenPath string
+	http      *http.Client
+}
+
+// NewClient creates a client for the API server at baseURL that authenticates
+// with the given bearer token (empty for none).
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{baseURL: baseURL, token: token, http: httpClient}
+}
+
+// InCluster reports whether snoop appears to be running inside a Kubernetes pod.
+func InCluster() bool {
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != "" && os.Getenv("KUBERNETES_SERVICE_PORT") != ""
+}
+
+// NewInClusterClient creates a client using the pod's service account token and CA.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster (KUBERNETES_SERVICE_HOST/PORT unset)")
+	}
+
+	caData, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates found in service account CA")
+	}
+
+	tokenPath := filepath.Join(serviceAccountDir, "token")
+	if _, err := os.Stat(tokenPath); err != nil {
+		return nil, fmt.Errorf("reading service account token: %w", err)
+	}
+
+	c := NewClient("https://"+net.JoinHostPort(host, port), "", &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	})
+	// Bound service account tokens are rotated; re-read on every request
+	c.tokenPath = tokenPath
+	return c, nil
+}
+
+// get performs a GET request against the API server and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	return c.do(ctx, http.MethodGet, path, "", nil, v)
+}
+
+// do sends a request with an optional body of the given content type to the
+// API server and decodes the JSON response into v, if v is non-nil.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte, v any) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	token := c.token
+	if c.tokenPath != "" {
+		data, err := os.ReadFile(c.tokenPath)
+		if err != nil {
+			return fmt.Errorf("reading service account token: %w", err)
+		}
+		token = string(data)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: unexpected status %s: %s", method, path, resp.Status, body)
+	}
+	if v == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// Apply creates or updates the object at path with server-side apply, as
+// fieldManager, taking ownership of any fields other managers set.
+func (c *Client) Apply(ctx context.Context, path, fieldManager string, obj any) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("encoding object: %w", err)
+	}
+	path += "?force=true&fieldManager=" + url.QueryEscape(fieldManager)
+	return c.do(ctx, http.MethodPatch, path, "application/apply-patch+yaml", data, nil)
+}
+
+// GetPod fetches a pod by namespace and name.
+func (c *Client) GetPod(ctx context.Context, namespace, name string) (*Pod, error) {
+	var pod Pod
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", url.PathEscape(namespace), url.PathEscape(name))
+	if err := c.get(ctx, path, &pod); err != nil {
+		return nil, err
+	}
+	return &pod, nil
+}
+
+// Namespace is the subset of a Kubernetes Namespace that snoop uses.
+type Namespace struct {
+	Metadata ObjectMeta `json:"metadata"`
+}
+
+// GetNamespace fetches a namespace by name.
+func (c *Client) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	var ns Namespace
+	if err := c.get(ctx, "/api/v1/namespaces/"+url.PathEscape(name), &ns); err != nil {
+		return nil, err
+	}
+	return &ns, nil
+}
+
+// PodList is the subset of a list of pods that snoop uses.
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// ListKubeletPods returns every pod the kubelet is running, for clients
+// created with the kubelet's address.
+func (c *Client) ListKubeletPods(ctx context.Context) ([]Pod, error) {
+	var pods PodList
+	if err := c.get(ctx, "/pods", &pods); err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// GetKubeletPod finds a pod by namespace and name among the pods the
+// kubelet is running, for clients created with the kubelet's address
+// (e.g. its read-only port, http://<node>:10255) rather than the API
+// server's. Returns an error if the pod isn't running on the node.
+func (c *Client) GetKubeletPod(ctx context.Context, namespace, name string) (*Pod, error) {
+	pods, err := c.ListKubeletPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		if pods[i].Metadata.Namespace == namespace && pods[i].Metadata.Name == name {
+			return &pods[i], nil
+		}
+	}
+	return nil, fmt.Errorf("pod %s/%s not found on the kubelet", namespace, name)
+}
@@ -0,0 +1,142 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetPod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/pods/my-app" {
+			t.Errorf("path = %q", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want Bearer secret", got)
+		}
+		w.Write([]byte(`{
+  "metadata": {"name": "my-app", "namespace": "default", "uid": "1234"},
+  "spec": {"nodeName": "node-1", "containers": [{"name": "nginx", "image": "nginx:1.25"}]},
+  "status": {
+    "containerStatuses": [
+      {"name": "nginx", "image": "docker.io/library/nginx:1.25", "imageID": "docker.io/library/nginx@sha256:abc", "containerID": "containerd://0123456789abcdef"}
+    ],
+    "ephemeralContainerStatuses": [
+      {"name": "debugger", "image": "busybox", "containerID": "containerd://fedcba9876543210"}
+    ]
+  }
+}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "secret", nil)
+	pod, err := c.GetPod(context.Background(), "default", "my-app")
+	if err != nil {
+		t.Fatalf("GetPod failed: %v", err)
+	}
+	if pod.Metadata.UID != "1234" || pod.Spec.NodeName != "node-1" {
+		t.Errorf("pod = %+v", pod)
+	}
+
+	images := pod.ContainerImages()
+	img, ok := images["0123456789abcdef"]
+	if !ok {
+		t.Fatalf("ContainerImages() = %v, missing container", images)
+	}
+	if img.Name != "nginx" || img.Ref != "docker.io/library/nginx:1.25" || img.Digest != "sha256:abc" || img.Ephemeral {
+		t.Errorf("image = %+v", img)
+	}
+	if dbg := images["fedcba9876543210"]; dbg.Name != "debugger" || !dbg.Ephemeral {
+		t.Errorf("ephemeral container image = %+v", dbg)
+	}
+}
+
+func TestGetNamespace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod" {
+			t.Errorf("path = %q", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"metadata": {"name": "prod", "annotations": {"snoop.dev/inject": "true"}}}`))
+	}))
+	defer server.Close()
+
+	ns, err := NewClient(server.URL, "", nil).GetNamespace(context.Background(), "prod")
+	if err != nil {
+		t.Fatalf("GetNamespace failed: %v", err)
+	}
+	if ns.Metadata.Name != "prod" || ns.Metadata.Annotations["snoop.dev/inject"] != "true" {
+		t.Errorf("namespace = %+v", ns)
+	}
+}
+
+func TestGetKubeletPod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pods" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.Write([]byte(`{"items": [
+  {"metadata": {"name": "my-app", "namespace": "other"}},
+  {"metadata": {"name": "my-app", "namespace": "default", "uid": "1234"}}
+]}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "", nil)
+	pod, err := c.GetKubeletPod(context.Background(), "default", "my-app")
+	if err != nil {
+		t.Fatalf("GetKubeletPod failed: %v", err)
+	}
+	if pod.Metadata.UID != "1234" {
+		t.Errorf("pod = %+v", pod)
+	}
+	if _, err := c.GetKubeletPod(context.Background(), "default", "missing"); err == nil {
+		t.Error("GetKubeletPod(missing) succeeded")
+	}
+}
+
+func TestGetPodNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	c := NewClient(server.URL, "", nil)
+	if _, err := c.GetPod(context.Background(), "default", "missing"); err == nil {
+		t.Error("expected error for 404")
+	}
+}
+
+func TestImageDigest(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"docker.io/library/nginx@sha256:abc", "sha256:abc"},
+		{"docker-pullable://nginx@sha256:def", "sha256:def"},
+		{"sha256:123", "sha256:123"},
+		{"", ""},
+		{"nginx:latest", ""},
+	} {
+		if got := ImageDigest(tt.in); got != tt.want {
+			t.Errorf("ImageDigest(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStripRuntimeScheme(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"containerd://abc", "abc"},
+		{"cri-o://def", "def"},
+		{"docker://123", "123"},
+		{"abc", "abc"},
+		{"", ""},
+	} {
+		if got := StripRuntimeScheme(tt.in); got != tt.want {
+			t.Errorf("StripRuntimeScheme(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultPodInfoDir is where snoop's manifests mount a downward API volume
+// with the pod's labels.
+const DefaultPodInfoDir = "/etc/podinfo"
+
+// PodInfo is the metadata of the pod snoop runs in, as exposed by the
+// downward API.
+type PodInfo struct {
+	Name      string
+	Namespace string
+	UID       string
+	NodeName  string
+	Labels    map[string]string
+}
+
+// ReadPodInfo reads the pod's metadata from the downward API: the
+// POD_NAME, POD_NAMESPACE, POD_UID, and NODE_NAME environment variables,
+// and the name, namespace, uid, and labels files of a downward API volume
+// mounted at dir. Environment variables take precedence over files, and
+// anything not exposed is left empty.
+func ReadPodInfo(dir string) PodInfo {
+	readFile := func(name string) []byte {
+		if dir == "" {
+			return nil
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		return data
+	}
+	value := func(env, file string) string {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+		return strings.TrimSpace(string(readFile(file)))
+	}
+	return PodInfo{
+		Name:      value("POD_NAME", "name"),
+		Namespace: value("POD_NAMESPACE", "namespace"),
+		UID:       value("POD_UID", "uid"),
+		NodeName:  os.Getenv("NODE_NAME"),
+		Labels:    ParseDownwardLabels(readFile("labels")),
+	}
+}
+
+// ParseDownwardLabels parses the labels or annotations file of a downward
+// API volume, which has one key="value" pair per line with the value
+// quoted like a Go string. Malformed lines are skipped. It returns nil if
+// there are no pairs.
+func ParseDownwardLabels(data []byte) map[string]string {
+	var result map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, quoted, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key == "" {
+			continue
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDownwardLabels(t *testing.T) {
+	got := ParseDownwardLabels([]byte("app=\"web\"\napp.kubernetes.io/version=\"1.2\"\nnote=\"a \\\"quoted\\\" value\"\nbroken\nunquoted=value\n"))
+	want := map[string]string{
+		"app":                       "web",
+		"app.kubernetes.io/version": "1.2",
+		"note":                      `a "quoted" value`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDownwardLabels = %v, want %v", got, want)
+	}
+	if got := ParseDownwardLabels(nil); got != nil {
+		t.Errorf("ParseDownwardLabels(nil) = %v, want nil", got)
+	}
+}
+
+func TestReadPodInfo(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"name":      "web-abc\n",
+		"namespace": "prod\n",
+		"uid":       "uid-1\n",
+		"labels":    "app=\"web\"\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("POD_NAME", "")
+	t.Setenv("POD_NAMESPACE", "staging")
+	t.Setenv("POD_UID", "")
+	t.Setenv("NODE_NAME", "node-1")
+
+	got := ReadPodInfo(dir)
+	want := PodInfo{
+		Name:      "web-abc",
+		Namespace: "staging", // the environment takes precedence
+		UID:       "uid-1",
+		NodeName:  "node-1",
+		Labels:    map[string]string{"app": "web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPodInfo = %+v, want %+v", got, want)
+	}
+
+	// A missing volume leaves only the environment
+	if got := ReadPodInfo(filepath.Join(dir, "missing")); got.Name != "" || got.Namespace != "staging" || got.Labels != nil {
+		t.Errorf("ReadPodInfo without a volume = %+v", got)
+	}
+}
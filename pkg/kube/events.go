@@ -0,0 +1,174 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/notify"
+)
+
+const (
+	// EventTypeNormal and EventTypeWarning are the types of Events.
+	EventTypeNormal  = "Normal"
+	EventTypeWarning = "Warning"
+
+	// eventComponent is the component Events are reported by.
+	eventComponent = "snoop"
+
+	// eventQueueSize bounds pending Events; when full, new ones are dropped.
+	eventQueueSize = 100
+)
+
+// Event is the subset of a Kubernetes core/v1 Event that snoop creates.
+type Event struct {
+	Metadata           ObjectMeta      `json:"metadata"`
+	InvolvedObject     ObjectReference `json:"involvedObject"`
+	Reason             string          `json:"reason"`
+	Message            string          `json:"message"`
+	Type               string          `json:"type"`
+	Source             EventSource     `json:"source"`
+	FirstTimestamp     time.Time       `json:"firstTimestamp"`
+	LastTimestamp      time.Time       `json:"lastTimestamp"`
+	Count              int             `json:"count"`
+	ReportingComponent string          `json:"reportingComponent"`
+	ReportingInstance  string          `json:"reportingInstance,omitempty"`
+}
+
+// ObjectReference identifies the object an Event is about.
+type ObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	UID        string `json:"uid,omitempty"`
+	FieldPath  string `json:"fieldPath,omitempty"`
+}
+
+// EventSource identifies the component that reported an Event.
+type EventSource struct {
+	Component string `json:"component"`
+}
+
+// CreateEvent creates an Event in namespace.
+func (c *Client) CreateEvent(ctx context.Context, namespace string, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/events", url.PathEscape(namespace))
+	return c.do(ctx, http.MethodPost, path, "application/json", data, nil)
+}
+
+// EventRecorder delivers notifications as Events on a pod, so they show up
+// in kubectl describe and in alerting built on Events. Events are created
+// on a background goroutine so callers on the event path are never blocked.
+type EventRecorder struct {
+	ctx    context.Context
+	client *Client
+	pod    ObjectReference
+	queue  chan notify.Notification
+	wg     sync.WaitGroup
+}
+
+// NewEventRecorder creates a notifier that records Events on the pod with
+// the given namespace, name, and UID (empty if unknown).
+func NewEventRecorder(ctx context.Context, client *Client, namespace, name, uid string) *EventRecorder {
+	r := &EventRecorder{
+		ctx:    ctx,
+		client: client,
+		pod:    ObjectReference{APIVersion: "v1", Kind: "Pod", Name: name, Namespace: namespace, UID: uid},
+		queue:  make(chan notify.Notification, eventQueueSize),
+	}
+	r.wg.Add(1)
+	go r.run()
+
+	clog.FromContext(ctx).Infof("Initialized Kubernetes Event notifier (pod: %s/%s)", namespace, name)
+	return r
+}
+
+// Notify queues a notification to be recorded as an Event. If the queue is
+// full the notification is dropped and a warning is logged.
+func (r *EventRecorder) Notify(n notify.Notification) {
+	select {
+	case r.queue <- n:
+	default:
+		clog.FromContext(r.ctx).Warnf("Event queue full, dropping %s notification", n.Kind)
+	}
+}
+
+// Close stops accepting notifications and waits for queued ones to be
+// recorded.
+func (r *EventRecorder) Close() error {
+	close(r.queue)
+	r.wg.Wait()
+	return nil
+}
+
+// run records queued notifications until the queue is closed.
+func (r *EventRecorder) run() {
+	defer r.wg.Done()
+	log := clog.FromContext(r.ctx)
+	for n := range r.queue {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.ctx), 10*time.Second)
+		if err := r.client.CreateEvent(ctx, r.pod.Namespace, r.event(n)); err != nil {
+			log.Warnf("Recording Event for %s notification failed: %v", n.Kind, err)
+		}
+		cancel()
+	}
+}
+
+// event converts a notification to an Event on the pod, pointing at the
+// container it concerns, if any.
+func (r *EventRecorder) event(n notify.Notification) *Event {
+	reason, typ := EventReason(n.Kind)
+	ts := n.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	obj := r.pod
+	if n.Container != "" {
+		obj.FieldPath = fmt.Sprintf("spec.containers{%s}", n.Container)
+	}
+	return &Event{
+		// Named like client-go's Events, unique per pod
+		Metadata:           ObjectMeta{Name: fmt.Sprintf("%s.%x", r.pod.Name, ts.UnixNano()), Namespace: r.pod.Namespace},
+		InvolvedObject:     obj,
+		Reason:             reason,
+		Message:            n.Message,
+		Type:               typ,
+		Source:             EventSource{Component: eventComponent},
+		FirstTimestamp:     ts,
+		LastTimestamp:      ts,
+		Count:              1,
+		ReportingComponent: eventComponent,
+		ReportingInstance:  r.pod.Name,
+	}
+}
+
+// EventReason returns the Event reason and type for a notification kind.
+func EventReason(kind notify.Kind) (reason, typ string) {
+	switch kind {
+	case notify.KindWatchedPath:
+		return "WatchedPathAccessed", EventTypeWarning
+	case notify.KindDropRate:
+		return "EventsDropped", EventTypeWarning
+	case notify.KindEviction:
+		return "FilesEvicted", EventTypeWarning
+	case notify.KindPackageUsed:
+		return "PackageUsed", EventTypeNormal
+	case notify.KindProbeFailed:
+		return "ProbeFailed", EventTypeWarning
+	case notify.KindBaselineViolation:
+		return "BaselineViolated", EventTypeWarning
+	case notify.KindRuleAlert:
+		return "RuleAlert", EventTypeWarning
+	default:
+		return string(kind), EventTypeNormal
+	}
+}
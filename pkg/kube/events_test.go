@@ -0,0 +1,73 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imjasonh/snoop/pkg/notify"
+)
+
+func TestEventRecorder(t *testing.T) {
+	var mu sync.Mutex
+	var got []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/namespaces/prod/events" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	r := NewEventRecorder(context.Background(), NewClient(server.URL, "", nil), "prod", "web", "uid-1")
+	now := time.Unix(1700000000, 0).UTC()
+	r.Notify(notify.Notification{Kind: notify.KindWatchedPath, Time: now, Container: "app", Path: "/etc/shadow", Message: "app accessed /etc/shadow"})
+	r.Notify(notify.Notification{Kind: notify.KindProbeFailed, Message: "eBPF program failed to load"})
+	r.Close()
+
+	if len(got) != 2 {
+		t.Fatalf("got %d Events, want 2", len(got))
+	}
+	e := got[0]
+	want := ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "web", Namespace: "prod", UID: "uid-1", FieldPath: "spec.containers{app}"}
+	if e.InvolvedObject != want {
+		t.Errorf("involvedObject = %+v, want %+v", e.InvolvedObject, want)
+	}
+	if e.Reason != "WatchedPathAccessed" || e.Type != EventTypeWarning || e.Message != "app accessed /etc/shadow" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Metadata.Namespace != "prod" || e.Metadata.Name == "" || !e.FirstTimestamp.Equal(now) || e.Count != 1 || e.Source.Component != "snoop" {
+		t.Errorf("event metadata = %+v", e)
+	}
+	if e := got[1]; e.Reason != "ProbeFailed" || e.InvolvedObject.FieldPath != "" || e.LastTimestamp.IsZero() {
+		t.Errorf("probe event = %+v", e)
+	}
+}
+
+func TestEventReason(t *testing.T) {
+	for kind, want := range map[notify.Kind]string{
+		notify.KindWatchedPath:       EventTypeWarning,
+		notify.KindDropRate:          EventTypeWarning,
+		notify.KindEviction:          EventTypeWarning,
+		notify.KindPackageUsed:       EventTypeNormal,
+		notify.KindProbeFailed:       EventTypeWarning,
+		notify.KindBaselineViolation: EventTypeWarning,
+		notify.KindRuleAlert:         EventTypeWarning,
+	} {
+		reason, typ := EventReason(kind)
+		if reason == "" || typ != want {
+			t.Errorf("EventReason(%s) = %q, %q, want type %q", kind, reason, typ, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package kube
+
+import "strings"
+
+// Pod is the subset of a Kubernetes Pod that snoop uses.
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     PodSpec    `json:"spec"`
+	Status   PodStatus  `json:"status"`
+}
+
+// ObjectMeta is the subset of Kubernetes object metadata that snoop uses.
+type ObjectMeta struct {
+	Name         string            `json:"name"`
+	GenerateName string            `json:"generateName,omitempty"`
+	Namespace    string            `json:"namespace"`
+	UID          string            `json:"uid"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// PodSpec is the subset of a pod spec that snoop uses.
+type PodSpec struct {
+	NodeName            string      `json:"nodeName"`
+	Containers          []Container `json:"containers"`
+	EphemeralContainers []Container `json:"ephemeralContainers,omitempty"`
+}
+
+// Container is the subset of a container spec that snoop uses.
+type Container struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// PodStatus is the subset of a pod status that snoop uses.
+type PodStatus struct {
+	ContainerStatuses          []ContainerStatus `json:"containerStatuses,omitempty"`
+	InitContainerStatuses      []ContainerStatus `json:"initContainerStatuses,omitempty"`
+	EphemeralContainerStatuses []ContainerStatus `json:"ephemeralContainerStatuses,omitempty"`
+}
+
+// ContainerStatus is the subset of a container status that snoop uses.
+type ContainerStatus struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	ImageID      string `json:"imageID"`
+	ContainerID  string `json:"containerID"`
+	RestartCount int32  `json:"restartCount"`
+}
+
+// ContainerImage identifies the image a container is running.
+type ContainerImage struct {
+	Name   string // Kubernetes container name
+	Ref    string // Image reference from the pod spec, e.g. "nginx:1.25"
+	Digest string // Resolved digest, e.g. "sha256:abc...", if known
+
+	// PodName and PodNamespace identify the container's pod, if known.
+	PodName      string
+	PodNamespace string
+
+	// Ephemeral marks an ephemeral container, e.g. one added by kubectl debug.
+	Ephemeral bool
+}
+
+// ContainerImages maps runtime container IDs (without the "containerd://"
+// style scheme) to the image each container is running.
+func (p *Pod) ContainerImages() map[string]ContainerImage {
+	result := make(map[string]ContainerImage)
+	all := make([]ContainerStatus, 0, len(p.Status.ContainerStatuses)+len(p.Status.InitContainerStatuses)+len(p.Status.EphemeralContainerStatuses))
+	all = append(all, p.Status.ContainerStatuses...)
+	all = append(all, p.Status.InitContainerStatuses...)
+	all = append(all, p.Status.EphemeralContainerStatuses...)
+	ephemeral := p.EphemeralContainerNames()
+	for _, cs := range all {
+		id := StripRuntimeScheme(cs.ContainerID)
+		if id == "" {
+			continue
+		}
+		result[id] = ContainerImage{
+			Name:   cs.Name,
+			Ref:    cs.Image,
+			Digest: ImageDigest(cs.ImageID),
+
+			PodName:      p.Metadata.Name,
+			PodNamespace: p.Metadata.Namespace,
+			Ephemeral:    ephemeral[cs.Name],
+		}
+	}
+	return result
+}
+
+// EphemeralContainerNames returns the names of the pod's ephemeral
+// containers, from its spec and status.
+func (p *Pod) EphemeralContainerNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, c := range p.Spec.EphemeralContainers {
+		names[c.Name] = true
+	}
+	for _, cs := range p.Status.EphemeralContainerStatuses {
+		names[cs.Name] = true
+	}
+	return names
+}
+
+// StripRuntimeScheme removes the runtime prefix from a container ID,
+// e.g. "containerd://abc123" -> "abc123".
+func StripRuntimeScheme(id string) string {
+	if i := strings.Index(id, "://"); i >= 0 {
+		return id[i+3:]
+	}
+	return id
+}
+
+// ImageDigest extracts the digest from a container status imageID, which
+// may be "repo@sha256:...", "docker-pullable://repo@sha256:...", or a bare
+// "sha256:..." image config ID.
+func ImageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	if strings.HasPrefix(imageID, "sha256:") {
+		return imageID
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/packages"
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+const (
+	// ReportAPIVersion and ReportKind identify the FileAccessReport custom
+	// resource, defined in deploy/kubernetes/crd.yaml.
+	ReportAPIVersion = "snoop.dev/v1alpha1"
+	ReportKind       = "FileAccessReport"
+
+	// reportFieldManager owns the fields snoop applies.
+	reportFieldManager = "snoop"
+)
+
+// FileAccessReport is a pod's report as a namespaced custom resource, named
+// after the pod. It holds per-container stats rather than file lists, which
+// could exceed the API server's object size limit.
+type FileAccessReport struct {
+	APIVersion string                 `json:"apiVersion"`
+	Kind       string                 `json:"kind"`
+	Metadata   ReportMeta             `json:"metadata"`
+	Spec       FileAccessReportSpec   `json:"spec"`
+	Status     FileAccessReportStatus `json:"status"`
+}
+
+// ReportMeta is the metadata of a FileAccessReport.
+type ReportMeta struct {
+	Name            string           `json:"name"`
+	Namespace       string           `json:"namespace"`
+	OwnerReferences []OwnerReference `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference makes an object garbage collected along with its owner.
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+}
+
+// FileAccessReportSpec identifies the traced pod and its images.
+type FileAccessReportSpec struct {
+	PodName    string                `json:"podName"`
+	PodUID     string                `json:"podUID,omitempty"`
+	Containers []FileAccessContainer `json:"containers"`
+}
+
+// FileAccessContainer identifies a traced container and its image.
+type FileAccessContainer struct {
+	Name        string `json:"name"`
+	Image       string `json:"image,omitempty"`
+	ImageDigest string `json:"imageDigest,omitempty"`
+	Ephemeral   bool   `json:"ephemeral,omitempty"`
+}
+
+// FileAccessReportStatus holds what the pod's containers accessed.
+type FileAccessReportStatus struct {
+	StartedAt     time.Time                  `json:"startedAt"`
+	LastUpdatedAt time.Time                  `json:"lastUpdatedAt"`
+	TotalEvents   uint64                     `json:"totalEvents"`
+	DroppedEvents uint64                     `json:"droppedEvents"`
+	Containers    []FileAccessContainerStats `json:"containers"`
+}
+
+// FileAccessContainerStats summarizes one container's file and package
+// usage. Package counts are only set when package attribution is enabled.
+type FileAccessContainerStats struct {
+	Name               string   `json:"name"`
+	TotalEvents        uint64   `json:"totalEvents"`
+	UniqueFiles        int      `json:"uniqueFiles"`
+	EvictedFiles       uint64   `json:"evictedFiles,omitempty"`
+	RestartCount       int      `json:"restartCount,omitempty"`
+	Packages           int      `json:"packages,omitempty"`
+	UsedPackages       int      `json:"usedPackages,omitempty"`
+	UnusedPackages     []string `json:"unusedPackages,omitempty"`
+	UnusedPackageBytes int64    `json:"unusedPackageBytes,omitempty"`
+	UnusedFileBytes    int64    `json:"unusedFileBytes,omitempty"`
+}
+
+// ReportReporter creates or updates a FileAccessReport for each pod in a
+// report, so cluster tooling can watch reports like any other resource.
+type ReportReporter struct {
+	client *Client
+}
+
+// NewReportReporter creates a reporter that applies FileAccessReports
+// through client.
+func NewReportReporter(ctx context.Context, client *Client) *ReportReporter {
+	clog.FromContext(ctx).Info("Initialized FileAccessReport reporter")
+	return &ReportReporter{client: client}
+}
+
+// Update applies a FileAccessReport for each pod in the report. Pods whose
+// name or namespace isn't known yet are skipped until it is.
+func (r *ReportReporter) Update(ctx context.Context, report *reporter.Report) error {
+	var errs []error
+	for _, pod := range reporter.SplitByPod(report) {
+		if pod.PodName == "" || pod.Namespace == "" {
+			clog.FromContext(ctx).Debugf("Not writing FileAccessReport for pod %s without a name", pod.PodUID)
+			continue
+		}
+		pod.LastUpdatedAt = time.Now()
+		obj := NewFileAccessReport(pod)
+		path := fmt.Sprintf("/apis/%s/namespaces/%s/fileaccessreports/%s", ReportAPIVersion, url.PathEscape(obj.Metadata.Namespace), url.PathEscape(obj.Metadata.Name))
+		if err := r.client.Apply(ctx, path, reportFieldManager, obj); err != nil {
+			errs = append(errs, fmt.Errorf("applying FileAccessReport %s/%s: %w", obj.Metadata.Namespace, obj.Metadata.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close is a no-op for ReportReporter.
+func (r *ReportReporter) Close() error {
+	return nil
+}
+
+// NewFileAccessReport converts the report of a single pod to a
+// FileAccessReport. When the pod's UID is known, the pod owns the
+// FileAccessReport, which is deleted along with it.
+func NewFileAccessReport(report *reporter.Report) *FileAccessReport {
+	obj := &FileAccessReport{
+		APIVersion: ReportAPIVersion,
+		Kind:       ReportKind,
+		Metadata:   ReportMeta{Name: report.PodName, Namespace: report.Namespace},
+		Spec: FileAccessReportSpec{
+			PodName:    report.PodName,
+			PodUID:     report.PodUID,
+			Containers: []FileAccessContainer{},
+		},
+		Status: FileAccessReportStatus{
+			StartedAt:     report.StartedAt,
+			LastUpdatedAt: report.LastUpdatedAt,
+			TotalEvents:   report.TotalEvents,
+			DroppedEvents: report.DroppedEvents,
+			Containers:    []FileAccessContainerStats{},
+		},
+	}
+	if report.PodUID != "" {
+		obj.Metadata.OwnerReferences = []OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: report.PodName, UID: report.PodUID}}
+	}
+	for _, c := range report.Containers {
+		obj.Spec.Containers = append(obj.Spec.Containers, FileAccessContainer{
+			Name:        c.Name,
+			Image:       c.ImageRef,
+			ImageDigest: c.ImageDigest,
+			Ephemeral:   c.Ephemeral,
+		})
+		stats := FileAccessContainerStats{
+			Name:               c.Name,
+			TotalEvents:        c.TotalEvents,
+			UniqueFiles:        c.UniqueFiles,
+			EvictedFiles:       c.EvictedFiles,
+			RestartCount:       c.RestartCount,
+			UnusedPackageBytes: c.UnusedPackageBytes,
+			UnusedFileBytes:    c.UnusedFileBytes,
+		}
+		for _, p := range c.Packages {
+			if p.Name == packages.OrphanName {
+				continue
+			}
+			stats.Packages++
+			if p.AccessedFiles > 0 {
+				stats.UsedPackages++
+			} else {
+				stats.UnusedPackages = append(stats.UnusedPackages, p.Name)
+			}
+		}
+		obj.Status.Containers = append(obj.Status.Containers, stats)
+	}
+	return obj
+}
@@ -0,0 +1,117 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+func TestNewFileAccessReport(t *testing.T) {
+	obj := NewFileAccessReport(&reporter.Report{
+		PodName:     "web",
+		Namespace:   "prod",
+		PodUID:      "uid-1",
+		TotalEvents: 15,
+		Containers: []reporter.ContainerReport{{
+			Name:         "app",
+			ImageRef:     "nginx:1.25",
+			ImageDigest:  "sha256:abc",
+			TotalEvents:  15,
+			UniqueFiles:  3,
+			RestartCount: 1,
+			Packages: []reporter.PackageReport{
+				{Name: "nginx", AccessedFiles: 2},
+				{Name: "curl"},
+				{Name: packages.OrphanName, AccessedFiles: 1},
+			},
+			UnusedPackageBytes: 1024,
+			UnusedFileBytes:    4096,
+		}},
+	})
+
+	if obj.APIVersion != ReportAPIVersion || obj.Kind != ReportKind {
+		t.Errorf("type = %s %s", obj.APIVersion, obj.Kind)
+	}
+	if obj.Metadata.Name != "web" || obj.Metadata.Namespace != "prod" {
+		t.Errorf("metadata = %+v", obj.Metadata)
+	}
+	wantOwner := []OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "web", UID: "uid-1"}}
+	if !reflect.DeepEqual(obj.Metadata.OwnerReferences, wantOwner) {
+		t.Errorf("ownerReferences = %+v, want %+v", obj.Metadata.OwnerReferences, wantOwner)
+	}
+	wantSpec := []FileAccessContainer{{Name: "app", Image: "nginx:1.25", ImageDigest: "sha256:abc"}}
+	if !reflect.DeepEqual(obj.Spec.Containers, wantSpec) {
+		t.Errorf("spec.containers = %+v, want %+v", obj.Spec.Containers, wantSpec)
+	}
+	wantStats := []FileAccessContainerStats{{
+		Name:               "app",
+		TotalEvents:        15,
+		UniqueFiles:        3,
+		RestartCount:       1,
+		Packages:           2,
+		UsedPackages:       1,
+		UnusedPackages:     []string{"curl"},
+		UnusedPackageBytes: 1024,
+		UnusedFileBytes:    4096,
+	}}
+	if !reflect.DeepEqual(obj.Status.Containers, wantStats) {
+		t.Errorf("status.containers = %+v, want %+v", obj.Status.Containers, wantStats)
+	}
+}
+
+func TestReportReporter(t *testing.T) {
+	var mu sync.Mutex
+	applied := make(map[string]FileAccessReport)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			t.Errorf("method = %s, want PATCH", r.Method)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/apply-patch+yaml" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if q := r.URL.Query(); q.Get("fieldManager") != "snoop" || q.Get("force") != "true" {
+			t.Errorf("query = %q", r.URL.RawQuery)
+		}
+		data, _ := io.ReadAll(r.Body)
+		var obj FileAccessReport
+		if err := json.Unmarshal(data, &obj); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		mu.Lock()
+		applied[r.URL.Path] = obj
+		mu.Unlock()
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	r := NewReportReporter(context.Background(), NewClient(server.URL, "", nil))
+	err := r.Update(context.Background(), &reporter.Report{
+		Containers: []reporter.ContainerReport{
+			{Name: "app", PodUID: "uid-a", PodName: "web", PodNamespace: "prod"},
+			{Name: "worker", PodUID: "uid-b", PodName: "batch", PodNamespace: "jobs"},
+			{Name: "pending", PodUID: "uid-c"}, // Name not known yet
+		},
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if len(applied) != 2 {
+		t.Fatalf("applied %d objects, want 2: %v", len(applied), applied)
+	}
+	web, ok := applied["/apis/snoop.dev/v1alpha1/namespaces/prod/fileaccessreports/web"]
+	if !ok || web.Spec.PodUID != "uid-a" || len(web.Status.Containers) != 1 || web.Status.LastUpdatedAt.IsZero() {
+		t.Errorf("web report = %+v", web)
+	}
+	if _, ok := applied["/apis/snoop.dev/v1alpha1/namespaces/jobs/fileaccessreports/batch"]; !ok {
+		t.Errorf("batch report not applied: %v", applied)
+	}
+}
@@ -0,0 +1,117 @@
+// Package manifests renders Kubernetes manifests for the common ways of
+// deploying snoop from templates embedded in the binary, so they match the
+// flags of the snoop that generates them.
+package manifests
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/imjasonh/snoop/pkg/inject"
+)
+
+//go:embed templates/*.yaml.tmpl
+var templateFS embed.FS
+
+// Kinds are the manifests that can be rendered, in the order they should
+// be applied.
+var Kinds = []string{"rbac", "sidecar", "daemonset", "webhook"}
+
+// Options parameterize the rendered manifests.
+type Options struct {
+	// Namespace is where snoop's own resources are created.
+	Namespace string
+	// Image is the snoop image to run.
+	Image string
+	// ServiceAccount is the service account snoop runs as.
+	ServiceAccount string
+	// Interval is the report interval passed to snoop.
+	Interval time.Duration
+	// Args are additional snoop flags, e.g. "-packages".
+	Args []string
+
+	// HasFlag reports whether snoop defines the named flag. Rendering fails
+	// if a template passes snoop a flag it doesn't define. If nil, every
+	// flag is accepted.
+	HasFlag func(name string) bool
+}
+
+// templateData is what templates are executed against.
+type templateData struct {
+	Options
+	ContainerName string
+	ReportPath    string
+
+	// SidecarArgs are the flags the injector adds to every sidecar.
+	SidecarArgs string
+}
+
+// Render writes the manifests of the given kind to w.
+func Render(w io.Writer, kind string, opts Options) error {
+	if !slices.Contains(Kinds, kind) {
+		return fmt.Errorf("unknown manifest %q (must be one of %s)", kind, strings.Join(Kinds, ", "))
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "snoop-system"
+	}
+	if opts.Image == "" {
+		opts.Image = inject.DefaultImage
+	}
+	if opts.ServiceAccount == "" {
+		opts.ServiceAccount = "snoop"
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+
+	for _, arg := range opts.Args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name == "" {
+			return fmt.Errorf("invalid snoop flag %q", arg)
+		}
+		if opts.HasFlag != nil && !opts.HasFlag(name) {
+			return fmt.Errorf("snoop has no -%s flag", name)
+		}
+	}
+	interval, err := opts.flag("interval", opts.Interval)
+	if err != nil {
+		return err
+	}
+	sidecarArgs, _ := strconv.Unquote(interval)
+
+	tmpl, err := template.New(kind).Funcs(template.FuncMap{
+		"flag":  opts.flag,
+		"quote": strconv.Quote,
+	}).ParseFS(templateFS, "templates/"+kind+".yaml.tmpl")
+	if err != nil {
+		return fmt.Errorf("parsing %s template: %w", kind, err)
+	}
+	return tmpl.ExecuteTemplate(w, kind+".yaml.tmpl", templateData{
+		Options:       opts,
+		ContainerName: inject.ContainerName,
+		ReportPath:    inject.ReportPath,
+		SidecarArgs:   strings.Join(append([]string{sidecarArgs}, opts.Args...), " "),
+	})
+}
+
+// flag formats a snoop flag as a quoted YAML string, "-name" or
+// "-name=value", failing if snoop doesn't define it.
+func (o Options) flag(name string, value ...any) (string, error) {
+	if o.HasFlag != nil && !o.HasFlag(name) {
+		return "", fmt.Errorf("snoop has no -%s flag", name)
+	}
+	switch len(value) {
+	case 0:
+		return strconv.Quote("-" + name), nil
+	case 1:
+		return strconv.Quote(fmt.Sprintf("-%s=%v", name, value[0])), nil
+	default:
+		return "", fmt.Errorf("flag -%s given %d values", name, len(value))
+	}
+}
@@ -0,0 +1,71 @@
+package manifests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	opts := Options{
+		Namespace: "tools",
+		Image:     "example.com/snoop:v1",
+		Interval:  time.Minute,
+		Args:      []string{"-packages", "-trace-containers=app"},
+	}
+	for _, kind := range Kinds {
+		t.Run(kind, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Render(&buf, kind, opts); err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			out := buf.String()
+			for _, want := range []string{"example.com/snoop:v1", "-interval=1m0s", "-trace-containers=app"} {
+				if kind != "rbac" && !strings.Contains(out, want) {
+					t.Errorf("%s manifest missing %q:\n%s", kind, want, out)
+				}
+			}
+			if kind != "sidecar" && !strings.Contains(out, "namespace: tools") {
+				t.Errorf("%s manifest not in namespace tools:\n%s", kind, out)
+			}
+			if strings.Contains(out, "<no value>") || strings.Contains(out, "snoop-system") {
+				t.Errorf("%s manifest has unrendered or default values:\n%s", kind, out)
+			}
+		})
+	}
+}
+
+func TestRenderDefaults(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, "daemonset", Options{}); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, want := range []string{"namespace: snoop-system", "serviceAccountName: snoop", "ghcr.io/imjasonh/snoop:latest", `"-interval=30s"`, `"-node"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("manifest missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRenderUnknownFlags(t *testing.T) {
+	known := map[string]bool{"report": true, "interval": true, "packages": true}
+	hasFlag := func(name string) bool { return known[name] }
+
+	if err := Render(&bytes.Buffer{}, "sidecar", Options{Args: []string{"-packages"}, HasFlag: hasFlag}); err != nil {
+		t.Errorf("Render with known flags failed: %v", err)
+	}
+	if err := Render(&bytes.Buffer{}, "sidecar", Options{Args: []string{"-pakages"}, HasFlag: hasFlag}); err == nil {
+		t.Error("Render with an unknown extra flag succeeded, want error")
+	}
+	if err := Render(&bytes.Buffer{}, "sidecar", Options{Args: []string{"packages"}}); err == nil {
+		t.Error("Render with a non-flag argument succeeded, want error")
+	}
+	// The DaemonSet passes -node, which this snoop doesn't define
+	if err := Render(&bytes.Buffer{}, "daemonset", Options{HasFlag: hasFlag}); err == nil || !strings.Contains(err.Error(), "-node") {
+		t.Errorf("Render of a template using an unknown flag = %v, want error about -node", err)
+	}
+	if err := Render(&bytes.Buffer{}, "helm", Options{}); err == nil {
+		t.Error("Render of an unknown kind succeeded, want error")
+	}
+}
@@ -3,7 +3,13 @@ package metrics
 
 import (
 	"net/http"
+	"sort"
 
+	"github.com/imjasonh/snoop/pkg/packages"
+	"github.com/imjasonh/snoop/pkg/processor"
+	"github.com/imjasonh/snoop/pkg/reporter"
+	"github.com/imjasonh/snoop/pkg/sampling"
+	"github.com/imjasonh/snoop/pkg/version"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -19,6 +25,33 @@ type Metrics struct {
 	EventsEvicted   prometheus.Counter
 	UniqueFiles     prometheus.Gauge
 
+	// EventsBySyscall counts events received, labeled with the syscall
+	// that triggered them
+	EventsBySyscall *prometheus.CounterVec
+
+	// EventsUnknownContainer counts events from cgroups that aren't
+	// tracked, such as containers that just restarted, labeled with the
+	// cgroup ID
+	EventsUnknownContainer *prometheus.CounterVec
+
+	// Per-container metrics, labeled with the container's name
+	ContainerEventsReceived *prometheus.CounterVec
+	ContainerUniqueFiles    *prometheus.GaugeVec
+
+	// ContainerUnownedExecutables counts the files each container executed
+	// that no installed package owns, updated on each report
+	ContainerUnownedExecutables *prometheus.GaugeVec
+
+	// BaselineViolations counts files accessed outside a container's
+	// baseline, labeled with the container's name
+	BaselineViolations *prometheus.CounterVec
+
+	// Package utilization per container and package manager, replaced on
+	// each report by SetPackageUsage
+	PackagesInstalled *prometheus.GaugeVec
+	PackagesAccessed  *prometheus.GaugeVec
+	PackageAccesses   *prometheus.GaugeVec
+
 	ReportWrites      prometheus.Counter
 	ReportWriteErrors prometheus.Counter
 
@@ -58,6 +91,42 @@ func New() *Metrics {
 			Name: "snoop_unique_files",
 			Help: "Current number of unique files recorded.",
 		}),
+		EventsBySyscall: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snoop_events_by_syscall_total",
+			Help: "Total number of file access events received from eBPF per syscall.",
+		}, []string{"syscall"}),
+		EventsUnknownContainer: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snoop_events_unknown_container_total",
+			Help: "Total number of events from cgroups of containers that aren't tracked.",
+		}, []string{"cgroup_id"}),
+		ContainerEventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snoop_container_events_received_total",
+			Help: "Total number of file access events received per container.",
+		}, []string{"container"}),
+		ContainerUniqueFiles: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "snoop_container_unique_files",
+			Help: "Current number of unique files recorded per container.",
+		}, []string{"container"}),
+		ContainerUnownedExecutables: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "snoop_container_unowned_executables",
+			Help: "Number of files the container executed that no installed package owns.",
+		}, []string{"container"}),
+		BaselineViolations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "snoop_baseline_violations_total",
+			Help: "Total number of files accessed outside the container's baseline.",
+		}, []string{"container"}),
+		PackagesInstalled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "snoop_packages_total",
+			Help: "Number of installed packages per container and package manager.",
+		}, []string{"container", "manager"}),
+		PackagesAccessed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "snoop_packages_accessed",
+			Help: "Number of installed packages with at least one accessed file per container and package manager.",
+		}, []string{"container", "manager"}),
+		PackageAccesses: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "snoop_package_accesses",
+			Help: "Accesses to a package's files, including repeats, for each container's most accessed packages.",
+		}, []string{"container", "manager", "package"}),
 		ReportWrites: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "snoop_report_writes_total",
 			Help: "Total number of successful report writes.",
@@ -78,6 +147,15 @@ func New() *Metrics {
 		m.EventsDropped,
 		m.EventsEvicted,
 		m.UniqueFiles,
+		m.EventsBySyscall,
+		m.EventsUnknownContainer,
+		m.ContainerEventsReceived,
+		m.ContainerUniqueFiles,
+		m.ContainerUnownedExecutables,
+		m.BaselineViolations,
+		m.PackagesInstalled,
+		m.PackagesAccessed,
+		m.PackageAccesses,
 		m.ReportWrites,
 		m.ReportWriteErrors,
 	)
@@ -89,6 +167,125 @@ func New() *Metrics {
 	return m
 }
 
+// RingBuffer reports the state of the eBPF ring buffer events are read
+// from.
+type RingBuffer interface {
+	BufferSize() int   // Size in bytes
+	PendingBytes() int // Bytes written but not read yet
+	Backlog() int      // Events waiting to be read
+}
+
+// RegisterRingBuffer registers gauges for rb's size, fill level, and
+// backlog, read on each scrape so that a filling buffer can be alerted on
+// before events are dropped.
+func (m *Metrics) RegisterRingBuffer(rb RingBuffer) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "snoop_ring_buffer_size_bytes",
+			Help: "Size of the eBPF ring buffer in bytes.",
+		}, func() float64 { return float64(rb.BufferSize()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "snoop_ring_buffer_used_bytes",
+			Help: "Bytes in the eBPF ring buffer waiting to be read.",
+		}, func() float64 { return float64(rb.PendingBytes()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "snoop_ring_buffer_utilization_ratio",
+			Help: "Fraction of the eBPF ring buffer waiting to be read; events are dropped when it reaches 1.",
+		}, func() float64 {
+			size := rb.BufferSize()
+			if size == 0 {
+				return 0
+			}
+			return float64(rb.PendingBytes()) / float64(size)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "snoop_ring_buffer_backlog_events",
+			Help: "Events in the eBPF ring buffer waiting to be read.",
+		}, func() float64 { return float64(rb.Backlog()) }),
+	)
+}
+
+// RegisterSampler registers the current sampling rate and the events
+// skipped by sampling, read on each scrape.
+func (m *Metrics) RegisterSampler(s *sampling.Sampler) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "snoop_sample_rate",
+			Help: "Current event sampling rate: 1 in this many events is processed, 1 meaning every event.",
+		}, func() float64 { return float64(s.Stats().Rate) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "snoop_events_sampled_out_total",
+			Help: "Events skipped by sampling under backpressure.",
+		}, func() float64 { return float64(s.Stats().Skipped) }),
+	)
+}
+
+// RegisterRules registers the events matched by each file access rule,
+// labeled with its name and action, read from hits on each scrape. hits
+// must return the same rules, in the same order, on every call.
+func (m *Metrics) RegisterRules(hits func() []processor.RuleHits) {
+	for i, h := range hits() {
+		m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "snoop_rule_hits_total",
+			Help:        "Events matched by each file access rule.",
+			ConstLabels: prometheus.Labels{"rule": h.Rule, "action": string(h.Action)},
+		}, func() float64 { return float64(hits()[i].Hits) }))
+	}
+}
+
+// RegisterBuildInfo registers snoop_build_info, which is always 1 and
+// labeled with the build's version, commit, build date, Go version, and eBPF
+// object digest, so differences between reports can be traced to agent
+// versions.
+func (m *Metrics) RegisterBuildInfo(info version.Info) {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "snoop_build_info",
+		Help: "Build information of the running snoop, always 1.",
+		ConstLabels: prometheus.Labels{
+			"version":    info.Version,
+			"commit":     info.Commit,
+			"build_date": info.BuildDate,
+			"go_version": info.GoVersion,
+			"bpf_object": info.BPFObject,
+		},
+	})
+	g.Set(1)
+	m.registry.MustRegister(g)
+}
+
+// SetPackageUsage replaces the package utilization gauges with the packages
+// of each container, keyed by its container label. Only the topN most
+// accessed packages of each container get a snoop_package_accesses series,
+// bounding its cardinality (0 = none).
+func (m *Metrics) SetPackageUsage(usage map[string][]reporter.PackageReport, topN int) {
+	m.PackagesInstalled.Reset()
+	m.PackagesAccessed.Reset()
+	m.PackageAccesses.Reset()
+	for container, pkgs := range usage {
+		var accessed []reporter.PackageReport
+		for _, p := range pkgs {
+			if p.Name == packages.OrphanName {
+				continue
+			}
+			m.PackagesInstalled.WithLabelValues(container, p.Manager).Inc()
+			m.PackagesAccessed.WithLabelValues(container, p.Manager).Add(0)
+			if p.Used() {
+				m.PackagesAccessed.WithLabelValues(container, p.Manager).Inc()
+				accessed = append(accessed, p)
+			}
+		}
+		sort.Slice(accessed, func(i, j int) bool {
+			if accessed[i].AccessCount != accessed[j].AccessCount {
+				return accessed[i].AccessCount > accessed[j].AccessCount
+			}
+			return accessed[i].Name < accessed[j].Name
+		})
+		for _, p := range accessed[:min(topN, len(accessed))] {
+			m.PackageAccesses.WithLabelValues(container, p.Manager, p.Name).Set(float64(p.AccessCount))
+		}
+	}
+}
+
 // Handler returns an HTTP handler for the /metrics endpoint.
 func (m *Metrics) Handler() http.Handler {
 	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
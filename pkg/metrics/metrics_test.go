@@ -6,6 +6,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/imjasonh/snoop/pkg/policy"
+	"github.com/imjasonh/snoop/pkg/processor"
+	"github.com/imjasonh/snoop/pkg/reporter"
+	"github.com/imjasonh/snoop/pkg/sampling"
+	"github.com/imjasonh/snoop/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestNew(t *testing.T) {
@@ -28,6 +35,9 @@ func TestNew(t *testing.T) {
 	if m.UniqueFiles == nil {
 		t.Error("UniqueFiles is nil")
 	}
+	if m.EventsBySyscall == nil {
+		t.Error("EventsBySyscall is nil")
+	}
 	if m.ReportWrites == nil {
 		t.Error("ReportWrites is nil")
 	}
@@ -49,6 +59,12 @@ func TestMetricsHandler(t *testing.T) {
 	m.EventsExcluded.Inc()
 	m.EventsDuplicate.Inc()
 	m.UniqueFiles.Set(42)
+	m.EventsBySyscall.WithLabelValues("openat").Add(2)
+	m.EventsUnknownContainer.WithLabelValues("9999").Inc()
+	m.ContainerEventsReceived.WithLabelValues("nginx").Add(3)
+	m.ContainerUniqueFiles.WithLabelValues("nginx").Set(7)
+	m.BaselineViolations.WithLabelValues("nginx").Inc()
+	m.ContainerUnownedExecutables.WithLabelValues("nginx").Set(1)
 	m.ReportWrites.Inc()
 
 	// Create test server with metrics handler
@@ -97,6 +113,30 @@ func TestMetricsHandler(t *testing.T) {
 		desc:   "unique files gauge",
 		metric: "snoop_unique_files",
 		value:  "42",
+	}, {
+		desc:   "events by syscall counter",
+		metric: `snoop_events_by_syscall_total{syscall="openat"}`,
+		value:  "2",
+	}, {
+		desc:   "unknown container events counter",
+		metric: `snoop_events_unknown_container_total{cgroup_id="9999"}`,
+		value:  "1",
+	}, {
+		desc:   "per-container events counter",
+		metric: `snoop_container_events_received_total{container="nginx"}`,
+		value:  "3",
+	}, {
+		desc:   "per-container unique files gauge",
+		metric: `snoop_container_unique_files{container="nginx"}`,
+		value:  "7",
+	}, {
+		desc:   "baseline violations counter",
+		metric: `snoop_baseline_violations_total{container="nginx"}`,
+		value:  "1",
+	}, {
+		desc:   "per-container unowned executables gauge",
+		metric: `snoop_container_unowned_executables{container="nginx"}`,
+		value:  "1",
 	}, {
 		desc:   "report writes counter",
 		metric: "snoop_report_writes_total",
@@ -154,3 +194,180 @@ func TestProcessMetricsIncluded(t *testing.T) {
 		t.Error("Go runtime metrics not found in output")
 	}
 }
+
+type fakeRingBuffer struct{ size, pending, backlog int }
+
+func (f *fakeRingBuffer) BufferSize() int   { return f.size }
+func (f *fakeRingBuffer) PendingBytes() int { return f.pending }
+func (f *fakeRingBuffer) Backlog() int      { return f.backlog }
+
+func TestRegisterRingBuffer(t *testing.T) {
+	m := New()
+	rb := &fakeRingBuffer{size: 4096, pending: 1024, backlog: 3}
+	m.RegisterRingBuffer(rb)
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	scrape := func() string {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Failed to fetch metrics: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read response body: %v", err)
+		}
+		return string(body)
+	}
+
+	content := scrape()
+	for _, want := range []string{
+		"snoop_ring_buffer_size_bytes 4096",
+		"snoop_ring_buffer_used_bytes 1024",
+		"snoop_ring_buffer_utilization_ratio 0.25",
+		"snoop_ring_buffer_backlog_events 3",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected metric line %q not found in output:\n%s", want, content)
+		}
+	}
+
+	// Gauges are read on each scrape
+	rb.pending = 4096
+	if content := scrape(); !strings.Contains(content, "snoop_ring_buffer_utilization_ratio 1") {
+		t.Errorf("utilization not updated:\n%s", content)
+	}
+}
+
+func TestRegisterBuildInfo(t *testing.T) {
+	m := New()
+	m.RegisterBuildInfo(version.Info{Version: "v1.2.3", Commit: "abc123", GoVersion: "go1.25.5", BPFObject: "sha256:0123"})
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	want := `snoop_build_info{bpf_object="sha256:0123",build_date="",commit="abc123",go_version="go1.25.5",version="v1.2.3"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("Expected metric line %q not found in output:\n%s", want, body)
+	}
+}
+
+func TestRegisterSampler(t *testing.T) {
+	m := New()
+	m.RegisterSampler(sampling.New(4))
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	for _, want := range []string{"snoop_sample_rate 1", "snoop_events_sampled_out_total 0"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected metric line %q not found in output:\n%s", want, body)
+		}
+	}
+}
+
+func TestRegisterRules(t *testing.T) {
+	m := New()
+	m.RegisterRules(func() []processor.RuleHits {
+		return []processor.RuleHits{
+			{Rule: "app-secrets", Action: policy.Allow, Hits: 7},
+			{Rule: "secrets", Action: policy.Alert, Hits: 2},
+		}
+	})
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	for _, want := range []string{
+		`snoop_rule_hits_total{action="allow",rule="app-secrets"} 7`,
+		`snoop_rule_hits_total{action="alert",rule="secrets"} 2`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected metric line %q not found in output:\n%s", want, body)
+		}
+	}
+}
+
+func TestSetPackageUsage(t *testing.T) {
+	m := New()
+	m.SetPackageUsage(map[string][]reporter.PackageReport{
+		"app": {
+			{Name: "busybox", Manager: "apk", TotalFiles: 400, AccessedFiles: 3, AccessCount: 50},
+			{Name: "musl", Manager: "apk", TotalFiles: 3, AccessedFiles: 1, AccessCount: 90},
+			{Name: "curl", Manager: "apk", TotalFiles: 10, AccessedFiles: 1, AccessCount: 5},
+			{Name: "zlib", Manager: "apk", TotalFiles: 2},
+			{Name: "flask", Manager: "pip", TotalFiles: 40},
+			{Name: "(orphan)", Manager: "none", AccessedFiles: 7, AccessCount: 7},
+		},
+	}, 2)
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to fetch metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	content := string(body)
+
+	for _, want := range []string{
+		`snoop_packages_total{container="app",manager="apk"} 4`,
+		`snoop_packages_total{container="app",manager="pip"} 1`,
+		`snoop_packages_accessed{container="app",manager="apk"} 3`,
+		`snoop_packages_accessed{container="app",manager="pip"} 0`,
+		`snoop_package_accesses{container="app",manager="apk",package="musl"} 90`,
+		`snoop_package_accesses{container="app",manager="apk",package="busybox"} 50`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected metric line %q not found in output:\n%s", want, content)
+		}
+	}
+	for _, unwanted := range []string{`package="curl"`, `manager="none"`} {
+		if strings.Contains(content, unwanted) {
+			t.Errorf("Unexpected %s in output:\n%s", unwanted, content)
+		}
+	}
+
+	// Each call replaces the previous report's series
+	m.SetPackageUsage(map[string][]reporter.PackageReport{}, 2)
+	if n := testCollect(t, m.PackagesInstalled); n != 0 {
+		t.Errorf("%d package series left after an empty report", n)
+	}
+}
+
+func testCollect(t *testing.T, c prometheus.Collector) int {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+	return len(ch)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/reporter"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PushReporter pushes the current metrics to a Prometheus Pushgateway
+// every time a report is written. It implements reporter.Reporter so it
+// can be combined with other reporters in a reporter.MultiReporter.
+type PushReporter struct {
+	pusher *push.Pusher
+	url    string
+}
+
+var _ reporter.Reporter = (*PushReporter)(nil)
+
+// NewPushReporter creates a reporter that pushes m's registry to the
+// Pushgateway at url under the given job name. Non-empty grouping labels
+// are attached to the pushed group.
+func NewPushReporter(ctx context.Context, m *Metrics, url, job string, grouping map[string]string) *PushReporter {
+	pusher := push.New(url, job).Gatherer(m.registry)
+	for k, v := range grouping {
+		if v != "" {
+			pusher = pusher.Grouping(k, v)
+		}
+	}
+	clog.FromContext(ctx).Infof("Initialized Pushgateway reporter (url: %s, job: %s)", url, job)
+	return &PushReporter{pusher: pusher, url: url}
+}
+
+// Update pushes all metrics, replacing the previous push for this group.
+func (p *PushReporter) Update(ctx context.Context, _ *reporter.Report) error {
+	if err := p.pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("pushing metrics to %s: %w", p.url, err)
+	}
+	return nil
+}
+
+// Close is a no-op for PushReporter.
+func (p *PushReporter) Close() error {
+	return nil
+}
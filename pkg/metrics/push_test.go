@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/reporter"
+)
+
+func TestPushReporter(t *testing.T) {
+	var gotPath, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s, want PUT", r.Method)
+		}
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	m := New()
+	m.UniqueFiles.Set(7)
+
+	p := NewPushReporter(context.Background(), m, server.URL, "snoop", map[string]string{"pod": "my-app", "namespace": ""})
+	if err := p.Update(context.Background(), &reporter.Report{}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if !strings.Contains(gotPath, "/job/snoop") {
+		t.Errorf("path = %q, want job grouping", gotPath)
+	}
+	if !strings.Contains(gotPath, "/pod/my-app") {
+		t.Errorf("path = %q, want pod grouping", gotPath)
+	}
+	if strings.Contains(gotPath, "namespace") {
+		t.Errorf("path = %q, empty grouping labels should be skipped", gotPath)
+	}
+	if gotBody == "" {
+		t.Error("expected metrics in push body")
+	}
+}
+
+func TestPushReporterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	p := NewPushReporter(context.Background(), New(), server.URL, "snoop", nil)
+	if err := p.Update(context.Background(), &reporter.Report{}); err == nil {
+		t.Error("expected error for failed push")
+	}
+}
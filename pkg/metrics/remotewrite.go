@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/golang/snappy"
+	"github.com/imjasonh/snoop/pkg/reporter"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWriteReporter pushes per-container series to a Prometheus
+// remote-write endpoint every time a report is written, for environments
+// where scraping sidecars is not allowed. It implements reporter.Reporter.
+type RemoteWriteReporter struct {
+	url    string
+	client *http.Client
+}
+
+var _ reporter.Reporter = (*RemoteWriteReporter)(nil)
+
+// NewRemoteWriteReporter creates a reporter that sends samples to the given
+// remote-write URL (e.g. "http://prometheus:9090/api/v1/write").
+func NewRemoteWriteReporter(ctx context.Context, url string) *RemoteWriteReporter {
+	clog.FromContext(ctx).Infof("Initialized remote-write reporter (url: %s)", url)
+	return &RemoteWriteReporter{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Update sends the current per-container stats as remote-write samples.
+func (r *RemoteWriteReporter) Update(ctx context.Context, report *reporter.Report) error {
+	body := snappy.Encode(nil, encodeWriteRequest(reportSeries(report, time.Now())))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote-writing to %s: %w", r.url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("remote-writing to %s: unexpected status %s", r.url, resp.Status)
+	}
+	return nil
+}
+
+// Close is a no-op for RemoteWriteReporter.
+func (r *RemoteWriteReporter) Close() error {
+	return nil
+}
+
+// label is a Prometheus label name/value pair.
+type label struct {
+	name, value string
+}
+
+// series is a single remote-write time series with one sample.
+type series struct {
+	labels    []label
+	value     float64
+	timestamp time.Time
+}
+
+// reportSeries builds the series pushed for a report.
+func reportSeries(report *reporter.Report, now time.Time) []series {
+	podLabels := func(extra ...label) []label {
+		ls := []label{{"job", "snoop"}}
+		if report.PodName != "" {
+			ls = append(ls, label{"pod", report.PodName})
+		}
+		if report.Namespace != "" {
+			ls = append(ls, label{"namespace", report.Namespace})
+		}
+		return append(ls, extra...)
+	}
+
+	result := []series{{
+		labels:    podLabels(label{"__name__", "snoop_events_dropped_total"}),
+		value:     float64(report.DroppedEvents),
+		timestamp: now,
+	}}
+	for _, c := range report.Containers {
+		container := label{"container", c.Name}
+		result = append(result,
+			series{podLabels(label{"__name__", "snoop_container_unique_files"}, container), float64(c.UniqueFiles), now},
+			series{podLabels(label{"__name__", "snoop_container_events_total"}, container), float64(c.TotalEvents), now},
+			series{podLabels(label{"__name__", "snoop_container_evicted_total"}, container), float64(c.EvictedFiles), now},
+		)
+	}
+	return result
+}
+
+// encodeWriteRequest encodes series as a prometheus.WriteRequest protobuf:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(ss []series) []byte {
+	var req []byte
+	for _, s := range ss {
+		// Remote-write requires labels sorted by name
+		labels := make([]label, len(s.labels))
+		copy(labels, s.labels)
+		sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+		var ts []byte
+		for _, l := range labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp.UnixMilli()))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/imjasonh/snoop/pkg/reporter"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodedSeries is a remote-write series decoded for assertions.
+type decodedSeries struct {
+	labels map[string]string
+	value  float64
+}
+
+// decodeWriteRequest parses a prometheus.WriteRequest protobuf.
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	t.Helper()
+	var result []decodedSeries
+	for len(b) > 0 {
+		_, _, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		tsBytes, n := protowire.ConsumeBytes(b)
+		b = b[n:]
+
+		s := decodedSeries{labels: map[string]string{}}
+		for len(tsBytes) > 0 {
+			num, _, n := protowire.ConsumeTag(tsBytes)
+			tsBytes = tsBytes[n:]
+			field, n := protowire.ConsumeBytes(tsBytes)
+			tsBytes = tsBytes[n:]
+			switch num {
+			case 1: // Label
+				var name, value string
+				for len(field) > 0 {
+					fnum, _, n := protowire.ConsumeTag(field)
+					field = field[n:]
+					v, n := protowire.ConsumeString(field)
+					field = field[n:]
+					if fnum == 1 {
+						name = v
+					} else {
+						value = v
+					}
+				}
+				s.labels[name] = value
+			case 2: // Sample
+				_, _, n := protowire.ConsumeTag(field)
+				field = field[n:]
+				bits, _ := protowire.ConsumeFixed64(field)
+				s.value = math.Float64frombits(bits)
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func TestRemoteWriteReporter(t *testing.T) {
+	var got []decodedSeries
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" {
+			t.Errorf("Content-Encoding = %q, want snappy", r.Header.Get("Content-Encoding"))
+		}
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("Content-Type = %q, want application/x-protobuf", r.Header.Get("Content-Type"))
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Fatalf("snappy decode: %v", err)
+		}
+		got = decodeWriteRequest(t, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	rw := NewRemoteWriteReporter(context.Background(), server.URL)
+	report := &reporter.Report{
+		PodName:   "my-app",
+		Namespace: "default",
+		Containers: []reporter.ContainerReport{
+			{Name: "app", UniqueFiles: 42, TotalEvents: 100, EvictedFiles: 3},
+		},
+		DroppedEvents: 5,
+	}
+	if err := rw.Update(context.Background(), report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	byName := map[string]decodedSeries{}
+	for _, s := range got {
+		byName[s.labels["__name__"]] = s
+	}
+	for name, want := range map[string]float64{
+		"snoop_events_dropped_total":    5,
+		"snoop_container_unique_files":  42,
+		"snoop_container_events_total":  100,
+		"snoop_container_evicted_total": 3,
+	} {
+		s, ok := byName[name]
+		if !ok {
+			t.Errorf("missing series %s", name)
+			continue
+		}
+		if s.value != want {
+			t.Errorf("%s = %v, want %v", name, s.value, want)
+		}
+		if s.labels["pod"] != "my-app" || s.labels["namespace"] != "default" {
+			t.Errorf("%s labels = %v, want pod and namespace", name, s.labels)
+		}
+	}
+	if c := byName["snoop_container_unique_files"].labels["container"]; c != "app" {
+		t.Errorf("container label = %q, want app", c)
+	}
+}
+
+func TestRemoteWriteReporterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	rw := NewRemoteWriteReporter(context.Background(), server.URL)
+	if err := rw.Update(context.Background(), &reporter.Report{}); err == nil {
+		t.Error("expected error for 400 response")
+	}
+}
@@ -0,0 +1,237 @@
+// Package mounts reads a process's mount table, so accessed files can be
+// classified by the kind of volume they live on.
+package mounts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Kinds of volumes Classify recognizes.
+const (
+	// Tmpfs is an in-memory filesystem, such as /dev/shm, a Kubernetes
+	// Secret or projected volume, or an emptyDir with medium Memory. Their
+	// mounts look alike from inside the container.
+	Tmpfs = "tmpfs"
+
+	// EmptyDir is a Kubernetes emptyDir volume backed by the node's disk.
+	EmptyDir = "emptyDir"
+
+	// ConfigMap, Secret, Projected, and DownwardAPI are Kubernetes volumes
+	// of those types that the kubelet writes to the node's disk.
+	ConfigMap   = "configMap"
+	Secret      = "secret"
+	Projected   = "projected"
+	DownwardAPI = "downwardAPI"
+
+	// PVC is a persistent volume: a network filesystem, a whole block
+	// device, or a volume of another kubelet plugin such as CSI.
+	PVC = "pvc"
+
+	// HostPath is a directory of the node's filesystem.
+	HostPath = "hostPath"
+
+	// SubPath is a single directory or file of a volume mounted with
+	// subPath, whose volume type the mount doesn't show.
+	SubPath = "subPath"
+
+	// Runtime is a file the kubelet or container runtime provides, such as
+	// /etc/hosts, /etc/resolv.conf, or the termination log.
+	Runtime = "runtime"
+
+	// Image is the class of files on none of the above: the container's
+	// own root filesystem. Classify never returns it.
+	Image = "image"
+)
+
+// Pod volumes are bind mounts of a directory the kubelet creates under
+// /var/lib/kubelet/pods/<uid>/volumes/kubernetes.io~<plugin>/, which is the
+// mount root when the directory is on the node's disk. subPath mounts are
+// bind mounts from volume-subpaths/ instead, and files the kubelet provides
+// itself, such as etc-hosts, come from the pod directory.
+const (
+	podsDir       = "/kubelet/pods/"
+	pluginPrefix  = "/volumes/kubernetes.io~"
+	subPathPrefix = "/volume-subpaths/"
+)
+
+// volumePlugins maps kubelet volume plugin names to kinds. Other plugins are
+// persistent volumes.
+var volumePlugins = map[string]string{
+	"empty-dir":    EmptyDir,
+	"configmap":    ConfigMap,
+	"secret":       Secret,
+	"projected":    Projected,
+	"downward-api": DownwardAPI,
+}
+
+// runtimeDirs appear in the roots of the files container runtimes bind mount
+// into containers, such as resolv.conf and hostname.
+var runtimeDirs = []string{"/io.containerd.", "/docker/containers/", "/containers/storage/"}
+
+// networkFSTypes are filesystems a persistent volume may be.
+var networkFSTypes = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "ceph": true,
+	"glusterfs": true, "lustre": true, "9p": true,
+}
+
+// Mount is one line of a mountinfo listing (see proc(5)).
+type Mount struct {
+	// Root is the directory of the mounted filesystem that is mounted, and
+	// Point where it is mounted.
+	Root, Point string
+
+	// FSType is the filesystem type, e.g. "tmpfs", and Source the mount
+	// source, e.g. a device.
+	FSType, Source string
+
+	// Options are the comma-separated superblock options, e.g. an
+	// overlayfs mount's lowerdir. Their values are still escaped, since
+	// commas in them are; see Unescape.
+	Options string
+}
+
+// Table is a process's mounts, in mount order.
+type Table []Mount
+
+// Fields of a mountinfo line.
+const (
+	rootField       = 3
+	mountPointField = 4
+	minFields       = 10
+)
+
+// ParseMountInfo parses a /proc/<pid>/mountinfo listing.
+func ParseMountInfo(r io.Reader) (Table, error) {
+	var t Table
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < minFields {
+			continue
+		}
+		// Optional fields end with a "-" separator, followed by the
+		// filesystem type, source, and super options
+		sep := -1
+		for i := mountPointField + 1; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+2 >= len(fields) {
+			continue
+		}
+		m := Mount{
+			Root:   Unescape(fields[rootField]),
+			Point:  Unescape(fields[mountPointField]),
+			FSType: fields[sep+1],
+			Source: Unescape(fields[sep+2]),
+		}
+		if sep+3 < len(fields) {
+			m.Options = fields[sep+3]
+		}
+		t = append(t, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading mountinfo: %w", err)
+	}
+	return t, nil
+}
+
+// Load reads the mount table from the mountinfo file at path, e.g.
+// /proc/<pid>/mountinfo.
+func Load(path string) (Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// Find returns the mount path is on: the one with the longest mount point
+// containing it, the latest if several share it. It returns nil if no mount
+// contains path.
+func (t Table) Find(path string) *Mount {
+	var found *Mount
+	for i := range t {
+		m := &t[i]
+		if !under(path, m.Point) {
+			continue
+		}
+		if found == nil || len(m.Point) >= len(found.Point) {
+			found = m
+		}
+	}
+	return found
+}
+
+// under reports whether path is dir or inside it.
+func under(path, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, dir) && path[len(dir)] == '/'
+}
+
+// Classify returns the kind of volume m is, or "" for the container's root
+// filesystem and pseudo filesystems such as proc. The kind is inferred from
+// the mount's root, filesystem type and source, so a hostPath volume of a
+// whole filesystem looks like a PVC.
+func Classify(m *Mount) string {
+	if i := strings.Index(m.Root, pluginPrefix); i >= 0 {
+		plugin, _, _ := strings.Cut(m.Root[i+len(pluginPrefix):], "/")
+		if kind, ok := volumePlugins[plugin]; ok {
+			return kind
+		}
+		return PVC
+	}
+	switch {
+	case strings.Contains(m.Root, podsDir) && strings.Contains(m.Root, subPathPrefix):
+		return SubPath
+	case strings.Contains(m.Root, podsDir):
+		return Runtime
+	case m.FSType == "tmpfs":
+		return Tmpfs
+	case networkFSTypes[m.FSType] || strings.HasPrefix(m.FSType, "fuse."):
+		return PVC
+	case m.FSType == "overlay" || !strings.HasPrefix(m.Source, "/dev/"):
+		// The root filesystem, or a pseudo filesystem
+		return ""
+	}
+	for _, dir := range runtimeDirs {
+		if strings.Contains(m.Root, dir) {
+			return Runtime
+		}
+	}
+	if m.Root == "/" {
+		return PVC
+	}
+	return HostPath
+}
+
+// Unescape decodes the octal escapes (e.g. \040 for a space) mountinfo
+// uses for special characters in paths.
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package mounts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMountInfo = `1200 1100 0:310 / / rw,relatime master:1 - overlay overlay rw,lowerdir=/snapshots/1/fs,upperdir=/snapshots/2/fs
+1201 1200 0:21 / /proc rw,nosuid - proc proc rw
+1202 1200 0:312 / /dev rw,nosuid - tmpfs tmpfs rw,size=65536k
+1203 1202 0:313 / /dev/shm rw - tmpfs shm rw,size=65536k
+1204 1200 8:1 /var/lib/kubelet/pods/0f1e/volumes/kubernetes.io~empty-dir/cache /cache rw,relatime - ext4 /dev/sda1 rw
+1205 1200 0:314 / /var/run/secrets/kubernetes.io/serviceaccount ro,relatime - tmpfs tmpfs rw,size=4096k
+1206 1200 8:1 /var/lib/kubelet/pods/0f1e/etc-hosts /etc/hosts rw,relatime - ext4 /dev/sda1 rw
+1207 1200 8:1 /var/lib/kubelet/pods/0f1e/volumes/kubernetes.io~empty-dir/my\040data /my\040data rw - ext4 /dev/sda1 rw
+1208 1200 8:1 /kubelet/pods/0f1e/volumes/kubernetes.io~configmap/cfg /etc/app ro - xfs /dev/nvme0n1p1 rw
+1209 1200 8:1 /var/lib/kubelet/pods/0f1e/volumes/kubernetes.io~secret/tls /etc/tls ro - ext4 /dev/sda1 rw
+1210 1200 8:1 /var/lib/kubelet/pods/0f1e/volumes/kubernetes.io~csi/pvc-123/mount /data rw - ext4 /dev/sda1 rw
+1211 1200 8:16 / /db rw - ext4 /dev/sdb rw
+1212 1200 0:60 / /shared rw - nfs4 10.0.0.2:/export rw
+1213 1200 8:1 /var/log /host/log ro - ext4 /dev/sda1 rw
+1214 1200 8:1 /var/lib/kubelet/pods/0f1e/volume-subpaths/cfg/app/0 /etc/app.conf ro - ext4 /dev/sda1 rw
+1215 1200 8:1 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/ab12/resolv.conf /etc/resolv.conf ro - ext4 /dev/sda1 rw
+1216 1200 8:1 /var/lib/kubelet/pods/0f1e/containers/app/5d3c /dev/termination-log rw - ext4 /dev/sda1 rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	table, err := ParseMountInfo(strings.NewReader(testMountInfo))
+	if err != nil {
+		t.Fatalf("ParseMountInfo failed: %v", err)
+	}
+	if len(table) != 17 {
+		t.Fatalf("got %d mounts, want 17", len(table))
+	}
+	want := Mount{Root: "/var/lib/kubelet/pods/0f1e/volumes/kubernetes.io~empty-dir/my data", Point: "/my data", FSType: "ext4", Source: "/dev/sda1", Options: "rw"}
+	if table[7] != want {
+		t.Errorf("mount = %+v, want %+v", table[7], want)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	if got, want := Unescape(`/var/lib/my\040dir`), "/var/lib/my dir"; got != want {
+		t.Errorf("Unescape = %q, want %q", got, want)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	table, err := ParseMountInfo(strings.NewReader(testMountInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path, point, kind string
+	}{
+		{"/etc/passwd", "/", ""},
+		{"/etc/hosts", "/etc/hosts", Runtime},
+		{"/proc/self/status", "/proc", ""},
+		{"/etc/app/app.yaml", "/etc/app", ConfigMap},
+		{"/etc/tls/tls.crt", "/etc/tls", Secret},
+		{"/data/db", "/data", PVC},
+		{"/db/pg_hba.conf", "/db", PVC},
+		{"/shared/x", "/shared", PVC},
+		{"/host/log/syslog", "/host/log", HostPath},
+		{"/etc/app.conf", "/etc/app.conf", SubPath},
+		{"/etc/resolv.conf", "/etc/resolv.conf", Runtime},
+		{"/dev/termination-log", "/dev/termination-log", Runtime},
+		{"/dev/shm/sem.lock", "/dev/shm", Tmpfs},
+		{"/dev/shmx", "/dev", Tmpfs},
+		{"/cache/index", "/cache", EmptyDir},
+		{"/cachex", "/", ""},
+		{"/var/run/secrets/kubernetes.io/serviceaccount/token", "/var/run/secrets/kubernetes.io/serviceaccount", Tmpfs},
+		{"/my data/x", "/my data", EmptyDir},
+	} {
+		m := table.Find(tt.path)
+		if m == nil {
+			t.Errorf("Find(%q) = nil", tt.path)
+			continue
+		}
+		if m.Point != tt.point {
+			t.Errorf("Find(%q) = %s, want %s", tt.path, m.Point, tt.point)
+		}
+		if got := Classify(m); got != tt.kind {
+			t.Errorf("Classify(%s) = %q, want %q", m.Point, got, tt.kind)
+		}
+	}
+	if m := (Table{}).Find("/etc"); m != nil {
+		t.Errorf("Find in empty table = %+v", m)
+	}
+}
+
+func TestFindShadowed(t *testing.T) {
+	// The later of two mounts at the same point is the visible one
+	table := Table{
+		{Root: "/", Point: "/tmp", FSType: "ext4"},
+		{Root: "/", Point: "/tmp", FSType: "tmpfs"},
+	}
+	if m := table.Find("/tmp/x"); m == nil || m.FSType != "tmpfs" {
+		t.Errorf("Find = %+v, want the tmpfs mount", m)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(path, []byte(testMountInfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := Load(path)
+	if err != nil || len(table) != 17 {
+		t.Errorf("Load = %d mounts, %v", len(table), err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Thresholds configures which conditions trigger notifications.
+type Thresholds struct {
+	// WatchPaths lists paths whose first access should be reported.
+	// Entries ending in "/" match any path under that directory, entries
+	// containing glob metacharacters are matched with path.Match, and all
+	// others must match exactly.
+	WatchPaths []string
+
+	// DropRatePercent is the ring buffer drop rate, as a percentage of events
+	// in a report interval, above which a notification fires (0 = disabled).
+	DropRatePercent float64
+}
+
+// Monitor turns observations into notifications when thresholds are crossed.
+// Rate-based conditions are edge-triggered: they fire once when the condition
+// starts and re-arm only after it clears, so a sustained condition does not
+// produce a notification every interval.
+type Monitor struct {
+	notifier   Notifier
+	thresholds Thresholds
+	podName    string
+	namespace  string
+
+	mu           sync.Mutex
+	dropping     bool
+	evicting     map[string]bool
+	usedPackages map[string]bool
+}
+
+// NewMonitor creates a monitor that sends notifications to n.
+func NewMonitor(n Notifier, thresholds Thresholds, podName, namespace string) *Monitor {
+	return &Monitor{
+		notifier:     n,
+		thresholds:   thresholds,
+		podName:      podName,
+		namespace:    namespace,
+		evicting:     make(map[string]bool),
+		usedPackages: make(map[string]bool),
+	}
+}
+
+// FileAccessed should be called for each newly observed file in a container.
+func (m *Monitor) FileAccessed(container, filePath string) {
+	m.mu.Lock()
+	watched := MatchesAny(filePath, m.thresholds.WatchPaths)
+	m.mu.Unlock()
+	if !watched {
+		return
+	}
+	m.notify(Notification{
+		Kind:      KindWatchedPath,
+		Container: container,
+		Path:      filePath,
+		Message:   fmt.Sprintf("container %s accessed watched path %s", container, filePath),
+	})
+}
+
+// BaselineViolation should be called the first time a container accesses a
+// file outside its baseline.
+func (m *Monitor) BaselineViolation(container, filePath string) {
+	m.notify(Notification{
+		Kind:      KindBaselineViolation,
+		Container: container,
+		Path:      filePath,
+		Message:   fmt.Sprintf("container %s accessed %s, which is outside its baseline", container, filePath),
+	})
+}
+
+// RuleAlert should be called the first time a container's process, whose
+// executable is process, accesses filePath in a way the named rule alerts on.
+func (m *Monitor) RuleAlert(container, rule, filePath, process string) {
+	by := process
+	if by == "" {
+		by = "an exited process"
+	}
+	m.notify(Notification{
+		Kind:      KindRuleAlert,
+		Container: container,
+		Path:      filePath,
+		Rule:      rule,
+		Process:   process,
+		Message:   fmt.Sprintf("rule %s: container %s accessed %s by %s", rule, container, filePath, by),
+	})
+}
+
+// ProbeFailed should be called when the eBPF program fails to load, before
+// snoop exits.
+func (m *Monitor) ProbeFailed(err error) {
+	m.notify(Notification{
+		Kind:    KindProbeFailed,
+		Message: fmt.Sprintf("eBPF program failed to load, no file accesses are traced: %v", err),
+	})
+}
+
+// SetWatchPaths replaces the paths whose first access is reported, for
+// files accessed from now on.
+func (m *Monitor) SetWatchPaths(paths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.thresholds.WatchPaths = paths
+}
+
+// PackageUsed should be called when a file owned by a package is accessed.
+// A notification fires the first time each container uses each package.
+func (m *Monitor) PackageUsed(container, pkg string) {
+	key := container + "\x00" + pkg
+	m.mu.Lock()
+	seen := m.usedPackages[key]
+	m.usedPackages[key] = true
+	m.mu.Unlock()
+	if seen {
+		return
+	}
+	m.notify(Notification{
+		Kind:      KindPackageUsed,
+		Container: container,
+		Package:   pkg,
+		Message:   fmt.Sprintf("container %s used package %s for the first time", container, pkg),
+	})
+}
+
+// PackagesBaseline records packages a container was already using when its
+// package database was first loaded, so that only packages that become used
+// afterwards trigger PackageUsed notifications.
+func (m *Monitor) PackagesBaseline(container string, pkgs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, pkg := range pkgs {
+		m.usedPackages[container+"\x00"+pkg] = true
+	}
+}
+
+// DropRate returns the percentage of events dropped, out of those received
+// and dropped.
+func DropRate(received, dropped uint64) float64 {
+	total := received + dropped
+	if total == 0 {
+		return 0
+	}
+	return float64(dropped) / float64(total) * 100
+}
+
+// Interval should be called once per report interval with the number of
+// events received and dropped during the interval, and the number of paths
+// evicted per container during the interval.
+func (m *Monitor) Interval(received, dropped uint64, evicted map[string]uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.thresholds.DropRatePercent > 0 {
+		rate := DropRate(received, dropped)
+		exceeded := rate > m.thresholds.DropRatePercent
+		if exceeded && !m.dropping {
+			m.notifyLocked(Notification{
+				Kind:     KindDropRate,
+				DropRate: rate,
+				Dropped:  dropped,
+				Message:  fmt.Sprintf("ring buffer drop rate %.1f%% exceeds %.1f%% (%d events dropped)", rate, m.thresholds.DropRatePercent, dropped),
+			})
+		}
+		m.dropping = exceeded
+	}
+
+	for container, n := range evicted {
+		if n > 0 && !m.evicting[container] {
+			m.notifyLocked(Notification{
+				Kind:      KindEviction,
+				Container: container,
+				Evicted:   n,
+				Message:   fmt.Sprintf("container %s is evicting paths from its deduplication cache (%d evicted)", container, n),
+			})
+		}
+		m.evicting[container] = n > 0
+	}
+}
+
+func (m *Monitor) notify(n Notification) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifyLocked(n)
+}
+
+func (m *Monitor) notifyLocked(n Notification) {
+	n.Time = time.Now()
+	n.PodName = m.podName
+	n.Namespace = m.namespace
+	m.notifier.Notify(n)
+}
+
+// MatchesAny reports whether p matches any of the given watch patterns.
+// See Thresholds.WatchPaths for the matching rules.
+func MatchesAny(p string, patterns []string) bool {
+	for _, pattern := range patterns {
+		switch {
+		case strings.HasSuffix(pattern, "/"):
+			if strings.HasPrefix(p, pattern) {
+				return true
+			}
+		case strings.ContainsAny(pattern, "*?["):
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		default:
+			if p == pattern {
+				return true
+			}
+		}
+	}
+	return false
+}
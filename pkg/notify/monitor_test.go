@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// recorder collects notifications in memory.
+type recorder struct {
+	got []Notification
+}
+
+func (r *recorder) Notify(n Notification) {
+	r.got = append(r.got, n)
+}
+
+func TestMatchesAny(t *testing.T) {
+	patterns := []string{"/var/run/secrets/", "/etc/shadow", "/root/*.key"}
+	for _, tt := range []struct {
+		path string
+		want bool
+	}{
+		{"/var/run/secrets/kubernetes.io/token", true},
+		{"/etc/shadow", true},
+		{"/etc/shadow-", false},
+		{"/root/server.key", true},
+		{"/root/dir/server.key", false},
+		{"/etc/passwd", false},
+	} {
+		if got := MatchesAny(tt.path, patterns); got != tt.want {
+			t.Errorf("MatchesAny(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMonitorWatchedPath(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{WatchPaths: []string{"/etc/shadow"}}, "pod", "ns")
+
+	m.FileAccessed("app", "/etc/passwd")
+	m.FileAccessed("app", "/etc/shadow")
+
+	if len(r.got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(r.got))
+	}
+	n := r.got[0]
+	if n.Kind != KindWatchedPath || n.Container != "app" || n.Path != "/etc/shadow" {
+		t.Errorf("notification = %+v", n)
+	}
+	if n.PodName != "pod" || n.Namespace != "ns" || n.Time.IsZero() {
+		t.Errorf("notification missing pod metadata: %+v", n)
+	}
+}
+
+func TestMonitorDropRateEdgeTriggered(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{DropRatePercent: 10}, "", "")
+
+	m.Interval(100, 5, nil) // 4.8%, below threshold
+	if len(r.got) != 0 {
+		t.Fatalf("got %d notifications below threshold, want 0", len(r.got))
+	}
+
+	m.Interval(80, 20, nil) // 20%, crosses threshold
+	m.Interval(80, 20, nil) // still above, no repeat
+	if len(r.got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(r.got))
+	}
+	if r.got[0].Kind != KindDropRate || r.got[0].DropRate != 20 {
+		t.Errorf("notification = %+v, want drop rate 20", r.got[0])
+	}
+
+	m.Interval(100, 0, nil) // clears
+	m.Interval(50, 50, nil) // crosses again
+	if len(r.got) != 2 {
+		t.Errorf("got %d notifications after re-crossing, want 2", len(r.got))
+	}
+}
+
+func TestDropRate(t *testing.T) {
+	for _, tt := range []struct {
+		received, dropped uint64
+		want              float64
+	}{
+		{0, 0, 0},
+		{100, 0, 0},
+		{80, 20, 20},
+		{0, 10, 100},
+	} {
+		if got := DropRate(tt.received, tt.dropped); got != tt.want {
+			t.Errorf("DropRate(%d, %d) = %v, want %v", tt.received, tt.dropped, got, tt.want)
+		}
+	}
+}
+
+func TestMonitorDropRateDisabled(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{}, "", "")
+	m.Interval(0, 100, nil)
+	if len(r.got) != 0 {
+		t.Errorf("got %d notifications with drop rate disabled, want 0", len(r.got))
+	}
+}
+
+func TestMonitorEviction(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{}, "", "")
+
+	m.Interval(0, 0, map[string]uint64{"app": 0, "sidecar": 3})
+	m.Interval(0, 0, map[string]uint64{"app": 0, "sidecar": 7})
+	if len(r.got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(r.got))
+	}
+	if r.got[0].Kind != KindEviction || r.got[0].Container != "sidecar" || r.got[0].Evicted != 3 {
+		t.Errorf("notification = %+v", r.got[0])
+	}
+}
+
+func TestMonitorPackageUsedOnce(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{}, "", "")
+
+	m.PackageUsed("app", "curl")
+	m.PackageUsed("app", "curl")
+	m.PackageUsed("sidecar", "curl")
+
+	if len(r.got) != 2 {
+		t.Errorf("got %d notifications, want 2 (once per container/package)", len(r.got))
+	}
+}
+
+func TestMonitorPackagesBaseline(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{}, "", "")
+
+	m.PackagesBaseline("app", []string{"libc6", "bash"})
+	m.PackageUsed("app", "libc6")
+	m.PackageUsed("app", "curl")
+
+	if len(r.got) != 1 || r.got[0].Package != "curl" {
+		t.Errorf("got %+v, want a single notification for curl", r.got)
+	}
+}
+
+func TestMonitorSetWatchPaths(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{WatchPaths: []string{"/etc/shadow"}}, "pod", "ns")
+
+	m.SetWatchPaths([]string{"/etc/passwd"})
+	m.FileAccessed("app", "/etc/shadow")
+	m.FileAccessed("app", "/etc/passwd")
+
+	if len(r.got) != 1 || r.got[0].Path != "/etc/passwd" {
+		t.Errorf("notifications = %+v, want one for /etc/passwd", r.got)
+	}
+}
+
+func TestMonitorProbeFailed(t *testing.T) {
+	a, b := &recorder{}, &recorder{}
+	m := NewMonitor(MultiNotifier{a, b}, Thresholds{}, "pod", "ns")
+
+	m.ProbeFailed(errors.New("permission denied"))
+
+	for _, r := range []*recorder{a, b} {
+		if len(r.got) != 1 || r.got[0].Kind != KindProbeFailed || !strings.Contains(r.got[0].Message, "permission denied") {
+			t.Errorf("notifications = %+v, want one probe failure", r.got)
+		}
+	}
+}
+
+func TestMonitorRuleAlert(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{}, "pod", "ns")
+
+	m.RuleAlert("app", "secrets", "/var/run/secrets/token", "/bin/cat")
+	if len(r.got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(r.got))
+	}
+	n := r.got[0]
+	if n.Kind != KindRuleAlert || n.Rule != "secrets" || n.Process != "/bin/cat" || n.Path != "/var/run/secrets/token" {
+		t.Errorf("notification = %+v", n)
+	}
+	if want := "rule secrets: container app accessed /var/run/secrets/token by /bin/cat"; n.Message != want {
+		t.Errorf("message = %q, want %q", n.Message, want)
+	}
+}
+
+func TestMonitorBaselineViolation(t *testing.T) {
+	r := &recorder{}
+	m := NewMonitor(r, Thresholds{}, "pod", "ns")
+
+	m.BaselineViolation("app", "/bin/sh")
+	if len(r.got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(r.got))
+	}
+	n := r.got[0]
+	if n.Kind != KindBaselineViolation || n.Container != "app" || n.Path != "/bin/sh" || n.PodName != "pod" {
+		t.Errorf("notification = %+v", n)
+	}
+}
@@ -0,0 +1,167 @@
+// Package notify sends notifications when notable events occur.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// Kind identifies the type of notable event.
+type Kind string
+
+const (
+	// KindWatchedPath fires the first time a container accesses a watched path.
+	KindWatchedPath Kind = "watched_path_accessed"
+	// KindDropRate fires when the ring buffer drop rate rises above the threshold.
+	KindDropRate Kind = "drop_rate_exceeded"
+	// KindEviction fires when a container's deduplication cache starts evicting paths.
+	KindEviction Kind = "eviction"
+	// KindPackageUsed fires the first time a file from a package is accessed.
+	KindPackageUsed Kind = "package_used"
+	// KindProbeFailed fires when the eBPF program fails to load.
+	KindProbeFailed Kind = "probe_failed"
+	// KindBaselineViolation fires the first time a container accesses a
+	// file outside its baseline.
+	KindBaselineViolation Kind = "baseline_violation"
+	// KindRuleAlert fires the first time a container's process accesses a
+	// file in a way an alerting rule matches.
+	KindRuleAlert Kind = "rule_alert"
+)
+
+// Notification describes a notable event. It is the data passed to webhook templates.
+type Notification struct {
+	Kind      Kind      `json:"kind"`
+	Time      time.Time `json:"time"`
+	PodName   string    `json:"pod_name,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Package   string    `json:"package,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	Process   string    `json:"process,omitempty"`
+	DropRate  float64   `json:"drop_rate,omitempty"`
+	Dropped   uint64    `json:"dropped,omitempty"`
+	Evicted   uint64    `json:"evicted,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// Notifier delivers notifications.
+type Notifier interface {
+	Notify(n Notification)
+}
+
+// MultiNotifier fans out notifications to several notifiers.
+type MultiNotifier []Notifier
+
+// Notify forwards the notification to every notifier.
+func (m MultiNotifier) Notify(n Notification) {
+	for _, notifier := range m {
+		notifier.Notify(n)
+	}
+}
+
+// webhookQueueSize bounds pending notifications; when full, new ones are dropped.
+const webhookQueueSize = 100
+
+// Webhook delivers notifications by POSTing to a URL. Delivery happens on a
+// background goroutine so callers on the event path are never blocked.
+type Webhook struct {
+	ctx    context.Context
+	url    string
+	tmpl   *template.Template
+	client *http.Client
+	queue  chan Notification
+	wg     sync.WaitGroup
+}
+
+// NewWebhook creates a webhook notifier. If tmpl is empty, notifications are
+// sent as JSON; otherwise tmpl is a text/template executed against the
+// Notification to produce the request body.
+func NewWebhook(ctx context.Context, url, tmpl string) (*Webhook, error) {
+	w := &Webhook{
+		ctx:    ctx,
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan Notification, webhookQueueSize),
+	}
+	if tmpl != "" {
+		t, err := template.New("webhook").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("parsing webhook template: %w", err)
+		}
+		w.tmpl = t
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	clog.FromContext(ctx).Infof("Initialized webhook notifier (url: %s)", url)
+	return w, nil
+}
+
+// Notify queues a notification for delivery. If the queue is full the
+// notification is dropped and a warning is logged.
+func (w *Webhook) Notify(n Notification) {
+	select {
+	case w.queue <- n:
+	default:
+		clog.FromContext(w.ctx).Warnf("Webhook queue full, dropping %s notification", n.Kind)
+	}
+}
+
+// Close stops accepting notifications and waits for queued ones to be sent.
+func (w *Webhook) Close() error {
+	close(w.queue)
+	w.wg.Wait()
+	return nil
+}
+
+// run delivers queued notifications until the queue is closed.
+func (w *Webhook) run() {
+	defer w.wg.Done()
+	log := clog.FromContext(w.ctx)
+	for n := range w.queue {
+		if err := w.send(n); err != nil {
+			log.Warnf("Webhook delivery failed for %s notification: %v", n.Kind, err)
+		}
+	}
+}
+
+// send renders and POSTs a single notification.
+func (w *Webhook) send(n Notification) error {
+	var body bytes.Buffer
+	if w.tmpl != nil {
+		if err := w.tmpl.Execute(&body, n); err != nil {
+			return fmt.Errorf("executing template: %w", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(n); err != nil {
+		return fmt.Errorf("marshaling notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(context.WithoutCancel(w.ctx), http.MethodPost, w.url, &body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to %s: %w", w.url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting to %s: unexpected status %s", w.url, resp.Status)
+	}
+	return nil
+}
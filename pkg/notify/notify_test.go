@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWebhookJSON(t *testing.T) {
+	var mu sync.Mutex
+	var got []Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		mu.Lock()
+		got = append(got, n)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	w, err := NewWebhook(context.Background(), server.URL, "")
+	if err != nil {
+		t.Fatalf("NewWebhook failed: %v", err)
+	}
+	w.Notify(Notification{Kind: KindWatchedPath, Container: "app", Path: "/etc/shadow"})
+	w.Close()
+
+	if len(got) != 1 {
+		t.Fatalf("received %d notifications, want 1", len(got))
+	}
+	if got[0].Kind != KindWatchedPath || got[0].Path != "/etc/shadow" {
+		t.Errorf("notification = %+v, want watched path /etc/shadow", got[0])
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	w, err := NewWebhook(context.Background(), server.URL, `{"text": "{{.Kind}}: {{.Message}}"}`)
+	if err != nil {
+		t.Fatalf("NewWebhook failed: %v", err)
+	}
+	w.Notify(Notification{Kind: KindEviction, Message: "evicting"})
+	w.Close()
+
+	if want := `{"text": "eviction: evicting"}`; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestWebhookInvalidTemplate(t *testing.T) {
+	if _, err := NewWebhook(context.Background(), "http://localhost", "{{.Kind"); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
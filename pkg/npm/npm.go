@@ -0,0 +1,144 @@
+// Package npm reads installed Node.js packages from node_modules directories
+// for package attribution.
+package npm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+const (
+	// Manager identifies packages loaded by this package.
+	Manager = "npm"
+
+	// Ecosystem is the packages.DirLoader ecosystem name for Node.js.
+	Ecosystem = "npm"
+)
+
+// DirLoader returns a loader that discovers node_modules directories from
+// accessed paths and reads the packages installed in them.
+func DirLoader() packages.DirLoader {
+	return packages.DirLoader{
+		Ecosystem: Ecosystem,
+		Dir:       NodeModulesDir,
+		Load:      Load,
+	}
+}
+
+// NodeModulesDir returns the innermost node_modules directory containing p,
+// or "" if p is not inside one. Nested node_modules directories (dependencies
+// that couldn't be hoisted) are loaded separately when they are accessed.
+func NodeModulesDir(p string) string {
+	const name = "/node_modules/"
+	i := strings.LastIndex(p, name)
+	if i < 0 {
+		return ""
+	}
+	return p[:i+len(name)-1]
+}
+
+// Load reads every package installed directly in the node_modules directory
+// dir under root, including scoped (@scope/name) packages. Each package owns
+// the files under its directory, excluding its own nested node_modules.
+func Load(root, dir string) ([]*packages.Package, error) {
+	entries, err := os.ReadDir(filepath.Join(root, dir))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	var pkgs []*packages.Package
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if strings.HasPrefix(name, "@") {
+			scoped, err := os.ReadDir(filepath.Join(root, dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", path.Join(dir, name), err)
+			}
+			for _, se := range scoped {
+				if !se.IsDir() {
+					continue
+				}
+				pkg, err := loadPackage(root, dir, name+"/"+se.Name())
+				if err != nil {
+					return nil, err
+				}
+				if pkg != nil {
+					pkgs = append(pkgs, pkg)
+				}
+			}
+			continue
+		}
+		pkg, err := loadPackage(root, dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if pkg != nil {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+// loadPackage reads the package installed as name in the node_modules
+// directory dir. Returns nil if it has no package.json.
+func loadPackage(root, dir, name string) (*packages.Package, error) {
+	pkgDir := path.Join(dir, name)
+	data, err := os.ReadFile(filepath.Join(root, pkgDir, "package.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s/package.json: %w", pkgDir, err)
+	}
+	var manifest struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing %s/package.json: %w", pkgDir, err)
+	}
+	if manifest.Name == "" {
+		manifest.Name = name
+	}
+
+	var files []string
+	base := filepath.Join(root, pkgDir)
+	err = filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" && p != base {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, path.Join(pkgDir, filepath.ToSlash(rel)))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing files of %s: %w", pkgDir, err)
+	}
+
+	return &packages.Package{
+		Name:    manifest.Name,
+		Version: manifest.Version,
+		Manager: Manager,
+		Files:   files,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package npm
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNodeModulesDir(t *testing.T) {
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"/app/node_modules/express/index.js", "/app/node_modules"},
+		{"/app/node_modules/@babel/core/lib/index.js", "/app/node_modules"},
+		{"/app/node_modules/a/node_modules/b/index.js", "/app/node_modules/a/node_modules"},
+		{"/app/src/index.js", ""},
+	} {
+		if got := NodeModulesDir(tt.path); got != tt.want {
+			t.Errorf("NodeModulesDir(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	nm := "/app/node_modules"
+	writeFile(t, filepath.Join(root, nm, "express/package.json"), `{"name": "express", "version": "4.18.2"}`)
+	writeFile(t, filepath.Join(root, nm, "express/index.js"), "")
+	writeFile(t, filepath.Join(root, nm, "express/lib/router.js"), "")
+	writeFile(t, filepath.Join(root, nm, "express/node_modules/debug/package.json"), `{"name": "debug", "version": "2.6.9"}`)
+	writeFile(t, filepath.Join(root, nm, "@babel/core/package.json"), `{"name": "@babel/core", "version": "7.23.0"}`)
+	writeFile(t, filepath.Join(root, nm, "@babel/core/lib/index.js"), "")
+	writeFile(t, filepath.Join(root, nm, ".package-lock.json"), "{}")
+	writeFile(t, filepath.Join(root, nm, ".bin/express"), "")
+	writeFile(t, filepath.Join(root, nm, "not-a-package/README"), "")
+
+	pkgs, err := Load(root, nm)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d packages, want 2: %+v", len(pkgs), pkgs)
+	}
+
+	byName := map[string][]string{}
+	for _, p := range pkgs {
+		if p.Manager != Manager {
+			t.Errorf("%s manager = %q", p.Name, p.Manager)
+		}
+		byName[p.Name+"@"+p.Version] = p.Files
+	}
+
+	// Nested node_modules belong to their own directory, not the parent package
+	wantExpress := []string{"/app/node_modules/express/index.js", "/app/node_modules/express/lib/router.js", "/app/node_modules/express/package.json"}
+	if got := byName["express@4.18.2"]; !slices.Equal(got, wantExpress) {
+		t.Errorf("express files = %v, want %v", got, wantExpress)
+	}
+	if got := byName["@babel/core@7.23.0"]; len(got) != 2 {
+		t.Errorf("@babel/core files = %v, want 2 files", got)
+	}
+}
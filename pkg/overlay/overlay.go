@@ -0,0 +1,276 @@
+// Package overlay reads the overlayfs layer stack backing a container's root
+// filesystem, so accessed files can be attributed to the image layer that
+// provides them.
+package overlay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/imjasonh/snoop/pkg/mounts"
+	"golang.org/x/sys/unix"
+)
+
+// dockerLayerDB is where Docker records the diff ID and overlay2 directory
+// (cache ID) of each image layer, relative to the host root.
+const dockerLayerDB = "/var/lib/docker/image/overlay2/layerdb/sha256"
+
+// Layer is one directory of an overlayfs mount.
+type Layer struct {
+	// Index numbers layers from 1 for the base image layer upwards. The
+	// writable container layer, if any, is last.
+	Index int
+
+	// Dir is the layer's directory on the host.
+	Dir string
+
+	// Digest is the layer's diff ID (e.g. "sha256:..."), or "" if the
+	// container runtime's layer metadata couldn't be read.
+	Digest string
+
+	// Upper marks the writable layer holding files the container created or
+	// modified.
+	Upper bool
+}
+
+// Stack is the layers of an overlayfs mount, base layer first.
+type Stack struct {
+	Layers []Layer
+}
+
+// ParseMountInfo finds the overlayfs mount at / in a /proc/<pid>/mountinfo
+// listing and returns its layers. It returns an error if the root
+// filesystem isn't overlayfs.
+func ParseMountInfo(r io.Reader) (*Stack, error) {
+	table, err := mounts.ParseMountInfo(r)
+	if err != nil {
+		return nil, err
+	}
+	var options string
+	found := false
+	for _, m := range table {
+		if m.Point != "/" {
+			continue
+		}
+		// Later mounts at / shadow earlier ones
+		found = m.FSType == "overlay"
+		options = m.Options
+	}
+	if !found {
+		return nil, errors.New("root filesystem is not overlayfs")
+	}
+
+	var lower []string
+	var upper string
+	for _, opt := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "lowerdir":
+			lower = strings.Split(mounts.Unescape(value), ":")
+		case "upperdir":
+			upper = mounts.Unescape(value)
+		}
+	}
+	if len(lower) == 0 {
+		return nil, errors.New("overlayfs root mount has no lowerdir")
+	}
+
+	// lowerdir lists the topmost layer first
+	s := &Stack{}
+	for i := len(lower) - 1; i >= 0; i-- {
+		s.Layers = append(s.Layers, Layer{Index: len(s.Layers) + 1, Dir: lower[i]})
+	}
+	if upper != "" {
+		s.Layers = append(s.Layers, Layer{Index: len(s.Layers) + 1, Dir: upper, Upper: true})
+	}
+	return s, nil
+}
+
+// Load reads the layer stack from the mountinfo file at path (e.g.
+// /proc/<pid>/mountinfo). Layer directories are host paths, read under
+// hostRoot (e.g. "/host" when the host filesystem is mounted there, or ""
+// when running on the host). Digests are filled in for Docker's overlay2
+// layers; other runtimes don't keep the mapping in a readable form.
+func Load(path, hostRoot string) (*Stack, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening mountinfo: %w", err)
+	}
+	defer f.Close()
+	s, err := ParseMountInfo(f)
+	if err != nil {
+		return nil, err
+	}
+	s.resolveDockerDigests(hostRoot)
+	return s, nil
+}
+
+// resolveDockerDigests sets the digest of each layer that Docker's layer
+// database describes. Docker's lowerdirs are short symlinks
+// (overlay2/l/<link>) to overlay2/<cache-id>/diff.
+func (s *Stack) resolveDockerDigests(hostRoot string) {
+	entries, err := os.ReadDir(filepath.Join(hostRoot, dockerLayerDB))
+	if err != nil {
+		return
+	}
+	byCacheID := make(map[string]string, len(entries))
+	for _, e := range entries {
+		dir := filepath.Join(hostRoot, dockerLayerDB, e.Name())
+		cacheID, err := os.ReadFile(filepath.Join(dir, "cache-id"))
+		if err != nil {
+			continue
+		}
+		diffID, err := os.ReadFile(filepath.Join(dir, "diff"))
+		if err != nil {
+			continue
+		}
+		byCacheID[strings.TrimSpace(string(cacheID))] = strings.TrimSpace(string(diffID))
+	}
+
+	for i := range s.Layers {
+		l := &s.Layers[i]
+		if l.Upper || !filepath.IsAbs(l.Dir) {
+			continue
+		}
+		dir := l.Dir
+		if target, err := os.Readlink(filepath.Join(hostRoot, dir)); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(dir), target)
+			}
+			dir = target
+		}
+		if filepath.Base(dir) != "diff" {
+			continue
+		}
+		l.Digest = byCacheID[filepath.Base(filepath.Dir(dir))]
+	}
+}
+
+// Find returns the topmost layer providing path, reading layer directories
+// under hostRoot, or nil if no layer has it or a higher layer deleted it.
+func (s *Stack) Find(hostRoot, path string) *Layer {
+	l, _ := s.Stat(hostRoot, path)
+	return l
+}
+
+// Stat is like Find, but also returns the file's information in the layer.
+func (s *Stack) Stat(hostRoot, path string) (*Layer, os.FileInfo) {
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		info, err := os.Lstat(filepath.Join(hostRoot, s.Layers[i].Dir, path))
+		if err != nil {
+			continue
+		}
+		if isWhiteout(info) {
+			return nil, nil
+		}
+		return &s.Layers[i], info
+	}
+	return nil, nil
+}
+
+// Size is how much of the merged filesystem a layer provides.
+type Size struct {
+	// Files counts the regular files the layer provides, and Bytes their
+	// total size.
+	Files int
+	Bytes int64
+}
+
+// Measure returns the Size each layer contributes to the merged filesystem,
+// in the order of s.Layers, reading layer directories under hostRoot. Files
+// hidden by a higher layer, whether replaced, deleted by a whiteout, or
+// below an opaque directory, aren't counted. Layers that can't be read are
+// skipped.
+func (s *Stack) Measure(hostRoot string) []Size {
+	sizes := make([]Size, len(s.Layers))
+	s.Walk(hostRoot, func(_ string, layer int, info os.FileInfo) {
+		if info.Mode().IsRegular() {
+			sizes[layer].Files++
+			sizes[layer].Bytes += info.Size()
+		}
+	})
+	return sizes
+}
+
+// Walk calls fn for every entry of the merged filesystem other than
+// directories, with its path, the position in s.Layers of the layer
+// providing it, and its information in that layer, reading layer
+// directories under hostRoot. Entries hidden by a higher layer aren't
+// visited. Layers that can't be read are skipped.
+func (s *Stack) Walk(hostRoot string, fn func(path string, layer int, info os.FileInfo)) {
+	seen := make(map[string]bool)    // paths provided by a higher layer
+	deleted := make(map[string]bool) // paths hidden from lower layers
+	hidden := func(path string) bool {
+		for ; path != "/"; path = filepath.Dir(path) {
+			if deleted[path] {
+				return true
+			}
+		}
+		return false
+	}
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		// Whiteouts and opaque directories only apply to lower layers
+		var hides []string
+		dir := filepath.Join(hostRoot, s.Layers[i].Dir)
+		filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || file == dir {
+				return nil
+			}
+			path := "/" + filepath.ToSlash(strings.TrimPrefix(file, dir+string(filepath.Separator)))
+			if hidden(path) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if isOpaque(file) {
+					hides = append(hides, path)
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if isWhiteout(info) {
+				hides = append(hides, path)
+				return nil
+			}
+			if seen[path] {
+				return nil
+			}
+			seen[path] = true
+			fn(path, i, info)
+			return nil
+		})
+		for _, path := range hides {
+			deleted[path] = true
+		}
+	}
+}
+
+// isOpaque reports whether dir is an overlayfs opaque directory, hiding the
+// contents of the same directory in lower layers. Reading the attribute
+// requires CAP_SYS_ADMIN; without it, directories are assumed not opaque.
+func isOpaque(dir string) bool {
+	buf := make([]byte, 1)
+	n, err := unix.Lgetxattr(dir, "trusted.overlay.opaque", buf)
+	return err == nil && n == 1 && buf[0] == 'y'
+}
+
+// isWhiteout reports whether info is an overlayfs whiteout, a 0/0 character
+// device marking a file deleted from the layers below.
+func isWhiteout(info os.FileInfo) bool {
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Rdev == 0
+}
@@ -0,0 +1,134 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMountInfo = `22 1 0:21 / /proc rw,nosuid - proc proc rw
+1200 1100 0:310 / / rw,relatime master:1 - overlay overlay rw,lowerdir=/snapshots/3/fs:/snapshots/2/fs:/snapshots/1/fs,upperdir=/snapshots/4/fs,workdir=/snapshots/4/work
+1201 1200 0:311 / /etc/hosts rw - ext4 /dev/sda1 rw
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseMountInfo(t *testing.T) {
+	s, err := ParseMountInfo(strings.NewReader(testMountInfo))
+	if err != nil {
+		t.Fatalf("ParseMountInfo failed: %v", err)
+	}
+	want := []Layer{
+		{Index: 1, Dir: "/snapshots/1/fs"},
+		{Index: 2, Dir: "/snapshots/2/fs"},
+		{Index: 3, Dir: "/snapshots/3/fs"},
+		{Index: 4, Dir: "/snapshots/4/fs", Upper: true},
+	}
+	if len(s.Layers) != len(want) {
+		t.Fatalf("layers = %+v, want %+v", s.Layers, want)
+	}
+	for i := range want {
+		if s.Layers[i] != want[i] {
+			t.Errorf("layer %d = %+v, want %+v", i, s.Layers[i], want[i])
+		}
+	}
+}
+
+func TestParseMountInfoNotOverlay(t *testing.T) {
+	_, err := ParseMountInfo(strings.NewReader("1 0 8:1 / / rw - ext4 /dev/sda1 rw\n"))
+	if err == nil {
+		t.Error("ParseMountInfo succeeded for an ext4 root")
+	}
+}
+
+func TestFind(t *testing.T) {
+	host := t.TempDir()
+	writeFile(t, filepath.Join(host, "/snapshots/1/fs/bin/sh"), "base")
+	writeFile(t, filepath.Join(host, "/snapshots/1/fs/etc/os-release"), "base")
+	writeFile(t, filepath.Join(host, "/snapshots/3/fs/etc/os-release"), "override")
+	writeFile(t, filepath.Join(host, "/snapshots/4/fs/tmp/new"), "runtime")
+
+	s, err := ParseMountInfo(strings.NewReader(testMountInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int{
+		"/bin/sh":         1,
+		"/etc/os-release": 3,
+		"/tmp/new":        4,
+		"/missing":        0,
+	} {
+		l := s.Find(host, path)
+		got := 0
+		if l != nil {
+			got = l.Index
+		}
+		if got != want {
+			t.Errorf("Find(%q) = layer %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestDockerDigests(t *testing.T) {
+	host := t.TempDir()
+	writeFile(t, filepath.Join(host, "/var/lib/docker/overlay2/abc123/diff/bin/sh"), "sh")
+	if err := os.MkdirAll(filepath.Join(host, "/var/lib/docker/overlay2/l"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../abc123/diff", filepath.Join(host, "/var/lib/docker/overlay2/l/SHORT")); err != nil {
+		t.Fatal(err)
+	}
+	layer := filepath.Join(host, dockerLayerDB, "chain1")
+	writeFile(t, filepath.Join(layer, "cache-id"), "abc123")
+	writeFile(t, filepath.Join(layer, "diff"), "sha256:deadbeef")
+
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	writeFile(t, mountinfo, "1 0 0:50 / / rw - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/SHORT,upperdir=/var/lib/docker/overlay2/xyz/diff\n")
+	s, err := Load(mountinfo, host)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := s.Layers[0].Digest; got != "sha256:deadbeef" {
+		t.Errorf("base layer digest = %q, want sha256:deadbeef", got)
+	}
+	if l := s.Find(host, "/bin/sh"); l == nil || l.Index != 1 {
+		t.Errorf("Find(/bin/sh) = %+v, want base layer through the symlinked lowerdir", l)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	host := t.TempDir()
+	writeFile(t, filepath.Join(host, "/snapshots/1/fs/bin/sh"), "base")
+	writeFile(t, filepath.Join(host, "/snapshots/1/fs/etc/os-release"), "base")
+	writeFile(t, filepath.Join(host, "/snapshots/3/fs/etc/os-release"), "override")
+	writeFile(t, filepath.Join(host, "/snapshots/3/fs/app/main"), "app")
+	writeFile(t, filepath.Join(host, "/snapshots/4/fs/tmp/new"), "runtime")
+	if err := os.Symlink("sh", filepath.Join(host, "/snapshots/1/fs/bin/ash")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ParseMountInfo(strings.NewReader(testMountInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Measure(host)
+	want := []Size{
+		{Files: 1, Bytes: 4}, // os-release is replaced, and symlinks don't count
+		{},                   // not on disk
+		{Files: 2, Bytes: 11},
+		{Files: 1, Bytes: 7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Measure() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,180 @@
+package packages
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// OrphanName and OrphanManager identify the synthetic package that
+	// accounts for accessed files no package owns, such as application code
+	// or files copied into the image.
+	OrphanName    = "(orphan)"
+	OrphanManager = "none"
+
+	// orphanSampleSize bounds the sample of orphan files in stats.
+	orphanSampleSize = 20
+)
+
+// PackageStats summarizes how much of a package a container used.
+type PackageStats struct {
+	Name          string
+	Version       string
+	Manager       string
+	TotalFiles    int      // Files the package owns
+	AccessedFiles int      // Distinct owned files that were accessed
+	AccessCount   uint64   // Total accesses to owned files, including repeats
+	Origin        string   // Source package, or "" if unknown
+	License       string   // License expression, or "" if unknown
+	Arch          string   // Architecture, or "" if unknown
+	Size          int64    // Installed size in bytes, or 0 if unknown
+	Layer         int      // Image layer providing the package, from 1 for the base layer; 0 if unknown
+	InstallReason string   // Explicit, Dependency, or "" if unknown
+	Depends       []string // Names of installed packages this one depends on
+	Provides      []string // Virtual names this package provides
+
+	// UnusedFiles lists owned files that were never accessed, sorted. Only
+	// populated by StatsWithUnusedFiles.
+	UnusedFiles []string
+
+	// SampleFiles lists the most accessed files of the orphan package.
+	SampleFiles []string
+}
+
+// Mapper tracks accesses to package-owned files. It is safe for concurrent use.
+type Mapper struct {
+	db *Database
+
+	mu       sync.Mutex
+	accessed map[*Package]map[string]struct{}
+	counts   map[*Package]uint64
+	orphans  map[string]uint64 // access counts of files no package owns
+}
+
+// NewMapper creates a Mapper over db.
+func NewMapper(db *Database) *Mapper {
+	return &Mapper{
+		db:       db,
+		accessed: make(map[*Package]map[string]struct{}),
+		counts:   make(map[*Package]uint64),
+		orphans:  make(map[string]uint64),
+	}
+}
+
+// Database returns the database the mapper attributes files with.
+func (m *Mapper) Database() *Database {
+	return m.db
+}
+
+// Record attributes n accesses of path to its owning package, returning the
+// package name and whether this was the first access to any of its files.
+// It returns "", false if no package owns path.
+func (m *Mapper) Record(path string, n uint64) (string, bool) {
+	pkg, owned := m.db.Owner(path)
+	if pkg == nil {
+		return "", false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files, ok := m.accessed[pkg]
+	if !ok {
+		files = make(map[string]struct{})
+		m.accessed[pkg] = files
+	}
+	files[owned] = struct{}{}
+	m.counts[pkg] += n
+	return pkg.Name, !ok
+}
+
+// RecordOrphan records n accesses of a path that no package owns, so that
+// package stats account for every access. Callers decide when a path is
+// unowned, e.g. after also failing to resolve it through symlinks.
+func (m *Mapper) RecordOrphan(path string, n uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orphans[path] += n
+}
+
+// Stats returns usage for every package in the database, including
+// packages that were never accessed, sorted by name. If orphan files were
+// recorded, a final OrphanName entry summarizes them.
+func (m *Mapper) Stats() []PackageStats {
+	return m.stats(false)
+}
+
+// StatsWithUnusedFiles is like Stats but also lists each package's files
+// that were never accessed, which can be large.
+func (m *Mapper) StatsWithUnusedFiles() []PackageStats {
+	return m.stats(true)
+}
+
+func (m *Mapper) stats(withUnused bool) []PackageStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]PackageStats, 0, len(m.db.Packages))
+	for _, pkg := range m.db.Packages {
+		var unused []string
+		if withUnused {
+			for _, f := range pkg.Files {
+				if _, ok := m.accessed[pkg][f]; !ok {
+					unused = append(unused, f)
+				}
+			}
+			sort.Strings(unused)
+		}
+		stats = append(stats, PackageStats{
+			Name:          pkg.Name,
+			Version:       pkg.Version,
+			Manager:       pkg.Manager,
+			TotalFiles:    len(pkg.Files),
+			AccessedFiles: len(m.accessed[pkg]),
+			AccessCount:   m.counts[pkg],
+			Origin:        pkg.Origin,
+			License:       pkg.License,
+			Arch:          pkg.Arch,
+			Size:          pkg.Size,
+			InstallReason: pkg.InstallReason,
+			Depends:       pkg.Depends,
+			Provides:      pkg.Provides,
+			UnusedFiles:   unused,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Name != stats[j].Name {
+			return stats[i].Name < stats[j].Name
+		}
+		return stats[i].Version < stats[j].Version
+	})
+	if len(m.orphans) > 0 {
+		stats = append(stats, m.orphanStats())
+	}
+	return stats
+}
+
+// orphanStats summarizes orphan files as a synthetic package. m.mu must be held.
+func (m *Mapper) orphanStats() PackageStats {
+	s := PackageStats{
+		Name:          OrphanName,
+		Manager:       OrphanManager,
+		TotalFiles:    len(m.orphans),
+		AccessedFiles: len(m.orphans),
+	}
+	files := make([]string, 0, len(m.orphans))
+	for f, n := range m.orphans {
+		s.AccessCount += n
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if m.orphans[files[i]] != m.orphans[files[j]] {
+			return m.orphans[files[i]] > m.orphans[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	if len(files) > orphanSampleSize {
+		files = files[:orphanSampleSize]
+	}
+	s.SampleFiles = files
+	return s
+}
@@ -0,0 +1,95 @@
+package packages
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMapper(t *testing.T) {
+	db := NewDatabase([]*Package{
+		{Name: "curl", Version: "8.5.0", Manager: "dpkg", Files: []string{"/usr/bin/curl", "/usr/share/doc/curl/README"}},
+		{Name: "bash", Version: "5.2", Manager: "dpkg", Files: []string{"/bin/bash"}},
+		{Name: "unused", Version: "1.0", Manager: "dpkg", Files: []string{"/usr/bin/unused"}, InstallReason: Explicit},
+	})
+	m := NewMapper(db)
+
+	if name, first := m.Record("/usr/bin/curl", 1); name != "curl" || !first {
+		t.Errorf("Record(curl) = %q, %v; want curl, true", name, first)
+	}
+	if _, first := m.Record("/usr/bin/curl", 2); first {
+		t.Errorf("second Record(curl) reported first access")
+	}
+	m.Record("/usr/bin/bash", 1) // via /usr merge alias
+	if name, _ := m.Record("/etc/hosts", 1); name != "" {
+		t.Errorf("Record(unowned) = %q, want empty", name)
+	}
+
+	stats := m.Stats()
+	want := []PackageStats{
+		{Name: "bash", Version: "5.2", Manager: "dpkg", TotalFiles: 1, AccessedFiles: 1, AccessCount: 1},
+		{Name: "curl", Version: "8.5.0", Manager: "dpkg", TotalFiles: 2, AccessedFiles: 1, AccessCount: 3},
+		{Name: "unused", Version: "1.0", Manager: "dpkg", TotalFiles: 1, InstallReason: Explicit},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("Stats() = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(stats[i], want[i]) {
+			t.Errorf("stats[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
+
+func TestMapperUnusedFiles(t *testing.T) {
+	db := NewDatabase([]*Package{
+		{Name: "curl", Version: "8.5.0", Manager: "apk", Files: []string{"/usr/bin/curl", "/usr/share/man/man1/curl.1.gz", "/usr/share/doc/curl/README"}},
+	})
+	m := NewMapper(db)
+	m.Record("/usr/bin/curl", 1)
+
+	if got := m.Stats()[0].UnusedFiles; got != nil {
+		t.Errorf("Stats() unused files = %v, want nil", got)
+	}
+	want := []string{"/usr/share/doc/curl/README", "/usr/share/man/man1/curl.1.gz"}
+	if got := m.StatsWithUnusedFiles()[0].UnusedFiles; !reflect.DeepEqual(got, want) {
+		t.Errorf("StatsWithUnusedFiles() unused files = %v, want %v", got, want)
+	}
+}
+
+func TestMapperOrphans(t *testing.T) {
+	db := NewDatabase([]*Package{
+		{Name: "curl", Version: "8.5.0", Manager: "apk", Files: []string{"/usr/bin/curl"}},
+	})
+	m := NewMapper(db)
+	m.Record("/usr/bin/curl", 1)
+	if got := m.Stats(); len(got) != 1 {
+		t.Fatalf("Stats() = %+v, want no orphan entry", got)
+	}
+
+	m.RecordOrphan("/app/main.py", 1)
+	m.RecordOrphan("/app/config.yaml", 3)
+	m.RecordOrphan("/app/main.py", 1)
+	for i := 0; i < orphanSampleSize; i++ {
+		m.RecordOrphan(fmt.Sprintf("/app/static/%02d.css", i), 1)
+	}
+
+	stats := m.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Stats() = %+v, want curl and orphans", stats)
+	}
+	o := stats[1]
+	if o.Name != OrphanName || o.Manager != OrphanManager {
+		t.Errorf("orphan entry = %s/%s, want %s/%s", o.Manager, o.Name, OrphanManager, OrphanName)
+	}
+	if o.TotalFiles != orphanSampleSize+2 || o.AccessedFiles != orphanSampleSize+2 || o.AccessCount != orphanSampleSize+5 {
+		t.Errorf("orphan counts = %+v", o)
+	}
+	if len(o.SampleFiles) != orphanSampleSize {
+		t.Fatalf("len(SampleFiles) = %d, want %d", len(o.SampleFiles), orphanSampleSize)
+	}
+	want := []string{"/app/config.yaml", "/app/main.py", "/app/static/00.css"}
+	if !reflect.DeepEqual(o.SampleFiles[:3], want) {
+		t.Errorf("SampleFiles[:3] = %v, want %v", o.SampleFiles[:3], want)
+	}
+}
@@ -0,0 +1,196 @@
+// Package packages attributes accessed files to the OS packages that own
+// them. Package manager specific parsers (e.g. pkg/dpkg) produce a list of
+// Packages; a Database indexes them by file, and a Mapper tracks which
+// packages a container actually uses.
+package packages
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Install reasons, for package managers that record why a package is installed.
+const (
+	// Explicit marks a package that was requested directly. If unused, it
+	// can be removed outright.
+	Explicit = "explicit"
+
+	// Dependency marks a package that was pulled in by another package.
+	Dependency = "dependency"
+)
+
+// Package is an installed package and the files it owns.
+type Package struct {
+	Name    string
+	Version string
+	Manager string   // Package manager that installed it, e.g. "dpkg"
+	Files   []string // Absolute paths of regular files and symlinks (not directories)
+
+	// Origin, License, and Arch are the source package the package was
+	// built from, its license expression, and its architecture, or "" if the
+	// package manager doesn't record them.
+	Origin  string
+	License string
+	Arch    string
+
+	// Size is the installed size in bytes, or 0 if the package manager
+	// doesn't record it.
+	Size int64
+
+	// InstallReason is Explicit, Dependency, or "" if the package manager
+	// doesn't record it.
+	InstallReason string
+
+	// Depends names the installed packages this package depends on, or is
+	// nil if the package manager's dependency graph isn't known.
+	Depends []string
+
+	// Provides lists the virtual names the package provides (e.g.
+	// so:libssl.so.3 or cmd:bash), for package managers that record them.
+	Provides []string
+
+	// Checksums maps files to the content digest the package manager
+	// recorded for them, in "<algorithm>:<hex>" form (e.g. "sha1:..."), for
+	// package managers that record them.
+	Checksums map[string]string
+}
+
+// Loader reads the installed packages of one package manager from the
+// filesystem rooted at root (e.g. /proc/<pid>/root). It returns an error
+// wrapping fs.ErrNotExist if that package manager's database isn't present.
+type Loader func(root string) ([]*Package, error)
+
+// DirLoader discovers language package installations from accessed paths,
+// for ecosystems whose packages live in per-project directories (e.g.
+// site-packages, node_modules) rather than one system-wide database.
+type DirLoader struct {
+	// Ecosystem names the kind of packages loaded, e.g. "python".
+	Ecosystem string
+
+	// Dir returns the package directory containing path, or "" if path is
+	// not inside one.
+	Dir func(path string) string
+
+	// Load reads the packages installed in dir under root.
+	Load func(root, dir string) ([]*Package, error)
+}
+
+// Database indexes installed packages by the files they own.
+type Database struct {
+	Packages []*Package
+	byFile   map[string]*Package
+}
+
+// NewDatabase builds a file index over pkgs. A path claimed by more than one
+// package is not attributed to either, since it is most likely a shared
+// directory or a diverted file.
+func NewDatabase(pkgs []*Package) *Database {
+	db := &Database{
+		Packages: pkgs,
+		byFile:   make(map[string]*Package),
+	}
+	conflicts := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			if owner, ok := db.byFile[f]; ok && owner != pkg {
+				conflicts[f] = true
+				continue
+			}
+			db.byFile[f] = pkg
+		}
+	}
+	for f := range conflicts {
+		delete(db.byFile, f)
+	}
+	return db
+}
+
+// Lookup returns the package owning path, or nil if no package owns it.
+//
+// Many distributions have merged /bin, /sbin, and /lib into /usr while their
+// package databases still record the pre-merge paths (or vice versa), so a
+// miss is retried with the /usr prefix added or removed.
+func (db *Database) Lookup(path string) *Package {
+	pkg, _ := db.Owner(path)
+	return pkg
+}
+
+// Owner is like Lookup but also returns the path under which the package
+// lists the file, which differs from path when matched through /usr merging.
+func (db *Database) Owner(path string) (*Package, string) {
+	if pkg, ok := db.byFile[path]; ok {
+		return pkg, path
+	}
+	if alt, ok := usrMergeAlias(path); ok {
+		if pkg, ok := db.byFile[alt]; ok {
+			return pkg, alt
+		}
+	}
+	return nil, ""
+}
+
+// usrMergeAlias maps /bin/x <-> /usr/bin/x and similarly for sbin and lib*.
+func usrMergeAlias(path string) (string, bool) {
+	for _, dir := range []string{"/bin/", "/sbin/", "/lib/", "/lib32/", "/lib64/", "/libx32/"} {
+		if strings.HasPrefix(path, "/usr"+dir) {
+			return strings.TrimPrefix(path, "/usr"), true
+		}
+		if strings.HasPrefix(path, dir) {
+			return "/usr" + path, true
+		}
+	}
+	return "", false
+}
+
+// Load runs each loader against root and combines the packages they find.
+// Loaders whose database isn't present are skipped. It returns an error
+// wrapping fs.ErrNotExist if no loader found a database.
+func Load(root string, loaders ...Loader) (*Database, error) {
+	var pkgs []*Package
+	var errs []error
+	found := false
+	for _, load := range loaders {
+		p, err := load(root)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		found = true
+		pkgs = append(pkgs, p...)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if !found {
+		return nil, fmt.Errorf("no package database found under %s: %w", root, fs.ErrNotExist)
+	}
+	return NewDatabase(pkgs), nil
+}
+
+// FilesOnly removes directory entries from a package file list, identified
+// as any path that is a parent of another path in the same list. Package
+// databases such as dpkg's list directories alongside files, and counting
+// them would skew utilization.
+func FilesOnly(paths []string) []string {
+	dirs := make(map[string]bool)
+	for _, p := range paths {
+		for i := strings.LastIndexByte(p, '/'); i > 0; i = strings.LastIndexByte(p[:i], '/') {
+			if dirs[p[:i]] {
+				break
+			}
+			dirs[p[:i]] = true
+		}
+	}
+	files := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p != "" && p != "/" && !dirs[p] {
+			files = append(files, p)
+		}
+	}
+	return files
+}
@@ -0,0 +1,69 @@
+package packages
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+)
+
+func TestDatabaseLookup(t *testing.T) {
+	curl := &Package{Name: "curl", Files: []string{"/usr/bin/curl"}}
+	coreutils := &Package{Name: "coreutils", Files: []string{"/bin/ls", "/usr/share/man"}}
+	man := &Package{Name: "man-db", Files: []string{"/usr/share/man"}}
+	db := NewDatabase([]*Package{curl, coreutils, man})
+
+	for _, tt := range []struct {
+		path string
+		want *Package
+	}{
+		{"/usr/bin/curl", curl},
+		{"/bin/curl", curl},        // merged-/usr alias
+		{"/usr/bin/ls", coreutils}, // merged-/usr alias the other way
+		{"/bin/ls", coreutils},
+		{"/usr/share/man", nil}, // claimed by two packages
+		{"/etc/passwd", nil},
+	} {
+		if got := db.Lookup(tt.path); got != tt.want {
+			t.Errorf("Lookup(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFilesOnly(t *testing.T) {
+	got := FilesOnly([]string{"/", "/usr", "/usr/bin", "/usr/bin/curl", "/usr/share/doc/curl/copyright", "/etc/curlrc"})
+	want := []string{"/usr/bin/curl", "/usr/share/doc/curl/copyright", "/etc/curlrc"}
+	if !slices.Equal(got, want) {
+		t.Errorf("FilesOnly() = %v, want %v", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	found := func(name string) Loader {
+		return func(string) ([]*Package, error) {
+			return []*Package{{Name: name, Files: []string{"/" + name}}}, nil
+		}
+	}
+	missing := func(string) ([]*Package, error) {
+		return nil, fmt.Errorf("no db: %w", fs.ErrNotExist)
+	}
+	broken := func(string) ([]*Package, error) {
+		return nil, errors.New("corrupt db")
+	}
+
+	db, err := Load("/root", missing, found("a"), found("b"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(db.Packages) != 2 || db.Lookup("/b") == nil {
+		t.Errorf("Load combined %d packages, want 2", len(db.Packages))
+	}
+
+	if _, err := Load("/root", missing, missing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load with no databases error = %v, want ErrNotExist", err)
+	}
+	if _, err := Load("/root", found("a"), broken); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load with broken database error = %v, want parse error", err)
+	}
+}
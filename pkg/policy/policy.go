@@ -0,0 +1,85 @@
+// Package policy defines file access policy rules, which the processor
+// checks traced events against, and the operations they match.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
+	"golang.org/x/sys/unix"
+)
+
+// Action is what a Rule does with the events it matches.
+type Action string
+
+const (
+	// Allow counts matching events and nothing more, exempting them from
+	// later rules.
+	Allow Action = "allow"
+	// Alert reports matching events.
+	Alert Action = "alert"
+)
+
+// Operations are the operations a Rule can match, by the syscalls that
+// perform them. The probe doesn't record how files are opened, so reads and
+// writes are both "open".
+var Operations = []string{"open", "exec", "stat", "readlink"}
+
+// Operation returns the operation of a traced syscall, from an event's
+// syscall number, or "" for syscalls the probe doesn't trace.
+func Operation(syscallNr uint32) string {
+	switch syscallNr {
+	case unix.SYS_OPENAT, unix.SYS_OPENAT2:
+		return "open"
+	case unix.SYS_EXECVE, unix.SYS_EXECVEAT:
+		return "exec"
+	case unix.SYS_NEWFSTATAT, unix.SYS_STATX, unix.SYS_FACCESSAT, unix.SYS_FACCESSAT2:
+		return "stat"
+	case unix.SYS_READLINKAT:
+		return "readlink"
+	default:
+		return ""
+	}
+}
+
+// Rule is a file access policy rule. An event matches it if its path
+// matches one of Paths, its process's executable matches one of Processes,
+// and its operation is one of Operations; empty Processes or Operations
+// match any. Patterns are matched like watched paths: those ending in "/"
+// match any path under that directory, those containing glob
+// metacharacters are matched with path.Match, and others must match
+// exactly.
+type Rule struct {
+	Name       string
+	Paths      []string
+	Processes  []string
+	Operations []string
+	Action     Action
+}
+
+// Validate checks that the rule is complete and its patterns are valid.
+func (r Rule) Validate() error {
+	var errs []error
+	if r.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if len(r.Paths) == 0 {
+		errs = append(errs, errors.New("paths are required"))
+	}
+	for _, pattern := range slices.Concat(r.Paths, r.Processes) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid pattern %q: %w", pattern, err))
+		}
+	}
+	for _, op := range r.Operations {
+		if !slices.Contains(Operations, op) {
+			errs = append(errs, fmt.Errorf("unknown operation %q", op))
+		}
+	}
+	if r.Action != Allow && r.Action != Alert {
+		errs = append(errs, fmt.Errorf("action must be %q or %q, not %q", Allow, Alert, r.Action))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,29 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleValidate(t *testing.T) {
+	for _, tt := range []struct {
+		rule    Rule
+		wantErr string
+	}{
+		{rule: Rule{Name: "ok", Paths: []string{"/etc/"}, Operations: []string{"open"}, Action: Alert}},
+		{rule: Rule{Paths: []string{"/etc/"}, Action: Alert}, wantErr: "name is required"},
+		{rule: Rule{Name: "r", Action: Allow}, wantErr: "paths are required"},
+		{rule: Rule{Name: "r", Paths: []string{"/etc/["}, Action: Allow}, wantErr: "invalid pattern"},
+		{rule: Rule{Name: "r", Paths: []string{"/etc/"}, Operations: []string{"write"}, Action: Allow}, wantErr: "unknown operation"},
+		{rule: Rule{Name: "r", Paths: []string{"/etc/"}, Action: "deny"}, wantErr: "action must be"},
+	} {
+		err := tt.rule.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Validate(%+v) = %v", tt.rule, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.rule, err, tt.wantErr)
+		}
+	}
+}
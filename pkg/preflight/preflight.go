@@ -0,0 +1,282 @@
+//go:build linux
+
+// Package preflight checks that a host can run snoop's eBPF probe, so that
+// problems are reported plainly instead of as eBPF load errors.
+package preflight
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/features"
+	"github.com/imjasonh/snoop/pkg/ebpf/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	// Pass means the requirement is met.
+	Pass Status = iota
+	// Warn means snoop runs, but with reduced coverage.
+	Warn
+	// Fail means snoop won't run.
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Paths are the host files the checks read.
+type Paths struct {
+	BTF         string   // Kernel BTF
+	CgroupRoot  string   // cgroup v2 mount
+	ProcStatus  string   // Status of the checking process, for its capabilities
+	TracingDirs []string // tracefs mounts, in order of preference
+}
+
+// DefaultPaths are the usual locations of the files the checks read.
+var DefaultPaths = Paths{
+	BTF:         "/sys/kernel/btf/vmlinux",
+	CgroupRoot:  "/sys/fs/cgroup",
+	ProcStatus:  "/proc/self/status",
+	TracingDirs: []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"},
+}
+
+// MinKernel is the oldest kernel snoop runs on, the first with BPF ring
+// buffers.
+var MinKernel = [2]int{5, 8}
+
+// RequiredTracepoints are the syscall tracepoints the probe can't run
+// without, and OptionalTracepoints those it attaches to when they exist.
+var (
+	RequiredTracepoints = []string{"sys_enter_openat", "sys_enter_execve", "sys_enter_newfstatat", "sys_enter_faccessat", "sys_enter_readlinkat"}
+	OptionalTracepoints = []string{"sys_enter_execveat", "sys_enter_openat2", "sys_enter_statx", "sys_enter_faccessat2"}
+)
+
+// Run runs every check, in order.
+func Run(p Paths) []Result {
+	var uts unix.Utsname
+	release := ""
+	if err := unix.Uname(&uts); err == nil {
+		release = unix.ByteSliceToString(uts.Release[:])
+	}
+	return []Result{
+		checkKernel(release),
+		checkBTF(p.BTF),
+		checkCgroup(p.CgroupRoot),
+		checkCapabilities(p.ProcStatus),
+		checkRingBuffer(),
+		checkTracepoints(p.TracingDirs),
+		checkLoad(),
+	}
+}
+
+// Failed reports whether any check failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes results as a table.
+func Write(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, r.Status, r.Detail)
+	}
+	return tw.Flush()
+}
+
+// parseKernelVersion returns the major and minor version of a kernel
+// release, such as "6.1.0-18-amd64".
+func parseKernelVersion(release string) (major, minor int, err error) {
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("unrecognized kernel release %q", release)
+	}
+	if major, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("unrecognized kernel release %q", release)
+	}
+	// The minor version may run into a suffix, as in "5.10-rc1"
+	digits := strings.IndexFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+	if digits < 0 {
+		digits = len(parts[1])
+	}
+	if minor, err = strconv.Atoi(parts[1][:digits]); err != nil {
+		return 0, 0, fmt.Errorf("unrecognized kernel release %q", release)
+	}
+	return major, minor, nil
+}
+
+func checkKernel(release string) Result {
+	r := Result{Name: "Kernel version"}
+	major, minor, err := parseKernelVersion(release)
+	switch {
+	case err != nil:
+		r.Status, r.Detail = Fail, err.Error()
+	case major < MinKernel[0] || major == MinKernel[0] && minor < MinKernel[1]:
+		r.Status, r.Detail = Fail, fmt.Sprintf("%s is older than the required %d.%d", release, MinKernel[0], MinKernel[1])
+	default:
+		r.Detail = release
+	}
+	return r
+}
+
+func checkBTF(path string) Result {
+	r := Result{Name: "BTF"}
+	if _, err := os.Stat(path); err != nil {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%s not found; the kernel must be built with CONFIG_DEBUG_INFO_BTF", path)
+		return r
+	}
+	r.Detail = path
+	return r
+}
+
+func checkCgroup(root string) Result {
+	r := Result{Name: "cgroup v2"}
+	var st unix.Statfs_t
+	if err := unix.Statfs(root, &st); err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+	if st.Type != unix.CGROUP2_SUPER_MAGIC {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%s is not a cgroup v2 mount", root)
+		return r
+	}
+	r.Detail = root
+	return r
+}
+
+// capabilities returns the effective capabilities in a /proc/<pid>/status
+// file.
+func capabilities(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s: no CapEff", path)
+}
+
+func checkCapabilities(path string) Result {
+	r := Result{Name: "Capabilities"}
+	caps, err := capabilities(path)
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+	has := func(c int) bool { return caps&(1<<c) != 0 }
+	switch {
+	case has(unix.CAP_SYS_ADMIN):
+		r.Detail = "CAP_SYS_ADMIN"
+	case has(unix.CAP_BPF) && has(unix.CAP_PERFMON):
+		r.Detail = "CAP_BPF, CAP_PERFMON"
+	default:
+		var missing []string
+		if !has(unix.CAP_BPF) {
+			missing = append(missing, "CAP_BPF")
+		}
+		if !has(unix.CAP_PERFMON) {
+			missing = append(missing, "CAP_PERFMON")
+		}
+		r.Status, r.Detail = Fail, fmt.Sprintf("missing %s (or CAP_SYS_ADMIN)", strings.Join(missing, ", "))
+	}
+	return r
+}
+
+func checkRingBuffer() Result {
+	r := Result{Name: "Ring buffer"}
+	switch err := features.HaveMapType(ebpf.RingBuf); {
+	case errors.Is(err, ebpf.ErrNotSupported):
+		r.Status, r.Detail = Fail, "BPF ring buffer maps are not supported"
+	case err != nil:
+		r.Status, r.Detail = Fail, err.Error()
+	default:
+		r.Detail = "supported"
+	}
+	return r
+}
+
+func checkTracepoints(dirs []string) Result {
+	r := Result{Name: "Tracepoints"}
+	events := ""
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, "events", "syscalls")); err == nil {
+			events = filepath.Join(dir, "events", "syscalls")
+			break
+		}
+	}
+	if events == "" {
+		r.Status, r.Detail = Fail, fmt.Sprintf("no syscall tracepoints in %s; is tracefs mounted?", strings.Join(dirs, " or "))
+		return r
+	}
+	missing := func(names []string) []string {
+		var m []string
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(events, name)); err != nil {
+				m = append(m, name)
+			}
+		}
+		return m
+	}
+	if m := missing(RequiredTracepoints); len(m) > 0 {
+		r.Status, r.Detail = Fail, fmt.Sprintf("missing %s", strings.Join(m, ", "))
+		return r
+	}
+	if m := missing(OptionalTracepoints); len(m) > 0 {
+		r.Status, r.Detail = Warn, fmt.Sprintf("missing %s; those syscalls won't be traced", strings.Join(m, ", "))
+		return r
+	}
+	r.Detail = fmt.Sprintf("%d syscalls in %s", len(RequiredTracepoints)+len(OptionalTracepoints), events)
+	return r
+}
+
+func checkLoad() Result {
+	r := Result{Name: "eBPF programs"}
+	objs := &bpf.SnoopObjects{}
+	if err := bpf.LoadSnoopObjects(objs, nil); err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+	objs.Close()
+	r.Detail = "loaded"
+	return r
+}
@@ -0,0 +1,126 @@
+//go:build linux
+
+package preflight
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseKernelVersion(t *testing.T) {
+	for release, want := range map[string][2]int{
+		"6.1.0-18-amd64":  {6, 1},
+		"5.15.0":          {5, 15},
+		"5.10-rc1":        {5, 10},
+		"6.18.44-fc-v130": {6, 18},
+	} {
+		major, minor, err := parseKernelVersion(release)
+		if err != nil || major != want[0] || minor != want[1] {
+			t.Errorf("parseKernelVersion(%q) = %d, %d, %v; want %d, %d", release, major, minor, err, want[0], want[1])
+		}
+	}
+	for _, release := range []string{"", "6", "x.1", "6.x"} {
+		if _, _, err := parseKernelVersion(release); err == nil {
+			t.Errorf("parseKernelVersion(%q) succeeded", release)
+		}
+	}
+}
+
+func TestCheckKernel(t *testing.T) {
+	for release, want := range map[string]Status{
+		"5.8.0":   Pass,
+		"6.1.0":   Pass,
+		"5.4.0":   Fail,
+		"4.19.0":  Fail,
+		"unknown": Fail,
+	} {
+		if got := checkKernel(release); got.Status != want {
+			t.Errorf("checkKernel(%q) = %+v, want %s", release, got, want)
+		}
+	}
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		capEff, want string
+		status       Status
+	}{
+		{"000001ffffffffff", "CAP_SYS_ADMIN", Pass},
+		{"000000c000000000", "CAP_BPF, CAP_PERFMON", Pass},
+		{"0000008000000000", "missing CAP_PERFMON (or CAP_SYS_ADMIN)", Fail},
+		{"0000000000000000", "missing CAP_BPF, CAP_PERFMON (or CAP_SYS_ADMIN)", Fail},
+	} {
+		path := filepath.Join(dir, "status")
+		if err := os.WriteFile(path, []byte("Name:\tsnoop\nCapInh:\t0000000000000000\nCapEff:\t"+tt.capEff+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := checkCapabilities(path); got.Status != tt.status || got.Detail != tt.want {
+			t.Errorf("checkCapabilities(CapEff %s) = %+v, want %s %q", tt.capEff, got, tt.status, tt.want)
+		}
+	}
+	if got := checkCapabilities(filepath.Join(dir, "missing")); got.Status != Fail {
+		t.Errorf("checkCapabilities of a missing file = %+v", got)
+	}
+}
+
+func TestCheckTracepoints(t *testing.T) {
+	debug := filepath.Join(t.TempDir(), "debug", "tracing")
+	dirs := []string{filepath.Join(t.TempDir(), "tracing"), debug}
+	if got := checkTracepoints(dirs); got.Status != Fail || !strings.Contains(got.Detail, "tracefs") {
+		t.Errorf("checkTracepoints without tracefs = %+v", got)
+	}
+
+	events := filepath.Join(debug, "events", "syscalls")
+	for _, name := range RequiredTracepoints {
+		if err := os.MkdirAll(filepath.Join(events, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := checkTracepoints(dirs); got.Status != Warn || !strings.Contains(got.Detail, "sys_enter_openat2") {
+		t.Errorf("checkTracepoints without optional tracepoints = %+v", got)
+	}
+	for _, name := range OptionalTracepoints {
+		if err := os.MkdirAll(filepath.Join(events, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := checkTracepoints(dirs); got.Status != Pass {
+		t.Errorf("checkTracepoints = %+v", got)
+	}
+	if err := os.Remove(filepath.Join(events, "sys_enter_execve")); err != nil {
+		t.Fatal(err)
+	}
+	if got := checkTracepoints(dirs); got.Status != Fail || got.Detail != "missing sys_enter_execve" {
+		t.Errorf("checkTracepoints without execve = %+v", got)
+	}
+}
+
+func TestCheckCgroup(t *testing.T) {
+	if got := checkCgroup(t.TempDir()); got.Status != Fail {
+		t.Errorf("checkCgroup of a plain directory = %+v", got)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := []Result{
+		{Name: "Kernel version", Detail: "6.1.0"},
+		{Name: "BTF", Status: Fail, Detail: "not found"},
+	}
+	if !Failed(results) || Failed(results[:1]) {
+		t.Error("Failed doesn't match the results")
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	want := "CHECK           STATUS  DETAIL\n" +
+		"Kernel version  PASS    6.1.0\n" +
+		"BTF             FAIL    not found\n"
+	if buf.String() != want {
+		t.Errorf("Write =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
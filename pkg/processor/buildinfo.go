@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"debug/buildinfo"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// GoBuildInfo describes the Go build information embedded in an executed binary.
+type GoBuildInfo struct {
+	Path          string // Normalized path of the binary inside the container
+	GoVersion     string // Toolchain version, e.g. "go1.25.5"
+	ModulePath    string // Main module path
+	ModuleVersion string // Main module version, e.g. "v1.2.3" or "(devel)"
+	VCSRevision   string // Commit the binary was built from, if stamped
+	VCSTime       time.Time
+	VCSModified   bool // Whether the working tree had uncommitted changes
+}
+
+// readBuildInfo reads Go build information from the binary at path under
+// root. Returns false if the file can't be read or isn't a Go binary.
+func readBuildInfo(root, path string) (GoBuildInfo, bool) {
+	bi, err := buildinfo.ReadFile(filepath.Join(root, path))
+	if err != nil {
+		return GoBuildInfo{}, false
+	}
+	info := GoBuildInfo{
+		Path:          path,
+		GoVersion:     bi.GoVersion,
+		ModulePath:    bi.Main.Path,
+		ModuleVersion: bi.Main.Version,
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.VCSRevision = s.Value
+		case "vcs.time":
+			info.VCSTime, _ = time.Parse(time.RFC3339, s.Value)
+		case "vcs.modified":
+			info.VCSModified = s.Value == "true"
+		}
+	}
+	return info, true
+}
+
+// recordExec reads the build information of a binary the first time the
+// container executes it. Non-Go binaries are remembered so they're only read once.
+func (p *Processor) recordExec(state *containerState, pid uint32, path string) {
+	state.seenMu.Lock()
+	_, done := state.execs[path]
+	if !done {
+		state.execs[path] = nil
+	}
+	state.seenMu.Unlock()
+	if done {
+		return
+	}
+
+	info, ok := readBuildInfo(p.buildInfoRoot(pid), path)
+	if !ok {
+		return
+	}
+	state.seenMu.Lock()
+	state.execs[path] = &info
+	state.seenMu.Unlock()
+}
+
+// BuildInfo returns the Go build information of binaries each container has
+// executed, sorted by path. Returns nil if build info detection is not enabled.
+func (p *Processor) BuildInfo() map[uint64][]GoBuildInfo {
+	if p.buildInfoRoot == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]GoBuildInfo)
+	for cgroupID, state := range p.containers {
+		var infos []GoBuildInfo
+		state.seenMu.RLock()
+		for _, info := range state.execs {
+			if info != nil {
+				infos = append(infos, *info)
+			}
+		}
+		state.seenMu.RUnlock()
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Path < infos[j].Path })
+		result[cgroupID] = infos
+	}
+
+	return result
+}
@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestGoBuildInfo(t *testing.T) {
+	ctx := context.Background()
+
+	// The test binary itself is a Go binary with embedded build info
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Dir(exe)
+	bin := "/" + filepath.Base(exe)
+	want, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("test binary has no build info")
+	}
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/app", Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithGoBuildInfo(func(uint32) string { return root }))
+
+	p.Process(&Event{CgroupID: 1000, PID: 1, SyscallNr: unix.SYS_EXECVE, Path: bin})
+	p.Process(&Event{CgroupID: 1000, PID: 2, SyscallNr: unix.SYS_EXECVE, Path: bin})
+	// Opening a binary isn't executing it
+	p.Process(&Event{CgroupID: 1000, PID: 1, SyscallNr: unix.SYS_OPENAT, Path: "/other"})
+	// Binaries that can't be read or aren't Go are skipped
+	p.Process(&Event{CgroupID: 1000, PID: 1, SyscallNr: unix.SYS_EXECVE, Path: "/missing"})
+
+	infos := p.BuildInfo()[1000]
+	if len(infos) != 1 {
+		t.Fatalf("got %d build infos, want 1: %+v", len(infos), infos)
+	}
+	got := infos[0]
+	if got.Path != bin {
+		t.Errorf("Path = %q, want %q", got.Path, bin)
+	}
+	if got.GoVersion != want.GoVersion {
+		t.Errorf("GoVersion = %q, want %q", got.GoVersion, want.GoVersion)
+	}
+	if got.ModulePath != want.Main.Path {
+		t.Errorf("ModulePath = %q, want %q", got.ModulePath, want.Main.Path)
+	}
+}
+
+func TestGoBuildInfoDisabled(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/app", Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0)
+	p.Process(&Event{CgroupID: 1000, PID: 1, SyscallNr: unix.SYS_EXECVE, Path: "/bin/app"})
+	if got := p.BuildInfo(); got != nil {
+		t.Errorf("BuildInfo() = %v, want nil when disabled", got)
+	}
+}
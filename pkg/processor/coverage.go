@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"path"
+	"sort"
+)
+
+// ImageFile is a regular file in a container's image.
+type ImageFile struct {
+	Path string
+	Size int64
+}
+
+// FileCoverage counts regular files in a container's image, and how many of
+// them it accessed.
+type FileCoverage struct {
+	Files         int
+	AccessedFiles int
+	Bytes         int64
+	AccessedBytes int64
+}
+
+// add counts a file of size.
+func (c *FileCoverage) add(size int64, accessed bool) {
+	c.Files++
+	c.Bytes += size
+	if accessed {
+		c.AccessedFiles++
+		c.AccessedBytes += size
+	}
+}
+
+// Coverage compares the files a container accessed with every regular file
+// its image layers provide, showing what exists but wasn't used.
+type Coverage struct {
+	// Total covers the whole image, and Directories each directory
+	// directly containing files, by path.
+	Total       FileCoverage
+	Directories map[string]FileCoverage
+
+	// LargestUnused lists the largest files never accessed, largest first.
+	LargestUnused []ImageFile
+}
+
+// Coverage returns each container's image file coverage, for containers
+// whose layers have been measured. Returns nil if coverage is not enabled.
+func (p *Processor) Coverage() map[uint64]Coverage {
+	if p.layerMountInfo == nil || !p.coverage {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64]Coverage)
+	for cgroupID, state := range p.containers {
+		state.layers.mu.Lock()
+		files := state.layers.files
+		state.layers.mu.Unlock()
+		if files == nil {
+			continue
+		}
+
+		c := Coverage{Directories: make(map[string]FileCoverage)}
+		var unused []ImageFile
+		state.seenMu.RLock()
+		for file, size := range files {
+			_, accessed := state.fileLayers[file]
+			c.Total.add(size, accessed)
+			dir := path.Dir(file)
+			dc := c.Directories[dir]
+			dc.add(size, accessed)
+			c.Directories[dir] = dc
+			if !accessed {
+				unused = append(unused, ImageFile{Path: file, Size: size})
+			}
+		}
+		state.seenMu.RUnlock()
+
+		sort.Slice(unused, func(i, j int) bool {
+			if unused[i].Size != unused[j].Size {
+				return unused[i].Size > unused[j].Size
+			}
+			return unused[i].Path < unused[j].Path
+		})
+		if len(unused) > p.coverageLargest {
+			unused = unused[:p.coverageLargest]
+		}
+		c.LargestUnused = unused
+		result[cgroupID] = c
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	ctx := context.Background()
+	host := buildRoot(t, map[string]string{
+		"layers/1/bin/busybox":                 "busybox",
+		"layers/1/bin/sh":                      "-> busybox",
+		"layers/1/usr/share/doc/README":        "documentation",
+		"layers/1/usr/share/locale/de/app.mo":  "übersetzung",
+		"layers/2/app/main":                    "app",
+		"layers/2/app/config.yaml":             "{}",
+		"layers/upper/tmp/scratch":             "runtime",
+		"layers/upper/usr/share/doc/generated": "not in the image",
+	})
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(mountinfo, []byte("1 0 0:50 / / rw - overlay overlay rw,lowerdir=/layers/2:/layers/1,upperdir=/layers/upper,workdir=/work\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0,
+		WithLayerAttribution(func(uint32) string { return mountinfo }, host),
+		WithCoverage(2))
+
+	for _, path := range []string{"/bin/busybox", "/app/main", "/tmp/scratch"} {
+		p.Process(&Event{CgroupID: 1000, PID: 1, Path: path})
+	}
+	p.Close()
+
+	c, ok := p.Coverage()[1000]
+	if !ok {
+		t.Fatal("no coverage for container")
+	}
+	if want := (FileCoverage{Files: 5, AccessedFiles: 2, Bytes: 37, AccessedBytes: 10}); c.Total != want {
+		t.Errorf("total = %+v, want %+v", c.Total, want)
+	}
+	wantDirs := map[string]FileCoverage{
+		"/bin":                 {Files: 1, AccessedFiles: 1, Bytes: 7, AccessedBytes: 7},
+		"/app":                 {Files: 2, AccessedFiles: 1, Bytes: 5, AccessedBytes: 3},
+		"/usr/share/doc":       {Files: 1, Bytes: 13},
+		"/usr/share/locale/de": {Files: 1, Bytes: 12},
+	}
+	if !reflect.DeepEqual(c.Directories, wantDirs) {
+		t.Errorf("directories = %+v, want %+v", c.Directories, wantDirs)
+	}
+	wantUnused := []ImageFile{
+		{Path: "/usr/share/doc/README", Size: 13},
+		{Path: "/usr/share/locale/de/app.mo", Size: 12},
+	}
+	if !reflect.DeepEqual(c.LargestUnused, wantUnused) {
+		t.Errorf("largest unused = %+v, want %+v", c.LargestUnused, wantUnused)
+	}
+}
+
+func TestCoverageDisabled(t *testing.T) {
+	p := NewProcessor(context.Background(), nil, nil, 0, WithLayerAttribution(nil, ""))
+	if got := p.Coverage(); got != nil {
+		t.Errorf("Coverage() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// hashQueueSize bounds the number of files waiting to be hashed.
+// When the queue is full, new files are skipped rather than blocking event processing.
+const hashQueueSize = 1024
+
+// hashJob is a request to hash a file on behalf of a container.
+type hashJob struct {
+	state *containerState
+	root  string
+	path  string
+}
+
+// hasher computes sha256 digests of files using a fixed pool of workers.
+type hasher struct {
+	maxSize int64
+	jobs    chan hashJob
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	skipped uint64
+}
+
+// newHasher starts a hasher with the given number of workers.
+// Files larger than maxSize bytes are not hashed.
+func newHasher(maxSize int64, workers int) *hasher {
+	if workers <= 0 {
+		workers = 1
+	}
+	h := &hasher{
+		maxSize: maxSize,
+		jobs:    make(chan hashJob, hashQueueSize),
+	}
+	for range workers {
+		h.wg.Add(1)
+		go h.work()
+	}
+	return h
+}
+
+// enqueue schedules a file for hashing. Returns false if the queue is full.
+func (h *hasher) enqueue(job hashJob) bool {
+	select {
+	case h.jobs <- job:
+		return true
+	default:
+		h.mu.Lock()
+		h.skipped++
+		h.mu.Unlock()
+		return false
+	}
+}
+
+// work processes hash jobs until the queue is closed.
+func (h *hasher) work() {
+	defer h.wg.Done()
+	for job := range h.jobs {
+		digest, ok := hashFile(filepath.Join(job.root, job.path), h.maxSize)
+		if !ok {
+			continue
+		}
+		job.state.seenMu.Lock()
+		if job.state.seen.contains(job.path) {
+			job.state.digests[job.path] = digest
+		}
+		job.state.seenMu.Unlock()
+	}
+}
+
+// close stops accepting jobs and waits for in-flight hashing to finish.
+func (h *hasher) close() {
+	close(h.jobs)
+	h.wg.Wait()
+}
+
+// skippedCount returns the number of files skipped because the queue was full.
+func (h *hasher) skippedCount() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.skipped
+}
+
+// hashFile returns the sha256 digest of a regular file in "sha256:<hex>" form.
+// Returns false for files that can't be read, aren't regular files, or exceed maxSize.
+func hashFile(path string, maxSize int64) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return "", false
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", false
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), true
+}
@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	content := []byte("hello world")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	want := "sha256:" + hex.EncodeToString(sum[:])
+
+	got, ok := hashFile(path, 0)
+	if !ok || got != want {
+		t.Errorf("hashFile() = %q, %v, want %q, true", got, ok, want)
+	}
+
+	// Size limit
+	if _, ok := hashFile(path, 4); ok {
+		t.Error("expected file over size limit to be skipped")
+	}
+
+	// Directories are not hashed
+	if _, ok := hashFile(dir, 0); ok {
+		t.Error("expected directory to be skipped")
+	}
+
+	// Missing files are not hashed
+	if _, ok := hashFile(filepath.Join(dir, "missing"), 0); ok {
+		t.Error("expected missing file to be skipped")
+	}
+}
+
+func TestContentHashing(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "small"), []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "large"), make([]byte, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/app", Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithContentHashing(func(uint32) string { return root }, 100, 2))
+
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/small"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/large"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/small"}) // duplicate, not re-hashed
+
+	// Close waits for in-flight hashing
+	p.Close()
+
+	digests := p.Digests()[1000]
+	sum := sha256.Sum256([]byte("abc"))
+	if got, want := digests["/small"], "sha256:"+hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("digest(/small) = %q, want %q", got, want)
+	}
+	if _, ok := digests["/large"]; ok {
+		t.Error("file over size limit should not be hashed")
+	}
+	if p.HashesSkipped() != 0 {
+		t.Errorf("HashesSkipped() = %d, want 0", p.HashesSkipped())
+	}
+}
+
+func TestContentHashingDisabled(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/app", Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0)
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/etc/passwd"})
+	p.Close()
+
+	if d := p.Digests(); d != nil {
+		t.Errorf("Digests() = %v, want nil when disabled", d)
+	}
+}
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/imjasonh/snoop/pkg/java"
+)
+
+// JarUse describes a jar a container's JVMs had on their classpath or
+// opened.
+type JarUse struct {
+	Path string
+
+	// OnClasspath is whether a JVM had the jar on its classpath, and Opened
+	// whether the container was seen opening it.
+	OnClasspath bool
+	Opened      bool
+}
+
+// javaState tracks the jars a container's JVMs use.
+type javaState struct {
+	mu sync.Mutex
+
+	// pids holds the processes whose classpath has been read, and jars the
+	// jars seen on a classpath or opened.
+	pids map[uint32]bool
+	jars map[string]*JarUse
+}
+
+// recordJar records that a process opened the jar at path, reading the
+// process's classpath the first time it opens one.
+func (p *Processor) recordJar(state *containerState, pid uint32, path string) {
+	js := &state.java
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if js.jars == nil {
+		js.pids = make(map[uint32]bool)
+		js.jars = make(map[string]*JarUse)
+	}
+	js.use(path).Opened = true
+	if js.pids[pid] {
+		return
+	}
+	js.pids[pid] = true
+
+	dir := p.javaProc(pid)
+	args := readNulSeparated(dir + "/cmdline")
+	if len(args) == 0 || !java.IsLauncher(args[0]) {
+		return
+	}
+	cwd, err := os.Readlink(dir + "/cwd")
+	if err != nil {
+		cwd = "/"
+	}
+	launch := java.ParseArgs(args, readNulSeparated(dir+"/environ"))
+	for _, jar := range launch.Jars(dir+"/root", cwd) {
+		js.use(jar).OnClasspath = true
+	}
+}
+
+// use returns the entry for the jar at path, adding it if needed. js.mu
+// must be held.
+func (js *javaState) use(path string) *JarUse {
+	u := js.jars[path]
+	if u == nil {
+		u = &JarUse{Path: path}
+		js.jars[path] = u
+	}
+	return u
+}
+
+// readNulSeparated reads a procfs file of NUL-terminated strings, such as
+// cmdline or environ, returning nil if it can't be read.
+func readNulSeparated(name string) []string {
+	data, err := os.ReadFile(name)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(bytes.TrimSuffix(data, []byte{0})), "\x00")
+}
+
+// Jars returns, per container, the jars on its JVMs' classpaths and those
+// it opened, sorted by path, for containers with any. A jar on the
+// classpath that was never opened held no class the application loaded.
+// Returns nil if Java classpath analysis is not enabled.
+func (p *Processor) Jars() map[uint64][]JarUse {
+	if p.javaProc == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]JarUse)
+	for cgroupID, state := range p.containers {
+		state.java.mu.Lock()
+		jars := make([]JarUse, 0, len(state.java.jars))
+		for _, u := range state.java.jars {
+			jars = append(jars, *u)
+		}
+		state.java.mu.Unlock()
+		if len(jars) == 0 {
+			continue
+		}
+		sort.Slice(jars, func(i, j int) bool { return jars[i].Path < jars[j].Path })
+		result[cgroupID] = jars
+	}
+	return result
+}
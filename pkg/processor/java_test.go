@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestJars(t *testing.T) {
+	proc := buildRoot(t, map[string]string{
+		"cmdline":                 "/usr/bin/java\x00-cp\x00lib/*:/opt/extra.jar\x00com.example.Main\x00",
+		"environ":                 "PATH=/bin\x00",
+		"cwd":                     "-> /app",
+		"root/app/lib/used.jar":   "jar",
+		"root/app/lib/unused.jar": "jar",
+		"root/opt/extra.jar":      "jar",
+	})
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+		2000: {CgroupID: 2000, Name: "idle"},
+	}
+	p := NewProcessor(context.Background(), containers, nil, 0, WithJavaClasspath(func(uint32) string { return proc }))
+
+	for _, path := range []string{"/app/lib/used.jar", "/app/lib/used.jar", "/usr/lib/jvm/lib/jrt-fs.jar", "/app/Main.class"} {
+		p.Process(&Event{CgroupID: 1000, PID: 7, SyscallNr: unix.SYS_OPENAT, Path: path})
+	}
+
+	got := p.Jars()
+	want := []JarUse{
+		{Path: "/app/lib/unused.jar", OnClasspath: true},
+		{Path: "/app/lib/used.jar", OnClasspath: true, Opened: true},
+		{Path: "/opt/extra.jar", OnClasspath: true},
+		{Path: "/usr/lib/jvm/lib/jrt-fs.jar", Opened: true},
+	}
+	if !reflect.DeepEqual(got[1000], want) {
+		t.Errorf("Jars() = %+v, want %+v", got[1000], want)
+	}
+	if _, ok := got[2000]; ok {
+		t.Errorf("Jars() has container without jars: %+v", got[2000])
+	}
+}
+
+func TestJarsNotJava(t *testing.T) {
+	proc := buildRoot(t, map[string]string{
+		"cmdline":         "unzip\x00-l\x00/data/x.jar\x00",
+		"root/data/y.jar": "jar",
+	})
+	containers := map[uint64]*ContainerInfo{1000: {CgroupID: 1000, Name: "app"}}
+	p := NewProcessor(context.Background(), containers, nil, 0, WithJavaClasspath(func(uint32) string { return proc }))
+	p.Process(&Event{CgroupID: 1000, PID: 7, SyscallNr: unix.SYS_OPENAT, Path: "/data/x.jar"})
+
+	want := []JarUse{{Path: "/data/x.jar", Opened: true}}
+	if got := p.Jars()[1000]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Jars() = %+v, want %+v", got, want)
+	}
+}
+
+func TestJarsDisabled(t *testing.T) {
+	p := NewProcessor(context.Background(), nil, nil, 0)
+	if got := p.Jars(); got != nil {
+		t.Errorf("Jars() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"os"
+	"sync"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/overlay"
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+// layerState tracks layer attribution for one container.
+type layerState struct {
+	mu     sync.Mutex
+	loaded bool
+	stack  *overlay.Stack // nil if the layers couldn't be read
+	sizes  []overlay.Size // nil until the layers are measured
+
+	// files maps the regular files of image layers to their sizes, when
+	// coverage is enabled; nil until the layers are measured.
+	files map[string]int64
+}
+
+// layerFile is the layer providing an accessed file.
+type layerFile struct {
+	index int
+	size  int64 // of a regular file
+}
+
+// pkgKey identifies a package within a container's database.
+type pkgKey struct {
+	name, version, manager string
+}
+
+// LayerUsage describes a container's image layers and which of them
+// provided the files it accessed.
+type LayerUsage struct {
+	// Layers lists the container's layers, base first.
+	Layers []overlay.Layer
+
+	// Files maps accessed files to the Index of the layer providing them.
+	// Files no layer provides (e.g. on volumes) are omitted.
+	Files map[string]int
+
+	// Sizes holds how much of the merged filesystem each layer provides,
+	// in the order of Layers, and AccessedBytes maps layer Indexes to the
+	// total size of the accessed regular files they provide. Sizes is nil
+	// until the layers have been measured.
+	Sizes         []overlay.Size
+	AccessedBytes map[int]int64
+}
+
+// containerLayers returns the container's layer stack, reading it from pid's
+// mountinfo on first use. Returns nil if the stack isn't available.
+func (p *Processor) containerLayers(state *containerState, pid uint32) *overlay.Stack {
+	ls := &state.layers
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if !ls.loaded {
+		ls.loaded = true
+		stack, err := overlay.Load(p.layerMountInfo(pid), p.layerHostRoot)
+		if err != nil {
+			clog.FromContext(p.ctx).Infof("Layer attribution unavailable for container %s: %v", state.info.Name, err)
+			return nil
+		}
+		ls.stack = stack
+		// Walking every layer can take a while, so do it off the event path
+		p.layerWG.Add(1)
+		go p.measureLayers(state, stack)
+	}
+	return ls.stack
+}
+
+// measureLayers records how much of the container's filesystem each layer
+// provides and, when coverage is enabled, the files of its image layers.
+func (p *Processor) measureLayers(state *containerState, stack *overlay.Stack) {
+	defer p.layerWG.Done()
+	sizes := make([]overlay.Size, len(stack.Layers))
+	var files map[string]int64
+	if p.coverage {
+		files = make(map[string]int64)
+	}
+	stack.Walk(p.layerHostRoot, func(path string, layer int, info os.FileInfo) {
+		if !info.Mode().IsRegular() {
+			return
+		}
+		sizes[layer].Files++
+		sizes[layer].Bytes += info.Size()
+		if files != nil && !stack.Layers[layer].Upper {
+			files[path] = info.Size()
+		}
+	})
+	ls := &state.layers
+	ls.mu.Lock()
+	ls.sizes = sizes
+	ls.files = files
+	ls.mu.Unlock()
+}
+
+// recordLayer attributes a newly seen path to the layer that provides it.
+func (p *Processor) recordLayer(state *containerState, pid uint32, path string) {
+	stack := p.containerLayers(state, pid)
+	if stack == nil {
+		return
+	}
+	l, info := stack.Stat(p.layerHostRoot, path)
+	if l == nil {
+		return
+	}
+	f := layerFile{index: l.Index}
+	if info.Mode().IsRegular() {
+		f.size = info.Size()
+	}
+	state.seenMu.Lock()
+	if state.seen.contains(path) {
+		state.fileLayers[path] = f
+	}
+	state.seenMu.Unlock()
+}
+
+// packageLayers returns the Index of the layer providing each package,
+// judged by the first of its files found in a layer, or nil if the
+// container's layers aren't available.
+func (p *Processor) packageLayers(state *containerState, pid uint32, pkgs []*packages.Package) map[pkgKey]int {
+	stack := p.containerLayers(state, pid)
+	if stack == nil {
+		return nil
+	}
+	result := make(map[pkgKey]int, len(pkgs))
+	for _, pkg := range pkgs {
+		for _, path := range pkg.Files {
+			if l := stack.Find(p.layerHostRoot, path); l != nil {
+				result[pkgKey{pkg.Name, pkg.Version, pkg.Manager}] = l.Index
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Layers returns each container's layers and the layers of the files it
+// accessed, for containers whose layers could be read. Returns nil if layer
+// attribution is not enabled.
+func (p *Processor) Layers() map[uint64]LayerUsage {
+	if p.layerMountInfo == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64]LayerUsage)
+	for cgroupID, state := range p.containers {
+		state.layers.mu.Lock()
+		stack, sizes := state.layers.stack, state.layers.sizes
+		state.layers.mu.Unlock()
+		if stack == nil {
+			continue
+		}
+		state.seenMu.RLock()
+		files := make(map[string]int, len(state.fileLayers))
+		accessed := make(map[int]int64)
+		for path, f := range state.fileLayers {
+			files[path] = f.index
+			accessed[f.index] += f.size
+		}
+		state.seenMu.RUnlock()
+		result[cgroupID] = LayerUsage{Layers: stack.Layers, Files: files, Sizes: sizes, AccessedBytes: accessed}
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/overlay"
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+func TestLayerAttribution(t *testing.T) {
+	ctx := context.Background()
+	host := buildRoot(t, map[string]string{
+		"layers/1/bin/busybox":     "busybox",
+		"layers/1/etc/os-release":  "base",
+		"layers/2/app/main":        "app",
+		"layers/2/etc/os-release":  "app",
+		"layers/upper/tmp/scratch": "runtime",
+	})
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(mountinfo, []byte("1 0 0:50 / / rw - overlay overlay rw,lowerdir=/layers/2:/layers/1,upperdir=/layers/upper,workdir=/work\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loader := func(string) ([]*packages.Package, error) {
+		return []*packages.Package{
+			{Name: "busybox", Manager: "apk", Files: []string{"/bin/busybox"}},
+		}, nil
+	}
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0,
+		WithLayerAttribution(func(uint32) string { return mountinfo }, host),
+		WithPackageAttribution(func(uint32) string { return filepath.Join(host, "layers/1") }, loader))
+
+	for _, path := range []string{"/bin/busybox", "/etc/os-release", "/tmp/scratch", "/data/volume"} {
+		p.Process(&Event{CgroupID: 1000, PID: 1, Path: path})
+	}
+	p.Close()
+
+	usage := p.Layers()[1000]
+	if len(usage.Layers) != 3 || !usage.Layers[2].Upper {
+		t.Fatalf("layers = %+v, want two image layers and an upper layer", usage.Layers)
+	}
+	want := map[string]int{"/bin/busybox": 1, "/etc/os-release": 2, "/tmp/scratch": 3}
+	if len(usage.Files) != len(want) {
+		t.Errorf("files = %v, want %v", usage.Files, want)
+	}
+	for path, index := range want {
+		if usage.Files[path] != index {
+			t.Errorf("layer of %s = %d, want %d", path, usage.Files[path], index)
+		}
+	}
+
+	wantSizes := []overlay.Size{{Files: 1, Bytes: 7}, {Files: 2, Bytes: 6}, {Files: 1, Bytes: 7}}
+	if !reflect.DeepEqual(usage.Sizes, wantSizes) {
+		t.Errorf("sizes = %+v, want %+v", usage.Sizes, wantSizes)
+	}
+	if want := map[int]int64{1: 7, 2: 3, 3: 7}; !reflect.DeepEqual(usage.AccessedBytes, want) {
+		t.Errorf("accessed bytes = %v, want %v", usage.AccessedBytes, want)
+	}
+
+	if stats := p.Packages()[1000]; len(stats) == 0 || stats[0].Layer != 1 {
+		t.Errorf("package stats = %+v, want busybox in layer 1", stats)
+	}
+}
@@ -0,0 +1,395 @@
+package processor
+
+import (
+	"bufio"
+	"debug/elf"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LibraryDependency is a shared library that an executed binary needs,
+// directly or through other libraries.
+type LibraryDependency struct {
+	// Name is the library as listed in DT_NEEDED, or the path of the
+	// program interpreter (the dynamic loader).
+	Name string
+
+	// Path is where the dynamic loader would find the library, or "" if it
+	// isn't in the container.
+	Path string
+
+	// RequiredBy lists the executed binaries needing the library, sorted.
+	RequiredBy []string
+}
+
+// Default library search directories, used when the container doesn't
+// configure its own.
+var (
+	glibcLibraryDirs = []string{"/lib64", "/usr/lib64", "/lib", "/usr/lib"}
+	muslLibraryDirs  = []string{"/lib", "/usr/local/lib", "/usr/lib"}
+)
+
+// elfObject is what the dynamic loader reads from an ELF file.
+type elfObject struct {
+	class   elf.Class
+	machine elf.Machine
+	interp  string
+	needed  []string
+	rpath   []string
+	runpath []string
+}
+
+// libraryState tracks the shared libraries a container's executed binaries
+// need.
+type libraryState struct {
+	mu sync.Mutex
+
+	// execs holds the binaries already checked, and needs the libraries they
+	// need, keyed by name and path.
+	execs map[string]bool
+	needs map[libraryKey]*libraryNeed
+
+	// objects caches parsed ELF files by path, nil for files that aren't
+	// ELF, and dirs the default search directories by interpreter.
+	objects map[string]*elfObject
+	dirs    map[string][]string
+}
+
+// libraryKey identifies a needed library.
+type libraryKey struct {
+	name, path string
+}
+
+// libraryNeed is a needed library and the binaries needing it.
+type libraryNeed struct {
+	real       string // Path with symlinks resolved
+	requiredBy map[string]bool
+}
+
+// recordLibraries resolves the shared libraries a binary needs the first
+// time the container executes it.
+func (p *Processor) recordLibraries(state *containerState, pid uint32, binary string) {
+	ls := &state.libraries
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.execs[binary] {
+		return
+	}
+	if ls.execs == nil {
+		ls.execs = make(map[string]bool)
+		ls.needs = make(map[libraryKey]*libraryNeed)
+		ls.objects = make(map[string]*elfObject)
+		ls.dirs = make(map[string][]string)
+	}
+	ls.execs[binary] = true
+
+	root := p.libRoot(pid)
+	for _, lib := range ls.closure(root, binary) {
+		need := ls.needs[lib]
+		if need == nil {
+			need = &libraryNeed{requiredBy: make(map[string]bool)}
+			if lib.path != "" {
+				need.real, _ = resolveInRoot(root, lib.path)
+			}
+			ls.needs[lib] = need
+		}
+		need.requiredBy[binary] = true
+	}
+}
+
+// closure returns the program interpreter and every library binary needs,
+// directly or through other libraries, found the way the dynamic loader
+// would under root. Libraries that can't be found have an empty path.
+// Returns nil if binary isn't a dynamically linked ELF file. ls.mu must be
+// held.
+func (ls *libraryState) closure(root, binary string) []libraryKey {
+	exe := ls.read(root, binary)
+	if exe == nil {
+		return nil
+	}
+
+	var result []libraryKey
+	if exe.interp != "" {
+		lib := libraryKey{name: exe.interp}
+		if ls.read(root, exe.interp) != nil {
+			lib.path = exe.interp
+		}
+		result = append(result, lib)
+	}
+
+	// The executable's DT_RPATH applies to every library, unless it has a
+	// DT_RUNPATH
+	var inherited []string
+	if len(exe.runpath) == 0 {
+		inherited = expandOrigin(exe.rpath, binary)
+	}
+	dirs := ls.defaultDirs(root, exe.interp)
+
+	type pending struct {
+		name string
+		from string
+		obj  *elfObject
+	}
+	var queue []pending
+	for _, name := range exe.needed {
+		queue = append(queue, pending{name, binary, exe})
+	}
+	done := make(map[string]bool)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if done[next.name] {
+			continue
+		}
+		done[next.name] = true
+
+		var search []string
+		if len(next.obj.runpath) == 0 {
+			search = append(search, expandOrigin(next.obj.rpath, next.from)...)
+			search = append(search, inherited...)
+		}
+		search = append(search, expandOrigin(next.obj.runpath, next.from)...)
+		search = append(search, dirs...)
+
+		lib := libraryKey{name: next.name}
+		var obj *elfObject
+		if strings.Contains(next.name, "/") {
+			if obj = ls.read(root, next.name); obj != nil {
+				lib.path = next.name
+			}
+		} else {
+			for _, dir := range search {
+				candidate := path.Join(dir, next.name)
+				o := ls.read(root, candidate)
+				if o != nil && o.class == exe.class && o.machine == exe.machine {
+					lib.path, obj = candidate, o
+					break
+				}
+			}
+		}
+		result = append(result, lib)
+		if obj != nil {
+			for _, name := range obj.needed {
+				queue = append(queue, pending{name, lib.path, obj})
+			}
+		}
+	}
+	return result
+}
+
+// read parses the ELF file at p under root, returning nil if it can't be
+// read or isn't ELF. ls.mu must be held.
+func (ls *libraryState) read(root, p string) *elfObject {
+	if obj, ok := ls.objects[p]; ok {
+		return obj
+	}
+	obj := readELF(root, p)
+	ls.objects[p] = obj
+	return obj
+}
+
+// readELF parses the ELF file at p under root, returning nil if it can't be
+// read or isn't ELF.
+func readELF(root, p string) *elfObject {
+	if !path.IsAbs(p) {
+		return nil
+	}
+	resolved, err := resolveInRoot(root, p)
+	if err != nil {
+		return nil
+	}
+	f, err := elf.Open(filepath.Join(root, resolved))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	obj := &elfObject{class: f.Class, machine: f.Machine}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_INTERP {
+			b, err := io.ReadAll(prog.Open())
+			if err != nil {
+				return nil
+			}
+			obj.interp = strings.TrimRight(string(b), "\x00")
+		}
+	}
+	if obj.needed, err = f.DynString(elf.DT_NEEDED); err != nil {
+		return nil
+	}
+	for _, rpath := range dynString(f, elf.DT_RPATH) {
+		obj.rpath = append(obj.rpath, strings.Split(rpath, ":")...)
+	}
+	for _, runpath := range dynString(f, elf.DT_RUNPATH) {
+		obj.runpath = append(obj.runpath, strings.Split(runpath, ":")...)
+	}
+	return obj
+}
+
+// dynString returns the values of a dynamic section tag, or nil if f has
+// none.
+func dynString(f *elf.File, tag elf.DynTag) []string {
+	values, _ := f.DynString(tag)
+	return values
+}
+
+// expandOrigin substitutes $ORIGIN in search directories with the
+// directory of the object at from. Directories using other substitutions,
+// or relative ones, are dropped.
+func expandOrigin(dirs []string, from string) []string {
+	var result []string
+	for _, dir := range dirs {
+		dir = strings.ReplaceAll(dir, "${ORIGIN}", "$ORIGIN")
+		dir = strings.ReplaceAll(dir, "$ORIGIN", path.Dir(from))
+		if strings.Contains(dir, "$") || !path.IsAbs(dir) {
+			continue
+		}
+		result = append(result, dir)
+	}
+	return result
+}
+
+// defaultDirs returns the directories the dynamic loader at interp searches
+// after a library's own search paths: those in musl's
+// /etc/ld-musl-<arch>.path, or glibc's /etc/ld.so.conf followed by the
+// built-in ones. ls.mu must be held.
+func (ls *libraryState) defaultDirs(root, interp string) []string {
+	if dirs, ok := ls.dirs[interp]; ok {
+		return dirs
+	}
+	var dirs []string
+	base := path.Base(interp)
+	if arch, ok := strings.CutPrefix(base, "ld-musl-"); ok {
+		arch = strings.TrimSuffix(arch, ".so.1")
+		if data, err := readInRoot(root, "/etc/ld-musl-"+arch+".path"); err == nil {
+			dirs = strings.FieldsFunc(string(data), func(r rune) bool { return r == ':' || r == '\n' })
+		} else {
+			dirs = muslLibraryDirs
+		}
+	} else {
+		dirs = append(ldSoConf(root, "/etc/ld.so.conf", 0), glibcLibraryDirs...)
+	}
+	ls.dirs[interp] = dirs
+	return dirs
+}
+
+// maxLdSoConfDepth bounds nested ld.so.conf includes.
+const maxLdSoConfDepth = 8
+
+// ldSoConf returns the directories listed in the glibc loader
+// configuration file at conf under root, following include directives.
+func ldSoConf(root, conf string, depth int) []string {
+	if depth > maxLdSoConfDepth {
+		return nil
+	}
+	f, err := openInRoot(root, conf)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var dirs []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[0] == "include" {
+			for _, pattern := range fields[1:] {
+				if !path.IsAbs(pattern) {
+					pattern = path.Join(path.Dir(conf), pattern)
+				}
+				// Glob returns matches in lexical order, as the loader reads them
+				matches, _ := filepath.Glob(filepath.Join(root, pattern))
+				for _, m := range matches {
+					dirs = append(dirs, ldSoConf(root, filepath.ToSlash(strings.TrimPrefix(m, filepath.Clean(root))), depth+1)...)
+				}
+			}
+			continue
+		}
+		for _, dir := range fields {
+			if path.IsAbs(dir) {
+				dirs = append(dirs, path.Clean(dir))
+			}
+		}
+	}
+	return dirs
+}
+
+// openInRoot opens the file at p as seen from inside root.
+func openInRoot(root, p string) (*os.File, error) {
+	resolved, err := resolveInRoot(root, p)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(root, resolved))
+}
+
+// readInRoot reads the file at p as seen from inside root.
+func readInRoot(root, p string) ([]byte, error) {
+	f, err := openInRoot(root, p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// UnobservedLibraries returns, per container, the shared libraries that its
+// executed binaries need, directly or through other libraries, but that it
+// wasn't seen opening, sorted by name and path, for containers with any.
+// The dynamic loader itself is loaded by the kernel, so it is listed unless
+// the container also opened it. Returns nil if library dependency checking
+// is not enabled.
+func (p *Processor) UnobservedLibraries() map[uint64][]LibraryDependency {
+	if p.libRoot == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]LibraryDependency)
+	for cgroupID, state := range p.containers {
+		type candidate struct {
+			dep  LibraryDependency
+			real string
+		}
+		var candidates []candidate
+		state.libraries.mu.Lock()
+		for lib, need := range state.libraries.needs {
+			dep := LibraryDependency{Name: lib.name, Path: lib.path}
+			for binary := range need.requiredBy {
+				dep.RequiredBy = append(dep.RequiredBy, binary)
+			}
+			sort.Strings(dep.RequiredBy)
+			candidates = append(candidates, candidate{dep, need.real})
+		}
+		state.libraries.mu.Unlock()
+
+		var deps []LibraryDependency
+		state.seenMu.RLock()
+		for _, c := range candidates {
+			if c.dep.Path != "" && (state.seen.contains(c.dep.Path) || state.seen.contains(c.real)) {
+				continue
+			}
+			deps = append(deps, c.dep)
+		}
+		state.seenMu.RUnlock()
+		if len(deps) == 0 {
+			continue
+		}
+		sort.Slice(deps, func(i, j int) bool {
+			if deps[i].Name != deps[j].Name {
+				return deps[i].Name < deps[j].Name
+			}
+			return deps[i].Path < deps[j].Path
+		})
+		result[cgroupID] = deps
+	}
+	return result
+}
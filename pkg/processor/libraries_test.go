@@ -0,0 +1,161 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// writeELF writes a minimal 64-bit ELF file with the given program
+// interpreter and dynamic section entries.
+func writeELF(t *testing.T, path string, machine elf.Machine, interp string, needed []string, runpath string) {
+	t.Helper()
+	const (
+		ehdrSize = 64
+		phdrSize = 56
+		shdrSize = 64
+	)
+	var progs []elf.Prog64
+	if interp != "" {
+		progs = append(progs, elf.Prog64{Type: uint32(elf.PT_INTERP), Flags: uint32(elf.PF_R)})
+	}
+
+	var data bytes.Buffer
+	off := func() uint64 { return uint64(ehdrSize + phdrSize*len(progs) + data.Len()) }
+	if interp != "" {
+		progs[0].Off = off()
+		data.WriteString(interp + "\x00")
+		progs[0].Filesz = uint64(len(interp) + 1)
+		progs[0].Memsz = progs[0].Filesz
+	}
+
+	dynstrOff := off()
+	strtab := []byte{0}
+	var dyns []elf.Dyn64
+	addString := func(tag elf.DynTag, s string) {
+		dyns = append(dyns, elf.Dyn64{Tag: int64(tag), Val: uint64(len(strtab))})
+		strtab = append(strtab, s+"\x00"...)
+	}
+	for _, name := range needed {
+		addString(elf.DT_NEEDED, name)
+	}
+	if runpath != "" {
+		addString(elf.DT_RUNPATH, runpath)
+	}
+	dyns = append(dyns, elf.Dyn64{Tag: int64(elf.DT_NULL)})
+	data.Write(strtab)
+
+	dynamicOff := off()
+	binary.Write(&data, binary.LittleEndian, dyns)
+	dynamicSize := off() - dynamicOff
+
+	shstrOff := off()
+	shstrtab := "\x00.dynstr\x00.dynamic\x00.shstrtab\x00"
+	data.WriteString(shstrtab)
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_STRTAB), Off: dynstrOff, Size: uint64(len(strtab)), Addralign: 1},
+		{Name: 9, Type: uint32(elf.SHT_DYNAMIC), Off: dynamicOff, Size: dynamicSize, Link: 1, Entsize: 16, Addralign: 8},
+		{Name: 18, Type: uint32(elf.SHT_STRTAB), Off: shstrOff, Size: uint64(len(shstrtab)), Addralign: 1},
+	}
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     ehdrSize,
+		Shoff:     off(),
+		Ehsize:    ehdrSize,
+		Phentsize: phdrSize,
+		Phnum:     uint16(len(progs)),
+		Shentsize: shdrSize,
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  3,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, hdr)
+	binary.Write(&out, binary.LittleEndian, progs)
+	out.Write(data.Bytes())
+	binary.Write(&out, binary.LittleEndian, sections)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnobservedLibraries(t *testing.T) {
+	ctx := context.Background()
+	root := buildRoot(t, map[string]string{
+		"etc/ld.so.conf":                 "include /etc/ld.so.conf.d/*.conf\n",
+		"etc/ld.so.conf.d/x86_64.conf":   "# Multiarch support\n/lib/x86_64-linux-gnu\n/usr/lib/x86_64-linux-gnu\n",
+		"lib":                            "-> usr/lib",
+		"bin/script":                     "#!/bin/sh\n",
+		"usr/lib/i386-linux-gnu/libz.so": "not ELF",
+	})
+	const interp = "/lib64/ld-linux-x86-64.so.2"
+	writeELF(t, filepath.Join(root, interp), elf.EM_X86_64, "", nil, "")
+	writeELF(t, filepath.Join(root, "usr/bin/app"), elf.EM_X86_64, interp, []string{"libapp.so", "libc.so.6", "libmissing.so.1"}, "$ORIGIN/../lib/app")
+	writeELF(t, filepath.Join(root, "usr/lib/app/libapp.so"), elf.EM_X86_64, "", []string{"libz.so.1", "libc.so.6"}, "")
+	// A library for another architecture earlier in the search path is skipped
+	writeELF(t, filepath.Join(root, "usr/lib/app/libc.so.6"), elf.EM_AARCH64, "", nil, "")
+	writeELF(t, filepath.Join(root, "usr/lib/x86_64-linux-gnu/libc.so.6"), elf.EM_X86_64, "", nil, "")
+	writeELF(t, filepath.Join(root, "usr/lib/x86_64-linux-gnu/libz.so.1"), elf.EM_X86_64, "", []string{"libc.so.6"}, "")
+	writeELF(t, filepath.Join(root, "usr/bin/static"), elf.EM_X86_64, "", nil, "")
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithLibraryDependencies(func(uint32) string { return root }))
+
+	for _, path := range []string{"/usr/bin/app", "/usr/bin/static", "/bin/script", "/usr/bin/missing"} {
+		p.Process(&Event{CgroupID: 1000, PID: 1, SyscallNr: unix.SYS_EXECVE, Path: path})
+	}
+	// libc is found through the /lib symlink, but opening its target counts
+	for _, path := range []string{"/usr/lib/app/libapp.so", "/usr/lib/x86_64-linux-gnu/libc.so.6"} {
+		p.Process(&Event{CgroupID: 1000, PID: 1, SyscallNr: unix.SYS_OPENAT, Path: path})
+	}
+
+	want := []LibraryDependency{
+		{Name: interp, Path: interp, RequiredBy: []string{"/usr/bin/app"}},
+		{Name: "libmissing.so.1", RequiredBy: []string{"/usr/bin/app"}},
+		{Name: "libz.so.1", Path: "/lib/x86_64-linux-gnu/libz.so.1", RequiredBy: []string{"/usr/bin/app"}},
+	}
+	if got := p.UnobservedLibraries()[1000]; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnobservedLibraries() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLibraryDirsMusl(t *testing.T) {
+	root := buildRoot(t, map[string]string{
+		"etc/ld-musl-x86_64.path": "/usr/local/lib\n/opt/lib:/lib\n",
+	})
+	ls := libraryState{dirs: make(map[string][]string)}
+	if got, want := ls.defaultDirs(root, "/lib/ld-musl-x86_64.so.1"), []string{"/usr/local/lib", "/opt/lib", "/lib"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("defaultDirs() = %v, want %v", got, want)
+	}
+	if got := ls.defaultDirs(root, "/lib/ld-musl-aarch64.so.1"); !reflect.DeepEqual(got, muslLibraryDirs) {
+		t.Errorf("defaultDirs() without a path file = %v, want %v", got, muslLibraryDirs)
+	}
+}
+
+func TestUnobservedLibrariesDisabled(t *testing.T) {
+	p := NewProcessor(context.Background(), nil, nil, 0)
+	if got := p.UnobservedLibraries(); got != nil {
+		t.Errorf("UnobservedLibraries() = %v, want nil", got)
+	}
+}
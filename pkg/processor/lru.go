@@ -1,14 +1,56 @@
 package processor
 
-import "container/list"
+import (
+	"strings"
+	"unique"
+	"unsafe"
+)
 
-// lruCache implements a simple Least Recently Used cache for string deduplication.
-// It maintains a doubly-linked list for LRU ordering and a map for O(1) lookups.
+// nodeBytes approximates the memory a pathNode takes besides its name: the
+// node itself and its entry in its parent's children map.
+const nodeBytes = int64(unsafe.Sizeof(pathNode{})) + 48
+
+// lruCache implements a Least Recently Used cache for path deduplication.
+// Paths are stored in a tree of their "/"-separated components, so paths in
+// the same directory share its nodes and memory scales with the size of the
+// tree rather than the total length of the paths. A doubly-linked list
+// through the nodes holding paths orders them by recency.
 type lruCache struct {
 	maxSize int
-	items   map[string]*list.Element
-	order   *list.List
+	root    *pathNode
+	size    int
 	evicted uint64
+
+	// bytes approximates the memory the tree takes, and maxBytes, if
+	// positive, limits it.
+	bytes, maxBytes int64
+
+	// changes counts insertions and evictions, and adds every add, so
+	// callers can tell whether the keys, or only their counts, changed.
+	changes, adds uint64
+
+	// head is the most recently used path, and tail the least.
+	head, tail *pathNode
+
+	// onEvict, if set, is called with each key evicted from the cache.
+	onEvict func(key string)
+}
+
+// pathNode is one component of the paths in an lruCache.
+type pathNode struct {
+	// name interns the component, so that components common to many
+	// containers' caches, such as "usr" and "lib", are stored once.
+	name     unique.Handle[string]
+	parent   *pathNode
+	children map[string]*pathNode // keyed by the children's names
+
+	// present is set if the path ending at this node is in the cache, with
+	// count the number of times it has been added since it was inserted.
+	present bool
+	count   uint64
+
+	// prev is the more recently used path, and next the less.
+	prev, next *pathNode
 }
 
 // newLRUCache creates a new LRU cache with the given maximum size.
@@ -16,46 +58,177 @@ type lruCache struct {
 func newLRUCache(maxSize int) *lruCache {
 	return &lruCache{
 		maxSize: maxSize,
-		items:   make(map[string]*list.Element),
-		order:   list.New(),
+		root:    &pathNode{},
 	}
 }
 
 // add adds a key to the cache. Returns true if the key was already present.
 // If the cache is at capacity, the least recently used item is evicted.
 func (c *lruCache) add(key string) bool {
-	// Check if key already exists
-	if elem, exists := c.items[key]; exists {
+	c.adds++
+	n := c.root
+	for rest, more := key, true; more; {
+		var name string
+		name, rest, more = strings.Cut(rest, "/")
+		child := n.children[name]
+		if child == nil {
+			child = n.addChild(name)
+			c.bytes += nodeBytes + int64(len(name))
+		}
+		n = child
+	}
+
+	if n.present {
 		// Move to front (most recently used)
-		c.order.MoveToFront(elem)
+		c.unlink(n)
+		c.pushFront(n)
+		n.count++
 		return true
 	}
 
-	// Add new key
-	elem := c.order.PushFront(key)
-	c.items[key] = elem
+	n.present, n.count = true, 1
+	c.pushFront(n)
+	c.size++
+	c.changes++
+
+	c.trim()
+	return false
+}
+
+// over reports whether the cache exceeds either of its limits.
+func (c *lruCache) over() bool {
+	return (c.maxSize > 0 && c.size > c.maxSize) || (c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
 
-	// Evict if over capacity (only if maxSize > 0)
-	if c.maxSize > 0 && c.order.Len() > c.maxSize {
+// trim evicts the least recently used items until the cache is within its
+// limits. The most recently used item is always kept, even if it alone
+// exceeds maxBytes.
+func (c *lruCache) trim() {
+	for c.size > 1 && c.over() {
 		c.evictOldest()
 	}
+}
 
-	return false
+// addChild adds a child node for the component name.
+func (n *pathNode) addChild(name string) *pathNode {
+	handle := unique.Make(name)
+	child := &pathNode{name: handle, parent: n}
+	if n.children == nil {
+		n.children = make(map[string]*pathNode)
+	}
+	n.children[handle.Value()] = child
+	return child
+}
+
+// find returns the node for key, or nil if it isn't in the tree.
+func (c *lruCache) find(key string) *pathNode {
+	n := c.root
+	for rest, more := key, true; more && n != nil; {
+		var name string
+		name, rest, more = strings.Cut(rest, "/")
+		n = n.children[name]
+	}
+	return n
+}
+
+// contains reports whether key is in the cache without updating its recency.
+func (c *lruCache) contains(key string) bool {
+	n := c.find(key)
+	return n != nil && n.present
+}
+
+// pushFront makes n the most recently used path.
+func (c *lruCache) pushFront(n *pathNode) {
+	n.prev, n.next = nil, c.head
+	if c.head != nil {
+		c.head.prev = n
+	}
+	c.head = n
+	if c.tail == nil {
+		c.tail = n
+	}
+}
+
+// unlink removes n from the recency list.
+func (c *lruCache) unlink(n *pathNode) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		c.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		c.tail = n.prev
+	}
+	n.prev, n.next = nil, nil
 }
 
 // evictOldest removes the least recently used item from the cache.
 func (c *lruCache) evictOldest() {
-	elem := c.order.Back()
-	if elem != nil {
-		c.order.Remove(elem)
-		delete(c.items, elem.Value.(string))
-		c.evicted++
+	n := c.tail
+	if n == nil {
+		return
+	}
+	key := n.path()
+	c.unlink(n)
+	n.present, n.count = false, 0
+	c.size--
+	c.evicted++
+	c.changes++
+
+	// Remove the nodes no other path needs
+	for n != c.root && !n.present && len(n.children) == 0 {
+		delete(n.parent.children, n.name.Value())
+		c.bytes -= nodeBytes + int64(len(n.name.Value()))
+		n = n.parent
+	}
+
+	if c.onEvict != nil {
+		c.onEvict(key)
 	}
 }
 
+// path returns the path ending at n.
+func (n *pathNode) path() string {
+	var names []string
+	for ; n.parent != nil; n = n.parent {
+		names = append(names, n.name.Value())
+	}
+	var b strings.Builder
+	for i := len(names) - 1; i >= 0; i-- {
+		b.WriteString(names[i])
+		if i > 0 {
+			b.WriteByte('/')
+		}
+	}
+	return b.String()
+}
+
+// resize changes the maximum size of the cache, evicting the least recently
+// used items that no longer fit. If maxSize is 0 or negative, the cache is
+// unbounded.
+func (c *lruCache) resize(maxSize int) {
+	c.maxSize = maxSize
+	c.trim()
+}
+
+// setMaxBytes changes the memory limit of the cache, evicting the least
+// recently used items that no longer fit. If maxBytes is 0 or negative, the
+// cache's memory is unbounded.
+func (c *lruCache) setMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+	c.trim()
+}
+
 // len returns the current number of items in the cache.
 func (c *lruCache) len() int {
-	return len(c.items)
+	return c.size
+}
+
+// memory returns the approximate memory the cache takes, in bytes.
+func (c *lruCache) memory() int64 {
+	return c.bytes
 }
 
 // evictions returns the total number of evictions that have occurred.
@@ -63,18 +236,95 @@ func (c *lruCache) evictions() uint64 {
 	return c.evicted
 }
 
+// walk calls fn with each key in the cache under n, whose path is prefix,
+// and its node, in no particular order.
+func walk(n *pathNode, prefix string, fn func(key string, n *pathNode)) {
+	if n.present {
+		fn(prefix, n)
+	}
+	for name, child := range n.children {
+		if n.parent == nil {
+			walk(child, name, fn)
+		} else {
+			walk(child, prefix+"/"+name, fn)
+		}
+	}
+}
+
 // keys returns all keys currently in the cache (unsorted).
 func (c *lruCache) keys() []string {
-	keys := make([]string, 0, len(c.items))
-	for key := range c.items {
+	keys := make([]string, 0, c.size)
+	walk(c.root, "", func(key string, _ *pathNode) {
 		keys = append(keys, key)
-	}
+	})
 	return keys
 }
 
+// counts returns each key in the cache with the number of times it has been
+// added since it was last inserted.
+func (c *lruCache) counts() map[string]uint64 {
+	counts := make(map[string]uint64, c.size)
+	walk(c.root, "", func(key string, n *pathNode) {
+		counts[key] = n.count
+	})
+	return counts
+}
+
+// countsUnder is like counts but only returns dir and the keys under it.
+// Only the subtree for dir is visited.
+func (c *lruCache) countsUnder(dir string) map[string]uint64 {
+	dir = strings.TrimSuffix(dir, "/")
+	counts := make(map[string]uint64)
+	if n := c.find(dir); n != nil {
+		walk(n, dir, func(key string, n *pathNode) {
+			counts[key] = n.count
+		})
+	}
+	return counts
+}
+
+// dirCounts returns the number of keys under each directory depth
+// components deep, e.g. "/usr/lib" at depth 2. Keys less deep count toward
+// themselves.
+func (c *lruCache) dirCounts(depth int) map[string]int {
+	counts := make(map[string]int)
+	var visit func(n *pathNode, prefix string, d int)
+	visit = func(n *pathNode, prefix string, d int) {
+		if d == depth {
+			total := 0
+			walk(n, prefix, func(string, *pathNode) { total++ })
+			if total > 0 {
+				counts[prefix] = total
+			}
+			return
+		}
+		if n.present {
+			counts[prefix]++
+		}
+		for name, child := range n.children {
+			if n.parent == nil {
+				// The root's children are the first components: "" for
+				// absolute paths, which isn't a level of its own
+				if name == "" {
+					visit(child, "", d)
+				} else {
+					visit(child, name, d+1)
+				}
+			} else {
+				visit(child, prefix+"/"+name, d+1)
+			}
+		}
+	}
+	visit(c.root, "", 0)
+	return counts
+}
+
 // reset clears all items from the cache.
 func (c *lruCache) reset() {
-	c.items = make(map[string]*list.Element)
-	c.order = list.New()
+	c.root = &pathNode{}
+	c.head, c.tail = nil, nil
+	c.size = 0
+	c.bytes = 0
 	c.evicted = 0
+	c.changes++
 }
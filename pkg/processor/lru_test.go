@@ -1,6 +1,12 @@
 package processor
 
-import "testing"
+import (
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
 
 func TestLRUCache_Basic(t *testing.T) {
 	cache := newLRUCache(3)
@@ -202,3 +208,148 @@ func TestLRUCache_Reset(t *testing.T) {
 		t.Errorf("len after adding to reset cache = %d, want 1", cache.len())
 	}
 }
+
+func TestLRUCache_OnEvict(t *testing.T) {
+	cache := newLRUCache(2)
+	var evicted []string
+	cache.onEvict = func(key string) {
+		evicted = append(evicted, key)
+	}
+
+	cache.add("a")
+	cache.add("b")
+	cache.add("c")
+
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("evicted = %v, want [a]", evicted)
+	}
+	if cache.contains("a") {
+		t.Error("expected 'a' to be evicted")
+	}
+	if !cache.contains("c") {
+		t.Error("expected 'c' to be present")
+	}
+}
+
+func TestLRUCache_Counts(t *testing.T) {
+	cache := newLRUCache(2)
+	cache.add("a")
+	cache.add("a")
+	cache.add("b")
+
+	counts := cache.counts()
+	if counts["a"] != 2 || counts["b"] != 1 {
+		t.Errorf("counts = %v, want a=2 b=1", counts)
+	}
+
+	// A re-inserted key starts counting again
+	cache.add("c") // evicts a
+	cache.add("a") // evicts b
+	if got := cache.counts()["a"]; got != 1 {
+		t.Errorf("count for re-inserted key = %d, want 1", got)
+	}
+}
+
+func TestLRUCache_SharedPrefixes(t *testing.T) {
+	cache := newLRUCache(2)
+	nodes := func() int {
+		var count func(n *pathNode) int
+		count = func(n *pathNode) int {
+			total := 1
+			for _, child := range n.children {
+				total += count(child)
+			}
+			return total
+		}
+		return count(cache.root) - 1
+	}
+
+	// "", "usr", "lib", and the two files
+	cache.add("/usr/lib/libc.so")
+	cache.add("/usr/lib/libm.so")
+	if got := nodes(); got != 5 {
+		t.Errorf("nodes = %d, want 5", got)
+	}
+
+	// Evicting libc.so removes only its own node, and evicting both the
+	// rest of /usr/lib
+	cache.add("/etc/hosts")
+	if got := nodes(); got != 6 || cache.contains("/usr/lib/libc.so") || !cache.contains("/usr/lib/libm.so") {
+		t.Errorf("after evicting libc.so: nodes = %d, keys = %v", got, cache.keys())
+	}
+	cache.add("/etc/passwd")
+	if got := nodes(); got != 4 {
+		t.Errorf("after evicting libm.so: nodes = %d, want 4", got)
+	}
+
+	// A path can also be a directory of other paths
+	cache.add("/etc")
+	if !cache.contains("/etc") || !cache.contains("/etc/passwd") || cache.contains("/etc/hosts") {
+		t.Errorf("keys = %v, want /etc and /etc/passwd", cache.keys())
+	}
+}
+
+func TestLRUCache_Paths(t *testing.T) {
+	cache := newLRUCache(0)
+	for _, key := range []string{"/usr/bin/ls", "/usr/lib/a.so", "/usr/lib/b.so", "/etc", "relative/file", "//double", "/trailing/"} {
+		cache.add(key)
+	}
+	keys := cache.keys()
+	sort.Strings(keys)
+	want := []string{"//double", "/etc", "/trailing/", "/usr/bin/ls", "/usr/lib/a.so", "/usr/lib/b.so", "relative/file"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	cache.add("/usr/lib/a.so")
+	if got := cache.countsUnder("/usr/lib/"); !maps.Equal(got, map[string]uint64{"/usr/lib/a.so": 2, "/usr/lib/b.so": 1}) {
+		t.Errorf("countsUnder(/usr/lib/) = %v", got)
+	}
+	if got := cache.countsUnder("/missing"); len(got) != 0 {
+		t.Errorf("countsUnder(/missing) = %v", got)
+	}
+
+	want2 := map[string]int{"/usr/bin": 1, "/usr/lib": 2, "/etc": 1, "/trailing/": 1, "//double": 1, "relative/file": 1}
+	if got := cache.dirCounts(2); !maps.Equal(got, want2) {
+		t.Errorf("dirCounts(2) = %v, want %v", got, want2)
+	}
+}
+
+func TestLRUCache_MaxBytes(t *testing.T) {
+	// Room for "", "usr", "lib", and about three short file names
+	cache := newLRUCache(0)
+	cache.setMaxBytes(6*nodeBytes + 20)
+
+	for _, key := range []string{"/usr/lib/a", "/usr/lib/b", "/usr/lib/c"} {
+		cache.add(key)
+	}
+	if cache.len() != 3 || cache.evictions() != 0 {
+		t.Fatalf("len = %d, evictions = %d, want 3 files and no evictions", cache.len(), cache.evictions())
+	}
+
+	// A long path takes more memory than a short one, evicting several
+	// files to fit
+	long := "/opt/" + strings.Repeat("x", 20)
+	cache.add(long)
+	if !cache.contains(long) || cache.contains("/usr/lib/a") || cache.contains("/usr/lib/b") {
+		t.Errorf("keys = %v, want the long path to evict the oldest files", cache.keys())
+	}
+	if cache.memory() > 6*nodeBytes+20 {
+		t.Errorf("memory = %d, over the %d limit", cache.memory(), 6*nodeBytes+20)
+	}
+
+	// The most recent path is kept even if it alone is over the limit
+	huge := "/" + strings.Repeat("y", 1000)
+	cache.add(huge)
+	if cache.len() != 1 || !cache.contains(huge) {
+		t.Errorf("keys = %v, want only the huge path", cache.keys())
+	}
+
+	// Evicting everything frees all the memory the paths took
+	cache.setMaxBytes(0)
+	cache.add("/a")
+	cache.resize(1)
+	if want := 2 * nodeBytes; cache.memory() != want+1 {
+		t.Errorf("memory = %d with only /a, want %d", cache.memory(), want+1)
+	}
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// FileMetadata holds filesystem attributes of an observed file, as seen from
+// inside the container that accessed it.
+type FileMetadata struct {
+	Size    int64
+	Mode    os.FileMode
+	UID     uint32
+	GID     uint32
+	ModTime time.Time
+
+	// Label is the file's SELinux context, e.g.
+	// "system_u:object_r:container_file_t:s0:c1,c2", when it has one.
+	Label string
+}
+
+// statFile stats path relative to root without following a final symlink,
+// matching the processor's policy of recording what the app asked for.
+// Returns false if the file cannot be stat'd (e.g. it doesn't exist or the
+// process has already exited).
+func statFile(root, path string) (FileMetadata, bool) {
+	info, err := os.Lstat(filepath.Join(root, path))
+	if err != nil {
+		return FileMetadata{}, false
+	}
+	md := FileMetadata{
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		md.UID = st.Uid
+		md.GID = st.Gid
+	}
+	md.Label = selinuxLabel(filepath.Join(root, path))
+	return md, true
+}
+
+// selinuxLabel returns the SELinux context of path without following a
+// final symlink, or "" if it has none.
+func selinuxLabel(path string) string {
+	buf := make([]byte, 256)
+	n, err := unix.Lgetxattr(path, "security.selinux", buf)
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimRight(buf[:n], "\x00"))
+}
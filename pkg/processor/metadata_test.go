@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMetadataEnrichment(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc/app.conf"), []byte("hello world"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/app", Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithFileMetadata(func(uint32) string { return root }))
+
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/etc/app.conf"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/etc/missing.conf"})
+
+	md := p.Metadata()[1000]
+	got, ok := md["/etc/app.conf"]
+	if !ok {
+		t.Fatalf("no metadata recorded for /etc/app.conf: %v", md)
+	}
+	if got.Size != int64(len("hello world")) {
+		t.Errorf("Size = %d, want %d", got.Size, len("hello world"))
+	}
+	if got.Mode.Perm() != 0640 {
+		t.Errorf("Mode = %v, want 0640", got.Mode.Perm())
+	}
+	if got.UID != uint32(os.Getuid()) {
+		t.Errorf("UID = %d, want %d", got.UID, os.Getuid())
+	}
+	if got.ModTime.IsZero() {
+		t.Error("ModTime should be set")
+	}
+
+	// Files that can't be stat'd are still recorded, just without metadata
+	if _, ok := md["/etc/missing.conf"]; ok {
+		t.Error("expected no metadata for missing file")
+	}
+	if len(p.Files()[1000]) != 2 {
+		t.Errorf("files = %v, want 2 entries", p.Files()[1000])
+	}
+}
+
+func TestFileMetadataDisabled(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/app", Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0)
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/etc/passwd"})
+
+	if md := p.Metadata(); md != nil {
+		t.Errorf("Metadata() = %v, want nil when disabled", md)
+	}
+}
+
+func TestFileMetadataEvictedWithFile(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/app", Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 2, WithFileMetadata(func(uint32) string { return root }))
+
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/a"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/b"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/c"})
+
+	md := p.Metadata()[1000]
+	if _, ok := md["/a"]; ok {
+		t.Error("metadata for evicted file /a should be dropped")
+	}
+	if len(md) != 2 {
+		t.Errorf("len(metadata) = %d, want 2", len(md))
+	}
+}
@@ -1,9 +1,15 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/chainguard-dev/clog"
 )
 
 func TestMultiContainerProcessor(t *testing.T) {
@@ -148,6 +154,82 @@ func TestUnknownContainer(t *testing.T) {
 	}
 }
 
+func TestUnknownContainerWarningsRateLimited(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := clog.WithLogger(context.Background(), clog.New(slog.NewTextHandler(&buf, nil)))
+	p := NewProcessor(ctx, map[uint64]*ContainerInfo{}, nil, 0, WithUnknownContainerWarnInterval(time.Hour))
+
+	for range 3 {
+		p.Process(&Event{CgroupID: 9999, PID: 100, Path: "/etc/passwd"})
+	}
+	p.Process(&Event{CgroupID: 8888, PID: 100, Path: "/etc/passwd"})
+	if got := strings.Count(buf.String(), "Event from unknown container"); got != 2 {
+		t.Errorf("logged %d warnings, want one per cgroup:\n%s", got, buf.String())
+	}
+	if agg := p.Aggregate(); agg.UnknownEvents != 4 {
+		t.Errorf("UnknownEvents = %d, want 4", agg.UnknownEvents)
+	}
+
+	// A cgroup that's tracked and then removed again warns right away, with
+	// no stale count of suppressed events
+	p.AddContainer(&ContainerInfo{CgroupID: 9999, Name: "app"})
+	p.RemoveContainer(9999)
+	buf.Reset()
+	p.Process(&Event{CgroupID: 9999, PID: 100, Path: "/etc/passwd"})
+	if !strings.Contains(buf.String(), "Event from unknown container (cgroup_id=9999)\"") {
+		t.Errorf("no plain warning after the cgroup was tracked again:\n%s", buf.String())
+	}
+
+	// Once the interval is up, the next warning counts what was suppressed
+	p = NewProcessor(ctx, map[uint64]*ContainerInfo{}, nil, 0, WithUnknownContainerWarnInterval(time.Hour))
+	for range 3 {
+		p.Process(&Event{CgroupID: 9999, PID: 100, Path: "/etc/passwd"})
+	}
+	p.mu.Lock()
+	p.unknownWarned[9999] = time.Now().Add(-2 * time.Hour)
+	p.mu.Unlock()
+	buf.Reset()
+	p.Process(&Event{CgroupID: 9999, PID: 100, Path: "/etc/passwd"})
+	if !strings.Contains(buf.String(), "2 more since the last warning") {
+		t.Errorf("warning doesn't count suppressed events:\n%s", buf.String())
+	}
+}
+
+func TestProcessResultString(t *testing.T) {
+	for r, want := range map[ProcessResult]string{
+		ResultNew:              "new",
+		ResultDuplicate:        "duplicate",
+		ResultUnknownContainer: "unknown_container",
+		ProcessResult(42):      "ProcessResult(42)",
+	} {
+		if got := r.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestLastNewFile(t *testing.T) {
+	p := NewProcessor(context.Background(), map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/container1", Name: "container1"},
+	}, nil, 0)
+	if last := p.Aggregate().LastNewFile; !last.IsZero() {
+		t.Errorf("LastNewFile before any events = %s, want zero", last)
+	}
+
+	before := time.Now()
+	p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/etc/passwd"})
+	first := p.Aggregate().LastNewFile
+	if first.Before(before) {
+		t.Errorf("LastNewFile = %s, want after %s", first, before)
+	}
+
+	// Duplicates don't count as new files
+	p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/etc/passwd"})
+	if last := p.Aggregate().LastNewFile; !last.Equal(first) {
+		t.Errorf("LastNewFile after a duplicate = %s, want %s", last, first)
+	}
+}
+
 func TestPerContainerDeduplication(t *testing.T) {
 	ctx := context.Background()
 
@@ -278,3 +360,286 @@ func TestContainerInfoInStats(t *testing.T) {
 		t.Errorf("container2 CgroupPath = %q, want /pod/container2", c2Stats.CgroupPath)
 	}
 }
+
+func TestTopFiles(t *testing.T) {
+	ctx := context.Background()
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "busy"},
+		2000: {CgroupID: 2000, Name: "quiet"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0)
+
+	// Access counts: /a=3, /b=1, /c=2, /d=1
+	for _, path := range []string{"/a", "/a", "/a", "/b", "/c", "/c", "/d"} {
+		p.Process(&Event{CgroupID: 1000, PID: 100, Path: path})
+	}
+	p.Process(&Event{CgroupID: 2000, PID: 200, Path: "/x"})
+
+	files, truncated := p.TopFiles(3)
+
+	// Ties between /b and /d are broken by path
+	want := []string{"/a", "/b", "/c"}
+	if fmt.Sprint(files[1000]) != fmt.Sprint(want) {
+		t.Errorf("busy files = %v, want %v", files[1000], want)
+	}
+	if truncated[1000] != 1 {
+		t.Errorf("busy truncated = %d, want 1", truncated[1000])
+	}
+	if len(files[2000]) != 1 {
+		t.Errorf("quiet files = %v, want [/x]", files[2000])
+	}
+	if _, ok := truncated[2000]; ok {
+		t.Errorf("quiet container should not be truncated")
+	}
+
+	// No limit returns everything
+	files, truncated = p.TopFiles(0)
+	if len(files[1000]) != 4 || len(truncated) != 0 {
+		t.Errorf("TopFiles(0) = %v, %v; want all files, no truncation", files, truncated)
+	}
+}
+
+func TestTopFilesCached(t *testing.T) {
+	ctx := context.Background()
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "busy"},
+		2000: {CgroupID: 2000, Name: "quiet"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		p.Process(&Event{CgroupID: 1000, PID: 100, Path: path})
+	}
+	p.Process(&Event{CgroupID: 2000, PID: 200, Path: "/x"})
+
+	same := func(a, b []string) bool { return len(a) > 0 && &a[0] == &b[0] }
+
+	// Unchanged containers reuse their list, even if their files were
+	// accessed again, while changed ones are rebuilt
+	before, _ := p.TopFiles(0)
+	p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/a"})
+	p.Process(&Event{CgroupID: 2000, PID: 200, Path: "/y"})
+	after, _ := p.TopFiles(0)
+	if !same(before[1000], after[1000]) {
+		t.Error("busy files were rebuilt without new files")
+	}
+	if same(before[2000], after[2000]) || len(after[2000]) != 2 {
+		t.Errorf("quiet files = %v, want /x and /y", after[2000])
+	}
+
+	// Truncated lists depend on access counts, so repeated accesses
+	// rebuild them
+	before, _ = p.TopFiles(2)
+	p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/c"})
+	p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/c"})
+	after, truncated := p.TopFiles(2)
+	if want := []string{"/a", "/c"}; fmt.Sprint(after[1000]) != fmt.Sprint(want) || truncated[1000] != 1 {
+		t.Errorf("busy files = %v with %d truncated, want %v and 1", after[1000], truncated[1000], want)
+	}
+	if same(before[1000], after[1000]) {
+		t.Error("truncated busy files weren't rebuilt after new accesses")
+	}
+	if again, _ := p.TopFiles(2); !same(after[1000], again[1000]) {
+		t.Error("truncated busy files were rebuilt without changes")
+	}
+}
+
+func TestDirectoryCounts(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+		2000: {CgroupID: 2000, Name: "idle"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0)
+	for _, path := range []string{"/usr/lib/a.so", "/usr/lib/b.so", "/usr/lib/x/c.so", "/usr/bin/app", "/app"} {
+		p.Process(&Event{CgroupID: 1000, PID: 100, Path: path})
+	}
+
+	counts := p.DirectoryCounts(2)
+	want := map[string]int{"/usr/lib": 3, "/usr/bin": 1, "/app": 1}
+	if fmt.Sprint(counts[1000]) != fmt.Sprint(want) {
+		t.Errorf("app directory counts = %v, want %v", counts[1000], want)
+	}
+	if len(counts[2000]) != 0 {
+		t.Errorf("idle directory counts = %v, want none", counts[2000])
+	}
+}
+
+func TestAddRemoveContainer(t *testing.T) {
+	ctx := context.Background()
+	p := NewProcessor(ctx, map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "container1"},
+	}, nil, 10)
+
+	// A restarted container appears under a new cgroup
+	if _, _, result := p.Process(&Event{CgroupID: 3000, PID: 300, Path: "/etc/hosts"}); result != ResultUnknownContainer {
+		t.Errorf("before AddContainer: got %v, want ResultUnknownContainer", result)
+	}
+	p.AddContainer(&ContainerInfo{CgroupID: 3000, Name: "container1-restarted"})
+	if _, _, result := p.Process(&Event{CgroupID: 3000, PID: 300, Path: "/etc/hosts"}); result != ResultNew {
+		t.Errorf("after AddContainer: got %v, want ResultNew", result)
+	}
+	if info := p.Container(3000); info == nil || info.Name != "container1-restarted" {
+		t.Errorf("Container(3000) = %+v", info)
+	}
+
+	p.RemoveContainer(1000)
+	if p.Container(1000) != nil {
+		t.Error("Container(1000) still tracked after RemoveContainer")
+	}
+	if _, ok := p.Stats()[1000]; ok {
+		t.Error("removed container still in Stats")
+	}
+	if _, _, result := p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/etc/passwd"}); result != ResultUnknownContainer {
+		t.Errorf("after RemoveContainer: got %v, want ResultUnknownContainer", result)
+	}
+}
+
+func TestAggregateAfterRemoveContainer(t *testing.T) {
+	p := NewProcessor(context.Background(), map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "container1"},
+		2000: {CgroupID: 2000, Name: "container2"},
+	}, nil, 1)
+	for _, path := range []string{"/etc/hosts", "/etc/passwd", "/etc/hosts"} {
+		p.Process(&Event{CgroupID: 1000, PID: 100, Path: path})
+	}
+	p.Process(&Event{CgroupID: 2000, PID: 200, Path: "/etc/hosts"})
+	before := p.Aggregate()
+
+	// Totals taken between report intervals must not go backwards when a
+	// container exits, or deltas between them underflow
+	p.RemoveContainer(1000)
+	p.Process(&Event{CgroupID: 2000, PID: 200, Path: "/etc/group"})
+	after := p.Aggregate()
+	if after.EventsReceived != before.EventsReceived+1 {
+		t.Errorf("EventsReceived = %d after removing a container, want %d", after.EventsReceived, before.EventsReceived+1)
+	}
+	if after.EventsEvicted < before.EventsEvicted || before.EventsEvicted == 0 {
+		t.Errorf("EventsEvicted went from %d to %d", before.EventsEvicted, after.EventsEvicted)
+	}
+	if after.EventsDuplicate != before.EventsDuplicate || after.EventsProcessed != before.EventsProcessed+1 {
+		t.Errorf("after removing a container: %+v, before: %+v", after, before)
+	}
+	if after.UniqueFiles != 1 {
+		t.Errorf("UniqueFiles = %d, want 1 for the remaining container", after.UniqueFiles)
+	}
+}
+
+func TestSetExclusionsAndMaxUniqueFiles(t *testing.T) {
+	ctx := context.Background()
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, CgroupPath: "/pod/container1", Name: "container1"},
+	}
+	p := NewProcessor(ctx, containers, []string{"/tmp/"}, 0)
+
+	for i := 1; i <= 4; i++ {
+		p.Process(&Event{CgroupID: 1000, PID: 100, Path: fmt.Sprintf("/file%d", i)})
+	}
+	if _, _, result := p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/tmp/x"}); result != ResultExcluded {
+		t.Errorf("/tmp/x before SetExclusions: got %v, want ResultExcluded", result)
+	}
+
+	p.SetExclusions([]string{"/var/"})
+	if _, _, result := p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/tmp/x"}); result != ResultNew {
+		t.Errorf("/tmp/x after SetExclusions: got %v, want ResultNew", result)
+	}
+	if _, _, result := p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/var/log"}); result != ResultExcluded {
+		t.Errorf("/var/log after SetExclusions: got %v, want ResultExcluded", result)
+	}
+
+	// Shrinking the limit evicts the least recently used files right away
+	p.SetMaxUniqueFiles(2)
+	stats := p.Stats()[1000]
+	if stats.UniqueFiles != 2 || stats.EventsEvicted != 3 {
+		t.Errorf("after SetMaxUniqueFiles(2): UniqueFiles = %d, EventsEvicted = %d, want 2 and 3", stats.UniqueFiles, stats.EventsEvicted)
+	}
+
+	// Containers added later get the new limit
+	p.AddContainer(&ContainerInfo{CgroupID: 2000, Name: "container2"})
+	for i := 1; i <= 3; i++ {
+		p.Process(&Event{CgroupID: 2000, PID: 200, Path: fmt.Sprintf("/other%d", i)})
+	}
+	if got := p.Stats()[2000].UniqueFiles; got != 2 {
+		t.Errorf("container2 UniqueFiles = %d, want 2", got)
+	}
+}
+
+func TestContainerOverrides(t *testing.T) {
+	ctx := context.Background()
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+		2000: {CgroupID: 2000, Name: "worker"},
+	}
+	p := NewProcessor(ctx, containers, []string{"/tmp/"}, 10, WithContainerOverrides(func(info *ContainerInfo) ContainerOverrides {
+		if info.Name == "worker" {
+			return ContainerOverrides{Exclude: []string{"/cache/"}, MaxUniqueFiles: 2}
+		}
+		return ContainerOverrides{}
+	}))
+
+	for _, cgroupID := range []uint64{1000, 2000} {
+		for i := 1; i <= 3; i++ {
+			p.Process(&Event{CgroupID: cgroupID, PID: 100, Path: fmt.Sprintf("/file%d", i)})
+		}
+	}
+	if _, _, result := p.Process(&Event{CgroupID: 2000, PID: 100, Path: "/cache/x"}); result != ResultExcluded {
+		t.Errorf("worker /cache/x: got %v, want ResultExcluded", result)
+	}
+	if _, _, result := p.Process(&Event{CgroupID: 2000, PID: 100, Path: "/tmp/x"}); result != ResultExcluded {
+		t.Errorf("worker /tmp/x: got %v, want ResultExcluded", result)
+	}
+	if _, _, result := p.Process(&Event{CgroupID: 1000, PID: 100, Path: "/cache/x"}); result != ResultNew {
+		t.Errorf("app /cache/x: got %v, want ResultNew", result)
+	}
+
+	stats := p.Stats()
+	if stats[1000].UniqueFiles != 4 || stats[2000].UniqueFiles != 2 {
+		t.Errorf("UniqueFiles = %d and %d, want 4 and 2", stats[1000].UniqueFiles, stats[2000].UniqueFiles)
+	}
+
+	// Changing the processor's limit keeps the container's own
+	p.SetMaxUniqueFiles(1)
+	stats = p.Stats()
+	if stats[1000].UniqueFiles != 1 || stats[2000].UniqueFiles != 2 {
+		t.Errorf("after SetMaxUniqueFiles(1): UniqueFiles = %d and %d, want 1 and 2", stats[1000].UniqueFiles, stats[2000].UniqueFiles)
+	}
+}
+
+func TestMaxPathBytes(t *testing.T) {
+	ctx := context.Background()
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+		2000: {CgroupID: 2000, Name: "worker"},
+	}
+	p := NewProcessor(ctx, containers, []string{}, 0, WithMaxPathBytes(1<<20), WithContainerOverrides(func(info *ContainerInfo) ContainerOverrides {
+		if info.Name == "worker" {
+			return ContainerOverrides{MaxPathBytes: 1 << 30}
+		}
+		return ContainerOverrides{}
+	}))
+
+	for _, cgroupID := range []uint64{1000, 2000} {
+		for i := 1; i <= 10; i++ {
+			p.Process(&Event{CgroupID: cgroupID, PID: 100, Path: fmt.Sprintf("/dir%d/%s", i, strings.Repeat("x", 100))})
+		}
+	}
+	stats := p.Stats()
+	if stats[1000].UniqueFiles != 10 || stats[2000].UniqueFiles != 10 {
+		t.Fatalf("UniqueFiles = %d and %d, want 10 and 10", stats[1000].UniqueFiles, stats[2000].UniqueFiles)
+	}
+
+	// Shrinking the limit evicts the least recently used files right away,
+	// but keeps the container's own
+	p.SetMaxPathBytes(5 * (2*nodeBytes + 105))
+	stats = p.Stats()
+	if stats[1000].UniqueFiles >= 10 || stats[1000].EventsEvicted == 0 || stats[2000].UniqueFiles != 10 {
+		t.Errorf("after SetMaxPathBytes: UniqueFiles = %d and %d, want fewer than 10 and 10", stats[1000].UniqueFiles, stats[2000].UniqueFiles)
+	}
+	if state := p.State("")[0]; state.Cache.Bytes > state.Cache.MaxBytes {
+		t.Errorf("app cache = %+v, over its limit", state.Cache)
+	}
+}
@@ -1,10 +1,11 @@
 package processor
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/imjasonh/snoop/pkg/cgroup"
 )
 
 // NormalizePath normalizes a file path by:
@@ -75,8 +76,7 @@ func cleanPath(path string) string {
 // Returns empty string if the process doesn't exist or cwd can't be read.
 func getProcessCwd(pid uint32) string {
 	// Read the symlink target of /proc/<pid>/cwd
-	cwdPath := fmt.Sprintf("/proc/%d/cwd", pid)
-	cwd, err := os.Readlink(cwdPath)
+	cwd, err := os.Readlink(filepath.Join(cgroup.Proc.Dir(pid), "cwd"))
 	if err != nil {
 		return ""
 	}
@@ -0,0 +1,259 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/imjasonh/snoop/pkg/cgroup"
+	"github.com/imjasonh/snoop/pkg/packages"
+	"github.com/imjasonh/snoop/pkg/policy"
+)
+
+// Option configures optional Processor behavior.
+type Option func(*Processor)
+
+// RootFunc returns the root directory through which a process's view of the
+// filesystem can be read, e.g. /proc/<pid>/root.
+type RootFunc func(pid uint32) string
+
+// WithFileMetadata enables stat-ing each newly observed file through the
+// container's root filesystem and recording its size, mode, owner, and mtime.
+// If root is nil, cgroup.Proc.Root is used.
+func WithFileMetadata(root RootFunc) Option {
+	return func(p *Processor) {
+		if root == nil {
+			root = cgroup.Proc.Root
+		}
+		p.metadataRoot = root
+	}
+}
+
+// WithContentHashing enables computing sha256 digests of newly observed files
+// through the container's root filesystem. Files larger than maxSize bytes
+// (0 = no limit) are skipped, and hashing runs on a pool of the given number
+// of workers so it never blocks event processing. If root is nil, cgroup.Proc.Root is used.
+func WithContentHashing(root RootFunc, maxSize int64, workers int) Option {
+	return func(p *Processor) {
+		if root == nil {
+			root = cgroup.Proc.Root
+		}
+		p.hashRoot = root
+		p.hasher = newHasher(maxSize, workers)
+	}
+}
+
+// WithGoBuildInfo enables reading the Go build information (module path,
+// version, and VCS revision) embedded in binaries the first time a container
+// executes them. If root is nil, cgroup.Proc.Root is used.
+func WithGoBuildInfo(root RootFunc) Option {
+	return func(p *Processor) {
+		if root == nil {
+			root = cgroup.Proc.Root
+		}
+		p.buildInfoRoot = root
+	}
+}
+
+// WithLibraryDependencies enables resolving the shared libraries that
+// binaries need, as the dynamic loader would, the first time a container
+// executes them, so that libraries it never opened can be reported; see
+// UnobservedLibraries. If root is nil, cgroup.Proc.Root is used.
+func WithLibraryDependencies(root RootFunc) Option {
+	return func(p *Processor) {
+		if root == nil {
+			root = cgroup.Proc.Root
+		}
+		p.libRoot = root
+	}
+}
+
+// WithJavaClasspath enables reading the classpath of each JVM the first time
+// it opens a jar, from the procfs directory returned by proc, so that jars
+// on the classpath that were never opened can be reported; see Jars. If
+// proc is nil, cgroup.Proc.Dir is used.
+func WithJavaClasspath(proc func(pid uint32) string) Option {
+	return func(p *Processor) {
+		if proc == nil {
+			proc = cgroup.Proc.Dir
+		}
+		p.javaProc = proc
+	}
+}
+
+// WithPackageAttribution enables attributing accessed files to the packages
+// that own them. Each container's package database is read through its root
+// filesystem by the given loaders the first time it accesses a file. If root
+// is nil, cgroup.Proc.Root is used.
+func WithPackageAttribution(root RootFunc, loaders ...packages.Loader) Option {
+	return func(p *Processor) {
+		if root == nil {
+			root = cgroup.Proc.Root
+		}
+		p.pkgRoot = root
+		p.pkgLoaders = loaders
+	}
+}
+
+// WithPackageReload makes package attribution pick up packages installed or
+// removed at runtime. Once a container's database is loaded, the size and
+// modification time of the given database files (e.g. /lib/apk/db/installed)
+// are checked at most every interval as the container accesses files, and
+// the database is reloaded when they change.
+func WithPackageReload(interval time.Duration, paths ...string) Option {
+	return func(p *Processor) {
+		p.pkgCheckInterval = interval
+		p.pkgWatch = paths
+	}
+}
+
+// WithPackageLoadRetry sets how many times a container's package database is
+// looked for, and the minimum time between attempts, replacing the defaults
+// of 5 attempts 2s apart. Attempts below 1 are treated as 1.
+func WithPackageLoadRetry(attempts int, delay time.Duration) Option {
+	return func(p *Processor) {
+		p.pkgLoadAttempts = max(attempts, 1)
+		p.pkgLoadRetry = delay
+	}
+}
+
+// WithUnknownContainerWarnInterval sets the minimum time between warnings
+// about events from the same unknown cgroup, replacing the default of a
+// minute. Events in between are counted and mentioned in the next warning.
+func WithUnknownContainerWarnInterval(d time.Duration) Option {
+	return func(p *Processor) {
+		p.unknownWarnInterval = d
+	}
+}
+
+// WithUnusedPackageFiles includes the list of each package's files that were
+// never accessed in package stats, for both OS and language packages.
+func WithUnusedPackageFiles() Option {
+	return func(p *Processor) {
+		p.pkgUnusedFiles = true
+	}
+}
+
+// WithPackageVerification enables checking accessed files against the
+// checksums their package manager recorded (e.g. apk's per-file digests),
+// to flag package-owned files that were modified after installation. It
+// requires WithPackageAttribution.
+func WithPackageVerification() Option {
+	return func(p *Processor) {
+		p.pkgVerify = true
+	}
+}
+
+// WithLanguagePackages enables attributing accessed files to language
+// packages (e.g. pip packages). Package directories are discovered from the
+// paths containers access and loaded through the container's root
+// filesystem on first use. If root is nil, cgroup.Proc.Root is used.
+func WithLanguagePackages(root RootFunc, loaders ...packages.DirLoader) Option {
+	return func(p *Processor) {
+		if root == nil {
+			root = cgroup.Proc.Root
+		}
+		p.langRoot = root
+		p.dirLoaders = append(p.dirLoaders, loaders...)
+	}
+}
+
+// WithLayerAttribution enables attributing accessed files and packages to
+// the overlayfs image layer that provides them. Each container's layers are
+// read from the mountinfo file returned by mountInfo the first time it
+// accesses a file, and layer directories are read under hostRoot (e.g.
+// "/host" if the host filesystem is mounted there). If mountInfo is nil,
+// cgroup.Proc.MountInfo is used.
+func WithLayerAttribution(mountInfo func(pid uint32) string, hostRoot string) Option {
+	return func(p *Processor) {
+		if mountInfo == nil {
+			mountInfo = cgroup.Proc.MountInfo
+		}
+		p.layerMountInfo = mountInfo
+		p.layerHostRoot = hostRoot
+	}
+}
+
+// WithCoverage enables comparing each container's accessed files with every
+// regular file in its image layers; see Coverage. It has no effect without
+// WithLayerAttribution. Coverage lists up to largestUnused of the largest
+// files never accessed.
+func WithCoverage(largestUnused int) Option {
+	return func(p *Processor) {
+		p.coverage = true
+		p.coverageLargest = largestUnused
+	}
+}
+
+// WithVolumeClassification enables recording which accessed files are on
+// volumes, and of which kind; see mounts.Classify. Each container's mount table is read from the
+// mountinfo file returned by mountInfo the first time it accesses a file. If
+// mountInfo is nil, cgroup.Proc.MountInfo is used.
+func WithVolumeClassification(mountInfo func(pid uint32) string) Option {
+	return func(p *Processor) {
+		if mountInfo == nil {
+			mountInfo = cgroup.Proc.MountInfo
+		}
+		p.volumeMountInfo = mountInfo
+	}
+}
+
+// ContainerOverrides are settings that apply to one container instead of
+// the processor's.
+type ContainerOverrides struct {
+	// Exclude lists path prefixes not recorded for the container, in
+	// addition to the processor's exclusions.
+	Exclude []string
+
+	// MaxUniqueFiles, if positive, limits the container's deduplication
+	// cache instead of the processor's limit.
+	MaxUniqueFiles int
+
+	// MaxPathBytes, if positive, limits the memory of the container's
+	// deduplication cache instead of the processor's limit.
+	MaxPathBytes int64
+}
+
+// WithMaxPathBytes limits the approximate memory, in bytes, that each
+// container's deduplication cache takes (0 = unbounded), in addition to its
+// limit on unique files. Many long, unrelated paths take far more memory
+// than as many files in a few directories, which a limit on unique files
+// alone can't account for.
+func WithMaxPathBytes(maxPathBytesPerContainer int64) Option {
+	return func(p *Processor) {
+		p.maxPathBytes = maxPathBytesPerContainer
+	}
+}
+
+// WithContainerOverrides sets a function returning the overrides for a
+// container, called when the container is first tracked.
+func WithContainerOverrides(overrides func(*ContainerInfo) ContainerOverrides) Option {
+	return func(p *Processor) {
+		p.overrides = overrides
+	}
+}
+
+// ImagePackagesFunc reads the packages installed in the image identified by
+// ref and digest, e.g. from its registry.
+type ImagePackagesFunc func(ctx context.Context, ref, digest string) ([]*packages.Package, error)
+
+// WithImagePackages makes package attribution fall back to load when a
+// container's package database still isn't found through its root
+// filesystem on the last load attempt, e.g. because the runtime doesn't
+// expose it. Containers without a known image are skipped.
+func WithImagePackages(load ImagePackagesFunc) Option {
+	return func(p *Processor) {
+		p.imagePkgs = load
+	}
+}
+
+// WithRules checks every event against rules, in order, counting it against
+// the first one it matches; see RuleHits. Events matching alerting rules are
+// passed to alert once per container, path, and process executable.
+func WithRules(rules []policy.Rule, alert func(RuleAlertEvent)) Option {
+	return func(p *Processor) {
+		for _, r := range rules {
+			p.rules = append(p.rules, &rule{Rule: r})
+		}
+		p.ruleAlert = alert
+	}
+}
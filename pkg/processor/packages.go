@@ -0,0 +1,399 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+const (
+	// packageLoadAttempts bounds how many times a container's package
+	// database is looked for, unless set with WithPackageLoadRetry. Early
+	// events can arrive before the container's root filesystem is
+	// reachable, so a miss is retried.
+	packageLoadAttempts = 5
+
+	// packageLoadRetry is the default minimum time between load attempts.
+	packageLoadRetry = 2 * time.Second
+)
+
+// packageState tracks package attribution for one container.
+type packageState struct {
+	mu       sync.Mutex
+	mapper   *packages.Mapper // nil until the database is loaded
+	loading  bool
+	attempts int
+	next     time.Time // earliest time for the next attempt
+
+	// fingerprint identifies the database files the mapper was built from,
+	// and nextCheck is the earliest time to look for changes to them.
+	fingerprint string
+	nextCheck   time.Time
+
+	// layers maps packages to the Index of the image layer providing them,
+	// when layer attribution is enabled.
+	layers map[pkgKey]int
+
+	// pid is the most recent process seen accessing files, whose root is
+	// used to verify package files.
+	pid uint32
+
+	// verified records package files whose checksums have been checked, and
+	// modified those that didn't match. Both are reset when the database is
+	// (re)loaded.
+	verified map[string]bool
+	modified map[string]ModifiedFile
+
+	// links caches the symlink resolution of accessed paths no package owns,
+	// "" for paths that aren't symlinks. It has its own lock since it's used
+	// while mu is held during database loads.
+	linksMu sync.Mutex
+	links   map[string]string
+
+	// dirs tracks language package directories, keyed by ecosystem and dir.
+	dirs map[dirKey]*dirState
+
+	// execs maps the paths the container executed to what they resolve to
+	// if they're symlinks, and unownedExecs holds those no package owns,
+	// once the database is loaded.
+	execs        map[string]string
+	unownedExecs map[string]bool
+}
+
+// dirKey identifies a language package directory within a container.
+type dirKey struct {
+	ecosystem string
+	dir       string
+}
+
+// dirState tracks one language package directory. Each directory is loaded
+// once; it was just accessed, so it exists.
+type dirState struct {
+	mapper *packages.Mapper // nil while loading or if loading failed
+}
+
+// recordPackage attributes an access of path to its owning package, loading
+// the container's package database in the background on first use.
+func (p *Processor) recordPackage(state *containerState, pid uint32, path string) {
+	ps := &state.packages
+	ps.mu.Lock()
+	mapper := ps.mapper
+	ps.pid = pid
+	now := time.Now()
+	switch {
+	case ps.loading:
+	case mapper == nil && ps.attempts < p.pkgLoadAttempts && !now.Before(ps.next):
+		ps.loading = true
+		ps.attempts++
+		p.pkgWG.Add(1)
+		go p.loadPackages(state, pid)
+	case mapper != nil && len(p.pkgWatch) > 0 && !now.Before(ps.nextCheck):
+		ps.loading = true
+		ps.nextCheck = now.Add(p.pkgCheckInterval)
+		p.pkgWG.Add(1)
+		go p.loadPackages(state, pid)
+	}
+	ps.mu.Unlock()
+
+	if mapper != nil {
+		p.recordOwned(state, mapper, pid, path, 1)
+	}
+}
+
+// recordOwned records n accesses of path with mapper. Paths no package owns
+// are resolved through the container's symlinks, so that e.g. busybox applets
+// like /bin/ls, which are created at install time rather than listed in the
+// package, count toward the package owning the link target. Paths that are
+// still unowned are recorded as orphans.
+func (p *Processor) recordOwned(state *containerState, mapper *packages.Mapper, pid uint32, path string, n uint64) {
+	if name, _ := mapper.Record(path, n); name != "" {
+		return
+	}
+	if target := p.symlinkTarget(state, pid, path); target != "" {
+		if name, _ := mapper.Record(target, n); name != "" {
+			return
+		}
+	}
+	mapper.RecordOrphan(path, n)
+}
+
+// symlinkTarget returns what path resolves to inside the container, or ""
+// if it isn't a symlink or can't be resolved. Results are cached.
+func (p *Processor) symlinkTarget(state *containerState, pid uint32, path string) string {
+	ps := &state.packages
+	ps.linksMu.Lock()
+	target, ok := ps.links[path]
+	ps.linksMu.Unlock()
+	if ok {
+		return target
+	}
+
+	resolved, err := resolveInRoot(p.packageRoot(state, pid), path)
+	if err != nil {
+		// Don't cache failures; the container may not be reachable yet
+		return ""
+	}
+	if resolved == path {
+		resolved = ""
+	}
+	ps.linksMu.Lock()
+	if ps.links == nil {
+		ps.links = make(map[string]string)
+	}
+	ps.links[path] = resolved
+	ps.linksMu.Unlock()
+	return resolved
+}
+
+// packageRoot returns where to read the container's package databases.
+func (p *Processor) packageRoot(state *containerState, pid uint32) string {
+	if state.info.Rootfs != "" {
+		return state.info.Rootfs
+	}
+	return p.pkgRoot(pid)
+}
+
+// loadPackages loads the package database of the container that pid belongs
+// to and, on success, attributes files the container accessed before the
+// database was available.
+// If a database is already loaded, it is only reloaded when the watched
+// database files have changed, e.g. because the container ran apk add.
+func (p *Processor) loadPackages(state *containerState, pid uint32) {
+	defer p.pkgWG.Done()
+	root := p.packageRoot(state, pid)
+	log := clog.FromContext(p.ctx)
+	ps := &state.packages
+
+	fingerprint := databaseFingerprint(root, p.pkgWatch)
+	ps.mu.Lock()
+	reload := ps.mapper != nil
+	lastAttempt := ps.attempts >= p.pkgLoadAttempts
+	if reload && fingerprint == ps.fingerprint {
+		ps.loading = false
+		ps.mu.Unlock()
+		return
+	}
+	ps.mu.Unlock()
+
+	db, err := packages.Load(root, p.pkgLoaders...)
+	if errors.Is(err, fs.ErrNotExist) && !reload && lastAttempt {
+		db, err = p.loadImagePackages(state, err)
+	}
+	var layers map[pkgKey]int
+	if err == nil && p.layerMountInfo != nil {
+		layers = p.packageLayers(state, pid, db.Packages)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.loading = false
+
+	if err != nil && reload {
+		// Keep the previous database; a package manager may be mid-write
+		log.Warnf("Reloading package database for container %s: %v", state.info.Name, err)
+		return
+	}
+	if err != nil {
+		ps.next = time.Now().Add(p.pkgLoadRetry)
+		switch {
+		case ps.attempts < p.pkgLoadAttempts:
+			log.Debugf("Package database for container %s not loaded (attempt %d/%d): %v", state.info.Name, ps.attempts, p.pkgLoadAttempts, err)
+		case errors.Is(err, fs.ErrNotExist):
+			log.Infof("No package database found for container %s", state.info.Name)
+		default:
+			log.Warnf("Loading package database for container %s: %v", state.info.Name, err)
+		}
+		return
+	}
+
+	mapper := packages.NewMapper(db)
+	state.seenMu.RLock()
+	counts := state.seen.counts()
+	state.seenMu.RUnlock()
+	for path, n := range counts {
+		p.recordOwned(state, mapper, pid, path, n)
+	}
+	ps.mapper = mapper
+	ps.layers = layers
+	p.checkExecs(state, db)
+	ps.verified = make(map[string]bool)
+	ps.modified = make(map[string]ModifiedFile)
+	ps.fingerprint = fingerprint
+	ps.nextCheck = time.Now().Add(p.pkgCheckInterval)
+	if reload {
+		log.Infof("Package database changed; reloaded %d packages for container %s", len(db.Packages), state.info.Name)
+		return
+	}
+	log.Infof("Loaded %d packages for container %s", len(db.Packages), state.info.Name)
+}
+
+// loadImagePackages reads the container's packages from its image, if image
+// packages are enabled and the image is known, or returns notFound.
+// Failures to read the image are returned as is, since they're worth a warning.
+func (p *Processor) loadImagePackages(state *containerState, notFound error) (*packages.Database, error) {
+	info := state.info
+	if p.imagePkgs == nil || info.ImageRef == "" {
+		return nil, notFound
+	}
+	pkgs, err := p.imagePkgs(p.ctx, info.ImageRef, info.ImageDigest)
+	if err != nil {
+		return nil, fmt.Errorf("reading packages from image %s: %w", info.ImageRef, err)
+	}
+	clog.FromContext(p.ctx).Infof("Package database for container %s read from image %s", info.Name, info.ImageRef)
+	return packages.NewDatabase(pkgs), nil
+}
+
+// databaseFingerprint summarizes the size and modification time of the
+// given files under root, so changes to them can be detected cheaply.
+func databaseFingerprint(root string, paths []string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(filepath.Join(root, path))
+		if err != nil {
+			fmt.Fprintf(&b, "%s:-;", path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// recordLanguagePackage attributes an access of path to the language package
+// that owns it, loading the enclosing package directory on first use.
+func (p *Processor) recordLanguagePackage(state *containerState, pid uint32, path string) {
+	for _, l := range p.dirLoaders {
+		dir := l.Dir(path)
+		if dir == "" {
+			continue
+		}
+		key := dirKey{ecosystem: l.Ecosystem, dir: dir}
+
+		ps := &state.packages
+		ps.mu.Lock()
+		ds, ok := ps.dirs[key]
+		if !ok {
+			if ps.dirs == nil {
+				ps.dirs = make(map[dirKey]*dirState)
+			}
+			ds = &dirState{}
+			ps.dirs[key] = ds
+			p.pkgWG.Add(1)
+			go p.loadDir(state, ds, l, p.langRoot(pid), dir)
+		}
+		mapper := ds.mapper
+		ps.mu.Unlock()
+
+		if mapper != nil {
+			mapper.Record(path, 1)
+		}
+	}
+}
+
+// loadDir loads the language packages in dir and attributes files the
+// container accessed before they were loaded.
+func (p *Processor) loadDir(state *containerState, ds *dirState, l packages.DirLoader, root, dir string) {
+	defer p.pkgWG.Done()
+	log := clog.FromContext(p.ctx)
+
+	pkgs, err := l.Load(root, dir)
+	if err != nil {
+		log.Warnf("Loading %s packages from %s for container %s: %v", l.Ecosystem, dir, state.info.Name, err)
+		return
+	}
+
+	mapper := packages.NewMapper(packages.NewDatabase(pkgs))
+	state.seenMu.RLock()
+	counts := state.seen.counts()
+	state.seenMu.RUnlock()
+	for path, n := range counts {
+		mapper.Record(path, n)
+	}
+
+	state.packages.mu.Lock()
+	ds.mapper = mapper
+	state.packages.mu.Unlock()
+	log.Infof("Loaded %d %s packages from %s for container %s", len(pkgs), l.Ecosystem, dir, state.info.Name)
+}
+
+// LanguagePackages returns per-package usage for each container, keyed by
+// ecosystem (e.g. "python"), combining all package directories the container
+// used. Returns nil if language package attribution is not enabled.
+func (p *Processor) LanguagePackages() map[uint64]map[string][]packages.PackageStats {
+	if p.langRoot == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64]map[string][]packages.PackageStats)
+	for cgroupID, state := range p.containers {
+		state.packages.mu.Lock()
+		byEcosystem := make(map[string][]packages.PackageStats)
+		for key, ds := range state.packages.dirs {
+			if ds.mapper != nil {
+				byEcosystem[key.ecosystem] = append(byEcosystem[key.ecosystem], p.packageStats(ds.mapper)...)
+			}
+		}
+		state.packages.mu.Unlock()
+
+		if len(byEcosystem) == 0 {
+			continue
+		}
+		for _, stats := range byEcosystem {
+			sort.Slice(stats, func(i, j int) bool {
+				if stats[i].Name != stats[j].Name {
+					return stats[i].Name < stats[j].Name
+				}
+				return stats[i].Version < stats[j].Version
+			})
+		}
+		result[cgroupID] = byEcosystem
+	}
+	return result
+}
+
+// Packages returns per-package usage for each container whose package
+// database has been loaded. Returns nil if package attribution is not enabled.
+func (p *Processor) Packages() map[uint64][]packages.PackageStats {
+	if p.pkgRoot == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]packages.PackageStats)
+	for cgroupID, state := range p.containers {
+		state.packages.mu.Lock()
+		mapper := state.packages.mapper
+		layers := state.packages.layers
+		state.packages.mu.Unlock()
+		if mapper == nil {
+			continue
+		}
+		stats := p.packageStats(mapper)
+		for i := range stats {
+			stats[i].Layer = layers[pkgKey{stats[i].Name, stats[i].Version, stats[i].Manager}]
+		}
+		result[cgroupID] = stats
+	}
+	return result
+}
+
+// packageStats returns the mapper's stats, with unused file lists if enabled.
+func (p *Processor) packageStats(m *packages.Mapper) []packages.PackageStats {
+	if p.pkgUnusedFiles {
+		return m.StatsWithUnusedFiles()
+	}
+	return m.Stats()
+}
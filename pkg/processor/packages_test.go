@@ -0,0 +1,271 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+func TestPackageAttribution(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+
+	loader := func(root string) ([]*packages.Package, error) {
+		if root != "/proc/42/root" {
+			return nil, fmt.Errorf("unexpected root %q", root)
+		}
+		return []*packages.Package{
+			{Name: "curl", Version: "8.5.0", Manager: "test", Files: []string{"/usr/bin/curl", "/usr/lib/libcurl.so"}},
+			{Name: "unused", Version: "1.0", Manager: "test", Files: []string{"/usr/bin/unused"}},
+		}, nil
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(nil, loader))
+
+	// Accesses before the database finishes loading are replayed once it does
+	p.Process(&Event{CgroupID: 1000, PID: 42, Path: "/usr/bin/curl"})
+	p.Process(&Event{CgroupID: 1000, PID: 42, Path: "/usr/bin/curl"})
+	p.pkgWG.Wait()
+	p.Process(&Event{CgroupID: 1000, PID: 42, Path: "/usr/lib/libcurl.so"})
+	p.Process(&Event{CgroupID: 1000, PID: 42, Path: "/etc/hosts"})
+	p.Close()
+
+	stats := p.Packages()[1000]
+	if len(stats) != 3 {
+		t.Fatalf("stats = %+v, want 2 packages and orphans", stats)
+	}
+	want := packages.PackageStats{Name: "curl", Version: "8.5.0", Manager: "test", TotalFiles: 2, AccessedFiles: 2, AccessCount: 3}
+	if !reflect.DeepEqual(stats[0], want) {
+		t.Errorf("curl stats = %+v, want %+v", stats[0], want)
+	}
+	if stats[1].AccessedFiles != 0 {
+		t.Errorf("unused stats = %+v, want no accesses", stats[1])
+	}
+	if stats[2].Name != packages.OrphanName || stats[2].AccessCount != 1 {
+		t.Errorf("orphan stats = %+v, want /etc/hosts accessed once", stats[2])
+	}
+}
+
+func TestPackageAttributionNoDatabase(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+
+	var calls atomic.Int32
+	loader := func(string) ([]*packages.Package, error) {
+		calls.Add(1)
+		return nil, fs.ErrNotExist
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(func(uint32) string { return "/" }, loader))
+
+	// Retries are rate limited, so a burst of events makes a single attempt
+	for i := 0; i < 10; i++ {
+		p.Process(&Event{CgroupID: 1000, PID: 1, Path: fmt.Sprintf("/file%d", i)})
+		p.pkgWG.Wait()
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader called %d times, want 1", got)
+	}
+
+	// Once the retry interval passes, the next event tries again
+	p.containers[1000].packages.mu.Lock()
+	p.containers[1000].packages.next = time.Now().Add(-time.Second)
+	p.containers[1000].packages.mu.Unlock()
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/another"})
+	p.Close()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("loader called %d times after retry interval, want 2", got)
+	}
+
+	if stats := p.Packages(); len(stats) != 0 {
+		t.Errorf("Packages() = %v, want none", stats)
+	}
+}
+
+func TestPackageLoadRetry(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+
+	var calls atomic.Int32
+	loader := func(string) ([]*packages.Package, error) {
+		calls.Add(1)
+		return nil, fs.ErrNotExist
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(func(uint32) string { return "/" }, loader), WithPackageLoadRetry(3, 0))
+
+	// Without a delay every event retries, until the attempts run out
+	for i := 0; i < 10; i++ {
+		p.Process(&Event{CgroupID: 1000, PID: 1, Path: fmt.Sprintf("/file%d", i)})
+		p.pkgWG.Wait()
+	}
+	p.Close()
+	if got := calls.Load(); got != 3 {
+		t.Errorf("loader called %d times, want 3", got)
+	}
+}
+
+func TestPackageAttributionFromImage(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app", ImageRef: "cgr.dev/chainguard/curl", ImageDigest: "sha256:abc"},
+	}
+	loader := func(string) ([]*packages.Package, error) {
+		return nil, fs.ErrNotExist
+	}
+	var gotRef, gotDigest string
+	fromImage := func(_ context.Context, ref, digest string) ([]*packages.Package, error) {
+		gotRef, gotDigest = ref, digest
+		return []*packages.Package{
+			{Name: "curl", Version: "8.5.0", Manager: "apk", Files: []string{"/usr/bin/curl"}},
+		}, nil
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(func(uint32) string { return "/" }, loader), WithImagePackages(fromImage))
+
+	// The image is only read once the filesystem has been tried on every attempt
+	for i := 0; i < packageLoadAttempts; i++ {
+		p.containers[1000].packages.mu.Lock()
+		p.containers[1000].packages.next = time.Time{}
+		p.containers[1000].packages.mu.Unlock()
+		if i == packageLoadAttempts-1 && gotRef != "" {
+			t.Fatalf("image read before the last attempt")
+		}
+		p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/usr/bin/curl"})
+		p.pkgWG.Wait()
+	}
+	p.Close()
+
+	if gotRef != "cgr.dev/chainguard/curl" || gotDigest != "sha256:abc" {
+		t.Errorf("image read = %q@%q, want the container's image", gotRef, gotDigest)
+	}
+	stats := p.Packages()[1000]
+	if len(stats) != 1 || stats[0].Name != "curl" || stats[0].AccessCount != packageLoadAttempts {
+		t.Errorf("stats = %+v, want curl with replayed accesses", stats)
+	}
+}
+
+func TestPackageAttributionDisabled(t *testing.T) {
+	ctx := context.Background()
+	p := NewProcessor(ctx, map[uint64]*ContainerInfo{1000: {CgroupID: 1000}}, nil, 0)
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/usr/bin/curl"})
+	if p.Packages() != nil {
+		t.Error("Packages() should be nil when attribution is disabled")
+	}
+}
+
+func TestLanguagePackageAttribution(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+
+	var loads atomic.Int32
+	loader := packages.DirLoader{
+		Ecosystem: "test",
+		Dir: func(path string) string {
+			if strings.HasPrefix(path, "/venv/") {
+				return "/venv"
+			}
+			return ""
+		},
+		Load: func(root, dir string) ([]*packages.Package, error) {
+			loads.Add(1)
+			return []*packages.Package{
+				{Name: "requests", Version: "2.31.0", Files: []string{"/venv/requests/api.py", "/venv/requests/models.py"}},
+				{Name: "unused", Version: "1.0", Files: []string{"/venv/unused/__init__.py"}},
+			}, nil
+		},
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithLanguagePackages(func(uint32) string { return "/" }, loader))
+
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/venv/requests/api.py"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/usr/bin/python3"})
+	p.pkgWG.Wait()
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/venv/requests/models.py"})
+	p.Close()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("directory loaded %d times, want 1", got)
+	}
+	stats := p.LanguagePackages()[1000]["test"]
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v, want 2 packages", stats)
+	}
+	if stats[0].Name != "requests" || stats[0].AccessedFiles != 2 {
+		t.Errorf("requests stats = %+v, want 2 accessed files", stats[0])
+	}
+	if stats[1].AccessedFiles != 0 {
+		t.Errorf("unused stats = %+v, want none accessed", stats[1])
+	}
+}
+
+func TestPackageReload(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	db := filepath.Join(root, "db")
+	if err := os.WriteFile(db, []byte("curl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test database is a list of package names, each owning /usr/bin/<name>
+	var loads atomic.Int32
+	loader := func(root string) ([]*packages.Package, error) {
+		loads.Add(1)
+		data, err := os.ReadFile(filepath.Join(root, "db"))
+		if err != nil {
+			return nil, err
+		}
+		var pkgs []*packages.Package
+		for _, name := range strings.Fields(string(data)) {
+			pkgs = append(pkgs, &packages.Package{Name: name, Manager: "test", Files: []string{"/usr/bin/" + name}})
+		}
+		return pkgs, nil
+	}
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0,
+		WithPackageAttribution(func(uint32) string { return root }, loader),
+		WithPackageReload(0, "/db"))
+
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/usr/bin/curl"})
+	p.pkgWG.Wait()
+
+	// Unchanged database files are not reloaded
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/usr/bin/curl"})
+	p.pkgWG.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Errorf("database loaded %d times, want 1", n)
+	}
+
+	// Simulate apk add at runtime
+	if err := os.WriteFile(db, []byte("curl jq"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/usr/bin/jq"})
+	p.pkgWG.Wait()
+	p.Close()
+
+	stats := p.Packages()[1000]
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v, want curl and jq", stats)
+	}
+	// Accesses made before the reload are replayed into the new database
+	for _, s := range stats {
+		if s.AccessedFiles != 1 {
+			t.Errorf("%s accessed files = %d, want 1", s.Name, s.AccessedFiles)
+		}
+	}
+}
@@ -2,18 +2,39 @@ package processor
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/java"
+	"github.com/imjasonh/snoop/pkg/packages"
+	"github.com/imjasonh/snoop/pkg/policy"
 )
 
 // ContainerInfo holds information about a discovered container.
 // This mirrors cgroup.ContainerInfo to avoid circular dependencies.
 type ContainerInfo struct {
-	CgroupID   uint64
-	CgroupPath string
-	Name       string
+	CgroupID    uint64
+	CgroupPath  string
+	Name        string
+	ID          string // Full runtime container ID, if known
+	ImageRef    string
+	ImageDigest string
+
+	// PodUID, PodName, and PodNamespace identify the container's pod when
+	// tracing every pod on a node.
+	PodUID       string
+	PodName      string
+	PodNamespace string
+
+	// Ephemeral marks an ephemeral container, e.g. one added by kubectl debug.
+	Ephemeral bool
+
+	// Rootfs, if set, is where the container's root filesystem can be read
+	// for package databases, instead of through /proc/<pid>/root.
+	Rootfs string
 }
 
 // Event represents a file access event from the eBPF program.
@@ -31,6 +52,54 @@ type containerState struct {
 	seen   *lruCache
 	seenMu sync.RWMutex
 
+	// metadata holds stat results for files in seen; guarded by seenMu.
+	metadata map[string]FileMetadata
+
+	// digests holds content digests for files in seen; guarded by seenMu.
+	digests map[string]string
+
+	// execs holds Go build info for executed binaries, nil for binaries
+	// without it; guarded by seenMu. Unlike metadata, it isn't tied to seen,
+	// since a container executes few distinct binaries.
+	execs map[string]*GoBuildInfo
+
+	// libraries tracks the shared libraries executed binaries need, when
+	// library dependency checking is enabled.
+	libraries libraryState
+
+	// java tracks the jars JVMs use, when Java classpath analysis is
+	// enabled.
+	java javaState
+
+	// packages tracks package attribution when it is enabled.
+	packages packageState
+
+	// layers tracks layer attribution when it is enabled, and fileLayers
+	// maps files in seen to the layer providing them; guarded by seenMu.
+	layers     layerState
+	fileLayers map[string]layerFile
+
+	// mounts holds the container's mount table when volume classification
+	// is enabled, and volumeFiles the files in seen on volumes rather than
+	// the container's root filesystem; volumeFiles is guarded by seenMu.
+	mounts      mountState
+	volumeFiles map[string]VolumeFile
+
+	// preexisting holds the files in seen first recorded by Snapshot;
+	// guarded by seenMu.
+	preexisting map[string]struct{}
+
+	// files caches the container's report file list, so it is only
+	// rebuilt and sorted when seen changes; guarded by filesMu.
+	files   fileList
+	filesMu sync.Mutex
+
+	// overrides are the container's own settings, if any.
+	overrides ContainerOverrides
+
+	// ruleAlerts holds the alerts already reported; guarded by mu.
+	ruleAlerts map[ruleAlertKey]struct{}
+
 	// Per-container metrics
 	eventsReceived  uint64
 	eventsProcessed uint64
@@ -45,18 +114,115 @@ type Processor struct {
 	ctx          context.Context
 	containers   map[uint64]*containerState
 	containersMu sync.RWMutex
-	excluded     []string
 
-	// Global metrics for unknown containers
+	// excluded lists the path prefixes that aren't recorded.
+	excluded   []string
+	excludedMu sync.RWMutex
+
+	// maxUniqueFiles bounds each container's deduplication cache; guarded
+	// by containersMu.
+	maxUniqueFiles int
+
+	// maxPathBytes bounds the memory of each container's deduplication
+	// cache; guarded by containersMu.
+	maxPathBytes int64
+
+	// overrides, if set, returns a container's own settings.
+	overrides func(*ContainerInfo) ContainerOverrides
+
+	// metadataRoot is non-nil when file metadata enrichment is enabled.
+	metadataRoot RootFunc
+
+	// hasher and hashRoot are non-nil when content hashing is enabled.
+	hasher   *hasher
+	hashRoot RootFunc
+
+	// buildInfoRoot is non-nil when Go build info detection is enabled.
+	buildInfoRoot RootFunc
+
+	// libRoot is non-nil when library dependency checking is enabled.
+	libRoot RootFunc
+
+	// javaProc is non-nil when Java classpath analysis is enabled.
+	javaProc func(pid uint32) string
+
+	// pkgRoot is non-nil when package attribution is enabled.
+	pkgRoot    RootFunc
+	pkgLoaders []packages.Loader
+	pkgWG      sync.WaitGroup
+
+	// pkgLoadAttempts bounds how many times a container's package database
+	// is looked for, at least pkgLoadRetry apart.
+	pkgLoadAttempts int
+	pkgLoadRetry    time.Duration
+
+	// imagePkgs, if set, reads packages from a container's image when its
+	// database isn't found in its filesystem.
+	imagePkgs ImagePackagesFunc
+
+	// pkgWatch lists package database files whose changes trigger a reload,
+	// checked at most every pkgCheckInterval.
+	pkgWatch         []string
+	pkgCheckInterval time.Duration
+
+	// pkgUnusedFiles includes each package's never-accessed files in its stats.
+	pkgUnusedFiles bool
+
+	// pkgVerify enables checking accessed package files against their
+	// recorded checksums.
+	pkgVerify bool
+
+	// layerMountInfo is non-nil when layer attribution is enabled.
+	layerMountInfo func(pid uint32) string
+	layerHostRoot  string
+	layerWG        sync.WaitGroup
+
+	// coverage enables indexing the files of image layers, and
+	// coverageLargest is how many never-accessed files Coverage lists.
+	coverage        bool
+	coverageLargest int
+
+	// volumeMountInfo is non-nil when volume classification is enabled.
+	volumeMountInfo func(pid uint32) string
+
+	// langRoot is non-nil when language package attribution is enabled.
+	langRoot   RootFunc
+	dirLoaders []packages.DirLoader
+
+	// rules are checked in order against every event, and ruleAlert, if
+	// set, reports the events matching alerting rules. ruleExe returns a
+	// process's executable.
+	rules     []*rule
+	ruleAlert func(RuleAlertEvent)
+	ruleExe   func(pid uint32) string
+
+	// Global metrics for unknown containers, and when the last new file
+	// was recorded in any container
 	unknownEvents uint64
+	lastNewFile   time.Time
 	mu            sync.Mutex
+
+	// retired holds the event counters of removed containers, so that
+	// Aggregate's totals never decrease; guarded by mu
+	retired AggregateStats
+
+	// unknownWarned holds when events from each unknown cgroup were last
+	// logged, and unknownSuppressed how many haven't been since; guarded by
+	// mu. Each cgroup is logged at most once per unknownWarnInterval.
+	unknownWarned       map[uint64]time.Time
+	unknownSuppressed   map[uint64]uint64
+	unknownWarnInterval time.Duration
 }
 
+// unknownWarnInterval is the default minimum time between warnings about
+// events from the same unknown cgroup.
+const unknownWarnInterval = time.Minute
+
 // NewProcessor creates a new event processor for multiple containers.
 // containers maps cgroup IDs to container information.
 // If excludePrefixes is nil, DefaultExclusions() will be used.
 // maxUniqueFilesPerContainer limits each container's deduplication cache size (0 = unbounded).
-func NewProcessor(ctx context.Context, containers map[uint64]*ContainerInfo, excludePrefixes []string, maxUniqueFilesPerContainer int) *Processor {
+func NewProcessor(ctx context.Context, containers map[uint64]*ContainerInfo, excludePrefixes []string, maxUniqueFilesPerContainer int, opts ...Option) *Processor {
 	log := clog.FromContext(ctx)
 	if excludePrefixes == nil {
 		excludePrefixes = DefaultExclusions()
@@ -71,22 +237,233 @@ func NewProcessor(ctx context.Context, containers map[uint64]*ContainerInfo, exc
 		log.Info("Per-container deduplication cache is unbounded")
 	}
 
+	p := &Processor{
+		ctx:                 ctx,
+		excluded:            excludePrefixes,
+		pkgLoadAttempts:     packageLoadAttempts,
+		pkgLoadRetry:        packageLoadRetry,
+		unknownWarned:       make(map[uint64]time.Time),
+		unknownSuppressed:   make(map[uint64]uint64),
+		unknownWarnInterval: unknownWarnInterval,
+		ruleExe:             procExe,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.metadataRoot != nil {
+		log.Info("File metadata enrichment enabled")
+	}
+	if p.hasher != nil {
+		log.Infof("Content hashing enabled (max file size: %d bytes)", p.hasher.maxSize)
+	}
+	if p.buildInfoRoot != nil {
+		log.Info("Go build info detection enabled")
+	}
+	if p.libRoot != nil {
+		log.Info("Shared library dependency checking enabled")
+	}
+	if p.javaProc != nil {
+		log.Info("Java classpath analysis enabled")
+	}
+	if p.pkgRoot != nil {
+		log.Info("Package attribution enabled")
+	}
+	for _, l := range p.dirLoaders {
+		log.Infof("%s package attribution enabled", l.Ecosystem)
+	}
+	if p.layerMountInfo != nil {
+		log.Info("Layer attribution enabled")
+		if p.coverage {
+			log.Info("Image file coverage enabled")
+		}
+	}
+	if p.volumeMountInfo != nil {
+		log.Info("Volume classification enabled")
+	}
+	if p.maxPathBytes > 0 {
+		log.Infof("Per-container deduplication cache limited to about %d bytes", p.maxPathBytes)
+	}
+	if len(p.rules) > 0 {
+		log.Infof("Checking events against %d file access rules", len(p.rules))
+	}
+
 	// Initialize per-container state
-	containerStates := make(map[uint64]*containerState)
+	p.maxUniqueFiles = maxUniqueFilesPerContainer
+	p.containers = make(map[uint64]*containerState)
 	for cgroupID, info := range containers {
-		containerStates[cgroupID] = &containerState{
-			info: info,
-			seen: newLRUCache(maxUniqueFilesPerContainer),
-		}
+		p.containers[cgroupID] = p.newContainerState(info)
+	}
+
+	return p
+}
+
+// newContainerState creates the tracking state for a container.
+func (p *Processor) newContainerState(info *ContainerInfo) *containerState {
+	state := &containerState{info: info}
+	if p.overrides != nil {
+		state.overrides = p.overrides(info)
+	}
+	state.seen = newLRUCache(state.maxUniqueFiles(p.maxUniqueFiles))
+	state.seen.setMaxBytes(state.maxPathBytes(p.maxPathBytes))
+	if p.metadataRoot != nil {
+		state.metadata = make(map[string]FileMetadata)
 	}
+	if p.hasher != nil {
+		state.digests = make(map[string]string)
+	}
+	if p.buildInfoRoot != nil {
+		state.execs = make(map[string]*GoBuildInfo)
+	}
+	if p.layerMountInfo != nil {
+		state.fileLayers = make(map[string]layerFile)
+	}
+	if p.volumeMountInfo != nil {
+		state.volumeFiles = make(map[string]VolumeFile)
+	}
+	state.seen.onEvict = func(key string) {
+		delete(state.metadata, key)
+		delete(state.digests, key)
+		delete(state.fileLayers, key)
+		delete(state.volumeFiles, key)
+		delete(state.preexisting, key)
+	}
+	return state
+}
 
-	return &Processor{
-		ctx:        ctx,
-		containers: containerStates,
-		excluded:   excludePrefixes,
+// AddContainer starts tracking a container discovered after the processor
+// was created. Adding a container that is already tracked does nothing.
+func (p *Processor) AddContainer(info *ContainerInfo) {
+	p.containersMu.Lock()
+	defer p.containersMu.Unlock()
+	if _, ok := p.containers[info.CgroupID]; ok {
+		return
+	}
+	p.containers[info.CgroupID] = p.newContainerState(info)
+	clog.FromContext(p.ctx).Infof("Tracking container %s (cgroup_id=%d)", info.Name, info.CgroupID)
+
+	p.mu.Lock()
+	delete(p.unknownWarned, info.CgroupID)
+	delete(p.unknownSuppressed, info.CgroupID)
+	p.mu.Unlock()
+}
+
+// warnUnknown counts an event from a cgroup that isn't tracked, such as a
+// container that just restarted, and logs it unless the cgroup was logged
+// within the warning interval, so a busy one doesn't flood the logs.
+func (p *Processor) warnUnknown(cgroupID uint64) {
+	p.mu.Lock()
+	p.unknownEvents++
+	now := time.Now()
+	if last, ok := p.unknownWarned[cgroupID]; ok && now.Sub(last) < p.unknownWarnInterval {
+		p.unknownSuppressed[cgroupID]++
+		p.mu.Unlock()
+		return
+	}
+	p.unknownWarned[cgroupID] = now
+	suppressed := p.unknownSuppressed[cgroupID]
+	delete(p.unknownSuppressed, cgroupID)
+	p.mu.Unlock()
+
+	log := clog.FromContext(p.ctx)
+	if suppressed > 0 {
+		log.Warnf("Event from unknown container (cgroup_id=%d), %d more since the last warning", cgroupID, suppressed)
+	} else {
+		log.Warnf("Event from unknown container (cgroup_id=%d)", cgroupID)
 	}
 }
 
+// RemoveContainer stops tracking a container that went away, discarding
+// everything recorded for it. Later events from its cgroup are reported
+// as ResultUnknownContainer.
+func (p *Processor) RemoveContainer(cgroupID uint64) {
+	p.containersMu.Lock()
+	defer p.containersMu.Unlock()
+	if state, ok := p.containers[cgroupID]; ok {
+		delete(p.containers, cgroupID)
+		state.mu.Lock()
+		state.seenMu.RLock()
+		p.mu.Lock()
+		p.retired.EventsReceived += state.eventsReceived
+		p.retired.EventsProcessed += state.eventsProcessed
+		p.retired.EventsExcluded += state.eventsExcluded
+		p.retired.EventsDuplicate += state.eventsDuplicate
+		p.retired.EventsEvicted += state.seen.evictions()
+		p.mu.Unlock()
+		state.seenMu.RUnlock()
+		state.mu.Unlock()
+		clog.FromContext(p.ctx).Infof("Stopped tracking container %s (cgroup_id=%d)", state.info.Name, cgroupID)
+	}
+}
+
+// SetExclusions replaces the path prefixes that aren't recorded, for
+// events processed from now on. If excludePrefixes is nil,
+// DefaultExclusions() will be used.
+func (p *Processor) SetExclusions(excludePrefixes []string) {
+	if excludePrefixes == nil {
+		excludePrefixes = DefaultExclusions()
+	}
+	p.excludedMu.Lock()
+	defer p.excludedMu.Unlock()
+	p.excluded = excludePrefixes
+}
+
+// SetMaxUniqueFiles changes the size limit of each container's
+// deduplication cache (0 = unbounded). Caches over the new limit evict
+// their least recently used files right away.
+func (p *Processor) SetMaxUniqueFiles(maxUniqueFilesPerContainer int) {
+	p.containersMu.Lock()
+	defer p.containersMu.Unlock()
+	p.maxUniqueFiles = maxUniqueFilesPerContainer
+	for _, state := range p.containers {
+		state.seenMu.Lock()
+		state.seen.resize(state.maxUniqueFiles(maxUniqueFilesPerContainer))
+		state.seenMu.Unlock()
+	}
+}
+
+// maxUniqueFiles returns the container's deduplication cache limit, given
+// the processor's.
+func (s *containerState) maxUniqueFiles(processorLimit int) int {
+	if s.overrides.MaxUniqueFiles > 0 {
+		return s.overrides.MaxUniqueFiles
+	}
+	return processorLimit
+}
+
+// SetMaxPathBytes changes the approximate memory limit, in bytes, of each
+// container's deduplication cache (0 = unbounded). Caches over the new limit
+// evict their least recently used files right away.
+func (p *Processor) SetMaxPathBytes(maxPathBytesPerContainer int64) {
+	p.containersMu.Lock()
+	defer p.containersMu.Unlock()
+	p.maxPathBytes = maxPathBytesPerContainer
+	for _, state := range p.containers {
+		state.seenMu.Lock()
+		state.seen.setMaxBytes(state.maxPathBytes(maxPathBytesPerContainer))
+		state.seenMu.Unlock()
+	}
+}
+
+// maxPathBytes returns the container's deduplication cache memory limit,
+// given the processor's.
+func (s *containerState) maxPathBytes(processorLimit int64) int64 {
+	if s.overrides.MaxPathBytes > 0 {
+		return s.overrides.MaxPathBytes
+	}
+	return processorLimit
+}
+
+// Container returns the information of a tracked container, or nil if the
+// cgroup isn't tracked.
+func (p *Processor) Container(cgroupID uint64) *ContainerInfo {
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+	if state, ok := p.containers[cgroupID]; ok {
+		return state.info
+	}
+	return nil
+}
+
 // ProcessResult indicates what happened when processing an event.
 type ProcessResult int
 
@@ -103,6 +480,23 @@ const (
 	ResultUnknownContainer
 )
 
+func (r ProcessResult) String() string {
+	switch r {
+	case ResultNew:
+		return "new"
+	case ResultDuplicate:
+		return "duplicate"
+	case ResultExcluded:
+		return "excluded"
+	case ResultEmpty:
+		return "empty"
+	case ResultUnknownContainer:
+		return "unknown_container"
+	default:
+		return fmt.Sprintf("ProcessResult(%d)", int(r))
+	}
+}
+
 // Process handles an incoming event, normalizing the path and deduplicating per container.
 // Returns the container ID, normalized path, and a result indicating what happened.
 func (p *Processor) Process(event *Event) (uint64, string, ProcessResult) {
@@ -112,10 +506,7 @@ func (p *Processor) Process(event *Event) (uint64, string, ProcessResult) {
 	p.containersMu.RUnlock()
 
 	if !exists {
-		p.mu.Lock()
-		p.unknownEvents++
-		p.mu.Unlock()
-		clog.FromContext(p.ctx).Warnf("Event from unknown container (cgroup_id=%d)", event.CgroupID)
+		p.warnUnknown(event.CgroupID)
 		return event.CgroupID, "", ResultUnknownContainer
 	}
 
@@ -130,8 +521,21 @@ func (p *Processor) Process(event *Event) (uint64, string, ProcessResult) {
 		return event.CgroupID, "", ResultEmpty
 	}
 
+	// Rules and executables no package owns see every event, since
+	// exclusions only keep reports small
+	if len(p.rules) > 0 {
+		p.checkRules(state, event, normalized)
+	}
+	exec := policy.Operation(event.SyscallNr) == "exec"
+	if p.pkgRoot != nil && exec {
+		p.recordPackageExec(state, event.PID, normalized)
+	}
+
 	// Check exclusions
-	if IsExcluded(normalized, p.excluded) {
+	p.excludedMu.RLock()
+	excluded := IsExcluded(normalized, p.excluded) || IsExcluded(normalized, state.overrides.Exclude)
+	p.excludedMu.RUnlock()
+	if excluded {
 		state.mu.Lock()
 		state.eventsExcluded++
 		state.mu.Unlock()
@@ -143,6 +547,22 @@ func (p *Processor) Process(event *Event) (uint64, string, ProcessResult) {
 	exists = state.seen.add(normalized)
 	state.seenMu.Unlock()
 
+	if p.buildInfoRoot != nil && exec {
+		p.recordExec(state, event.PID, normalized)
+	}
+	if p.libRoot != nil && exec {
+		p.recordLibraries(state, event.PID, normalized)
+	}
+	if p.javaProc != nil && java.IsArchive(normalized) {
+		p.recordJar(state, event.PID, normalized)
+	}
+	if p.pkgRoot != nil {
+		p.recordPackage(state, event.PID, normalized)
+	}
+	if p.langRoot != nil {
+		p.recordLanguagePackage(state, event.PID, normalized)
+	}
+
 	if exists {
 		state.mu.Lock()
 		state.eventsDuplicate++
@@ -150,35 +570,223 @@ func (p *Processor) Process(event *Event) (uint64, string, ProcessResult) {
 		return event.CgroupID, normalized, ResultDuplicate
 	}
 
+	// Stat the new file through the container's root filesystem
+	if p.metadataRoot != nil {
+		if md, ok := statFile(p.metadataRoot(event.PID), normalized); ok {
+			state.seenMu.Lock()
+			if state.seen.contains(normalized) {
+				state.metadata[normalized] = md
+			}
+			state.seenMu.Unlock()
+		}
+	}
+
+	// Find the image layer providing the new file
+	if p.layerMountInfo != nil {
+		p.recordLayer(state, event.PID, normalized)
+	}
+
+	// Note whether the new file is on a volume
+	if p.volumeMountInfo != nil {
+		p.recordVolume(state, event.PID, normalized)
+	}
+
+	// Hash the new file's contents in the background
+	if p.hasher != nil {
+		p.hasher.enqueue(hashJob{state: state, root: p.hashRoot(event.PID), path: normalized})
+	}
+
 	state.mu.Lock()
 	state.eventsProcessed++
 	state.mu.Unlock()
+
+	p.mu.Lock()
+	p.lastNewFile = time.Now()
+	p.mu.Unlock()
 	return event.CgroupID, normalized, ResultNew
 }
 
+// Digests returns a snapshot of the recorded content digests, per container.
+// Returns nil if content hashing is not enabled.
+func (p *Processor) Digests() map[uint64]map[string]string {
+	if p.hasher == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64]map[string]string)
+	for cgroupID, state := range p.containers {
+		state.seenMu.RLock()
+		digests := make(map[string]string, len(state.digests))
+		for path, d := range state.digests {
+			digests[path] = d
+		}
+		state.seenMu.RUnlock()
+		result[cgroupID] = digests
+	}
+
+	return result
+}
+
+// HashesSkipped returns the number of files that were not hashed because the
+// hashing queue was full.
+func (p *Processor) HashesSkipped() uint64 {
+	if p.hasher == nil {
+		return 0
+	}
+	return p.hasher.skippedCount()
+}
+
+// Close stops background work started by the processor, waiting for
+// in-flight hashing and package database loads to complete.
+func (p *Processor) Close() {
+	if p.hasher != nil {
+		p.hasher.close()
+	}
+	p.pkgWG.Wait()
+	p.layerWG.Wait()
+}
+
+// Metadata returns a snapshot of the recorded file metadata, per container.
+// Returns nil if metadata enrichment is not enabled.
+func (p *Processor) Metadata() map[uint64]map[string]FileMetadata {
+	if p.metadataRoot == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64]map[string]FileMetadata)
+	for cgroupID, state := range p.containers {
+		state.seenMu.RLock()
+		md := make(map[string]FileMetadata, len(state.metadata))
+		for path, m := range state.metadata {
+			md[path] = m
+		}
+		state.seenMu.RUnlock()
+		result[cgroupID] = md
+	}
+
+	return result
+}
+
 // Files returns a snapshot of all unique files seen so far, per container.
 // Returns a map of cgroup_id -> sorted file list.
 func (p *Processor) Files() map[uint64][]string {
+	files, _ := p.TopFiles(0)
+	return files
+}
+
+// TopFiles is like Files but returns at most limit files per container,
+// keeping the most frequently accessed ones (ties broken by path). The second
+// result maps cgroup_id -> number of files omitted, for containers that were
+// truncated. A limit of 0 or less returns every file.
+//
+// Each container's list is only rebuilt when its files have changed since
+// the last call, so the lists are shared between calls and must not be
+// modified.
+func (p *Processor) TopFiles(limit int) (map[uint64][]string, map[uint64]int) {
 	p.containersMu.RLock()
 	defer p.containersMu.RUnlock()
 
 	result := make(map[uint64][]string)
+	truncated := make(map[uint64]int)
 	for cgroupID, state := range p.containers {
-		state.seenMu.RLock()
-		files := state.seen.keys()
-		sort.Strings(files)
-		state.seenMu.RUnlock()
+		files, omitted := state.topFiles(limit)
 		result[cgroupID] = files
+		if omitted > 0 {
+			truncated[cgroupID] = omitted
+		}
+	}
+
+	return result, truncated
+}
+
+// fileList is a container's sorted file list as of its cache's changes and
+// adds counters.
+type fileList struct {
+	valid         bool
+	limit         int
+	changes, adds uint64
+
+	files     []string
+	truncated int
+}
+
+// topFiles returns the container's file list for TopFiles, reusing the
+// cached list if it is still current: if no files were inserted or evicted
+// and, when the list is truncated to the most accessed files, none were
+// accessed again either.
+func (s *containerState) topFiles(limit int) ([]string, int) {
+	s.filesMu.Lock()
+	defer s.filesMu.Unlock()
+
+	s.seenMu.RLock()
+	cached := &s.files
+	if cached.valid && cached.limit == limit && cached.changes == s.seen.changes && (cached.truncated == 0 || cached.adds == s.seen.adds) {
+		s.seenMu.RUnlock()
+		return cached.files, cached.truncated
+	}
+	*cached = fileList{valid: true, limit: limit, changes: s.seen.changes, adds: s.seen.adds}
+
+	if limit <= 0 || s.seen.len() <= limit {
+		files := s.seen.keys()
+		s.seenMu.RUnlock()
+		sort.Strings(files)
+		cached.files = files
+		return files, 0
 	}
+	counts := s.seen.counts()
+	s.seenMu.RUnlock()
 
+	files := make([]string, 0, len(counts))
+	for path := range counts {
+		files = append(files, path)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if counts[files[i]] != counts[files[j]] {
+			return counts[files[i]] > counts[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	cached.truncated = len(files) - limit
+	files = files[:limit:limit]
+	sort.Strings(files)
+	cached.files = files
+	return files, cached.truncated
+}
+
+// DirectoryCounts returns, for each container, the number of files recorded
+// under each directory depth components deep, e.g. "/usr/lib" at depth 2.
+// Files less deep count toward themselves.
+func (p *Processor) DirectoryCounts(depth int) map[uint64]map[string]int {
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64]map[string]int, len(p.containers))
+	for cgroupID, state := range p.containers {
+		state.seenMu.RLock()
+		result[cgroupID] = state.seen.dirCounts(depth)
+		state.seenMu.RUnlock()
+	}
 	return result
 }
 
 // ContainerStats returns processing statistics for a specific container.
 type ContainerStats struct {
 	Name            string
+	ID              string
 	CgroupID        uint64
 	CgroupPath      string
+	ImageRef        string
+	ImageDigest     string
+	PodUID          string
+	PodName         string
+	PodNamespace    string
+	Ephemeral       bool
 	EventsReceived  uint64
 	EventsProcessed uint64
 	EventsExcluded  uint64
@@ -208,8 +816,15 @@ func (p *Processor) Stats() map[uint64]ContainerStats {
 
 		result[cgroupID] = ContainerStats{
 			Name:            state.info.Name,
+			ID:              state.info.ID,
 			CgroupID:        cgroupID,
 			CgroupPath:      state.info.CgroupPath,
+			ImageRef:        state.info.ImageRef,
+			ImageDigest:     state.info.ImageDigest,
+			PodUID:          state.info.PodUID,
+			PodName:         state.info.PodName,
+			PodNamespace:    state.info.PodNamespace,
+			Ephemeral:       state.info.Ephemeral,
 			EventsReceived:  received,
 			EventsProcessed: processed,
 			EventsExcluded:  excluded,
@@ -231,9 +846,15 @@ type AggregateStats struct {
 	EventsEvicted   uint64
 	UniqueFiles     int
 	UnknownEvents   uint64
+
+	// LastNewFile is when a file was last recorded for the first time, or
+	// zero if none has been.
+	LastNewFile time.Time
 }
 
-// Aggregate returns aggregated statistics across all containers.
+// Aggregate returns aggregated statistics across all containers. The event
+// counters include those of removed containers, so they never decrease
+// between calls; UniqueFiles counts only the containers still tracked.
 func (p *Processor) Aggregate() AggregateStats {
 	p.containersMu.RLock()
 	defer p.containersMu.RUnlock()
@@ -255,7 +876,13 @@ func (p *Processor) Aggregate() AggregateStats {
 	}
 
 	p.mu.Lock()
+	stats.EventsReceived += p.retired.EventsReceived
+	stats.EventsProcessed += p.retired.EventsProcessed
+	stats.EventsExcluded += p.retired.EventsExcluded
+	stats.EventsDuplicate += p.retired.EventsDuplicate
+	stats.EventsEvicted += p.retired.EventsEvicted
 	stats.UnknownEvents = p.unknownEvents
+	stats.LastNewFile = p.lastNewFile
 	p.mu.Unlock()
 
 	return stats
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+
+	"github.com/imjasonh/snoop/pkg/cgroup"
+	"github.com/imjasonh/snoop/pkg/notify"
+	"github.com/imjasonh/snoop/pkg/policy"
+)
+
+// RuleAlertEvent describes an event that matched an alerting rule.
+type RuleAlertEvent struct {
+	CgroupID  uint64
+	Rule      string
+	Path      string
+	PID       uint32
+	Process   string // the process's executable, or "" if it had exited
+	Operation string
+}
+
+// RuleHits counts the events a rule matched.
+type RuleHits struct {
+	Rule   string
+	Action policy.Action
+	Hits   uint64
+}
+
+// rule is a policy rule with its hit counter.
+type rule struct {
+	policy.Rule
+	hits atomic.Uint64
+}
+
+// ruleAlertKey identifies an alert, which is reported once per container.
+type ruleAlertKey struct {
+	rule, path, process string
+}
+
+// procExe returns the executable of a process, as a path in its own mount
+// namespace, or "" if it has exited.
+func procExe(pid uint32) string {
+	exe, err := os.Readlink(filepath.Join(cgroup.Proc.Dir(pid), "exe"))
+	if err != nil {
+		return ""
+	}
+	return exe
+}
+
+// checkRules counts the event against the first rule it matches, and
+// reports it if that rule alerts and hasn't reported the same path and
+// process in the container before.
+func (p *Processor) checkRules(state *containerState, event *Event, normalized string) {
+	op := policy.Operation(event.SyscallNr)
+	exe, resolved := "", false
+	for _, r := range p.rules {
+		if !notify.MatchesAny(normalized, r.Paths) {
+			continue
+		}
+		if len(r.Operations) > 0 && !slices.Contains(r.Operations, op) {
+			continue
+		}
+		if len(r.Processes) > 0 {
+			// Only resolved when needed, since it reads /proc
+			if !resolved {
+				exe, resolved = p.ruleExe(event.PID), true
+			}
+			if !notify.MatchesAny(exe, r.Processes) {
+				continue
+			}
+		}
+		r.hits.Add(1)
+		if r.Action != policy.Alert || p.ruleAlert == nil {
+			return
+		}
+		if !resolved {
+			exe = p.ruleExe(event.PID)
+		}
+		key := ruleAlertKey{rule: r.Name, path: normalized, process: exe}
+		state.mu.Lock()
+		_, alerted := state.ruleAlerts[key]
+		if !alerted {
+			if state.ruleAlerts == nil {
+				state.ruleAlerts = make(map[ruleAlertKey]struct{})
+			}
+			state.ruleAlerts[key] = struct{}{}
+		}
+		state.mu.Unlock()
+		if !alerted {
+			p.ruleAlert(RuleAlertEvent{
+				CgroupID:  event.CgroupID,
+				Rule:      r.Name,
+				Path:      normalized,
+				PID:       event.PID,
+				Process:   exe,
+				Operation: op,
+			})
+		}
+		return
+	}
+}
+
+// RuleHits returns how many events each rule has matched, in rule order.
+func (p *Processor) RuleHits() []RuleHits {
+	hits := make([]RuleHits, len(p.rules))
+	for i, r := range p.rules {
+		hits[i] = RuleHits{Rule: r.Name, Action: r.Action, Hits: r.hits.Load()}
+	}
+	return hits
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/policy"
+	"golang.org/x/sys/unix"
+)
+
+func TestRules(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	var alerts []RuleAlertEvent
+	p := NewProcessor(ctx, containers, []string{"/proc/"}, 0, WithRules([]policy.Rule{{
+		Name:      "app-reads-secrets",
+		Paths:     []string{"/var/run/secrets/"},
+		Processes: []string{"/app/server"},
+		Action:    policy.Allow,
+	}, {
+		Name:   "secrets",
+		Paths:  []string{"/var/run/secrets/"},
+		Action: policy.Alert,
+	}, {
+		Name:       "shell",
+		Paths:      []string{"/bin/*sh"},
+		Operations: []string{"exec"},
+		Action:     policy.Alert,
+	}}, func(a RuleAlertEvent) { alerts = append(alerts, a) }))
+	exes := map[uint32]string{1: "/app/server", 2: "/bin/cat"}
+	p.ruleExe = func(pid uint32) string { return exes[pid] }
+
+	const token = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	for _, e := range []Event{
+		{PID: 1, SyscallNr: unix.SYS_OPENAT, Path: token},
+		{PID: 2, SyscallNr: unix.SYS_OPENAT, Path: token},
+		{PID: 2, SyscallNr: unix.SYS_OPENAT, Path: token}, // already alerted
+		{PID: 2, SyscallNr: unix.SYS_EXECVE, Path: "/bin/sh"},
+		{PID: 2, SyscallNr: unix.SYS_NEWFSTATAT, Path: "/bin/bash"}, // not an exec
+		{PID: 2, SyscallNr: unix.SYS_OPENAT, Path: "/etc/hosts"},
+	} {
+		e.CgroupID = 1000
+		p.Process(&e)
+	}
+
+	want := []RuleAlertEvent{
+		{CgroupID: 1000, Rule: "secrets", Path: token, PID: 2, Process: "/bin/cat", Operation: "open"},
+		{CgroupID: 1000, Rule: "shell", Path: "/bin/sh", PID: 2, Process: "/bin/cat", Operation: "exec"},
+	}
+	if len(alerts) != len(want) {
+		t.Fatalf("alerts = %+v, want %+v", alerts, want)
+	}
+	for i := range want {
+		if alerts[i] != want[i] {
+			t.Errorf("alert %d = %+v, want %+v", i, alerts[i], want[i])
+		}
+	}
+
+	wantHits := []RuleHits{
+		{Rule: "app-reads-secrets", Action: policy.Allow, Hits: 1},
+		{Rule: "secrets", Action: policy.Alert, Hits: 2},
+		{Rule: "shell", Action: policy.Alert, Hits: 1},
+	}
+	for i, got := range p.RuleHits() {
+		if got != wantHits[i] {
+			t.Errorf("RuleHits()[%d] = %+v, want %+v", i, got, wantHits[i])
+		}
+	}
+}
+
+func TestRulesSeeExcludedPaths(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	alerted := false
+	p := NewProcessor(ctx, containers, []string{"/proc/"}, 0, WithRules([]policy.Rule{{
+		Name:   "environ",
+		Paths:  []string{"/proc/*/environ"},
+		Action: policy.Alert,
+	}}, func(RuleAlertEvent) { alerted = true }))
+	p.ruleExe = func(uint32) string { return "" }
+
+	if _, _, result := p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/proc/1/environ"}); result != ResultExcluded {
+		t.Errorf("result = %s, want excluded", result)
+	}
+	if !alerted {
+		t.Error("excluded path didn't alert")
+	}
+}
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/cgroup"
+)
+
+// OpenFiles returns the files a process has open or mapped into memory,
+// from its /proc/<pid>/fd links and /proc/<pid>/maps, as paths in its own
+// mount namespace. Sockets, pipes, anonymous mappings, and deleted files are
+// skipped. It returns nil if the process has exited.
+func OpenFiles(pid uint32) []string {
+	return openFiles(cgroup.Proc, pid)
+}
+
+func openFiles(proc *cgroup.Procfs, pid uint32) []string {
+	dir := proc.Dir(pid)
+	seen := make(map[string]struct{})
+	add := func(path string) {
+		if strings.HasPrefix(path, "/") && !strings.HasSuffix(path, " (deleted)") {
+			seen[path] = struct{}{}
+		}
+	}
+
+	if entries, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		for _, e := range entries {
+			// Sockets and pipes link to e.g. "socket:[1234]"
+			if target, err := os.Readlink(filepath.Join(dir, "fd", e.Name())); err == nil {
+				add(target)
+			}
+		}
+	}
+
+	// Each line of maps is "address perms offset dev inode pathname", where
+	// pathname is empty for anonymous mappings and may contain spaces
+	if data, err := proc.ReadFile(pid, "maps"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.SplitN(scanner.Text(), " ", 6)
+			if len(fields) == 6 {
+				add(strings.TrimLeft(fields[5], " "))
+			}
+		}
+	}
+
+	files := make([]string, 0, len(seen))
+	for path := range seen {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Snapshot records the files that the container's processes, pids, already
+// have open or mapped, such as those opened before the container was
+// traced, as if each had been accessed once. Files not recorded before are
+// flagged as pre-existing. It returns how many were.
+func (p *Processor) Snapshot(cgroupID uint64, pids []uint32) int {
+	p.containersMu.RLock()
+	state, ok := p.containers[cgroupID]
+	p.containersMu.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	added := 0
+	for _, pid := range pids {
+		for _, path := range OpenFiles(pid) {
+			_, normalized, result := p.Process(&Event{CgroupID: cgroupID, PID: pid, Path: path})
+			if result != ResultNew {
+				continue
+			}
+			state.seenMu.Lock()
+			if state.seen.contains(normalized) {
+				if state.preexisting == nil {
+					state.preexisting = make(map[string]struct{})
+				}
+				state.preexisting[normalized] = struct{}{}
+				added++
+			}
+			state.seenMu.Unlock()
+		}
+	}
+	return added
+}
+
+// Preexisting returns, per container, the sorted files first recorded by
+// Snapshot rather than from an event, for containers that have any.
+func (p *Processor) Preexisting() map[uint64][]string {
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]string)
+	for cgroupID, state := range p.containers {
+		state.seenMu.RLock()
+		var files []string
+		for path := range state.preexisting {
+			files = append(files, path)
+		}
+		state.seenMu.RUnlock()
+		if len(files) > 0 {
+			sort.Strings(files)
+			result[cgroupID] = files
+		}
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/cgroup"
+)
+
+func TestOpenFiles(t *testing.T) {
+	proc := t.TempDir()
+	fd := filepath.Join(proc, "42", "fd")
+	if err := os.MkdirAll(fd, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"0": "/dev/null",
+		"3": "/etc/app/config.yaml",
+		"4": "socket:[1234]",
+		"5": "pipe:[5678]",
+		"6": "/tmp/scratch (deleted)",
+	} {
+		if err := os.Symlink(target, filepath.Join(fd, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	maps := `55d4c6a00000-55d4c6a28000 r--p 00000000 08:01 1234 /usr/bin/app
+7f1c2a000000-7f1c2a021000 rw-p 00000000 00:00 0
+7f1c2b000000-7f1c2b1d5000 r-xp 00000000 08:01 5678                       /usr/lib/libc.so.6
+7f1c2c000000-7f1c2c001000 r--p 00000000 08:01 9012                       /opt/app/My Data.db
+7ffd1e000000-7ffd1e021000 rw-p 00000000 00:00 0                          [stack]
+`
+	if err := os.WriteFile(filepath.Join(proc, "42", "maps"), []byte(maps), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/dev/null", "/etc/app/config.yaml", "/opt/app/My Data.db", "/usr/bin/app", "/usr/lib/libc.so.6"}
+	if got := openFiles(&cgroup.Procfs{Mount: proc, Attempts: 1}, 42); !slices.Equal(got, want) {
+		t.Errorf("openFiles = %v, want %v", got, want)
+	}
+	if got := openFiles(&cgroup.Procfs{Mount: proc, Attempts: 1}, 43); len(got) != 0 {
+		t.Errorf("openFiles of an exited process = %v, want none", got)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	// The test process has this file open
+	path := filepath.Join(t.TempDir(), "open.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, []string{}, 0)
+
+	// A file already recorded from an event isn't pre-existing
+	exe, err := os.Readlink("/proc/self/exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: exe})
+
+	pid := uint32(os.Getpid())
+	if n := p.Snapshot(1000, []uint32{pid}); n == 0 {
+		t.Fatal("Snapshot recorded no files")
+	}
+	preexisting := p.Preexisting()[1000]
+	if !slices.Contains(preexisting, path) {
+		t.Errorf("pre-existing files = %v, want %s", preexisting, path)
+	}
+	if slices.Contains(preexisting, exe) {
+		t.Errorf("pre-existing files = %v, want no %s", preexisting, exe)
+	}
+	if !slices.Contains(p.Files()[1000], path) {
+		t.Errorf("files = %v, want %s", p.Files()[1000], path)
+	}
+
+	// Snapshotting again, or an untracked container, records nothing new
+	if n := p.Snapshot(1000, []uint32{pid}); n != 0 {
+		t.Errorf("second Snapshot recorded %d files, want 0", n)
+	}
+	if n := p.Snapshot(2000, []uint32{pid}); n != 0 {
+		t.Errorf("Snapshot of an untracked container recorded %d files, want 0", n)
+	}
+}
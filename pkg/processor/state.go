@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"sort"
+	"time"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+// ContainerState is a snapshot of what the processor holds for a container,
+// for inspecting a running processor.
+type ContainerState struct {
+	CgroupID     uint64 `json:"cgroup_id"`
+	CgroupPath   string `json:"cgroup_path,omitempty"`
+	Name         string `json:"name"`
+	ID           string `json:"id,omitempty"`
+	ImageRef     string `json:"image_ref,omitempty"`
+	PodName      string `json:"pod_name,omitempty"`
+	PodNamespace string `json:"pod_namespace,omitempty"`
+
+	EventsReceived  uint64 `json:"events_received"`
+	EventsProcessed uint64 `json:"events_processed"`
+	EventsExcluded  uint64 `json:"events_excluded"`
+	EventsDuplicate uint64 `json:"events_duplicate"`
+
+	// Cache describes the container's deduplication cache, and Files maps
+	// each file in it to its access count.
+	Cache CacheState        `json:"cache"`
+	Files map[string]uint64 `json:"files"`
+
+	// Exclude lists the container's own excluded path prefixes.
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Packages is nil unless package attribution is enabled.
+	Packages *PackageState `json:"packages,omitempty"`
+}
+
+// CacheState describes a deduplication cache.
+type CacheState struct {
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"` // 0 if unbounded
+	Evictions uint64 `json:"evictions"`
+
+	// Bytes approximates the memory the cache takes, and MaxBytes limits
+	// it (0 if unbounded).
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// PackageState describes a container's package database.
+type PackageState struct {
+	Loaded      bool      `json:"loaded"`
+	Loading     bool      `json:"loading"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt,omitzero"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+
+	// Packages is how many packages the database holds, Accessed how many
+	// of them had files accessed, and OrphanFiles how many accessed files
+	// no package owns.
+	Packages    int `json:"packages"`
+	Accessed    int `json:"accessed"`
+	OrphanFiles int `json:"orphan_files"`
+
+	// VerifiedFiles and ModifiedFiles count the package files whose
+	// checksums were checked, and those that didn't match.
+	VerifiedFiles int `json:"verified_files"`
+	ModifiedFiles int `json:"modified_files"`
+}
+
+// State returns a snapshot of every tracked container's state, sorted by
+// cgroup ID. Files lists only the files under prefix, if it is set. It copies
+// each container's file list, so it is meant for occasional debugging rather
+// than regular reporting.
+func (p *Processor) State(prefix string) []ContainerState {
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make([]ContainerState, 0, len(p.containers))
+	for cgroupID, state := range p.containers {
+		s := ContainerState{
+			CgroupID:     cgroupID,
+			CgroupPath:   state.info.CgroupPath,
+			Name:         state.info.Name,
+			ID:           state.info.ID,
+			ImageRef:     state.info.ImageRef,
+			PodName:      state.info.PodName,
+			PodNamespace: state.info.PodNamespace,
+			Exclude:      state.overrides.Exclude,
+		}
+
+		state.mu.Lock()
+		s.EventsReceived = state.eventsReceived
+		s.EventsProcessed = state.eventsProcessed
+		s.EventsExcluded = state.eventsExcluded
+		s.EventsDuplicate = state.eventsDuplicate
+		state.mu.Unlock()
+
+		state.seenMu.RLock()
+		if prefix != "" {
+			s.Files = state.seen.countsUnder(prefix)
+		} else {
+			s.Files = state.seen.counts()
+		}
+		s.Cache = CacheState{
+			Size:      state.seen.len(),
+			Capacity:  state.maxUniqueFiles(p.maxUniqueFiles),
+			Evictions: state.seen.evictions(),
+			Bytes:     state.seen.memory(),
+			MaxBytes:  state.maxPathBytes(p.maxPathBytes),
+		}
+		state.seenMu.RUnlock()
+
+		if p.pkgRoot != nil {
+			s.Packages = p.packageState(state)
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CgroupID < result[j].CgroupID })
+	return result
+}
+
+// packageState describes a container's package database.
+func (p *Processor) packageState(state *containerState) *PackageState {
+	ps := &state.packages
+	ps.mu.Lock()
+	s := &PackageState{
+		Loaded:        ps.mapper != nil,
+		Loading:       ps.loading,
+		Attempts:      ps.attempts,
+		NextAttempt:   ps.next,
+		Fingerprint:   ps.fingerprint,
+		VerifiedFiles: len(ps.verified),
+		ModifiedFiles: len(ps.modified),
+	}
+	mapper := ps.mapper
+	ps.mu.Unlock()
+
+	if mapper == nil {
+		return s
+	}
+	for _, stats := range mapper.Stats() {
+		if stats.Name == packages.OrphanName && stats.Manager == packages.OrphanManager {
+			s.OrphanFiles = stats.TotalFiles
+			continue
+		}
+		s.Packages++
+		if stats.AccessedFiles > 0 {
+			s.Accessed++
+		}
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+func TestState(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		2000: {CgroupID: 2000, Name: "worker"},
+		1000: {CgroupID: 1000, Name: "app", PodName: "web-1"},
+	}
+	p := NewProcessor(ctx, containers, nil, 2, WithContainerOverrides(func(info *ContainerInfo) ContainerOverrides {
+		if info.Name == "worker" {
+			return ContainerOverrides{Exclude: []string{"/cache/"}, MaxUniqueFiles: 10}
+		}
+		return ContainerOverrides{}
+	}))
+
+	for _, path := range []string{"/a", "/a", "/b", "/c"} {
+		p.Process(&Event{CgroupID: 1000, PID: 100, Path: path})
+	}
+	p.Process(&Event{CgroupID: 2000, PID: 200, Path: "/cache/x"})
+
+	state := p.State("")
+	if len(state) != 2 || state[0].CgroupID != 1000 || state[1].CgroupID != 2000 {
+		t.Fatalf("State() = %+v, want containers 1000 and 2000", state)
+	}
+	app := state[0]
+	if app.Name != "app" || app.PodName != "web-1" || app.EventsReceived != 4 || app.EventsDuplicate != 1 {
+		t.Errorf("app state = %+v", app)
+	}
+	// "", "b", and "c"
+	if want := (CacheState{Size: 2, Capacity: 2, Evictions: 1, Bytes: 3*nodeBytes + 2}); app.Cache != want {
+		t.Errorf("app cache = %+v, want %+v", app.Cache, want)
+	}
+	if len(app.Files) != 2 || app.Files["/b"] != 1 || app.Files["/c"] != 1 {
+		t.Errorf("app files = %v, want /b and /c", app.Files)
+	}
+	if app.Packages != nil {
+		t.Errorf("app packages = %+v without package attribution", app.Packages)
+	}
+	worker := state[1]
+	if worker.Cache.Capacity != 10 || worker.EventsExcluded != 1 || len(worker.Exclude) != 1 {
+		t.Errorf("worker state = %+v, want its overrides", worker)
+	}
+
+	if files := p.State("/b")[0].Files; len(files) != 1 || files["/b"] != 1 {
+		t.Errorf("app files under /b = %v, want /b", files)
+	}
+
+	// The snapshot is meant to be served as JSON
+	if _, err := json.Marshal(state); err != nil {
+		t.Errorf("json.Marshal(State()) = %v", err)
+	}
+}
+
+func TestStatePackages(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	loader := func(string) ([]*packages.Package, error) {
+		return []*packages.Package{
+			{Name: "curl", Version: "8.5.0", Manager: "test", Files: []string{"/usr/bin/curl"}},
+			{Name: "unused", Version: "1.0", Manager: "test", Files: []string{"/usr/bin/unused"}},
+		}, nil
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(func(uint32) string { return "/" }, loader))
+
+	if got := p.State("")[0].Packages; got == nil || got.Loaded {
+		t.Errorf("packages before loading = %+v, want not loaded", got)
+	}
+	p.Process(&Event{CgroupID: 1000, PID: 42, Path: "/usr/bin/curl"})
+	p.Process(&Event{CgroupID: 1000, PID: 42, Path: "/etc/hosts"})
+	p.Close()
+
+	got := p.State("")[0].Packages
+	want := PackageState{Loaded: true, Attempts: 1, Packages: 2, Accessed: 1, OrphanFiles: 1}
+	if got == nil {
+		t.Fatal("packages = nil with package attribution")
+	}
+	got.NextAttempt = want.NextAttempt
+	if *got != want {
+		t.Errorf("packages = %+v, want %+v", *got, want)
+	}
+}
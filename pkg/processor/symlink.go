@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks bounds symlink resolution, matching the kernel's ELOOP limit.
+const maxSymlinks = 40
+
+// errTooManyLinks is returned when resolution exceeds maxSymlinks.
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// resolveInRoot resolves every symlink in the absolute path p as seen from
+// inside root, returning the resolved path relative to root. Absolute link
+// targets are interpreted relative to root rather than the host, since
+// following them through /proc/<pid>/root would escape the container.
+// Components that don't exist are kept as is.
+func resolveInRoot(root, p string) (string, error) {
+	if _, err := os.Stat(root); err != nil {
+		return "", err
+	}
+	remaining := strings.Split(strings.TrimPrefix(path.Clean("/"+p), "/"), "/")
+	resolved := "/"
+	links := 0
+	for len(remaining) > 0 {
+		name := remaining[0]
+		remaining = remaining[1:]
+		if name == "" || name == "." {
+			continue
+		}
+		if name == ".." {
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, name)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if errors.Is(err, os.ErrNotExist) {
+			resolved = path.Join(append([]string{next}, remaining...)...)
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", errTooManyLinks
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+// buildRoot creates files and symlinks under a temp dir. Entries with a
+// "->" value are symlinks.
+func buildRoot(t *testing.T, entries map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, value := range entries {
+		full := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if target, ok := strings.CutPrefix(value, "-> "); ok {
+			if err := os.Symlink(target, full); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(full, []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestResolveInRoot(t *testing.T) {
+	root := buildRoot(t, map[string]string{
+		"bin/busybox":   "busybox",
+		"bin/ls":        "-> /bin/busybox",
+		"bin/sh":        "-> busybox",
+		"usr/bin/env":   "-> ../../bin/busybox",
+		"lib64":         "-> /lib",
+		"lib/libc.so":   "libc",
+		"loop/a":        "-> /loop/b",
+		"loop/b":        "-> /loop/a",
+		"escape/passwd": "-> ../../../../etc/passwd",
+	})
+
+	for _, tt := range []struct {
+		path, want string
+		wantErr    bool
+	}{
+		{path: "/bin/ls", want: "/bin/busybox"},
+		{path: "/bin/sh", want: "/bin/busybox"},
+		{path: "/usr/bin/env", want: "/bin/busybox"},
+		// Absolute symlinks in directories stay inside root
+		{path: "/lib64/libc.so", want: "/lib/libc.so"},
+		{path: "/bin/busybox", want: "/bin/busybox"},
+		{path: "/missing/file", want: "/missing/file"},
+		{path: "/escape/passwd", want: "/etc/passwd"},
+		{path: "/loop/a", wantErr: true},
+	} {
+		got, err := resolveInRoot(root, tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveInRoot(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveInRoot(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPackageAttributionSymlinks(t *testing.T) {
+	ctx := context.Background()
+	root := buildRoot(t, map[string]string{
+		"bin/busybox": "busybox",
+		"bin/ls":      "-> /bin/busybox",
+		"bin/cat":     "-> busybox",
+		"etc/hosts":   "hosts",
+	})
+	loader := func(string) ([]*packages.Package, error) {
+		return []*packages.Package{
+			{Name: "busybox", Manager: "apk", Files: []string{"/bin/busybox"}},
+		}, nil
+	}
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(func(uint32) string { return root }, loader))
+
+	// Before the database loads; replayed with symlink resolution
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/bin/ls"})
+	p.pkgWG.Wait()
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/bin/cat"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/bin/cat"})
+	p.Process(&Event{CgroupID: 1000, PID: 1, Path: "/etc/hosts"})
+	p.Close()
+
+	stats := p.Packages()[1000]
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v, want busybox and orphans", stats)
+	}
+	if stats[0].AccessedFiles != 1 || stats[0].AccessCount != 3 {
+		t.Errorf("busybox stats = %+v, want 1 file accessed 3 times", stats[0])
+	}
+	if stats[1].Name != packages.OrphanName || stats[1].AccessCount != 1 || len(stats[1].SampleFiles) != 1 || stats[1].SampleFiles[0] != "/etc/hosts" {
+		t.Errorf("orphan stats = %+v, want /etc/hosts accessed once", stats[1])
+	}
+}
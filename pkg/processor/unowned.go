@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"sort"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+// recordPackageExec records that the container executed path, and flags it
+// if the container's package database is loaded and no package owns it.
+// Files dropped into e.g. /tmp and executed are a classic sign of compromise.
+func (p *Processor) recordPackageExec(state *containerState, pid uint32, path string) {
+	ps := &state.packages
+	ps.mu.Lock()
+	_, done := ps.execs[path]
+	ps.mu.Unlock()
+	if done {
+		return
+	}
+
+	// Resolved while the process is running, so that e.g. busybox applets
+	// count as owned by the package owning their target
+	target := p.symlinkTarget(state, pid, path)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.execs == nil {
+		ps.execs = make(map[string]string)
+	}
+	ps.execs[path] = target
+	if ps.mapper != nil {
+		p.checkExecs(state, ps.mapper.Database())
+	}
+}
+
+// checkExecs flags the executed paths that no package in db owns, replacing
+// the previous flags. ps.mu must be held.
+func (p *Processor) checkExecs(state *containerState, db *packages.Database) {
+	ps := &state.packages
+	unowned := make(map[string]bool)
+	for path, target := range ps.execs {
+		if db.Lookup(path) != nil || (target != "" && db.Lookup(target) != nil) {
+			continue
+		}
+		unowned[path] = true
+		if !ps.unownedExecs[path] {
+			clog.FromContext(p.ctx).Warnf("Container %s executed %s, which no installed package owns", state.info.Name, path)
+		}
+	}
+	ps.unownedExecs = unowned
+}
+
+// UnownedExecutables returns, per container, the sorted paths the container
+// executed that no installed package owns, for containers that have any.
+// Only containers whose package database has been loaded are checked.
+// Returns nil if package attribution is not enabled.
+func (p *Processor) UnownedExecutables() map[uint64][]string {
+	if p.pkgRoot == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]string)
+	for cgroupID, state := range p.containers {
+		state.packages.mu.Lock()
+		paths := make([]string, 0, len(state.packages.unownedExecs))
+		for path := range state.packages.unownedExecs {
+			paths = append(paths, path)
+		}
+		state.packages.mu.Unlock()
+		if len(paths) > 0 {
+			sort.Strings(paths)
+			result[cgroupID] = paths
+		}
+	}
+	return result
+}
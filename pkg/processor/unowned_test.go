@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+	"golang.org/x/sys/unix"
+)
+
+func TestUnownedExecutables(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/bin/busybox", filepath.Join(root, "bin", "ls")); err != nil {
+		t.Fatal(err)
+	}
+	loader := func(string) ([]*packages.Package, error) {
+		return []*packages.Package{
+			{Name: "busybox", Version: "1.36", Manager: "test", Files: []string{"/bin/busybox"}},
+			{Name: "curl", Version: "8.5.0", Manager: "test", Files: []string{"/usr/bin/curl"}},
+		}, nil
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(func(uint32) string { return root }, loader))
+
+	exec := func(path string) {
+		p.Process(&Event{CgroupID: 1000, PID: 42, SyscallNr: unix.SYS_EXECVE, Path: path})
+	}
+	// Executed before the database finishes loading, and checked once it does
+	exec("/tmp/x")
+	p.pkgWG.Wait()
+	exec("/bin/curl") // owned through /usr merging
+	exec("/bin/ls")   // owned through its symlink target
+	exec("/dev/shm/.hidden")
+	p.Process(&Event{CgroupID: 1000, PID: 42, SyscallNr: unix.SYS_OPENAT, Path: "/etc/hosts"}) // not executed
+	p.Close()
+
+	want := map[uint64][]string{1000: {"/dev/shm/.hidden", "/tmp/x"}}
+	if got := p.UnownedExecutables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnownedExecutables() = %v, want %v", got, want)
+	}
+}
+
+func TestUnownedExecutablesNoDatabase(t *testing.T) {
+	ctx := context.Background()
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	loader := func(string) ([]*packages.Package, error) { return nil, os.ErrNotExist }
+	p := NewProcessor(ctx, containers, nil, 0, WithPackageAttribution(nil, loader), WithPackageLoadRetry(1, 0))
+
+	p.Process(&Event{CgroupID: 1000, PID: 42, SyscallNr: unix.SYS_EXECVE, Path: "/tmp/x"})
+	p.Close()
+
+	// Without a database there is nothing to check against
+	if got := p.UnownedExecutables(); len(got) != 0 {
+		t.Errorf("UnownedExecutables() = %v without a package database", got)
+	}
+	if got := NewProcessor(ctx, containers, nil, 0).UnownedExecutables(); got != nil {
+		t.Errorf("UnownedExecutables() = %v without package attribution", got)
+	}
+}
@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ModifiedFile is an accessed package-owned file whose contents don't match
+// the checksum its package manager recorded, a sign of tampering or drift.
+type ModifiedFile struct {
+	Path     string
+	Package  string
+	Expected string // Recorded digest, "<algorithm>:<hex>"
+	Actual   string // Digest of the file on disk, same algorithm
+}
+
+// VerifyPackageFiles checks accessed package-owned files that haven't been
+// checked yet against their recorded checksums, and returns every modified
+// file found so far per container, sorted by path. Each file is read once
+// per database load; files that can't be read are retried on the next call.
+// Returns nil if package verification is not enabled.
+func (p *Processor) VerifyPackageFiles() map[uint64][]ModifiedFile {
+	if !p.pkgVerify || p.pkgRoot == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]ModifiedFile)
+	for cgroupID, state := range p.containers {
+		ps := &state.packages
+		ps.mu.Lock()
+		mapper, pid, verified, modified := ps.mapper, ps.pid, ps.verified, ps.modified
+		ps.mu.Unlock()
+		if mapper == nil {
+			continue
+		}
+
+		state.seenMu.RLock()
+		paths := state.seen.keys()
+		state.seenMu.RUnlock()
+
+		root := p.packageRoot(state, pid)
+		db := mapper.Database()
+		for _, path := range paths {
+			ps.mu.Lock()
+			done := verified[path]
+			ps.mu.Unlock()
+			if done {
+				continue
+			}
+
+			pkg, owned := db.Owner(path)
+			expected := ""
+			if pkg != nil {
+				expected = pkg.Checksums[owned]
+			}
+			var actual string
+			if expected != "" {
+				var ok bool
+				actual, ok = digestFile(filepath.Join(root, path), expected)
+				if !ok {
+					continue
+				}
+			}
+
+			ps.mu.Lock()
+			verified[path] = true
+			if actual != "" && actual != expected {
+				modified[path] = ModifiedFile{Path: path, Package: pkg.Name, Expected: expected, Actual: actual}
+			}
+			ps.mu.Unlock()
+		}
+
+		ps.mu.Lock()
+		files := make([]ModifiedFile, 0, len(modified))
+		for _, m := range modified {
+			files = append(files, m)
+		}
+		ps.mu.Unlock()
+		if len(files) > 0 {
+			sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+			result[cgroupID] = files
+		}
+	}
+	return result
+}
+
+// digestFile hashes a regular file with the algorithm of expected, a
+// "<algorithm>:<hex>" digest. Symlinks and other non-regular files report
+// "" (nothing to compare), as do unsupported algorithms. Returns false if
+// the file can't be read.
+func digestFile(path, expected string) (string, bool) {
+	algo, _, _ := strings.Cut(expected, ":")
+	var h hash.Hash
+	switch algo {
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return "", true
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", false
+	}
+	if !info.Mode().IsRegular() {
+		return "", true
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", false
+	}
+	return algo + ":" + hex.EncodeToString(h.Sum(nil)), true
+}
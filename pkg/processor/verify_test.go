@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+func sha1Digest(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return "sha1:" + hex.EncodeToString(sum[:])
+}
+
+func TestVerifyPackageFiles(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"usr/bin/curl":        "curl binary",
+		"etc/ssl/openssl.cnf": "tampered config",
+		"usr/bin/unchecked":   "no checksum",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loader := func(string) ([]*packages.Package, error) {
+		return []*packages.Package{
+			{
+				Name:  "curl",
+				Files: []string{"/usr/bin/curl", "/usr/bin/unchecked"},
+				Checksums: map[string]string{
+					"/usr/bin/curl": sha1Digest("curl binary"),
+				},
+			},
+			{
+				Name:      "libssl3",
+				Files:     []string{"/etc/ssl/openssl.cnf", "/usr/lib/libssl.so.3"},
+				Checksums: map[string]string{"/etc/ssl/openssl.cnf": sha1Digest("original config"), "/usr/lib/libssl.so.3": sha1Digest("missing")},
+			},
+		}, nil
+	}
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0,
+		WithPackageAttribution(func(uint32) string { return root }, loader),
+		WithPackageVerification())
+
+	for _, path := range []string{"/usr/bin/curl", "/etc/ssl/openssl.cnf", "/usr/bin/unchecked", "/usr/lib/libssl.so.3"} {
+		p.Process(&Event{CgroupID: 1000, PID: 1, Path: path})
+	}
+	p.Close()
+
+	modified := p.VerifyPackageFiles()[1000]
+	if len(modified) != 1 {
+		t.Fatalf("modified = %+v, want only openssl.cnf", modified)
+	}
+	want := ModifiedFile{
+		Path:     "/etc/ssl/openssl.cnf",
+		Package:  "libssl3",
+		Expected: sha1Digest("original config"),
+		Actual:   sha1Digest("tampered config"),
+	}
+	if modified[0] != want {
+		t.Errorf("modified[0] = %+v, want %+v", modified[0], want)
+	}
+
+	// Results persist across calls without rereading files
+	if got := p.VerifyPackageFiles()[1000]; len(got) != 1 {
+		t.Errorf("second call modified = %+v, want 1 entry", got)
+	}
+}
+
+func TestVerifyPackageFilesDisabled(t *testing.T) {
+	ctx := context.Background()
+	p := NewProcessor(ctx, map[uint64]*ContainerInfo{1000: {CgroupID: 1000}}, nil, 0)
+	if got := p.VerifyPackageFiles(); got != nil {
+		t.Errorf("VerifyPackageFiles() = %v, want nil when disabled", got)
+	}
+}
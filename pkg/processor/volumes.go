@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/imjasonh/snoop/pkg/mounts"
+)
+
+// VolumeFile is an accessed file on a volume rather than the container's
+// image.
+type VolumeFile struct {
+	Path string
+
+	// Volume is the kind of volume, as returned by mounts.Classify, and
+	// MountPoint where the volume is mounted in the container.
+	Volume     string
+	MountPoint string
+}
+
+// mountState tracks a container's mount table for volume classification.
+type mountState struct {
+	mu     sync.Mutex
+	loaded bool
+	table  mounts.Table // nil if the table couldn't be read
+}
+
+// containerMounts returns the container's mount table, reading it from
+// pid's mountinfo on first use. Returns nil if it isn't available.
+func (p *Processor) containerMounts(state *containerState, pid uint32) mounts.Table {
+	ms := &state.mounts
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if !ms.loaded {
+		ms.loaded = true
+		table, err := mounts.Load(p.volumeMountInfo(pid))
+		if err != nil {
+			clog.FromContext(p.ctx).Infof("Volume classification unavailable for container %s: %v", state.info.Name, err)
+			return nil
+		}
+		ms.table = table
+	}
+	return ms.table
+}
+
+// recordVolume records a newly seen path if it's on a volume.
+func (p *Processor) recordVolume(state *containerState, pid uint32, path string) {
+	m := p.containerMounts(state, pid).Find(path)
+	if m == nil {
+		return
+	}
+	kind := mounts.Classify(m)
+	if kind == "" {
+		return
+	}
+	state.seenMu.Lock()
+	if state.seen.contains(path) {
+		state.volumeFiles[path] = VolumeFile{Path: path, Volume: kind, MountPoint: m.Point}
+	}
+	state.seenMu.Unlock()
+}
+
+// VolumeFiles returns, per container, the accessed files on volumes sorted
+// by path, for containers that have any. Returns nil
+// if volume classification is not enabled.
+func (p *Processor) VolumeFiles() map[uint64][]VolumeFile {
+	if p.volumeMountInfo == nil {
+		return nil
+	}
+
+	p.containersMu.RLock()
+	defer p.containersMu.RUnlock()
+
+	result := make(map[uint64][]VolumeFile)
+	for cgroupID, state := range p.containers {
+		state.seenMu.RLock()
+		files := make([]VolumeFile, 0, len(state.volumeFiles))
+		for _, f := range state.volumeFiles {
+			files = append(files, f)
+		}
+		state.seenMu.RUnlock()
+		if len(files) > 0 {
+			sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+			result[cgroupID] = files
+		}
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/mounts"
+)
+
+func TestVolumeFiles(t *testing.T) {
+	ctx := context.Background()
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	if err := os.WriteFile(mountinfo, []byte(`1 0 0:50 / / rw - overlay overlay rw,lowerdir=/layers/1
+2 1 0:51 / /var/run/secrets/kubernetes.io/serviceaccount ro - tmpfs tmpfs rw
+3 1 8:1 /var/lib/kubelet/pods/0f1e/volumes/kubernetes.io~empty-dir/cache /cache rw - ext4 /dev/sda1 rw
+4 1 8:1 /var/lib/kubelet/pods/0f1e/etc-hosts /etc/hosts rw - ext4 /dev/sda1 rw
+5 1 8:1 /var/lib/kubelet/pods/0f1e/volumes/kubernetes.io~configmap/cfg /etc/app ro - ext4 /dev/sda1 rw
+6 1 0:52 / /proc rw - proc proc rw
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	containers := map[uint64]*ContainerInfo{
+		1000: {CgroupID: 1000, Name: "app"},
+	}
+	p := NewProcessor(ctx, containers, nil, 0, WithVolumeClassification(func(uint32) string { return mountinfo }))
+
+	const token = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	for _, path := range []string{token, "/cache/index", "/etc/hosts", "/bin/sh", "/cache/index", "/etc/app/app.yaml"} {
+		p.Process(&Event{CgroupID: 1000, PID: 1, Path: path})
+	}
+	p.Close()
+
+	want := map[uint64][]VolumeFile{1000: {
+		{Path: "/cache/index", Volume: mounts.EmptyDir, MountPoint: "/cache"},
+		{Path: "/etc/app/app.yaml", Volume: mounts.ConfigMap, MountPoint: "/etc/app"},
+		{Path: "/etc/hosts", Volume: mounts.Runtime, MountPoint: "/etc/hosts"},
+		{Path: token, Volume: mounts.Tmpfs, MountPoint: "/var/run/secrets/kubernetes.io/serviceaccount"},
+	}}
+	if got := p.VolumeFiles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("VolumeFiles() = %+v, want %+v", got, want)
+	}
+
+	if got := NewProcessor(ctx, containers, nil, 0).VolumeFiles(); got != nil {
+		t.Errorf("VolumeFiles() = %v without volume classification", got)
+	}
+}
@@ -0,0 +1,145 @@
+package python
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// pycache is the directory CPython caches compiled bytecode in, next to
+// the sources (PEP 3147).
+const pycache = "__pycache__"
+
+// Counterparts returns globs, as matched by path.Match, of the files CPython
+// pairs with p: the bytecode cached in __pycache__ for a .py source, or the
+// source of cached bytecode. Cached bytecode is only used when its source is
+// present, so keeping one without the other breaks imports or slows every
+// start down. Returns nil for other files.
+func Counterparts(p string) []string {
+	dir, file := path.Dir(p), path.Base(p)
+	switch {
+	case strings.HasSuffix(file, ".py"):
+		stem := strings.TrimSuffix(file, ".py")
+		return []string{escapeGlob(path.Join(dir, pycache, stem)) + ".*.pyc"}
+	case strings.HasSuffix(file, ".pyc") && path.Base(dir) == pycache:
+		stem, _, _ := strings.Cut(file, ".")
+		return []string{escapeGlob(path.Join(path.Dir(dir), stem+".py"))}
+	case strings.HasSuffix(file, ".pyc"):
+		// Python 2 style bytecode next to its source
+		return []string{escapeGlob(strings.TrimSuffix(p, "c"))}
+	}
+	return nil
+}
+
+// escapeGlob escapes the characters path.Match treats specially.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ModuleName returns the dotted name of the module CPython loads from p,
+// e.g. "requests.adapters" for .../site-packages/requests/adapters.py or
+// its cached bytecode, or "" if p isn't a module of the standard library
+// or a site-packages directory. Packages are named after their __init__.
+func ModuleName(p string) string {
+	root := moduleRoot(p)
+	if root == "" {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(p, root+"/"), "/")
+	file := parts[len(parts)-1]
+	parts = parts[:len(parts)-1]
+	if len(parts) > 0 && parts[len(parts)-1] == pycache {
+		if !strings.HasSuffix(file, ".pyc") {
+			return ""
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	var stem string
+	switch {
+	case strings.HasSuffix(file, ".py"):
+		stem = strings.TrimSuffix(file, ".py")
+	case strings.HasSuffix(file, ".pyc"), strings.HasSuffix(file, ".so"):
+		// Bytecode and extension modules carry interpreter and ABI tags,
+		// e.g. _ssl.cpython-312-x86_64-linux-gnu.so
+		stem, _, _ = strings.Cut(file, ".")
+	default:
+		return ""
+	}
+	if stem != "__init__" {
+		parts = append(parts, stem)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	for _, part := range parts {
+		if !isIdentifier(part) {
+			return ""
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// moduleRoot returns the directory on sys.path that p is imported from: a
+// site-packages directory, the standard library's lib-dynload, or the
+// standard library itself (e.g. /usr/lib/python3.12). Returns "" if p isn't
+// under one.
+func moduleRoot(p string) string {
+	if dir := SitePackagesDir(p); dir != "" {
+		return dir
+	}
+	const lib = "/lib/python"
+	i := strings.Index(p, lib)
+	if i < 0 {
+		return ""
+	}
+	end := strings.IndexByte(p[i+len(lib):], '/')
+	if end < 0 {
+		return ""
+	}
+	root := p[:i+len(lib)+end]
+	if strings.HasPrefix(p[len(root):], "/lib-dynload/") {
+		root += "/lib-dynload"
+	}
+	return root
+}
+
+// isIdentifier reports whether s is a valid Python identifier in ASCII,
+// which is what importable module names are in practice.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Modules returns the sorted, distinct dotted names of the modules among
+// files, as named by ModuleName.
+func Modules(files []string) []string {
+	seen := make(map[string]bool)
+	var modules []string
+	for _, f := range files {
+		if name := ModuleName(f); name != "" && !seen[name] {
+			seen[name] = true
+			modules = append(modules, name)
+		}
+	}
+	sort.Strings(modules)
+	return modules
+}
@@ -0,0 +1,85 @@
+package python
+
+import (
+	"path"
+	"slices"
+	"testing"
+)
+
+func TestCounterparts(t *testing.T) {
+	for _, tc := range []struct {
+		file string
+		want []string
+		// match is a file the first glob must match
+		match string
+	}{
+		{
+			file:  "/usr/lib/python3.12/json/decoder.py",
+			want:  []string{"/usr/lib/python3.12/json/__pycache__/decoder.*.pyc"},
+			match: "/usr/lib/python3.12/json/__pycache__/decoder.cpython-312.opt-1.pyc",
+		},
+		{
+			file:  "/usr/lib/python3.12/json/__pycache__/decoder.cpython-312.pyc",
+			want:  []string{"/usr/lib/python3.12/json/decoder.py"},
+			match: "/usr/lib/python3.12/json/decoder.py",
+		},
+		{
+			file: "/app/legacy.pyc",
+			want: []string{"/app/legacy.py"},
+		},
+		{
+			file:  "/app/[odd]/mod.py",
+			want:  []string{`/app/\[odd]/__pycache__/mod.*.pyc`},
+			match: "/app/[odd]/__pycache__/mod.cpython-311.pyc",
+		},
+		{
+			file: "/usr/lib/python3.12/lib-dynload/_ssl.cpython-312-x86_64-linux-gnu.so",
+		},
+	} {
+		got := Counterparts(tc.file)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Counterparts(%q) = %q, want %q", tc.file, got, tc.want)
+			continue
+		}
+		if tc.match != "" {
+			if ok, err := path.Match(got[0], tc.match); err != nil || !ok {
+				t.Errorf("%q doesn't match %q (err %v)", got[0], tc.match, err)
+			}
+		}
+	}
+}
+
+func TestModuleName(t *testing.T) {
+	for file, want := range map[string]string{
+		"/usr/lib/python3.12/site-packages/requests/adapters.py":                          "requests.adapters",
+		"/usr/lib/python3.12/site-packages/requests/__init__.py":                          "requests",
+		"/usr/lib/python3.12/site-packages/requests/__pycache__/adapters.cpython-312.pyc": "requests.adapters",
+		"/usr/lib/python3.12/site-packages/six.py":                                        "six",
+		"/usr/lib/python3.12/site-packages/requests-2.31.0.dist-info/METADATA":            "",
+		"/venv/lib/python3.11/site-packages/yaml/_yaml.cpython-311-x86_64-linux-gnu.so":   "yaml._yaml",
+		"/usr/lib/python3/dist-packages/apt/__init__.py":                                  "apt",
+		"/usr/lib/python3.12/json/decoder.py":                                             "json.decoder",
+		"/usr/lib/python3.12/__pycache__/os.cpython-312.pyc":                              "os",
+		"/usr/local/lib/python3.12/lib-dynload/_ssl.cpython-312-x86_64-linux-gnu.so":      "_ssl",
+		"/usr/lib/python3.12/__pycache__/notbytecode.txt":                                 "",
+		"/usr/lib/python3.12/site-packages/__init__.py":                                   "",
+		"/usr/lib/python3.12/test-data/x.py":                                              "",
+		"/app/main.py":                                                                    "",
+	} {
+		if got := ModuleName(file); got != want {
+			t.Errorf("ModuleName(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	got := Modules([]string{
+		"/usr/lib/python3.12/site-packages/requests/__init__.py",
+		"/usr/lib/python3.12/site-packages/requests/__pycache__/__init__.cpython-312.pyc",
+		"/usr/lib/python3.12/json/__init__.py",
+		"/etc/hosts",
+	})
+	if want := []string{"json", "requests"}; !slices.Equal(got, want) {
+		t.Errorf("Modules() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+// Package python reads pip-installed package metadata (dist-info RECORD
+// files) for package attribution.
+package python
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+const (
+	// Manager identifies packages loaded by this package.
+	Manager = "pip"
+
+	// Ecosystem is the packages.DirLoader ecosystem name for Python.
+	Ecosystem = "python"
+)
+
+// DirLoader returns a loader that discovers site-packages directories from
+// accessed paths and reads the pip packages installed in them.
+func DirLoader() packages.DirLoader {
+	return packages.DirLoader{
+		Ecosystem: Ecosystem,
+		Dir:       SitePackagesDir,
+		Load:      Load,
+	}
+}
+
+// SitePackagesDir returns the site-packages (or Debian dist-packages)
+// directory containing p, or "" if p is not inside one. Detecting the
+// directory from accessed paths finds virtualenvs wherever they live.
+func SitePackagesDir(p string) string {
+	for _, name := range []string{"/site-packages/", "/dist-packages/"} {
+		if i := strings.Index(p, name); i >= 0 {
+			return p[:i+len(name)-1]
+		}
+	}
+	return ""
+}
+
+// Load reads every *.dist-info directory in the site-packages directory dir
+// under root. Paths in the returned packages are absolute within root.
+func Load(root, dir string) ([]*packages.Package, error) {
+	entries, err := os.ReadDir(filepath.Join(root, dir))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	var pkgs []*packages.Package
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasSuffix(e.Name(), ".dist-info") {
+			continue
+		}
+		pkg, err := loadDistInfo(root, dir, e.Name())
+		if errors.Is(err, fs.ErrNotExist) {
+			// No RECORD, e.g. installed by a distro package manager
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// loadDistInfo reads one dist-info directory's METADATA and RECORD.
+func loadDistInfo(root, dir, distInfo string) (*packages.Package, error) {
+	base := filepath.Join(root, dir, distInfo)
+
+	f, err := os.Open(filepath.Join(base, "RECORD"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	files, err := ParseRecord(f, dir)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s/RECORD: %w", distInfo, err)
+	}
+
+	name, version := nameFromDistInfo(distInfo)
+	if mf, err := os.Open(filepath.Join(base, "METADATA")); err == nil {
+		if n, v := parseMetadata(mf); n != "" {
+			name, version = n, v
+		}
+		mf.Close()
+	}
+
+	return &packages.Package{
+		Name:    name,
+		Version: version,
+		Manager: Manager,
+		Files:   files,
+	}, nil
+}
+
+// ParseRecord parses a dist-info RECORD file, a CSV of path,hash,size rows
+// with paths relative to the site-packages directory dir. Returns absolute,
+// cleaned paths.
+func ParseRecord(r io.Reader, dir string) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var files []string
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) == 0 || rec[0] == "" {
+			continue
+		}
+		p := rec[0]
+		if !path.IsAbs(p) {
+			// Entry points and data files use ../../../bin/foo style paths
+			p = path.Join(dir, p)
+		}
+		files = append(files, path.Clean(p))
+	}
+	return files, nil
+}
+
+// parseMetadata reads the Name and Version headers from a METADATA file.
+func parseMetadata(r io.Reader) (name, version string) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			// Headers end at the first blank line; the description follows
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "Name: "); ok {
+			name = strings.TrimSpace(v)
+		} else if v, ok := strings.CutPrefix(line, "Version: "); ok {
+			version = strings.TrimSpace(v)
+		}
+	}
+	return name, version
+}
+
+// nameFromDistInfo splits "requests-2.31.0.dist-info" into name and version.
+func nameFromDistInfo(distInfo string) (name, version string) {
+	s := strings.TrimSuffix(distInfo, ".dist-info")
+	name, version, _ = strings.Cut(s, "-")
+	return name, version
+}
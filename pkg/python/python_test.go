@@ -0,0 +1,86 @@
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSitePackagesDir(t *testing.T) {
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"/usr/local/lib/python3.12/site-packages/requests/api.py", "/usr/local/lib/python3.12/site-packages"},
+		{"/opt/venv/lib/python3.11/site-packages/yaml/__init__.py", "/opt/venv/lib/python3.11/site-packages"},
+		{"/usr/lib/python3/dist-packages/apt/__init__.py", "/usr/lib/python3/dist-packages"},
+		{"/usr/lib/python3.12/os.py", ""},
+		{"/etc/hosts", ""},
+	} {
+		if got := SitePackagesDir(tt.path); got != tt.want {
+			t.Errorf("SitePackagesDir(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	record := `requests/__init__.py,sha256=abc,4924
+requests/__pycache__/__init__.cpython-312.pyc,,
+"requests/odd,name.py",sha256=def,10
+../../../bin/normalizer,sha256=ghi,250
+requests-2.31.0.dist-info/RECORD,,
+`
+	got, err := ParseRecord(strings.NewReader(record), "/usr/local/lib/python3.12/site-packages")
+	if err != nil {
+		t.Fatalf("ParseRecord failed: %v", err)
+	}
+	want := []string{
+		"/usr/local/lib/python3.12/site-packages/requests/__init__.py",
+		"/usr/local/lib/python3.12/site-packages/requests/__pycache__/__init__.cpython-312.pyc",
+		"/usr/local/lib/python3.12/site-packages/requests/odd,name.py",
+		"/usr/local/bin/normalizer",
+		"/usr/local/lib/python3.12/site-packages/requests-2.31.0.dist-info/RECORD",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseRecord() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	site := "/usr/local/lib/python3.12/site-packages"
+	writeFile(t, filepath.Join(root, site, "requests-2.31.0.dist-info/METADATA"), "Metadata-Version: 2.1\nName: requests\nVersion: 2.31.0\n\nName: not-a-header\n")
+	writeFile(t, filepath.Join(root, site, "requests-2.31.0.dist-info/RECORD"), "requests/api.py,sha256=abc,100\n")
+	writeFile(t, filepath.Join(root, site, "PyYAML-6.0.1.dist-info/RECORD"), "yaml/__init__.py,,\n")
+	writeFile(t, filepath.Join(root, site, "distro_only-1.0.dist-info/METADATA"), "Name: distro-only\nVersion: 1.0\n")
+
+	pkgs, err := Load(root, site)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d packages, want 2 (dist-info without RECORD skipped)", len(pkgs))
+	}
+	byName := map[string]string{}
+	for _, p := range pkgs {
+		if p.Manager != Manager {
+			t.Errorf("%s manager = %q, want %q", p.Name, p.Manager, Manager)
+		}
+		byName[p.Name] = p.Version
+	}
+	// METADATA wins; without it the name comes from the directory
+	if byName["requests"] != "2.31.0" || byName["PyYAML"] != "6.0.1" {
+		t.Errorf("packages = %v", byName)
+	}
+}
@@ -0,0 +1,229 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubConfigKey is the key docker stores Docker Hub's credentials
+// under.
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// Credentials authenticate to a registry. The zero value is anonymous.
+type Credentials struct {
+	Username string
+	Password string
+
+	// IdentityToken is an OAuth2 refresh token, exchanged for bearer
+	// tokens in place of a username and password.
+	IdentityToken string
+}
+
+// DockerCredentials looks up a registry's credentials as docker does, from
+// $DOCKER_CONFIG/config.json or ~/.docker/config.json: through the
+// registry's credential helper in credHelpers, its entry in auths, or the
+// credsStore helper, in that order. Without a config file or any
+// credentials for the registry it returns zero Credentials.
+func DockerCredentials(registry string) (Credentials, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Credentials{}, nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Credentials{}, nil
+	}
+	if err != nil {
+		return Credentials{}, err
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth          string `json:"auth"`
+			Username      string `json:"username"`
+			Password      string `json:"password"`
+			IdentityToken string `json:"identitytoken"`
+		} `json:"auths"`
+		CredHelpers map[string]string `json:"credHelpers"`
+		CredsStore  string            `json:"credsStore"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Credentials{}, fmt.Errorf("parsing docker config: %w", err)
+	}
+
+	key := registry
+	if registry == dockerHub {
+		key = dockerHubConfigKey
+	}
+	if helper := config.CredHelpers[key]; helper != "" {
+		return credentialHelper(helper, key)
+	}
+	for k, a := range config.Auths {
+		if configHost(k) != configHost(key) {
+			continue
+		}
+		creds := Credentials{Username: a.Username, Password: a.Password, IdentityToken: a.IdentityToken}
+		if a.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return Credentials{}, fmt.Errorf("decoding docker config auth for %s: %w", k, err)
+			}
+			creds.Username, creds.Password, _ = strings.Cut(string(decoded), ":")
+		}
+		if creds != (Credentials{}) {
+			return creds, nil
+		}
+	}
+	if config.CredsStore != "" {
+		return credentialHelper(config.CredsStore, key)
+	}
+	return Credentials{}, nil
+}
+
+// configHost returns the host of a docker config auths key, which may be a
+// URL, e.g. "https://index.docker.io/v1/".
+func configHost(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ := strings.Cut(key, "/")
+	if host == "docker.io" || host == "registry-1.docker.io" {
+		host = "index.docker.io"
+	}
+	return host
+}
+
+// credentialHelper asks docker-credential-<helper> for a registry's
+// credentials, returning zero Credentials if it has none.
+func credentialHelper(helper, registry string) (Credentials, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stdout.String(), "credentials not found") {
+			return Credentials{}, nil
+		}
+		return Credentials{}, fmt.Errorf("docker-credential-%s: %w: %s", helper, err, strings.TrimSpace(stdout.String()+stderr.String()))
+	}
+	var out struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return Credentials{}, fmt.Errorf("docker-credential-%s: %w", helper, err)
+	}
+	if out.Username == "<token>" {
+		return Credentials{IdentityToken: out.Secret}, nil
+	}
+	return Credentials{Username: out.Username, Password: out.Secret}, nil
+}
+
+// authorize answers a WWW-Authenticate challenge from ref's registry with
+// the client's credentials, returning the Authorization header for
+// requests to ref's repository. Bearer tokens are scoped to pulls, or to
+// pushes too for methods other than GET and HEAD, unless the challenge
+// names a scope.
+func (c *Client) authorize(ctx context.Context, ref Reference, method, challenge string) (string, error) {
+	var creds Credentials
+	if c.Auth != nil {
+		var err error
+		if creds, err = c.Auth(ref.Registry); err != nil {
+			return "", err
+		}
+	}
+
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if strings.EqualFold(scheme, "Basic") {
+		if creds.Username == "" {
+			return "", errors.New("registry requires a username and password")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	}
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+	attrs := parseChallenge(params)
+	realm := attrs["realm"]
+	if realm == "" {
+		return "", errors.New("auth challenge has no realm")
+	}
+	scope := attrs["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+		if method != http.MethodGet && method != http.MethodHead {
+			scope += ",push"
+		}
+	}
+	token, err := c.token(ctx, realm, attrs["service"], scope, creds)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// token requests a bearer token for scope from a token server: with an
+// OAuth2 refresh token if creds have an identity token, or else with basic
+// auth, or anonymously if creds are zero.
+func (c *Client) token(ctx context.Context, realm, service, scope string, creds Credentials) (string, error) {
+	q := url.Values{}
+	if service != "" {
+		q.Set("service", service)
+	}
+	q.Set("scope", scope)
+
+	var req *http.Request
+	var err error
+	if creds.IdentityToken != "" {
+		q.Set("grant_type", "refresh_token")
+		q.Set("refresh_token", creds.IdentityToken)
+		q.Set("client_id", "snoop")
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm, strings.NewReader(q.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+q.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		if creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request: unexpected status %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", errors.New("token response has no token")
+}
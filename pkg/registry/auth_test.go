@@ -0,0 +1,135 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerCredentials(t *testing.T) {
+	// Fake credential helpers: "fake" knows every registry, "empty" none
+	bin := t.TempDir()
+	for name, script := range map[string]string{
+		"fake":  "#!/bin/sh\nread host\necho '{\"Username\":\"<token>\",\"Secret\":\"refresh-'$host'\"}'\n",
+		"empty": "#!/bin/sh\necho 'credentials not found in native keychain'\nexit 1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(bin, "docker-credential-"+name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", config)
+
+	// Without a config file, access is anonymous
+	if creds, err := DockerCredentials("cgr.dev"); err != nil || creds != (Credentials{}) {
+		t.Errorf("DockerCredentials without a config = %+v, %v", creds, err)
+	}
+
+	hub := base64.StdEncoding.EncodeToString([]byte("hubuser:hubpass"))
+	if err := os.WriteFile(filepath.Join(config, "config.json"), []byte(`{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "`+hub+`"},
+			"https://cgr.dev": {"username": "cgruser", "password": "cgrpass"},
+			"ghcr.io": {}
+		},
+		"credHelpers": {"gcr.io": "fake"},
+		"credsStore": "empty"
+	}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		registry string
+		want     Credentials
+	}{
+		{dockerHub, Credentials{Username: "hubuser", Password: "hubpass"}},
+		{"cgr.dev", Credentials{Username: "cgruser", Password: "cgrpass"}},
+		{"gcr.io", Credentials{IdentityToken: "refresh-gcr.io"}},
+		{"ghcr.io", Credentials{}},
+		{"quay.io", Credentials{}},
+	} {
+		creds, err := DockerCredentials(tt.registry)
+		if err != nil {
+			t.Errorf("DockerCredentials(%q): %v", tt.registry, err)
+		} else if creds != tt.want {
+			t.Errorf("DockerCredentials(%q) = %+v, want %+v", tt.registry, creds, tt.want)
+		}
+	}
+}
+
+func TestPushAuth(t *testing.T) {
+	// A registry that hands out tokens scoped as requested to one user, and
+	// requires a push token for anything but pulls
+	fake := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token": "` + r.URL.Query().Get("scope") + `"}`))
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, "repository:app:pull") ||
+			(r.Method != http.MethodGet && !strings.HasSuffix(token, ",push")) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ref, err := ParseReference(strings.TrimPrefix(srv.URL, "http://")+"/app:slim", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := &SlimImage{config: []byte("{}"), layer: tarLayer(t)}
+
+	c := NewClient()
+	c.Auth = nil
+	if err := c.Push(context.Background(), ref, img); err == nil {
+		t.Error("anonymous Push succeeded")
+	}
+	c.Auth = func(registry string) (Credentials, error) {
+		return Credentials{Username: "user", Password: "pass"}, nil
+	}
+	if err := c.Push(context.Background(), ref, img); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if fake.manifests["slim"] == nil {
+		t.Error("no manifest pushed")
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"schemaVersion": 2, "mediaType": "` + mediaTypeOCIManifest + `"}`))
+	}))
+	defer srv.Close()
+
+	ref, err := ParseReference(strings.TrimPrefix(srv.URL, "http://")+"/app:latest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient()
+	c.Auth = func(registry string) (Credentials, error) {
+		return Credentials{Username: "user", Password: "pass"}, nil
+	}
+	if _, err := c.imageManifest(context.Background(), ref); err != nil {
+		t.Errorf("imageManifest with basic auth failed: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Defaults for DockerfileOptions.
+const (
+	DefaultDockerfileBase   = "scratch"
+	DefaultDockerfileCopier = "busybox:stable"
+)
+
+// dockerfileListEnd ends the heredoc listing the files to copy.
+const dockerfileListEnd = "SNOOP_FILES"
+
+// SlimFiles lists what a slimmed image would hold, without its contents.
+type SlimFiles struct {
+	// Source is the image the files come from.
+	Source Reference
+
+	// Paths are the absolute paths of the kept entries and the directories
+	// containing them, in lexical order.
+	Paths []string
+
+	// Missing lists requested Files that aren't in the source image.
+	Missing []string
+
+	// Config holds the source's runtime settings.
+	Config ImageConfig
+}
+
+// ImageConfig holds the runtime settings of an image's configuration.
+type ImageConfig struct {
+	Entrypoint []string
+	Cmd        []string
+	Env        []string
+	User       string
+	WorkingDir string
+}
+
+// SlimFiles selects the same files as Slim, including the symlinks, their
+// targets, and the directories each file is reached through, but only reads
+// the image's layer headers and configuration.
+func (c *Client) SlimFiles(ctx context.Context, ref Reference, opts SlimOptions) (*SlimFiles, error) {
+	sel, err := c.selectFiles(ctx, ref, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Config ImageConfig `json:"config"`
+	}
+	if err := json.Unmarshal(sel.config, &config); err != nil {
+		return nil, fmt.Errorf("reading config of %s: %w", ref, err)
+	}
+	files := &SlimFiles{Source: ref, Missing: sel.missing, Config: config.Config}
+	for _, name := range layerNames(sel.entries, sel.kept) {
+		files.Paths = append(files.Paths, "/"+name)
+	}
+	return files, nil
+}
+
+// DockerfileOptions configures the Dockerfile written by WriteDockerfile.
+type DockerfileOptions struct {
+	// Source is the FROM reference of the original image.
+	Source string
+
+	// Base is the image the files are copied onto, such as scratch or a
+	// distroless image, and Copier an image with a shell and tar used to
+	// gather them.
+	Base   string
+	Copier string
+
+	// Comment is written at the top of the Dockerfile.
+	Comment string
+}
+
+// WriteDockerfile writes a multi-stage Dockerfile that copies files.Paths
+// from the source image onto the base image with their original modes,
+// owners, and symlinks, and keeps the source's runtime settings.
+func WriteDockerfile(w io.Writer, files *SlimFiles, opts DockerfileOptions) error {
+	if len(files.Paths) == 0 {
+		return fmt.Errorf("no files of %s to copy", files.Source)
+	}
+	if opts.Source == "" {
+		opts.Source = files.Source.String()
+	}
+	if opts.Base == "" {
+		opts.Base = DefaultDockerfileBase
+	}
+	if opts.Copier == "" {
+		opts.Copier = DefaultDockerfileCopier
+	}
+	for _, p := range files.Paths {
+		if strings.Contains(p, "\n") || p == dockerfileListEnd {
+			return fmt.Errorf("can't list path %q in a Dockerfile", p)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# syntax=docker/dockerfile:1\n")
+	for _, line := range strings.Split(opts.Comment, "\n") {
+		if line != "" {
+			fmt.Fprintf(bw, "# %s\n", line)
+		}
+	}
+	fmt.Fprintf(bw, "FROM %s AS source\n\n", opts.Source)
+
+	// COPY follows symlinks, so the files are gathered with tar, which
+	// keeps them along with modes and owners
+	fmt.Fprintf(bw, "FROM %s AS files\n", opts.Copier)
+	fmt.Fprintf(bw, "COPY --from=source / /source/\n")
+	fmt.Fprintf(bw, "COPY <<'%s' /files.txt\n", dockerfileListEnd)
+	for _, p := range files.Paths {
+		fmt.Fprintf(bw, ".%s\n", p)
+	}
+	fmt.Fprintf(bw, "%s\n", dockerfileListEnd)
+	fmt.Fprintf(bw, "RUN mkdir /rootfs && tar -C /source --no-recursion -cf - -T /files.txt | tar -C /rootfs -xpf -\n\n")
+
+	fmt.Fprintf(bw, "FROM %s\n", opts.Base)
+	fmt.Fprintf(bw, "COPY --from=files /rootfs/ /\n")
+	cfg := files.Config
+	for _, env := range cfg.Env {
+		k, v, _ := strings.Cut(env, "=")
+		fmt.Fprintf(bw, "ENV %s=%s\n", k, strconv.Quote(v))
+	}
+	if cfg.WorkingDir != "" {
+		fmt.Fprintf(bw, "WORKDIR %s\n", cfg.WorkingDir)
+	}
+	if cfg.User != "" {
+		fmt.Fprintf(bw, "USER %s\n", cfg.User)
+	}
+	if len(cfg.Entrypoint) > 0 {
+		fmt.Fprintf(bw, "ENTRYPOINT %s\n", dockerfileExec(cfg.Entrypoint))
+	}
+	if len(cfg.Cmd) > 0 {
+		fmt.Fprintf(bw, "CMD %s\n", dockerfileExec(cfg.Cmd))
+	}
+	return bw.Flush()
+}
+
+// dockerfileExec formats args in the exec (JSON array) form.
+func dockerfileExec(args []string) string {
+	b, _ := json.Marshal(args)
+	return string(b)
+}
@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// serveImage serves an image with config and gzipped layers from a fake
+// registry as app:v1, returning its reference.
+func serveImage(t *testing.T, config []byte, layers ...[]byte) Reference {
+	t.Helper()
+	fake := &fakeRegistry{blobs: map[string][]byte{digestOf(config): config}}
+	var descs []map[string]string
+	for _, l := range layers {
+		fake.blobs[digestOf(l)] = l
+		descs = append(descs, map[string]string{"mediaType": mediaTypeOCILayerGzip, "digest": digestOf(l)})
+	}
+	image, _ := json.Marshal(map[string]any{
+		"mediaType": mediaTypeOCIManifest,
+		"config":    map[string]string{"digest": digestOf(config)},
+		"layers":    descs,
+	})
+	fake.manifests = map[string][]byte{"v1": image}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	ref, err := ParseReference(strings.TrimPrefix(srv.URL, "http://")+"/app:v1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func TestWriteDockerfile(t *testing.T) {
+	reg := func(name string) tar.Header { return tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeReg} }
+	layer := tarLayer(t,
+		reg("usr/lib/libfoo.so"),
+		reg("usr/lib/libunused.so"),
+		tar.Header{Name: "lib", Typeflag: tar.TypeSymlink, Linkname: "usr/lib"},
+		reg("bin/app"),
+	)
+	config := []byte(`{"config":{"Entrypoint":["/bin/app","--serve"],"Env":["PATH=/usr/bin:/bin"],"User":"65532","WorkingDir":"/srv"}}`)
+	ref := serveImage(t, config, layer)
+
+	files, err := NewClient().SlimFiles(context.Background(), ref, SlimOptions{Files: []string{"/lib/libfoo.so", "/bin/app", "/missing"}})
+	if err != nil {
+		t.Fatalf("SlimFiles failed: %v", err)
+	}
+	if want := []string{"/bin", "/bin/app", "/lib", "/usr", "/usr/lib", "/usr/lib/libfoo.so"}; !slices.Equal(files.Paths, want) {
+		t.Errorf("Paths = %v, want %v", files.Paths, want)
+	}
+	if want := []string{"/missing"}; !slices.Equal(files.Missing, want) {
+		t.Errorf("Missing = %v, want %v", files.Missing, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDockerfile(&buf, files, DockerfileOptions{Source: "app:v1", Comment: "Generated for app"}); err != nil {
+		t.Fatalf("WriteDockerfile failed: %v", err)
+	}
+	want := `# syntax=docker/dockerfile:1
+# Generated for app
+FROM app:v1 AS source
+
+FROM busybox:stable AS files
+COPY --from=source / /source/
+COPY <<'SNOOP_FILES' /files.txt
+./bin
+./bin/app
+./lib
+./usr
+./usr/lib
+./usr/lib/libfoo.so
+SNOOP_FILES
+RUN mkdir /rootfs && tar -C /source --no-recursion -cf - -T /files.txt | tar -C /rootfs -xpf -
+
+FROM scratch
+COPY --from=files /rootfs/ /
+ENV PATH="/usr/bin:/bin"
+WORKDIR /srv
+USER 65532
+ENTRYPOINT ["/bin/app","--serve"]
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteDockerfile() =\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := WriteDockerfile(&buf, &SlimFiles{Source: ref}, DockerfileOptions{}); err == nil {
+		t.Error("WriteDockerfile succeeded without files")
+	}
+}
@@ -0,0 +1,434 @@
+// Package registry reads files from container images in OCI registries, for
+// when a container's root filesystem can't be read at runtime, and writes
+// minimized copies of them. Credentials come from docker's config, as
+// they would for docker pull and docker push.
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// dockerHub is the registry host for references without one.
+	dockerHub = "registry-1.docker.io"
+
+	// requestTimeout bounds each registry request, including layer downloads.
+	requestTimeout = 5 * time.Minute
+
+	// maxManifestSize bounds the size of a manifest or token response.
+	maxManifestSize = 4 << 20
+
+	// maxFileSize bounds the size of a file extracted from a layer.
+	maxFileSize = 256 << 20
+)
+
+// Media types of manifests and layers snoop understands.
+const (
+	mediaTypeOCIIndex          = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest       = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList        = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest    = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeOCILayerGzip      = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeOCILayer          = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeDockerLayerGzip   = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	mediaTypeOCINondistributed = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+)
+
+// Reference identifies an image in a registry.
+type Reference struct {
+	Registry   string // e.g. "cgr.dev"
+	Repository string // e.g. "chainguard/nginx"
+	Tag        string // used when Digest is empty
+	Digest     string // e.g. "sha256:..."
+}
+
+// String returns the reference in registry/repository[:tag|@digest] form.
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Digest != "" {
+		return s + "@" + r.Digest
+	}
+	return s + ":" + r.Tag
+}
+
+// ParseReference parses an image reference such as "nginx:1.25" or
+// "cgr.dev/chainguard/nginx@sha256:...". If digest is non-empty it pins the
+// reference, overriding any tag or digest in ref.
+func ParseReference(ref, digest string) (Reference, error) {
+	if ref == "" {
+		return Reference{}, errors.New("empty image reference")
+	}
+	var r Reference
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, r.Digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.Tag = name[:i], name[i+1:]
+	}
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry, r.Repository = first, rest
+	} else {
+		r.Registry, r.Repository = dockerHub, name
+	}
+	if r.Registry == "docker.io" || r.Registry == "index.docker.io" {
+		r.Registry = dockerHub
+	}
+	if r.Registry == dockerHub && !strings.Contains(r.Repository, "/") {
+		r.Repository = "library/" + r.Repository
+	}
+	if r.Repository == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", ref)
+	}
+	if digest != "" {
+		r.Digest = digest
+	}
+	if r.Digest != "" && !strings.Contains(r.Digest, ":") {
+		return Reference{}, fmt.Errorf("invalid digest %q", r.Digest)
+	}
+	if r.Tag == "" {
+		r.Tag = "latest"
+	}
+	return r, nil
+}
+
+// Client fetches images from registries. It is safe for concurrent use.
+type Client struct {
+	// HTTP is the client used for requests.
+	HTTP *http.Client
+
+	// OS and Arch select the image to use from a multi-platform index.
+	OS, Arch string
+
+	// Auth looks up credentials for a registry host. If it's nil, access
+	// is anonymous.
+	Auth func(registry string) (Credentials, error)
+
+	mu    sync.Mutex
+	auths map[string]string // Authorization headers by registry and repository
+}
+
+// NewClient creates a Client selecting images for the current platform,
+// with docker's credentials.
+func NewClient() *Client {
+	return &Client{
+		HTTP: &http.Client{Timeout: requestTimeout},
+		OS:   "linux",
+		Arch: runtime.GOARCH,
+		Auth: DockerCredentials,
+	}
+}
+
+// descriptor references a manifest or blob.
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform,omitempty"`
+}
+
+// manifest is the subset of an image manifest or index snoop uses.
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// Extract writes the given absolute paths, as they appear in the image's
+// flattened filesystem, under dir. Paths that aren't regular files in the
+// image are skipped. It returns the paths that were written.
+func (c *Client) Extract(ctx context.Context, ref Reference, dir string, paths ...string) ([]string, error) {
+	m, err := c.imageManifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk layers top down; a path is settled by the first layer that has
+	// it, deletes it, or hides the directory containing it
+	pending := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		pending[strings.TrimPrefix(path.Clean("/"+p), "/")] = true
+	}
+	var found []string
+	for i := len(m.Layers) - 1; i >= 0 && len(pending) > 0; i-- {
+		written, err := c.extractLayer(ctx, ref, m.Layers[i], dir, pending)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, written...)
+	}
+	return found, nil
+}
+
+// imageManifest fetches ref's image manifest, resolving a multi-platform
+// index to the image for the client's platform.
+func (c *Client) imageManifest(ctx context.Context, ref Reference) (*manifest, error) {
+	target := ref.Digest
+	if target == "" {
+		target = ref.Tag
+	}
+	m, err := c.fetchManifest(ctx, ref, target)
+	if err != nil {
+		return nil, err
+	}
+	if m.MediaType != mediaTypeOCIIndex && m.MediaType != mediaTypeDockerList && len(m.Manifests) == 0 {
+		return m, nil
+	}
+	for _, d := range m.Manifests {
+		if d.Platform != nil && d.Platform.OS == c.OS && d.Platform.Architecture == c.Arch {
+			return c.fetchManifest(ctx, ref, d.Digest)
+		}
+	}
+	return nil, fmt.Errorf("image %s has no %s/%s manifest", ref, c.OS, c.Arch)
+}
+
+func (c *Client) fetchManifest(ctx context.Context, ref Reference, target string) (*manifest, error) {
+	resp, err := c.get(ctx, ref, "manifests/"+target, strings.Join([]string{
+		mediaTypeOCIIndex, mediaTypeDockerList, mediaTypeOCIManifest, mediaTypeDockerManifest,
+	}, ","))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var m manifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("decoding manifest of %s: %w", ref, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	return &m, nil
+}
+
+// extractLayer writes pending paths found in one layer under dir, removing
+// settled paths from pending.
+func (c *Client) extractLayer(ctx context.Context, ref Reference, layer descriptor, dir string, pending map[string]bool) ([]string, error) {
+	var written []string
+	settled := make(map[string]bool)
+	err := c.walkLayer(ctx, ref, layer, func(name string, hdr *tar.Header, r io.Reader) error {
+		base := path.Base(name)
+
+		// Whiteouts delete a path, or everything below a directory, in
+		// lower layers
+		if base == whiteoutOpaque {
+			prefix := path.Dir(name) + "/"
+			for p := range pending {
+				if strings.HasPrefix(p, prefix) {
+					settled[p] = true
+				}
+			}
+			return nil
+		}
+		if deleted, ok := strings.CutPrefix(base, whiteoutPrefix); ok {
+			deleted = path.Join(path.Dir(name), deleted)
+			for p := range pending {
+				if p == deleted || strings.HasPrefix(p, deleted+"/") {
+					settled[p] = true
+				}
+			}
+			return nil
+		}
+
+		if !pending[name] {
+			return nil
+		}
+		settled[name] = true
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		if err := writeFile(filepath.Join(dir, filepath.FromSlash(name)), r); err != nil {
+			return err
+		}
+		written = append(written, "/"+name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range settled {
+		delete(pending, p)
+	}
+	return written, nil
+}
+
+// Whiteout file names, which delete paths from lower layers.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// walkLayer calls fn for each entry of a layer, with its name cleaned and
+// relative to the root.
+func (c *Client) walkLayer(ctx context.Context, ref Reference, layer descriptor, fn func(name string, hdr *tar.Header, r io.Reader) error) error {
+	resp, err := c.get(ctx, ref, "blobs/"+layer.Digest, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r io.Reader = resp.Body
+	switch layer.MediaType {
+	case mediaTypeOCILayerGzip, mediaTypeDockerLayerGzip, mediaTypeOCINondistributed:
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("reading layer %s: %w", layer.Digest, err)
+		}
+		defer gz.Close()
+		r = gz
+	case mediaTypeOCILayer:
+	default:
+		return fmt.Errorf("unsupported layer media type %q", layer.MediaType)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading layer %s: %w", layer.Digest, err)
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if name == "" {
+			continue
+		}
+		if err := fn(name, hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile copies at most maxFileSize bytes from r to path.
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, io.LimitReader(r, maxFileSize)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// get requests /v2/<repository>/<suffix>, authenticating if the registry
+// asks.
+func (c *Client) get(ctx context.Context, ref Reference, suffix, accept string) (*http.Response, error) {
+	header := http.Header{}
+	if accept != "" {
+		header.Set("Accept", accept)
+	}
+	return c.send(ctx, ref, http.MethodGet, c.url(ref, suffix), header, nil, http.StatusOK)
+}
+
+// url returns the URL of /v2/<repository>/<suffix> in ref's registry.
+func (c *Client) url(ref Reference, suffix string) string {
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme(ref.Registry), ref.Registry, ref.Repository, suffix)
+}
+
+// send makes a request to ref's registry, authenticating if the registry
+// asks, and fails unless the response has the wanted status.
+func (c *Client) send(ctx context.Context, ref Reference, method, u string, header http.Header, body []byte, want int) (*http.Response, error) {
+	key := ref.Registry + "/" + ref.Repository
+	do := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(req.Header, header)
+		c.mu.Lock()
+		auth := c.auths[key]
+		c.mu.Unlock()
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		return c.HTTP.Do(req)
+	}
+
+	resp, err := do()
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", u, err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		auth, err := c.authorize(ctx, ref, method, challenge)
+		if err != nil {
+			return nil, fmt.Errorf("authenticating to %s: %w", ref.Registry, err)
+		}
+		c.mu.Lock()
+		if c.auths == nil {
+			c.auths = make(map[string]string)
+		}
+		c.auths[key] = auth
+		c.mu.Unlock()
+		if resp, err = do(); err != nil {
+			return nil, fmt.Errorf("fetching %s: %w", u, err)
+		}
+	}
+	if resp.StatusCode != want {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: unexpected status %s", method, u, resp.Status)
+	}
+	return resp, nil
+}
+
+// parseChallenge parses the comma-separated key="value" parameters of a
+// WWW-Authenticate challenge.
+func parseChallenge(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		key, rest, ok := strings.Cut(strings.TrimLeft(s, " ,"), "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, s = rest[1:end+1], rest[end+2:]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return attrs
+}
+
+// scheme returns the URL scheme for a registry: plain http for local
+// registries, https otherwise.
+func scheme(registry string) string {
+	host := registry
+	if h, _, ok := strings.Cut(registry, ":"); ok {
+		host = h
+	}
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		return "http"
+	}
+	return "https"
+}
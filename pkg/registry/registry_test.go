@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	for _, tt := range []struct {
+		ref, digest string
+		want        Reference
+	}{
+		{ref: "nginx", want: Reference{Registry: dockerHub, Repository: "library/nginx", Tag: "latest"}},
+		{ref: "docker.io/bitnami/redis:7", want: Reference{Registry: dockerHub, Repository: "bitnami/redis", Tag: "7"}},
+		{ref: "cgr.dev/chainguard/nginx:latest", digest: "sha256:abc", want: Reference{Registry: "cgr.dev", Repository: "chainguard/nginx", Tag: "latest", Digest: "sha256:abc"}},
+		{ref: "localhost:5000/app@sha256:def", want: Reference{Registry: "localhost:5000", Repository: "app", Tag: "latest", Digest: "sha256:def"}},
+	} {
+		got, err := ParseReference(tt.ref, tt.digest)
+		if err != nil {
+			t.Errorf("ParseReference(%q) failed: %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseReference(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+	if _, err := ParseReference("", ""); err == nil {
+		t.Error("ParseReference(\"\") succeeded")
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	got := parseChallenge(`realm="https://auth.example.com/token",service="registry.example.com",scope="repository:app:pull"`)
+	if got["realm"] != "https://auth.example.com/token" || got["service"] != "registry.example.com" || got["scope"] != "repository:app:pull" {
+		t.Errorf("parseChallenge = %v", got)
+	}
+}
+
+// layer builds a gzipped tar layer from name -> content entries.
+func layer(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func digest(b []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+}
+
+func TestExtract(t *testing.T) {
+	base := layer(t, map[string]string{
+		"lib/apk/db/installed": "P:musl\n",
+		"etc/apk/world":        "musl\n",
+		"etc/removed":          "old",
+	})
+	top := layer(t, map[string]string{
+		"lib/apk/db/installed": "P:musl\n\nP:curl\n",
+		"etc/.wh.removed":      "",
+	})
+	blobs := map[string][]byte{digest(base): base, digest(top): top}
+
+	image, _ := json.Marshal(manifest{
+		MediaType: mediaTypeOCIManifest,
+		Layers: []descriptor{
+			{MediaType: mediaTypeOCILayerGzip, Digest: digest(base)},
+			{MediaType: mediaTypeOCILayerGzip, Digest: digest(top)},
+		},
+	})
+	index, _ := json.Marshal(map[string]any{
+		"mediaType": mediaTypeOCIIndex,
+		"manifests": []map[string]any{
+			{"mediaType": mediaTypeOCIManifest, "digest": "sha256:other", "platform": map[string]string{"os": "linux", "architecture": "s390x"}},
+			{"mediaType": mediaTypeOCIManifest, "digest": digest(image), "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+		},
+	})
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:app:pull" {
+				t.Errorf("token scope = %q", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/app/manifests/sha256:index":
+			w.Header().Set("Content-Type", mediaTypeOCIIndex)
+			w.Write(index)
+		case r.URL.Path == "/v2/app/manifests/"+digest(image):
+			w.Write(image)
+		case strings.HasPrefix(r.URL.Path, "/v2/app/blobs/"):
+			b, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/app/blobs/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(b)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ref, err := ParseReference(strings.TrimPrefix(srv.URL, "http://")+"/app", "sha256:index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient()
+	c.Arch = "amd64"
+	dir := t.TempDir()
+	found, err := c.Extract(context.Background(), ref, dir, "/lib/apk/db/installed", "/etc/apk/world", "/etc/removed", "/usr/lib/apk/db/installed")
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	slices.Sort(found)
+	if want := []string{"/etc/apk/world", "/lib/apk/db/installed"}; !slices.Equal(found, want) {
+		t.Errorf("found = %v, want %v", found, want)
+	}
+	// The top layer's copy wins
+	if b, err := os.ReadFile(filepath.Join(dir, "lib/apk/db/installed")); err != nil || !strings.Contains(string(b), "curl") {
+		t.Errorf("installed = %q, %v; want the top layer's database", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "etc/removed")); err == nil {
+		t.Error("whited-out file was extracted")
+	}
+}
@@ -0,0 +1,543 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxSymlinkHops bounds symlink resolution, as the kernel does.
+const maxSymlinkHops = 40
+
+// SlimOptions select the files kept in a slimmed image.
+type SlimOptions struct {
+	// Files are absolute paths to keep, such as the files in a report.
+	Files []string
+
+	// Keep are globs of absolute paths to keep in addition to Files, as
+	// matched by path.Match. A glob matching a directory keeps everything
+	// below it.
+	Keep []string
+}
+
+// SlimImage is a single-layer image holding a subset of another image's
+// files. The layer is held in memory.
+type SlimImage struct {
+	// Source is the image the files came from.
+	Source Reference
+
+	// Files counts the entries in the layer, and Size the bytes of their
+	// contents.
+	Files int
+	Size  int64
+
+	// Missing lists requested Files that aren't in the source image.
+	Missing []string
+
+	config []byte
+	layer  []byte // uncompressed tar
+	diffID string
+}
+
+// entry is a path in an image's flattened filesystem.
+type entry struct {
+	hdr   *tar.Header
+	layer int    // index of the layer defining it
+	data  []byte // contents of a regular file, once read
+}
+
+// selection is the part of an image's flattened filesystem selected by
+// SlimOptions.
+type selection struct {
+	layers  []descriptor
+	config  []byte
+	entries map[string]*entry
+	kept    map[string]bool
+	missing []string
+}
+
+// Slim builds an image from ref holding only the files selected by opts,
+// the directories containing them, and the symlinks and hard links they are
+// reached through. The image keeps the source's configuration, such as its
+// entrypoint and environment.
+func (c *Client) Slim(ctx context.Context, ref Reference, opts SlimOptions) (*SlimImage, error) {
+	sel, err := c.selectFiles(ctx, ref, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	img := &SlimImage{Source: ref, Missing: sel.missing}
+	if err := c.readKept(ctx, ref, sel.layers, sel.entries, sel.kept); err != nil {
+		return nil, err
+	}
+
+	if img.layer, err = writeLayer(sel.entries, sel.kept, img); err != nil {
+		return nil, err
+	}
+	img.diffID = digestOf(img.layer)
+	if img.config, err = slimConfig(sel.config, img.diffID); err != nil {
+		return nil, fmt.Errorf("rewriting config of %s: %w", ref, err)
+	}
+	return img, nil
+}
+
+// selectFiles flattens ref and selects the entries opts keeps. If read is
+// set, the contents of files selected outright are read along the way.
+func (c *Client) selectFiles(ctx context.Context, ref Reference, opts SlimOptions, read bool) (*selection, error) {
+	m, err := c.imageManifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	config, err := c.blob(ctx, ref, m.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(opts.Files))
+	for _, f := range opts.Files {
+		if name := strings.TrimPrefix(path.Clean("/"+f), "/"); name != "" {
+			wanted[name] = true
+		}
+	}
+	for _, g := range opts.Keep {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("invalid keep glob %q: %w", g, err)
+		}
+	}
+	keep := func(name string) bool {
+		return read && (wanted[name] || matchGlobs(opts.Keep, "/"+name))
+	}
+
+	// Flatten the layers top down, reading the contents of files that are
+	// kept outright; files only reached through links are read afterwards
+	entries, err := c.flatten(ctx, ref, m.Layers, keep)
+	if err != nil {
+		return nil, err
+	}
+	sel := &selection{layers: m.Layers, config: config, entries: entries, kept: make(map[string]bool)}
+	for name := range wanted {
+		if !resolve(entries, name, sel.kept) {
+			sel.missing = append(sel.missing, "/"+name)
+		}
+	}
+	slices.Sort(sel.missing)
+	for name := range entries {
+		if matchGlobs(opts.Keep, "/"+name) {
+			resolve(entries, name, sel.kept)
+		}
+	}
+	return sel, nil
+}
+
+// matchGlobs reports whether p or any directory containing it matches one
+// of globs.
+func matchGlobs(globs []string, p string) bool {
+	for {
+		for _, g := range globs {
+			if ok, _ := path.Match(g, p); ok {
+				return true
+			}
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+		p = path.Dir(p)
+	}
+}
+
+// flatten walks layers top down, returning the entries of the flattened
+// filesystem. Whiteouts hide entries of lower layers. The contents of
+// regular files selected by keep are read.
+func (c *Client) flatten(ctx context.Context, ref Reference, layers []descriptor, keep func(string) bool) (map[string]*entry, error) {
+	entries := make(map[string]*entry)
+	deleted := make(map[string]bool) // paths hidden from lower layers
+	opaque := make(map[string]bool)  // directories whose lower contents are hidden
+	hidden := func(name string) bool {
+		if deleted[name] {
+			return true
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if deleted[dir] || opaque[dir] {
+				return true
+			}
+		}
+		return opaque["."]
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		// Whiteouts only apply to lower layers
+		var whiteouts, opaques []string
+		err := c.walkLayer(ctx, ref, layers[i], func(name string, hdr *tar.Header, r io.Reader) error {
+			base := path.Base(name)
+			if base == whiteoutOpaque {
+				opaques = append(opaques, path.Dir(name))
+				return nil
+			}
+			if target, ok := strings.CutPrefix(base, whiteoutPrefix); ok {
+				whiteouts = append(whiteouts, path.Join(path.Dir(name), target))
+				return nil
+			}
+			if entries[name] != nil || hidden(name) {
+				return nil
+			}
+			e := &entry{hdr: hdr, layer: i}
+			entries[name] = e
+			if hdr.Typeflag == tar.TypeReg && keep(name) {
+				return e.read(r)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range whiteouts {
+			deleted[name] = true
+		}
+		for _, name := range opaques {
+			opaque[name] = true
+		}
+	}
+	return entries, nil
+}
+
+// read reads a regular file's contents.
+func (e *entry) read(r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxFileSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxFileSize {
+		return fmt.Errorf("%s is larger than %d bytes", e.hdr.Name, maxFileSize)
+	}
+	e.data = data
+	return nil
+}
+
+// resolve marks name as kept, along with the symlinks it is reached
+// through, their targets, and the targets of hard links. It reports whether
+// name resolves to an entry.
+func resolve(entries map[string]*entry, name string, kept map[string]bool) bool {
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		name = resolveDirs(entries, name, kept)
+		e := entries[name]
+		if e == nil {
+			return false
+		}
+		if kept[name] {
+			return true
+		}
+		kept[name] = true
+		switch e.hdr.Typeflag {
+		case tar.TypeSymlink:
+			name = linkTarget(name, e.hdr.Linkname)
+		case tar.TypeLink:
+			name = strings.TrimPrefix(path.Clean("/"+e.hdr.Linkname), "/")
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDirs follows symlinks among the directories containing name,
+// marking them as kept, and returns the resolved path.
+func resolveDirs(entries map[string]*entry, name string, kept map[string]bool) string {
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		parts := strings.Split(name, "/")
+		dir, followed := "", false
+		for i, part := range parts[:len(parts)-1] {
+			p := path.Join(dir, part)
+			if e := entries[p]; e != nil && e.hdr.Typeflag == tar.TypeSymlink {
+				kept[p] = true
+				name = path.Join(append([]string{linkTarget(p, e.hdr.Linkname)}, parts[i+1:]...)...)
+				followed = true
+				break
+			}
+			dir = p
+		}
+		if !followed {
+			break
+		}
+	}
+	return name
+}
+
+// linkTarget returns the path a symlink at name points to, relative to the
+// root.
+func linkTarget(name, target string) string {
+	if !path.IsAbs(target) {
+		target = path.Join("/", path.Dir(name), target)
+	}
+	return strings.TrimPrefix(path.Clean(target), "/")
+}
+
+// readKept reads the contents of kept regular files that flatten didn't,
+// re-reading only the layers defining them.
+func (c *Client) readKept(ctx context.Context, ref Reference, layers []descriptor, entries map[string]*entry, kept map[string]bool) error {
+	unread := make(map[int]map[string]bool)
+	for name := range kept {
+		if e := entries[name]; e.hdr.Typeflag == tar.TypeReg && e.data == nil && e.hdr.Size > 0 {
+			if unread[e.layer] == nil {
+				unread[e.layer] = make(map[string]bool)
+			}
+			unread[e.layer][name] = true
+		}
+	}
+	for i, names := range unread {
+		err := c.walkLayer(ctx, ref, layers[i], func(name string, _ *tar.Header, r io.Reader) error {
+			if !names[name] {
+				return nil
+			}
+			delete(names, name)
+			return entries[name].read(r)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLayer writes the kept entries, and the directories containing them,
+// to an uncompressed tar layer in lexical order, so that directories come
+// before their contents. Hard links become copies of their targets, which
+// may not be written first.
+func writeLayer(entries map[string]*entry, kept map[string]bool, img *SlimImage) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range layerNames(entries, kept) {
+		var hdr tar.Header
+		var data []byte
+		switch e := entries[name]; {
+		case e == nil:
+			// A directory only known from the paths below it
+			hdr = tar.Header{Typeflag: tar.TypeDir, Mode: 0755}
+		case e.hdr.Typeflag == tar.TypeLink:
+			target := entries[strings.TrimPrefix(path.Clean("/"+e.hdr.Linkname), "/")]
+			if target == nil || target.hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			hdr = *e.hdr
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeReg, "", target.hdr.Size
+			data = target.data
+		default:
+			hdr = *e.hdr
+			data = e.data
+		}
+		hdr.Name = name
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", name, err)
+		}
+		img.Files++
+		img.Size += int64(len(data))
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// layerNames returns the kept entries and the directories containing them in
+// lexical order, so that directories come before their contents.
+func layerNames(entries map[string]*entry, kept map[string]bool) []string {
+	names := make(map[string]bool, len(kept))
+	for name := range kept {
+		names[name] = true
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			names[dir] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		// A symlink on the way to a kept path is kept itself, so this is a
+		// directory replaced by something else in an upper layer
+		if e := entries[name]; e != nil && !kept[name] && e.hdr.Typeflag != tar.TypeDir {
+			continue
+		}
+		sorted = append(sorted, name)
+	}
+	slices.Sort(sorted)
+	return sorted
+}
+
+// slimConfig rewrites an image config for a single layer with diffID,
+// keeping the rest of the configuration.
+func slimConfig(config []byte, diffID string) ([]byte, error) {
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, err
+	}
+	rootfs, err := json.Marshal(map[string]any{"type": "layers", "diff_ids": []string{diffID}})
+	if err != nil {
+		return nil, err
+	}
+	history, err := json.Marshal([]map[string]string{{
+		"created":    time.Now().UTC().Format(time.RFC3339),
+		"created_by": "snoop slim",
+	}})
+	if err != nil {
+		return nil, err
+	}
+	cfg["rootfs"] = rootfs
+	cfg["history"] = history
+	return json.Marshal(cfg)
+}
+
+// blob fetches a small blob, such as an image config.
+func (c *Client) blob(ctx context.Context, ref Reference, digest string) ([]byte, error) {
+	resp, err := c.get(ctx, ref, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", digest, err)
+	}
+	if got := digestOf(data); got != digest {
+		return nil, fmt.Errorf("blob %s has digest %s", digest, got)
+	}
+	return data, nil
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// WriteRootFS writes the image's filesystem as a tar, with the original
+// modes and owners, for `docker import` or other tools that take a root
+// filesystem.
+func (img *SlimImage) WriteRootFS(w io.Writer) error {
+	_, err := w.Write(img.layer)
+	return err
+}
+
+// WriteTarball writes the image in the format of `docker save`, which
+// `docker load` and most image tools read, tagged with tag if it's set.
+func (img *SlimImage) WriteTarball(w io.Writer, tag string) error {
+	configName := strings.TrimPrefix(digestOf(img.config), "sha256:") + ".json"
+	layerName := strings.TrimPrefix(img.diffID, "sha256:") + "/layer.tar"
+	var tags []string
+	if tag != "" {
+		tags = []string{tag}
+	}
+	manifest, err := json.Marshal([]map[string]any{{
+		"Config":   configName,
+		"RepoTags": tags,
+		"Layers":   []string{layerName},
+	}})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{configName, img.config},
+		{layerName, img.layer},
+		{"manifest.json", manifest},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// Push uploads the image to ref, compressing its layer.
+func (c *Client) Push(ctx context.Context, ref Reference, img *SlimImage) error {
+	var layer bytes.Buffer
+	gz := gzip.NewWriter(&layer)
+	if _, err := gz.Write(img.layer); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	layerDigest := digestOf(layer.Bytes())
+	configDigest := digestOf(img.config)
+	if err := c.upload(ctx, ref, layerDigest, layer.Bytes()); err != nil {
+		return err
+	}
+	if err := c.upload(ctx, ref, configDigest, img.config); err != nil {
+		return err
+	}
+
+	manifest, err := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeOCIManifest,
+		"config": map[string]any{
+			"mediaType": "application/vnd.oci.image.config.v1+json",
+			"digest":    configDigest,
+			"size":      len(img.config),
+		},
+		"layers": []map[string]any{{
+			"mediaType": mediaTypeOCILayerGzip,
+			"digest":    layerDigest,
+			"size":      layer.Len(),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	target := ref.Digest
+	if target == "" {
+		target = ref.Tag
+	}
+	header := http.Header{"Content-Type": {mediaTypeOCIManifest}}
+	resp, err := c.send(ctx, ref, http.MethodPut, c.url(ref, "manifests/"+target), header, manifest, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// upload pushes a blob to ref's repository in a single request.
+func (c *Client) upload(ctx context.Context, ref Reference, digest string, data []byte) error {
+	resp, err := c.send(ctx, ref, http.MethodPost, c.url(ref, "blobs/uploads/"), nil, nil, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("upload location: %w", err)
+	}
+	q := location.Query()
+	q.Set("digest", digest)
+	location.RawQuery = q.Encode()
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.send(ctx, ref, http.MethodPut, location.String(), header, data, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
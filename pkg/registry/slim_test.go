@@ -0,0 +1,282 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// tarLayer builds a gzipped tar layer from headers; regular files contain
+// their own names.
+func tarLayer(t *testing.T, hdrs ...tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(hdr.Name))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeRegistry serves blobs and manifests from memory and accepts pushes.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rest, ok := strings.CutPrefix(r.URL.Path, "/v2/app/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(rest, "blobs/"):
+		b, ok := f.blobs[strings.TrimPrefix(rest, "blobs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	case r.Method == http.MethodGet && strings.HasPrefix(rest, "manifests/"):
+		b, ok := f.manifests[strings.TrimPrefix(rest, "manifests/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	case r.Method == http.MethodPost && rest == "blobs/uploads/":
+		w.Header().Set("Location", "/v2/app/blobs/uploads/session?state=1")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && rest == "blobs/uploads/session":
+		b, _ := io.ReadAll(r.Body)
+		if r.URL.Query().Get("state") != "1" || r.URL.Query().Get("digest") != digestOf(b) {
+			http.Error(w, "bad upload", http.StatusBadRequest)
+			return
+		}
+		f.blobs[digestOf(b)] = b
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && strings.HasPrefix(rest, "manifests/"):
+		b, _ := io.ReadAll(r.Body)
+		f.manifests[strings.TrimPrefix(rest, "manifests/")] = b
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// readTar returns the entries of an uncompressed tar, with the contents of
+// regular files.
+func readTar(t *testing.T, r io.Reader) map[string]*tar.Header {
+	t.Helper()
+	entries := make(map[string]*tar.Header)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			b, _ := io.ReadAll(tr)
+			hdr.PAXRecords = map[string]string{"contents": string(b)}
+		}
+		entries[hdr.Name] = hdr
+	}
+}
+
+func TestSlim(t *testing.T) {
+	reg := func(name string) tar.Header { return tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeReg} }
+	base := tarLayer(t,
+		tar.Header{Name: "usr/", Mode: 0700, Typeflag: tar.TypeDir},
+		reg("usr/lib/libfoo.so"),
+		reg("usr/lib/libunused.so"),
+		tar.Header{Name: "lib", Typeflag: tar.TypeSymlink, Linkname: "usr/lib"},
+		reg("bin/app"),
+		tar.Header{Name: "bin/sh", Typeflag: tar.TypeLink, Linkname: "bin/app"},
+		tar.Header{Name: "bin/current", Typeflag: tar.TypeSymlink, Linkname: "../opt/v2/run"},
+		reg("opt/v2/run"),
+		reg("etc/removed"),
+		reg("etc/ssl/certs/ca.pem"),
+		reg("etc/ssl/openssl.cnf"),
+	)
+	top := tarLayer(t,
+		reg("bin/app"),
+		tar.Header{Name: "etc/.wh.removed", Typeflag: tar.TypeReg},
+	)
+	config := []byte(`{"architecture":"amd64","os":"linux","config":{"Entrypoint":["/bin/app"]},"rootfs":{"type":"layers","diff_ids":["sha256:a","sha256:b"]}}`)
+	image, _ := json.Marshal(map[string]any{
+		"mediaType": mediaTypeOCIManifest,
+		"config":    map[string]string{"digest": digestOf(config)},
+		"layers": []map[string]string{
+			{"mediaType": mediaTypeOCILayerGzip, "digest": digestOf(base)},
+			{"mediaType": mediaTypeOCILayerGzip, "digest": digestOf(top)},
+		},
+	})
+	fake := &fakeRegistry{
+		blobs:     map[string][]byte{digestOf(config): config, digestOf(base): base, digestOf(top): top},
+		manifests: map[string][]byte{"v1": image},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	ref, err := ParseReference(host+"/app:v1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient()
+	img, err := c.Slim(context.Background(), ref, SlimOptions{
+		Files: []string{"/lib/libfoo.so", "/bin/sh", "/bin/current", "/etc/removed", "/nonexistent"},
+		Keep:  []string{"/etc/ssl/cert[s]"},
+	})
+	if err != nil {
+		t.Fatalf("Slim failed: %v", err)
+	}
+	if want := []string{"/etc/removed", "/nonexistent"}; !slices.Equal(img.Missing, want) {
+		t.Errorf("Missing = %v, want %v", img.Missing, want)
+	}
+
+	entries := readTar(t, bytes.NewReader(img.layer))
+	want := []string{
+		"bin/", "bin/app", "bin/current", "bin/sh",
+		"etc/", "etc/ssl/", "etc/ssl/certs/", "etc/ssl/certs/ca.pem",
+		"lib",
+		"opt/", "opt/v2/", "opt/v2/run",
+		"usr/", "usr/lib/", "usr/lib/libfoo.so",
+	}
+	if got := slices.Sorted(maps.Keys(entries)); !slices.Equal(got, want) {
+		t.Errorf("layer entries = %v, want %v", got, want)
+	}
+	if img.Files != len(want) {
+		t.Errorf("Files = %d, want %d", img.Files, len(want))
+	}
+	// The hard link is a copy of the top layer's target
+	if sh := entries["bin/sh"]; sh == nil || sh.Typeflag != tar.TypeReg || sh.PAXRecords["contents"] != "bin/app" {
+		t.Errorf("bin/sh = %+v, want a copy of bin/app", sh)
+	}
+	if usr := entries["usr/"]; usr == nil || usr.Mode != 0700 {
+		t.Errorf("usr/ = %+v, want the image's mode", usr)
+	}
+
+	var cfg struct {
+		Config struct{ Entrypoint []string }
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		}
+	}
+	if err := json.Unmarshal(img.config, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.Config.Entrypoint, []string{"/bin/app"}) || !slices.Equal(cfg.RootFS.DiffIDs, []string{digestOf(img.layer)}) {
+		t.Errorf("config = %s", img.config)
+	}
+
+	t.Run("rootfs", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := img.WriteRootFS(&buf); err != nil {
+			t.Fatal(err)
+		}
+		rootfs := readTar(t, &buf)
+		if got := slices.Sorted(maps.Keys(rootfs)); !slices.Equal(got, want) {
+			t.Errorf("rootfs entries = %v, want %v", got, want)
+		}
+		if lib := rootfs["lib"]; lib == nil || lib.Typeflag != tar.TypeSymlink || lib.Linkname != "usr/lib" {
+			t.Errorf("lib = %+v, want the symlink", lib)
+		}
+	})
+
+	t.Run("tarball", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := img.WriteTarball(&buf, "app:slim"); err != nil {
+			t.Fatal(err)
+		}
+		files := make(map[string][]byte)
+		tr := tar.NewReader(&buf)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[hdr.Name], _ = io.ReadAll(tr)
+		}
+		var manifest []struct {
+			Config   string
+			RepoTags []string
+			Layers   []string
+		}
+		if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest) != 1 || len(manifest[0].Layers) != 1 || !slices.Equal(manifest[0].RepoTags, []string{"app:slim"}) {
+			t.Fatalf("manifest.json = %s", files["manifest.json"])
+		}
+		if !bytes.Equal(files[manifest[0].Config], img.config) || !bytes.Equal(files[manifest[0].Layers[0]], img.layer) {
+			t.Error("tarball config or layer doesn't match the image")
+		}
+	})
+
+	t.Run("push", func(t *testing.T) {
+		dst, err := ParseReference(host+"/app:slim", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Push(context.Background(), dst, img); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		var m manifest
+		if err := json.Unmarshal(fake.manifests["slim"], &m); err != nil {
+			t.Fatalf("pushed manifest: %v", err)
+		}
+		if len(m.Layers) != 1 || fake.blobs[m.Config.Digest] == nil || fake.blobs[m.Layers[0].Digest] == nil {
+			t.Fatalf("pushed manifest = %s, missing blobs", fake.manifests["slim"])
+		}
+
+		// The pushed image reads back like the source
+		pulled, err := c.Slim(context.Background(), dst, SlimOptions{Keep: []string{"/"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pulled.layer, img.layer) {
+			t.Error("pushed image's files differ")
+		}
+	})
+
+	if _, err := c.Slim(context.Background(), ref, SlimOptions{Keep: []string{"["}}); err == nil {
+		t.Error("Slim with an invalid glob succeeded")
+	}
+}
@@ -0,0 +1,127 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ContainerFiles is the response to GET /containers/{name}/files.
+type ContainerFiles struct {
+	Name           string   `json:"name"`
+	PodName        string   `json:"pod_name,omitempty"`
+	PodNamespace   string   `json:"pod_namespace,omitempty"`
+	Files          []string `json:"files"`
+	UniqueFiles    int      `json:"unique_files"`
+	FilesTruncated int      `json:"files_truncated,omitempty"`
+}
+
+// ContainerPackages is one container's entry in the response to
+// GET /packages.
+type ContainerPackages struct {
+	Name           string          `json:"name"`
+	PodName        string          `json:"pod_name,omitempty"`
+	PodNamespace   string          `json:"pod_namespace,omitempty"`
+	Packages       []PackageReport `json:"packages,omitempty"`
+	PythonPackages []PackageReport `json:"python_packages,omitempty"`
+	NpmPackages    []PackageReport `json:"npm_packages,omitempty"`
+}
+
+// NewAPIHandler returns a handler serving live report state as JSON:
+//
+//	GET /report                  the whole report
+//	GET /containers/{name}/files a container's accessed files
+//	GET /packages                package usage per container
+//
+// snapshot is called for each request, so responses reflect everything
+// recorded so far rather than the last report written. In node mode, where
+// container names repeat across pods, ?pod=name selects a pod's container.
+// GET /packages?used=true omits packages none of whose files were accessed.
+func NewAPIHandler(snapshot func() *Report) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /report", func(w http.ResponseWriter, r *http.Request) {
+		report := *snapshot()
+		report.SchemaVersion = SchemaVersion
+		writeAPIResponse(w, &report)
+	})
+	mux.HandleFunc("GET /containers/{name}/files", func(w http.ResponseWriter, r *http.Request) {
+		name, pod := r.PathValue("name"), r.URL.Query().Get("pod")
+		var matches []ContainerReport
+		for _, c := range snapshot().Containers {
+			if c.Name == name && (pod == "" || c.PodName == pod) {
+				matches = append(matches, c)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			http.Error(w, fmt.Sprintf("container %q not found", name), http.StatusNotFound)
+			return
+		case 1:
+		default:
+			http.Error(w, fmt.Sprintf("%d containers are named %q; select one with ?pod=", len(matches), name), http.StatusConflict)
+			return
+		}
+		c := matches[0]
+		files := c.Files
+		if files == nil {
+			files = []string{}
+		}
+		writeAPIResponse(w, ContainerFiles{
+			Name:           c.Name,
+			PodName:        c.PodName,
+			PodNamespace:   c.PodNamespace,
+			Files:          files,
+			UniqueFiles:    c.UniqueFiles,
+			FilesTruncated: c.FilesTruncated,
+		})
+	})
+	mux.HandleFunc("GET /packages", func(w http.ResponseWriter, r *http.Request) {
+		usedOnly := false
+		if v := r.URL.Query().Get("used"); v != "" {
+			var err error
+			if usedOnly, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, fmt.Sprintf("invalid used=%q", v), http.StatusBadRequest)
+				return
+			}
+		}
+		filter := func(pkgs []PackageReport) []PackageReport {
+			if !usedOnly {
+				return pkgs
+			}
+			var used []PackageReport
+			for _, p := range pkgs {
+				if p.Used() {
+					used = append(used, p)
+				}
+			}
+			return used
+		}
+		containers := []ContainerPackages{}
+		for _, c := range snapshot().Containers {
+			containers = append(containers, ContainerPackages{
+				Name:           c.Name,
+				PodName:        c.PodName,
+				PodNamespace:   c.PodNamespace,
+				Packages:       filter(c.Packages),
+				PythonPackages: filter(c.PythonPackages),
+				NpmPackages:    filter(c.NpmPackages),
+			})
+		}
+		writeAPIResponse(w, struct {
+			Containers []ContainerPackages `json:"containers"`
+		}{containers})
+	})
+	return mux
+}
+
+// writeAPIResponse writes v as indented JSON.
+func writeAPIResponse(w http.ResponseWriter, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,79 @@
+package reporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestAPIHandler(t *testing.T) {
+	calls := 0
+	h := NewAPIHandler(func() *Report {
+		calls++
+		return &Report{
+			NodeName: "node-1",
+			Containers: []ContainerReport{
+				{Name: "app", PodName: "web-1", Files: []string{"/bin/app"}, UniqueFiles: 1, Packages: []PackageReport{
+					{Name: "musl", Manager: "apk", TotalFiles: 2, AccessedFiles: 1},
+					{Name: "curl", Manager: "apk", TotalFiles: 3},
+				}},
+				{Name: "app", PodName: "web-2", Files: []string{"/bin/app", "/etc/hosts"}, UniqueFiles: 2},
+				{Name: "sidecar", PodName: "web-1"},
+			},
+		}
+	})
+	get := func(target string, want int, v any) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != want {
+			t.Fatalf("GET %s = %d %s, want %d", target, rec.Code, rec.Body, want)
+		}
+		if v != nil {
+			if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+				t.Fatalf("GET %s: %v", target, err)
+			}
+		}
+	}
+
+	// The report decodes like one written to a file
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+	report, err := Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("decoding GET /report: %v", err)
+	}
+	if report.NodeName != "node-1" || len(report.Containers) != 3 {
+		t.Errorf("GET /report = %+v", report)
+	}
+
+	var files ContainerFiles
+	get("/containers/app/files?pod=web-2", http.StatusOK, &files)
+	if files.PodName != "web-2" || !slices.Equal(files.Files, []string{"/bin/app", "/etc/hosts"}) {
+		t.Errorf("GET /containers/app/files?pod=web-2 = %+v", files)
+	}
+	get("/containers/sidecar/files", http.StatusOK, &files)
+	if files.Files == nil {
+		t.Error("empty file list encoded as null")
+	}
+	get("/containers/app/files", http.StatusConflict, nil)
+	get("/containers/missing/files", http.StatusNotFound, nil)
+
+	var pkgs struct{ Containers []ContainerPackages }
+	get("/packages?used=true", http.StatusOK, &pkgs)
+	if len(pkgs.Containers) != 3 || len(pkgs.Containers[0].Packages) != 1 || pkgs.Containers[0].Packages[0].Name != "musl" {
+		t.Errorf("GET /packages?used=true = %+v", pkgs)
+	}
+	get("/packages", http.StatusOK, &pkgs)
+	if len(pkgs.Containers[0].Packages) != 2 {
+		t.Errorf("GET /packages = %+v", pkgs)
+	}
+	get("/packages?used=maybe", http.StatusBadRequest, nil)
+
+	// Each request sees a fresh snapshot
+	if calls != 7 {
+		t.Errorf("snapshot called %d times, want 7", calls)
+	}
+}
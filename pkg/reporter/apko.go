@@ -0,0 +1,106 @@
+package reporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ApkoOptions configures the apko configuration written by WriteApkoConfig.
+type ApkoOptions struct {
+	Repositories []string
+	Keyrings     []string
+	Archs        []string
+}
+
+// ApkoPackages returns the minimal set of apk packages to request so that
+// every used apk package is installed: used packages that aren't already
+// pulled in as a dependency of another used package. apk resolves the rest.
+func ApkoPackages(pkgs []PackageReport) []string {
+	byName := make(map[string]PackageReport)
+	for _, p := range pkgs {
+		if p.Manager == "apk" {
+			byName[p.Name] = p
+		}
+	}
+
+	// Everything reachable from a used package through its dependencies
+	pulledIn := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		for _, dep := range byName[name].Depends {
+			if !pulledIn[dep] {
+				pulledIn[dep] = true
+				visit(dep)
+			}
+		}
+	}
+	for name, p := range byName {
+		if p.Used() {
+			visit(name)
+		}
+	}
+
+	var result []string
+	for name, p := range byName {
+		if p.Used() && !pulledIn[name] {
+			result = append(result, name)
+		}
+	}
+	// Dependency cycles among used packages leave no root; keep them all
+	if len(result) == 0 {
+		for name, p := range byName {
+			if p.Used() {
+				result = append(result, name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// WriteApkoConfig writes an apko configuration that installs only the apk
+// packages the container used, plus whatever they depend on. Entrypoint,
+// accounts, and other image settings aren't observable and must be copied
+// from the original image configuration.
+func WriteApkoConfig(w io.Writer, c ContainerReport, opts ApkoOptions) error {
+	pkgs := ApkoPackages(c.Packages)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("container %s has no used apk packages; was it traced with -packages?", c.Name)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Generated by snoop from observed usage of container %s", c.Name)
+	if c.ImageRef != "" {
+		fmt.Fprintf(bw, " (%s)", c.ImageRef)
+	}
+	fmt.Fprintf(bw, ".\n# Add the entrypoint, accounts, and environment from the original image.\n")
+	fmt.Fprintf(bw, "contents:\n")
+	writeYAMLList(bw, "  ", "repositories", opts.Repositories)
+	writeYAMLList(bw, "  ", "keyring", opts.Keyrings)
+	writeYAMLList(bw, "  ", "packages", pkgs)
+	writeYAMLList(bw, "", "archs", opts.Archs)
+	return bw.Flush()
+}
+
+// writeYAMLList writes a YAML block sequence, omitting it if empty.
+func writeYAMLList(w io.Writer, indent, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s%s:\n", indent, key)
+	for _, v := range values {
+		fmt.Fprintf(w, "%s  - %s\n", indent, yamlString(v))
+	}
+}
+
+// yamlString quotes s if it could otherwise be misread as YAML syntax.
+func yamlString(s string) string {
+	if s == "" || strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`") || strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package reporter
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestApkoPackages(t *testing.T) {
+	pkgs := []PackageReport{
+		{Name: "app", Manager: "apk", AccessedFiles: 2, Depends: []string{"libssl", "musl"}},
+		{Name: "libssl", Manager: "apk", AccessedFiles: 1, Depends: []string{"musl"}},
+		{Name: "musl", Manager: "apk", AccessedFiles: 1},
+		{Name: "ca-certificates-bundle", Manager: "apk", AccessedFiles: 1},
+		{Name: "curl", Manager: "apk", Depends: []string{"musl"}},
+		{Name: "vim", Manager: "dpkg", AccessedFiles: 1},
+	}
+	want := []string{"app", "ca-certificates-bundle"}
+	if got := ApkoPackages(pkgs); !slices.Equal(got, want) {
+		t.Errorf("ApkoPackages() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteApkoConfig(t *testing.T) {
+	c := ContainerReport{
+		Name:     "app",
+		ImageRef: "cgr.dev/example/app:latest",
+		Packages: []PackageReport{
+			{Name: "app", Manager: "apk", AccessedFiles: 1},
+			{Name: "unused", Manager: "apk"},
+		},
+	}
+	var buf bytes.Buffer
+	err := WriteApkoConfig(&buf, c, ApkoOptions{
+		Repositories: []string{"https://packages.wolfi.dev/os"},
+		Keyrings:     []string{"https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"},
+		Archs:        []string{"x86_64"},
+	})
+	if err != nil {
+		t.Fatalf("WriteApkoConfig failed: %v", err)
+	}
+	want := `# Generated by snoop from observed usage of container app (cgr.dev/example/app:latest).
+# Add the entrypoint, accounts, and environment from the original image.
+contents:
+  repositories:
+    - "https://packages.wolfi.dev/os"
+  keyring:
+    - "https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"
+  packages:
+    - app
+archs:
+  - x86_64
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteApkoConfig() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteApkoConfigNoPackages(t *testing.T) {
+	c := ContainerReport{Name: "app", Packages: []PackageReport{{Name: "vim", Manager: "dpkg", AccessedFiles: 1}}}
+	if err := WriteApkoConfig(&bytes.Buffer{}, c, ApkoOptions{}); err == nil {
+		t.Error("expected error for a container without apk packages")
+	}
+}
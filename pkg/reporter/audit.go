@@ -0,0 +1,215 @@
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// Kinds of audit records.
+const (
+	// AuditStart is written each time snoop opens the log.
+	AuditStart = "start"
+	// AuditFile records a container's first access to a file.
+	AuditFile = "file"
+	// AuditReport records a container's totals on a report update.
+	AuditReport = "report"
+)
+
+// AuditRecord is one line of an audit log. Prev is the hex sha256 digest of
+// the previous line, so altering, removing, or reordering any record
+// breaks the chain at the record following it.
+type AuditRecord struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+
+	PodName   string `json:"pod_name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Container string `json:"container,omitempty"`
+	Path      string `json:"path,omitempty"`
+
+	UniqueFiles int    `json:"unique_files,omitempty"`
+	TotalEvents uint64 `json:"total_events,omitempty"`
+
+	Prev string `json:"prev"`
+}
+
+// AuditHead describes the end of a verified audit log.
+type AuditHead struct {
+	// Records is how many records the log holds, and Hash the digest of the
+	// last one, which the next record's Prev must match. Keeping a copy of
+	// Hash elsewhere allows records later removed from the end to be
+	// detected; see VerifyAudit.
+	Records uint64 `json:"records"`
+	Hash    string `json:"hash"`
+}
+
+// VerifyAudit checks that every record of an audit log is intact and chained
+// to the one before it, returning where the log ends or an error naming the
+// first line that isn't. It also checks that the log still holds the records
+// whose hashes are anchors, such as an earlier Hash of its head.
+func VerifyAudit(r io.Reader, anchors ...string) (AuditHead, error) {
+	var head AuditHead
+	missing := make(map[string]bool, len(anchors))
+	for _, hash := range anchors {
+		missing[hash] = true
+	}
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				return head, fmt.Errorf("line %d: incomplete record", line)
+			}
+			for _, hash := range anchors {
+				if missing[hash] {
+					return head, fmt.Errorf("no record has hash %s; records may have been removed from the end", hash)
+				}
+			}
+			return head, nil
+		} else if err != nil {
+			return head, err
+		}
+		data = bytes.TrimSuffix(data, []byte("\n"))
+
+		var rec AuditRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return head, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Seq != head.Records+1 {
+			return head, fmt.Errorf("line %d: sequence number %d, want %d", line, rec.Seq, head.Records+1)
+		}
+		if rec.Prev != head.Hash {
+			return head, fmt.Errorf("line %d: previous record's hash doesn't match", line)
+		}
+		sum := sha256.Sum256(data)
+		head = AuditHead{Records: rec.Seq, Hash: hex.EncodeToString(sum[:])}
+		delete(missing, head.Hash)
+	}
+}
+
+// VerifyAuditFile verifies the audit log at path; see VerifyAudit.
+func VerifyAuditFile(path string, anchors ...string) (AuditHead, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return AuditHead{}, err
+	}
+	defer f.Close()
+	return VerifyAudit(f, anchors...)
+}
+
+// AuditLog is a Reporter that appends a hash-chained JSON record to a file
+// for each newly accessed file and, on every report update, for each
+// container's totals. Records are never rewritten, so what was collected
+// can be shown not to have been altered since with VerifyAudit.
+type AuditLog struct {
+	ctx context.Context
+	now func() time.Time
+
+	mu   sync.Mutex
+	f    *os.File
+	head AuditHead
+	err  error // the first write error, after which nothing is written
+}
+
+// NewAuditLog opens the audit log at path, creating it if needed. An existing
+// log is verified and continued, and one that fails verification is an
+// error rather than being appended to.
+func NewAuditLog(ctx context.Context, path, podName, namespace string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	head, err := VerifyAudit(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("verifying audit log %s: %w", path, err)
+	}
+	a := &AuditLog{ctx: ctx, now: time.Now, f: f, head: head}
+	if err := a.append(AuditRecord{Kind: AuditStart, PodName: podName, Namespace: namespace}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	clog.FromContext(ctx).Infof("Appending audit records to %s after %d existing records", path, head.Records)
+	return a, nil
+}
+
+// FileAccessed records container's first access to path.
+func (a *AuditLog) FileAccessed(container, path string) error {
+	return a.append(AuditRecord{Kind: AuditFile, Container: container, Path: path})
+}
+
+// Update records each container's totals and flushes the log to disk.
+func (a *AuditLog) Update(ctx context.Context, report *Report) error {
+	for _, c := range report.Containers {
+		if err := a.append(AuditRecord{
+			Kind:        AuditReport,
+			PodName:     c.PodName,
+			Namespace:   c.PodNamespace,
+			Container:   c.Name,
+			UniqueFiles: c.UniqueFiles,
+			TotalEvents: c.TotalEvents,
+		}); err != nil {
+			return err
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.f.Sync(); err != nil {
+		return fmt.Errorf("syncing audit log: %w", err)
+	}
+	clog.FromContext(ctx).Debugf("Audit log has %d records, ending with %s", a.head.Records, a.head.Hash)
+	return nil
+}
+
+// Head returns where the log currently ends.
+func (a *AuditLog) Head() AuditHead {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.head
+}
+
+// Close flushes and closes the log.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return errors.Join(a.f.Sync(), a.f.Close())
+}
+
+// append chains rec to the last record and writes it as one line. Once a
+// write fails, the log may end in a partial record, so later records are
+// refused rather than appended after it.
+func (a *AuditLog) append(rec AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+	rec.Seq = a.head.Records + 1
+	rec.Time = a.now().UTC()
+	rec.Prev = a.head.Hash
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := a.f.Write(append(data, '\n')); err != nil {
+		a.err = fmt.Errorf("writing audit log: %w", err)
+		clog.FromContext(a.ctx).Errorf("Audit log stopped: %v", err)
+		return a.err
+	}
+	sum := sha256.Sum256(data)
+	a.head = AuditHead{Records: rec.Seq, Hash: hex.EncodeToString(sum[:])}
+	return nil
+}
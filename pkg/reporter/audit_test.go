@@ -0,0 +1,122 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	a, err := NewAuditLog(ctx, path, "web-1", "default")
+	if err != nil {
+		t.Fatalf("NewAuditLog failed: %v", err)
+	}
+	for _, p := range []string{"/etc/passwd", "/bin/sh"} {
+		if err := a.FileAccessed("app", p); err != nil {
+			t.Fatalf("FileAccessed failed: %v", err)
+		}
+	}
+	report := &Report{Containers: []ContainerReport{{Name: "app", UniqueFiles: 2, TotalEvents: 9}}}
+	if err := a.Update(ctx, report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	head := a.Head()
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if head.Records != 4 {
+		t.Errorf("head = %+v, want 4 records", head)
+	}
+	if got, err := VerifyAuditFile(path); err != nil || got != head {
+		t.Fatalf("VerifyAuditFile = %+v, %v, want %+v", got, err, head)
+	}
+
+	// Reopening continues the chain
+	a, err = NewAuditLog(ctx, path, "web-1", "default")
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	if err := a.FileAccessed("app", "/etc/hosts"); err != nil {
+		t.Fatalf("FileAccessed failed: %v", err)
+	}
+	a.Close()
+	if got, err := VerifyAuditFile(path, head.Hash); err != nil || got.Records != 6 {
+		t.Fatalf("VerifyAuditFile after reopening = %+v, %v, want 6 records", got, err)
+	}
+}
+
+func TestVerifyAuditTampering(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := NewAuditLog(ctx, path, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		a.FileAccessed("app", p)
+	}
+	head := a.Head().Hash
+	a.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	lines = lines[:len(lines)-1] // after the last newline
+
+	for _, tt := range []struct {
+		desc    string
+		log     string
+		anchors []string
+		wantErr string
+	}{{
+		desc:    "altered record",
+		log:     strings.Join(lines[:2], "") + strings.Replace(lines[2], `"/b"`, `"/x"`, 1) + lines[3],
+		wantErr: "line 4: previous record's hash doesn't match",
+	}, {
+		desc:    "removed record",
+		log:     strings.Join(lines[:2], "") + lines[3],
+		wantErr: "line 3: sequence number 4, want 3",
+	}, {
+		desc:    "reordered records",
+		log:     lines[0] + lines[2] + lines[1] + lines[3],
+		wantErr: "line 2: sequence number 3, want 2",
+	}, {
+		desc:    "removed from the end",
+		log:     strings.Join(lines[:2], ""),
+		anchors: []string{head},
+		wantErr: "no record has hash " + head,
+	}, {
+		desc:    "partial record",
+		log:     strings.Join(lines, "") + `{"seq":5`,
+		wantErr: "line 5: incomplete record",
+	}, {
+		desc:    "not JSON",
+		log:     lines[0] + "garbage\n",
+		wantErr: "line 2:",
+	}} {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := VerifyAudit(strings.NewReader(tt.log), tt.anchors...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("VerifyAudit = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A tampered log isn't appended to
+	if err := os.WriteFile(path, []byte(lines[0]+lines[2]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAuditLog(ctx, path, "", ""); err == nil {
+		t.Error("NewAuditLog succeeded on a tampered log")
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, []byte(lines[0]+lines[2])) {
+		t.Errorf("tampered log was modified:\n%s", got)
+	}
+}
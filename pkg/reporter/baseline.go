@@ -0,0 +1,54 @@
+package reporter
+
+// Baseline is the set of files each container accessed in a previously
+// captured report, for flagging accesses outside it at runtime.
+type Baseline struct {
+	files map[string]map[string]struct{}
+}
+
+// NewBaseline returns the baseline of report's containers, matched by name.
+func NewBaseline(report *Report) *Baseline {
+	b := &Baseline{files: make(map[string]map[string]struct{}, len(report.Containers))}
+	for _, c := range report.Containers {
+		files := b.files[c.Name]
+		if files == nil {
+			files = make(map[string]struct{}, len(c.Files))
+			b.files[c.Name] = files
+		}
+		for _, f := range c.Files {
+			files[f] = struct{}{}
+		}
+	}
+	return b
+}
+
+// Covers reports whether the baseline has a container named container.
+func (b *Baseline) Covers(container string) bool {
+	_, ok := b.files[container]
+	return ok
+}
+
+// Violates reports whether path is outside the baseline of container.
+// Containers the baseline doesn't cover never violate it, since there is
+// nothing to compare them with.
+func (b *Baseline) Violates(container, path string) bool {
+	files, ok := b.files[container]
+	if !ok {
+		return false
+	}
+	_, ok = files[path]
+	return !ok
+}
+
+// Incomplete returns the names of the baseline's containers whose file
+// lists were truncated or lost files to eviction, whose files outside the
+// list will be flagged even if they were accessed when it was captured.
+func Incomplete(report *Report) []string {
+	var names []string
+	for _, c := range report.Containers {
+		if c.FilesTruncated > 0 || c.EvictedFiles > 0 {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,38 @@
+package reporter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	report := &Report{Containers: []ContainerReport{
+		{Name: "app", Files: []string{"/app", "/etc/hosts"}},
+		{Name: "sidecar", Files: []string{"/sidecar"}, FilesTruncated: 10},
+		// A restarted container's runs are merged by name
+		{Name: "app", Files: []string{"/lib/libc.so"}},
+	}}
+	b := NewBaseline(report)
+
+	for _, tt := range []struct {
+		container, path string
+		want            bool
+	}{
+		{"app", "/app", false},
+		{"app", "/lib/libc.so", false},
+		{"app", "/bin/sh", true},
+		{"app", "/sidecar", true},
+		{"sidecar", "/sidecar", false},
+		{"other", "/bin/sh", false},
+	} {
+		if got := b.Violates(tt.container, tt.path); got != tt.want {
+			t.Errorf("Violates(%q, %q) = %v, want %v", tt.container, tt.path, got, tt.want)
+		}
+	}
+	if !b.Covers("app") || b.Covers("other") {
+		t.Error("Covers: want app but not other")
+	}
+	if got := Incomplete(report); !slices.Equal(got, []string{"sidecar"}) {
+		t.Errorf("Incomplete = %v, want [sidecar]", got)
+	}
+}
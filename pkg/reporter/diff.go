@@ -0,0 +1,129 @@
+package reporter
+
+import "sort"
+
+// ContainerStatus describes how a container differs between two reports.
+type ContainerStatus string
+
+const (
+	// ContainerAdded means the container only appears in the new report.
+	ContainerAdded ContainerStatus = "added"
+	// ContainerRemoved means the container only appears in the old report.
+	ContainerRemoved ContainerStatus = "removed"
+	// ContainerChanged means the container appears in both reports with
+	// different files or used packages.
+	ContainerChanged ContainerStatus = "changed"
+)
+
+// ReportDiff describes the differences between two reports.
+type ReportDiff struct {
+	// Containers lists containers that differ, sorted by name.
+	// Containers with identical file sets and used packages are omitted.
+	Containers []ContainerDiff `json:"containers"`
+}
+
+// ContainerDiff describes the differences for a single container.
+type ContainerDiff struct {
+	Name         string          `json:"name"`
+	Status       ContainerStatus `json:"status"`
+	AddedFiles   []string        `json:"added_files,omitempty"`
+	RemovedFiles []string        `json:"removed_files,omitempty"`
+
+	// AddedPackages and RemovedPackages list packages (by name) that were
+	// used in only the new or only the old report, respectively.
+	AddedPackages   []string `json:"added_packages,omitempty"`
+	RemovedPackages []string `json:"removed_packages,omitempty"`
+}
+
+// Empty reports whether the two reports had no differences.
+func (d *ReportDiff) Empty() bool {
+	return len(d.Containers) == 0
+}
+
+// Diff compares two reports, matching containers by name, and returns the
+// files and used packages added and removed in each container going from
+// old to new.
+func Diff(old, new *Report) *ReportDiff {
+	oldByName := containersByName(old)
+	newByName := containersByName(new)
+
+	names := make(map[string]struct{})
+	for name := range oldByName {
+		names[name] = struct{}{}
+	}
+	for name := range newByName {
+		names[name] = struct{}{}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	diff := &ReportDiff{Containers: []ContainerDiff{}}
+	for _, name := range sorted {
+		o, inOld := oldByName[name]
+		n, inNew := newByName[name]
+
+		cd := ContainerDiff{Name: name}
+		switch {
+		case !inOld:
+			cd.Status = ContainerAdded
+		case !inNew:
+			cd.Status = ContainerRemoved
+		default:
+			cd.Status = ContainerChanged
+		}
+		cd.AddedFiles = difference(n.Files, o.Files)
+		cd.RemovedFiles = difference(o.Files, n.Files)
+		cd.AddedPackages = difference(usedPackages(n), usedPackages(o))
+		cd.RemovedPackages = difference(usedPackages(o), usedPackages(n))
+
+		if cd.Status == ContainerChanged && len(cd.AddedFiles) == 0 && len(cd.RemovedFiles) == 0 &&
+			len(cd.AddedPackages) == 0 && len(cd.RemovedPackages) == 0 {
+			continue
+		}
+		diff.Containers = append(diff.Containers, cd)
+	}
+	return diff
+}
+
+// containersByName indexes a report's containers by name.
+func containersByName(r *Report) map[string]ContainerReport {
+	result := make(map[string]ContainerReport)
+	if r == nil {
+		return result
+	}
+	for _, c := range r.Containers {
+		result[c.Name] = c
+	}
+	return result
+}
+
+// usedPackages returns the names of packages the container used.
+func usedPackages(c ContainerReport) []string {
+	var names []string
+	for _, p := range c.Packages {
+		if p.Used() {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// difference returns the sorted elements of a that are not in b.
+func difference(a, b []string) []string {
+	exclude := make(map[string]struct{}, len(b))
+	for _, s := range b {
+		exclude[s] = struct{}{}
+	}
+	var result []string
+	for _, s := range a {
+		if _, ok := exclude[s]; !ok {
+			result = append(result, s)
+			exclude[s] = struct{}{} // dedupe
+		}
+	}
+	sort.Strings(result)
+	return result
+}
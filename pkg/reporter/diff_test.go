@@ -0,0 +1,83 @@
+package reporter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := &Report{Containers: []ContainerReport{
+		{Name: "app", Files: []string{"/bin/app", "/etc/old.conf", "/lib/libc.so"}},
+		{Name: "legacy", Files: []string{"/bin/legacy"}},
+		{Name: "same", Files: []string{"/bin/same"}},
+	}}
+	new := &Report{Containers: []ContainerReport{
+		{Name: "app", Files: []string{"/bin/app", "/etc/new.conf", "/lib/libc.so"}},
+		{Name: "same", Files: []string{"/bin/same"}},
+		{Name: "sidecar", Files: []string{"/bin/sidecar"}},
+	}}
+
+	got := Diff(old, new)
+	if got.Empty() {
+		t.Fatal("expected differences")
+	}
+	if len(got.Containers) != 3 {
+		t.Fatalf("len(Containers) = %d, want 3 (identical container omitted): %+v", len(got.Containers), got.Containers)
+	}
+
+	app := got.Containers[0]
+	if app.Name != "app" || app.Status != ContainerChanged {
+		t.Errorf("Containers[0] = %s/%s, want app/changed", app.Name, app.Status)
+	}
+	if !slices.Equal(app.AddedFiles, []string{"/etc/new.conf"}) {
+		t.Errorf("app added = %v, want [/etc/new.conf]", app.AddedFiles)
+	}
+	if !slices.Equal(app.RemovedFiles, []string{"/etc/old.conf"}) {
+		t.Errorf("app removed = %v, want [/etc/old.conf]", app.RemovedFiles)
+	}
+
+	legacy := got.Containers[1]
+	if legacy.Name != "legacy" || legacy.Status != ContainerRemoved {
+		t.Errorf("Containers[1] = %s/%s, want legacy/removed", legacy.Name, legacy.Status)
+	}
+	if !slices.Equal(legacy.RemovedFiles, []string{"/bin/legacy"}) {
+		t.Errorf("legacy removed = %v, want [/bin/legacy]", legacy.RemovedFiles)
+	}
+
+	sidecar := got.Containers[2]
+	if sidecar.Name != "sidecar" || sidecar.Status != ContainerAdded {
+		t.Errorf("Containers[2] = %s/%s, want sidecar/added", sidecar.Name, sidecar.Status)
+	}
+	if !slices.Equal(sidecar.AddedFiles, []string{"/bin/sidecar"}) {
+		t.Errorf("sidecar added = %v, want [/bin/sidecar]", sidecar.AddedFiles)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	r := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/a", "/b"}}}}
+	if d := Diff(r, r); !d.Empty() {
+		t.Errorf("Diff of identical reports = %+v, want empty", d)
+	}
+}
+
+func TestDiffPackages(t *testing.T) {
+	old := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/a"}, Packages: []PackageReport{
+		{Name: "curl", AccessedFiles: 1},
+		{Name: "bash", AccessedFiles: 2},
+		{Name: "vim"},
+	}}}}
+	new := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/a"}, Packages: []PackageReport{
+		{Name: "curl", AccessedFiles: 3},
+		{Name: "bash"},
+		{Name: "vim", AccessedFiles: 1},
+	}}}}
+
+	d := Diff(old, new)
+	if len(d.Containers) != 1 {
+		t.Fatalf("Diff() = %+v, want one changed container", d)
+	}
+	c := d.Containers[0]
+	if c.Status != ContainerChanged || !slices.Equal(c.AddedPackages, []string{"vim"}) || !slices.Equal(c.RemovedPackages, []string{"bash"}) {
+		t.Errorf("container diff = %+v, want +vim -bash", c)
+	}
+}
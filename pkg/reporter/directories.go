@@ -0,0 +1,77 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// DefaultDirectoryDepth is how deep DirectoryUtilization goes by default:
+// top-level directories such as /usr and second-level ones such as
+// /usr/share.
+const DefaultDirectoryDepth = 2
+
+// DirectoryUtilization rolls cov's per-directory counts up to every
+// directory at most depth components deep, so that /usr/share counts all
+// the files below it, and returns them sorted by path. Files directly in /
+// are counted under /. Returns nil if cov is nil.
+func DirectoryUtilization(cov *Coverage, depth int) []DirectoryCoverage {
+	if cov == nil {
+		return nil
+	}
+	byPath := make(map[string]*DirectoryCoverage)
+	add := func(path string, d DirectoryCoverage) {
+		u := byPath[path]
+		if u == nil {
+			u = &DirectoryCoverage{Path: path}
+			byPath[path] = u
+		}
+		u.Files += d.Files
+		u.AccessedFiles += d.AccessedFiles
+		u.Bytes += d.Bytes
+		u.AccessedBytes += d.AccessedBytes
+	}
+	for _, d := range cov.Directories {
+		parts := strings.Split(strings.Trim(d.Path, "/"), "/")
+		if parts[0] == "" {
+			add("/", d)
+			continue
+		}
+		for i := 1; i <= depth && i <= len(parts); i++ {
+			add("/"+strings.Join(parts[:i], "/"), d)
+		}
+	}
+
+	result := make([]DirectoryCoverage, 0, len(byPath))
+	for _, u := range byPath {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// UnusedBytes returns the size of d's files that were never accessed.
+func (d DirectoryCoverage) UnusedBytes() int64 {
+	return d.Bytes - d.AccessedBytes
+}
+
+// BytePercent returns the share of d's bytes that were accessed, from 0 to
+// 100.
+func (d DirectoryCoverage) BytePercent() int {
+	if d.Bytes == 0 {
+		return 0
+	}
+	return int(d.AccessedBytes * 100 / d.Bytes)
+}
+
+// WriteDirectoryUtilization writes dirs as an aligned text table.
+func WriteDirectoryUtilization(w io.Writer, dirs []DirectoryCoverage) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "USED\tFILES\tSIZE\tUNUSED\tDIRECTORY\n")
+	for _, d := range dirs {
+		fmt.Fprintf(tw, "%d%%\t%d/%d\t%s\t%s\t%s\n", d.BytePercent(), d.AccessedFiles, d.Files, formatBytes(d.Bytes), formatBytes(d.UnusedBytes()), d.Path)
+	}
+	return tw.Flush()
+}
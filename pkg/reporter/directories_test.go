@@ -0,0 +1,57 @@
+package reporter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDirectoryUtilization(t *testing.T) {
+	cov := &Coverage{
+		Directories: []DirectoryCoverage{
+			{Path: "/", Files: 1, Bytes: 10},
+			{Path: "/app", Files: 2, AccessedFiles: 1, Bytes: 200, AccessedBytes: 150},
+			{Path: "/usr/lib", Files: 4, AccessedFiles: 2, Bytes: 1000, AccessedBytes: 600},
+			{Path: "/usr/share/doc/bash", Files: 3, Bytes: 300},
+			{Path: "/usr/share/locale/de/LC_MESSAGES", Files: 5, AccessedFiles: 1, Bytes: 500, AccessedBytes: 6},
+		},
+	}
+	want := []DirectoryCoverage{
+		{Path: "/", Files: 1, Bytes: 10},
+		{Path: "/app", Files: 2, AccessedFiles: 1, Bytes: 200, AccessedBytes: 150},
+		{Path: "/usr", Files: 12, AccessedFiles: 3, Bytes: 1800, AccessedBytes: 606},
+		{Path: "/usr/lib", Files: 4, AccessedFiles: 2, Bytes: 1000, AccessedBytes: 600},
+		{Path: "/usr/share", Files: 8, AccessedFiles: 1, Bytes: 800, AccessedBytes: 6},
+	}
+	got := DirectoryUtilization(cov, DefaultDirectoryDepth)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DirectoryUtilization() = %+v, want %+v", got, want)
+	}
+	if p := got[3].BytePercent(); p != 60 {
+		t.Errorf("/usr/lib percent = %d, want 60", p)
+	}
+
+	if got := DirectoryUtilization(cov, 1); len(got) != 3 || got[2].Path != "/usr" {
+		t.Errorf("DirectoryUtilization(depth 1) = %+v, want /, /app, and /usr", got)
+	}
+	if got := DirectoryUtilization(nil, DefaultDirectoryDepth); got != nil {
+		t.Errorf("DirectoryUtilization(nil) = %+v, want nil", got)
+	}
+}
+
+func TestWriteDirectoryUtilization(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDirectoryUtilization(&buf, []DirectoryCoverage{
+		{Path: "/usr/lib", Files: 4, AccessedFiles: 2, Bytes: 1000, AccessedBytes: 600},
+		{Path: "/usr/share", Files: 812, AccessedFiles: 3, Bytes: 30 << 20, AccessedBytes: 1 << 20},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := `USED  FILES  SIZE      UNUSED    DIRECTORY
+60%   2/4    1000 B    400 B     /usr/lib
+3%    3/812  30.0 MiB  29.0 MiB  /usr/share
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteDirectoryUtilization() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,480 @@
+package reporter
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormats are the formats Export writes.
+var ExportFormats = []string{"cyclonedx", "spdx", "csv", "md"}
+
+// Export writes report in format, one of ExportFormats.
+func Export(w io.Writer, report *Report, format string) error {
+	switch format {
+	case "cyclonedx":
+		return writeJSONDoc(w, cycloneDXBOM(report))
+	case "spdx":
+		doc, err := spdxDocument(report)
+		if err != nil {
+			return err
+		}
+		return writeJSONDoc(w, doc)
+	case "csv":
+		return WriteCSV(w, report)
+	case "md":
+		return WriteMarkdown(w, report)
+	default:
+		return fmt.Errorf("unknown export format %q (must be one of %s)", format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+func writeJSONDoc(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// sortedFiles returns a container's files in lexical order.
+func sortedFiles(c ContainerReport) []string {
+	files := append([]string(nil), c.Files...)
+	sort.Strings(files)
+	return files
+}
+
+// allPackages returns a container's OS and language packages, without the
+// "(orphan)" entry accounting for files no package owns.
+func allPackages(c ContainerReport) []PackageReport {
+	var pkgs []PackageReport
+	for _, list := range [][]PackageReport{c.Packages, c.PythonPackages, c.NpmPackages} {
+		for _, p := range list {
+			if p.Manager != "none" {
+				pkgs = append(pkgs, p)
+			}
+		}
+	}
+	return pkgs
+}
+
+// purl returns the package URL of a package, or "" if its manager has no
+// purl type.
+func purl(p PackageReport) string {
+	var typ, name string
+	switch p.Manager {
+	case "apk":
+		typ, name = "apk", purlEscape(p.Name)
+	case "dpkg":
+		typ, name = "deb", purlEscape(p.Name)
+	case "pip":
+		typ, name = "pypi", purlEscape(strings.ToLower(strings.ReplaceAll(p.Name, "_", "-")))
+	case "npm":
+		// Scoped packages keep the scope as the namespace
+		typ = "npm"
+		if scope, n, ok := strings.Cut(p.Name, "/"); ok {
+			name = purlEscape(scope) + "/" + purlEscape(n)
+		} else {
+			name = purlEscape(p.Name)
+		}
+	default:
+		return ""
+	}
+	s := "pkg:" + typ + "/" + name
+	if p.Version != "" {
+		s += "@" + purlEscape(p.Version)
+	}
+	if p.Arch != "" {
+		s += "?arch=" + url.QueryEscape(p.Arch)
+	}
+	return s
+}
+
+// purlEscape percent-encodes a purl component, including the '@' that
+// separates the version.
+func purlEscape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+// utilization describes how much of a package was accessed.
+func utilization(p PackageReport) string {
+	return fmt.Sprintf("%d of %d files accessed", p.AccessedFiles, p.TotalFiles)
+}
+
+// WriteCSV writes one row per accessed file, with the metadata, digest, and
+// layer the report records for it.
+func WriteCSV(w io.Writer, report *Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"container", "path", "size", "mode", "uid", "gid", "mtime", "digest", "layer"})
+	for _, c := range report.Containers {
+		for _, path := range sortedFiles(c) {
+			row := []string{c.Name, path, "", "", "", "", "", c.FileDigests[path], ""}
+			if md, ok := c.FileMetadata[path]; ok {
+				row[2] = strconv.FormatInt(md.Size, 10)
+				row[3] = md.Mode
+				row[4] = strconv.FormatUint(uint64(md.UID), 10)
+				row[5] = strconv.FormatUint(uint64(md.GID), 10)
+				row[6] = md.ModTime.UTC().Format(time.RFC3339)
+			}
+			if layer, ok := c.FileLayers[path]; ok {
+				row[8] = strconv.Itoa(layer)
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown writes a summary of report, with a section per container
+// listing its packages and files, for pasting into a review or issue.
+func WriteMarkdown(w io.Writer, report *Report) error {
+	bw := bufio.NewWriter(w)
+	title := report.PodName
+	if title == "" {
+		title = report.NodeName
+	}
+	if title == "" {
+		title = "report"
+	}
+	fmt.Fprintf(bw, "# snoop report: %s\n\n", mdEscape(title))
+	if report.Namespace != "" {
+		fmt.Fprintf(bw, "- Namespace: %s\n", mdEscape(report.Namespace))
+	}
+	fmt.Fprintf(bw, "- Recorded: %s to %s\n", report.StartedAt.UTC().Format(time.RFC3339), report.LastUpdatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(bw, "- Events: %d (%d dropped)\n", report.TotalEvents, report.DroppedEvents)
+	if s := report.Sampling; s != nil {
+		fmt.Fprintf(bw, "- Sampled: %d events skipped under backpressure, at up to 1 in %d\n", s.SkippedEvents, s.PeakRate)
+	}
+
+	containers := append([]ContainerReport(nil), report.Containers...)
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	for _, c := range containers {
+		fmt.Fprintf(bw, "\n## %s\n\n", mdEscape(c.Name))
+		if c.ImageRef != "" || c.ImageDigest != "" {
+			fmt.Fprintf(bw, "- Image: `%s`", c.ImageRef)
+			if c.ImageDigest != "" {
+				fmt.Fprintf(bw, " (`%s`)", c.ImageDigest)
+			}
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "- Files: %d unique, %d events\n", c.UniqueFiles, c.TotalEvents)
+		if c.FilesTruncated > 0 || c.EvictedFiles > 0 {
+			fmt.Fprintf(bw, "- Incomplete: %d files truncated, %d evicted\n", c.FilesTruncated, c.EvictedFiles)
+		}
+		if c.UnusedPackageBytes > 0 {
+			fmt.Fprintf(bw, "- Unused packages: %s\n", formatBytes(c.UnusedPackageBytes))
+		}
+		if c.UnusedFileBytes > 0 {
+			fmt.Fprintf(bw, "- Unused image files: %s\n", formatBytes(c.UnusedFileBytes))
+		}
+		if c.VolumeSummary != nil {
+			fmt.Fprintf(bw, "- Files by source: %s\n", formatVolumeSummary(c.VolumeSummary))
+		}
+		if cov := c.Coverage; cov != nil {
+			fmt.Fprintf(bw, "- Image coverage: %d of %d files, %s of %s\n", cov.AccessedFiles, cov.Files, formatBytes(cov.AccessedBytes), formatBytes(cov.Bytes))
+		}
+
+		if pkgs := allPackages(c); len(pkgs) > 0 {
+			fmt.Fprintf(bw, "\n### Packages\n\n| Package | Version | Manager | Files accessed | Used |\n|---|---|---|---:|---:|\n")
+			for _, p := range pkgs {
+				percent := 0
+				if p.TotalFiles > 0 {
+					percent = p.AccessedFiles * 100 / p.TotalFiles
+				}
+				fmt.Fprintf(bw, "| %s | %s | %s | %d / %d | %d%% |\n", mdEscape(p.Name), mdEscape(p.Version), p.Manager, p.AccessedFiles, p.TotalFiles, percent)
+			}
+		}
+
+		if len(c.Layers) > 0 {
+			fmt.Fprintf(bw, "\n### Layers\n\n| Layer | Digest | Files accessed | Size | Unused | Used |\n|---:|---|---:|---:|---:|---:|\n")
+			for _, l := range c.Layers {
+				if l.Upper {
+					continue
+				}
+				note := ""
+				if l.Unused() {
+					note = " (unused)"
+				}
+				fmt.Fprintf(bw, "| %d%s | `%s` | %d / %d | %s | %s | %d%% |\n", l.Index, note, shortDigest(l.Digest), l.AccessedFiles, l.TotalFiles, formatBytes(l.TotalBytes), formatBytes(l.UnusedBytes), l.BytePercent())
+			}
+		}
+
+		if dirs := DirectoryUtilization(c.Coverage, DefaultDirectoryDepth); len(dirs) > 0 {
+			fmt.Fprintf(bw, "\n### Directories\n\n| Directory | Files accessed | Size | Unused | Used |\n|---|---:|---:|---:|---:|\n")
+			for _, d := range dirs {
+				fmt.Fprintf(bw, "| %s | %d / %d | %s | %s | %d%% |\n", mdEscape(d.Path), d.AccessedFiles, d.Files, formatBytes(d.Bytes), formatBytes(d.UnusedBytes()), d.BytePercent())
+			}
+		}
+
+		fmt.Fprintf(bw, "\n### Files\n\n")
+		if len(c.Files) == 0 {
+			fmt.Fprintf(bw, "None recorded.\n")
+			continue
+		}
+		fmt.Fprintf(bw, "```\n")
+		for _, path := range sortedFiles(c) {
+			fmt.Fprintln(bw, path)
+		}
+		fmt.Fprintf(bw, "```\n")
+	}
+	return bw.Flush()
+}
+
+// mdEscape escapes characters with meaning in Markdown text and tables.
+func mdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "\n", " ").Replace(s)
+}
+
+// cdxBOM is a CycloneDX 1.5 JSON BOM.
+type cdxBOM struct {
+	BOMFormat   string         `json:"bomFormat"`
+	SpecVersion string         `json:"specVersion"`
+	Version     int            `json:"version"`
+	Metadata    cdxMetadata    `json:"metadata"`
+	Components  []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp  string         `json:"timestamp,omitempty"`
+	Tools      cdxTools       `json:"tools"`
+	Properties []cdxProperty  `json:"properties,omitempty"`
+	Component  *cdxComponent  `json:"component,omitempty"`
+	Lifecycles []cdxLifecycle `json:"lifecycles"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxLifecycle struct {
+	Phase string `json:"phase"`
+}
+
+type cdxComponent struct {
+	BOMRef     string         `json:"bom-ref,omitempty"`
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version,omitempty"`
+	PURL       string         `json:"purl,omitempty"`
+	Licenses   []cdxLicense   `json:"licenses,omitempty"`
+	Hashes     []cdxHash      `json:"hashes,omitempty"`
+	Properties []cdxProperty  `json:"properties,omitempty"`
+	Components []cdxComponent `json:"components,omitempty"`
+}
+
+type cdxLicense struct {
+	Expression string `json:"expression"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cycloneDXBOM describes each container as a component holding its
+// packages, with how much of each was used, and its accessed files.
+func cycloneDXBOM(report *Report) cdxBOM {
+	bom := cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Tools:      cdxTools{Components: []cdxComponent{{Type: "application", Name: "snoop"}}},
+			Lifecycles: []cdxLifecycle{{Phase: "operations"}},
+		},
+		Components: []cdxComponent{},
+	}
+	if !report.LastUpdatedAt.IsZero() {
+		bom.Metadata.Timestamp = report.LastUpdatedAt.UTC().Format(time.RFC3339)
+	}
+	for _, p := range [][2]string{{"snoop:pod", report.PodName}, {"snoop:namespace", report.Namespace}, {"snoop:node", report.NodeName}} {
+		if p[1] != "" {
+			bom.Metadata.Properties = append(bom.Metadata.Properties, cdxProperty{Name: p[0], Value: p[1]})
+		}
+	}
+
+	for _, c := range report.Containers {
+		cc := cdxComponent{
+			BOMRef:  "container:" + c.Name,
+			Type:    "container",
+			Name:    c.Name,
+			Version: c.ImageDigest,
+		}
+		if c.ImageRef != "" {
+			cc.Properties = append(cc.Properties, cdxProperty{Name: "snoop:image", Value: c.ImageRef})
+		}
+		for _, p := range allPackages(c) {
+			pc := cdxComponent{
+				BOMRef:  fmt.Sprintf("%s:%s:%s@%s", cc.BOMRef, p.Manager, p.Name, p.Version),
+				Type:    "library",
+				Name:    p.Name,
+				Version: p.Version,
+				PURL:    purl(p),
+				Properties: []cdxProperty{
+					{Name: "snoop:used", Value: strconv.FormatBool(p.Used())},
+					{Name: "snoop:accessed_files", Value: strconv.Itoa(p.AccessedFiles)},
+					{Name: "snoop:total_files", Value: strconv.Itoa(p.TotalFiles)},
+				},
+			}
+			if p.License != "" {
+				pc.Licenses = []cdxLicense{{Expression: p.License}}
+			}
+			cc.Components = append(cc.Components, pc)
+		}
+		for _, path := range sortedFiles(c) {
+			fc := cdxComponent{BOMRef: cc.BOMRef + ":file:" + path, Type: "file", Name: path}
+			if hex, ok := strings.CutPrefix(c.FileDigests[path], "sha256:"); ok {
+				fc.Hashes = []cdxHash{{Alg: "SHA-256", Content: hex}}
+			}
+			cc.Components = append(cc.Components, fc)
+		}
+		bom.Components = append(bom.Components, cc)
+	}
+	if len(bom.Components) == 1 {
+		// A single container is what the BOM describes
+		bom.Metadata.Component = &cdxComponent{BOMRef: "subject", Type: "container", Name: bom.Components[0].Name, Version: bom.Components[0].Version}
+	}
+	return bom
+}
+
+// spdxDoc is an SPDX 2.3 JSON document.
+type spdxDoc struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files,omitempty"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string       `json:"SPDXID"`
+	Name             string       `json:"name"`
+	VersionInfo      string       `json:"versionInfo,omitempty"`
+	DownloadLocation string       `json:"downloadLocation"`
+	FilesAnalyzed    bool         `json:"filesAnalyzed"`
+	LicenseDeclared  string       `json:"licenseDeclared,omitempty"`
+	ExternalRefs     []spdxExtRef `json:"externalRefs,omitempty"`
+	Comment          string       `json:"comment,omitempty"`
+	PrimaryPurpose   string       `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxExtRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxFile struct {
+	SPDXID    string         `json:"SPDXID"`
+	FileName  string         `json:"fileName"`
+	Checksums []spdxChecksum `json:"checksums"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+// spdxDocument describes each container as a package that contains its
+// packages and accessed files. The namespace is derived from the report's
+// contents, so exporting the same report twice gives the same document.
+func spdxDocument(report *Report) (spdxDoc, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return spdxDoc{}, err
+	}
+	name := report.PodName
+	if name == "" {
+		name = "snoop-report"
+	}
+	created := report.LastUpdatedAt
+	if created.IsZero() {
+		created = report.StartedAt
+	}
+	doc := spdxDoc{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://github.com/imjasonh/snoop/spdx/%s-%x", url.PathEscape(name), sha256.Sum256(data)),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: snoop"},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	for i, c := range report.Containers {
+		id := fmt.Sprintf("SPDXRef-Container-%d", i)
+		cp := spdxPackage{
+			SPDXID:           id,
+			Name:             c.Name,
+			VersionInfo:      c.ImageDigest,
+			DownloadLocation: "NOASSERTION",
+			PrimaryPurpose:   "CONTAINER",
+		}
+		if c.ImageRef != "" {
+			cp.Comment = "image " + c.ImageRef
+		}
+		doc.Packages = append(doc.Packages, cp)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{"SPDXRef-DOCUMENT", "DESCRIBES", id})
+
+		for j, p := range allPackages(c) {
+			pid := fmt.Sprintf("SPDXRef-Package-%d-%d", i, j)
+			sp := spdxPackage{
+				SPDXID:           pid,
+				Name:             p.Name,
+				VersionInfo:      p.Version,
+				DownloadLocation: "NOASSERTION",
+				LicenseDeclared:  p.License,
+				Comment:          utilization(p),
+			}
+			if sp.LicenseDeclared == "" {
+				sp.LicenseDeclared = "NOASSERTION"
+			}
+			if u := purl(p); u != "" {
+				sp.ExternalRefs = []spdxExtRef{{"PACKAGE-MANAGER", "purl", u}}
+			}
+			doc.Packages = append(doc.Packages, sp)
+			doc.Relationships = append(doc.Relationships, spdxRelationship{id, "CONTAINS", pid})
+		}
+		for j, path := range sortedFiles(c) {
+			fid := fmt.Sprintf("SPDXRef-File-%d-%d", i, j)
+			f := spdxFile{SPDXID: fid, FileName: "." + path, Checksums: []spdxChecksum{}}
+			if hex, ok := strings.CutPrefix(c.FileDigests[path], "sha256:"); ok {
+				f.Checksums = append(f.Checksums, spdxChecksum{"SHA256", hex})
+			}
+			doc.Files = append(doc.Files, f)
+			doc.Relationships = append(doc.Relationships, spdxRelationship{id, "CONTAINS", fid})
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,186 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportReport() *Report {
+	return &Report{
+		PodName:       "my-app",
+		Namespace:     "default",
+		StartedAt:     time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		LastUpdatedAt: time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC),
+		TotalEvents:   12,
+		Containers: []ContainerReport{{
+			Name:        "app",
+			ImageRef:    "cgr.dev/chainguard/python:latest",
+			ImageDigest: "sha256:img",
+			Files:       []string{"/usr/bin/python3", "/etc/a|b"},
+			UniqueFiles: 2,
+			FileMetadata: map[string]FileMetadata{
+				"/usr/bin/python3": {Size: 4096, Mode: "-rwxr-xr-x", ModTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+			FileDigests:   map[string]string{"/usr/bin/python3": "sha256:abc"},
+			FileLayers:    map[string]int{"/usr/bin/python3": 2},
+			VolumeFiles:   []VolumeFile{{Path: "/etc/a|b", Volume: "configMap", MountPoint: "/etc"}},
+			VolumeSummary: map[string]int{"image": 1, "configMap": 1},
+			Layers: []LayerReport{
+				{Index: 1, Digest: "sha256:0123456789abcdef", TotalFiles: 2, TotalBytes: 2048, UnusedBytes: 2048},
+				{Index: 2, Digest: "sha256:fedcba", AccessedFiles: 1, TotalFiles: 1, TotalBytes: 4096},
+				{Index: 3, Upper: true, AccessedFiles: 1},
+			},
+			Packages: []PackageReport{
+				{Name: "python-3.12", Version: "3.12.1-r0", Manager: "apk", Arch: "x86_64", License: "PSF-2.0", TotalFiles: 10, AccessedFiles: 1},
+				{Name: "(orphan)", Manager: "none", AccessedFiles: 1},
+			},
+			PythonPackages: []PackageReport{{Name: "Flask_Login", Version: "0.6.3", Manager: "pip", TotalFiles: 4}},
+			NpmPackages:    []PackageReport{{Name: "@types/node", Version: "20.0.0", Manager: "npm", TotalFiles: 2, AccessedFiles: 2}},
+			Coverage: &Coverage{Files: 3, AccessedFiles: 1, Bytes: 6144, AccessedBytes: 4096, Directories: []DirectoryCoverage{
+				{Path: "/usr/bin", Files: 1, AccessedFiles: 1, Bytes: 4096, AccessedBytes: 4096},
+				{Path: "/usr/share/doc", Files: 2, Bytes: 2048},
+			}},
+		}},
+	}
+}
+
+func TestPURL(t *testing.T) {
+	c := exportReport().Containers[0]
+	for i, want := range []string{
+		"pkg:apk/python-3.12@3.12.1-r0?arch=x86_64",
+		"pkg:pypi/flask-login@0.6.3",
+		"pkg:npm/%40types/node@20.0.0",
+	} {
+		if got := purl(allPackages(c)[i]); got != want {
+			t.Errorf("purl = %q, want %q", got, want)
+		}
+	}
+	if got := purl(PackageReport{Name: "x", Manager: "sbom"}); got != "" {
+		t.Errorf("purl of an unknown manager = %q, want empty", got)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, exportReport(), "csv"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"container", "path", "size", "mode", "uid", "gid", "mtime", "digest", "layer"},
+		{"app", "/etc/a|b", "", "", "", "", "", "", ""},
+		{"app", "/usr/bin/python3", "4096", "-rwxr-xr-x", "0", "0", "2024-01-01T00:00:00Z", "sha256:abc", "2"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %q, want %q", rows, want)
+	}
+	for i := range want {
+		if strings.Join(rows[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d = %q, want %q", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, exportReport(), "md"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# snoop report: my-app\n",
+		"- Image: `cgr.dev/chainguard/python:latest` (`sha256:img`)\n",
+		"| python-3.12 | 3.12.1-r0 | apk | 1 / 10 | 10% |\n",
+		"| Flask\\_Login | 0.6.3 | pip | 0 / 4 | 0% |\n",
+		"```\n/etc/a|b\n/usr/bin/python3\n```\n",
+		"- Image coverage: 1 of 3 files, 4.0 KiB of 6.0 KiB\n",
+		"- Files by source: image 1, configMap 1\n",
+		"| /usr | 1 / 3 | 6.0 KiB | 2.0 KiB | 66% |\n",
+		"| /usr/share | 0 / 2 | 2.0 KiB | 2.0 KiB | 0% |\n",
+		"| 1 (unused) | `sha256:0123456789ab` | 0 / 2 | 2.0 KiB | 2.0 KiB | 0% |\n",
+		"| 2 | `sha256:fedcba` | 1 / 1 | 4.0 KiB | 0 B | 100% |\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "(orphan)") {
+		t.Error("markdown lists the orphan entry as a package")
+	}
+}
+
+func TestExportCycloneDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, exportReport(), "cyclonedx"); err != nil {
+		t.Fatal(err)
+	}
+	var bom cdxBOM
+	if err := json.Unmarshal(buf.Bytes(), &bom); err != nil {
+		t.Fatal(err)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.Metadata.Timestamp != "2024-01-15T11:00:00Z" || bom.Metadata.Component == nil {
+		t.Errorf("BOM header = %+v", bom.Metadata)
+	}
+	if len(bom.Components) != 1 || bom.Components[0].Type != "container" {
+		t.Fatalf("components = %+v, want one container", bom.Components)
+	}
+	var libs, files int
+	for _, c := range bom.Components[0].Components {
+		switch c.Type {
+		case "library":
+			libs++
+			if c.Name == "python-3.12" && (len(c.Licenses) != 1 || c.Licenses[0].Expression != "PSF-2.0") {
+				t.Errorf("python licenses = %+v", c.Licenses)
+			}
+		case "file":
+			files++
+			if c.Name == "/usr/bin/python3" && (len(c.Hashes) != 1 || c.Hashes[0].Content != "abc") {
+				t.Errorf("python3 hashes = %+v", c.Hashes)
+			}
+		}
+	}
+	if libs != 3 || files != 2 {
+		t.Errorf("got %d libraries and %d files, want 3 and 2", libs, files)
+	}
+}
+
+func TestExportSPDX(t *testing.T) {
+	export := func() []byte {
+		var buf bytes.Buffer
+		if err := Export(&buf, exportReport(), "spdx"); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	out := export()
+	if !bytes.Equal(out, export()) {
+		t.Error("exporting the same report twice gave different documents")
+	}
+	var doc spdxDoc
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || !strings.HasPrefix(doc.DocumentNamespace, "https://github.com/imjasonh/snoop/spdx/my-app-") {
+		t.Errorf("document header = %s %s", doc.SPDXVersion, doc.DocumentNamespace)
+	}
+	// The container, three packages, and two files
+	if len(doc.Packages) != 4 || len(doc.Files) != 2 || len(doc.Relationships) != 6 {
+		t.Errorf("got %d packages, %d files, %d relationships; want 4, 2, 6", len(doc.Packages), len(doc.Files), len(doc.Relationships))
+	}
+	if p := doc.Packages[1]; p.Comment != "1 of 10 files accessed" || len(p.ExternalRefs) != 1 {
+		t.Errorf("package = %+v", p)
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	if err := Export(&bytes.Buffer{}, exportReport(), "xml"); err == nil {
+		t.Error("Export to an unknown format succeeded")
+	}
+}
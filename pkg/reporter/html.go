@@ -0,0 +1,115 @@
+package reporter
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+)
+
+//go:embed templates/report.html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes":   formatBytes,
+	"volumes": formatVolumeSummary,
+}).Parse(htmlTemplateText))
+
+// htmlFile is one row of a container's file table.
+type htmlFile struct {
+	Path     string
+	Metadata *FileMetadata
+	Digest   string
+}
+
+// htmlPackage is one row of a container's package table.
+type htmlPackage struct {
+	PackageReport
+	Percent int // AccessedFiles as a percentage of TotalFiles
+}
+
+// htmlContainer is one container section of the HTML report.
+type htmlContainer struct {
+	ContainerReport
+	Rows        []htmlFile
+	PackageRows []htmlPackage
+	PythonRows  []htmlPackage
+	NpmRows     []htmlPackage
+	DirRows     []DirectoryCoverage // Coverage rolled up by DirectoryUtilization
+
+	// UnusedLayers are the image layers that provided no accessed files,
+	// and UnusedLayerBytes their size.
+	UnusedLayers     []LayerReport
+	UnusedLayerBytes int64
+	HasMetadata      bool
+	HasDigests       bool
+}
+
+// RenderHTML writes report as a self-contained HTML page with sortable file
+// and package utilization tables per container. The page has no external dependencies so it can be
+// attached to a review or opened offline.
+func RenderHTML(w io.Writer, report *Report) error {
+	containers := make([]htmlContainer, 0, len(report.Containers))
+	for _, c := range report.Containers {
+		hc := htmlContainer{
+			ContainerReport: c,
+			Rows:            make([]htmlFile, 0, len(c.Files)),
+			HasMetadata:     len(c.FileMetadata) > 0,
+			HasDigests:      len(c.FileDigests) > 0,
+		}
+		files := append([]string(nil), c.Files...)
+		sort.Strings(files)
+		for _, path := range files {
+			row := htmlFile{Path: path, Digest: c.FileDigests[path]}
+			if md, ok := c.FileMetadata[path]; ok {
+				row.Metadata = &md
+			}
+			hc.Rows = append(hc.Rows, row)
+		}
+		hc.PackageRows = packageRows(c.Packages)
+		hc.PythonRows = packageRows(c.PythonPackages)
+		hc.NpmRows = packageRows(c.NpmPackages)
+		hc.DirRows = DirectoryUtilization(c.Coverage, DefaultDirectoryDepth)
+		hc.UnusedLayers, hc.UnusedLayerBytes = UnusedLayers(c.Layers)
+		containers = append(containers, hc)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Name < containers[j].Name
+	})
+
+	if err := htmlTemplate.Execute(w, struct {
+		*Report
+		Containers []htmlContainer
+	}{report, containers}); err != nil {
+		return fmt.Errorf("rendering HTML report: %w", err)
+	}
+	return nil
+}
+
+// packageRows computes utilization for each package.
+func packageRows(pkgs []PackageReport) []htmlPackage {
+	rows := make([]htmlPackage, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		row := htmlPackage{PackageReport: pkg}
+		if pkg.TotalFiles > 0 {
+			row.Percent = pkg.AccessedFiles * 100 / pkg.TotalFiles
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "83.2 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
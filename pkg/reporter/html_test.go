@@ -0,0 +1,101 @@
+package reporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderHTML(t *testing.T) {
+	report := &Report{
+		PodName:   "my-app",
+		Namespace: "default",
+		StartedAt: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		Containers: []ContainerReport{
+			{
+				Name:        "nginx",
+				ImageRef:    "nginx:1.25",
+				Files:       []string{"/etc/nginx/nginx.conf", "/usr/sbin/nginx"},
+				UniqueFiles: 2,
+				FileMetadata: map[string]FileMetadata{
+					"/usr/sbin/nginx": {Size: 1234, Mode: "-rwxr-xr-x"},
+				},
+				FileDigests: map[string]string{"/usr/sbin/nginx": "sha256:abc"},
+				Packages: []PackageReport{
+					{Name: "nginx-core", Version: "1.25.3", Manager: "dpkg", TotalFiles: 4, AccessedFiles: 1, AccessCount: 9},
+				},
+				PythonPackages: []PackageReport{
+					{Name: "requests", Version: "2.31.0", Manager: "pip", TotalFiles: 10, AccessedFiles: 5},
+				},
+				PythonModules: []string{"requests.adapters"},
+				Jars:          []JarReport{{Path: "/app/lib/unused.jar", OnClasspath: true}},
+				Layers: []LayerReport{
+					{Index: 1, Digest: "sha256:base", AccessedFiles: 2, TotalFiles: 40, TotalBytes: 3 << 20, UnusedBytes: 3 << 19},
+					{Index: 2, Digest: "sha256:docs", TotalFiles: 3, TotalBytes: 1 << 20, UnusedBytes: 1 << 20},
+				},
+				UnusedFileBytes: 3 << 19,
+				Coverage: &Coverage{Files: 40, AccessedFiles: 2, Bytes: 3 << 20, AccessedBytes: 3 << 19,
+					Directories: []DirectoryCoverage{
+						{Path: "/usr/sbin", Files: 1, AccessedFiles: 1, Bytes: 3 << 19, AccessedBytes: 3 << 19},
+						{Path: "/usr/share/doc/nginx", Files: 39, Bytes: 3 << 19},
+					},
+					LargestUnusedFiles: []ImageFile{{Path: "/usr/share/doc/nginx/changelog.gz", Size: 1 << 20}}},
+			},
+			{Name: "sidecar", Files: []string{"/<script>alert(1)</script>"}, EvictedFiles: 7, UnownedExecutables: []string{"/tmp/miner"},
+				UnobservedLibraries: []LibraryDependency{{Name: "libgone.so.1", RequiredBy: []string{"/tmp/miner"}}},
+				VolumeFiles:         []VolumeFile{{Path: "/cache/index", Volume: "emptyDir", MountPoint: "/cache"}},
+				VolumeSummary:       map[string]int{"image": 1, "emptyDir": 1}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, report); err != nil {
+		t.Fatalf("RenderHTML failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"<title>snoop report: my-app</title>",
+		"<h2>nginx</h2>",
+		"nginx:1.25",
+		"/etc/nginx/nginx.conf",
+		`data-sort="1234"`,
+		"sha256:abc",
+		"nginx-core",
+		"width: 25%",
+		"Python packages",
+		"<li><code>requests.adapters</code></li>",
+		"<td><code>/app/lib/unused.jar</code></td><td>unused</td><td>yes</td>",
+		"sha256:base",
+		"never accessed take up <strong>1.5 MiB</strong>",
+		"width: 50%",
+		"(unused)",
+		"Accessed files by source: image 1, emptyDir 1.",
+		"1 image layer taking up <strong>1.0 MiB</strong> provided no accessed files.",
+		"Accessed <strong>2</strong> of 40 image files",
+		"/usr/share/doc/nginx/changelog.gz",
+		"<td><code>/usr/share</code></td>",
+		`<td class="num" data-sort="0">0 / 39</td>`,
+		"<h2>sidecar</h2>",
+		"7 paths evicted",
+		"Executables no package owns",
+		"<code>/tmp/miner</code>",
+		"Libraries needed but never opened",
+		"<code>libgone.so.1</code>",
+		`<span class="muted">not found</span>`,
+		"Files on volumes",
+		"<td>emptyDir</td>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML output missing %q", want)
+		}
+	}
+	if strings.Contains(out, "<script>alert(1)") {
+		t.Error("file paths are not escaped")
+	}
+	if strings.Contains(out, "http://") || strings.Contains(out, "https://") {
+		t.Error("HTML report should be self-contained with no external references")
+	}
+}
@@ -0,0 +1,66 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// HTTPReporter POSTs each report as JSON to a remote endpoint.
+type HTTPReporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPReporter creates a reporter that POSTs reports to the given URL.
+func NewHTTPReporter(ctx context.Context, url string) *HTTPReporter {
+	log := clog.FromContext(ctx)
+	log.Infof("Initialized HTTP reporter (url: %s)", url)
+	return &HTTPReporter{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Update sends the report to the remote endpoint.
+func (r *HTTPReporter) Update(ctx context.Context, report *Report) error {
+	reportCopy := *report
+	reportCopy.SchemaVersion = SchemaVersion
+	reportCopy.LastUpdatedAt = time.Now()
+
+	data, err := json.Marshal(&reportCopy)
+	if err != nil {
+		return fmt.Errorf("marshaling report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting report to %s: %w", r.url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting report to %s: unexpected status %s", r.url, resp.Status)
+	}
+
+	clog.FromContext(ctx).Debugf("Report posted to %s (%d bytes)", r.url, len(data))
+	return nil
+}
+
+// Close is a no-op for HTTPReporter.
+func (r *HTTPReporter) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPReporterPostsReport(t *testing.T) {
+	ctx := context.Background()
+
+	var got Report
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	r := NewHTTPReporter(ctx, server.URL)
+	report := &Report{
+		PodName:    "my-app",
+		StartedAt:  time.Now(),
+		Containers: []ContainerReport{{Name: "app", CgroupID: 1000, Files: []string{"/bin/sh"}}},
+	}
+	if err := r.Update(ctx, report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if got.PodName != "my-app" {
+		t.Errorf("PodName = %q, want my-app", got.PodName)
+	}
+	if got.SchemaVersion != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", got.SchemaVersion, SchemaVersion)
+	}
+	if len(got.Containers) != 1 {
+		t.Errorf("len(Containers) = %d, want 1", len(got.Containers))
+	}
+}
+
+func TestHTTPReporterErrorStatus(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	r := NewHTTPReporter(ctx, server.URL)
+	if err := r.Update(ctx, &Report{StartedAt: time.Now()}); err == nil {
+		t.Error("expected error for 500 response")
+	}
+}
@@ -0,0 +1,76 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// JarUsage returns c's jars, and any other jar among its files, with the
+// classes a class-loading log showed loaded from each, keyed by path as
+// returned by java.ParseClassLog, sorted by path. Jars only in classes are
+// added as opened. A nil classes leaves the counts as recorded.
+func JarUsage(c ContainerReport, classes map[string]int) []JarReport {
+	byPath := make(map[string]JarReport, len(c.Jars))
+	for _, f := range c.Files {
+		if strings.HasSuffix(strings.ToLower(f), ".jar") {
+			byPath[f] = JarReport{Path: f, Opened: true}
+		}
+	}
+	for _, j := range c.Jars {
+		byPath[j.Path] = j
+	}
+	for path, n := range classes {
+		j, ok := byPath[path]
+		if !ok {
+			j = JarReport{Path: path, Opened: true}
+		}
+		j.ClassesLoaded = n
+		byPath[path] = j
+	}
+	jars := make([]JarReport, 0, len(byPath))
+	for _, j := range byPath {
+		jars = append(jars, j)
+	}
+	sort.Slice(jars, func(i, j int) bool { return jars[i].Path < jars[j].Path })
+	return jars
+}
+
+// Status describes how j was used: "loaded" if classes were loaded from
+// it, "opened" if it was opened but no class log says otherwise, "unused"
+// if it was on the classpath but never opened.
+func (j JarReport) Status() string {
+	switch {
+	case j.ClassesLoaded > 0:
+		return "loaded"
+	case j.Opened:
+		return "opened"
+	default:
+		return "unused"
+	}
+}
+
+// WriteJars writes jars as an aligned text table. The CLASSES column is
+// only written if classes is set.
+func WriteJars(w io.Writer, jars []JarReport, classes bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if classes {
+		fmt.Fprintf(tw, "STATUS\tCLASSPATH\tCLASSES\tJAR\n")
+	} else {
+		fmt.Fprintf(tw, "STATUS\tCLASSPATH\tJAR\n")
+	}
+	for _, j := range jars {
+		cp := "no"
+		if j.OnClasspath {
+			cp = "yes"
+		}
+		if classes {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", j.Status(), cp, j.ClassesLoaded, j.Path)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", j.Status(), cp, j.Path)
+		}
+	}
+	return tw.Flush()
+}
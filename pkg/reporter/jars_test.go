@@ -0,0 +1,47 @@
+package reporter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestJarUsage(t *testing.T) {
+	c := ContainerReport{Files: []string{"/app/lib/a.jar", "/etc/hosts"}, Jars: []JarReport{
+		{Path: "/app/lib/a.jar", OnClasspath: true, Opened: true},
+		{Path: "/app/lib/b.jar", OnClasspath: true, Opened: true},
+		{Path: "/app/lib/c.jar", OnClasspath: true},
+	}}
+	got := JarUsage(c, map[string]int{"/app/lib/a.jar": 12, "/app/plugin.jar": 2})
+	want := []JarReport{
+		{Path: "/app/lib/a.jar", OnClasspath: true, Opened: true, ClassesLoaded: 12},
+		{Path: "/app/lib/b.jar", OnClasspath: true, Opened: true},
+		{Path: "/app/lib/c.jar", OnClasspath: true},
+		{Path: "/app/plugin.jar", Opened: true, ClassesLoaded: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JarUsage() = %+v, want %+v", got, want)
+	}
+	if got := JarUsage(c, nil); !reflect.DeepEqual(got, c.Jars) {
+		t.Errorf("JarUsage(nil) = %+v, want %+v", got, c.Jars)
+	}
+	// Without classpath analysis, accessed jars are still listed
+	want = []JarReport{{Path: "/app/lib/a.jar", Opened: true}}
+	if got := JarUsage(ContainerReport{Files: c.Files}, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("JarUsage(files) = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJars(&buf, got, true); err != nil {
+		t.Fatal(err)
+	}
+	wantTable := `STATUS  CLASSPATH  CLASSES  JAR
+loaded  yes        12       /app/lib/a.jar
+opened  yes        0        /app/lib/b.jar
+unused  yes        0        /app/lib/c.jar
+loaded  no         2        /app/plugin.jar
+`
+	if buf.String() != wantTable {
+		t.Errorf("WriteJars() =\n%s\nwant\n%s", buf.String(), wantTable)
+	}
+}
@@ -0,0 +1,93 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// AccessedBytes returns the size of l's accessed files.
+func (l LayerReport) AccessedBytes() int64 {
+	return max(l.TotalBytes-l.UnusedBytes, 0)
+}
+
+// BytePercent returns the share of l's bytes that were accessed, from 0 to
+// 100.
+func (l LayerReport) BytePercent() int {
+	if l.TotalBytes == 0 {
+		return 0
+	}
+	return int(l.AccessedBytes() * 100 / l.TotalBytes)
+}
+
+// Unused reports whether l is an image layer that provided none of the
+// files the container accessed, so an image without it would have worked
+// the same for what was traced.
+func (l LayerReport) Unused() bool {
+	return !l.Upper && l.AccessedFiles == 0
+}
+
+// UnusedLayers returns the image layers among layers that provided none of
+// the accessed files, in order, and their total size.
+func UnusedLayers(layers []LayerReport) ([]LayerReport, int64) {
+	var unused []LayerReport
+	var size int64
+	for _, l := range layers {
+		if l.Unused() {
+			unused = append(unused, l)
+			size += l.TotalBytes
+		}
+	}
+	return unused, size
+}
+
+// WriteLayers writes layers as an aligned text table, base layer first,
+// followed by a line naming the image layers that provided no accessed
+// files, if any.
+func WriteLayers(w io.Writer, layers []LayerReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "LAYER\tUSED\tFILES\tSIZE\tUNUSED\tDIGEST\n")
+	for _, l := range layers {
+		digest := shortDigest(l.Digest)
+		switch {
+		case l.Upper:
+			// The writable layer holds what the container wrote, not image files
+			fmt.Fprintf(tw, "%d\t-\t%d\t%s\t-\t(writable)\n", l.Index, l.AccessedFiles, formatBytes(l.TotalBytes))
+			continue
+		case l.Unused():
+			digest += " (unused)"
+		}
+		fmt.Fprintf(tw, "%d\t%d%%\t%d/%d\t%s\t%s\t%s\n", l.Index, l.BytePercent(), l.AccessedFiles, l.TotalFiles, formatBytes(l.TotalBytes), formatBytes(l.UnusedBytes), digest)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if unused, size := UnusedLayers(layers); len(unused) > 0 {
+		indexes := make([]string, 0, len(unused))
+		for _, l := range unused {
+			indexes = append(indexes, strconv.Itoa(l.Index))
+		}
+		noun := "Layer"
+		if len(unused) > 1 {
+			noun = "Layers"
+		}
+		_, err := fmt.Fprintf(w, "\n%s %s (%s) provided no accessed files.\n", noun, strings.Join(indexes, ", "), formatBytes(size))
+		return err
+	}
+	return nil
+}
+
+// shortDigest abbreviates a digest to its algorithm and 12 hex digits, as
+// docker does, or returns "-" for an empty one.
+func shortDigest(d string) string {
+	if d == "" {
+		return "-"
+	}
+	algo, hex, ok := strings.Cut(d, ":")
+	if !ok || len(hex) <= 12 {
+		return d
+	}
+	return algo + ":" + hex[:12]
+}
@@ -0,0 +1,39 @@
+package reporter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteLayers(t *testing.T) {
+	layers := []LayerReport{
+		{Index: 1, Digest: "sha256:4abcf2d0e8b1a6c3", AccessedFiles: 42, TotalFiles: 1830, TotalBytes: 4 << 20, UnusedBytes: 3 << 20},
+		{Index: 2, Digest: "sha256:9b2e07", TotalFiles: 12, TotalBytes: 2 << 20, UnusedBytes: 2 << 20},
+		{Index: 3, TotalFiles: 1, TotalBytes: 1 << 10, UnusedBytes: 1 << 10},
+		{Index: 4, Upper: true, AccessedFiles: 3, TotalFiles: 3, TotalBytes: 100},
+	}
+
+	unused, size := UnusedLayers(layers)
+	if len(unused) != 2 || unused[0].Index != 2 || unused[1].Index != 3 {
+		t.Errorf("UnusedLayers() = %+v, want layers 2 and 3", unused)
+	}
+	if want := int64(2<<20 + 1<<10); size != want {
+		t.Errorf("UnusedLayers() size = %d, want %d", size, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLayers(&buf, layers); err != nil {
+		t.Fatal(err)
+	}
+	want := `LAYER  USED  FILES    SIZE     UNUSED   DIGEST
+1      25%   42/1830  4.0 MiB  3.0 MiB  sha256:4abcf2d0e8b1
+2      0%    0/12     2.0 MiB  2.0 MiB  sha256:9b2e07 (unused)
+3      0%    0/1      1.0 KiB  1.0 KiB  - (unused)
+4      -     3        100 B    -        (writable)
+
+Layers 2, 3 (2.0 MiB) provided no accessed files.
+`
+	if buf.String() != want {
+		t.Errorf("WriteLayers() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,419 @@
+package reporter
+
+import (
+	"maps"
+	"sort"
+)
+
+// Merge combines several reports for the same image (e.g. from multiple
+// replicas) into one. Containers are matched by name; their file sets are
+// unioned and their counters summed, including truncated and evicted files,
+// so the merged report is incomplete if any input was. The merged report
+// spans from the earliest StartedAt to the latest LastUpdatedAt, and has
+// converged only if every input has. Pod-level metadata and the agent build
+// are kept only when every input agrees on them.
+//
+// Per-file metadata and digests, pre-existing files, Go binaries, and
+// modified package files are unioned by path; when inputs disagree on a
+// path's entry, the one from the most recently updated report wins.
+// Unobserved libraries are unioned by name and path, except those the merged
+// files include.
+//
+// Packages are matched by manager, name, and version. Access counts are
+// summed, but since reports don't say which package files were accessed,
+// AccessedFiles is the largest count seen in any input: a lower bound on the
+// true union. Removable package sets and unused package bytes are recomputed
+// from the merged packages.
+//
+// Sampling is kept if any input sampled events: skipped events and degraded
+// time are summed, and rates are the highest of any input.
+//
+// Each merged container counts its Observations, the containers it was
+// merged from, and in FileObservations how many of them accessed each file
+// that not all of them did, so files seen by a single replica can be told
+// from ones every replica needs. Inputs that are themselves merged reports
+// contribute their own counts.
+//
+// Layers describe one node's storage and are dropped. So is coverage, since
+// accessed counts can't be combined without the image's full file list.
+func Merge(reports ...*Report) *Report {
+	return merge(func(c ContainerReport) string { return c.Name }, reports)
+}
+
+// MergeByImage is like Merge, but matches containers by image digest rather
+// than name, so that reports from every pod across a fleet running the same
+// image are combined whatever their containers are called. A merged
+// container is named after the first of its inputs. Containers without a
+// recorded digest are matched by name, separately from those with one.
+func MergeByImage(reports ...*Report) *Report {
+	return merge(func(c ContainerReport) string {
+		if c.ImageDigest == "" {
+			return "name:" + c.Name
+		}
+		return "digest:" + c.ImageDigest
+	}, reports)
+}
+
+// merge implements Merge, matching containers with equal keys.
+func merge(key func(ContainerReport) string, reports []*Report) *Report {
+	merged := &Report{
+		SchemaVersion: SchemaVersion,
+		Containers:    []ContainerReport{},
+	}
+	if len(reports) == 0 {
+		return merged
+	}
+
+	// Apply reports oldest-first so newer metadata and digests take precedence
+	ordered := make([]*Report, len(reports))
+	copy(ordered, reports)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].LastUpdatedAt.Before(ordered[j].LastUpdatedAt)
+	})
+
+	merged.PodName = ordered[0].PodName
+	merged.Namespace = ordered[0].Namespace
+	merged.NodeName = ordered[0].NodeName
+	merged.Labels = ordered[0].Labels
+	merged.Agent = ordered[0].Agent
+	merged.StartedAt = ordered[0].StartedAt
+	merged.Converged = true
+
+	type containerAcc struct {
+		report         ContainerReport
+		observations   int
+		files          map[string]int // observations of each file
+		preexisting    map[string]struct{}
+		unownedExecs   map[string]struct{}
+		packages       packageAcc
+		pythonPackages packageAcc
+		pythonModules  map[string]struct{}
+		npmPackages    packageAcc
+		goBinaries     map[string]GoBinary
+		modifiedFiles  map[string]ModifiedFile
+		volumeFiles    map[string]VolumeFile
+		jars           map[string]JarReport
+		volumeSummary  bool // whether any input summarized volumes
+		libraries      map[libraryKey]map[string]struct{}
+	}
+	byKey := make(map[string]*containerAcc)
+	var keys []string
+
+	for _, r := range ordered {
+		if r.PodName != merged.PodName {
+			merged.PodName = ""
+		}
+		if r.Namespace != merged.Namespace {
+			merged.Namespace = ""
+		}
+		if r.NodeName != merged.NodeName {
+			merged.NodeName = ""
+		}
+		if !maps.Equal(r.Labels, merged.Labels) {
+			merged.Labels = nil
+		}
+		if merged.Agent != nil && (r.Agent == nil || *r.Agent != *merged.Agent) {
+			merged.Agent = nil
+		}
+		if !r.StartedAt.IsZero() && (merged.StartedAt.IsZero() || r.StartedAt.Before(merged.StartedAt)) {
+			merged.StartedAt = r.StartedAt
+		}
+		if r.LastUpdatedAt.After(merged.LastUpdatedAt) {
+			merged.LastUpdatedAt = r.LastUpdatedAt
+		}
+		if r.LastNewFileAt != nil && (merged.LastNewFileAt == nil || r.LastNewFileAt.After(*merged.LastNewFileAt)) {
+			merged.LastNewFileAt = r.LastNewFileAt
+		}
+		merged.Converged = merged.Converged && r.Converged
+		merged.TotalEvents += r.TotalEvents
+		merged.DroppedEvents += r.DroppedEvents
+		if r.Sampling != nil {
+			if merged.Sampling == nil {
+				merged.Sampling = &SamplingInfo{}
+			}
+			merged.Sampling.SkippedEvents += r.Sampling.SkippedEvents
+			merged.Sampling.Rate = max(merged.Sampling.Rate, r.Sampling.Rate)
+			merged.Sampling.PeakRate = max(merged.Sampling.PeakRate, r.Sampling.PeakRate)
+			merged.Sampling.DegradedSeconds += r.Sampling.DegradedSeconds
+		}
+
+		for _, c := range r.Containers {
+			k := key(c)
+			acc, ok := byKey[k]
+			if !ok {
+				acc = &containerAcc{
+					report: ContainerReport{
+						Name:        c.Name,
+						CgroupID:    c.CgroupID,
+						CgroupPath:  c.CgroupPath,
+						ImageRef:    c.ImageRef,
+						ImageDigest: c.ImageDigest,
+						Ephemeral:   c.Ephemeral,
+					},
+					files:         make(map[string]int),
+					preexisting:   make(map[string]struct{}),
+					unownedExecs:  make(map[string]struct{}),
+					pythonModules: make(map[string]struct{}),
+					goBinaries:    make(map[string]GoBinary),
+					modifiedFiles: make(map[string]ModifiedFile),
+					volumeFiles:   make(map[string]VolumeFile),
+					jars:          make(map[string]JarReport),
+					libraries:     make(map[libraryKey]map[string]struct{}),
+				}
+				byKey[k] = acc
+				keys = append(keys, k)
+			}
+			// Cgroup identity is per-pod; keep it only if all inputs agree
+			if acc.report.CgroupID != c.CgroupID {
+				acc.report.CgroupID = 0
+			}
+			if acc.report.CgroupPath != c.CgroupPath {
+				acc.report.CgroupPath = ""
+			}
+			// Merging replicas of different images is allowed but the
+			// result no longer describes a single image
+			if acc.report.ImageRef != c.ImageRef {
+				acc.report.ImageRef = ""
+			}
+			if acc.report.ImageDigest != c.ImageDigest {
+				acc.report.ImageDigest = ""
+			}
+			acc.report.TotalEvents += c.TotalEvents
+			acc.report.RestartCount += c.RestartCount
+			acc.report.FilesTruncated += c.FilesTruncated
+			acc.report.EvictedFiles += c.EvictedFiles
+			observations := max(c.Observations, 1)
+			acc.observations += observations
+			for _, f := range c.Files {
+				n, ok := c.FileObservations[f]
+				if !ok {
+					n = observations
+				}
+				acc.files[f] += n
+			}
+			for _, f := range c.PreexistingFiles {
+				acc.preexisting[f] = struct{}{}
+			}
+			for _, f := range c.UnownedExecutables {
+				acc.unownedExecs[f] = struct{}{}
+			}
+			for path, md := range c.FileMetadata {
+				if acc.report.FileMetadata == nil {
+					acc.report.FileMetadata = make(map[string]FileMetadata)
+				}
+				acc.report.FileMetadata[path] = md
+			}
+			for path, d := range c.FileDigests {
+				if acc.report.FileDigests == nil {
+					acc.report.FileDigests = make(map[string]string)
+				}
+				acc.report.FileDigests[path] = d
+			}
+			acc.packages.add(c.Packages)
+			acc.pythonPackages.add(c.PythonPackages)
+			for _, m := range c.PythonModules {
+				acc.pythonModules[m] = struct{}{}
+			}
+			acc.npmPackages.add(c.NpmPackages)
+			for _, b := range c.GoBinaries {
+				acc.goBinaries[b.Path] = b
+			}
+			for _, m := range c.ModifiedFiles {
+				acc.modifiedFiles[m.Path] = m
+			}
+			for _, v := range c.VolumeFiles {
+				acc.volumeFiles[v.Path] = v
+			}
+			acc.volumeSummary = acc.volumeSummary || c.VolumeSummary != nil
+			for _, j := range c.Jars {
+				prev := acc.jars[j.Path]
+				j.OnClasspath = j.OnClasspath || prev.OnClasspath
+				j.Opened = j.Opened || prev.Opened
+				j.ClassesLoaded = max(j.ClassesLoaded, prev.ClassesLoaded)
+				acc.jars[j.Path] = j
+			}
+			for _, l := range c.UnobservedLibraries {
+				k := libraryKey{l.Name, l.Path}
+				if acc.libraries[k] == nil {
+					acc.libraries[k] = make(map[string]struct{})
+				}
+				for _, b := range l.RequiredBy {
+					acc.libraries[k][b] = struct{}{}
+				}
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		acc := byKey[k]
+		files := make([]string, 0, len(acc.files))
+		for f, n := range acc.files {
+			files = append(files, f)
+			if n < acc.observations {
+				if acc.report.FileObservations == nil {
+					acc.report.FileObservations = make(map[string]int)
+				}
+				acc.report.FileObservations[f] = n
+			}
+		}
+		sort.Strings(files)
+		acc.report.Files = files
+		acc.report.UniqueFiles = len(files)
+		acc.report.Observations = acc.observations
+		for f := range acc.preexisting {
+			acc.report.PreexistingFiles = append(acc.report.PreexistingFiles, f)
+		}
+		sort.Strings(acc.report.PreexistingFiles)
+		for f := range acc.unownedExecs {
+			acc.report.UnownedExecutables = append(acc.report.UnownedExecutables, f)
+		}
+		sort.Strings(acc.report.UnownedExecutables)
+		acc.report.Packages = acc.packages.result()
+		acc.report.RemovablePackages = RemovableSets(acc.report.Packages)
+		acc.report.UnusedPackageBytes = UnusedSize(acc.report.Packages)
+		acc.report.PythonPackages = acc.pythonPackages.result()
+		for m := range acc.pythonModules {
+			acc.report.PythonModules = append(acc.report.PythonModules, m)
+		}
+		sort.Strings(acc.report.PythonModules)
+		acc.report.NpmPackages = acc.npmPackages.result()
+		for _, b := range acc.goBinaries {
+			acc.report.GoBinaries = append(acc.report.GoBinaries, b)
+		}
+		sort.Slice(acc.report.GoBinaries, func(i, j int) bool {
+			return acc.report.GoBinaries[i].Path < acc.report.GoBinaries[j].Path
+		})
+		for _, m := range acc.modifiedFiles {
+			acc.report.ModifiedFiles = append(acc.report.ModifiedFiles, m)
+		}
+		sort.Slice(acc.report.ModifiedFiles, func(i, j int) bool {
+			return acc.report.ModifiedFiles[i].Path < acc.report.ModifiedFiles[j].Path
+		})
+		for _, v := range acc.volumeFiles {
+			acc.report.VolumeFiles = append(acc.report.VolumeFiles, v)
+		}
+		sort.Slice(acc.report.VolumeFiles, func(i, j int) bool {
+			return acc.report.VolumeFiles[i].Path < acc.report.VolumeFiles[j].Path
+		})
+		if acc.volumeSummary {
+			acc.report.VolumeSummary = SummarizeVolumes(acc.report)
+		}
+		for _, j := range acc.jars {
+			acc.report.Jars = append(acc.report.Jars, j)
+		}
+		sort.Slice(acc.report.Jars, func(i, j int) bool {
+			return acc.report.Jars[i].Path < acc.report.Jars[j].Path
+		})
+		for k, requiredBy := range acc.libraries {
+			// Another input may have seen the library opened
+			if _, ok := acc.files[k.path]; ok {
+				continue
+			}
+			l := LibraryDependency{Name: k.name, Path: k.path}
+			for b := range requiredBy {
+				l.RequiredBy = append(l.RequiredBy, b)
+			}
+			sort.Strings(l.RequiredBy)
+			acc.report.UnobservedLibraries = append(acc.report.UnobservedLibraries, l)
+		}
+		sort.Slice(acc.report.UnobservedLibraries, func(i, j int) bool {
+			a, b := acc.report.UnobservedLibraries[i], acc.report.UnobservedLibraries[j]
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.Path < b.Path
+		})
+		merged.Containers = append(merged.Containers, acc.report)
+	}
+
+	return merged
+}
+
+// libraryKey identifies an unobserved library across reports.
+type libraryKey struct {
+	name, path string
+}
+
+// packageKey identifies a package across reports.
+type packageKey struct {
+	name, version, manager string
+}
+
+// packageAcc accumulates package reports across inputs, keyed by identity.
+type packageAcc map[packageKey]*PackageReport
+
+func (a *packageAcc) add(pkgs []PackageReport) {
+	for _, pkg := range pkgs {
+		if *a == nil {
+			*a = make(packageAcc)
+		}
+		key := packageKey{pkg.Name, pkg.Version, pkg.Manager}
+		merged, ok := (*a)[key]
+		if !ok {
+			merged = &PackageReport{Name: pkg.Name, Version: pkg.Version, Manager: pkg.Manager}
+			(*a)[key] = merged
+		}
+		merged.UnusedFiles = mergeUnused(merged, pkg, ok)
+		merged.TotalFiles = max(merged.TotalFiles, pkg.TotalFiles)
+		merged.Size = max(merged.Size, pkg.Size)
+		merged.AccessedFiles = max(merged.AccessedFiles, pkg.AccessedFiles)
+		merged.AccessCount += pkg.AccessCount
+		if merged.InstallReason == "" {
+			merged.InstallReason = pkg.InstallReason
+		}
+		if merged.Origin == "" && merged.License == "" && merged.Arch == "" {
+			merged.Origin, merged.License, merged.Arch = pkg.Origin, pkg.License, pkg.Arch
+		}
+		if merged.Depends == nil {
+			merged.Depends = pkg.Depends
+		}
+		if merged.Provides == nil {
+			merged.Provides = pkg.Provides
+		}
+	}
+}
+
+// mergeUnused returns the files unused in both merged and pkg: a file used
+// by any replica was used. A package without a list is unknown unless all of
+// its files were accessed, in which case nothing was unused.
+func mergeUnused(merged *PackageReport, pkg PackageReport, seen bool) []string {
+	known := func(p PackageReport) bool {
+		return p.UnusedFiles != nil || p.AccessedFiles >= p.TotalFiles
+	}
+	switch {
+	case !seen || !known(*merged):
+		return pkg.UnusedFiles
+	case !known(pkg):
+		return merged.UnusedFiles
+	}
+	inPkg := make(map[string]bool, len(pkg.UnusedFiles))
+	for _, f := range pkg.UnusedFiles {
+		inPkg[f] = true
+	}
+	var unused []string
+	for _, f := range merged.UnusedFiles {
+		if inPkg[f] {
+			unused = append(unused, f)
+		}
+	}
+	return unused
+}
+
+// result returns the merged packages sorted by name and version, or nil if none were added.
+func (a packageAcc) result() []PackageReport {
+	if len(a) == 0 {
+		return nil
+	}
+	result := make([]PackageReport, 0, len(a))
+	for _, pkg := range a {
+		result = append(result, *pkg)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Version < result[j].Version
+	})
+	return result
+}
@@ -0,0 +1,420 @@
+package reporter
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	t0 := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	a := &Report{
+		PodName:       "app-abc",
+		Namespace:     "prod",
+		NodeName:      "node-1",
+		Labels:        map[string]string{"app": "web"},
+		StartedAt:     t0.Add(time.Minute),
+		LastUpdatedAt: t0.Add(10 * time.Minute),
+		Containers: []ContainerReport{
+			{Name: "app", CgroupID: 1000, CgroupPath: "/pod-a/app", Files: []string{"/bin/app", "/etc/app.conf"}, TotalEvents: 10, UniqueFiles: 2},
+			{Name: "sidecar", CgroupID: 2000, CgroupPath: "/pod-a/sidecar", Files: []string{"/etc/fluent.conf"}, TotalEvents: 3, UniqueFiles: 1},
+		},
+		TotalEvents:   13,
+		DroppedEvents: 1,
+	}
+	b := &Report{
+		PodName:       "app-def",
+		Namespace:     "prod",
+		NodeName:      "node-2",
+		Labels:        map[string]string{"app": "web"},
+		StartedAt:     t0,
+		LastUpdatedAt: t0.Add(5 * time.Minute),
+		Containers: []ContainerReport{
+			{Name: "app", CgroupID: 3000, CgroupPath: "/pod-b/app", Files: []string{"/bin/app", "/lib/libc.so"}, TotalEvents: 20, UniqueFiles: 2},
+		},
+		TotalEvents:   20,
+		DroppedEvents: 2,
+	}
+
+	got := Merge(a, b)
+
+	if got.SchemaVersion != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", got.SchemaVersion, SchemaVersion)
+	}
+	if got.PodName != "" {
+		t.Errorf("PodName = %q, want empty (inputs disagree)", got.PodName)
+	}
+	if got.Namespace != "prod" {
+		t.Errorf("Namespace = %q, want prod", got.Namespace)
+	}
+	if got.NodeName != "" {
+		t.Errorf("NodeName = %q, want empty (inputs disagree)", got.NodeName)
+	}
+	if got.Labels["app"] != "web" {
+		t.Errorf("Labels = %v, want app=web", got.Labels)
+	}
+	if !got.StartedAt.Equal(t0) {
+		t.Errorf("StartedAt = %v, want %v", got.StartedAt, t0)
+	}
+	if !got.LastUpdatedAt.Equal(t0.Add(10 * time.Minute)) {
+		t.Errorf("LastUpdatedAt = %v, want %v", got.LastUpdatedAt, t0.Add(10*time.Minute))
+	}
+	if got.TotalEvents != 33 || got.DroppedEvents != 3 {
+		t.Errorf("TotalEvents, DroppedEvents = %d, %d, want 33, 3", got.TotalEvents, got.DroppedEvents)
+	}
+
+	if len(got.Containers) != 2 {
+		t.Fatalf("len(Containers) = %d, want 2", len(got.Containers))
+	}
+	app := got.Containers[0]
+	if app.Name != "app" {
+		t.Fatalf("Containers[0].Name = %q, want app", app.Name)
+	}
+	wantFiles := []string{"/bin/app", "/etc/app.conf", "/lib/libc.so"}
+	if !slices.Equal(app.Files, wantFiles) {
+		t.Errorf("app files = %v, want %v", app.Files, wantFiles)
+	}
+	if app.UniqueFiles != 3 || app.TotalEvents != 30 {
+		t.Errorf("app UniqueFiles, TotalEvents = %d, %d, want 3, 30", app.UniqueFiles, app.TotalEvents)
+	}
+	if app.CgroupID != 0 || app.CgroupPath != "" {
+		t.Errorf("app cgroup = %d %q, want cleared when inputs disagree", app.CgroupID, app.CgroupPath)
+	}
+
+	sidecar := got.Containers[1]
+	if sidecar.CgroupID != 2000 || sidecar.CgroupPath != "/pod-a/sidecar" {
+		t.Errorf("sidecar cgroup = %d %q, want preserved from single input", sidecar.CgroupID, sidecar.CgroupPath)
+	}
+}
+
+func TestMergeDigestsNewestWins(t *testing.T) {
+	t0 := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	older := &Report{
+		LastUpdatedAt: t0,
+		Containers:    []ContainerReport{{Name: "app", Files: []string{"/a"}, FileDigests: map[string]string{"/a": "sha256:old"}}},
+	}
+	newer := &Report{
+		LastUpdatedAt: t0.Add(time.Hour),
+		Containers:    []ContainerReport{{Name: "app", Files: []string{"/a"}, FileDigests: map[string]string{"/a": "sha256:new"}}},
+	}
+
+	// Argument order must not matter
+	got := Merge(newer, older)
+	if d := got.Containers[0].FileDigests["/a"]; d != "sha256:new" {
+		t.Errorf("digest = %q, want sha256:new", d)
+	}
+}
+
+func TestMergeGoBinaries(t *testing.T) {
+	t0 := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	older := &Report{
+		LastUpdatedAt: t0,
+		Containers: []ContainerReport{{Name: "app", GoBinaries: []GoBinary{
+			{Path: "/usr/bin/server", Module: "example.com/server", Version: "v1.0.0"},
+			{Path: "/usr/bin/tool", Module: "example.com/tool", Version: "v0.1.0"},
+		}}},
+	}
+	newer := &Report{
+		LastUpdatedAt: t0.Add(time.Hour),
+		Containers: []ContainerReport{{Name: "app", GoBinaries: []GoBinary{
+			{Path: "/usr/bin/server", Module: "example.com/server", Version: "v1.1.0"},
+		}}},
+	}
+
+	got := Merge(newer, older).Containers[0].GoBinaries
+	if len(got) != 2 {
+		t.Fatalf("got %d binaries, want 2: %+v", len(got), got)
+	}
+	if got[0].Path != "/usr/bin/server" || got[0].Version != "v1.1.0" {
+		t.Errorf("server = %+v, want newest version v1.1.0", got[0])
+	}
+	if got[1].Path != "/usr/bin/tool" {
+		t.Errorf("second binary = %q, want /usr/bin/tool", got[1].Path)
+	}
+}
+
+func TestMergeImages(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{
+		{Name: "app", ImageRef: "app:v1", ImageDigest: "sha256:aaa"},
+		{Name: "sidecar", ImageRef: "fluentd:v1", ImageDigest: "sha256:fff"},
+	}}
+	b := &Report{Containers: []ContainerReport{
+		{Name: "app", ImageRef: "app:v1", ImageDigest: "sha256:bbb"},
+		{Name: "sidecar", ImageRef: "fluentd:v1", ImageDigest: "sha256:fff"},
+	}}
+
+	got := Merge(a, b)
+	app, sidecar := got.Containers[0], got.Containers[1]
+	if app.ImageRef != "app:v1" || app.ImageDigest != "" {
+		t.Errorf("app image = %q@%q, want app:v1 with no digest", app.ImageRef, app.ImageDigest)
+	}
+	if sidecar.ImageRef != "fluentd:v1" || sidecar.ImageDigest != "sha256:fff" {
+		t.Errorf("sidecar image = %q@%q, want fluentd:v1@sha256:fff", sidecar.ImageRef, sidecar.ImageDigest)
+	}
+}
+
+func TestMergeEmpty(t *testing.T) {
+	got := Merge()
+	if got.Containers == nil || len(got.Containers) != 0 {
+		t.Errorf("Containers = %v, want empty non-nil slice", got.Containers)
+	}
+}
+
+func TestMergePackages(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{{Name: "app", Packages: []PackageReport{
+		{Name: "curl", Version: "8.5.0", Manager: "dpkg", TotalFiles: 10, AccessedFiles: 2, AccessCount: 5},
+		{Name: "bash", Version: "5.2", Manager: "dpkg", TotalFiles: 4},
+	}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", Packages: []PackageReport{
+		{Name: "curl", Version: "8.5.0", Manager: "dpkg", TotalFiles: 10, AccessedFiles: 3, AccessCount: 7},
+		{Name: "curl", Version: "8.6.0", Manager: "dpkg", TotalFiles: 10, AccessedFiles: 1, AccessCount: 1},
+	}}}}
+
+	got := Merge(a, b).Containers[0].Packages
+	want := []PackageReport{
+		{Name: "bash", Version: "5.2", Manager: "dpkg", TotalFiles: 4},
+		{Name: "curl", Version: "8.5.0", Manager: "dpkg", TotalFiles: 10, AccessedFiles: 3, AccessCount: 12},
+		{Name: "curl", Version: "8.6.0", Manager: "dpkg", TotalFiles: 10, AccessedFiles: 1, AccessCount: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged packages = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeUnusedFiles(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{{Name: "app", Packages: []PackageReport{
+		{Name: "curl", Manager: "apk", TotalFiles: 3, AccessedFiles: 1, UnusedFiles: []string{"/doc", "/man"}},
+		{Name: "jq", Manager: "apk", TotalFiles: 2, AccessedFiles: 2},
+	}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", Packages: []PackageReport{
+		{Name: "curl", Manager: "apk", TotalFiles: 3, AccessedFiles: 2, UnusedFiles: []string{"/doc"}},
+		{Name: "jq", Manager: "apk", TotalFiles: 2, AccessedFiles: 1, UnusedFiles: []string{"/usr/share/jq"}},
+	}}}}
+
+	pkgs := Merge(a, b).Containers[0].Packages
+	// A file used by any input is used
+	if got := pkgs[0].UnusedFiles; !slices.Equal(got, []string{"/doc"}) {
+		t.Errorf("curl unused files = %v, want [/doc]", got)
+	}
+	// Every jq file was used in a, so nothing is unused
+	if got := pkgs[1].UnusedFiles; len(got) != 0 {
+		t.Errorf("jq unused files = %v, want none", got)
+	}
+}
+
+func TestMergeConverged(t *testing.T) {
+	t0 := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	a := &Report{LastNewFileAt: &t0, Converged: true}
+	b := &Report{LastNewFileAt: &t1, Converged: true}
+	c := &Report{}
+
+	got := Merge(a, b)
+	if !got.Converged || got.LastNewFileAt == nil || !got.LastNewFileAt.Equal(t1) {
+		t.Errorf("Merge of converged reports: Converged=%t LastNewFileAt=%v, want true %v", got.Converged, got.LastNewFileAt, t1)
+	}
+	if got := Merge(a, c); got.Converged {
+		t.Error("Merge with an unconverged report converged")
+	}
+}
+
+func TestMergeIncomplete(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/bin/app"}, EvictedFiles: 3}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/bin/app"}, EvictedFiles: 2, FilesTruncated: 1}}}
+
+	got := Merge(a, b)
+	if c := got.Containers[0]; c.EvictedFiles != 5 || c.FilesTruncated != 1 {
+		t.Errorf("merged EvictedFiles, FilesTruncated = %d, %d, want 5, 1", c.EvictedFiles, c.FilesTruncated)
+	}
+	if names := Incomplete(got); !slices.Equal(names, []string{"app"}) {
+		t.Errorf("Incomplete(merged) = %v, want [app]", names)
+	}
+}
+
+func TestMergeAgent(t *testing.T) {
+	v1 := &AgentInfo{Version: "v1.0.0", BPFObject: "sha256:aa"}
+	a := &Report{Agent: v1}
+	b := &Report{Agent: &AgentInfo{Version: "v1.0.0", BPFObject: "sha256:aa"}}
+	c := &Report{Agent: &AgentInfo{Version: "v1.1.0", BPFObject: "sha256:bb"}}
+
+	if got := Merge(a, b); got.Agent == nil || *got.Agent != *v1 {
+		t.Errorf("Merge of reports from one build: Agent = %+v, want %+v", got.Agent, v1)
+	}
+	if got := Merge(a, c); got.Agent != nil {
+		t.Errorf("Merge of reports from different builds: Agent = %+v, want nil", got.Agent)
+	}
+	if got := Merge(a, &Report{}); got.Agent != nil {
+		t.Errorf("Merge with a report without agent info: Agent = %+v, want nil", got.Agent)
+	}
+}
+
+func TestMergeSampling(t *testing.T) {
+	a := &Report{Sampling: &SamplingInfo{SkippedEvents: 100, Rate: 1, PeakRate: 4, DegradedSeconds: 2}}
+	b := &Report{Sampling: &SamplingInfo{SkippedEvents: 50, Rate: 2, PeakRate: 2, DegradedSeconds: 1.5}}
+
+	want := SamplingInfo{SkippedEvents: 150, Rate: 2, PeakRate: 4, DegradedSeconds: 3.5}
+	if got := Merge(a, &Report{}, b); got.Sampling == nil || *got.Sampling != want {
+		t.Errorf("Merge: Sampling = %+v, want %+v", got.Sampling, want)
+	}
+	if got := Merge(&Report{}, &Report{}); got.Sampling != nil {
+		t.Errorf("Merge of reports without sampling: Sampling = %+v, want nil", got.Sampling)
+	}
+	// The inputs aren't modified
+	if a.Sampling.SkippedEvents != 100 {
+		t.Errorf("input Sampling modified: %+v", a.Sampling)
+	}
+}
+
+func TestMergeUnownedExecutables(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{{Name: "app", UnownedExecutables: []string{"/tmp/x"}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", UnownedExecutables: []string{"/dev/shm/y", "/tmp/x"}}}}
+
+	got := Merge(a, b).Containers[0].UnownedExecutables
+	if want := []string{"/dev/shm/y", "/tmp/x"}; !slices.Equal(got, want) {
+		t.Errorf("UnownedExecutables = %v, want %v", got, want)
+	}
+	if got := Merge(&Report{Containers: []ContainerReport{{Name: "app"}}}).Containers[0].UnownedExecutables; got != nil {
+		t.Errorf("UnownedExecutables = %v, want nil", got)
+	}
+}
+
+func TestMergePythonModules(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{{Name: "app", PythonModules: []string{"json", "requests"}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", PythonModules: []string{"requests", "requests.adapters"}}}}
+
+	got := Merge(a, b).Containers[0].PythonModules
+	if want := []string{"json", "requests", "requests.adapters"}; !slices.Equal(got, want) {
+		t.Errorf("PythonModules = %v, want %v", got, want)
+	}
+}
+
+func TestMergeJars(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{{Name: "app", Jars: []JarReport{
+		{Path: "/app/a.jar", OnClasspath: true},
+		{Path: "/app/b.jar", OnClasspath: true, Opened: true},
+	}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", Jars: []JarReport{
+		{Path: "/app/a.jar", OnClasspath: true, Opened: true},
+		{Path: "/opt/c.jar", Opened: true, ClassesLoaded: 3},
+	}}}}
+
+	got := Merge(a, b).Containers[0].Jars
+	want := []JarReport{
+		{Path: "/app/a.jar", OnClasspath: true, Opened: true},
+		{Path: "/app/b.jar", OnClasspath: true, Opened: true},
+		{Path: "/opt/c.jar", Opened: true, ClassesLoaded: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Jars = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeVolumeFiles(t *testing.T) {
+	token := VolumeFile{Path: "/var/run/secrets/kubernetes.io/serviceaccount/token", Volume: "tmpfs", MountPoint: "/var/run/secrets/kubernetes.io/serviceaccount"}
+	cache := VolumeFile{Path: "/cache/index", Volume: "emptyDir", MountPoint: "/cache"}
+	a := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/app", token.Path}, VolumeFiles: []VolumeFile{token},
+		VolumeSummary: map[string]int{"image": 1, "tmpfs": 1}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/app", cache.Path, token.Path}, VolumeFiles: []VolumeFile{cache, token},
+		VolumeSummary: map[string]int{"image": 1, "emptyDir": 1, "tmpfs": 1}}}}
+
+	merged := Merge(a, b).Containers[0]
+	if want := []VolumeFile{cache, token}; !slices.Equal(merged.VolumeFiles, want) {
+		t.Errorf("VolumeFiles = %v, want %v", merged.VolumeFiles, want)
+	}
+	if want := map[string]int{"image": 1, "emptyDir": 1, "tmpfs": 1}; !reflect.DeepEqual(merged.VolumeSummary, want) {
+		t.Errorf("VolumeSummary = %v, want %v", merged.VolumeSummary, want)
+	}
+}
+
+func TestMergeUnobservedLibraries(t *testing.T) {
+	loader := "/lib/ld-musl-x86_64.so.1"
+	a := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/app"}, UnobservedLibraries: []LibraryDependency{
+		{Name: loader, Path: loader, RequiredBy: []string{"/app"}},
+		{Name: "libz.so.1", Path: "/usr/lib/libz.so.1", RequiredBy: []string{"/app"}},
+	}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/usr/bin/curl", "/usr/lib/libz.so.1"}, UnobservedLibraries: []LibraryDependency{
+		{Name: loader, Path: loader, RequiredBy: []string{"/usr/bin/curl"}},
+		{Name: "libgone.so", RequiredBy: []string{"/usr/bin/curl"}},
+	}}}}
+
+	got := Merge(a, b).Containers[0].UnobservedLibraries
+	want := []LibraryDependency{
+		{Name: loader, Path: loader, RequiredBy: []string{"/app", "/usr/bin/curl"}},
+		{Name: "libgone.so", RequiredBy: []string{"/usr/bin/curl"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnobservedLibraries = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergePreexisting(t *testing.T) {
+	a := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/app", "/etc/hosts"}, PreexistingFiles: []string{"/app"}}}}
+	b := &Report{Containers: []ContainerReport{{Name: "app", Files: []string{"/app", "/lib/libc.so"}, PreexistingFiles: []string{"/lib/libc.so"}}}}
+
+	got := Merge(a, b).Containers[0].PreexistingFiles
+	if want := []string{"/app", "/lib/libc.so"}; !slices.Equal(got, want) {
+		t.Errorf("PreexistingFiles = %v, want %v", got, want)
+	}
+	if got := Merge(a, &Report{}).Containers[0].PreexistingFiles; !slices.Equal(got, []string{"/app"}) {
+		t.Errorf("PreexistingFiles = %v, want [/app]", got)
+	}
+}
+
+func TestMergeObservations(t *testing.T) {
+	replica := func(files ...string) *Report {
+		return &Report{Containers: []ContainerReport{{Name: "app", Files: files}}}
+	}
+	merged := Merge(replica("/bin/app", "/etc/rare"), replica("/bin/app"), replica("/bin/app", "/etc/rare", "/tmp/once"))
+	c := merged.Containers[0]
+	if c.Observations != 3 {
+		t.Errorf("Observations = %d, want 3", c.Observations)
+	}
+	want := map[string]int{"/etc/rare": 2, "/tmp/once": 1}
+	if !reflect.DeepEqual(c.FileObservations, want) {
+		t.Errorf("FileObservations = %v, want %v", c.FileObservations, want)
+	}
+
+	// Merging a merged report counts its observations
+	c = Merge(merged, replica("/tmp/once")).Containers[0]
+	if c.Observations != 4 {
+		t.Errorf("Observations = %d, want 4", c.Observations)
+	}
+	want = map[string]int{"/bin/app": 3, "/etc/rare": 2, "/tmp/once": 2}
+	if !reflect.DeepEqual(c.FileObservations, want) {
+		t.Errorf("FileObservations = %v, want %v", c.FileObservations, want)
+	}
+}
+
+func TestMergeByImage(t *testing.T) {
+	a := &Report{PodName: "web-1", Containers: []ContainerReport{
+		{Name: "web", ImageDigest: "sha256:aaa", Files: []string{"/bin/web"}},
+		{Name: "istio-proxy", ImageDigest: "sha256:bbb", Files: []string{"/usr/local/bin/envoy"}},
+	}}
+	b := &Report{PodName: "api-1", Containers: []ContainerReport{
+		{Name: "api", ImageDigest: "sha256:ccc", Files: []string{"/bin/api"}},
+		{Name: "proxy", ImageDigest: "sha256:bbb", Files: []string{"/usr/local/bin/envoy", "/etc/envoy.yaml"}},
+		{Name: "web", Files: []string{"/unpinned"}},
+	}}
+
+	merged := MergeByImage(a, b)
+	type summary struct {
+		name, digest string
+		files        []string
+		observations int
+	}
+	var got []summary
+	for _, c := range merged.Containers {
+		got = append(got, summary{c.Name, c.ImageDigest, c.Files, c.Observations})
+	}
+	want := []summary{
+		{"web", "sha256:aaa", []string{"/bin/web"}, 1},
+		{"istio-proxy", "sha256:bbb", []string{"/etc/envoy.yaml", "/usr/local/bin/envoy"}, 2},
+		{"api", "sha256:ccc", []string{"/bin/api"}, 1},
+		{"web", "", []string{"/unpinned"}, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeByImage() containers = %+v, want %+v", got, want)
+	}
+}
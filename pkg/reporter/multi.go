@@ -0,0 +1,45 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+)
+
+// MultiReporter fans out report updates to several reporters.
+// Every reporter is called even if an earlier one fails, and all
+// errors are returned joined together.
+type MultiReporter struct {
+	reporters []Reporter
+}
+
+// NewMultiReporter creates a reporter that forwards to all of the given reporters.
+func NewMultiReporter(reporters ...Reporter) *MultiReporter {
+	return &MultiReporter{reporters: reporters}
+}
+
+// Update forwards the report to every reporter.
+func (m *MultiReporter) Update(ctx context.Context, report *Report) error {
+	var errs []error
+	for _, r := range m.reporters {
+		if err := r.Update(ctx, report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every reporter.
+func (m *MultiReporter) Close() error {
+	var errs []error
+	for _, r := range m.reporters {
+		if err := r.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reporters returns the reporters this MultiReporter forwards to.
+func (m *MultiReporter) Reporters() []Reporter {
+	return m.reporters
+}
@@ -1,6 +1,7 @@
 package reporter
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"os"
@@ -205,7 +206,7 @@ func TestContainerReportJSONFields(t *testing.T) {
 	}
 
 	// Check top-level fields
-	expectedTopLevel := []string{"pod_name", "namespace", "started_at", "last_updated_at", "containers", "total_events", "dropped_events"}
+	expectedTopLevel := []string{"schema_version", "pod_name", "namespace", "started_at", "last_updated_at", "containers", "total_events", "dropped_events"}
 	for _, field := range expectedTopLevel {
 		if _, ok := raw[field]; !ok {
 			t.Errorf("expected top-level field %q not found", field)
@@ -362,3 +363,74 @@ func TestMultipleUpdates(t *testing.T) {
 		t.Errorf("TotalEvents = %d, want 10", got.Containers[0].TotalEvents)
 	}
 }
+
+func TestFileReporterCompact(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	report := &Report{
+		PodName:    "my-app",
+		Containers: []ContainerReport{{Name: "app", CgroupID: 1, Files: []string{"/bin/app", "/etc/app.conf"}, UniqueFiles: 2}},
+	}
+
+	indentedPath := filepath.Join(tmpDir, "indented.json")
+	if err := NewFileReporter(ctx, indentedPath).Update(ctx, report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	compactPath := filepath.Join(tmpDir, "compact.json")
+	if err := NewFileReporter(ctx, compactPath, WithCompactOutput()).Update(ctx, report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	indented, err := os.ReadFile(indentedPath)
+	if err != nil {
+		t.Fatalf("reading report: %v", err)
+	}
+	compact, err := os.ReadFile(compactPath)
+	if err != nil {
+		t.Fatalf("reading report: %v", err)
+	}
+
+	if len(compact) >= len(indented) {
+		t.Errorf("compact report is %d bytes, indented is %d; want compact smaller", len(compact), len(indented))
+	}
+	if n := bytes.Count(bytes.TrimSpace(compact), []byte("\n")); n != 0 {
+		t.Errorf("compact report has %d newlines, want 0", n)
+	}
+
+	got, err := DecodeBytes(compact)
+	if err != nil {
+		t.Fatalf("DecodeBytes failed: %v", err)
+	}
+	if got.PodName != "my-app" || len(got.Containers) != 1 || len(got.Containers[0].Files) != 2 {
+		t.Errorf("decoded compact report = %+v", got)
+	}
+}
+
+func TestSetConvergence(t *testing.T) {
+	t0 := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		desc        string
+		lastNewFile time.Time
+		window      time.Duration
+		now         time.Time
+		want        bool
+	}{
+		{"stable for the window", t0.Add(time.Minute), 5 * time.Minute, t0.Add(6 * time.Minute), true},
+		{"new file within the window", t0.Add(2 * time.Minute), 5 * time.Minute, t0.Add(6 * time.Minute), false},
+		{"no files since starting", time.Time{}, 5 * time.Minute, t0.Add(5 * time.Minute), true},
+		{"no files, just started", time.Time{}, 5 * time.Minute, t0.Add(time.Minute), false},
+		{"disabled", t0, 0, t0.Add(time.Hour), false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			r := &Report{StartedAt: t0}
+			r.SetConvergence(tt.lastNewFile, tt.window, tt.now)
+			if r.Converged != tt.want {
+				t.Errorf("Converged = %t, want %t", r.Converged, tt.want)
+			}
+			if tt.lastNewFile.IsZero() != (r.LastNewFileAt == nil) {
+				t.Errorf("LastNewFileAt = %v, want %v", r.LastNewFileAt, tt.lastNewFile)
+			}
+		})
+	}
+}
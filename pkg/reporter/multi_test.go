@@ -0,0 +1,82 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeReporter records calls and optionally returns an error.
+type fakeReporter struct {
+	updates int
+	closed  bool
+	err     error
+}
+
+func (f *fakeReporter) Update(ctx context.Context, report *Report) error {
+	f.updates++
+	return f.err
+}
+
+func (f *fakeReporter) Close() error {
+	f.closed = true
+	return f.err
+}
+
+func TestMultiReporterFansOut(t *testing.T) {
+	ctx := context.Background()
+	a, b := &fakeReporter{}, &fakeReporter{}
+	m := NewMultiReporter(a, b)
+
+	if err := m.Update(ctx, &Report{StartedAt: time.Now()}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if a.updates != 1 || b.updates != 1 {
+		t.Errorf("updates = %d, %d, want 1, 1", a.updates, b.updates)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !a.closed || !b.closed {
+		t.Error("expected all reporters to be closed")
+	}
+}
+
+func TestMultiReporterAggregatesErrors(t *testing.T) {
+	ctx := context.Background()
+	errA := errors.New("a failed")
+	errC := errors.New("c failed")
+	a, b, c := &fakeReporter{err: errA}, &fakeReporter{}, &fakeReporter{err: errC}
+	m := NewMultiReporter(a, b, c)
+
+	err := m.Update(ctx, &Report{StartedAt: time.Now()})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !errors.Is(err, errA) || !errors.Is(err, errC) {
+		t.Errorf("error %v should wrap both failures", err)
+	}
+	// A failing reporter must not prevent later reporters from running
+	if b.updates != 1 || c.updates != 1 {
+		t.Errorf("updates = %d, %d, want 1, 1", b.updates, c.updates)
+	}
+
+	if err := m.Close(); err == nil {
+		t.Error("expected Close error")
+	}
+	if !b.closed {
+		t.Error("expected healthy reporter to be closed")
+	}
+}
+
+func TestMultiReporterEmpty(t *testing.T) {
+	m := NewMultiReporter()
+	if err := m.Update(context.Background(), &Report{}); err != nil {
+		t.Errorf("Update on empty MultiReporter = %v, want nil", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("Close on empty MultiReporter = %v, want nil", err)
+	}
+}
@@ -0,0 +1,279 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// OTLPReporter exports report summaries to an OpenTelemetry collector using
+// OTLP/HTTP with JSON encoding. Each update sends per-container metrics to
+// /v1/metrics and a summary log record to /v1/logs.
+type OTLPReporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOTLPReporter creates a reporter that exports to the OTLP/HTTP endpoint,
+// e.g. "http://otel-collector:4318".
+func NewOTLPReporter(ctx context.Context, endpoint string) *OTLPReporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	clog.FromContext(ctx).Infof("Initialized OTLP reporter (endpoint: %s)", endpoint)
+	return &OTLPReporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Update exports metrics and a summary log record for the report.
+func (r *OTLPReporter) Update(ctx context.Context, report *Report) error {
+	now := time.Now()
+	resource := otlpResource(report)
+
+	metrics := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: resource,
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "snoop"},
+			Metrics: otlpMetrics(report, now),
+		}},
+	}}}
+	if err := r.post(ctx, "/v1/metrics", &metrics); err != nil {
+		return err
+	}
+
+	logs := otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: resource,
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: "snoop"},
+			LogRecords: []otlpLogRecord{otlpSummaryLog(report, now)},
+		}},
+	}}}
+	return r.post(ctx, "/v1/logs", &logs)
+}
+
+// Close is a no-op for OTLPReporter.
+func (r *OTLPReporter) Close() error {
+	return nil
+}
+
+// post sends a JSON-encoded OTLP request to the given signal path.
+func (r *OTLPReporter) post(ctx context.Context, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshaling OTLP request: %w", err)
+	}
+
+	url := r.endpoint + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("exporting to %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
+
+// otlpResource describes the pod being traced using semantic convention attributes.
+func otlpResource(report *Report) otlpResourceDesc {
+	attrs := []otlpKeyValue{stringAttr("service.name", "snoop")}
+	if report.PodName != "" {
+		attrs = append(attrs, stringAttr("k8s.pod.name", report.PodName))
+	}
+	if report.Namespace != "" {
+		attrs = append(attrs, stringAttr("k8s.namespace.name", report.Namespace))
+	}
+	return otlpResourceDesc{Attributes: attrs}
+}
+
+// otlpMetrics converts report counters into OTLP metrics.
+func otlpMetrics(report *Report, now time.Time) []otlpMetric {
+	start := unixNano(report.StartedAt)
+	ts := unixNano(now)
+
+	var uniqueFiles, events []otlpDataPoint
+	for _, c := range report.Containers {
+		attrs := []otlpKeyValue{stringAttr("k8s.container.name", c.Name)}
+		uniqueFiles = append(uniqueFiles, otlpDataPoint{
+			Attributes:   attrs,
+			TimeUnixNano: ts,
+			AsInt:        strconv.Itoa(c.UniqueFiles),
+		})
+		events = append(events, otlpDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      ts,
+			AsInt:             strconv.FormatUint(c.TotalEvents, 10),
+		})
+	}
+
+	return []otlpMetric{
+		{
+			Name:        "snoop.unique_files",
+			Description: "Number of unique files accessed by the container.",
+			Unit:        "{file}",
+			Gauge:       &otlpGauge{DataPoints: uniqueFiles},
+		},
+		{
+			Name:        "snoop.events",
+			Description: "Number of file access events received for the container.",
+			Unit:        "{event}",
+			Sum:         &otlpSum{DataPoints: events, AggregationTemporality: otlpCumulative, IsMonotonic: true},
+		},
+		{
+			Name:        "snoop.events.dropped",
+			Description: "Number of events dropped due to ring buffer overflow.",
+			Unit:        "{event}",
+			Sum: &otlpSum{
+				DataPoints: []otlpDataPoint{{
+					StartTimeUnixNano: start,
+					TimeUnixNano:      ts,
+					AsInt:             strconv.FormatUint(report.DroppedEvents, 10),
+				}},
+				AggregationTemporality: otlpCumulative,
+				IsMonotonic:            true,
+			},
+		},
+	}
+}
+
+// otlpSummaryLog builds a log record summarizing the report.
+func otlpSummaryLog(report *Report, now time.Time) otlpLogRecord {
+	totalFiles := 0
+	for _, c := range report.Containers {
+		totalFiles += c.UniqueFiles
+	}
+	return otlpLogRecord{
+		TimeUnixNano:   unixNano(now),
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+		Body: otlpAnyValue{StringValue: ptr(fmt.Sprintf("snoop report: %d containers, %d unique files, %d events, %d dropped",
+			len(report.Containers), totalFiles, report.TotalEvents, report.DroppedEvents))},
+		Attributes: []otlpKeyValue{
+			intAttr("snoop.containers", int64(len(report.Containers))),
+			intAttr("snoop.unique_files", int64(totalFiles)),
+			intAttr("snoop.events", int64(report.TotalEvents)),
+			intAttr("snoop.events.dropped", int64(report.DroppedEvents)),
+		},
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func stringAttr(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func intAttr(key string, value int64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: ptr(strconv.FormatInt(value, 10))}}
+}
+
+// OTLP/JSON wire types. Only the subset of the protocol used by snoop is modeled.
+// 64-bit integers are encoded as strings per the OTLP JSON mapping.
+
+const (
+	otlpCumulative   = 2 // AGGREGATION_TEMPORALITY_CUMULATIVE
+	otlpSeverityInfo = 9 // SEVERITY_NUMBER_INFO
+)
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResourceDesc   `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResourceDesc `json:"resource"`
+	ScopeLogs []otlpScopeLogs  `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpResourceDesc struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
@@ -0,0 +1,113 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOTLPReporterExportsMetricsAndLogs(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	bodies := map[string]map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", r.Header.Get("Content-Type"))
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding %s: %v", r.URL.Path, err)
+		}
+		mu.Lock()
+		bodies[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	r := NewOTLPReporter(ctx, server.URL+"/")
+	report := &Report{
+		PodName:   "my-app",
+		Namespace: "default",
+		StartedAt: time.Now().Add(-time.Minute),
+		Containers: []ContainerReport{
+			{Name: "app", CgroupID: 1000, Files: []string{"/a", "/b"}, TotalEvents: 10, UniqueFiles: 2},
+			{Name: "sidecar", CgroupID: 2000, Files: []string{"/c"}, TotalEvents: 5, UniqueFiles: 1},
+		},
+		TotalEvents:   15,
+		DroppedEvents: 1,
+	}
+	if err := r.Update(ctx, report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	metrics, ok := bodies["/v1/metrics"]
+	if !ok {
+		t.Fatal("no request to /v1/metrics")
+	}
+	rm := metrics["resourceMetrics"].([]any)[0].(map[string]any)
+	attrs := rm["resource"].(map[string]any)["attributes"].([]any)
+	foundPod := false
+	for _, a := range attrs {
+		kv := a.(map[string]any)
+		if kv["key"] == "k8s.pod.name" && kv["value"].(map[string]any)["stringValue"] == "my-app" {
+			foundPod = true
+		}
+	}
+	if !foundPod {
+		t.Errorf("resource attributes %v missing k8s.pod.name", attrs)
+	}
+
+	ms := rm["scopeMetrics"].([]any)[0].(map[string]any)["metrics"].([]any)
+	byName := map[string]map[string]any{}
+	for _, m := range ms {
+		mm := m.(map[string]any)
+		byName[mm["name"].(string)] = mm
+	}
+	uf, ok := byName["snoop.unique_files"]
+	if !ok {
+		t.Fatalf("missing snoop.unique_files metric in %v", byName)
+	}
+	points := uf["gauge"].(map[string]any)["dataPoints"].([]any)
+	if len(points) != 2 {
+		t.Errorf("unique_files data points = %d, want 2", len(points))
+	}
+	if points[0].(map[string]any)["asInt"] != "2" {
+		t.Errorf("unique_files[0] = %v, want \"2\"", points[0].(map[string]any)["asInt"])
+	}
+	if _, ok := byName["snoop.events"]["sum"]; !ok {
+		t.Error("snoop.events should be a sum")
+	}
+	if _, ok := byName["snoop.events.dropped"]; !ok {
+		t.Error("missing snoop.events.dropped metric")
+	}
+
+	logs, ok := bodies["/v1/logs"]
+	if !ok {
+		t.Fatal("no request to /v1/logs")
+	}
+	records := logs["resourceLogs"].([]any)[0].(map[string]any)["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)
+	if len(records) != 1 {
+		t.Fatalf("log records = %d, want 1", len(records))
+	}
+	if records[0].(map[string]any)["severityText"] != "INFO" {
+		t.Errorf("severityText = %v, want INFO", records[0].(map[string]any)["severityText"])
+	}
+}
+
+func TestOTLPReporterErrorStatus(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	r := NewOTLPReporter(ctx, server.URL)
+	if err := r.Update(ctx, &Report{StartedAt: time.Now()}); err == nil {
+		t.Error("expected error for 400 response")
+	}
+}
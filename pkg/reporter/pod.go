@@ -0,0 +1,108 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+)
+
+// SplitByPod splits a node-wide report into one report per pod, keyed by
+// pod UID. Each report's pod metadata comes from its containers, and its
+// TotalEvents is the sum of theirs. DroppedEvents is node-wide, since events
+// are dropped before snoop knows which container they belong to, so every
+// report carries the same count, as well as the node name and sampling.
+// Containers without a pod UID go into a report keyed by "" that keeps the
+// input's pod metadata, including its UID if set.
+func SplitByPod(report *Report) map[string]*Report {
+	result := make(map[string]*Report)
+	for _, c := range report.Containers {
+		r, ok := result[c.PodUID]
+		if !ok {
+			r = &Report{
+				SchemaVersion: report.SchemaVersion,
+				PodUID:        c.PodUID,
+				NodeName:      report.NodeName,
+				Agent:         report.Agent,
+				StartedAt:     report.StartedAt,
+				LastUpdatedAt: report.LastUpdatedAt,
+				LastNewFileAt: report.LastNewFileAt,
+				Converged:     report.Converged,
+				Containers:    []ContainerReport{},
+				DroppedEvents: report.DroppedEvents,
+				Sampling:      report.Sampling,
+			}
+			if c.PodUID == "" {
+				r.PodUID = report.PodUID
+				r.PodName = report.PodName
+				r.Namespace = report.Namespace
+				r.Labels = report.Labels
+			}
+			result[c.PodUID] = r
+		}
+		if r.PodName == "" {
+			r.PodName = c.PodName
+		}
+		if r.Namespace == "" {
+			r.Namespace = c.PodNamespace
+		}
+		r.Containers = append(r.Containers, c)
+		r.TotalEvents += c.TotalEvents
+	}
+	return result
+}
+
+// PodReportName returns the file name of a pod's report:
+// "<namespace>_<name>_<uid>.json", or "<uid>.json" when the pod's name
+// isn't known.
+func PodReportName(r *Report) string {
+	uid := r.PodUID
+	if uid == "" {
+		uid = "unknown"
+	}
+	if r.PodName == "" || r.Namespace == "" {
+		return uid + ".json"
+	}
+	return r.Namespace + "_" + r.PodName + "_" + uid + ".json"
+}
+
+// PodReporter writes each pod's part of a node-wide report to its own file
+// in a directory, named by PodReportName. Files of pods that went away are
+// left in place with their last report.
+type PodReporter struct {
+	dir  string
+	opts []FileReporterOption
+}
+
+// NewPodReporter creates a reporter that writes per-pod reports to dir,
+// with opts applied to each report file.
+func NewPodReporter(dir string, opts ...FileReporterOption) *PodReporter {
+	return &PodReporter{dir: dir, opts: opts}
+}
+
+// Update writes one file per pod in the report.
+func (r *PodReporter) Update(ctx context.Context, report *Report) error {
+	pods := SplitByPod(report)
+	uids := make([]string, 0, len(pods))
+	for uid := range pods {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	var errs []error
+	for _, uid := range uids {
+		fr := &FileReporter{ctx: ctx, path: filepath.Join(r.dir, PodReportName(pods[uid]))}
+		for _, opt := range r.opts {
+			opt(fr)
+		}
+		if err := fr.Update(ctx, pods[uid]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close is a no-op for PodReporter.
+func (r *PodReporter) Close() error {
+	return nil
+}
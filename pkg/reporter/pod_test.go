@@ -0,0 +1,55 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPodReporter(t *testing.T) {
+	report := &Report{
+		PodName:       "snoop-node",
+		Namespace:     "kube-system",
+		NodeName:      "node-1",
+		DroppedEvents: 7,
+		Containers: []ContainerReport{
+			{Name: "app", PodUID: "uid-a", PodName: "web", PodNamespace: "prod", TotalEvents: 10, Files: []string{"/a"}},
+			{Name: "sidecar", PodUID: "uid-a", PodName: "web", PodNamespace: "prod", TotalEvents: 5, Files: []string{"/b"}},
+			{Name: "worker", PodUID: "uid-b", TotalEvents: 3, Files: []string{"/c"}},
+		},
+	}
+	dir := t.TempDir()
+	r := NewPodReporter(dir)
+	if err := r.Update(context.Background(), report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	read := func(name string) *Report {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Report
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		return &got
+	}
+
+	web := read("prod_web_uid-a.json")
+	if web.PodUID != "uid-a" || web.PodName != "web" || web.Namespace != "prod" {
+		t.Errorf("web pod = %s/%s (%s)", web.Namespace, web.PodName, web.PodUID)
+	}
+	if len(web.Containers) != 2 || web.TotalEvents != 15 || web.DroppedEvents != 7 || web.NodeName != "node-1" {
+		t.Errorf("web report: %d containers, %d events, %d dropped, node %q", len(web.Containers), web.TotalEvents, web.DroppedEvents, web.NodeName)
+	}
+
+	// The pod's name wasn't resolved, so only its UID names the file
+	other := read("uid-b.json")
+	if len(other.Containers) != 1 || other.Containers[0].Name != "worker" || other.PodName != "" {
+		t.Errorf("other report = %+v", other)
+	}
+}
@@ -0,0 +1,143 @@
+package reporter
+
+import (
+	"sort"
+)
+
+// RemovablePackageSet is a group of unused packages that can be removed
+// together without breaking any package that stays installed.
+type RemovablePackageSet struct {
+	Manager    string   `json:"manager"`
+	Packages   []string `json:"packages"`
+	TotalFiles int      `json:"total_files"`
+	Size       int64    `json:"size,omitempty"` // Total installed size in bytes, if known
+
+	// Explicit lists the members that were requested directly; removing
+	// them from the image build removes the whole set. Empty means the set
+	// is orphaned dependencies that nothing installed requires.
+	Explicit []string `json:"explicit,omitempty"`
+}
+
+// RemovableSets finds unused packages that are safe to remove: a package is
+// removable if none of its files were accessed and every package depending
+// on it is removable too. Removable packages are grouped into sets that are
+// connected through dependencies, so each set can be removed on its own.
+// Packages that own no files (virtual packages like apk's .build-deps) are
+// kept while any of their dependencies must stay, since removing them
+// would take those dependencies with them.
+//
+// Only managers that report a dependency graph are considered; without one,
+// an unused package may still be required by a used one. Sets are sorted by
+// TotalFiles, largest first.
+func RemovableSets(pkgs []PackageReport) []RemovablePackageSet {
+	byManager := make(map[string][]PackageReport)
+	hasGraph := make(map[string]bool)
+	for _, p := range pkgs {
+		byManager[p.Manager] = append(byManager[p.Manager], p)
+		if p.Depends != nil {
+			hasGraph[p.Manager] = true
+		}
+	}
+
+	var sets []RemovablePackageSet
+	for manager, mpkgs := range byManager {
+		if hasGraph[manager] {
+			sets = append(sets, removableSets(manager, mpkgs)...)
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].TotalFiles != sets[j].TotalFiles {
+			return sets[i].TotalFiles > sets[j].TotalFiles
+		}
+		return sets[i].Packages[0] < sets[j].Packages[0]
+	})
+	return sets
+}
+
+// UnusedSize returns the total installed size of the packages that were
+// never accessed, for package managers that record sizes.
+func UnusedSize(pkgs []PackageReport) int64 {
+	var total int64
+	for _, p := range pkgs {
+		if !p.Used() {
+			total += p.Size
+		}
+	}
+	return total
+}
+
+// removableSets computes removable sets for the packages of one manager.
+func removableSets(manager string, pkgs []PackageReport) []RemovablePackageSet {
+	byName := make(map[string]PackageReport, len(pkgs))
+	dependents := make(map[string][]string)
+	for _, p := range pkgs {
+		byName[p.Name] = p
+		for _, d := range p.Depends {
+			dependents[d] = append(dependents[d], p.Name)
+		}
+	}
+
+	// Start from every unused package and drop those with a dependent that
+	// must stay, until nothing changes
+	removable := make(map[string]bool)
+	for _, p := range pkgs {
+		if !p.Used() {
+			removable[p.Name] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for name := range removable {
+			related := dependents[name]
+			if p := byName[name]; p.TotalFiles == 0 {
+				related = append(append([]string(nil), related...), p.Depends...)
+			}
+			for _, other := range related {
+				if !removable[other] {
+					delete(removable, name)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	// Group removable packages into connected components
+	visited := make(map[string]bool)
+	var names []string
+	for name := range removable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sets []RemovablePackageSet
+	for _, start := range names {
+		if visited[start] {
+			continue
+		}
+		set := RemovablePackageSet{Manager: manager}
+		stack := []string{start}
+		visited[start] = true
+		for len(stack) > 0 {
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			p := byName[name]
+			set.Packages = append(set.Packages, name)
+			set.TotalFiles += p.TotalFiles
+			set.Size += p.Size
+			if p.InstallReason == "explicit" {
+				set.Explicit = append(set.Explicit, name)
+			}
+			for _, next := range append(append([]string(nil), p.Depends...), dependents[name]...) {
+				if removable[next] && !visited[next] {
+					visited[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+		sort.Strings(set.Packages)
+		sort.Strings(set.Explicit)
+		sets = append(sets, set)
+	}
+	return sets
+}
@@ -0,0 +1,69 @@
+package reporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemovableSets(t *testing.T) {
+	pkgs := []PackageReport{
+		// app is used and keeps its dependencies installed
+		{Name: "app", Manager: "apk", TotalFiles: 3, AccessedFiles: 1, InstallReason: "explicit", Depends: []string{"musl", "libssl"}},
+		{Name: "musl", Manager: "apk", TotalFiles: 2, AccessedFiles: 1, InstallReason: "dependency", Depends: []string{}},
+		{Name: "libssl", Manager: "apk", TotalFiles: 4, InstallReason: "dependency", Depends: []string{"musl"}},
+		// curl and its unused dependency chain can go together
+		{Name: "curl", Manager: "apk", TotalFiles: 2, Size: 300, InstallReason: "explicit", Depends: []string{"libcurl", "musl"}},
+		{Name: "libcurl", Manager: "apk", TotalFiles: 5, Size: 700, InstallReason: "dependency", Depends: []string{"libssl", "nghttp2"}},
+		{Name: "nghttp2", Manager: "apk", TotalFiles: 1, InstallReason: "dependency", Depends: []string{"musl"}},
+		// An orphaned dependency nothing requires
+		{Name: "orphan", Manager: "apk", TotalFiles: 1, InstallReason: "dependency", Depends: []string{}},
+		// No dependency graph for dpkg, so its unused packages are skipped
+		{Name: "vim", Manager: "dpkg", TotalFiles: 20},
+	}
+
+	got := RemovableSets(pkgs)
+	want := []RemovablePackageSet{
+		{Manager: "apk", Packages: []string{"curl", "libcurl", "nghttp2"}, TotalFiles: 8, Size: 1000, Explicit: []string{"curl"}},
+		{Manager: "apk", Packages: []string{"orphan"}, TotalFiles: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RemovableSets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRemovableSetsUsedDependent(t *testing.T) {
+	// b is unused but a used package depends on it through an unused one
+	pkgs := []PackageReport{
+		{Name: "a", Manager: "apk", AccessedFiles: 1, Depends: []string{"b"}},
+		{Name: "b", Manager: "apk", Depends: []string{"c"}},
+		{Name: "c", Manager: "apk", Depends: []string{}},
+	}
+	if got := RemovableSets(pkgs); len(got) != 0 {
+		t.Errorf("RemovableSets() = %+v, want none", got)
+	}
+}
+
+func TestRemovableSetsVirtual(t *testing.T) {
+	// .build-deps owns no files; removing it would drop gcc, which is used
+	pkgs := []PackageReport{
+		{Name: ".build-deps", Manager: "apk", InstallReason: "explicit", Depends: []string{"gcc", "make"}},
+		{Name: "gcc", Manager: "apk", TotalFiles: 10, AccessedFiles: 1, Depends: []string{}},
+		{Name: "make", Manager: "apk", TotalFiles: 3, Depends: []string{}},
+	}
+	got := RemovableSets(pkgs)
+	if len(got) != 0 {
+		t.Errorf("RemovableSets() = %+v, want none", got)
+	}
+}
+
+func TestUnusedSize(t *testing.T) {
+	pkgs := []PackageReport{
+		{Name: "app", AccessedFiles: 1, Size: 5000},
+		{Name: "curl", Size: 300},
+		{Name: "vim", Size: 4000},
+		{Name: "unknown"},
+	}
+	if got := UnusedSize(pkgs); got != 4300 {
+		t.Errorf("UnusedSize() = %d, want 4300", got)
+	}
+}
@@ -1,11 +1,12 @@
 package reporter
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"time"
 
@@ -14,30 +15,421 @@ import (
 
 // Report represents the file access report for a pod with multiple containers.
 type Report struct {
+	// SchemaVersion identifies the report layout; see SchemaVersion for the
+	// compatibility policy.
+	SchemaVersion int `json:"schema_version"`
+
 	// Pod-level metadata
 	PodName   string `json:"pod_name,omitempty"`
 	Namespace string `json:"namespace,omitempty"`
+	PodUID    string `json:"pod_uid,omitempty"`
+
+	// NodeName is the node snoop ran on, and Labels are the labels of its
+	// pod (unset in node mode, where reports cover other pods).
+	NodeName string            `json:"node_name,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+
+	// Agent identifies the snoop build that wrote the report.
+	Agent *AgentInfo `json:"agent,omitempty"`
 
 	// Timing
 	StartedAt     time.Time `json:"started_at"`
 	LastUpdatedAt time.Time `json:"last_updated_at"`
 
+	// LastNewFileAt is when a file was last recorded for the first time,
+	// and Converged is set once none had been for the -stable-after window,
+	// signaling that the file set is unlikely to grow further.
+	LastNewFileAt *time.Time `json:"last_new_file_at,omitempty"`
+	Converged     bool       `json:"converged,omitempty"`
+
 	// Per-container data
 	Containers []ContainerReport `json:"containers"`
 
 	// Aggregate stats
 	TotalEvents   uint64 `json:"total_events"`
 	DroppedEvents uint64 `json:"dropped_events"`
+
+	// Sampling is set once events have been sampled under backpressure.
+	// Files accessed only in skipped events are missing from the report.
+	Sampling *SamplingInfo `json:"sampling,omitempty"`
+}
+
+// SamplingInfo records how far snoop degraded to sampling events because
+// it couldn't keep up with them.
+type SamplingInfo struct {
+	// SkippedEvents is how many events weren't processed.
+	SkippedEvents uint64 `json:"skipped_events"`
+
+	// Rate is the sampling rate when the report was written, 1 in Rate
+	// events being processed, and PeakRate the highest it reached.
+	Rate     int `json:"rate"`
+	PeakRate int `json:"peak_rate"`
+
+	// DegradedSeconds is how long events were sampled in total.
+	DegradedSeconds float64 `json:"degraded_seconds"`
+}
+
+// AgentInfo identifies a snoop build.
+type AgentInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+
+	// BPFObject is the sha256 digest of the eBPF object the build loads.
+	BPFObject string `json:"bpf_object,omitempty"`
+}
+
+// SetConvergence sets LastNewFileAt from when a file was last recorded for
+// the first time, or zero if none was, and sets Converged if none was
+// recorded within window of now, counting from StartedAt if none ever was.
+// A zero window never converges.
+func (r *Report) SetConvergence(lastNewFile time.Time, window time.Duration, now time.Time) {
+	stableSince := r.StartedAt
+	r.LastNewFileAt = nil
+	if !lastNewFile.IsZero() {
+		r.LastNewFileAt = &lastNewFile
+		stableSince = lastNewFile
+	}
+	r.Converged = window > 0 && now.Sub(stableSince) >= window
 }
 
 // ContainerReport represents the file access report for a single container.
 type ContainerReport struct {
-	Name        string   `json:"name"`
-	CgroupID    uint64   `json:"cgroup_id"`
-	CgroupPath  string   `json:"cgroup_path"`
+	Name       string `json:"name"`
+	CgroupID   uint64 `json:"cgroup_id"`
+	CgroupPath string `json:"cgroup_path"`
+
+	// ContainerID is the full runtime container ID, when the cgroup or
+	// runtime reveals it. Name is the Kubernetes (or Docker) container name
+	// when it could be resolved, and a short form of this ID otherwise.
+	ContainerID string `json:"container_id,omitempty"`
+
+	// PodUID, PodName, and PodNamespace identify the container's pod when
+	// snoop traces every pod on a node. The pod name and namespace come
+	// from the container runtime or kubelet and may be empty.
+	PodUID       string `json:"pod_uid,omitempty"`
+	PodName      string `json:"pod_name,omitempty"`
+	PodNamespace string `json:"pod_namespace,omitempty"`
+
+	// Ephemeral marks an ephemeral container, such as one added with
+	// kubectl debug, whose accesses say nothing about the app's image.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
+	// RestartCount is how many times the container restarted while traced.
+	// Files, packages, and counters include every run of the container.
+	RestartCount int `json:"restart_count,omitempty"`
+
+	// Image the container is running, resolved from the pod status when
+	// running in Kubernetes or from the -image/-image-digest flags.
+	ImageRef    string `json:"image_ref,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty"`
+
 	Files       []string `json:"files"`
 	TotalEvents uint64   `json:"total_events"`
 	UniqueFiles int      `json:"unique_files"`
+
+	// FilesTruncated counts files omitted from Files because the container
+	// exceeded the report's file limit; the most accessed files are kept.
+	// UniqueFiles still counts every tracked file.
+	FilesTruncated int `json:"files_truncated,omitempty"`
+
+	// Observations is how many containers, such as replicas of a pod, a
+	// merged report combines, and FileObservations how many of them
+	// accessed each file in Files that not all of them did. Both are only
+	// set by Merge; a file seen by few of many observations may only be
+	// needed on rare code paths, or not at all.
+	Observations     int            `json:"observations,omitempty"`
+	FileObservations map[string]int `json:"file_observations,omitempty"`
+
+	// EvictedFiles counts paths dropped from the deduplication cache due to
+	// the unique file limit. Non-zero means Files may be incomplete.
+	EvictedFiles uint64 `json:"evicted_files,omitempty"`
+
+	// PreexistingFiles lists the files in Files that the container already
+	// had open or mapped when snoop started tracing it, rather than ones
+	// it was seen accessing.
+	PreexistingFiles []string `json:"preexisting_files,omitempty"`
+
+	// FileMetadata maps file paths to their filesystem attributes.
+	// Only populated when metadata enrichment is enabled, and only for
+	// files that could be stat'd through the container's root filesystem.
+	FileMetadata map[string]FileMetadata `json:"file_metadata,omitempty"`
+
+	// FileDigests maps file paths to "sha256:<hex>" content digests.
+	// Only populated when content hashing is enabled.
+	FileDigests map[string]string `json:"file_digests,omitempty"`
+
+	// Layers lists the container's image layers, base first, with how many
+	// accessed files each provided. A layer providing no accessed files is
+	// dead weight. Only populated when layer attribution is enabled.
+	Layers []LayerReport `json:"layers,omitempty"`
+
+	// FileLayers maps file paths to the index of the layer providing them.
+	// Only populated when layer attribution is enabled.
+	FileLayers map[string]int `json:"file_layers,omitempty"`
+
+	// Packages lists every installed OS package with how much of it the
+	// container used. Only populated when package attribution is enabled
+	// and the container's package database could be read.
+	Packages []PackageReport `json:"packages,omitempty"`
+
+	// ModifiedFiles lists accessed package-owned files whose contents don't
+	// match the checksum recorded by their package manager. Only populated
+	// when package verification is enabled.
+	ModifiedFiles []ModifiedFile `json:"modified_files,omitempty"`
+
+	// UnownedExecutables lists the files the container executed that no
+	// installed package owns, such as binaries dropped into /tmp, a
+	// classic sign of compromise. Only populated when package attribution
+	// is enabled and the container's package database could be read.
+	UnownedExecutables []string `json:"unowned_executables,omitempty"`
+
+	// UnobservedLibraries lists the shared libraries that executed binaries
+	// need, directly or through other libraries, but that the container
+	// wasn't seen opening, such as the dynamic loader, which the kernel
+	// loads itself. An image slimmed to Files would fail to run them. Only
+	// populated when library dependency checking is enabled.
+	UnobservedLibraries []LibraryDependency `json:"unobserved_libraries,omitempty"`
+
+	// VolumeFiles lists the accessed files on volumes, such as mounted
+	// secrets, ConfigMaps, and scratch space. They aren't part of the
+	// image, so don't matter for slimming it, but show what the container
+	// read and wrote outside it. Only populated when volume classification
+	// is enabled.
+	VolumeFiles []VolumeFile `json:"volume_files,omitempty"`
+
+	// VolumeSummary counts the accessed files of each kind of volume, and
+	// those in the image under "image"; see SummarizeVolumes. Only
+	// populated when volume classification is enabled.
+	VolumeSummary map[string]int `json:"volume_summary,omitempty"`
+
+	// RemovablePackages groups unused packages that nothing used depends
+	// on, computed from the dependency graph in Packages. Each set can be
+	// removed independently of the others.
+	RemovablePackages []RemovablePackageSet `json:"removable_packages,omitempty"`
+
+	// UnusedPackageBytes is the total installed size of packages none of
+	// whose files were accessed: an estimate of what slimming could save.
+	UnusedPackageBytes int64 `json:"unused_package_bytes,omitempty"`
+
+	// UnusedFileBytes is the total size of the image's files that were
+	// never accessed, summed over the UnusedBytes of its image layers: the
+	// most slimming could save. Only populated when layer attribution is
+	// enabled.
+	UnusedFileBytes int64 `json:"unused_file_bytes,omitempty"`
+
+	// Coverage compares the accessed files with every regular file in the
+	// container's image layers, showing what the image holds but the
+	// container never used. Only populated when coverage analysis is
+	// enabled.
+	Coverage *Coverage `json:"coverage,omitempty"`
+
+	// PythonPackages lists pip packages found in site-packages directories
+	// the container accessed, with how much of each it used. Only populated
+	// when Python package attribution is enabled.
+	PythonPackages []PackageReport `json:"python_packages,omitempty"`
+
+	// PythonModules lists, by dotted name and sorted, the Python modules
+	// whose source or cached bytecode the container accessed. Only
+	// populated when Python package attribution is enabled.
+	PythonModules []string `json:"python_modules,omitempty"`
+
+	// Jars lists the jars on the classpath of the container's JVMs and the
+	// jars it opened, sorted by path. Only populated when Java classpath
+	// analysis is enabled.
+	Jars []JarReport `json:"jars,omitempty"`
+
+	// NpmPackages lists npm packages found in node_modules directories
+	// that the container accessed, when npm package attribution is enabled.
+	NpmPackages []PackageReport `json:"npm_packages,omitempty"`
+
+	// GoBinaries lists the Go binaries the container executed, with the
+	// build information embedded in each. Only populated when build info
+	// detection is enabled.
+	GoBinaries []GoBinary `json:"go_binaries,omitempty"`
+}
+
+// JarReport is a Java archive a container's JVMs had on their classpath or
+// opened.
+type JarReport struct {
+	Path string `json:"path"`
+
+	// OnClasspath is whether a JVM had the jar on its classpath, and Opened
+	// whether the container opened it. The JVM opens classpath jars in
+	// order while looking for a class, so an opened jar may still hold no
+	// class the application loaded.
+	OnClasspath bool `json:"on_classpath,omitempty"`
+	Opened      bool `json:"opened"`
+
+	// ClassesLoaded counts the classes a class-loading log showed loaded
+	// from the jar; see JarUsage. Snoop doesn't record it itself.
+	ClassesLoaded int `json:"classes_loaded,omitempty"`
+}
+
+// LibraryDependency is a shared library executed binaries need.
+type LibraryDependency struct {
+	// Name is the library as listed in the binary's DT_NEEDED entries, or
+	// the path of its program interpreter.
+	Name string `json:"name"`
+
+	// Path is where the dynamic loader would find the library, or empty if
+	// it isn't in the container.
+	Path string `json:"path,omitempty"`
+
+	RequiredBy []string `json:"required_by"`
+}
+
+// GoBinary describes an executed Go binary and the build that produced it.
+type GoBinary struct {
+	Path        string     `json:"path"`
+	GoVersion   string     `json:"go_version"`
+	Module      string     `json:"module,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	VCSRevision string     `json:"vcs_revision,omitempty"`
+	VCSTime     *time.Time `json:"vcs_time,omitempty"`
+	VCSModified bool       `json:"vcs_modified,omitempty"`
+}
+
+// Coverage counts the regular files in a container's image and how many of
+// them it accessed.
+type Coverage struct {
+	Files         int   `json:"files"`
+	AccessedFiles int   `json:"accessed_files"`
+	Bytes         int64 `json:"bytes"`
+	AccessedBytes int64 `json:"accessed_bytes"`
+
+	// Directories breaks the counts down by the directory directly
+	// containing each file, sorted by path.
+	Directories []DirectoryCoverage `json:"directories,omitempty"`
+
+	// LargestUnusedFiles lists the largest image files never accessed,
+	// largest first.
+	LargestUnusedFiles []ImageFile `json:"largest_unused_files,omitempty"`
+}
+
+// DirectoryCoverage counts the regular image files directly in a directory
+// and how many of them were accessed.
+type DirectoryCoverage struct {
+	Path          string `json:"path"`
+	Files         int    `json:"files"`
+	AccessedFiles int    `json:"accessed_files"`
+	Bytes         int64  `json:"bytes"`
+	AccessedBytes int64  `json:"accessed_bytes"`
+}
+
+// ImageFile is a regular file in a container's image.
+type ImageFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// LayerReport describes one layer of a container's root filesystem.
+type LayerReport struct {
+	// Index numbers layers from 1 for the base image layer.
+	Index int `json:"index"`
+
+	// Digest is the layer's diff ID, when the container runtime's layer
+	// metadata could be read.
+	Digest string `json:"digest,omitempty"`
+
+	// Dir is the layer's directory on the node.
+	Dir string `json:"dir"`
+
+	// Upper marks the container's writable layer rather than an image layer.
+	Upper bool `json:"upper,omitempty"`
+
+	AccessedFiles int `json:"accessed_files"`
+
+	// TotalFiles and TotalBytes count the regular files the layer provides
+	// to the container's filesystem, not counting ones a higher layer
+	// replaces or deletes, and UnusedBytes the size of those never
+	// accessed. Zero until the layer has been measured.
+	TotalFiles  int   `json:"total_files,omitempty"`
+	TotalBytes  int64 `json:"total_bytes,omitempty"`
+	UnusedBytes int64 `json:"unused_bytes,omitempty"`
+}
+
+// PackageReport describes a container's usage of one installed package.
+type PackageReport struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	Manager       string `json:"manager"`
+	TotalFiles    int    `json:"total_files"`
+	AccessedFiles int    `json:"accessed_files"`
+	AccessCount   uint64 `json:"access_count"`
+
+	// Origin, License, and Arch are the source package this package was
+	// built from, its license expression (e.g. "MIT AND BSD-3-Clause"), and
+	// its architecture. Empty when the package manager doesn't record them.
+	Origin  string `json:"origin,omitempty"`
+	License string `json:"license,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+
+	// Size is the package's installed size in bytes, when the package
+	// manager records it.
+	Size int64 `json:"size,omitempty"`
+
+	// Layer is the index of the image layer providing the package, when
+	// layer attribution is enabled.
+	Layer int `json:"layer,omitempty"`
+
+	// InstallReason is "explicit" for packages that were requested directly
+	// and "dependency" for ones pulled in by other packages. Empty when the
+	// package manager doesn't record it.
+	InstallReason string `json:"install_reason,omitempty"`
+
+	// Depends names the installed packages this package depends on. Only
+	// populated for package managers whose dependency graph is known.
+	Depends []string `json:"depends,omitempty"`
+
+	// Provides lists the virtual names the package provides (e.g.
+	// so:libssl.so.3), for package managers that record them.
+	Provides []string `json:"provides,omitempty"`
+
+	// UnusedFiles lists the package's files that were never accessed. Only
+	// populated when unused file reporting is enabled, since it can be large.
+	UnusedFiles []string `json:"unused_files,omitempty"`
+
+	// SampleFiles lists the most accessed files of the "(orphan)" entry,
+	// which accounts for accessed files no package owns.
+	SampleFiles []string `json:"sample_files,omitempty"`
+}
+
+// Used reports whether any of the package's files were accessed.
+func (p PackageReport) Used() bool {
+	return p.AccessedFiles > 0
+}
+
+// ModifiedFile is a package-owned file that changed after installation.
+type ModifiedFile struct {
+	Path     string `json:"path"`
+	Package  string `json:"package"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// VolumeFile is an accessed file on a volume rather than the image.
+type VolumeFile struct {
+	Path string `json:"path"`
+
+	// Volume is the kind of volume, such as "configMap", "secret", "pvc",
+	// "emptyDir", "hostPath", or "tmpfs" (see package mounts), and
+	// MountPoint where the volume is mounted in the container.
+	Volume     string `json:"volume"`
+	MountPoint string `json:"mount_point"`
+}
+
+// FileMetadata holds filesystem attributes of an accessed file.
+type FileMetadata struct {
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	UID     uint32    `json:"uid"`
+	GID     uint32    `json:"gid"`
+	ModTime time.Time `json:"mtime"`
+
+	// Label is the file's SELinux context, on hosts that label files.
+	Label string `json:"label,omitempty"`
 }
 
 // Reporter defines the interface for report output.
@@ -51,19 +443,35 @@ type Reporter interface {
 
 // FileReporter writes reports to a JSON file using atomic writes.
 type FileReporter struct {
-	ctx  context.Context
-	path string
+	ctx     context.Context
+	path    string
+	compact bool
+}
+
+// FileReporterOption configures a FileReporter.
+type FileReporterOption func(*FileReporter)
+
+// WithCompactOutput writes reports without indentation, which makes large
+// reports noticeably smaller on disk.
+func WithCompactOutput() FileReporterOption {
+	return func(r *FileReporter) {
+		r.compact = true
+	}
 }
 
 // NewFileReporter creates a reporter that writes to the given file path.
 // The file is written atomically using a temp file + rename.
-func NewFileReporter(ctx context.Context, path string) *FileReporter {
-	log := clog.FromContext(ctx)
-	log.Infof("Initialized file reporter (path: %s)", path)
-	return &FileReporter{
+func NewFileReporter(ctx context.Context, path string, opts ...FileReporterOption) *FileReporter {
+	r := &FileReporter{
 		ctx:  ctx,
 		path: path,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	log := clog.FromContext(ctx)
+	log.Infof("Initialized file reporter (path: %s, compact: %v)", path, r.compact)
+	return r
 }
 
 // Update writes the report to the file atomically.
@@ -83,21 +491,20 @@ func (r *FileReporter) Update(ctx context.Context, report *Report) error {
 	// Ensure each container's files are sorted
 	totalFiles := 0
 	for i := range reportCopy.Containers {
-		// Files should already be sorted from processor, but ensure it
-		sort.Strings(reportCopy.Containers[i].Files)
+		// Files should already be sorted from processor, but ensure it.
+		// The processor shares its lists between reports, so only sort a
+		// copy of one that isn't sorted
+		if files := reportCopy.Containers[i].Files; !sort.StringsAreSorted(files) {
+			files = slices.Clone(files)
+			sort.Strings(files)
+			reportCopy.Containers[i].Files = files
+		}
 		totalFiles += len(reportCopy.Containers[i].Files)
 	}
 
+	reportCopy.SchemaVersion = SchemaVersion
 	reportCopy.LastUpdatedAt = time.Now()
 
-	// Marshal to JSON with indentation for readability
-	data, err := json.MarshalIndent(&reportCopy, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshaling report: %w", err)
-	}
-
-	log.Debugf("Marshaled report: %d bytes, %d containers, %d total files", len(data), len(reportCopy.Containers), totalFiles)
-
 	// Write atomically: write to temp file, then rename
 	dir := filepath.Dir(r.path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -119,7 +526,14 @@ func (r *FileReporter) Update(ctx context.Context, report *Report) error {
 		}
 	}()
 
-	if _, err := tmpFile.Write(data); err != nil {
+	// Stream the report to the file a container and a file at a time; the
+	// whole document, encoded, would be several times its file lists' size
+	bw := bufio.NewWriter(tmpFile)
+	if err := encodeReport(bw, &reportCopy, r.compact); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("encoding report: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
 		tmpFile.Close()
 		return fmt.Errorf("writing temp file: %w", err)
 	}
@@ -134,7 +548,7 @@ func (r *FileReporter) Update(ctx context.Context, report *Report) error {
 	}
 
 	tmpPath = "" // Prevent cleanup since rename succeeded
-	log.Debug("Report written successfully")
+	log.Debugf("Report written successfully: %d containers, %d total files", len(reportCopy.Containers), totalFiles)
 	return nil
 }
 
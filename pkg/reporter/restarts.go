@@ -0,0 +1,99 @@
+package reporter
+
+import (
+	"sync"
+	"time"
+)
+
+// Restarts carries what containers accessed across restarts. A restarted
+// container gets a new cgroup and is tracked afresh, so without this each
+// restart would start a new, partial report. Instead, the last report of a
+// container that went away is kept, and folded into the report of the next
+// container with the same pod UID and name, whose RestartCount is one more.
+//
+// Reports of containers that don't come back within the window are
+// forgotten. Restarts is safe for concurrent use.
+type Restarts struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	previous map[restartKey]*retiredContainer
+}
+
+// restartKey identifies a container across restarts.
+type restartKey struct {
+	podUID, name string
+}
+
+// retiredContainer is the last report of a container that went away.
+type retiredContainer struct {
+	report    ContainerReport
+	retiredAt time.Time
+	claimed   bool // A new container with the same key was seen
+}
+
+// NewRestarts returns a Restarts that forgets containers that don't come
+// back within window. Kubernetes restarts crashing containers with a
+// backoff of up to 5 minutes.
+func NewRestarts(window time.Duration) *Restarts {
+	return &Restarts{
+		window:   window,
+		previous: make(map[restartKey]*retiredContainer),
+	}
+}
+
+// Retire records the last report of a container that went away, including
+// anything carried over from its own previous runs.
+func (r *Restarts) Retire(c ContainerReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.previous[restartKey{c.PodUID, c.Name}] = &retiredContainer{report: c, retiredAt: time.Now()}
+}
+
+// Apply folds the reports of previous runs into the reports of containers
+// that restarted, in place, and forgets containers that went away more than
+// the window ago without coming back.
+func (r *Restarts) Apply(containers []ContainerReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range containers {
+		prev, ok := r.previous[restartKey{c.PodUID, c.Name}]
+		if !ok || prev.report.CgroupID == c.CgroupID {
+			continue
+		}
+		prev.claimed = true
+		containers[i] = carryOver(prev.report, c)
+	}
+	for key, prev := range r.previous {
+		if !prev.claimed && time.Since(prev.retiredAt) > r.window {
+			delete(r.previous, key)
+		}
+	}
+}
+
+// carryOver returns cur with the report of the same container's previous
+// run folded in: files, packages, and counters are merged as for replicas,
+// while identity, layers, coverage, and cgroup come from cur.
+func carryOver(prev, cur ContainerReport) ContainerReport {
+	merged := Merge(
+		&Report{Containers: []ContainerReport{prev}},
+		&Report{Containers: []ContainerReport{cur}},
+	).Containers[0]
+	merged.CgroupID = cur.CgroupID
+	merged.CgroupPath = cur.CgroupPath
+	merged.ContainerID = cur.ContainerID
+	merged.PodUID = cur.PodUID
+	merged.PodName = cur.PodName
+	merged.PodNamespace = cur.PodNamespace
+	merged.ImageRef = cur.ImageRef
+	merged.ImageDigest = cur.ImageDigest
+	merged.Layers = cur.Layers
+	merged.FileLayers = cur.FileLayers
+	merged.UnusedFileBytes = cur.UnusedFileBytes
+	merged.Coverage = cur.Coverage
+	// Runs of one container aren't independent observations
+	merged.Observations = cur.Observations
+	merged.FileObservations = cur.FileObservations
+	merged.RestartCount = prev.RestartCount + 1
+	return merged
+}
@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRestarts(t *testing.T) {
+	r := NewRestarts(10 * time.Minute)
+	r.Retire(ContainerReport{Name: "app", PodUID: "uid-a", CgroupID: 1, TotalEvents: 10, EvictedFiles: 1, Files: []string{"/a", "/b"}})
+	r.Retire(ContainerReport{Name: "app", PodUID: "uid-b", CgroupID: 2, Files: []string{"/other-pod"}})
+
+	containers := []ContainerReport{
+		{Name: "app", PodUID: "uid-a", CgroupID: 3, CgroupPath: "/new", TotalEvents: 5, Files: []string{"/b", "/c"}},
+		{Name: "sidecar", PodUID: "uid-a", CgroupID: 4, Files: []string{"/s"}},
+	}
+	r.Apply(containers)
+
+	app := containers[0]
+	if want := []string{"/a", "/b", "/c"}; !reflect.DeepEqual(app.Files, want) {
+		t.Errorf("Files = %v, want %v", app.Files, want)
+	}
+	if app.RestartCount != 1 || app.TotalEvents != 15 || app.UniqueFiles != 3 || app.EvictedFiles != 1 {
+		t.Errorf("restarted container = %+v, want counters carried over", app)
+	}
+	if app.Observations != 0 || app.FileObservations != nil {
+		t.Errorf("restarted container observations = %d %v, want none", app.Observations, app.FileObservations)
+	}
+	if app.CgroupID != 3 || app.CgroupPath != "/new" || app.PodUID != "uid-a" {
+		t.Errorf("restarted container identity = %d %q %q, want the new run's", app.CgroupID, app.CgroupPath, app.PodUID)
+	}
+	if !reflect.DeepEqual(containers[1].Files, []string{"/s"}) || containers[1].RestartCount != 0 {
+		t.Errorf("sidecar = %+v, want unchanged", containers[1])
+	}
+
+	// The restarted container exits and restarts again, carrying both runs
+	r.Retire(app)
+	containers = []ContainerReport{{Name: "app", PodUID: "uid-a", CgroupID: 5, Files: []string{"/d"}}}
+	r.Apply(containers)
+	if containers[0].RestartCount != 2 || len(containers[0].Files) != 4 {
+		t.Errorf("second restart = %+v, want 2 restarts and 4 files", containers[0])
+	}
+}
+
+func TestRestartsSameCgroup(t *testing.T) {
+	// The retired container can still be listed in the report that retires it
+	r := NewRestarts(10 * time.Minute)
+	c := ContainerReport{Name: "app", CgroupID: 1, Files: []string{"/a"}}
+	r.Retire(c)
+	containers := []ContainerReport{c}
+	r.Apply(containers)
+	if containers[0].RestartCount != 0 {
+		t.Errorf("RestartCount = %d, want 0", containers[0].RestartCount)
+	}
+}
+
+func TestRestartsWindow(t *testing.T) {
+	r := NewRestarts(time.Minute)
+	r.Retire(ContainerReport{Name: "gone", CgroupID: 1})
+	r.Retire(ContainerReport{Name: "restarted", CgroupID: 2})
+	for _, prev := range r.previous {
+		prev.retiredAt = time.Now().Add(-2 * time.Minute)
+	}
+
+	// A container that came back in time keeps its previous runs
+	r.Apply([]ContainerReport{{Name: "restarted", CgroupID: 3}})
+	r.Apply(nil)
+	if _, ok := r.previous[restartKey{name: "gone"}]; ok {
+		t.Error("container that didn't come back within the window is still remembered")
+	}
+	if _, ok := r.previous[restartKey{name: "restarted"}]; !ok {
+		t.Error("restarted container's previous runs were forgotten")
+	}
+}
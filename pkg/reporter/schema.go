@@ -0,0 +1,121 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Report schema versions.
+//
+// Compatibility policy: the schema version is bumped whenever a field is
+// removed, renamed, or changes meaning, or when the document is restructured
+// (as happened when reports moved from a single top-level file list to
+// per-container entries). Adding new optional fields does not bump the
+// version, so consumers must ignore fields they don't recognize.
+// Decode accepts every version listed here and upgrades it to the current
+// in-memory Report type.
+const (
+	// SchemaV1 is the original single-container report format, which had no
+	// schema_version field and a top-level "files" list.
+	SchemaV1 = 1
+
+	// SchemaV2 is the multi-container report format with per-container entries.
+	SchemaV2 = 2
+
+	// SchemaVersion is the version written by this build of snoop.
+	SchemaVersion = SchemaV2
+)
+
+// reportV1 is the on-disk layout of a SchemaV1 report.
+type reportV1 struct {
+	ContainerID   string            `json:"container_id"`
+	ImageRef      string            `json:"image_ref"`
+	ImageDigest   string            `json:"image_digest,omitempty"`
+	PodName       string            `json:"pod_name,omitempty"`
+	Namespace     string            `json:"namespace,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	StartedAt     time.Time         `json:"started_at"`
+	LastUpdatedAt time.Time         `json:"last_updated_at"`
+	Files         []string          `json:"files"`
+	TotalEvents   uint64            `json:"total_events"`
+	DroppedEvents uint64            `json:"dropped_events"`
+}
+
+// Decode reads a report of any supported schema version and returns it in the
+// current Report format. Reports without a schema_version field are detected
+// as SchemaV1 if they have a top-level file list, and SchemaV2 otherwise.
+func Decode(r io.Reader) (*Report, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+	return DecodeBytes(data)
+}
+
+// DecodeBytes is like Decode but operates on an in-memory document.
+func DecodeBytes(data []byte) (*Report, error) {
+	var probe struct {
+		SchemaVersion *int            `json:"schema_version"`
+		Files         json.RawMessage `json:"files"`
+		Containers    json.RawMessage `json:"containers"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("parsing report: %w", err)
+	}
+
+	version := SchemaV2
+	switch {
+	case probe.SchemaVersion != nil:
+		version = *probe.SchemaVersion
+	case probe.Files != nil && probe.Containers == nil:
+		version = SchemaV1
+	}
+
+	switch version {
+	case SchemaV1:
+		var v1 reportV1
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, fmt.Errorf("parsing v1 report: %w", err)
+		}
+		return upgradeV1(&v1), nil
+	case SchemaV2:
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("parsing v2 report: %w", err)
+		}
+		report.SchemaVersion = SchemaV2
+		if report.Containers == nil {
+			report.Containers = []ContainerReport{}
+		}
+		return &report, nil
+	default:
+		return nil, fmt.Errorf("unsupported report schema version %d (this build supports up to %d)", version, SchemaVersion)
+	}
+}
+
+// upgradeV1 converts a single-container report into a one-entry multi-container report.
+func upgradeV1(v1 *reportV1) *Report {
+	files := v1.Files
+	if files == nil {
+		files = []string{}
+	}
+	return &Report{
+		SchemaVersion: SchemaVersion,
+		PodName:       v1.PodName,
+		Namespace:     v1.Namespace,
+		StartedAt:     v1.StartedAt,
+		LastUpdatedAt: v1.LastUpdatedAt,
+		Containers: []ContainerReport{{
+			Name:        v1.ContainerID,
+			ImageRef:    v1.ImageRef,
+			ImageDigest: v1.ImageDigest,
+			Files:       files,
+			TotalEvents: v1.TotalEvents,
+			UniqueFiles: len(files),
+		}},
+		TotalEvents:   v1.TotalEvents,
+		DroppedEvents: v1.DroppedEvents,
+	}
+}
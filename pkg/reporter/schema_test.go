@@ -0,0 +1,124 @@
+package reporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeV1(t *testing.T) {
+	input := `{
+  "container_id": "abc123",
+  "image_ref": "nginx:latest",
+  "pod_name": "my-app",
+  "namespace": "default",
+  "started_at": "2024-01-15T10:00:00Z",
+  "last_updated_at": "2024-01-15T10:05:00Z",
+  "files": ["/etc/nginx/nginx.conf", "/usr/sbin/nginx"],
+  "total_events": 42,
+  "dropped_events": 3
+}`
+
+	got, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if got.SchemaVersion != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", got.SchemaVersion, SchemaVersion)
+	}
+	if got.PodName != "my-app" {
+		t.Errorf("PodName = %q, want my-app", got.PodName)
+	}
+	if got.TotalEvents != 42 {
+		t.Errorf("TotalEvents = %d, want 42", got.TotalEvents)
+	}
+	if got.DroppedEvents != 3 {
+		t.Errorf("DroppedEvents = %d, want 3", got.DroppedEvents)
+	}
+	if len(got.Containers) != 1 {
+		t.Fatalf("len(Containers) = %d, want 1", len(got.Containers))
+	}
+	c := got.Containers[0]
+	if c.Name != "abc123" {
+		t.Errorf("Container name = %q, want abc123", c.Name)
+	}
+	if c.ImageRef != "nginx:latest" {
+		t.Errorf("Container ImageRef = %q, want nginx:latest", c.ImageRef)
+	}
+	if len(c.Files) != 2 || c.UniqueFiles != 2 {
+		t.Errorf("Container files = %v (unique %d), want 2 files", c.Files, c.UniqueFiles)
+	}
+}
+
+func TestDecodeV2WithoutVersion(t *testing.T) {
+	input := `{
+  "pod_name": "my-app",
+  "started_at": "2024-01-15T10:00:00Z",
+  "last_updated_at": "2024-01-15T10:05:00Z",
+  "containers": [
+    {"name": "app", "cgroup_id": 1000, "cgroup_path": "/pod/app", "files": ["/bin/sh"], "total_events": 1, "unique_files": 1}
+  ],
+  "total_events": 1,
+  "dropped_events": 0
+}`
+
+	got, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got.SchemaVersion != SchemaV2 {
+		t.Errorf("SchemaVersion = %d, want %d", got.SchemaVersion, SchemaV2)
+	}
+	if len(got.Containers) != 1 || got.Containers[0].CgroupID != 1000 {
+		t.Errorf("Containers = %+v, want one container with cgroup 1000", got.Containers)
+	}
+}
+
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	_, err := Decode(strings.NewReader(`{"schema_version": 99, "containers": []}`))
+	if err == nil {
+		t.Fatal("expected error for unsupported schema version")
+	}
+}
+
+func TestDecodeInvalidJSON(t *testing.T) {
+	_, err := Decode(strings.NewReader(`not json`))
+	if err == nil {
+		t.Fatal("expected error for invalid JSON")
+	}
+}
+
+func TestFileReporterWritesSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+
+	r := NewFileReporter(ctx, reportPath)
+	report := &Report{
+		StartedAt:  time.Now(),
+		Containers: []ContainerReport{{Name: "app", CgroupID: 1000, Files: []string{"/a"}}},
+	}
+	if err := r.Update(ctx, report); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	f, err := os.Open(reportPath)
+	if err != nil {
+		t.Fatalf("opening report: %v", err)
+	}
+	defer f.Close()
+
+	got, err := Decode(f)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got.SchemaVersion != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", got.SchemaVersion, SchemaVersion)
+	}
+	if len(got.Containers) != 1 || got.Containers[0].Name != "app" {
+		t.Errorf("Containers = %+v, want one container named app", got.Containers)
+	}
+}
@@ -0,0 +1,201 @@
+package reporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SELinuxOptions configures the policy module written by WriteSELinuxPolicy.
+type SELinuxOptions struct {
+	// Module names the policy module; "snoop_<container>" if empty.
+	Module string
+
+	// Domain is the SELinux type the container's processes run as, and
+	// FileType the type assumed for files whose label wasn't recorded.
+	Domain   string
+	FileType string
+}
+
+const (
+	defaultSELinuxDomain   = "container_t"
+	defaultSELinuxFileType = "container_file_t"
+)
+
+// selinuxPerms are the permissions granted for each object class. snoop
+// sees which files were opened and executed but not how, so nothing that
+// modifies a file is granted.
+var selinuxPerms = map[string][]string{
+	"file":      {"getattr", "open", "read"},
+	"dir":       {"getattr", "open", "read", "search"},
+	"lnk_file":  {"getattr", "read"},
+	"chr_file":  {"getattr", "open", "read"},
+	"blk_file":  {"getattr", "open", "read"},
+	"fifo_file": {"getattr", "open", "read"},
+	"sock_file": {"getattr", "read"},
+}
+
+// selinuxExecPerms are added for executed files.
+var selinuxExecPerms = []string{"execute", "execute_no_trans", "map"}
+
+// selinuxClass returns the object class of a file with mode, a string as
+// formatted by os.FileMode, or "file" if the mode wasn't recorded.
+func selinuxClass(mode string) string {
+	if len(mode) < 9 {
+		return "file"
+	}
+	typ := mode[:len(mode)-9]
+	switch {
+	case strings.Contains(typ, "d"):
+		return "dir"
+	case strings.Contains(typ, "L"):
+		return "lnk_file"
+	case strings.Contains(typ, "S"):
+		return "sock_file"
+	case strings.Contains(typ, "p"):
+		return "fifo_file"
+	case strings.Contains(typ, "D") && strings.Contains(typ, "c"):
+		return "chr_file"
+	case strings.Contains(typ, "D"):
+		return "blk_file"
+	}
+	return "file"
+}
+
+// selinuxType returns the type field of an SELinux context such as
+// "system_u:object_r:container_file_t:s0:c1,c2", or "" if it has none.
+func selinuxType(label string) string {
+	fields := strings.SplitN(label, ":", 4)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+// selinuxName makes s usable as an SELinux identifier.
+func selinuxName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, s)
+}
+
+// WriteSELinuxPolicy writes an SELinux type enforcement (.te) policy module
+// allowing the container's domain the file access observed in c. SELinux
+// rules apply to types rather than paths, so files are grouped by type
+// (from their recorded label, or opts.FileType) and object class (from
+// their recorded mode), and each rule is preceded by the paths it covers.
+// Files listed in GoBinaries and UnownedExecutables may also be executed.
+func WriteSELinuxPolicy(w io.Writer, c ContainerReport, opts SELinuxOptions) error {
+	if len(c.Files) == 0 {
+		return fmt.Errorf("container %s has no accessed files", c.Name)
+	}
+	if opts.Module == "" {
+		opts.Module = "snoop_" + selinuxName(c.Name)
+	}
+	if opts.Domain == "" {
+		opts.Domain = defaultSELinuxDomain
+	}
+	if opts.FileType == "" {
+		opts.FileType = defaultSELinuxFileType
+	}
+
+	executed := make(map[string]bool)
+	for _, b := range c.GoBinaries {
+		executed[b.Path] = true
+	}
+	for _, path := range c.UnownedExecutables {
+		executed[path] = true
+	}
+
+	type ruleKey struct {
+		fileType, class string
+		exec            bool
+	}
+	rules := make(map[ruleKey][]string)
+	types := map[string]bool{opts.Domain: true}
+	classes := make(map[string]map[string]bool)
+	for _, path := range c.Files {
+		md := c.FileMetadata[path]
+		key := ruleKey{fileType: selinuxType(md.Label), class: selinuxClass(md.Mode)}
+		if key.fileType == "" {
+			key.fileType = opts.FileType
+		}
+		key.exec = key.class == "file" && executed[path]
+		rules[key] = append(rules[key], path)
+
+		types[key.fileType] = true
+		if classes[key.class] == nil {
+			classes[key.class] = make(map[string]bool)
+		}
+		for _, perm := range selinuxRulePerms(key.class, key.exec) {
+			classes[key.class][perm] = true
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Generated by snoop from observed file access of container %s", c.Name)
+	if c.ImageRef != "" {
+		fmt.Fprintf(bw, " (%s)", c.ImageRef)
+	}
+	fmt.Fprintf(bw, ".\n# snoop doesn't see whether files were written, so no write permissions are\n# granted; add them for files the app modifies.\n")
+	fmt.Fprintf(bw, "module %s 1.0;\n\nrequire {\n", opts.Module)
+	for _, t := range sortedKeys(types) {
+		fmt.Fprintf(bw, "\ttype %s;\n", t)
+	}
+	for _, class := range sortedKeys(classes) {
+		fmt.Fprintf(bw, "\tclass %s { %s };\n", class, strings.Join(sortedKeys(classes[class]), " "))
+	}
+	fmt.Fprintf(bw, "}\n")
+
+	keys := make([]ruleKey, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.fileType != b.fileType {
+			return a.fileType < b.fileType
+		}
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		return !a.exec && b.exec
+	})
+	for _, key := range keys {
+		fmt.Fprintf(bw, "\n")
+		for _, path := range rules[key] {
+			fmt.Fprintf(bw, "# %s\n", path)
+		}
+		fmt.Fprintf(bw, "allow %s %s:%s { %s };\n", opts.Domain, key.fileType, key.class, strings.Join(selinuxRulePerms(key.class, key.exec), " "))
+	}
+	return bw.Flush()
+}
+
+// selinuxRulePerms returns the sorted permissions granted on files of class,
+// including execution if exec is set.
+func selinuxRulePerms(class string, exec bool) []string {
+	perms := append([]string(nil), selinuxPerms[class]...)
+	if exec {
+		perms = append(perms, selinuxExecPerms...)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
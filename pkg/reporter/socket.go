@@ -0,0 +1,100 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+// SocketReporter serves the latest report over HTTP on a unix domain socket,
+// so co-located agents can pull current state without a shared volume:
+//
+//	curl --unix-socket /run/snoop/report.sock http://snoop/report
+type SocketReporter struct {
+	path     string
+	listener net.Listener
+	server   *http.Server
+
+	mu     sync.RWMutex
+	latest []byte // JSON-encoded latest report, nil until the first Update
+}
+
+// NewSocketReporter listens on the unix socket at path and starts serving.
+// A stale socket file left at path by a previous run is removed.
+func NewSocketReporter(ctx context.Context, path string) (*SocketReporter, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket %s: %w", path, err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", path, err)
+	}
+
+	r := &SocketReporter{path: path, listener: ln}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /report", r.serveReport)
+	r.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	log := clog.FromContext(ctx)
+	go func() {
+		if err := r.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Report socket server error: %v", err)
+		}
+	}()
+	log.Infof("Initialized socket reporter (path: %s)", path)
+	return r, nil
+}
+
+// Update replaces the report served on the socket.
+func (r *SocketReporter) Update(ctx context.Context, report *Report) error {
+	reportCopy := *report
+	reportCopy.SchemaVersion = SchemaVersion
+	reportCopy.LastUpdatedAt = time.Now()
+
+	data, err := json.Marshal(&reportCopy)
+	if err != nil {
+		return fmt.Errorf("marshaling report: %w", err)
+	}
+
+	r.mu.Lock()
+	r.latest = data
+	r.mu.Unlock()
+	return nil
+}
+
+// Close stops serving and removes the socket file.
+func (r *SocketReporter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := r.server.Shutdown(ctx)
+	// The listener removes the socket file on close, but not if the
+	// server was never started; remove it explicitly either way
+	if rmErr := os.Remove(r.path); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+func (r *SocketReporter) serveReport(w http.ResponseWriter, _ *http.Request) {
+	r.mu.RLock()
+	data := r.latest
+	r.mu.RUnlock()
+
+	if data == nil {
+		http.Error(w, "no report available yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
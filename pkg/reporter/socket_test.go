@@ -0,0 +1,92 @@
+package reporter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocketReporter(t *testing.T) {
+	ctx := context.Background()
+	// Unix socket paths are length-limited, so avoid t.TempDir's long names
+	dir, err := os.MkdirTemp("", "snoop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.sock")
+
+	r, err := NewSocketReporter(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSocketReporter failed: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := client.Get("http://snoop/report")
+	if err != nil {
+		t.Fatalf("GET before Update: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status before Update = %d, want 503", resp.StatusCode)
+	}
+
+	if err := r.Update(ctx, &Report{PodName: "my-app", Containers: []ContainerReport{{Name: "app", Files: []string{"/bin/app"}}}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	resp, err = client.Get("http://snoop/report")
+	if err != nil {
+		t.Fatalf("GET after Update: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	got, err := Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got.PodName != "my-app" || got.SchemaVersion != SchemaVersion || len(got.Containers) != 1 {
+		t.Errorf("served report = %+v", got)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file still exists after Close")
+	}
+}
+
+func TestSocketReporterReplacesStaleSocket(t *testing.T) {
+	ctx := context.Background()
+	dir, err := os.MkdirTemp("", "snoop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.sock")
+
+	// Leave a socket file behind, as a crashed process would
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	r, err := NewSocketReporter(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSocketReporter with stale socket failed: %v", err)
+	}
+	r.Close()
+}
@@ -0,0 +1,141 @@
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// encodeReport writes report to w as json.Encoder would, indented by two
+// spaces unless compact, but one container and one file at a time, so that
+// no more than a single container's metadata, without its file list, is
+// ever held in encoded form.
+func encodeReport(w *bufio.Writer, report *Report, compact bool) error {
+	indent := "  "
+	if compact {
+		indent = ""
+	}
+	header := *report
+	header.Containers = nil
+	err := streamField(w, &header, "containers", "", indent, func(prefix string) error {
+		return streamArray(w, len(report.Containers), prefix, indent, func(i int, prefix string) error {
+			c := report.Containers[i]
+			files := c.Files
+			c.Files = nil
+			return streamField(w, &c, "files", prefix, indent, func(prefix string) error {
+				if files == nil {
+					_, err := w.WriteString("null")
+					return err
+				}
+				return streamArray(w, len(files), prefix, indent, func(i int, prefix string) error {
+					return writeJSON(w, files[i], prefix, indent)
+				})
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+// streamField writes v, a struct whose field key has been cleared to null,
+// with that field's value written by value instead. Lines of v after the
+// first begin with prefix, and value is passed the prefix of the field's
+// lines.
+func streamField(w *bufio.Writer, v any, key, prefix, indent string, value func(prefix string) error) error {
+	data, err := marshalJSON(v, prefix, indent)
+	if err != nil {
+		return err
+	}
+	end, err := fieldEnd(data, key)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data[:end-len("null")]); err != nil {
+		return err
+	}
+	fieldPrefix := prefix
+	if indent != "" {
+		fieldPrefix += indent
+	}
+	if err := value(fieldPrefix); err != nil {
+		return err
+	}
+	_, err = w.Write(data[end:])
+	return err
+}
+
+// streamArray writes an array of n elements, each written by elem, laid out
+// as json.MarshalIndent would for an array whose first line begins with
+// prefix. elem is passed the prefix of the element's lines.
+func streamArray(w *bufio.Writer, n int, prefix, indent string, elem func(i int, prefix string) error) error {
+	if n == 0 {
+		_, err := w.WriteString("[]")
+		return err
+	}
+	elemPrefix := prefix + indent
+	sep, open, close := ",", "[", "]"
+	if indent != "" {
+		sep = ",\n" + elemPrefix
+		open = "[\n" + elemPrefix
+		close = "\n" + prefix + "]"
+	}
+	if _, err := w.WriteString(open); err != nil {
+		return err
+	}
+	for i := range n {
+		if i > 0 {
+			if _, err := w.WriteString(sep); err != nil {
+				return err
+			}
+		}
+		if err := elem(i, elemPrefix); err != nil {
+			return err
+		}
+	}
+	_, err := w.WriteString(close)
+	return err
+}
+
+// writeJSON writes v as JSON, indented like marshalJSON.
+func writeJSON(w *bufio.Writer, v any, prefix, indent string) error {
+	data, err := marshalJSON(v, prefix, indent)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// marshalJSON is json.MarshalIndent, or json.Marshal if indent is empty.
+func marshalJSON(v any, prefix, indent string) ([]byte, error) {
+	if indent == "" {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, prefix, indent)
+}
+
+// fieldEnd returns the offset just past the value of the top-level field
+// key in the JSON object data.
+func fieldEnd(data []byte, key string) (int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return 0, err
+	}
+	for dec.More() {
+		name, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, err
+		}
+		if name == key {
+			return int(dec.InputOffset()), nil
+		}
+	}
+	return 0, fmt.Errorf("field %q not found", key)
+}
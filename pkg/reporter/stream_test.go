@@ -0,0 +1,57 @@
+package reporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEncodeReport(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	reports := map[string]*Report{
+		"empty": {SchemaVersion: SchemaVersion, Containers: []ContainerReport{}},
+		"full": {
+			SchemaVersion: SchemaVersion,
+			PodName:       "web-1",
+			LastNewFileAt: &now,
+			Containers: []ContainerReport{
+				{
+					Name:         "app",
+					CgroupID:     1,
+					Files:        []string{"/bin/app", "/etc/<config>.json", "/opt/\"quoted\" é"},
+					FileMetadata: map[string]FileMetadata{"/bin/app": {Size: 42, Mode: "-rwxr-xr-x", ModTime: now}},
+					Packages:     []PackageReport{{Name: "musl", Manager: "apk", TotalFiles: 2, AccessedFiles: 1}},
+				},
+				{Name: "idle", CgroupID: 2},
+				{Name: "none", CgroupID: 3, Files: []string{}},
+			},
+			TotalEvents: 7,
+		},
+	}
+	for name, report := range reports {
+		for _, compact := range []bool{false, true} {
+			var want bytes.Buffer
+			enc := json.NewEncoder(&want)
+			if !compact {
+				enc.SetIndent("", "  ")
+			}
+			if err := enc.Encode(report); err != nil {
+				t.Fatal(err)
+			}
+
+			var got bytes.Buffer
+			w := bufio.NewWriter(&got)
+			if err := encodeReport(w, report, compact); err != nil {
+				t.Fatalf("%s (compact %v): %v", name, compact, err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("%s (compact %v): encodeReport wrote\n%s\nwant, as json.Encoder writes,\n%s", name, compact, got.String(), want.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+)
+
+const (
+	// syslogFacility is the RFC 5424 facility used for all messages (daemon).
+	syslogFacility = 3
+
+	syslogSeverityNotice = 5
+	syslogSeverityInfo   = 6
+
+	// syslogSDID is the structured data ID for snoop parameters. 32473 is the
+	// private enterprise number reserved for documentation (RFC 5612).
+	syslogSDID = "snoop@32473"
+
+	syslogWriteTimeout = 5 * time.Second
+)
+
+// localSyslogSockets are tried in order when the address is "local".
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// ParseSyslogAddr parses a syslog destination into a network and address
+// suitable for net.Dial. Supported forms are "local" (the host's syslog
+// socket), "unix:///path/to/socket", "udp://host:port", and "tcp://host:port".
+// Remote addresses without a port default to 514.
+func ParseSyslogAddr(addr string) (network, address string, err error) {
+	if addr == "local" {
+		return "unixgram", "", nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("%q has no socket path", addr)
+		}
+		return "unixgram", u.Path, nil
+	case "udp", "tcp":
+		if u.Hostname() == "" {
+			return "", "", fmt.Errorf("%q has no host", addr)
+		}
+		port := u.Port()
+		if port == "" {
+			port = "514"
+		}
+		return u.Scheme, net.JoinHostPort(u.Hostname(), port), nil
+	default:
+		return "", "", fmt.Errorf("%q must be local or use unix, udp, or tcp", addr)
+	}
+}
+
+// SyslogReporter sends RFC 5424 messages to a local or remote syslog
+// daemon: one per newly accessed file, and a summary per container on
+// every report update.
+type SyslogReporter struct {
+	network, address string
+	hostname         string
+	pid              string
+	podName          string
+	namespace        string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogReporter creates a reporter that writes to the syslog destination
+// addr (see ParseSyslogAddr). The connection is established lazily and
+// re-established after write failures.
+func NewSyslogReporter(ctx context.Context, addr, podName, namespace string) (*SyslogReporter, error) {
+	network, address, err := ParseSyslogAddr(addr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing syslog address: %w", err)
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	clog.FromContext(ctx).Infof("Initialized syslog reporter (addr: %s)", addr)
+	return &SyslogReporter{
+		network:   network,
+		address:   address,
+		hostname:  hostname,
+		pid:       strconv.Itoa(os.Getpid()),
+		podName:   podName,
+		namespace: namespace,
+	}, nil
+}
+
+// FileAccessed emits a message recording container's first access to path.
+func (r *SyslogReporter) FileAccessed(container, path string) error {
+	return r.send(syslogSeverityInfo, "file", fmt.Sprintf("%s accessed %s", container, path),
+		"container", container, "path", path)
+}
+
+// Update emits a summary message for each container in the report.
+func (r *SyslogReporter) Update(ctx context.Context, report *Report) error {
+	var errs []error
+	for _, c := range report.Containers {
+		msg := fmt.Sprintf("%s: %d unique files, %d events", c.Name, c.UniqueFiles, c.TotalEvents)
+		if err := r.send(syslogSeverityNotice, "summary", msg,
+			"container", c.Name,
+			"unique_files", strconv.Itoa(c.UniqueFiles),
+			"total_events", strconv.FormatUint(c.TotalEvents, 10),
+			"evicted_files", strconv.FormatUint(c.EvictedFiles, 10),
+			"dropped_events", strconv.FormatUint(report.DroppedEvents, 10),
+		); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("sending syslog summary: %w", errs[0])
+	}
+	clog.FromContext(ctx).Debugf("Sent %d syslog summaries", len(report.Containers))
+	return nil
+}
+
+// Close closes the syslog connection.
+func (r *SyslogReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// send formats and writes one message, reconnecting once on failure.
+func (r *SyslogReporter) send(severity int, msgID, msg string, params ...string) error {
+	line := r.format(time.Now(), severity, msgID, msg, params...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if r.conn == nil {
+			if r.conn, err = r.dial(); err != nil {
+				return err
+			}
+		}
+		if err = r.write(line); err == nil {
+			return nil
+		}
+		r.conn.Close()
+		r.conn = nil
+	}
+	return fmt.Errorf("writing to syslog: %w", err)
+}
+
+func (r *SyslogReporter) dial() (net.Conn, error) {
+	if r.address != "" {
+		conn, err := net.DialTimeout(r.network, r.address, syslogWriteTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to syslog at %s: %w", r.address, err)
+		}
+		return conn, nil
+	}
+	for _, path := range localSyslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				return conn, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no local syslog socket found (tried %s)", strings.Join(localSyslogSockets, ", "))
+}
+
+func (r *SyslogReporter) write(line string) error {
+	r.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if r.network == "tcp" {
+		// Octet-counting framing (RFC 6587)
+		line = strconv.Itoa(len(line)) + " " + line
+	}
+	_, err := r.conn.Write([]byte(line))
+	return err
+}
+
+// format renders an RFC 5424 message. params are structured data name/value pairs.
+func (r *SyslogReporter) format(t time.Time, severity int, msgID, msg string, params ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s snoop %s %s [%s", syslogFacility*8+severity,
+		t.UTC().Format(time.RFC3339Nano), r.hostname, r.pid, msgID, syslogSDID)
+	if r.podName != "" {
+		fmt.Fprintf(&b, ` pod="%s"`, escapeSDParam(r.podName))
+	}
+	if r.namespace != "" {
+		fmt.Fprintf(&b, ` namespace="%s"`, escapeSDParam(r.namespace))
+	}
+	for i := 0; i+1 < len(params); i += 2 {
+		fmt.Fprintf(&b, ` %s="%s"`, params[i], escapeSDParam(params[i+1]))
+	}
+	b.WriteString("] ")
+	b.WriteString(msg)
+	return b.String()
+}
+
+// escapeSDParam escapes the characters RFC 5424 reserves in PARAM-VALUE.
+func escapeSDParam(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
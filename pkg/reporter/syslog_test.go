@@ -0,0 +1,125 @@
+package reporter
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslogAddr(t *testing.T) {
+	for _, tt := range []struct {
+		addr        string
+		wantNetwork string
+		wantAddress string
+		wantErr     bool
+	}{
+		{addr: "local", wantNetwork: "unixgram"},
+		{addr: "unix:///dev/log", wantNetwork: "unixgram", wantAddress: "/dev/log"},
+		{addr: "udp://syslog.example.com", wantNetwork: "udp", wantAddress: "syslog.example.com:514"},
+		{addr: "tcp://10.0.0.1:6514", wantNetwork: "tcp", wantAddress: "10.0.0.1:6514"},
+		{addr: "http://syslog.example.com", wantErr: true},
+		{addr: "udp://", wantErr: true},
+		{addr: "unix://", wantErr: true},
+	} {
+		network, address, err := ParseSyslogAddr(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSyslogAddr(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if network != tt.wantNetwork || address != tt.wantAddress {
+			t.Errorf("ParseSyslogAddr(%q) = %q, %q; want %q, %q", tt.addr, network, address, tt.wantNetwork, tt.wantAddress)
+		}
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	r := &SyslogReporter{hostname: "host", pid: "42", podName: "my-app", namespace: "default"}
+	got := r.format(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), syslogSeverityInfo, "file", "app accessed /etc/x",
+		"container", "app", "path", `/weird/"quoted"]\path`)
+	want := `<30>1 2024-01-15T10:00:00Z host snoop 42 file [snoop@32473 pod="my-app" namespace="default" container="app" path="/weird/\"quoted\"\]\\path"] app accessed /etc/x`
+	if got != want {
+		t.Errorf("format() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSyslogReporterUDP(t *testing.T) {
+	ctx := context.Background()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+
+	r, err := NewSyslogReporter(ctx, "udp://"+pc.LocalAddr().String(), "my-app", "default")
+	if err != nil {
+		t.Fatalf("NewSyslogReporter failed: %v", err)
+	}
+	defer r.Close()
+
+	if err := r.FileAccessed("app", "/etc/app.conf"); err != nil {
+		t.Fatalf("FileAccessed failed: %v", err)
+	}
+	if err := r.Update(ctx, &Report{Containers: []ContainerReport{{Name: "app", UniqueFiles: 1, TotalEvents: 3}}}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []string{`file [snoop@32473 pod="my-app" namespace="default" container="app" path="/etc/app.conf"]`, `summary [snoop@32473`} {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("reading datagram: %v", err)
+		}
+		if msg := string(buf[:n]); !strings.Contains(msg, want) {
+			t.Errorf("message = %q, want it to contain %q", msg, want)
+		}
+	}
+}
+
+func TestSyslogReporterTCPFraming(t *testing.T) {
+	ctx := context.Background()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		length, _ := br.ReadString(' ')
+		rest := make([]byte, 4096)
+		n, _ := br.Read(rest)
+		received <- length + string(rest[:n])
+	}()
+
+	r, err := NewSyslogReporter(ctx, "tcp://"+ln.Addr().String(), "", "")
+	if err != nil {
+		t.Fatalf("NewSyslogReporter failed: %v", err)
+	}
+	defer r.Close()
+
+	if err := r.FileAccessed("app", "/bin/sh"); err != nil {
+		t.Fatalf("FileAccessed failed: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		length, msg, _ := strings.Cut(got, " ")
+		if length == "" || !strings.HasPrefix(msg, "<30>1 ") {
+			t.Errorf("TCP frame = %q, want octet-counted RFC 5424 message", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for TCP message")
+	}
+}
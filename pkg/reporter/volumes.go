@@ -0,0 +1,46 @@
+package reporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ImageVolume is the VolumeSummary key of files in the container's image.
+const ImageVolume = "image"
+
+// SummarizeVolumes counts c's accessed files on each kind of volume, and
+// the rest of its unique files under ImageVolume.
+func SummarizeVolumes(c ContainerReport) map[string]int {
+	summary := map[string]int{ImageVolume: max(c.UniqueFiles-len(c.VolumeFiles), 0)}
+	for _, v := range c.VolumeFiles {
+		summary[v.Volume]++
+	}
+	return summary
+}
+
+// formatVolumeSummary formats a volume summary as "kind count" pairs, image
+// first and the rest by kind.
+func formatVolumeSummary(summary map[string]int) string {
+	kinds := make([]string, 0, len(summary))
+	for k := range summary {
+		if k != ImageVolume {
+			kinds = append(kinds, k)
+		}
+	}
+	sort.Strings(kinds)
+	parts := []string{fmt.Sprintf("%s %d", ImageVolume, summary[ImageVolume])}
+	for _, k := range kinds {
+		parts = append(parts, fmt.Sprintf("%s %d", k, summary[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// VolumePaths returns the set of c's accessed files that are on volumes.
+func VolumePaths(c ContainerReport) map[string]bool {
+	paths := make(map[string]bool, len(c.VolumeFiles))
+	for _, v := range c.VolumeFiles {
+		paths[v.Path] = true
+	}
+	return paths
+}
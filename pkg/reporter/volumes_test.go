@@ -0,0 +1,25 @@
+package reporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeVolumes(t *testing.T) {
+	c := ContainerReport{
+		UniqueFiles: 10,
+		VolumeFiles: []VolumeFile{
+			{Path: "/etc/app/app.yaml", Volume: "configMap", MountPoint: "/etc/app"},
+			{Path: "/etc/app/log.yaml", Volume: "configMap", MountPoint: "/etc/app"},
+			{Path: "/cache/index", Volume: "emptyDir", MountPoint: "/cache"},
+		},
+	}
+	summary := SummarizeVolumes(c)
+	want := map[string]int{"image": 7, "configMap": 2, "emptyDir": 1}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("SummarizeVolumes() = %v, want %v", summary, want)
+	}
+	if got, want := formatVolumeSummary(summary), "image 7, configMap 2, emptyDir 1"; got != want {
+		t.Errorf("formatVolumeSummary() = %q, want %q", got, want)
+	}
+}
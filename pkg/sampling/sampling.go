@@ -0,0 +1,114 @@
+// Package sampling adapts how many events snoop processes to backpressure,
+// so that under load it processes a known fraction of events instead of
+// letting the ring buffer overflow and drop an unknown set of them.
+package sampling
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// High and Low are the pressures, the fraction of the ring buffer in
+	// use, above which the sampling rate doubles and below which it halves.
+	High = 0.75
+	Low  = 0.25
+
+	// checkInterval is how often the pressure is checked, so the rate
+	// changes at most this often.
+	checkInterval = 100 * time.Millisecond
+)
+
+// Sampler keeps 1 in Rate events, doubling Rate while the pressure stays
+// above High, up to a maximum, and halving it once the pressure falls below
+// Low. A Rate of 1 keeps every event.
+type Sampler struct {
+	maxRate int
+	now     func() time.Time
+
+	mu            sync.Mutex
+	rate, peak    int
+	seen, skipped uint64
+	checked       time.Time
+	since         time.Time // when the rate rose above 1
+	degraded      time.Duration
+}
+
+// Stats describe how a Sampler has degraded.
+type Stats struct {
+	// Rate is the current rate, 1 in Rate events being kept, and PeakRate
+	// the highest it has been.
+	Rate, PeakRate int
+
+	// Skipped is how many events weren't kept.
+	Skipped uint64
+
+	// Degraded is how long the rate has been above 1 in total.
+	Degraded time.Duration
+}
+
+// New returns a Sampler whose rate goes up to maxRate. If maxRate is 1 or
+// less, every event is kept.
+func New(maxRate int) *Sampler {
+	return &Sampler{maxRate: maxRate, now: time.Now, rate: 1, peak: 1}
+}
+
+// Adjust changes the rate according to pressure, a fraction from 0 to 1,
+// returning the rate and whether it changed. pressure is only called, and
+// the rate only changed, once per check interval.
+func (s *Sampler) Adjust(pressure func() float64) (rate int, changed bool) {
+	if s.maxRate <= 1 {
+		return 1, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.checked) < checkInterval {
+		return s.rate, false
+	}
+	s.checked = now
+
+	prev := s.rate
+	switch p := pressure(); {
+	case p >= High && s.rate < s.maxRate:
+		s.rate = min(s.rate*2, s.maxRate)
+	case p <= Low && s.rate > 1:
+		s.rate = max(s.rate/2, 1)
+	}
+	if s.rate == prev {
+		return s.rate, false
+	}
+	s.peak = max(s.peak, s.rate)
+	if prev == 1 {
+		s.since = now
+	} else if s.rate == 1 {
+		s.degraded += now.Sub(s.since)
+	}
+	return s.rate, true
+}
+
+// Keep reports whether to process the next event.
+func (s *Sampler) Keep() bool {
+	if s.maxRate <= 1 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen++
+	if s.rate == 1 || s.seen%uint64(s.rate) == 0 {
+		return true
+	}
+	s.skipped++
+	return false
+}
+
+// Stats returns how the Sampler has degraded so far.
+func (s *Sampler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{Rate: s.rate, PeakRate: s.peak, Skipped: s.skipped, Degraded: s.degraded}
+	if s.rate > 1 {
+		stats.Degraded += s.now().Sub(s.since)
+	}
+	return stats
+}
@@ -0,0 +1,93 @@
+package sampling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := New(4)
+	s.now = func() time.Time { return now }
+
+	pressure := 0.0
+	adjust := func(p float64) (int, bool) {
+		now = now.Add(checkInterval)
+		pressure = p
+		return s.Adjust(func() float64 { return pressure })
+	}
+	keep := func(n int) (kept int) {
+		for range n {
+			if s.Keep() {
+				kept++
+			}
+		}
+		return kept
+	}
+
+	if got := keep(10); got != 10 {
+		t.Errorf("kept %d of 10 events without pressure, want all", got)
+	}
+
+	// Pressure doubles the rate up to the maximum
+	for _, want := range []int{2, 4, 4} {
+		if rate, _ := adjust(0.9); rate != want {
+			t.Errorf("rate under pressure = %d, want %d", rate, want)
+		}
+	}
+	if got := keep(100); got != 25 {
+		t.Errorf("kept %d of 100 events at rate 4, want 25", got)
+	}
+
+	// Moderate pressure keeps the rate, and low pressure halves it
+	if rate, changed := adjust(0.5); rate != 4 || changed {
+		t.Errorf("rate at moderate pressure = %d (changed %v), want 4", rate, changed)
+	}
+	for _, want := range []int{2, 1, 1} {
+		if rate, _ := adjust(0.1); rate != want {
+			t.Errorf("rate without pressure = %d, want %d", rate, want)
+		}
+	}
+
+	// The rate was above 1 from the first adjustment to the sixth
+	want := Stats{Rate: 1, PeakRate: 4, Skipped: 75, Degraded: 5 * checkInterval}
+	if got := s.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSamplerInterval(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := New(8)
+	s.now = func() time.Time { return now }
+
+	calls := 0
+	high := func() float64 { calls++; return 1 }
+
+	now = now.Add(checkInterval)
+	s.Adjust(high)
+	if rate, changed := s.Adjust(high); rate != 2 || changed || calls != 1 {
+		t.Errorf("second Adjust within the interval = %d, %v after %d pressure checks, want 2, false, 1", rate, changed, calls)
+	}
+
+	// A sampler that is still degraded counts the time so far
+	now = now.Add(time.Second)
+	if got := s.Stats().Degraded; got != time.Second {
+		t.Errorf("Degraded = %s, want 1s", got)
+	}
+}
+
+func TestSamplerDisabled(t *testing.T) {
+	s := New(1)
+	for range 10 {
+		if rate, changed := s.Adjust(func() float64 { return 1 }); rate != 1 || changed {
+			t.Fatalf("Adjust = %d, %v with a maximum rate of 1", rate, changed)
+		}
+		if !s.Keep() {
+			t.Fatal("Keep = false with a maximum rate of 1")
+		}
+	}
+	if got := s.Stats(); got != (Stats{Rate: 1, PeakRate: 1}) {
+		t.Errorf("Stats() = %+v", got)
+	}
+}
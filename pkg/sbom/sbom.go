@@ -0,0 +1,297 @@
+// Package sbom reads file-to-package ownership from SPDX and CycloneDX JSON
+// SBOMs, for package attribution when the image's package database isn't
+// reachable at runtime.
+package sbom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+const (
+	// Manager is used for packages whose purl doesn't name a type.
+	Manager = "sbom"
+
+	// fetchTimeout bounds fetching an SBOM from a URL.
+	fetchTimeout = 30 * time.Second
+
+	// maxSize bounds the size of an SBOM read from a URL.
+	maxSize = 256 << 20
+)
+
+// ValidateSource checks that src is a local file path or an http(s) URL.
+func ValidateSource(src string) error {
+	if src == "" {
+		return errors.New("SBOM source is empty")
+	}
+	if !strings.Contains(src, "://") {
+		return nil
+	}
+	u, err := url.Parse(src)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q (must be a file path or http(s) URL)", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("URL has no host")
+	}
+	return nil
+}
+
+// Loader returns a packages.Loader that reads packages from the SBOM at src,
+// a local file path or an http(s) URL. The SBOM describes the image rather
+// than a particular filesystem, so the root passed to the loader is ignored.
+// The SBOM is read once and shared by every container.
+func Loader(src string) packages.Loader {
+	var (
+		mu   sync.Mutex
+		pkgs []*packages.Package
+	)
+	return func(string) ([]*packages.Package, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if pkgs != nil {
+			return pkgs, nil
+		}
+		data, err := read(src)
+		if err != nil {
+			return nil, err
+		}
+		p, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing SBOM %s: %w", src, err)
+		}
+		pkgs = p
+		return pkgs, nil
+	}
+}
+
+// read returns the contents of the SBOM at src.
+func read(src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("reading SBOM: %w", err)
+		}
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating SBOM request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching SBOM: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching SBOM: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading SBOM response: %w", err)
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("SBOM exceeds %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// Parse reads packages and the files they own from an SPDX 2.x or
+// CycloneDX JSON document. Packages that own no files are included so they
+// show up as unused.
+func Parse(data []byte) ([]*packages.Package, error) {
+	var probe struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	switch {
+	case probe.SPDXVersion != "":
+		return parseSPDX(data)
+	case probe.BOMFormat == "CycloneDX":
+		return parseCycloneDX(data)
+	default:
+		return nil, errors.New("not an SPDX or CycloneDX JSON document")
+	}
+}
+
+// spdxDocument is the subset of an SPDX 2.x JSON document used for attribution.
+type spdxDocument struct {
+	Packages []struct {
+		SPDXID       string   `json:"SPDXID"`
+		Name         string   `json:"name"`
+		VersionInfo  string   `json:"versionInfo"`
+		HasFiles     []string `json:"hasFiles"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+	Files []struct {
+		SPDXID   string `json:"SPDXID"`
+		FileName string `json:"fileName"`
+	} `json:"files"`
+	Relationships []struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+// parseSPDX attributes files to packages through hasFiles and
+// CONTAINS/CONTAINED_BY relationships.
+func parseSPDX(data []byte) ([]*packages.Package, error) {
+	var doc spdxDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(doc.Files))
+	for _, f := range doc.Files {
+		files[f.SPDXID] = cleanPath(f.FileName)
+	}
+
+	byID := make(map[string]*packages.Package, len(doc.Packages))
+	owned := make(map[*packages.Package]map[string]bool)
+	var pkgs []*packages.Package
+	for _, p := range doc.Packages {
+		manager := Manager
+		for _, ref := range p.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				manager = purlType(ref.ReferenceLocator)
+			}
+		}
+		pkg := &packages.Package{Name: p.Name, Version: p.VersionInfo, Manager: manager}
+		byID[p.SPDXID] = pkg
+		owned[pkg] = make(map[string]bool)
+		pkgs = append(pkgs, pkg)
+		for _, id := range p.HasFiles {
+			owned[pkg][id] = true
+		}
+	}
+	for _, r := range doc.Relationships {
+		switch r.Type {
+		case "CONTAINS":
+			if pkg, ok := byID[r.Element]; ok {
+				owned[pkg][r.Related] = true
+			}
+		case "CONTAINED_BY":
+			if pkg, ok := byID[r.Related]; ok {
+				owned[pkg][r.Element] = true
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		for id := range owned[pkg] {
+			if f, ok := files[id]; ok && f != "" {
+				pkg.Files = append(pkg.Files, f)
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// cdxComponent is the subset of a CycloneDX component used for attribution.
+type cdxComponent struct {
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version"`
+	PURL       string         `json:"purl"`
+	Components []cdxComponent `json:"components"`
+	Evidence   struct {
+		Occurrences []struct {
+			Location string `json:"location"`
+		} `json:"occurrences"`
+	} `json:"evidence"`
+}
+
+// parseCycloneDX attributes files to packages through nested file
+// components and evidence occurrences (CycloneDX 1.5+).
+func parseCycloneDX(data []byte) ([]*packages.Package, error) {
+	var doc struct {
+		Components []cdxComponent `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var pkgs []*packages.Package
+	var walk func(cs []cdxComponent)
+	walk = func(cs []cdxComponent) {
+		for _, c := range cs {
+			if c.Type == "file" {
+				continue
+			}
+			manager := Manager
+			if c.PURL != "" {
+				manager = purlType(c.PURL)
+			}
+			pkg := &packages.Package{Name: c.Name, Version: c.Version, Manager: manager}
+			for _, sub := range c.Components {
+				if sub.Type == "file" {
+					if f := cleanPath(sub.Name); f != "" {
+						pkg.Files = append(pkg.Files, f)
+					}
+				}
+			}
+			for _, o := range c.Evidence.Occurrences {
+				if f := cleanPath(o.Location); f != "" {
+					pkg.Files = append(pkg.Files, f)
+				}
+			}
+			pkgs = append(pkgs, pkg)
+			walk(c.Components)
+		}
+	}
+	walk(doc.Components)
+	return pkgs, nil
+}
+
+// purlType returns the type of a package URL, e.g. "deb" for
+// pkg:deb/debian/curl@7.88.1, or Manager if it can't be parsed.
+func purlType(purl string) string {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return Manager
+	}
+	typ, _, ok := strings.Cut(rest, "/")
+	if !ok || typ == "" {
+		return Manager
+	}
+	return typ
+}
+
+// cleanPath makes an SBOM file name absolute; SPDX tools commonly write
+// paths relative to the image root, e.g. "./usr/bin/curl".
+func cleanPath(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	p := path.Clean("/" + strings.TrimPrefix(name, "./"))
+	if p == "/" {
+		return ""
+	}
+	return p
+}
@@ -0,0 +1,200 @@
+package sbom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/imjasonh/snoop/pkg/packages"
+)
+
+const spdxDoc = `{
+  "spdxVersion": "SPDX-2.3",
+  "packages": [
+    {
+      "SPDXID": "SPDXRef-Package-curl",
+      "name": "curl",
+      "versionInfo": "7.88.1-10",
+      "hasFiles": ["SPDXRef-File-curl"],
+      "externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:deb/debian/curl@7.88.1-10"}]
+    },
+    {
+      "SPDXID": "SPDXRef-Package-libcurl",
+      "name": "libcurl4",
+      "versionInfo": "7.88.1-10"
+    },
+    {
+      "SPDXID": "SPDXRef-Package-unused",
+      "name": "unused",
+      "versionInfo": "1.0"
+    }
+  ],
+  "files": [
+    {"SPDXID": "SPDXRef-File-curl", "fileName": "./usr/bin/curl"},
+    {"SPDXID": "SPDXRef-File-libcurl", "fileName": "/usr/lib/libcurl.so.4"},
+    {"SPDXID": "SPDXRef-File-libcurl-doc", "fileName": "usr/share/doc/libcurl4/copyright"}
+  ],
+  "relationships": [
+    {"spdxElementId": "SPDXRef-Package-libcurl", "relationshipType": "CONTAINS", "relatedSpdxElement": "SPDXRef-File-libcurl"},
+    {"spdxElementId": "SPDXRef-File-libcurl-doc", "relationshipType": "CONTAINED_BY", "relatedSpdxElement": "SPDXRef-Package-libcurl"},
+    {"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-Package-curl"}
+  ]
+}`
+
+const cycloneDXDoc = `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.5",
+  "components": [
+    {
+      "type": "library",
+      "name": "busybox",
+      "version": "1.36.1-r5",
+      "purl": "pkg:apk/wolfi/busybox@1.36.1-r5",
+      "evidence": {"occurrences": [{"location": "/bin/busybox"}]},
+      "components": [
+        {"type": "file", "name": "/etc/securetty"},
+        {"type": "library", "name": "nested", "version": "0.1", "evidence": {"occurrences": [{"location": "/lib/nested.so"}]}}
+      ]
+    }
+  ]
+}`
+
+func byName(pkgs []*packages.Package) map[string]*packages.Package {
+	m := make(map[string]*packages.Package)
+	for _, p := range pkgs {
+		m[p.Name] = p
+	}
+	return m
+}
+
+func TestParseSPDX(t *testing.T) {
+	pkgs, err := Parse([]byte(spdxDoc))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	got := byName(pkgs)
+	if len(got) != 3 {
+		t.Fatalf("got %d packages, want 3", len(got))
+	}
+
+	curl := got["curl"]
+	if curl.Version != "7.88.1-10" || curl.Manager != "deb" {
+		t.Errorf("curl = %s %s, want 7.88.1-10 deb", curl.Version, curl.Manager)
+	}
+	if !slices.Equal(curl.Files, []string{"/usr/bin/curl"}) {
+		t.Errorf("curl files = %v", curl.Files)
+	}
+
+	lib := got["libcurl4"]
+	slices.Sort(lib.Files)
+	if want := []string{"/usr/lib/libcurl.so.4", "/usr/share/doc/libcurl4/copyright"}; !slices.Equal(lib.Files, want) {
+		t.Errorf("libcurl4 files = %v, want %v", lib.Files, want)
+	}
+	if lib.Manager != Manager {
+		t.Errorf("libcurl4 manager = %q, want %q", lib.Manager, Manager)
+	}
+
+	if len(got["unused"].Files) != 0 {
+		t.Errorf("unused files = %v, want none", got["unused"].Files)
+	}
+}
+
+func TestParseCycloneDX(t *testing.T) {
+	pkgs, err := Parse([]byte(cycloneDXDoc))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	got := byName(pkgs)
+	if len(got) != 2 {
+		t.Fatalf("got %d packages, want 2: %v", len(got), got)
+	}
+	bb := got["busybox"]
+	if bb.Manager != "apk" {
+		t.Errorf("busybox manager = %q, want apk", bb.Manager)
+	}
+	slices.Sort(bb.Files)
+	if want := []string{"/bin/busybox", "/etc/securetty"}; !slices.Equal(bb.Files, want) {
+		t.Errorf("busybox files = %v, want %v", bb.Files, want)
+	}
+	if !slices.Equal(got["nested"].Files, []string{"/lib/nested.so"}) {
+		t.Errorf("nested files = %v", got["nested"].Files)
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := Parse([]byte(`{"foo": "bar"}`)); err == nil {
+		t.Error("expected error for non-SBOM JSON")
+	}
+	if _, err := Parse([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestLoaderFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sbom.spdx.json")
+	if err := os.WriteFile(path, []byte(spdxDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := packages.Load("/proc/1/root", Loader(path))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if pkg := db.Lookup("/usr/bin/curl"); pkg == nil || pkg.Name != "curl" {
+		t.Errorf("Lookup(/usr/bin/curl) = %v, want curl", pkg)
+	}
+}
+
+func TestLoaderURL(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(cycloneDXDoc))
+	}))
+	defer srv.Close()
+
+	load := Loader(srv.URL + "/sbom.cdx.json")
+	for range 2 {
+		pkgs, err := load("")
+		if err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+		if len(pkgs) != 2 {
+			t.Errorf("got %d packages, want 2", len(pkgs))
+		}
+	}
+	// The SBOM is shared across containers rather than refetched
+	if requests != 1 {
+		t.Errorf("server got %d requests, want 1", requests)
+	}
+}
+
+func TestLoaderURLError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	if _, err := Loader(srv.URL)(""); err == nil {
+		t.Error("expected error for 404")
+	}
+}
+
+func TestValidateSource(t *testing.T) {
+	for _, tt := range []struct {
+		src     string
+		wantErr bool
+	}{
+		{"/etc/snoop/sbom.json", false},
+		{"sbom.json", false},
+		{"https://example.com/sbom.json", false},
+		{"http://sbom-server:8080/app", false},
+		{"", true},
+		{"oci://registry/app@sha256:abc", true},
+		{"https://", true},
+	} {
+		if err := ValidateSource(tt.src); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSource(%q) error = %v, wantErr %v", tt.src, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package serving secures snoop's HTTP servers with TLS and client
+// authentication by client certificate (mTLS) or bearer token.
+package serving
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadInterval is how often certificates, client CAs, and tokens are
+// re-read, so rotated files (e.g. from cert-manager or spiffe-helper) are
+// picked up without a restart.
+const reloadInterval = time.Minute
+
+// Options configure a server's TLS and authentication. The zero value
+// serves plain HTTP to anyone.
+type Options struct {
+	// CertFile and KeyFile, if set, serve TLS with this key pair.
+	CertFile, KeyFile string
+
+	// ClientCAFile, if set, authenticates clients presenting a certificate
+	// signed by one of these CAs. It requires TLS.
+	ClientCAFile string
+
+	// ClientIDs, if set, restricts certificate authentication to clients
+	// whose certificate has one of these URI SANs (such as SPIFFE IDs), DNS
+	// SANs, or common names.
+	ClientIDs []string
+
+	// TokenFile, if set, authenticates clients sending its contents as a
+	// bearer token.
+	TokenFile string
+
+	// Public lists paths served without authentication, such as health
+	// checks probed by the kubelet.
+	Public []string
+}
+
+// TLS reports whether the server serves TLS.
+func (o Options) TLS() bool { return o.CertFile != "" }
+
+// authenticated reports whether clients must authenticate.
+func (o Options) authenticated() bool { return o.ClientCAFile != "" || o.TokenFile != "" }
+
+// Server secures an HTTP server according to Options.
+type Server struct {
+	opts Options
+
+	cert   *cached[*tls.Certificate]
+	pool   *cached[*x509.CertPool]
+	token  *cached[string]
+	config *tls.Config
+}
+
+// New loads the files in opts, failing if any can't be read.
+func New(opts Options) (*Server, error) {
+	s := &Server{opts: opts}
+	if opts.CertFile != "" {
+		s.cert = &cached[*tls.Certificate]{load: func() (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+			return &cert, err
+		}}
+		if _, err := s.cert.get(); err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		s.config = &tls.Config{MinVersion: tls.VersionTLS12, GetConfigForClient: s.configForClient}
+	}
+	if opts.ClientCAFile != "" {
+		s.pool = &cached[*x509.CertPool]{load: func() (*x509.CertPool, error) {
+			data, err := os.ReadFile(opts.ClientCAFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("%s: no PEM certificates", opts.ClientCAFile)
+			}
+			return pool, nil
+		}}
+		if _, err := s.pool.get(); err != nil {
+			return nil, fmt.Errorf("loading client CA: %w", err)
+		}
+	}
+	if opts.TokenFile != "" {
+		s.token = &cached[string]{load: func() (string, error) {
+			data, err := os.ReadFile(opts.TokenFile)
+			if err != nil {
+				return "", err
+			}
+			token := strings.TrimSpace(string(data))
+			if token == "" {
+				return "", fmt.Errorf("%s is empty", opts.TokenFile)
+			}
+			return token, nil
+		}}
+		if _, err := s.token.get(); err != nil {
+			return nil, fmt.Errorf("loading token: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// TLSConfig returns the server's TLS configuration, or nil if it serves
+// plain HTTP. Client certificates are verified if given, and required by
+// Handler.
+func (s *Server) TLSConfig() *tls.Config {
+	return s.config
+}
+
+// configForClient builds the TLS configuration for a connection from the
+// current certificate and client CAs.
+func (s *Server) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cert, err := s.cert.get()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{*cert}}
+	if s.pool != nil {
+		pool, err := s.pool.get()
+		if err != nil {
+			return nil, err
+		}
+		// Verified here but required by Handler, so Public paths stay
+		// reachable by clients without certificates
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		cfg.ClientCAs = pool
+	}
+	return cfg, nil
+}
+
+// Handler wraps h to reject unauthenticated requests for paths other than
+// Public ones. A request is authenticated by either a verified client
+// certificate with an allowed identity or the bearer token.
+func (s *Server) Handler(h http.Handler) http.Handler {
+	if !s.opts.authenticated() {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(s.opts.Public, r.URL.Path) || s.clientAllowed(r) || s.tokenValid(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if s.token != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// clientAllowed reports whether r has a verified client certificate with an
+// allowed identity.
+func (s *Server) clientAllowed(r *http.Request) bool {
+	if s.pool == nil || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return false
+	}
+	if len(s.opts.ClientIDs) == 0 {
+		return true
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	ids := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	for _, uri := range leaf.URIs {
+		ids = append(ids, uri.String())
+	}
+	for _, id := range ids {
+		if id != "" && slices.Contains(s.opts.ClientIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenValid reports whether r carries the bearer token.
+func (s *Server) tokenValid(r *http.Request) bool {
+	if s.token == nil {
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	want, err := s.token.get()
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// cached holds a value loaded from files, re-loading it at most every
+// reloadInterval. If a re-load fails, as it may mid-rotation, the previous
+// value is kept.
+type cached[T any] struct {
+	load func() (T, error)
+
+	mu     sync.Mutex
+	value  T
+	loaded time.Time
+	ok     bool
+}
+
+func (c *cached[T]) get() (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ok && time.Since(c.loaded) < reloadInterval {
+		return c.value, nil
+	}
+	v, err := c.load()
+	if err != nil {
+		if c.ok {
+			return c.value, nil
+		}
+		return v, err
+	}
+	c.value, c.loaded, c.ok = v, time.Now(), true
+	return v, nil
+}
@@ -0,0 +1,223 @@
+package serving
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert issues a certificate for id, signed by parent (self-signed if
+// nil), with id as its common name or, if it is a URI, its URI SAN.
+func testCert(t *testing.T, id string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: id},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if u, err := url.Parse(id); err == nil && u.Scheme != "" {
+		tmpl.Subject.CommonName = ""
+		tmpl.URIs = []*url.URL{u}
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writePEM writes cert and its key to files in dir, returning their paths.
+func writePEM(t *testing.T, dir, name string, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	der, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// serve serves an OK response with opts, returning the server's URL.
+func serve(t *testing.T, opts Options) string {
+	t.Helper()
+	s, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler:   s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+		TLSConfig: s.TLSConfig(),
+	}
+	t.Cleanup(func() { srv.Close() })
+	scheme := "http"
+	if opts.TLS() {
+		scheme = "https"
+		go srv.ServeTLS(ln, "", "")
+	} else {
+		go srv.Serve(ln)
+	}
+	return scheme + "://" + ln.Addr().String()
+}
+
+// get returns the status of a GET of url by a client trusting ca and
+// presenting cert, if they're set, and token, if it isn't empty.
+func get(t *testing.T, url string, ca, cert *tls.Certificate, token string) int {
+	t.Helper()
+	cfg := &tls.Config{}
+	if ca != nil {
+		cfg.RootCAs = x509.NewCertPool()
+		cfg.RootCAs.AddCert(ca.Leaf)
+	}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestPlain(t *testing.T) {
+	url := serve(t, Options{})
+	if got := get(t, url+"/metrics", nil, nil, ""); got != http.StatusOK {
+		t.Errorf("GET /metrics = %d, want 200", got)
+	}
+}
+
+func TestToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, Options{TokenFile: tokenFile, Public: []string{"/healthz"}})
+
+	for _, tt := range []struct {
+		path, token string
+		want        int
+	}{
+		{"/metrics", "s3cret", http.StatusOK},
+		{"/metrics", "wrong", http.StatusUnauthorized},
+		{"/metrics", "", http.StatusUnauthorized},
+		{"/healthz", "", http.StatusOK},
+	} {
+		if got := get(t, url+tt.path, nil, nil, tt.token); got != tt.want {
+			t.Errorf("GET %s with token %q = %d, want %d", tt.path, tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := testCert(t, "snoop-ca", nil)
+	caFile, _ := writePEM(t, dir, "ca", ca)
+	serverCert := testCert(t, "snoop", &ca)
+	certFile, keyFile := writePEM(t, dir, "server", serverCert)
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	url := serve(t, Options{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+		ClientIDs:    []string{"spiffe://cluster.local/ns/monitoring/sa/prometheus"},
+		TokenFile:    tokenFile,
+		Public:       []string{"/healthz"},
+	})
+
+	prometheus := testCert(t, "spiffe://cluster.local/ns/monitoring/sa/prometheus", &ca)
+	other := testCert(t, "spiffe://cluster.local/ns/default/sa/app", &ca)
+	untrusted := testCert(t, "spiffe://cluster.local/ns/monitoring/sa/prometheus", nil)
+	for _, tt := range []struct {
+		desc  string
+		path  string
+		cert  *tls.Certificate
+		token string
+		want  int
+	}{
+		{"allowed SPIFFE ID", "/metrics", &prometheus, "", http.StatusOK},
+		{"other SPIFFE ID", "/metrics", &other, "", http.StatusUnauthorized},
+		{"other SPIFFE ID with token", "/metrics", &other, "s3cret", http.StatusOK},
+		{"no certificate", "/metrics", nil, "", http.StatusUnauthorized},
+		{"token", "/metrics", nil, "s3cret", http.StatusOK},
+		{"public path", "/healthz", nil, "", http.StatusOK},
+	} {
+		if got := get(t, url+tt.path, &ca, tt.cert, tt.token); got != tt.want {
+			t.Errorf("%s: GET %s = %d, want %d", tt.desc, tt.path, got, tt.want)
+		}
+	}
+
+	// A certificate from another CA isn't accepted
+	if got := get(t, url+"/metrics", &ca, &untrusted, ""); got != http.StatusUnauthorized {
+		t.Errorf("GET with an untrusted certificate = %d, want 401", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{
+		{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")},
+		{ClientCAFile: empty},
+		{TokenFile: empty},
+		{TokenFile: filepath.Join(dir, "missing")},
+	} {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) succeeded", opts)
+		}
+	}
+}
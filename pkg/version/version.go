@@ -0,0 +1,70 @@
+// Package version identifies the running snoop build.
+package version
+
+import (
+	"runtime/debug"
+
+	"github.com/imjasonh/snoop/pkg/ebpf/bpf"
+)
+
+// These are set at build time, e.g. with
+//
+//	go build -ldflags "-X github.com/imjasonh/snoop/pkg/version.version=v1.2.3"
+//
+// Unset values fall back to what the Go toolchain recorded in the binary.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// Info identifies a snoop build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+
+	// BPFObject is the sha256 digest of the embedded eBPF object, which
+	// changes when the probe does even if the version doesn't.
+	BPFObject string `json:"bpf_object"`
+}
+
+// Get returns the running build's Info.
+func Get() Info {
+	info := Info{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		BPFObject: bpf.ObjectDigest(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = bi.GoVersion
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		var modified bool
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		// Flag builds from a dirty tree, unless the commit was set explicitly
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+	return info
+}
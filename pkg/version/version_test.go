@@ -0,0 +1,30 @@
+package version
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	info := Get()
+	// Tests have no main module version or VCS information
+	if info.Version != "devel" {
+		t.Errorf("Version = %q, want devel", info.Version)
+	}
+	if !strings.HasPrefix(info.GoVersion, "go") {
+		t.Errorf("GoVersion = %q", info.GoVersion)
+	}
+	if !strings.HasPrefix(info.BPFObject, "sha256:") || len(info.BPFObject) != len("sha256:")+64 {
+		t.Errorf("BPFObject = %q, want a sha256 digest", info.BPFObject)
+	}
+}
+
+func TestGetLinkerFlags(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "v1.2.3", "abc123", "2024-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc123" || info.BuildDate != "2024-01-02T03:04:05Z" {
+		t.Errorf("Get() = %+v, want the linker flag values", info)
+	}
+}